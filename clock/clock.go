@@ -0,0 +1,68 @@
+// Package clock abstracts the passage of time so that nodes can be simulated
+// deterministically. Real returns a Clock backed by the system clock, while
+// Fake is a Clock which only moves forward when it's advanced.
+package clock
+
+import "time"
+
+// Clock tells the current time and creates timers and tickers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer which fires once after d.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a Ticker which fires every d. d must be greater than
+	// zero.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a single event created by a Clock. See time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent to when the Timer fires.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing. Returns false if the Timer
+	// already fired or was stopped.
+	Stop() bool
+	// Reset changes the Timer to fire after d. Returns false if the Timer
+	// already fired or was stopped.
+	Reset(d time.Duration) bool
+}
+
+// Ticker sends the time on an interval. See time.Ticker.
+type Ticker interface {
+	// C returns the channel the time is sent to on every tick.
+	C() <-chan time.Time
+	// Stop turns off the Ticker.
+	Stop()
+}
+
+// Since returns the time elapsed since t according to c.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Real returns a Clock backed by the system clock.
+func Real() Clock { return realClock{} }
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{t: time.NewTicker(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (rt realTimer) C() <-chan time.Time        { return rt.t.C }
+func (rt realTimer) Stop() bool                 { return rt.t.Stop() }
+func (rt realTimer) Reset(d time.Duration) bool { return rt.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (rt realTicker) C() <-chan time.Time { return rt.t.C }
+func (rt realTicker) Stop()               { rt.t.Stop() }
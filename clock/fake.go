@@ -0,0 +1,144 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock which only moves forward when Advance is called. Timers
+// and tickers created by Fake fire as the time passes their deadlines.
+// Fake is safe for concurrent use.
+type Fake struct {
+	mut     sync.Mutex
+	now     time.Time
+	waiters map[*fakeWaiter]struct{}
+}
+
+// NewFake creates a Fake set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{
+		now:     now,
+		waiters: make(map[*fakeWaiter]struct{}),
+	}
+}
+
+// Now returns the current time of f.
+func (f *Fake) Now() time.Time {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.now
+}
+
+// Advance moves f forward by d, firing the timers and tickers whose
+// deadlines passed in order. Like a time.Ticker, a Ticker which isn't
+// being read from drops ticks.
+func (f *Fake) Advance(d time.Duration) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		w := f.next(end)
+		if w == nil {
+			break
+		}
+		f.now = w.when
+
+		select {
+		case w.ch <- w.when:
+		default:
+		}
+
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			delete(f.waiters, w)
+		}
+	}
+	f.now = end
+}
+
+// next returns the waiter with the earliest deadline no later than end.
+// Must be called with the mutex held.
+func (f *Fake) next(end time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for w := range f.waiters {
+		if w.when.After(end) {
+			continue
+		}
+		if next == nil || w.when.Before(next.when) {
+			next = w
+		}
+	}
+	return next
+}
+
+// Waiters returns the number of timers and tickers which haven't fired or
+// been stopped. Tests can use Waiters to wait for goroutines to be blocked
+// on f before advancing it.
+func (f *Fake) Waiters() int {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return len(f.waiters)
+}
+
+// NewTimer creates a Timer which fires once f advances by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.schedule(d, 0)
+}
+
+// NewTicker creates a Ticker which fires every time f advances by d.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.schedule(d, d)}
+}
+
+func (f *Fake) schedule(d, period time.Duration) *fakeWaiter {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	w := &fakeWaiter{
+		f:      f,
+		when:   f.now.Add(d),
+		period: period,
+		ch:     make(chan time.Time, 1),
+	}
+	f.waiters[w] = struct{}{}
+	return w
+}
+
+// fakeWaiter implements Timer and Ticker for Fake.
+type fakeWaiter struct {
+	f      *Fake
+	when   time.Time
+	period time.Duration // Zero for timers.
+	ch     chan time.Time
+}
+
+func (w *fakeWaiter) C() <-chan time.Time { return w.ch }
+
+func (w *fakeWaiter) Stop() bool {
+	w.f.mut.Lock()
+	defer w.f.mut.Unlock()
+
+	_, active := w.f.waiters[w]
+	delete(w.f.waiters, w)
+	return active
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.f.mut.Lock()
+	defer w.f.mut.Unlock()
+
+	_, active := w.f.waiters[w]
+	w.when = w.f.now.Add(d)
+	w.f.waiters[w] = struct{}{}
+	return active
+}
+
+// fakeTicker implements Ticker for Fake.
+type fakeTicker struct{ *fakeWaiter }
+
+func (t fakeTicker) Stop() { t.fakeWaiter.Stop() }
@@ -0,0 +1,49 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(0, 0)
+	f := NewFake(start)
+
+	var (
+		timer  = f.NewTimer(time.Second)
+		ticker = f.NewTicker(400 * time.Millisecond)
+	)
+	require.Equal(t, 2, f.Waiters())
+
+	f.Advance(500 * time.Millisecond)
+	require.Equal(t, start.Add(500*time.Millisecond), f.Now())
+	require.Equal(t, start.Add(400*time.Millisecond), <-ticker.C())
+	requireEmpty(t, timer.C())
+
+	// Ticks which aren't read are dropped.
+	f.Advance(time.Second)
+	require.Equal(t, start.Add(time.Second), <-timer.C())
+	require.Equal(t, start.Add(800*time.Millisecond), <-ticker.C())
+	requireEmpty(t, ticker.C())
+	require.Equal(t, 1, f.Waiters(), "fired timers stop waiting")
+
+	ticker.Stop()
+	require.Equal(t, 0, f.Waiters())
+
+	// Fired timers can be reset.
+	require.False(t, timer.Reset(time.Second))
+	require.True(t, timer.Stop())
+	f.Advance(time.Minute)
+	requireEmpty(t, timer.C())
+}
+
+func requireEmpty(t *testing.T, ch <-chan time.Time) {
+	t.Helper()
+	select {
+	case v := <-ch:
+		require.FailNow(t, "unexpected tick", "got %s", v)
+	default:
+	}
+}
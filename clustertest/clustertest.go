@@ -0,0 +1,100 @@
+// Package clustertest provides utilities for testing the behavior of a
+// Croissant cluster without any networking. Clusters are built with the
+// simulation package.
+package clustertest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/simulation"
+	"github.com/stretchr/testify/require"
+)
+
+// Config describes a simulated cluster.
+type Config struct {
+	// Number of nodes in the cluster. Must be at least 1.
+	NumNodes int
+
+	// Number of leaves and neighbors each node tracks. Both default to 8 if
+	// unset.
+	NumLeaves, NumNeighbors int
+
+	// Size is the bit length of IDs (one of 8, 16, 32, 64, 128) and Base is
+	// the power-of-two base used for routing. Defaults to 32 and 16.
+	Size, Base int
+}
+
+func (c *Config) applyDefaults() {
+	if c.NumLeaves == 0 {
+		c.NumLeaves = 8
+	}
+	if c.NumNeighbors == 0 {
+		c.NumNeighbors = 8
+	}
+	if c.Size == 0 {
+		c.Size = 32
+	}
+	if c.Base == 0 {
+		c.Base = 16
+	}
+}
+
+// AssertRoutingCorrect builds a simulated cluster from cfg and routes
+// numKeys random keys through it, each starting from a random node. The test
+// fails if any key does not end up at the node closest to it, or if a
+// routing cycle is detected.
+func AssertRoutingCorrect(t testing.TB, cfg Config, numKeys int, rnd *rand.Rand) {
+	t.Helper()
+	cfg.applyDefaults()
+
+	c := createCluster(t, cfg, rnd)
+	var (
+		nodes = c.Nodes()
+		max   = id.MaxForSize(cfg.Size)
+	)
+
+	for i := 0; i < numKeys; i++ {
+		key := randomID(rnd, max)
+
+		seed := nodes[rnd.Intn(len(nodes))]
+		path, err := c.Route(seed, key)
+		require.NoError(t, err, "routing key %s from %s", key, seed)
+
+		if dest, owner := path[len(path)-1], c.Owner(key); dest != owner {
+			require.Failf(t, "found routing to wrong node",
+				"key %s routed to %s (distance %s) but %s is closer (distance %s)",
+				key, dest, api.Distance(dest, key, cfg.Size),
+				owner, api.Distance(owner, key, cfg.Size),
+			)
+		}
+	}
+}
+
+// createCluster builds a simulation.Cluster from cfg, seeding its random
+// node IDs and joins from rnd.
+func createCluster(t testing.TB, cfg Config, rnd *rand.Rand) *simulation.Cluster {
+	t.Helper()
+	require.Greater(t, cfg.NumNodes, 0, "cluster must have at least one node")
+
+	c, err := simulation.New(simulation.Config{
+		NumNodes:     cfg.NumNodes,
+		NumLeaves:    cfg.NumLeaves,
+		NumNeighbors: cfg.NumNeighbors,
+		IDSize:       cfg.Size,
+		IDBase:       cfg.Base,
+		Seed:         rnd.Int63(),
+	})
+	require.NoError(t, err, "failed to create cluster")
+	return c
+}
+
+// randomID returns a random ID no bigger than max.
+func randomID(rnd *rand.Rand, max id.ID) id.ID {
+	return id.ID{
+		High: rnd.Uint64() & max.High,
+		Low:  rnd.Uint64() & max.Low,
+	}
+}
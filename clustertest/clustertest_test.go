@@ -0,0 +1,95 @@
+package clustertest
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertRoutingCorrect(t *testing.T) {
+	tt := []Config{
+		{NumNodes: 1},
+		{NumNodes: 500, Size: 32, Base: 16},
+		{NumNodes: 500, Size: 16, Base: 4},
+		{NumNodes: 500, Size: 64, Base: 8, NumLeaves: 4, NumNeighbors: 4},
+	}
+
+	for _, cfg := range tt {
+		name := fmt.Sprintf("nodes=%d,size=%d,base=%d", cfg.NumNodes, cfg.Size, cfg.Base)
+		t.Run(name, func(t *testing.T) {
+			AssertRoutingCorrect(t, cfg, 10_000, rand.New(rand.NewSource(0)))
+		})
+	}
+}
+
+// TestRouting_Wraparound checks that keys at the edges of the keyspace are
+// routed consistently, and that nodes at the edges of the ring route them
+// directly to their owner through their leaves.
+func TestRouting_Wraparound(t *testing.T) {
+	tt := []Config{
+		{NumNodes: 5},
+		{NumNodes: 64},
+		{NumNodes: 30, Size: 8, Base: 2},
+		{NumNodes: 64, Size: 128},
+	}
+
+	for _, cfg := range tt {
+		name := fmt.Sprintf("nodes=%d,size=%d,base=%d", cfg.NumNodes, cfg.Size, cfg.Base)
+		t.Run(name, func(t *testing.T) {
+			cfg.applyDefaults()
+
+			for seed := int64(0); seed < 10; seed++ {
+				c := createCluster(t, cfg, rand.New(rand.NewSource(seed)))
+
+				nodes := c.Nodes()
+				sort.Slice(nodes, func(i, j int) bool {
+					return id.Compare(nodes[i], nodes[j]) < 0
+				})
+
+				max := id.MaxForSize(cfg.Size)
+				keys := []id.ID{
+					id.Zero,
+					{Low: 1},
+					idSub(max, 1),
+					max,
+				}
+
+				for _, key := range keys {
+					owner := nodes[0]
+					for _, n := range nodes {
+						if api.Closer(n, owner, key, cfg.Size) {
+							owner = n
+						}
+					}
+
+					// Every node must agree on the owner.
+					for _, n := range nodes {
+						path, err := c.Route(n, key)
+						require.NoError(t, err)
+						require.Equal(t, owner, path[len(path)-1], "key %s from %s", key, n)
+					}
+
+					// Nodes at both ends of the ring know about each other through
+					// their leaves, so they should route directly to the owner.
+					edges := []id.ID{nodes[0], nodes[len(nodes)-1]}
+					for _, n := range edges {
+						path, err := c.Route(n, key)
+						require.NoError(t, err)
+						require.LessOrEqual(t, len(path), 2, "route for key %s from %s: %v", key, n, path)
+					}
+				}
+			}
+		})
+	}
+}
+
+func idSub(v id.ID, o uint64) id.ID {
+	low, borrow := bits.Sub64(v.Low, o, 0)
+	return id.ID{High: v.High - borrow, Low: low}
+}
@@ -0,0 +1,213 @@
+package croissanttest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+// SetFault makes RPCs sent from the node with index from to the node with
+// index to misbehave as described by f. The zero Fault removes the fault.
+func (c *Cluster) SetFault(from, to int, f Fault) {
+	c.net.setFault(c.Node(from).Addr, c.Node(to).Addr, f)
+}
+
+// Block prevents the node with index from from reaching the node with
+// index to, creating an asymmetric partition: to may still reach from.
+// Existing connections from from to to are broken.
+func (c *Cluster) Block(from, to int) {
+	c.net.block(c.Node(from).Addr, c.Node(to).Addr)
+}
+
+// Unblock undoes Block.
+func (c *Cluster) Unblock(from, to int) {
+	c.net.unblock(c.Node(from).Addr, c.Node(to).Addr)
+}
+
+// ClearFaults removes every fault set by SetFault and every link blocked by
+// Block. Partitions are removed with Heal.
+func (c *Cluster) ClearFaults() {
+	c.net.clearFaults()
+}
+
+// CheckRouting routes each key from every alive node by following the next
+// peer chosen by each node. An error is returned if a routing cycle is
+// found, if a key is routed to a node which isn't alive, or if a key
+// doesn't end up at its Owner. CheckRouting should be called once the
+// cluster converged and isn't partitioned.
+func (c *Cluster) CheckRouting(keys ...id.ID) error {
+	var (
+		alive  = c.Alive()
+		byAddr = make(map[string]*Node, len(alive))
+	)
+	for _, n := range alive {
+		byAddr[n.Addr] = n
+	}
+
+	for _, key := range keys {
+		owner := c.Owner(key)
+
+		for _, start := range alive {
+			var (
+				cur  = start
+				path = []string{cur.Addr}
+			)
+			for {
+				next, self, err := cur.NextPeer(key)
+				if err != nil {
+					return fmt.Errorf("routing %s from %s: %w", key, start.Addr, err)
+				} else if self {
+					break
+				}
+
+				nextNode, ok := byAddr[next.Addr]
+				if !ok {
+					return fmt.Errorf("routing %s from %s: %s routed to %s, which isn't alive", key, start.Addr, cur.Addr, next.Addr)
+				}
+				for _, addr := range path {
+					if addr == next.Addr {
+						return fmt.Errorf("routing %s from %s: found routing cycle %v", key, start.Addr, append(path, next.Addr))
+					}
+				}
+				cur = nextNode
+				path = append(path, cur.Addr)
+			}
+
+			if cur != owner {
+				return fmt.Errorf("routing %s from %s: routed to %s, but %s owns the key", key, start.Addr, cur.Addr, owner.Addr)
+			}
+		}
+	}
+	return nil
+}
+
+// ChaosConfig configures RunChaos.
+type ChaosConfig struct {
+	// Rounds is the number of rounds of faults to run. Defaults to 3 if
+	// unset.
+	Rounds int
+
+	// RoundDuration is how long faults are applied in each round. Defaults
+	// to 2s if unset.
+	RoundDuration time.Duration
+
+	// Fault is applied to FaultyLinks random links between alive nodes in
+	// each round. Defaults to dropping 20% of RPCs and delaying them by
+	// 10ms if unset.
+	Fault Fault
+	// FaultyLinks is the number of links which get Fault in each round.
+	// Defaults to the number of alive nodes if unset.
+	FaultyLinks int
+
+	// BlockedLinks is the number of random links blocked in each round,
+	// creating asymmetric partitions. See Cluster.Block.
+	BlockedLinks int
+
+	// Kills is the number of random nodes killed in each round. At least one
+	// node is always kept alive.
+	Kills int
+
+	// SettleTimeout is how long the cluster may take to converge after
+	// faults are removed at the end of each round. Defaults to 30s if unset.
+	SettleTimeout time.Duration
+
+	// Keys are the keys checked with CheckRouting after each round. Defaults
+	// to 100 random keys if unset.
+	Keys []id.ID
+
+	// Check, if set, checks application invariants after each round, once
+	// the cluster converged and routes correctly.
+	Check func(c *Cluster) error
+}
+
+// RunChaos runs rounds of random faults against the cluster. Each round,
+// faults are applied and random nodes are killed as configured by cfg.
+// Once the round ends, faults are removed and the test fails unless the
+// cluster converges, routes every key to its owner, and passes cfg.Check.
+//
+// Random choices are made from Config.Seed, so chaos runs can be repeated
+// by reusing a seed.
+func (c *Cluster) RunChaos(cfg ChaosConfig) {
+	c.t.Helper()
+
+	if cfg.Rounds == 0 {
+		cfg.Rounds = 3
+	}
+	if cfg.RoundDuration == 0 {
+		cfg.RoundDuration = 2 * time.Second
+	}
+	if cfg.Fault == (Fault{}) {
+		cfg.Fault = Fault{Drop: 0.2, Delay: 10 * time.Millisecond}
+	}
+	if cfg.SettleTimeout == 0 {
+		cfg.SettleTimeout = 30 * time.Second
+	}
+	if cfg.Keys == nil {
+		size := c.Node(0).State().Size
+		for i := 0; i < 100; i++ {
+			cfg.Keys = append(cfg.Keys, c.randomID(size))
+		}
+	}
+
+	c.t.Logf("running %d rounds of chaos with seed %d", cfg.Rounds, c.seed)
+
+	for round := 1; round <= cfg.Rounds; round++ {
+		alive := c.Alive()
+
+		faultyLinks := cfg.FaultyLinks
+		if faultyLinks == 0 {
+			faultyLinks = len(alive)
+		}
+		for i := 0; i < faultyLinks && len(alive) > 1; i++ {
+			from, to := c.randomLink(alive)
+			c.SetFault(from, to, cfg.Fault)
+		}
+		for i := 0; i < cfg.BlockedLinks && len(alive) > 1; i++ {
+			c.Block(c.randomLink(alive))
+		}
+		for i := 0; i < cfg.Kills && len(alive) > 1; i++ {
+			idx := c.intn(len(alive))
+			c.Kill(alive[idx].Index)
+			alive = append(alive[:idx], alive[idx+1:]...)
+		}
+
+		time.Sleep(cfg.RoundDuration)
+		c.ClearFaults()
+
+		c.WaitConverged(cfg.SettleTimeout)
+		require.NoError(c.t, c.CheckRouting(cfg.Keys...), "routing is incorrect after round %d", round)
+		if cfg.Check != nil {
+			require.NoError(c.t, cfg.Check(c), "check failed after round %d", round)
+		}
+	}
+}
+
+// randomLink returns the indices of two different random nodes from nodes.
+func (c *Cluster) randomLink(nodes []*Node) (from, to int) {
+	i := c.intn(len(nodes))
+	j := c.intn(len(nodes) - 1)
+	if j >= i {
+		j++
+	}
+	return nodes[i].Index, nodes[j].Index
+}
+
+func (c *Cluster) intn(n int) int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.rand.Intn(n)
+}
+
+// randomID returns a random ID of the given bit size.
+func (c *Cluster) randomID(size int) id.ID {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	max := id.MaxForSize(size)
+	return id.ID{
+		High: c.rand.Uint64() & max.High,
+		Low:  c.rand.Uint64() & max.Low,
+	}
+}
@@ -0,0 +1,71 @@
+package croissanttest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCluster_Faults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	c := New(t, Config{NumNodes: 2, Seed: 1})
+	c.WaitConverged(10 * time.Second)
+
+	var (
+		a = c.Node(0)
+		b = c.Node(1)
+	)
+	getState := func(from, to *Node) error {
+		cc, err := c.net.dialer(from.Addr)(to.Addr, grpc.WithInsecure())
+		require.NoError(t, err)
+		defer cc.Close()
+		_, err = nodepb.NewNodeClient(cc).GetState(ctx, &nodepb.GetStateRequest{})
+		return err
+	}
+
+	c.SetFault(0, 1, Fault{Drop: 1})
+	require.Equal(t, codes.Unavailable, status.Code(getState(a, b)))
+	require.NoError(t, getState(b, a), "faults only apply in one direction")
+
+	c.SetFault(0, 1, Fault{Delay: 100 * time.Millisecond})
+	start := time.Now()
+	require.NoError(t, getState(a, b))
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(100*time.Millisecond))
+
+	c.ClearFaults()
+	c.Block(0, 1)
+	require.Error(t, getState(a, b))
+	require.NoError(t, getState(b, a), "blocks only apply in one direction")
+
+	c.Unblock(0, 1)
+	require.NoError(t, getState(a, b))
+}
+
+func TestCluster_RunChaos(t *testing.T) {
+	c := New(t, Config{NumNodes: 6, Seed: 1})
+	c.WaitConverged(10 * time.Second)
+	require.NoError(t, c.CheckRouting(id.Zero, id.ID{Low: 1 << 31}))
+
+	c.RunChaos(ChaosConfig{
+		Rounds:        2,
+		RoundDuration: time.Second,
+		Kills:         1,
+		Check: func(c *Cluster) error {
+			if len(c.Alive()) == 0 {
+				return errors.New("no nodes alive")
+			}
+			return nil
+		},
+	})
+	require.Len(t, c.Alive(), 4)
+}
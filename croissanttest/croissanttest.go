@@ -0,0 +1,426 @@
+// Package croissanttest runs clusters of in-process nodes for testing
+// Applications. Nodes communicate over an in-memory transport, allowing
+// tests to kill nodes and partition the cluster without any networking.
+//
+// Faults such as dropped, delayed, or duplicated RPCs can be injected
+// between nodes, and RunChaos runs rounds of random faults while checking
+// that the cluster recovers.
+package croissanttest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// Config describes a Cluster.
+type Config struct {
+	// NumNodes is the number of nodes to start. Must be at least 1.
+	NumNodes int
+
+	// Configure, if set, is called with the index and config of each node
+	// before the node is created. By default, nodes detect failed peers with
+	// SWIM within a second and greet their leaves every 500ms. IDs are
+	// generated from the address of the node unless Configure sets them.
+	Configure func(i int, cfg *node.Config)
+
+	// NewApplication, if set, creates the Application of each node. By
+	// default, nodes use an Application which ignores changes.
+	NewApplication func(i int) node.Application
+
+	// Register, if set, registers services to the gRPC server of each node.
+	// Requests to the services are routed through the cluster by each
+	// node's Router.
+	Register func(i int, s *grpc.Server)
+
+	// Seed seeds the random decisions of the cluster, such as which RPCs are
+	// affected by faults and which nodes are picked by RunChaos. Nodes are
+	// seeded with Seed plus their index unless Configure sets RandSeed.
+	// Seeded from the current time if unset.
+	Seed int64
+
+	// Log will be used for logging messages from nodes. Messages are
+	// discarded if nil.
+	Log log.Logger
+}
+
+// Cluster is a cluster of in-process nodes. Create one with New.
+type Cluster struct {
+	t    testing.TB
+	cfg  Config
+	seed int64
+	net  *network
+
+	mut   sync.Mutex
+	nodes []*Node
+	rand  *rand.Rand
+}
+
+// Node is a node running in a Cluster.
+type Node struct {
+	*node.Node
+
+	// Index of the node in the Cluster.
+	Index int
+	// Addr is the address of the node in the Cluster's network.
+	Addr string
+
+	Config node.Config
+	App    node.Application
+	Server *grpc.Server
+	Router *node.Router
+
+	killed bool
+}
+
+// New starts a Cluster of cfg.NumNodes nodes and joins them together,
+// failing t if a node can't be started. The Cluster is closed when t
+// completes. Use WaitConverged to wait for the nodes to know about each
+// other.
+func New(t testing.TB, cfg Config) *Cluster {
+	t.Helper()
+	require.Greater(t, cfg.NumNodes, 0, "cluster must have at least one node")
+
+	if cfg.Log == nil {
+		cfg.Log = log.NewNopLogger()
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	c := &Cluster{
+		t:    t,
+		cfg:  cfg,
+		seed: seed,
+		net:  newNetwork(seed),
+		rand: rand.New(rand.NewSource(seed)),
+	}
+	t.Cleanup(c.Close)
+
+	for i := 0; i < cfg.NumNodes; i++ {
+		c.Add()
+	}
+	return c
+}
+
+// Add starts a new node and joins it to the cluster through the first node
+// which is still alive.
+func (c *Cluster) Add() *Node {
+	c.t.Helper()
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		i    = len(c.nodes)
+		addr = fmt.Sprintf("node-%d", i)
+	)
+
+	cfg := node.Config{
+		BroadcastAddr:  addr,
+		GossipInterval: 500 * time.Millisecond,
+		SWIM: &node.SWIMConfig{
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     50 * time.Millisecond,
+			SuspicionTimeout: 500 * time.Millisecond,
+		},
+		RandSeed: c.seed + int64(i),
+		Log:      log.With(c.cfg.Log, "node", addr),
+	}
+	if c.cfg.Configure != nil {
+		c.cfg.Configure(i, &cfg)
+	}
+	if cfg.ID == id.Zero {
+		size := cfg.IDSize
+		if size == 0 {
+			size = 32
+		}
+		cfg.ID = id.NewGenerator(size).Get(addr)
+	}
+	cfg.Dialer = c.net.dialer(addr)
+
+	var app node.Application = nopApplication{}
+	if c.cfg.NewApplication != nil {
+		app = c.cfg.NewApplication(i)
+	}
+
+	n, err := node.New(cfg, app, node.WithDialOptions(grpc.WithInsecure()))
+	require.NoError(c.t, err, "failed to create %s", addr)
+
+	router := &node.Router{}
+	router.SetNode(n)
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(router.Unary()),
+		grpc.ChainStreamInterceptor(router.Stream()),
+	)
+	n.Register(srv)
+	if c.cfg.Register != nil {
+		c.cfg.Register(i, srv)
+	}
+	go srv.Serve(c.net.listen(addr))
+
+	var seeds []string
+	for _, other := range c.nodes {
+		if !other.killed {
+			seeds = append(seeds, other.Addr)
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(c.t, n.Join(ctx, seeds), "failed to join %s", addr)
+
+	nn := &Node{
+		Node:   n,
+		Index:  i,
+		Addr:   addr,
+		Config: cfg,
+		App:    app,
+		Server: srv,
+		Router: router,
+	}
+	c.nodes = append(c.nodes, nn)
+	return nn
+}
+
+// Node returns the node with index i. Killed nodes are still returned.
+func (c *Cluster) Node(i int) *Node {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.nodes[i]
+}
+
+// Nodes returns every node of the cluster, including killed nodes, ordered
+// by index.
+func (c *Cluster) Nodes() []*Node {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return append([]*Node(nil), c.nodes...)
+}
+
+// Alive returns the nodes which haven't been killed, ordered by index.
+func (c *Cluster) Alive() []*Node {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.alive()
+}
+
+func (c *Cluster) alive() []*Node {
+	var res []*Node
+	for _, n := range c.nodes {
+		if !n.killed {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// Kill abruptly stops the node with index i, as if it crashed. Its peers
+// aren't told about it leaving and must detect that it failed. Use the
+// node's Leave method to gracefully remove a node instead.
+func (c *Cluster) Kill(i int) {
+	c.mut.Lock()
+	n := c.nodes[i]
+	killed := n.killed
+	n.killed = true
+	c.mut.Unlock()
+
+	if killed {
+		return
+	}
+
+	// Bring down the node's connections first so it can't say goodbye.
+	c.net.setDown(n.Addr)
+	n.Server.Stop()
+	_ = n.Close()
+}
+
+// Partition splits the cluster into groups of node indices. Nodes can only
+// communicate with nodes in the same group, and existing connections
+// between groups are broken. Nodes which aren't in any group are placed in
+// a group together. Calling Partition again replaces the previous
+// partition.
+//
+// Nodes in different groups eventually consider each other dead. Nodes
+// don't rediscover each other after the partition is healed unless they
+// join again.
+func (c *Cluster) Partition(groups ...[]int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	assigned := make(map[string]int)
+	for g, indices := range groups {
+		for _, i := range indices {
+			assigned[c.nodes[i].Addr] = g + 1
+		}
+	}
+	c.net.partition(assigned)
+}
+
+// Heal removes the partition created by Partition.
+func (c *Cluster) Heal() {
+	c.net.partition(nil)
+}
+
+// Dial returns a connection to the node with index i from a client outside
+// of the cluster. Partitions don't affect clients. The connection is closed
+// when the test completes.
+func (c *Cluster) Dial(i int) *grpc.ClientConn {
+	c.t.Helper()
+
+	addr := c.Node(i).Addr
+	cc, err := c.net.dialer("")(addr, grpc.WithInsecure())
+	require.NoError(c.t, err, "failed to dial %s", addr)
+	c.t.Cleanup(func() { _ = cc.Close() })
+	return cc
+}
+
+// Owner returns the alive node whose ID is closest to key. When nodes have
+// multiple virtual nodes, the IDs of every virtual node are considered.
+// Owner doesn't consider partitions.
+func (c *Cluster) Owner(key id.ID) *Node {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var (
+		owner   *Node
+		ownerID id.ID
+	)
+	for _, n := range c.alive() {
+		for _, s := range n.States() {
+			if owner == nil || api.Closer(s.Node.ID, ownerID, key, s.Size) {
+				owner, ownerID = n, s.Node.ID
+			}
+		}
+	}
+	return owner
+}
+
+// Converged returns nil if every alive node tracks exactly the expected
+// leaves: the closest alive nodes in the ring which the node can reach, all
+// of them healthy. Otherwise, an error describing the first node with
+// unexpected leaves is returned.
+func (c *Cluster) Converged() error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	alive := c.alive()
+	for _, n := range alive {
+		var reachable []api.Descriptor
+		for _, other := range alive {
+			if !c.net.canReach(n.Addr, other.Addr) {
+				continue
+			}
+			for _, s := range other.States() {
+				reachable = append(reachable, api.Descriptor{ID: s.Node.ID, Addr: other.Addr})
+			}
+		}
+
+		for _, s := range n.States() {
+			if err := checkLeaves(n, s, reachable); err != nil {
+				return fmt.Errorf("%s (%s): %w", n.Addr, s.Node.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLeaves checks that the leaves in s are the leaves expected from the
+// reachable nodes.
+func checkLeaves(n *Node, s node.State, reachable []api.Descriptor) error {
+	numLeaves, numNeighbors := n.Config.NumLeaves, n.Config.NumNeighbors
+	if numLeaves == 0 {
+		numLeaves = 8
+	}
+	if numNeighbors == 0 {
+		numNeighbors = 8
+	}
+
+	self := api.Descriptor{ID: s.Node.ID, Addr: n.Addr}
+	expect := api.NewState(self, numLeaves, numNeighbors, s.Size, s.Base)
+	for _, d := range reachable {
+		if d.ID != self.ID {
+			expect.MixinLeaves(api.NewState(d, numLeaves, numNeighbors, s.Size, s.Base))
+		}
+	}
+
+	var expectIDs []id.ID
+	for _, d := range expect.Leaves(false) {
+		expectIDs = append(expectIDs, d.ID)
+	}
+
+	var actualIDs []id.ID
+	seen := make(map[id.ID]struct{})
+	for _, p := range append(append([]node.Peer(nil), s.Predecessors...), s.Successors...) {
+		if _, ok := seen[p.ID]; ok {
+			continue
+		}
+		seen[p.ID] = struct{}{}
+		actualIDs = append(actualIDs, p.ID)
+
+		if h, ok := s.Health[p]; ok && h != node.Healthy {
+			return fmt.Errorf("leaf %s is %s", p.ID, h)
+		}
+	}
+
+	sortIDs(expectIDs)
+	sortIDs(actualIDs)
+	if fmt.Sprint(expectIDs) != fmt.Sprint(actualIDs) {
+		return fmt.Errorf("expected leaves %v, got %v", expectIDs, actualIDs)
+	}
+	return nil
+}
+
+func sortIDs(ids []id.ID) {
+	sort.Slice(ids, func(i, j int) bool { return id.Compare(ids[i], ids[j]) < 0 })
+}
+
+// WaitConverged waits until the cluster converged, failing the test if it
+// doesn't converge within timeout. See Converged.
+func (c *Cluster) WaitConverged(timeout time.Duration) {
+	c.t.Helper()
+
+	var (
+		deadline = time.Now().Add(timeout)
+		err      error
+	)
+	for time.Now().Before(deadline) {
+		if err = c.Converged(); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(c.t, err, "cluster did not converge within %s", timeout)
+}
+
+// Close stops every node of the cluster. Nodes say goodbye to their peers.
+func (c *Cluster) Close() {
+	for _, n := range c.Alive() {
+		c.mut.Lock()
+		n.killed = true
+		c.mut.Unlock()
+
+		_ = n.Close()
+		n.Server.Stop()
+	}
+}
+
+// nopApplication is an Application which ignores changes.
+type nopApplication struct{}
+
+func (nopApplication) PeersChanged([]node.Peer) {}
@@ -0,0 +1,55 @@
+package croissanttest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/examples/kv/kvserver"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestCluster(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	c := New(t, Config{
+		NumNodes: 5,
+		Register: func(i int, s *grpc.Server) {
+			kvproto.RegisterKVServer(s, &kvserver.Func{
+				GetFunc: func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+					return &kvproto.GetResponse{Value: fmt.Sprint(i)}, nil
+				},
+			})
+		},
+	})
+	c.WaitConverged(10 * time.Second)
+
+	// Requests sent to any node are handled by the owner of the key.
+	key := id.ID{Low: 1234}
+	resp, err := kvproto.NewKVClient(c.Dial(0)).Get(node.WithClientKey(ctx, key), &kvproto.GetRequest{Key: "key"})
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint(c.Owner(key).Index), resp.Value)
+
+	// Peers of killed nodes detect that they failed.
+	c.Kill(1)
+	require.Len(t, c.Alive(), 4)
+	c.WaitConverged(10 * time.Second)
+
+	// Nodes in different groups consider each other dead.
+	c.Partition([]int{0, 2})
+	require.Error(t, c.Converged(), "nodes shouldn't have detected the partition yet")
+	c.WaitConverged(10 * time.Second)
+	s := c.Node(0).State()
+	for _, l := range append(s.Predecessors, s.Successors...) {
+		require.Equal(t, c.Node(2).Addr, l.Addr)
+	}
+
+	c.Heal()
+	require.Equal(t, 5, c.Add().Index)
+}
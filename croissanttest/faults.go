@@ -0,0 +1,113 @@
+package croissanttest
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Fault describes how RPCs sent from one node to another misbehave. The
+// zero value sends RPCs normally.
+type Fault struct {
+	// Drop is the probability, between 0 and 1, that an RPC is dropped.
+	// Dropped RPCs fail with Unavailable. Half of dropped unary RPCs are
+	// dropped after the peer handled them, as if the response was lost.
+	Drop float64
+
+	// Delay is added before sending every RPC. Streams are only delayed when
+	// they're opened.
+	Delay time.Duration
+
+	// Duplicate is the probability, between 0 and 1, that a unary RPC is
+	// sent twice. The response of the duplicate is discarded.
+	Duplicate float64
+}
+
+// rpcFault is the fault decided for a single RPC.
+type rpcFault struct {
+	delay                 time.Duration
+	dropBefore, dropAfter bool
+	duplicate             bool
+}
+
+// decide decides how an RPC sent from from to to misbehaves.
+func (n *network) decide(from, to string) rpcFault {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	f, ok := n.faults[link{from, to}]
+	if !ok {
+		return rpcFault{}
+	}
+
+	res := rpcFault{delay: f.Delay}
+	if n.rand.Float64() < f.Drop {
+		if n.rand.Intn(2) == 0 {
+			res.dropBefore = true
+		} else {
+			res.dropAfter = true
+		}
+	}
+	res.duplicate = n.rand.Float64() < f.Duplicate
+	return res
+}
+
+var errDropped = status.Errorf(codes.Unavailable, "RPC dropped by fault injection")
+
+// unaryFaults returns an interceptor applying faults to unary RPCs sent by
+// from.
+func (n *network) unaryFaults(from string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		f := n.decide(from, cc.Target())
+		if err := sleep(ctx, f.delay); err != nil {
+			return err
+		} else if f.dropBefore {
+			return errDropped
+		}
+
+		if m, ok := reply.(proto.Message); ok && f.duplicate {
+			_ = invoker(ctx, method, req, proto.Clone(m), cc, opts...)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil && f.dropAfter {
+			return errDropped
+		}
+		return err
+	}
+}
+
+// streamFaults returns an interceptor applying faults to streams opened by
+// from.
+func (n *network) streamFaults(from string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		f := n.decide(from, cc.Target())
+		if err := sleep(ctx, f.delay); err != nil {
+			return nil, err
+		} else if f.dropBefore || f.dropAfter {
+			return nil, errDropped
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// sleep waits for d or until ctx is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
@@ -0,0 +1,197 @@
+package croissanttest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/rfratto/croissant/node"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufSize is the size of the buffer of in-memory connections.
+const bufSize = 1024 * 1024
+
+// network is an in-memory network connecting the nodes of a Cluster.
+// Connections are opened by address, and fail once either end is down or
+// the ends are partitioned from each other. RPCs sent over links with
+// faults are dropped, delayed, or duplicated.
+type network struct {
+	mut       sync.Mutex
+	listeners map[string]*bufconn.Listener
+	conns     map[*netConn]struct{}
+	down      map[string]struct{}
+	groups    map[string]int // Partition group of each address. Nil if not partitioned.
+	blocked   map[link]struct{}
+	faults    map[link]Fault
+	rand      *rand.Rand // Decides which RPCs are affected by faults.
+}
+
+// link is the direction between two addresses.
+type link struct{ from, to string }
+
+func newNetwork(seed int64) *network {
+	return &network{
+		listeners: make(map[string]*bufconn.Listener),
+		conns:     make(map[*netConn]struct{}),
+		down:      make(map[string]struct{}),
+		blocked:   make(map[link]struct{}),
+		faults:    make(map[link]Fault),
+		rand:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// listen creates a listener for addr.
+func (n *network) listen(addr string) net.Listener {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	lis := bufconn.Listen(bufSize)
+	n.listeners[addr] = lis
+	delete(n.down, addr)
+	return lis
+}
+
+// dialer returns a DialFunc which connects from to other addresses over the
+// network. from is empty for clients outside of the cluster.
+func (n *network) dialer(from string) node.DialFunc {
+	return func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		opts = append(opts[:len(opts):len(opts)],
+			grpc.WithContextDialer(func(_ context.Context, to string) (net.Conn, error) {
+				return n.dial(from, to)
+			}),
+			grpc.WithChainUnaryInterceptor(n.unaryFaults(from)),
+			grpc.WithChainStreamInterceptor(n.streamFaults(from)),
+		)
+		return grpc.Dial(addr, opts...)
+	}
+}
+
+// dial opens a connection from the address from to the address to.
+func (n *network) dial(from, to string) (net.Conn, error) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	lis, ok := n.listeners[to]
+	if !ok || !n.reachable(from, to) {
+		return nil, fmt.Errorf("%s is unreachable", to)
+	}
+	conn, err := lis.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	nc := &netConn{Conn: conn, n: n, from: from, to: to}
+	n.conns[nc] = struct{}{}
+	return nc, nil
+}
+
+// canReach returns true if to can be reached from the address from.
+func (n *network) canReach(from, to string) bool {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	return n.reachable(from, to)
+}
+
+// reachable returns true if to can be reached from the address from. Must
+// be called with the mutex held.
+func (n *network) reachable(from, to string) bool {
+	if _, down := n.down[to]; down {
+		return false
+	} else if from == "" {
+		return true
+	} else if _, down := n.down[from]; down {
+		return false
+	} else if _, blocked := n.blocked[link{from, to}]; blocked {
+		return false
+	}
+	return n.groups == nil || n.groups[from] == n.groups[to]
+}
+
+// setDown marks addr as down, closing its connections.
+func (n *network) setDown(addr string) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	n.down[addr] = struct{}{}
+	n.breakLinks()
+}
+
+// partition splits the network into groups. Addresses with the same group
+// can only reach each other. A nil groups heals the network.
+func (n *network) partition(groups map[string]int) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	n.groups = groups
+	n.breakLinks()
+}
+
+// block prevents from from reaching to, closing connections from from to
+// to. to may still reach from.
+func (n *network) block(from, to string) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	n.blocked[link{from, to}] = struct{}{}
+	n.breakLinks()
+}
+
+// unblock undoes block.
+func (n *network) unblock(from, to string) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	delete(n.blocked, link{from, to})
+}
+
+// setFault sets the fault of RPCs sent from from to to. The zero Fault
+// removes the fault.
+func (n *network) setFault(from, to string, f Fault) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	if f == (Fault{}) {
+		delete(n.faults, link{from, to})
+		return
+	}
+	n.faults[link{from, to}] = f
+}
+
+// clearFaults removes every fault and block.
+func (n *network) clearFaults() {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	n.blocked = make(map[link]struct{})
+	n.faults = make(map[link]Fault)
+}
+
+// breakLinks closes connections whose ends can no longer reach each other.
+// Must be called with the mutex held.
+func (n *network) breakLinks() {
+	for nc := range n.conns {
+		if !n.reachable(nc.from, nc.to) {
+			delete(n.conns, nc)
+			_ = nc.Conn.Close()
+		}
+	}
+}
+
+// netConn is a connection opened over a network.
+type netConn struct {
+	net.Conn
+
+	n        *network
+	from, to string
+}
+
+func (nc *netConn) Close() error {
+	nc.n.mut.Lock()
+	delete(nc.n.conns, nc)
+	nc.n.mut.Unlock()
+
+	return nc.Conn.Close()
+}
@@ -0,0 +1,205 @@
+// Package consul implements discovery of nodes using the Consul catalog.
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultAddress is the default address of the Consul agent.
+const DefaultAddress = "http://127.0.0.1:8500"
+
+// Discoverer is a discovery.Discoverer and discovery.Registrar that finds
+// nodes registered as instances of a service in the Consul catalog.
+//
+// When used with node.JoinDiscovered, the local node is registered as an
+// instance of Service with the local Consul agent after joining, and is
+// deregistered when the node is closed.
+type Discoverer struct {
+	// Service is the name of the Consul service nodes are registered as.
+	// Must be set.
+	Service string
+
+	// Tag, if set, only discovers service instances with the given tag. The
+	// local node is registered with Tag.
+	Tag string
+
+	// Address of the Consul agent. Defaults to DefaultAddress.
+	Address string
+
+	// Token is the ACL token to use for requests. Optional.
+	Token string
+
+	// Client is used to communicate with Consul. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	mut       sync.Mutex
+	serviceID string // ID of the registered service instance.
+}
+
+// catalogService is the subset of a Consul catalog entry used for
+// discovery.
+type catalogService struct {
+	Address        string
+	ServiceAddress string
+	ServicePort    int
+}
+
+// Discover implements discovery.Discoverer. Addresses are returned for every
+// instance of Service in the catalog, sorted and deduplicated.
+func (d *Discoverer) Discover(ctx context.Context) ([]string, error) {
+	if d.Service == "" {
+		return nil, fmt.Errorf("Service must be set")
+	}
+
+	query := url.Values{}
+	if d.Tag != "" {
+		query.Set("tag", d.Tag)
+	}
+
+	var entries []catalogService
+	if err := d.do(ctx, http.MethodGet, "/v1/catalog/service/"+url.PathEscape(d.Service), query, nil, &entries); err != nil {
+		return nil, fmt.Errorf("failed to list instances of %s: %w", d.Service, err)
+	}
+
+	var (
+		seen  = make(map[string]struct{}, len(entries))
+		addrs = make([]string, 0, len(entries))
+	)
+	for _, e := range entries {
+		host := e.ServiceAddress
+		if host == "" {
+			host = e.Address
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(e.ServicePort))
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// Register implements discovery.Registrar, registering addr as an instance
+// of Service with the Consul agent.
+func (d *Discoverer) Register(ctx context.Context, addr string) error {
+	if d.Service == "" {
+		return fmt.Errorf("Service must be set")
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid port in address %s: %w", addr, err)
+	}
+
+	reg := struct {
+		ID      string
+		Name    string
+		Tags    []string `json:",omitempty"`
+		Address string
+		Port    int
+	}{
+		ID:      d.Service + "-" + addr,
+		Name:    d.Service,
+		Address: host,
+		Port:    port,
+	}
+	if d.Tag != "" {
+		reg.Tags = []string{d.Tag}
+	}
+
+	if err := d.do(ctx, http.MethodPut, "/v1/agent/service/register", nil, reg, nil); err != nil {
+		return fmt.Errorf("failed to register %s: %w", addr, err)
+	}
+
+	d.mut.Lock()
+	d.serviceID = reg.ID
+	d.mut.Unlock()
+	return nil
+}
+
+// Deregister implements discovery.Registrar. Deregister does nothing if the
+// local node wasn't registered.
+func (d *Discoverer) Deregister(ctx context.Context) error {
+	d.mut.Lock()
+	id := d.serviceID
+	d.serviceID = ""
+	d.mut.Unlock()
+
+	if id == "" {
+		return nil
+	}
+	if err := d.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to deregister %s: %w", id, err)
+	}
+	return nil
+}
+
+// do performs a request against the Consul HTTP API. If in is non-nil, it's
+// sent as the JSON request body. If out is non-nil, the response is decoded
+// into it.
+func (d *Discoverer) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	addr := d.Address
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	u := strings.TrimSuffix(addr, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		bb, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(bb)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if d.Token != "" {
+		req.Header.Set("X-Consul-Token", d.Token)
+	}
+
+	cli := d.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverer(t *testing.T) {
+	agent := newFakeAgent()
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	d := &Discoverer{Service: "croissant", Tag: "v1", Address: srv.URL, Token: "secret"}
+
+	addrs, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, addrs)
+
+	require.NoError(t, d.Register(context.Background(), "10.0.0.2:9095"))
+	other := &Discoverer{Service: "croissant", Tag: "v1", Address: srv.URL, Token: "secret"}
+	require.NoError(t, other.Register(context.Background(), "10.0.0.1:9095"))
+
+	addrs, err = d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095", "10.0.0.2:9095"}, addrs)
+
+	require.NoError(t, d.Deregister(context.Background()))
+	addrs, err = d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095"}, addrs)
+
+	// Deregistering twice is a no-op.
+	require.NoError(t, d.Deregister(context.Background()))
+}
+
+// fakeAgent implements the subset of the Consul agent API used by
+// Discoverer.
+type fakeAgent struct {
+	mut      sync.Mutex
+	services map[string]registration
+}
+
+type registration struct {
+	ID      string
+	Name    string
+	Tags    []string
+	Address string
+	Port    int
+}
+
+func newFakeAgent() *fakeAgent {
+	return &fakeAgent{services: make(map[string]registration)}
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/agent/service/register":
+		var reg registration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.services[reg.ID] = reg
+
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(a.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/catalog/service/"):
+		var (
+			name = strings.TrimPrefix(r.URL.Path, "/v1/catalog/service/")
+			tag  = r.URL.Query().Get("tag")
+		)
+
+		entries := []catalogService{}
+		for _, reg := range a.services {
+			if reg.Name != name || (tag != "" && !contains(reg.Tags, tag)) {
+				continue
+			}
+			entries = append(entries, catalogService{
+				Address:        "192.168.0.1",
+				ServiceAddress: reg.Address,
+				ServicePort:    reg.Port,
+			})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ServiceAddress < entries[j].ServiceAddress })
+		_ = json.NewEncoder(w).Encode(entries)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+// Package discovery implements finding the addresses of existing nodes in a
+// cluster to join.
+package discovery
+
+import "context"
+
+// Discoverer finds addresses of nodes to join. Discover is called every
+// time addresses are needed, so implementations should look up the latest
+// set of addresses rather than caching them.
+type Discoverer interface {
+	// Discover returns the addresses of nodes in the cluster. An empty list
+	// of addresses with no error indicates there are no nodes to join.
+	Discover(ctx context.Context) ([]string, error)
+}
+
+// Static is a Discoverer that always returns a fixed list of addresses.
+type Static []string
+
+// Discover implements Discoverer.
+func (s Static) Discover(_ context.Context) ([]string, error) {
+	return append([]string(nil), s...), nil
+}
+
+// Registrar is implemented by Discoverers that can register the local node
+// so it may be discovered by other nodes.
+type Registrar interface {
+	// Register registers addr as the address of the local node.
+	Register(ctx context.Context, addr string) error
+
+	// Deregister removes the registration of the local node.
+	Deregister(ctx context.Context) error
+}
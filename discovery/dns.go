@@ -0,0 +1,107 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SRVPrefix is the prefix of names in DNS.Names that are resolved with SRV
+// lookups.
+const SRVPrefix = "srv+"
+
+// Resolver performs DNS lookups. *net.Resolver implements Resolver.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+// DNS is a Discoverer that finds nodes using DNS. Names are looked up again
+// on every call to Discover, so changes to DNS records are picked up as
+// nodes come and go. This makes DNS suitable for discovering nodes behind a
+// headless Kubernetes service.
+type DNS struct {
+	// Names to resolve. Names in the form host:port are resolved with A and
+	// AAAA lookups, returning one address per IP using the given port. Names
+	// prefixed with SRVPrefix, such as srv+_grpc._tcp.example.com, are
+	// resolved with SRV lookups, returning one address per target.
+	Names []string
+
+	// Resolver to use for lookups. Defaults to net.DefaultResolver.
+	Resolver Resolver
+}
+
+// Discover implements Discoverer. Discover returns the addresses from every
+// name that could be resolved and only fails if every name failed to
+// resolve. The returned addresses are sorted and deduplicated.
+func (d *DNS) Discover(ctx context.Context) ([]string, error) {
+	var resolver Resolver = net.DefaultResolver
+	if d.Resolver != nil {
+		resolver = d.Resolver
+	}
+
+	var (
+		seen     = make(map[string]struct{})
+		addrs    []string
+		firstErr error
+		failed   int
+	)
+	for _, name := range d.Names {
+		found, err := resolve(ctx, resolver, name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		for _, addr := range found {
+			if _, ok := seen[addr]; ok {
+				continue
+			}
+			seen[addr] = struct{}{}
+			addrs = append(addrs, addr)
+		}
+	}
+	if failed > 0 && failed == len(d.Names) {
+		return nil, firstErr
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// resolve resolves a single name.
+func resolve(ctx context.Context, r Resolver, name string) ([]string, error) {
+	if strings.HasPrefix(name, SRVPrefix) {
+		_, records, err := r.LookupSRV(ctx, "", "", strings.TrimPrefix(name, SRVPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up SRV records for %s: %w", name, err)
+		}
+
+		addrs := make([]string, 0, len(records))
+		for _, rec := range records {
+			host := strings.TrimSuffix(rec.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+		}
+		return addrs, nil
+	}
+
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %s: %w", name, err)
+	}
+	ips, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s: %w", host, err)
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip, port))
+	}
+	return addrs, nil
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNS(t *testing.T) {
+	r := &fakeResolver{
+		hosts: map[string][]string{
+			"croissant": {"10.0.0.2", "10.0.0.1", "fd00::1"},
+			"10.0.0.1":  {"10.0.0.1"},
+		},
+		srvs: map[string][]*net.SRV{
+			"_grpc._tcp.croissant": {
+				{Target: "croissant-0.croissant.", Port: 9095},
+				{Target: "croissant-1.croissant.", Port: 9095},
+			},
+		},
+	}
+
+	t.Run("A/AAAA", func(t *testing.T) {
+		d := DNS{Names: []string{"croissant:9095", "10.0.0.1:9095"}, Resolver: r}
+		addrs, err := d.Discover(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.0.0.1:9095", "10.0.0.2:9095", "[fd00::1]:9095"}, addrs)
+	})
+
+	t.Run("SRV", func(t *testing.T) {
+		d := DNS{Names: []string{"srv+_grpc._tcp.croissant"}, Resolver: r}
+		addrs, err := d.Discover(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"croissant-0.croissant:9095", "croissant-1.croissant:9095"}, addrs)
+	})
+
+	t.Run("re-resolution", func(t *testing.T) {
+		d := DNS{Names: []string{"srv+_grpc._tcp.croissant"}, Resolver: r}
+
+		r.srvs["_grpc._tcp.croissant"] = append(r.srvs["_grpc._tcp.croissant"], &net.SRV{Target: "croissant-2.croissant.", Port: 9095})
+		addrs, err := d.Discover(context.Background())
+		require.NoError(t, err)
+		require.Contains(t, addrs, "croissant-2.croissant:9095")
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		d := DNS{Names: []string{"missing:9095", "10.0.0.1:9095"}, Resolver: r}
+		addrs, err := d.Discover(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.0.0.1:9095"}, addrs)
+	})
+
+	t.Run("total failure", func(t *testing.T) {
+		d := DNS{Names: []string{"missing:9095", "srv+missing"}, Resolver: r}
+		_, err := d.Discover(context.Background())
+		require.Error(t, err)
+	})
+}
+
+type fakeResolver struct {
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	return addrs, nil
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	records, ok := r.srvs[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no such host %s", name)
+	}
+	return name, records, nil
+}
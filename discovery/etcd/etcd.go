@@ -0,0 +1,244 @@
+// Package etcd implements discovery of nodes registered under a key prefix
+// in etcd.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultEndpoint is the default address of etcd.
+const DefaultEndpoint = "http://127.0.0.1:2379"
+
+// DefaultTTL is the default TTL of registrations.
+const DefaultTTL = 30 * time.Second
+
+// Discoverer is a discovery.Discoverer and discovery.Registrar that finds
+// nodes from the values of keys under Prefix. It uses the JSON gateway of
+// the etcd v3 API.
+//
+// When used with node.JoinDiscovered, the local node is registered after
+// joining by writing its address to a key under Prefix. The key is attached
+// to a lease that is kept alive until the node is closed, so the keys of
+// nodes that exit abruptly expire after TTL.
+type Discoverer struct {
+	// Prefix of keys holding the addresses of nodes, such as
+	// "/croissant/nodes/". Must be set.
+	Prefix string
+
+	// Endpoint of etcd. Defaults to DefaultEndpoint.
+	Endpoint string
+
+	// TTL of the local node's registration. Defaults to DefaultTTL.
+	TTL time.Duration
+
+	// Client is used to communicate with etcd. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	mut     sync.Mutex
+	leaseID string        // Lease of the registration.
+	stop    chan struct{} // Stops keeping the lease alive.
+	done    chan struct{} // Closed when the lease is no longer kept alive.
+}
+
+// Discover implements discovery.Discoverer. The returned addresses are
+// sorted and deduplicated.
+func (d *Discoverer) Discover(ctx context.Context) ([]string, error) {
+	if d.Prefix == "" {
+		return nil, fmt.Errorf("Prefix must be set")
+	}
+
+	req := map[string]string{
+		"key":       encode(d.Prefix),
+		"range_end": encode(prefixEnd(d.Prefix)),
+	}
+	var resp struct {
+		KVs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := d.do(ctx, "/v3/kv/range", req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list keys under %s: %w", d.Prefix, err)
+	}
+
+	var (
+		seen  = make(map[string]struct{}, len(resp.KVs))
+		addrs = make([]string, 0, len(resp.KVs))
+	)
+	for _, kv := range resp.KVs {
+		addr, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %w", err)
+		}
+		if _, ok := seen[string(addr)]; ok || len(addr) == 0 {
+			continue
+		}
+		seen[string(addr)] = struct{}{}
+		addrs = append(addrs, string(addr))
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// Register implements discovery.Registrar, writing addr to the key
+// Prefix+addr. The key is removed after TTL if Deregister isn't called and
+// the process exits.
+func (d *Discoverer) Register(ctx context.Context, addr string) error {
+	if d.Prefix == "" {
+		return fmt.Errorf("Prefix must be set")
+	}
+
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	// Leases have a granularity of one second.
+	ttlSeconds := int64(ttl / time.Second)
+	if ttlSeconds < 1 {
+		ttlSeconds = 1
+	}
+
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := d.do(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": ttlSeconds}, &grant); err != nil {
+		return fmt.Errorf("failed to grant lease: %w", err)
+	}
+
+	put := map[string]string{
+		"key":   encode(d.Prefix + addr),
+		"value": encode(addr),
+		"lease": grant.ID,
+	}
+	if err := d.do(ctx, "/v3/kv/put", put, nil); err != nil {
+		return fmt.Errorf("failed to register %s: %w", addr, err)
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.stopKeepAlive()
+	d.leaseID = grant.ID
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.keepAlive(grant.ID, ttl/3, d.stop, d.done)
+	return nil
+}
+
+// keepAlive keeps the lease alive until stop is closed.
+func (d *Discoverer) keepAlive(id string, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			// Failures are retried on the next tick; the lease only
+			// expires if every attempt within the TTL fails.
+			_ = d.do(ctx, "/v3/lease/keepalive", map[string]string{"ID": id}, nil)
+			cancel()
+		}
+	}
+}
+
+// stopKeepAlive stops keeping the current lease alive. d.mut must be held.
+func (d *Discoverer) stopKeepAlive() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	d.stop, d.done = nil, nil
+}
+
+// Deregister implements discovery.Registrar, revoking the lease of the
+// registration and removing the key. Deregister does nothing if the local
+// node wasn't registered.
+func (d *Discoverer) Deregister(ctx context.Context) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.stopKeepAlive()
+	if d.leaseID == "" {
+		return nil
+	}
+	id := d.leaseID
+	d.leaseID = ""
+
+	if err := d.do(ctx, "/v3/lease/revoke", map[string]string{"ID": id}, nil); err != nil {
+		return fmt.Errorf("failed to revoke lease: %w", err)
+	}
+	return nil
+}
+
+// do sends in as JSON to path and decodes the response into out if out is
+// non-nil.
+func (d *Discoverer) do(ctx context.Context, path string, in, out interface{}) error {
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	cli := d.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// prefixEnd returns the end of the range of keys starting with prefix.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	// Every byte is 0xff; range to the end of the keyspace.
+	return "\x00"
+}
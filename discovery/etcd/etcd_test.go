@@ -0,0 +1,151 @@
+package etcd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverer(t *testing.T) {
+	store := newFakeEtcd()
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	newDiscoverer := func() *Discoverer {
+		return &Discoverer{Prefix: "/croissant/", Endpoint: srv.URL, TTL: 30 * time.Millisecond}
+	}
+	d := newDiscoverer()
+
+	addrs, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, addrs)
+
+	require.NoError(t, d.Register(context.Background(), "10.0.0.2:9095"))
+	other := newDiscoverer()
+	require.NoError(t, other.Register(context.Background(), "10.0.0.1:9095"))
+	defer other.Deregister(context.Background())
+
+	// Keys outside of the prefix must be ignored.
+	store.put("/croissantx/other", "10.0.0.3:9095", "")
+
+	addrs, err = d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095", "10.0.0.2:9095"}, addrs)
+
+	require.Eventually(t, func() bool {
+		return store.keepAlives() > 0
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, d.Deregister(context.Background()))
+	addrs, err = d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095"}, addrs)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	require.Equal(t, "/croissant0", prefixEnd("/croissant/"))
+	require.Equal(t, "b", prefixEnd("a\xff"))
+	require.Equal(t, "\x00", prefixEnd("\xff"))
+}
+
+// fakeEtcd implements the subset of the etcd v3 JSON gateway used by
+// Discoverer.
+type fakeEtcd struct {
+	mut       sync.Mutex
+	kvs       map[string]fakeKV
+	nextLease int
+	kas       int
+}
+
+type fakeKV struct {
+	value, lease string
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string]fakeKV)}
+}
+
+func (e *fakeEtcd) put(key, value, lease string) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.kvs[key] = fakeKV{value: value, lease: lease}
+}
+
+func (e *fakeEtcd) keepAlives() int {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	return e.kas
+}
+
+func (e *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	decode := func(field string) string {
+		s, _ := req[field].(string)
+		b, _ := base64.StdEncoding.DecodeString(s)
+		return string(b)
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	var resp interface{} = map[string]interface{}{}
+
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		e.nextLease++
+		resp = map[string]string{"ID": strconv.Itoa(e.nextLease), "TTL": "1"}
+
+	case "/v3/lease/keepalive":
+		e.kas++
+
+	case "/v3/lease/revoke":
+		for k, kv := range e.kvs {
+			if kv.lease == req["ID"] {
+				delete(e.kvs, k)
+			}
+		}
+
+	case "/v3/kv/put":
+		lease, _ := req["lease"].(string)
+		e.kvs[decode("key")] = fakeKV{value: decode("value"), lease: lease}
+
+	case "/v3/kv/range":
+		start, end := decode("key"), decode("range_end")
+
+		var keys []string
+		for k := range e.kvs {
+			if k >= start && k < end {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		var kvs []map[string]string
+		for _, k := range keys {
+			kvs = append(kvs, map[string]string{
+				"key":   base64.StdEncoding.EncodeToString([]byte(k)),
+				"value": base64.StdEncoding.EncodeToString([]byte(e.kvs[k].value)),
+			})
+		}
+		resp = map[string]interface{}{"kvs": kvs}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
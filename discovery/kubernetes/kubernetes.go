@@ -0,0 +1,453 @@
+// Package kubernetes implements discovery of nodes using the EndpointSlices
+// of a Kubernetes service.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths used when running inside of a Kubernetes pod.
+const (
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	tokenFile          = serviceAccountPath + "/token"
+	caFile             = serviceAccountPath + "/ca.crt"
+	namespaceFile      = serviceAccountPath + "/namespace"
+)
+
+const (
+	// watchTimeout is how long the API server keeps a watch open before
+	// ending it. Watches are resumed when they end.
+	watchTimeout = 5 * time.Minute
+
+	// retryInterval is how long to wait before watching again after a
+	// watch or list fails.
+	retryInterval = time.Second
+)
+
+// Discoverer is a discovery.Discoverer that finds nodes from the
+// EndpointSlices of a Kubernetes service. The service is typically the
+// headless service of a StatefulSet running the nodes.
+//
+// The first call to Discover lists the EndpointSlices of Service and starts
+// watching them for changes in the background, like an informer. Later calls
+// return the endpoints from the watched EndpointSlices without contacting the
+// API server. If the watch fails, the last known endpoints are returned until
+// it's resumed. Call Close to stop watching. The service account of the pod
+// must be allowed to list and watch EndpointSlices in Namespace.
+type Discoverer struct {
+	// Service is the name of the service to discover nodes from. Must be
+	// set.
+	Service string
+
+	// Namespace of Service. Defaults to the namespace of the pod if unset.
+	Namespace string
+
+	// PortName is the name of the port of Service nodes listen on. May be
+	// left unset if Service only has one port.
+	PortName string
+
+	// IncludeNotReady includes endpoints that aren't ready yet. By default,
+	// only ready endpoints are returned.
+	IncludeNotReady bool
+
+	// APIServer is the URL of the Kubernetes API server. Defaults to the API
+	// server of the cluster the pod is running in.
+	APIServer string
+
+	// Client is used to communicate with APIServer. Defaults to a client
+	// which trusts the cluster CA if unset.
+	Client *http.Client
+
+	// TokenFile is a file holding the bearer token to authenticate with.
+	// Defaults to the token of the pod's service account. The file is read
+	// on every request to APIServer, allowing the token to be rotated.
+	TokenFile string
+
+	initOnce sync.Once
+	initErr  error
+
+	mut  sync.Mutex
+	stop chan struct{} // Stops watching EndpointSlices.
+	done chan struct{} // Closed when EndpointSlices are no longer watched.
+
+	cacheMut sync.Mutex
+	slices   map[string]endpointSlice // Watched EndpointSlices by name.
+}
+
+// init fills in defaults for d.
+func (d *Discoverer) init() error {
+	d.initOnce.Do(func() {
+		if d.Service == "" {
+			d.initErr = fmt.Errorf("Service must be set")
+			return
+		}
+
+		if d.Namespace == "" {
+			ns, err := ioutil.ReadFile(namespaceFile)
+			if err != nil {
+				d.initErr = fmt.Errorf("failed to determine namespace: %w", err)
+				return
+			}
+			d.Namespace = strings.TrimSpace(string(ns))
+		}
+
+		if d.APIServer == "" {
+			var (
+				host = os.Getenv("KUBERNETES_SERVICE_HOST")
+				port = os.Getenv("KUBERNETES_SERVICE_PORT")
+			)
+			if host == "" || port == "" {
+				d.initErr = fmt.Errorf("APIServer must be set when not running in Kubernetes")
+				return
+			}
+			d.APIServer = "https://" + net.JoinHostPort(host, port)
+		}
+
+		if d.TokenFile == "" {
+			d.TokenFile = tokenFile
+		}
+
+		if d.Client == nil {
+			pool := x509.NewCertPool()
+			if ca, err := ioutil.ReadFile(caFile); err == nil {
+				pool.AppendCertsFromPEM(ca)
+			}
+			d.Client = &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: pool},
+				},
+			}
+		}
+	})
+	return d.initErr
+}
+
+// Discover implements discovery.Discoverer. The returned addresses are
+// sorted and deduplicated.
+func (d *Discoverer) Discover(ctx context.Context) ([]string, error) {
+	if err := d.init(); err != nil {
+		return nil, err
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.stop == nil {
+		list, err := d.listSlices(ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.setSlices(list.Items)
+
+		d.stop = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.watch(list.Metadata.ResourceVersion, d.stop, d.done)
+	}
+
+	return d.addrs()
+}
+
+// Close stops watching EndpointSlices. Discover starts watching them again
+// if called after Close.
+func (d *Discoverer) Close() error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.stop == nil {
+		return nil
+	}
+	close(d.stop)
+	<-d.done
+	d.stop, d.done = nil, nil
+	return nil
+}
+
+// addrs returns the addresses of the endpoints of the watched
+// EndpointSlices.
+func (d *Discoverer) addrs() ([]string, error) {
+	d.cacheMut.Lock()
+	defer d.cacheMut.Unlock()
+
+	var (
+		seen  = make(map[string]struct{})
+		addrs []string
+	)
+	for _, slice := range d.slices {
+		port, ok, err := d.findPort(slice.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("EndpointSlice %s: %w", slice.Metadata.Name, err)
+		} else if !ok {
+			continue
+		}
+
+		for _, ep := range slice.Endpoints {
+			if !d.IncludeNotReady && !ep.Conditions.isReady() {
+				continue
+			}
+			for _, ip := range ep.Addresses {
+				addr := net.JoinHostPort(ip, strconv.Itoa(port))
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// setSlices replaces the watched EndpointSlices with slices.
+func (d *Discoverer) setSlices(slices []endpointSlice) {
+	d.cacheMut.Lock()
+	defer d.cacheMut.Unlock()
+
+	d.slices = make(map[string]endpointSlice, len(slices))
+	for _, slice := range slices {
+		d.slices[slice.Metadata.Name] = slice
+	}
+}
+
+// watch keeps the watched EndpointSlices up to date until stop is closed,
+// starting from resourceVersion. EndpointSlices are listed again whenever
+// the watch can't be resumed from the last seen resource version.
+func (d *Discoverer) watch(resourceVersion string, stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		var err error
+		if resourceVersion == "" {
+			var list *endpointSliceList
+			if list, err = d.listSlices(ctx); err == nil {
+				d.setSlices(list.Items)
+				resourceVersion = list.Metadata.ResourceVersion
+			}
+		}
+		if err == nil {
+			resourceVersion, err = d.watchSlices(ctx, resourceVersion)
+		}
+
+		if ctx.Err() != nil {
+			return
+		} else if err == nil || errors.Is(err, errExpired) {
+			continue
+		}
+
+		// Try again later; the last known EndpointSlices are used in the
+		// meantime.
+		select {
+		case <-stop:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// errExpired is returned by watchSlices when the watch can't be resumed
+// and EndpointSlices must be listed again.
+var errExpired = errors.New("resource version expired")
+
+// watchSlices applies changes to the watched EndpointSlices after
+// resourceVersion until the watch ends. The last seen resource version is
+// returned, which is empty if the watch can't be resumed.
+func (d *Discoverer) watchSlices(ctx context.Context, resourceVersion string) (string, error) {
+	query := url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout / time.Second))},
+	}
+	resp, err := d.get(ctx, query)
+	if err != nil {
+		return resourceVersion, fmt.Errorf("failed to watch EndpointSlices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return "", errExpired
+	} else if resp.StatusCode != http.StatusOK {
+		return resourceVersion, fmt.Errorf("failed to watch EndpointSlices: %s", readError(resp))
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev watchEvent
+		if err := dec.Decode(&ev); errors.Is(err, io.EOF) {
+			return resourceVersion, nil
+		} else if err != nil {
+			return resourceVersion, fmt.Errorf("failed to decode watch event: %w", err)
+		}
+
+		if ev.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(ev.Object, &status)
+			if status.Code == http.StatusGone {
+				return "", errExpired
+			}
+			return resourceVersion, fmt.Errorf("watch failed: %s", status.Message)
+		}
+
+		var slice endpointSlice
+		if err := json.Unmarshal(ev.Object, &slice); err != nil {
+			return resourceVersion, fmt.Errorf("failed to decode EndpointSlice: %w", err)
+		}
+		resourceVersion = slice.Metadata.ResourceVersion
+
+		d.cacheMut.Lock()
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			d.slices[slice.Metadata.Name] = slice
+		case "DELETED":
+			delete(d.slices, slice.Metadata.Name)
+		}
+		d.cacheMut.Unlock()
+	}
+}
+
+func (d *Discoverer) listSlices(ctx context.Context) (*endpointSliceList, error) {
+	resp, err := d.get(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list EndpointSlices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list EndpointSlices: %s", readError(resp))
+	}
+
+	var list endpointSliceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode EndpointSlices: %w", err)
+	}
+	return &list, nil
+}
+
+// get requests the EndpointSlices of Service with the additional query
+// parameters in query.
+func (d *Discoverer) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("labelSelector", "kubernetes.io/service-name="+d.Service)
+
+	u := fmt.Sprintf(
+		"%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s",
+		strings.TrimSuffix(d.APIServer, "/"),
+		url.PathEscape(d.Namespace),
+		query.Encode(),
+	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if token, err := ioutil.ReadFile(d.TokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read token: %w", err)
+	}
+
+	return d.Client.Do(req)
+}
+
+// readError returns the status and body of a failed response.
+func readError(resp *http.Response) string {
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// findPort finds the port to use from ports. ok will be false if the port
+// with PortName doesn't exist.
+func (d *Discoverer) findPort(ports []endpointPort) (port int, ok bool, err error) {
+	if d.PortName == "" {
+		switch len(ports) {
+		case 0:
+			return 0, false, nil
+		case 1:
+			return ports[0].Port, true, nil
+		default:
+			return 0, false, fmt.Errorf("service has multiple ports and PortName is not set")
+		}
+	}
+
+	for _, p := range ports {
+		if p.Name == d.PortName {
+			return p.Port, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// The subset of the discovery.k8s.io/v1 EndpointSlice API used for
+// discovery.
+type (
+	endpointSliceList struct {
+		Metadata objectMeta      `json:"metadata"`
+		Items    []endpointSlice `json:"items"`
+	}
+
+	endpointSlice struct {
+		Metadata  objectMeta     `json:"metadata"`
+		Endpoints []endpoint     `json:"endpoints"`
+		Ports     []endpointPort `json:"ports"`
+	}
+
+	endpoint struct {
+		Addresses  []string           `json:"addresses"`
+		Conditions endpointConditions `json:"conditions"`
+	}
+
+	endpointConditions struct {
+		Ready *bool `json:"ready"`
+	}
+
+	endpointPort struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	}
+
+	objectMeta struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	}
+
+	watchEvent struct {
+		Type   string          `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+)
+
+// isReady returns true if the endpoint is ready. Endpoints with an unknown
+// ready condition should be treated as ready.
+func (c endpointConditions) isReady() bool {
+	return c.Ready == nil || *c.Ready
+}
@@ -0,0 +1,238 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSlices = `{
+  "metadata": {"resourceVersion": "10"},
+  "items": [
+    {
+      "metadata": {"name": "croissant-abcde"},
+      "endpoints": [
+        {"addresses": ["10.0.0.2"], "conditions": {"ready": true}},
+        {"addresses": ["10.0.0.1"], "conditions": {}},
+        {"addresses": ["10.0.0.3"], "conditions": {"ready": false}}
+      ],
+      "ports": [
+        {"name": "http", "port": 8080},
+        {"name": "grpc", "port": 9095}
+      ]
+    },
+    {
+      "metadata": {"name": "croissant-fghij"},
+      "endpoints": [
+        {"addresses": ["10.0.0.1"], "conditions": {"ready": true}}
+      ],
+      "ports": [
+        {"name": "grpc", "port": 9095}
+      ]
+    }
+  ]
+}`
+
+func TestDiscoverer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		require.Equal(t, "/apis/discovery.k8s.io/v1/namespaces/default/endpointslices", r.URL.Path)
+		require.Equal(t, "kubernetes.io/service-name=croissant", r.URL.Query().Get("labelSelector"))
+		if r.URL.Query().Get("watch") == "true" {
+			// Keep the watch open without any changes.
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(testSlices))
+	}))
+	t.Cleanup(srv.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	newDiscoverer := func() *Discoverer {
+		d := &Discoverer{
+			Service:   "croissant",
+			Namespace: "default",
+			PortName:  "grpc",
+			APIServer: srv.URL,
+			Client:    srv.Client(),
+			TokenFile: tokenFile,
+		}
+		t.Cleanup(func() { _ = d.Close() })
+		return d
+	}
+
+	t.Run("ready endpoints", func(t *testing.T) {
+		addrs, err := newDiscoverer().Discover(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.0.0.1:9095", "10.0.0.2:9095"}, addrs)
+	})
+
+	t.Run("not ready endpoints", func(t *testing.T) {
+		d := newDiscoverer()
+		d.IncludeNotReady = true
+
+		addrs, err := d.Discover(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"10.0.0.1:9095", "10.0.0.2:9095", "10.0.0.3:9095"}, addrs)
+	})
+
+	t.Run("ambiguous port", func(t *testing.T) {
+		d := newDiscoverer()
+		d.PortName = ""
+
+		_, err := d.Discover(context.Background())
+		require.Error(t, err)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		d := newDiscoverer()
+		d.TokenFile = filepath.Join(t.TempDir(), "missing")
+
+		_, err := d.Discover(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestDiscoverer_Watch(t *testing.T) {
+	var (
+		lists   = make(chan struct{}, 10)
+		watches = make(chan url.Values, 10)
+		events  = make(chan string)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			lists <- struct{}{}
+			_, _ = w.Write([]byte(`{
+  "metadata": {"resourceVersion": "1"},
+  "items": [{
+    "metadata": {"name": "a", "resourceVersion": "1"},
+    "endpoints": [{"addresses": ["10.0.0.1"]}],
+    "ports": [{"port": 9095}]
+  }]
+}`))
+			return
+		}
+
+		watches <- r.URL.Query()
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-events:
+				if ev == "" {
+					// End the watch.
+					return
+				}
+				_, _ = w.Write([]byte(ev + "\n"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	d := &Discoverer{
+		Service:   "croissant",
+		Namespace: "default",
+		APIServer: srv.URL,
+		Client:    srv.Client(),
+		TokenFile: filepath.Join(t.TempDir(), "missing"),
+	}
+	t.Cleanup(func() { _ = d.Close() })
+
+	addrs, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095"}, addrs)
+	<-lists
+
+	// The watch resumes from the listed resource version.
+	query := <-watches
+	require.Equal(t, "1", query.Get("resourceVersion"))
+
+	events <- `{"type": "ADDED", "object": {"metadata": {"name": "b", "resourceVersion": "2"}, "endpoints": [{"addresses": ["10.0.0.2"]}], "ports": [{"port": 9095}]}}`
+	events <- `{"type": "DELETED", "object": {"metadata": {"name": "a", "resourceVersion": "3"}}}`
+	requireAddrs(t, d, []string{"10.0.0.2:9095"})
+	require.Len(t, lists, 0, "changes should be watched without listing again")
+
+	// Watches that end are resumed from the last seen resource version.
+	events <- ""
+	query = <-watches
+	require.Equal(t, "3", query.Get("resourceVersion"))
+}
+
+func TestDiscoverer_WatchExpired(t *testing.T) {
+	var (
+		mut     sync.Mutex
+		address = "10.0.0.1"
+		lists   int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		defer mut.Unlock()
+
+		if r.URL.Query().Get("watch") == "true" {
+			// Expire the first watch; keep later ones open.
+			if lists == 1 {
+				address = "10.0.0.2"
+				_, _ = w.Write([]byte(`{"type": "ERROR", "object": {"code": 410, "message": "too old resource version"}}` + "\n"))
+				return
+			}
+			mut.Unlock()
+			<-r.Context().Done()
+			mut.Lock()
+			return
+		}
+
+		lists++
+		fmt.Fprintf(w, `{
+  "metadata": {"resourceVersion": "%d"},
+  "items": [{
+    "metadata": {"name": "a"},
+    "endpoints": [{"addresses": [%q]}],
+    "ports": [{"port": 9095}]
+  }]
+}`, lists, address)
+	}))
+	t.Cleanup(srv.Close)
+
+	d := &Discoverer{
+		Service:   "croissant",
+		Namespace: "default",
+		APIServer: srv.URL,
+		Client:    srv.Client(),
+		TokenFile: filepath.Join(t.TempDir(), "missing"),
+	}
+	t.Cleanup(func() { _ = d.Close() })
+
+	addrs, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1:9095"}, addrs)
+
+	// The expired watch causes the EndpointSlices to be listed again.
+	requireAddrs(t, d, []string{"10.0.0.2:9095"})
+}
+
+// requireAddrs waits for d to discover expect.
+func requireAddrs(t *testing.T, d *Discoverer, expect []string) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		addrs, err := d.Discover(context.Background())
+		return err == nil && reflect.DeepEqual(expect, addrs)
+	}, 5*time.Second, 10*time.Millisecond)
+}
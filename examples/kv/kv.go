@@ -12,6 +12,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/rfratto/croissant/discovery"
 	"github.com/rfratto/croissant/examples/kv/kvproto"
 	"github.com/rfratto/croissant/examples/kv/kvserver"
 	"github.com/rfratto/croissant/id"
@@ -29,6 +30,7 @@ func main() {
 		grpcListenAddr string
 		config         node.Config
 		joinAddr       string
+		replicas       int
 	)
 
 	config.Log = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
@@ -44,7 +46,8 @@ func main() {
 	fs.StringVar(&grpcListenAddr, "grpc-listen-addr", "0.0.0.0:9095", "address to response to gRPC requests on")
 	fs.StringVar(&name, "cluster-id", hn, "string to use to generate name of server. Defaults to using hostname")
 	fs.StringVar(&config.BroadcastAddr, "advertise-addr", "127.0.0.1:9095", "address to broadcast to peers for connecting.")
-	fs.StringVar(&joinAddr, "join-addr", "", "If non empty, joins the cluster of the given address.")
+	fs.StringVar(&joinAddr, "join-addr", "", "If non empty, joins the cluster of the given host:port. Prefix with srv+ to look up addresses with a DNS SRV query.")
+	fs.IntVar(&replicas, "replication-factor", 3, "number of nodes to store each key on.")
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		level.Error(config.Log).Log("msg", "invalid args", "err", err)
@@ -52,18 +55,46 @@ func main() {
 	}
 
 	config.ID = id.NewGenerator(32).Get(name)
+	config.ReplicationFactor = replicas
 
-	var joinAddrs []string
+	var join discovery.DNS
 	if joinAddr != "" {
-		joinAddrs = append(joinAddrs, joinAddr)
+		join.Names = append(join.Names, joinAddr)
 	}
 
 	var lb node.Router
 
+	// Replication requests are sent directly to replicas and must not be
+	// routed.
+	lb.Exclude("/example.kv.v1.Replica/Replicate")
+
 	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(lb.Unary()))
 
+	// Create our KV server. Keys must be converted into IDs the same way
+	// clients do.
+	var n *node.Node
+	kv := kvserver.NewReplicated(config.Log, kvserver.ReplicationConfig{
+		Self: kvserver.Peer{ID: config.ID, Addr: config.BroadcastAddr},
+		Keys: id.NewGenerator(32),
+		Replicas: func(key id.ID) ([]kvserver.Peer, error) {
+			ps, err := n.Replicas(key)
+			if err != nil {
+				return nil, err
+			}
+			replicas := make([]kvserver.Peer, len(ps))
+			for i, p := range ps {
+				replicas[i] = kvserver.Peer{ID: p.ID, Addr: p.Addr}
+			}
+			return replicas, nil
+		},
+		DialOptions: []grpc.DialOption{grpc.WithInsecure()},
+	})
+	defer kv.Close()
+	kvproto.RegisterKVServer(srv, kv)
+	kvproto.RegisterReplicaServer(srv, kv)
+
 	// Register the node
-	n, err := node.New(config, fakeApp{}, grpc.WithInsecure())
+	n, err = node.New(config, kvApp{kv: kv}, node.WithDialOptions(grpc.WithInsecure()))
 	if err != nil {
 		level.Error(config.Log).Log("msg", "failed to create http listener", "err", err)
 		os.Exit(1)
@@ -71,9 +102,6 @@ func main() {
 	n.Register(srv)
 	lb.SetNode(n)
 
-	// Create our KV server
-	kvproto.RegisterKVServer(srv, kvserver.New(config.Log))
-
 	httpLis, err := net.Listen("tcp", httpListenAddr)
 	if err != nil {
 		level.Error(config.Log).Log("msg", "failed to create http listener", "err", err)
@@ -86,9 +114,7 @@ func main() {
 	}
 
 	r := mux.NewRouter()
-	r.HandleFunc("/-/cluster", func(rw http.ResponseWriter, r *http.Request) {
-		node.WriteHTTPState(config.Log, rw, n)
-	})
+	r.PathPrefix("/-/").Handler(node.Handler(config.Log, n))
 	r.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
 
 	// Start the gRPC server and give 200ms for it to start up before we join
@@ -96,7 +122,7 @@ func main() {
 	go srv.Serve(grpcLis)
 	time.Sleep(200 * time.Millisecond)
 
-	if err := n.Join(context.Background(), joinAddrs); err != nil {
+	if err := n.JoinDiscovered(context.Background(), &join); err != nil {
 		level.Error(config.Log).Log("msg", "failed to join cluster", "err", err)
 		os.Exit(1)
 	}
@@ -105,6 +131,11 @@ func main() {
 	http.Serve(httpLis, r)
 }
 
-type fakeApp struct{}
+// kvApp moves keys between replicas when peers change.
+type kvApp struct {
+	kv *kvserver.Server
+}
 
-func (fa fakeApp) PeersChanged(ps []node.Peer) {}
+func (a kvApp) PeersChanged(_ []node.Peer) {
+	a.kv.Rebalance()
+}
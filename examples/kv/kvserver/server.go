@@ -3,32 +3,92 @@ package kvserver
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/id"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 // Server implements an example KV store. Don't use this for production.
+//
+// If created with NewReplicated, each key is stored on the replicas returned
+// by ReplicationConfig.Replicas. Rebalance must then be called whenever the
+// node's peers change so keys can be moved to their new replicas.
 type Server struct {
 	kvproto.UnimplementedKVServer
+	kvproto.UnimplementedReplicaServer
 
 	mut  sync.Mutex
 	data map[string]string
 
-	l log.Logger
+	l   log.Logger
+	cfg ReplicationConfig
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	trigger chan struct{}
+	done    chan struct{}
+
+	connMut sync.Mutex
+	conns   map[string]*grpc.ClientConn
+}
+
+// Peer is a node running a Server.
+type Peer struct {
+	ID   id.ID
+	Addr string
+}
+
+// ReplicationConfig configures replication of a Server.
+type ReplicationConfig struct {
+	// Self is the node the Server is running on.
+	Self Peer
+
+	// Keys generates IDs for keys. Must be the same generator used by
+	// clients.
+	Keys id.Generator
+
+	// Replicas returns the nodes that should store the key with the given
+	// ID, owner first. Should be set to a function calling the Replicas
+	// method of the node, so the Server agrees with the node about who owns
+	// a key. The number of replicas is set by the ReplicationFactor of the
+	// node. If nil, keys are only stored locally.
+	Replicas func(key id.ID) ([]Peer, error)
+
+	// DialOptions are used when connecting to other replicas.
+	DialOptions []grpc.DialOption
 }
 
+// New creates a Server which stores data locally without replication.
 func New(l log.Logger) *Server {
+	return NewReplicated(l, ReplicationConfig{})
+}
+
+// NewReplicated creates a Server which replicates data. Close must be called
+// to stop the Server from rebalancing keys.
+func NewReplicated(l log.Logger, cfg ReplicationConfig) *Server {
 	if l == nil {
 		l = log.NewNopLogger()
 	}
-	return &Server{
-		data: make(map[string]string),
-		l:    l,
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		data:    make(map[string]string),
+		l:       l,
+		cfg:     cfg,
+		ctx:     ctx,
+		cancel:  cancel,
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		conns:   make(map[string]*grpc.ClientConn),
 	}
+	go s.run()
+	return s
 }
 
 func (s *Server) Get(ctx context.Context, req *kvproto.GetRequest) (*kvproto.GetResponse, error) {
@@ -45,15 +105,190 @@ func (s *Server) Get(ctx context.Context, req *kvproto.GetRequest) (*kvproto.Get
 }
 
 func (s *Server) Set(ctx context.Context, req *kvproto.SetRequest) (*kvproto.SetResponse, error) {
-	s.mut.Lock()
-	defer s.mut.Unlock()
-
 	level.Info(s.l).Log("msg", "setting key", "key", req.Key, "value", req.Value)
 
+	replicas, err := s.replicas(req.GetKey())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to find replicas for key %s: %s", req.GetKey(), err)
+	}
+
+	s.mut.Lock()
 	s.data[req.GetKey()] = req.GetValue()
+	s.mut.Unlock()
+
+	entry := &kvproto.Entry{Key: req.GetKey(), Value: req.GetValue()}
+	for _, r := range replicas {
+		if r == s.cfg.Self {
+			continue
+		}
+		if err := s.replicate(ctx, r, []*kvproto.Entry{entry}); err != nil {
+			level.Warn(s.l).Log("msg", "failed to replicate key", "key", req.Key, "replica", r.Addr, "err", err)
+			return nil, status.Errorf(codes.Unavailable, "failed to replicate key %s: %s", req.GetKey(), err)
+		}
+	}
+
 	return &kvproto.SetResponse{}, nil
 }
 
+// Replicate stores entries sent from another replica.
+func (s *Server) Replicate(ctx context.Context, req *kvproto.ReplicateRequest) (*kvproto.ReplicateResponse, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	level.Debug(s.l).Log("msg", "received replicated entries", "count", len(req.GetEntries()))
+
+	for _, e := range req.GetEntries() {
+		s.data[e.GetKey()] = e.GetValue()
+	}
+	return &kvproto.ReplicateResponse{}, nil
+}
+
+// Rebalance schedules keys to be copied to any new replicas and removed
+// locally if this node is no longer a replica for them. Should be called
+// whenever the peers of the node change. Calls made while a rebalance is
+// pending are coalesced into a single rebalance.
+func (s *Server) Rebalance() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// run rebalances keys whenever a rebalance is triggered until the Server is
+// closed. Rebalances are serialized so they never send stale values or race
+// to delete keys.
+func (s *Server) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.trigger:
+			s.rebalance()
+		}
+	}
+}
+
+// rebalance sends keys to their replicas. Keys are sent by the owner, or by
+// any node holding a key that is no longer a replica for it.
+func (s *Server) rebalance() {
+	ctx, cancel := context.WithTimeout(s.ctx, time.Minute)
+	defer cancel()
+
+	s.mut.Lock()
+	var (
+		send    = make(map[Peer][]*kvproto.Entry)
+		dropped = make(map[string]string)
+	)
+	for k, v := range s.data {
+		replicas, err := s.replicas(k)
+		if err != nil {
+			// Keep keys whose replicas can't be determined so they aren't
+			// lost.
+			level.Debug(s.l).Log("msg", "failed to find replicas for key", "key", k, "err", err)
+			continue
+		}
+
+		var (
+			primary = len(replicas) > 0 && replicas[0] == s.cfg.Self
+			owned   = primary
+		)
+		for _, r := range replicas {
+			if r == s.cfg.Self {
+				owned = true
+			}
+		}
+		if owned && !primary {
+			continue
+		}
+
+		for _, r := range replicas {
+			if r != s.cfg.Self {
+				send[r] = append(send[r], &kvproto.Entry{Key: k, Value: v})
+			}
+		}
+		if !owned {
+			dropped[k] = v
+		}
+	}
+	s.mut.Unlock()
+
+	for r, entries := range send {
+		if err := s.replicate(ctx, r, entries); err != nil {
+			level.Warn(s.l).Log("msg", "failed to move keys to replica", "replica", r.Addr, "err", err)
+
+			// Keep the keys around so they aren't lost.
+			for _, e := range entries {
+				delete(dropped, e.Key)
+			}
+		}
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var removed int
+	for k, v := range dropped {
+		// Keys may have been set while they were being sent to their
+		// replicas, which then only received the old value.
+		if cur, ok := s.data[k]; ok && cur == v {
+			delete(s.data, k)
+			removed++
+		}
+	}
+	if removed > 0 {
+		level.Info(s.l).Log("msg", "removed keys no longer owned", "count", removed)
+	}
+}
+
+// replicas returns the nodes that should store key, owner first.
+func (s *Server) replicas(key string) ([]Peer, error) {
+	if s.cfg.Replicas == nil {
+		return []Peer{s.cfg.Self}, nil
+	}
+	return s.cfg.Replicas(s.cfg.Keys.Get(key))
+}
+
+func (s *Server) replicate(ctx context.Context, p Peer, entries []*kvproto.Entry) error {
+	cc, err := s.getConn(p.Addr)
+	if err != nil {
+		return err
+	}
+	_, err = kvproto.NewReplicaClient(cc).Replicate(ctx, &kvproto.ReplicateRequest{Entries: entries})
+	return err
+}
+
+func (s *Server) getConn(addr string) (*grpc.ClientConn, error) {
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	if cc, ok := s.conns[addr]; ok {
+		return cc, nil
+	}
+	cc, err := grpc.Dial(addr, s.cfg.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	s.conns[addr] = cc
+	return cc, nil
+}
+
+// Close stops rebalancing keys and closes connections to other replicas.
+func (s *Server) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	for addr, cc := range s.conns {
+		_ = cc.Close()
+		delete(s.conns, addr)
+	}
+	return nil
+}
+
 // Func is a function-based server.
 type Func struct {
 	kvproto.UnimplementedKVServer
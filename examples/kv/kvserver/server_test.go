@@ -0,0 +1,195 @@
+package kvserver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestServer_Set(t *testing.T) {
+	var (
+		a = startTestServer(t, id.ID{Low: 1})
+		b = startTestServer(t, id.ID{Low: 2})
+	)
+	a.replicas.Set(a.peer, b.peer)
+
+	_, err := a.Set(context.Background(), &kvproto.SetRequest{Key: "hello", Value: "world"})
+	require.NoError(t, err)
+
+	requireValue(t, a.Server, "hello", "world")
+	requireValue(t, b.Server, "hello", "world")
+}
+
+func TestServer_Rebalance(t *testing.T) {
+	var (
+		a = startTestServer(t, id.ID{Low: 1})
+		b = startTestServer(t, id.ID{Low: 2})
+		c = startTestServer(t, id.ID{Low: 3})
+	)
+	a.replicas.Set(a.peer)
+
+	_, err := a.Set(context.Background(), &kvproto.SetRequest{Key: "hello", Value: "world"})
+	require.NoError(t, err)
+
+	// a is no longer a replica for the key, so it should move the key to the
+	// new replicas and drop it.
+	a.replicas.Set(b.peer, c.peer)
+	a.Rebalance()
+
+	require.Eventually(t, func() bool {
+		return !hasKey(a.Server, "hello")
+	}, 5*time.Second, 10*time.Millisecond)
+	requireValue(t, b.Server, "hello", "world")
+	requireValue(t, c.Server, "hello", "world")
+}
+
+func TestServer_Rebalance_Owner(t *testing.T) {
+	var (
+		a = startTestServer(t, id.ID{Low: 1})
+		b = startTestServer(t, id.ID{Low: 2})
+	)
+	a.replicas.Set(a.peer)
+
+	_, err := a.Set(context.Background(), &kvproto.SetRequest{Key: "hello", Value: "world"})
+	require.NoError(t, err)
+
+	// a is still the owner of the key, so it should copy the key to the new
+	// replica and keep it.
+	a.replicas.Set(a.peer, b.peer)
+	a.Rebalance()
+
+	require.Eventually(t, func() bool {
+		return hasKey(b.Server, "hello")
+	}, 5*time.Second, 10*time.Millisecond)
+	requireValue(t, a.Server, "hello", "world")
+}
+
+func TestServer_Rebalance_ConcurrentSet(t *testing.T) {
+	var (
+		a = startTestServer(t, id.ID{Low: 1})
+		b = startTestServer(t, id.ID{Low: 2})
+	)
+
+	// Hold replication to b until the key has been set again on a.
+	var (
+		received = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	b.onReplicate = func() {
+		close(received)
+		<-release
+	}
+
+	a.replicas.Set(a.peer)
+	_, err := a.Set(context.Background(), &kvproto.SetRequest{Key: "hello", Value: "world"})
+	require.NoError(t, err)
+
+	a.replicas.Set(b.peer)
+
+	rebalanced := make(chan struct{})
+	go func() {
+		defer close(rebalanced)
+		a.rebalance()
+	}()
+	<-received
+
+	_, err = a.Replicate(context.Background(), &kvproto.ReplicateRequest{
+		Entries: []*kvproto.Entry{{Key: "hello", Value: "updated"}},
+	})
+	require.NoError(t, err)
+	close(release)
+	<-rebalanced
+
+	// The updated value must not be dropped, since b only received the old
+	// value.
+	requireValue(t, a.Server, "hello", "updated")
+	requireValue(t, b.Server, "hello", "world")
+}
+
+type testServer struct {
+	*Server
+
+	peer     Peer
+	replicas *testReplicas
+
+	// onReplicate, if set, is invoked before replicated entries are stored.
+	onReplicate func()
+}
+
+func (s *testServer) Replicate(ctx context.Context, req *kvproto.ReplicateRequest) (*kvproto.ReplicateResponse, error) {
+	if s.onReplicate != nil {
+		s.onReplicate()
+	}
+	return s.Server.Replicate(ctx, req)
+}
+
+// startTestServer starts a replicated Server listening on a random port.
+// The replicas of every key are determined by the returned testReplicas.
+func startTestServer(t *testing.T, nodeID id.ID) *testServer {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		self     = Peer{ID: nodeID, Addr: lis.Addr().String()}
+		replicas = &testReplicas{}
+	)
+	s := &testServer{
+		Server: NewReplicated(nil, ReplicationConfig{
+			Self:        self,
+			Keys:        id.NewGenerator(32),
+			Replicas:    replicas.Get,
+			DialOptions: []grpc.DialOption{grpc.WithInsecure()},
+		}),
+		peer:     self,
+		replicas: replicas,
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	srv := grpc.NewServer()
+	kvproto.RegisterKVServer(srv, s)
+	kvproto.RegisterReplicaServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return s
+}
+
+// testReplicas returns the same replicas for every key.
+type testReplicas struct {
+	mut   sync.Mutex
+	peers []Peer
+}
+
+func (r *testReplicas) Set(peers ...Peer) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.peers = peers
+}
+
+func (r *testReplicas) Get(_ id.ID) ([]Peer, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.peers, nil
+}
+
+func hasKey(s *Server, key string) bool {
+	_, err := s.Get(context.Background(), &kvproto.GetRequest{Key: key})
+	return err == nil
+}
+
+func requireValue(t *testing.T, s *Server, key, value string) {
+	t.Helper()
+
+	resp, err := s.Get(context.Background(), &kvproto.GetRequest{Key: key})
+	require.NoError(t, err)
+	require.Equal(t, value, resp.GetValue())
+}
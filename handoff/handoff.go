@@ -0,0 +1,522 @@
+// Package handoff streams application data to the nodes taking over
+// ownership of keys, such as when a node joins next to the local node or
+// when the local node leaves the cluster.
+//
+// Applications provide their data through a Store and tie a Manager into
+// joins and leaves by embedding it in their node.Application:
+//
+//	type app struct {
+//	  *handoff.Manager
+//	}
+//
+// Manager implements node.OwnershipWatcher, transferring ranges the local
+// node lost to their new owners in the background, and node.HandoffHandler,
+// transferring every range owned by the local node before it leaves through
+// Node.Leave. Every node must register its Manager to its gRPC server to
+// receive data.
+//
+// Transfers are streamed in batches. Only a limited number of batches may
+// wait to be stored by the receiver, so slow receivers slow down senders.
+// Interrupted transfers are retried, resuming after the last record the
+// receiver stored.
+//
+// Individual writes may be replicated with Manager.Replicate. Records for
+// unavailable replicas are delivered to another node along with a hint,
+// and that node replays them to the replica once it recovers. Hints are
+// queued in a HintStore, such as a FileHintStore.
+package handoff
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/connpool"
+	"github.com/rfratto/croissant/internal/handoffpb"
+	"github.com/rfratto/croissant/node"
+	"google.golang.org/grpc"
+)
+
+// Record is a piece of application data stored under a key.
+type Record struct {
+	Key  id.ID
+	Data []byte
+}
+
+// Store is implemented by applications to read the data being transferred
+// to other nodes and to store the data transferred from other nodes.
+type Store interface {
+	// Scan calls fn for every record with a key in r. Records must be
+	// scanned in a stable order, such as sorted by key, so interrupted
+	// transfers can be resumed by scanning r again. If fn returns an error,
+	// Scan must stop and return it.
+	Scan(ctx context.Context, r node.KeyRange, fn func(Record) error) error
+
+	// Store stores records received from another node. The records of a
+	// transfer are stored in the order the sender scanned them.
+	Store(ctx context.Context, records []Record) error
+}
+
+// Config configures a Manager.
+type Config struct {
+	// BatchSize is the number of records sent at once. Defaults to 100 if
+	// unset.
+	BatchSize int
+
+	// Window is the number of batches which may be waiting to be stored by
+	// the receiver before sending more. Defaults to 4 if unset.
+	Window int
+
+	// MaxRetries is the number of times interrupted transfers are resumed
+	// before giving up. Defaults to 3 if unset. Set to a negative value to
+	// never resume transfers.
+	MaxRetries int
+
+	// RetryBackoff is the time to wait before resuming an interrupted
+	// transfer. The backoff doubles after each attempt. Defaults to 1s if
+	// unset.
+	RetryBackoff time.Duration
+
+	// ResumeTimeout is how long receivers remember interrupted transfers so
+	// they can be resumed. Defaults to 10m if unset.
+	ResumeTimeout time.Duration
+
+	// Hints, if set, queues records for replicas which were unavailable when
+	// the records were written through Replicate. Queued records are
+	// replayed every HintReplayInterval. If unset, the local node doesn't
+	// accept hints from other nodes.
+	Hints HintStore
+
+	// HintReplayInterval is how often records queued in Hints are replayed
+	// to their peers. Defaults to 10s if unset.
+	HintReplayInterval time.Duration
+
+	// OnProgress, if set, is invoked whenever a transfer sent by the local
+	// node makes progress, and once more when it completes or fails.
+	OnProgress func(p Progress)
+
+	// Log will be used for logging messages. Use node.NewSlogLogger to log
+	// with log/slog.
+	Log log.Logger
+}
+
+// Progress describes a transfer sent by the local node.
+type Progress struct {
+	// Range and Peer are the range of keys being transferred and the peer
+	// they're transferred to.
+	Range node.KeyRange
+	Peer  node.Peer
+
+	// Sent and Acked are the number of records sent to Peer and the number
+	// of records Peer stored. Records stored by a previous attempt of an
+	// interrupted transfer are counted in both.
+	Sent, Acked uint64
+
+	// Done is true once the transfer completed or failed. Err is set if the
+	// transfer failed.
+	Done bool
+	Err  error
+}
+
+// Manager transfers application data between nodes.
+type Manager struct {
+	cfg   Config
+	store Store
+	pool  *connpool.Pool
+	nonce string // Unique to this Manager, used to build transfer IDs.
+
+	mut       sync.Mutex
+	transfers map[string]*transfer // Transfers sent by the local node.
+	received  map[string]*received // Transfers received by the local node.
+
+	ctx    context.Context // Canceled by Close to stop background transfers.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var (
+	_ node.OwnershipWatcher = (*Manager)(nil)
+	_ node.HandoffHandler   = (*Manager)(nil)
+)
+
+// transfer is a transfer sent by the local node.
+type transfer struct {
+	id string
+
+	mut      sync.Mutex
+	progress Progress
+}
+
+// received is a transfer received by the local node.
+type received struct {
+	acked   uint64    // Sequence number of the last stored record.
+	active  bool      // Set while a stream is receiving the transfer.
+	updated time.Time // Last time the transfer made progress.
+}
+
+// New creates a new Manager which reads and writes data using store. The
+// provided DialOptions are used when communicating with peers. Manager must
+// be registered to the same gRPC server as the node with Register.
+func New(cfg Config, store Store, dial ...grpc.DialOption) *Manager {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Window == 0 {
+		cfg.Window = 4
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff == 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	if cfg.ResumeTimeout == 0 {
+		cfg.ResumeTimeout = 10 * time.Minute
+	}
+	if cfg.HintReplayInterval == 0 {
+		cfg.HintReplayInterval = 10 * time.Second
+	}
+	if cfg.Log == nil {
+		cfg.Log = log.NewNopLogger()
+	}
+
+	nonce := make([]byte, 8)
+	_, _ = rand.Read(nonce)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Manager{
+		cfg:   cfg,
+		store: store,
+		pool:  connpool.New(250, dial...),
+		nonce: hex.EncodeToString(nonce),
+
+		transfers: make(map[string]*transfer),
+		received:  make(map[string]*received),
+
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if cfg.Hints != nil {
+		h.wg.Add(1)
+		go h.replayLoop()
+	}
+	return h
+}
+
+// Register registers the Handoff service to gRPC.
+func (h *Manager) Register(s grpc.ServiceRegistrar) {
+	handoffpb.RegisterHandoffServer(s, &server{h: h})
+}
+
+// Handoff transfers every range in changes to its peer, returning once
+// every transfer completed. Handoff is invoked by Node.Leave when the Manager
+// is part of the node's Application.
+func (h *Manager) Handoff(ctx context.Context, changes []node.OwnershipChange) error {
+	var (
+		wg       sync.WaitGroup
+		errMut   sync.Mutex
+		firstErr error
+	)
+	for _, c := range changes {
+		if c.Peer.Addr == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(c node.OwnershipChange) {
+			defer wg.Done()
+			if err := h.Transfer(ctx, c.Range, c.Peer); err != nil {
+				errMut.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMut.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// OwnershipChanged transfers the ranges the local node lost to their new
+// owners in the background. Ranges which are now owned by more than one
+// peer are not transferred.
+func (h *Manager) OwnershipChanged(changes []node.OwnershipChange) {
+	for _, c := range changes {
+		if c.Gained {
+			continue
+		} else if c.Peer.Addr == "" {
+			level.Warn(h.cfg.Log).Log("msg", "not transferring range owned by multiple peers", "start", c.Range.Start, "end", c.Range.End)
+			continue
+		}
+
+		h.wg.Add(1)
+		go func(c node.OwnershipChange) {
+			defer h.wg.Done()
+			_ = h.Transfer(h.ctx, c.Range, c.Peer)
+		}(c)
+	}
+}
+
+// Transfer streams the records in r to peer, returning once peer stored
+// every record. Interrupted transfers are retried as configured by
+// Config.MaxRetries.
+func (h *Manager) Transfer(ctx context.Context, r node.KeyRange, peer node.Peer) error {
+	t := h.startTransfer(r, peer)
+
+	var (
+		backoff = h.cfg.RetryBackoff
+		err     error
+	)
+	for attempt := 0; ; attempt++ {
+		if err = h.send(ctx, t); err == nil || ctx.Err() != nil || attempt >= h.cfg.MaxRetries {
+			break
+		}
+		level.Warn(h.cfg.Log).Log("msg", "transfer interrupted, resuming", "peer", peer.Addr, "start", r.Start, "end", r.End, "err", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	if err != nil {
+		level.Error(h.cfg.Log).Log("msg", "transfer failed", "peer", peer.Addr, "start", r.Start, "end", r.End, "err", err)
+		err = fmt.Errorf("failed to transfer %s-%s to %s: %w", r.Start, r.End, peer.Addr, err)
+	}
+	h.finishTransfer(t, err)
+	return err
+}
+
+// Progress returns the progress of every transfer currently being sent by
+// the local node.
+func (h *Manager) Progress() []Progress {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	res := make([]Progress, 0, len(h.transfers))
+	for _, t := range h.transfers {
+		t.mut.Lock()
+		res = append(res, t.progress)
+		t.mut.Unlock()
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return id.Compare(res[i].Range.Start, res[j].Range.Start) < 0
+	})
+	return res
+}
+
+// startTransfer tracks a new transfer of r to peer.
+func (h *Manager) startTransfer(r node.KeyRange, peer node.Peer) *transfer {
+	t := &transfer{
+		id:       fmt.Sprintf("%s/%s/%s-%s", h.nonce, peer.ID, r.Start, r.End),
+		progress: Progress{Range: r, Peer: peer},
+	}
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	h.transfers[t.id] = t
+	return t
+}
+
+// finishTransfer stops tracking t.
+func (h *Manager) finishTransfer(t *transfer, err error) {
+	h.mut.Lock()
+	delete(h.transfers, t.id)
+	h.mut.Unlock()
+
+	h.update(t, func(p *Progress) {
+		p.Done, p.Err = true, err
+	})
+}
+
+// update updates the progress of t and reports it to Config.OnProgress.
+func (h *Manager) update(t *transfer, f func(p *Progress)) {
+	t.mut.Lock()
+	f(&t.progress)
+	p := t.progress
+	t.mut.Unlock()
+
+	if h.cfg.OnProgress != nil {
+		h.cfg.OnProgress(p)
+	}
+}
+
+// send makes one attempt to send t, resuming after the last record stored
+// by the receiver.
+func (h *Manager) send(ctx context.Context, t *transfer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t.mut.Lock()
+	var (
+		r    = t.progress.Range
+		peer = t.progress.Peer
+	)
+	t.mut.Unlock()
+
+	cc, err := h.pool.Get(peer.Addr)
+	if err != nil {
+		return err
+	}
+	stream, err := handoffpb.NewHandoffClient(cc).Transfer(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = stream.Send(&handoffpb.TransferRequest{
+		Start: &handoffpb.TransferStart{
+			Id:    t.id,
+			Start: &handoffpb.ID{High: r.Start.High, Low: r.Start.Low},
+			End:   &handoffpb.ID{High: r.End.High, Low: r.End.Low},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	resume := resp.AckedSeq
+	h.update(t, func(p *Progress) { p.Sent, p.Acked = resume, resume })
+
+	// Receive acknowledgements in the background so batches can be sent
+	// while waiting for earlier batches to be stored.
+	var (
+		acks    = make(chan uint64)
+		recvErr = make(chan error, 1)
+	)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case acks <- resp.AckedSeq:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		inflight int
+		seq      uint64
+		firstSeq uint64
+		batch    []*handoffpb.Record
+	)
+
+	// waitAck waits for the receiver to store a batch. The error of the
+	// stream is returned if the receiver ended the stream instead.
+	waitAck := func() error {
+		select {
+		case acked := <-acks:
+			inflight--
+			h.update(t, func(p *Progress) { p.Acked = acked })
+			return nil
+		case err := <-recvErr:
+			if err == io.EOF {
+				err = errors.New("receiver ended transfer early")
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		for inflight >= h.cfg.Window {
+			if err := waitAck(); err != nil {
+				return err
+			}
+		}
+
+		err := stream.Send(&handoffpb.TransferRequest{FirstSeq: firstSeq, Records: batch})
+		if err == io.EOF {
+			// The receiver ended the stream; get the real error from Recv.
+			for err == io.EOF || err == nil {
+				err = waitAck()
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		inflight++
+		sent := firstSeq + uint64(len(batch)) - 1
+		h.update(t, func(p *Progress) { p.Sent = sent })
+		batch = nil
+		return nil
+	}
+
+	err = h.store.Scan(ctx, r, func(rec Record) error {
+		seq++
+		if seq <= resume {
+			return nil
+		}
+		if len(batch) == 0 {
+			firstSeq = seq
+		}
+		batch = append(batch, &handoffpb.Record{
+			Key:  &handoffpb.ID{High: rec.Key.High, Low: rec.Key.Low},
+			Data: rec.Data,
+		})
+		if len(batch) >= h.cfg.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for inflight > 0 {
+		if err := waitAck(); err != nil {
+			return err
+		}
+	}
+
+	// The receiver ends the stream once it stored everything.
+	for {
+		select {
+		case <-acks:
+		case err := <-recvErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops transfers started in the background by OwnershipChanged and
+// stops replaying hints.
+func (h *Manager) Close() error {
+	h.cancel()
+	h.wg.Wait()
+	return h.pool.Close()
+}
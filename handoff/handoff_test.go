@@ -0,0 +1,301 @@
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestHandoff_Transfer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		src = newMemStore(250)
+		dst = newMemStore(0)
+
+		progressMut sync.Mutex
+		progress    []Progress
+	)
+
+	sender := New(Config{
+		BatchSize: 10,
+		Window:    2,
+		OnProgress: func(p Progress) {
+			progressMut.Lock()
+			defer progressMut.Unlock()
+			progress = append(progress, p)
+		},
+	}, src, grpc.WithInsecure())
+	defer sender.Close()
+	receiver := New(Config{}, dst)
+	defer receiver.Close()
+
+	addr := serve(t, receiver)
+
+	r := node.KeyRange{Start: id.ID{Low: 50}, End: id.ID{Low: 149}}
+	require.NoError(t, sender.Transfer(ctx, r, node.Peer{Addr: addr}))
+	require.Equal(t, src.records(r), dst.records(r))
+	require.Len(t, dst.records(r), 100)
+	require.Empty(t, sender.Progress())
+
+	progressMut.Lock()
+	defer progressMut.Unlock()
+	last := progress[len(progress)-1]
+	require.True(t, last.Done)
+	require.NoError(t, last.Err)
+	require.Equal(t, uint64(100), last.Acked)
+	require.Equal(t, uint64(100), last.Sent)
+}
+
+func TestHandoff_Resume(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		src = newMemStore(100)
+		dst = newMemStore(0)
+	)
+	// Fail storing once after 30 records were stored.
+	dst.failAt = 30
+
+	sender := New(Config{BatchSize: 10, RetryBackoff: 10 * time.Millisecond}, src, grpc.WithInsecure())
+	defer sender.Close()
+	receiver := New(Config{}, dst)
+	defer receiver.Close()
+
+	addr := serve(t, receiver)
+
+	r := node.KeyRange{Start: id.Zero, End: id.ID{Low: 99}}
+	require.NoError(t, sender.Transfer(ctx, r, node.Peer{Addr: addr}))
+
+	// Every record should be stored exactly once.
+	require.Equal(t, src.records(r), dst.records(r))
+	require.Equal(t, 100, dst.stored)
+}
+
+func TestHandoff_JoinLeave(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	full := node.KeyRange{Start: id.ID{Low: 1}, End: id.Zero}
+
+	seedStore := newMemStore(0)
+	for i := uint64(0); i < 64; i++ {
+		seedStore.add(Record{Key: id.ID{Low: i << 26}, Data: []byte(fmt.Sprint(i))})
+	}
+	seed, _ := makeTestNode(t, log.With(l, "node", "seed"), seedStore)
+	defer seed.Close()
+	require.NoError(t, seed.Join(ctx, nil))
+
+	// The joining node takes over some keys from the seed, which should be
+	// transferred to it.
+	peerStore := newMemStore(0)
+	peer, _ := makeTestNode(t, log.With(l, "node", "peer"), peerStore)
+	require.NoError(t, peer.Join(ctx, []string{seed.State().Node.Addr}))
+
+	owned := peer.OwnedRange()
+	require.Eventually(t, func() bool {
+		return len(peerStore.records(owned)) == len(seedStore.records(owned))
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, seedStore.records(owned), peerStore.records(owned))
+
+	// Leaving should transfer every key back to the seed. Leave closes the
+	// node.
+	peerStore.add(Record{Key: owned.End, Data: []byte("new")})
+	require.NoError(t, peer.Leave(ctx))
+	require.Contains(t, seedStore.records(full), Record{Key: owned.End, Data: []byte("new")})
+}
+
+func TestHandoff_Replicate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		replicaStore  = newMemStore(0)
+		downStore     = newMemStore(0)
+		fallbackStore = newMemStore(0)
+	)
+	downStore.down = true
+
+	hints, err := NewFileHintStore(t.TempDir())
+	require.NoError(t, err)
+
+	sender := New(Config{}, newMemStore(0), grpc.WithInsecure())
+	defer sender.Close()
+
+	var peers []node.Peer
+	for i, store := range []*memStore{replicaStore, downStore, fallbackStore} {
+		cfg := Config{}
+		if store == fallbackStore {
+			cfg.Hints = hints
+		}
+		receiver := New(cfg, store)
+		defer receiver.Close()
+		peers = append(peers, node.Peer{ID: id.ID{Low: uint64(i)}, Addr: serve(t, receiver)})
+	}
+
+	all := node.KeyRange{Start: id.ID{Low: 1}, End: id.Zero}
+	records := []Record{{Key: id.ID{Low: 10}, Data: []byte("a")}, {Key: id.ID{Low: 20}, Data: []byte("b")}}
+	require.NoError(t, sender.Replicate(ctx, records, peers, 2))
+
+	// The fallback should have queued the records for the unavailable
+	// replica instead of storing them.
+	require.Equal(t, records, replicaStore.records(all))
+	require.Empty(t, downStore.records(all))
+	require.Empty(t, fallbackStore.records(all))
+
+	hinted, err := hints.HintedPeers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []node.Peer{peers[1]}, hinted)
+
+	// Replaying should fail while the replica is still down and deliver the
+	// records once it recovers.
+	fallback := New(Config{Hints: hints}, fallbackStore, grpc.WithInsecure())
+	defer fallback.Close()
+	require.Error(t, fallback.ReplayHints(ctx))
+
+	downStore.setDown(false)
+	require.NoError(t, fallback.ReplayHints(ctx))
+	require.Equal(t, records, downStore.records(all))
+
+	hinted, err = hints.HintedPeers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, hinted)
+}
+
+// memStore is a Store holding records in memory.
+type memStore struct {
+	mut    sync.Mutex
+	recs   []Record
+	stored int  // Number of records stored.
+	failAt int  // Fail storing once after failAt records were stored.
+	down   bool // Fail storing while set.
+}
+
+// newMemStore creates a memStore with records with keys 0 through n-1.
+func newMemStore(n int) *memStore {
+	s := &memStore{}
+	for i := 0; i < n; i++ {
+		s.add(Record{Key: id.ID{Low: uint64(i)}, Data: []byte(fmt.Sprint(i))})
+	}
+	return s
+}
+
+func (s *memStore) add(recs ...Record) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.recs = append(s.recs, recs...)
+	sort.SliceStable(s.recs, func(i, j int) bool {
+		return id.Compare(s.recs[i].Key, s.recs[j].Key) < 0
+	})
+}
+
+// records returns the records in r.
+func (s *memStore) records(r node.KeyRange) []Record {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var res []Record
+	for _, rec := range s.recs {
+		if r.Contains(rec.Key) {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
+
+func (s *memStore) Scan(ctx context.Context, r node.KeyRange, fn func(Record) error) error {
+	for _, rec := range s.records(r) {
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memStore) setDown(down bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.down = down
+}
+
+func (s *memStore) Store(ctx context.Context, recs []Record) error {
+	s.mut.Lock()
+	if s.down {
+		s.mut.Unlock()
+		return errors.New("store is down")
+	}
+	if s.failAt > 0 && s.stored >= s.failAt {
+		s.failAt = 0
+		s.mut.Unlock()
+		return errors.New("store failed")
+	}
+	s.stored += len(recs)
+	s.mut.Unlock()
+
+	s.add(recs...)
+	return nil
+}
+
+// serve registers h to a new gRPC server, returning its address.
+func serve(t *testing.T, h *Manager) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	h.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+// app is a node.Application using a Manager.
+type app struct {
+	*Manager
+}
+
+func (app) PeersChanged([]node.Peer) {}
+
+func makeTestNode(t *testing.T, l log.Logger, store Store) (*node.Node, *Manager) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	h := New(Config{Log: l}, store, grpc.WithInsecure())
+	t.Cleanup(func() { _ = h.Close() })
+
+	n, err := node.New(node.Config{
+		ID:            id.NewGenerator(32).Get(lis.Addr().String()),
+		BroadcastAddr: lis.Addr().String(),
+		Log:           l,
+	}, app{Manager: h}, node.WithDialOptions(grpc.WithInsecure()))
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	n.Register(srv)
+	h.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return n, h
+}
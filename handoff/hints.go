@@ -0,0 +1,359 @@
+package handoff
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/handoffpb"
+	"github.com/rfratto/croissant/node"
+)
+
+// HintStore queues records for peers which were unavailable when the
+// records were written to them. Implementations should persist hints so
+// they survive restarts.
+type HintStore interface {
+	// AddHints appends records to the queue of peer.
+	AddHints(ctx context.Context, peer node.Peer, records []Record) error
+
+	// Hints returns up to max of the oldest records queued for peer.
+	Hints(ctx context.Context, peer node.Peer, max int) ([]Record, error)
+
+	// RemoveHints removes the n oldest records queued for peer.
+	RemoveHints(ctx context.Context, peer node.Peer, n int) error
+
+	// HintedPeers returns the peers with queued records.
+	HintedPeers(ctx context.Context) ([]node.Peer, error)
+}
+
+// Replicate stores records on the first n peers, which should be ordered
+// by preference, such as the peers returned by Node.ReplicaPeers.
+//
+// If one of the first n peers is unavailable, records are delivered to the
+// next remaining peer instead along with a hint naming the unavailable
+// peer. The hinted peer queues the records in its Config.Hints and replays
+// them once the unavailable peer recovers. If no other peer accepts the
+// hint, it's queued in the local node's Config.Hints.
+//
+// An error is returned if records couldn't be stored or queued for every
+// one of the first n peers.
+func (h *Manager) Replicate(ctx context.Context, records []Record, peers []node.Peer, n int) error {
+	if n > len(peers) {
+		n = len(peers)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, n)
+	)
+	for i, p := range peers[:n] {
+		wg.Add(1)
+		go func(i int, p node.Peer) {
+			defer wg.Done()
+			errs[i] = h.deliver(ctx, p, nil, records)
+		}(i, p)
+	}
+	wg.Wait()
+
+	fallbacks := peers[n:]
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		failed := peers[i]
+		level.Warn(h.cfg.Log).Log("msg", "failed to deliver records to replica, sending hint", "peer", failed.Addr, "err", err)
+
+		hinted := false
+		for len(fallbacks) > 0 && !hinted {
+			fb := fallbacks[0]
+			fallbacks = fallbacks[1:]
+
+			if err := h.deliver(ctx, fb, &failed, records); err != nil {
+				level.Warn(h.cfg.Log).Log("msg", "failed to deliver hint", "peer", fb.Addr, "hint", failed.Addr, "err", err)
+				continue
+			}
+			hinted = true
+		}
+		if hinted {
+			continue
+		}
+
+		if h.cfg.Hints == nil {
+			return fmt.Errorf("failed to deliver records to %s: %w", failed.Addr, err)
+		}
+		if err := h.cfg.Hints.AddHints(ctx, failed, records); err != nil {
+			return fmt.Errorf("failed to queue hint for %s: %w", failed.Addr, err)
+		}
+	}
+	return nil
+}
+
+// deliver sends records to peer. If hint is set, peer queues the records
+// for hint instead of storing them.
+func (h *Manager) deliver(ctx context.Context, peer node.Peer, hint *node.Peer, records []Record) error {
+	cc, err := h.pool.Get(peer.Addr)
+	if err != nil {
+		return err
+	}
+
+	req := &handoffpb.DeliverRequest{Records: make([]*handoffpb.Record, len(records))}
+	for i, rec := range records {
+		req.Records[i] = &handoffpb.Record{
+			Key:  &handoffpb.ID{High: rec.Key.High, Low: rec.Key.Low},
+			Data: rec.Data,
+		}
+	}
+	if hint != nil {
+		req.Hint = &handoffpb.Peer{
+			Id:   &handoffpb.ID{High: hint.ID.High, Low: hint.ID.Low},
+			Addr: hint.Addr,
+		}
+	}
+
+	_, err = handoffpb.NewHandoffClient(cc).Deliver(ctx, req)
+	return err
+}
+
+// ReplayHints delivers the records queued in Config.Hints to their peers.
+// Records are removed from the queue once delivered. Peers which are still
+// unavailable are skipped, and the first error is returned once every peer
+// was tried.
+func (h *Manager) ReplayHints(ctx context.Context) error {
+	if h.cfg.Hints == nil {
+		return nil
+	}
+
+	peers, err := h.cfg.Hints.HintedPeers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, p := range peers {
+		if err := h.replayHints(ctx, p); err != nil {
+			level.Debug(h.cfg.Log).Log("msg", "failed to replay hints", "peer", p.Addr, "err", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to replay hints to %s: %w", p.Addr, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// replayHints delivers the records queued for peer in batches.
+func (h *Manager) replayHints(ctx context.Context, peer node.Peer) error {
+	for {
+		records, err := h.cfg.Hints.Hints(ctx, peer, h.cfg.BatchSize)
+		if err != nil {
+			return err
+		} else if len(records) == 0 {
+			return nil
+		}
+
+		if err := h.deliver(ctx, peer, nil, records); err != nil {
+			return err
+		}
+		if err := h.cfg.Hints.RemoveHints(ctx, peer, len(records)); err != nil {
+			return err
+		}
+		level.Debug(h.cfg.Log).Log("msg", "replayed hints", "peer", peer.Addr, "count", len(records))
+	}
+}
+
+// replayLoop periodically replays hints until Close is called.
+func (h *Manager) replayLoop() {
+	defer h.wg.Done()
+
+	t := time.NewTicker(h.cfg.HintReplayInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-t.C:
+			_ = h.ReplayHints(h.ctx)
+		}
+	}
+}
+
+// FileHintStore is a HintStore which keeps the queue of each peer in a file
+// in a directory. Queues are rewritten whenever they change, so
+// FileHintStore is only suitable for small numbers of hints.
+type FileHintStore struct {
+	dir string
+	mut sync.Mutex
+}
+
+var _ HintStore = (*FileHintStore)(nil)
+
+// hintFileExt is the extension of files written by FileHintStore.
+const hintFileExt = ".hints"
+
+// hintFile is the contents of a file written by FileHintStore.
+type hintFile struct {
+	// PeerID is the base-10 ID of the peer.
+	PeerID   string       `json:"peer_id"`
+	PeerAddr string       `json:"peer_addr"`
+	Records  []hintRecord `json:"records"`
+}
+
+type hintRecord struct {
+	// Key is the base-10 key of the record.
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+}
+
+// NewFileHintStore creates a FileHintStore storing hints in dir. dir is
+// created if it doesn't exist.
+func NewFileHintStore(dir string) (*FileHintStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileHintStore{dir: dir}, nil
+}
+
+// path returns the path of the file holding the queue for addr.
+func (s *FileHintStore) path(addr string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(addr))+hintFileExt)
+}
+
+// read reads the hint file at path. Returns an empty file if path doesn't
+// exist.
+func (s *FileHintStore) read(path string) (*hintFile, error) {
+	bb, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &hintFile{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var hf hintFile
+	if err := json.Unmarshal(bb, &hf); err != nil {
+		return nil, fmt.Errorf("invalid hint file %s: %w", path, err)
+	}
+	return &hf, nil
+}
+
+// write writes hf to path, removing path if hf holds no records.
+func (s *FileHintStore) write(path string, hf *hintFile) error {
+	if len(hf.Records) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	bb, err := json.Marshal(hf)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash doesn't leave a partially
+	// written file behind.
+	if err := ioutil.WriteFile(path+".tmp", bb, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// AddHints implements HintStore.
+func (s *FileHintStore) AddHints(_ context.Context, peer node.Peer, records []Record) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	path := s.path(peer.Addr)
+	hf, err := s.read(path)
+	if err != nil {
+		return err
+	}
+
+	hf.PeerID, hf.PeerAddr = peer.ID.String(), peer.Addr
+	for _, rec := range records {
+		hf.Records = append(hf.Records, hintRecord{Key: rec.Key.String(), Data: rec.Data})
+	}
+	return s.write(path, hf)
+}
+
+// Hints implements HintStore.
+func (s *FileHintStore) Hints(_ context.Context, peer node.Peer, max int) ([]Record, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	hf, err := s.read(s.path(peer.Addr))
+	if err != nil {
+		return nil, err
+	}
+	if max > len(hf.Records) {
+		max = len(hf.Records)
+	}
+
+	res := make([]Record, 0, max)
+	for _, hr := range hf.Records[:max] {
+		key, err := id.Parse(hr.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in hint file: %w", err)
+		}
+		res = append(res, Record{Key: key, Data: hr.Data})
+	}
+	return res, nil
+}
+
+// RemoveHints implements HintStore.
+func (s *FileHintStore) RemoveHints(_ context.Context, peer node.Peer, n int) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	path := s.path(peer.Addr)
+	hf, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	if n > len(hf.Records) {
+		n = len(hf.Records)
+	}
+	hf.Records = hf.Records[n:]
+	return s.write(path, hf)
+}
+
+// HintedPeers implements HintStore.
+func (s *FileHintStore) HintedPeers(_ context.Context) ([]node.Peer, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	fis, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []node.Peer
+	for _, fi := range fis {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), hintFileExt) {
+			continue
+		}
+		hf, err := s.read(filepath.Join(s.dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		} else if len(hf.Records) == 0 {
+			continue
+		}
+
+		peerID, err := id.Parse(hf.PeerID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID in hint file: %w", err)
+		}
+		res = append(res, node.Peer{ID: peerID, Addr: hf.PeerAddr})
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package handoff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHintStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s, err := NewFileHintStore(dir)
+	require.NoError(t, err)
+
+	var (
+		peerA = node.Peer{ID: id.ID{Low: 1}, Addr: "127.0.0.1:1"}
+		peerB = node.Peer{ID: id.ID{High: 1, Low: 2}, Addr: "127.0.0.1:2"}
+	)
+	require.NoError(t, s.AddHints(ctx, peerA, []Record{{Key: id.ID{Low: 1}, Data: []byte("1")}}))
+	require.NoError(t, s.AddHints(ctx, peerA, []Record{{Key: id.ID{Low: 2}, Data: []byte("2")}}))
+	require.NoError(t, s.AddHints(ctx, peerB, []Record{{Key: id.ID{Low: 3}, Data: []byte("3")}}))
+
+	// Hints should survive reopening the store.
+	s, err = NewFileHintStore(dir)
+	require.NoError(t, err)
+
+	peers, err := s.HintedPeers(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []node.Peer{peerA, peerB}, peers)
+
+	recs, err := s.Hints(ctx, peerA, 1)
+	require.NoError(t, err)
+	require.Equal(t, []Record{{Key: id.ID{Low: 1}, Data: []byte("1")}}, recs)
+
+	require.NoError(t, s.RemoveHints(ctx, peerA, 1))
+	recs, err = s.Hints(ctx, peerA, 10)
+	require.NoError(t, err)
+	require.Equal(t, []Record{{Key: id.ID{Low: 2}, Data: []byte("2")}}, recs)
+
+	require.NoError(t, s.RemoveHints(ctx, peerA, 10))
+	peers, err = s.HintedPeers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []node.Peer{peerB}, peers)
+}
@@ -0,0 +1,151 @@
+package handoff
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/handoffpb"
+	"github.com/rfratto/croissant/node"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// server implements handoffpb.HandoffServer for a Manager.
+type server struct {
+	handoffpb.UnimplementedHandoffServer
+	h *Manager
+}
+
+func (s *server) Transfer(stream handoffpb.Handoff_TransferServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := req.GetStart()
+	if start == nil || start.Id == "" {
+		return status.Error(codes.InvalidArgument, "first request must start a transfer")
+	}
+
+	h := s.h
+	rt, err := h.beginReceive(start.Id)
+	if err != nil {
+		return err
+	}
+	var done bool
+	defer func() { h.endReceive(start.Id, done) }()
+
+	level.Debug(h.cfg.Log).Log("msg", "receiving transfer", "id", start.Id, "resume", rt.acked)
+	if err := stream.Send(&handoffpb.TransferResponse{AckedSeq: rt.acked}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			done = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if req.FirstSeq != rt.acked+1 {
+			return status.Errorf(codes.FailedPrecondition, "expected records starting at %d, got %d", rt.acked+1, req.FirstSeq)
+		}
+
+		records := make([]Record, len(req.Records))
+		for i, rec := range req.Records {
+			records[i] = Record{
+				Key:  id.ID{High: rec.GetKey().GetHigh(), Low: rec.GetKey().GetLow()},
+				Data: rec.Data,
+			}
+		}
+		if err := h.store.Store(stream.Context(), records); err != nil {
+			return err
+		}
+
+		h.mut.Lock()
+		rt.acked += uint64(len(records))
+		rt.updated = time.Now()
+		acked := rt.acked
+		h.mut.Unlock()
+
+		if err := stream.Send(&handoffpb.TransferResponse{AckedSeq: acked}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) Deliver(ctx context.Context, req *handoffpb.DeliverRequest) (*handoffpb.DeliverResponse, error) {
+	records := make([]Record, len(req.Records))
+	for i, rec := range req.Records {
+		records[i] = Record{
+			Key:  id.ID{High: rec.GetKey().GetHigh(), Low: rec.GetKey().GetLow()},
+			Data: rec.Data,
+		}
+	}
+
+	h := s.h
+	if req.Hint == nil {
+		if err := h.store.Store(ctx, records); err != nil {
+			return nil, err
+		}
+		return &handoffpb.DeliverResponse{}, nil
+	}
+
+	if h.cfg.Hints == nil {
+		return nil, status.Error(codes.FailedPrecondition, "node does not accept hints")
+	}
+	hint := node.Peer{
+		ID:   id.ID{High: req.Hint.GetId().GetHigh(), Low: req.Hint.GetId().GetLow()},
+		Addr: req.Hint.Addr,
+	}
+	level.Debug(h.cfg.Log).Log("msg", "queueing hinted records", "peer", hint.Addr, "count", len(records))
+	if err := h.cfg.Hints.AddHints(ctx, hint, records); err != nil {
+		return nil, err
+	}
+	return &handoffpb.DeliverResponse{}, nil
+}
+
+// beginReceive starts receiving the transfer with the given ID, returning
+// how much of it was already received. Transfers which were interrupted for
+// longer than Config.ResumeTimeout are forgotten.
+func (h *Manager) beginReceive(transferID string) (*received, error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	now := time.Now()
+	for otherID, rt := range h.received {
+		if !rt.active && now.Sub(rt.updated) > h.cfg.ResumeTimeout {
+			delete(h.received, otherID)
+		}
+	}
+
+	rt, ok := h.received[transferID]
+	if !ok {
+		rt = &received{}
+		h.received[transferID] = rt
+	} else if rt.active {
+		return nil, status.Errorf(codes.Aborted, "transfer %s is already being received", transferID)
+	}
+	rt.active = true
+	rt.updated = now
+	return rt, nil
+}
+
+// endReceive stops receiving the transfer with the given ID. Completed
+// transfers are forgotten.
+func (h *Manager) endReceive(transferID string, done bool) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if done {
+		delete(h.received, transferID)
+		return
+	}
+	if rt, ok := h.received[transferID]; ok {
+		rt.active = false
+	}
+}
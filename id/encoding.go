@@ -0,0 +1,95 @@
+package id
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+)
+
+// binarySize is the size of an ID encoded by MarshalBinary.
+const binarySize = 16
+
+// MarshalText implements encoding.TextMarshaler. IDs are encoded in base
+// 10, the same as String.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. text is parsed with
+// Parse.
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. IDs are encoded as base 10
+// strings, since JSON numbers can't precisely hold 128-bit values.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both strings and numbers are
+// accepted. null leaves id unchanged.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	} else if bytes.ContainsAny(data, `"`) {
+		return fmt.Errorf("invalid id %s", data)
+	}
+	return id.UnmarshalText(data)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. IDs are encoded as 16
+// big-endian bytes, so encoded IDs sort in the same order as Compare.
+func (id ID) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, binarySize)
+	binary.BigEndian.PutUint64(buf[0:8], id.High)
+	binary.BigEndian.PutUint64(buf[8:16], id.Low)
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (id *ID) UnmarshalBinary(data []byte) error {
+	if len(data) != binarySize {
+		return fmt.Errorf("invalid binary id length %d, expected %d", len(data), binarySize)
+	}
+	id.High = binary.BigEndian.Uint64(data[0:8])
+	id.Low = binary.BigEndian.Uint64(data[8:16])
+	return nil
+}
+
+// Value implements driver.Valuer. IDs are stored as base 10 strings, since
+// SQL integer types can't hold 128-bit values.
+func (id ID) Value() (driver.Value, error) {
+	return id.String(), nil
+}
+
+// Scan implements sql.Scanner. src may be a base 10 string, either as a
+// string or []byte, or a non-negative integer. NULL is scanned as Zero.
+func (id *ID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*id = Zero
+		return nil
+	case string:
+		return id.UnmarshalText([]byte(v))
+	case []byte:
+		return id.UnmarshalText(v)
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("invalid negative id %d", v)
+		}
+		*id = ID{Low: uint64(v)}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %T for id", src)
+	}
+}
@@ -0,0 +1,79 @@
+package id
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestID_JSON(t *testing.T) {
+	type wrapper struct {
+		ID ID `json:"id"`
+	}
+
+	for _, n := range []ID{Zero, {Low: 101010}, {High: 0xABCDEF, Low: 0xFFFF}, Max} {
+		bb, err := json.Marshal(wrapper{ID: n})
+		require.NoError(t, err)
+		require.Equal(t, `{"id":"`+n.String()+`"}`, string(bb))
+
+		var res wrapper
+		require.NoError(t, json.Unmarshal(bb, &res))
+		require.Equal(t, n, res.ID)
+	}
+
+	var res wrapper
+	require.NoError(t, json.Unmarshal([]byte(`{"id":12345}`), &res), "numbers should be accepted")
+	require.Equal(t, ID{Low: 12345}, res.ID)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"id":null}`), &res))
+	require.Equal(t, ID{Low: 12345}, res.ID, "null should leave the ID unchanged")
+
+	require.Error(t, json.Unmarshal([]byte(`{"id":"12a"}`), &res))
+	require.Error(t, json.Unmarshal([]byte(`{"id":"340282366920938463463374607431768211456"}`), &res))
+}
+
+func TestID_Binary(t *testing.T) {
+	var prev []byte
+	for _, n := range []ID{Zero, {Low: 101010}, {High: 0xABCDEF, Low: 0xFFFF}, Max} {
+		bb, err := n.MarshalBinary()
+		require.NoError(t, err)
+		require.Len(t, bb, 16)
+		require.True(t, bytes.Compare(prev, bb) < 0, "encoded IDs should sort like IDs")
+		prev = bb
+
+		var res ID
+		require.NoError(t, res.UnmarshalBinary(bb))
+		require.Equal(t, n, res)
+	}
+
+	var res ID
+	require.Error(t, res.UnmarshalBinary([]byte{1, 2, 3}))
+}
+
+func TestID_SQL(t *testing.T) {
+	n := ID{High: 0xABCDEF, Low: 0xFFFF}
+	v, err := n.Value()
+	require.NoError(t, err)
+	require.Equal(t, n.String(), v)
+
+	tt := []struct {
+		src    interface{}
+		expect ID
+	}{
+		{src: v, expect: n},
+		{src: []byte(n.String()), expect: n},
+		{src: int64(42), expect: ID{Low: 42}},
+		{src: nil, expect: Zero},
+	}
+	for _, tc := range tt {
+		res := Max
+		require.NoError(t, res.Scan(tc.src))
+		require.Equal(t, tc.expect, res)
+	}
+
+	var res ID
+	require.Error(t, res.Scan(int64(-1)))
+	require.Error(t, res.Scan(1.5))
+}
@@ -14,127 +14,109 @@ type Generator interface {
 }
 
 // NewGenerator returns an ID generator where IDs will be generated from a hash
-// of size (must be one of 8, 16, 32, 64, 128).
+// of size (must be one of 8, 16, 32, 64, 128). IDs are generated from an md5
+// hash of the input.
 func NewGenerator(size int) Generator {
-	switch size {
-	case 8:
-		var g gen8
-		g.max = MaxForSize(size).Low
-		g.p.New = func() interface{} { return md5.New() }
-		return &g
-	case 16:
-		var g gen16
-		g.max = MaxForSize(size).Low
-		g.p.New = func() interface{} { return md5.New() }
-		return &g
-	case 32:
-		var g gen32
-		g.max = MaxForSize(size).Low
-		g.p.New = func() interface{} { return md5.New() }
-		return &g
-	case 64:
-		var g gen64
-		g.p.New = func() interface{} { return md5.New() }
-		return &g
-	case 128:
-		var g gen128
-		g.p.New = func() interface{} { return md5.New() }
-		return &g
-	default:
-		panic("invalid size")
-	}
+	return NewHashGenerator(size, md5.New)
 }
 
-type gen8 struct {
-	max uint64
-	p   sync.Pool
-}
-
-func (g *gen8) Get(s string) ID {
-	h := g.p.New().(hash.Hash)
-	defer g.p.Put(h)
-
-	h.Reset()
-	fmt.Fprint(h, s)
-
-	sum := h.Sum(nil)
-	var (
-		low  = binary.BigEndian.Uint64(sum[8:])
-		high = binary.BigEndian.Uint64(sum[:8])
-	)
-	return ID{Low: (high ^ low) % g.max}
+// BaseGenerator is a Generator for a cluster that uses IDs of a specific
+// size and routes them with a specific base.
+type BaseGenerator struct {
+	Generator
+	size, base int
 }
 
-type gen16 struct {
-	max uint64
-	p   sync.Pool
+// NewBaseGenerator returns an ID generator for IDs of the given size and
+// base, validating that the size and base are usable for routing. The base
+// does not change which IDs are generated, but is carried by the generator
+// so that clients can build IDs and digits matching the nodes in a cluster
+// from a single (size, base) pair.
+func NewBaseGenerator(size, base int) *BaseGenerator {
+	if base < 2 || base > 16 || !powerOfTwo(base) {
+		panic("invalid base")
+	}
+	return &BaseGenerator{
+		Generator: NewGenerator(size),
+		size:      size,
+		base:      base,
+	}
 }
 
-func (g *gen16) Get(s string) ID {
-	h := g.p.New().(hash.Hash)
-	defer g.p.Put(h)
-
-	h.Reset()
-	fmt.Fprint(h, s)
+// Size returns the bit length of generated IDs.
+func (g *BaseGenerator) Size() int { return g.size }
 
-	sum := h.Sum(nil)
-	var (
-		low  = binary.BigEndian.Uint64(sum[8:])
-		high = binary.BigEndian.Uint64(sum[:8])
-	)
-	return ID{Low: (high ^ low) % g.max}
-}
+// Base returns the base used for routing generated IDs.
+func (g *BaseGenerator) Base() int { return g.base }
 
-type gen32 struct {
-	max uint64
-	p   sync.Pool
+// Digits returns the digits of the ID generated for s, in the generator's
+// base. Digits are the path taken when routing the ID.
+func (g *BaseGenerator) Digits(s string) Digits {
+	return g.Get(s).Digits(g.size, g.base)
 }
 
-func (g *gen32) Get(s string) ID {
-	h := g.p.New().(hash.Hash)
-	defer g.p.Put(h)
+// NewHashGenerator returns an ID generator where IDs of the given size (must
+// be one of 8, 16, 32, 64, 128) are generated from the hash returned by
+// newHash.
+//
+// Hashes that produce more than 128 bits will have their entire output
+// folded into the ID, allowing a wider hash (e.g., sha512) to be used to
+// reduce the risk of collisions for 128-bit IDs. Hashes must produce at least
+// 128 bits.
+func NewHashGenerator(size int, newHash func() hash.Hash) Generator {
+	switch size {
+	case 8, 16, 32, 64, 128:
+	default:
+		panic("invalid size")
+	}
 
-	h.Reset()
-	fmt.Fprint(h, s)
+	if newHash().Size() < 16 {
+		panic("hash must produce at least 128 bits")
+	}
 
-	sum := h.Sum(nil)
-	var (
-		low  = binary.BigEndian.Uint64(sum[8:])
-		high = binary.BigEndian.Uint64(sum[:8])
-	)
-	return ID{Low: (high ^ low) % g.max}
+	g := &hashGenerator{
+		size: size,
+		max:  MaxForSize(size).Low,
+	}
+	g.p.New = func() interface{} { return newHash() }
+	return g
 }
 
-type gen64 struct{ p sync.Pool }
+type hashGenerator struct {
+	size int
+	max  uint64
+	p    sync.Pool
+}
 
-func (g *gen64) Get(s string) ID {
-	h := g.p.New().(hash.Hash)
+func (g *hashGenerator) Get(s string) ID {
+	h := g.p.Get().(hash.Hash)
 	defer g.p.Put(h)
 
 	h.Reset()
 	fmt.Fprint(h, s)
 
-	sum := h.Sum(nil)
 	var (
-		low  = binary.BigEndian.Uint64(sum[8:])
-		high = binary.BigEndian.Uint64(sum[:8])
+		sum       = h.Sum(nil)
+		high, low uint64
 	)
-	return ID{Low: high ^ low}
-}
-
-type gen128 struct{ p sync.Pool }
 
-func (g *gen128) Get(s string) ID {
-	h := g.p.New().(hash.Hash)
-	defer g.p.Put(h)
+	// Fold the sum into 128 bits. Any trailing bytes that don't fill a full
+	// 128-bit chunk are padded with zeroes.
+	for len(sum) > 0 {
+		var chunk [16]byte
+		n := copy(chunk[:], sum)
+		sum = sum[n:]
 
-	h.Reset()
-	fmt.Fprint(h, s)
+		high ^= binary.BigEndian.Uint64(chunk[:8])
+		low ^= binary.BigEndian.Uint64(chunk[8:])
+	}
 
-	sum := h.Sum(nil)
-	var (
-		low  = binary.BigEndian.Uint64(sum[8:])
-		high = binary.BigEndian.Uint64(sum[:8])
-	)
-	return ID{High: high, Low: low}
+	switch g.size {
+	case 128:
+		return ID{High: high, Low: low}
+	case 64:
+		return ID{Low: high ^ low}
+	default:
+		return ID{Low: (high ^ low) % g.max}
+	}
 }
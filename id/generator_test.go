@@ -1,7 +1,10 @@
 package id
 
 import (
+	"crypto/sha512"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -61,3 +64,33 @@ func TestGenerator(t *testing.T) {
 		})
 	}
 }
+
+func TestHashGenerator(t *testing.T) {
+	t.Run("sha512", func(t *testing.T) {
+		id := NewHashGenerator(128, sha512.New).Get("Never gonna say goodbye")
+		assert.Equal(t, "915a07a3beb0b361da33f4eab2055f03", id.Digits(128, 16).String())
+	})
+
+	t.Run("short hash", func(t *testing.T) {
+		require.Panics(t, func() {
+			NewHashGenerator(128, func() hash.Hash { return fnv.New64() })
+		})
+	})
+}
+
+func TestBaseGenerator(t *testing.T) {
+	g := NewBaseGenerator(16, 4)
+	require.Equal(t, 16, g.Size())
+	require.Equal(t, 4, g.Base())
+
+	// The base doesn't change which IDs are generated, only their digits.
+	expect := NewGenerator(16).Get("Never gonna run around")
+	require.Equal(t, expect, g.Get("Never gonna run around"))
+	require.Equal(t, expect.Digits(16, 4), g.Digits("Never gonna run around"))
+	require.Len(t, g.Digits("Never gonna run around"), 8)
+
+	require.Panics(t, func() { NewBaseGenerator(16, 3) })
+
+	// Base 1 is a power of two, but has no bits per digit.
+	require.Panics(t, func() { NewBaseGenerator(16, 1) })
+}
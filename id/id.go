@@ -8,6 +8,7 @@ package id
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/rfratto/croissant/internal/idconv"
@@ -44,8 +45,12 @@ func MaxForSize(size int) ID {
 	}
 }
 
-// Parse parses a string into an ID.
+// Parse parses a string into an ID. Strings prefixed with 0x or 0X are
+// parsed as hexadecimal with ParseHex. Otherwise, s is parsed as base 10.
 func Parse(s string) (ID, error) {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return ParseHex(s)
+	}
 	if s == "" || s == "0" {
 		return Zero, nil
 	}
@@ -55,7 +60,7 @@ func Parse(s string) (ID, error) {
 	cutoff := div64(Max, 10)
 
 	for _, c := range []byte(s) {
-		if c <= '0' && c >= '9' {
+		if c < '0' || c > '9' {
 			return Zero, fmt.Errorf("unexpected digit %s", string(c))
 		}
 		dig := uint64(c - '0')
@@ -78,6 +83,50 @@ func Parse(s string) (ID, error) {
 	return res, nil
 }
 
+// ParseHex parses a hexadecimal string into an ID. The 0x or 0X prefix is
+// optional, and digits may be upper or lower case. Leading zeros are
+// allowed, so the base 16 Digits of an ID can be parsed back into the ID.
+func ParseHex(s string) (ID, error) {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	if s == "" {
+		return Zero, fmt.Errorf("empty hex id")
+	}
+
+	s = strings.TrimLeft(s, "0")
+	if len(s) > 32 {
+		return Zero, fmt.Errorf("id overflow")
+	}
+
+	var res ID
+	for _, c := range []byte(s) {
+		var dig byte
+		switch {
+		case c >= '0' && c <= '9':
+			dig = c - '0'
+		case c >= 'a' && c <= 'f':
+			dig = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			dig = c - 'A' + 10
+		default:
+			return Zero, fmt.Errorf("unexpected digit %s", string(c))
+		}
+		res.High = res.High<<4 | res.Low>>60
+		res.Low = res.Low<<4 | uint64(dig)
+	}
+	return res, nil
+}
+
+// FormatHex returns the lowercase hexadecimal representation of id, without
+// a prefix or leading zeros. Use ParseHex to convert it back into an ID.
+func FormatHex(id ID) string {
+	if id.High == 0 {
+		return strconv.FormatUint(id.Low, 16)
+	}
+	return fmt.Sprintf("%x%016x", id.High, id.Low)
+}
+
 // ID is an unsigned 128-bit number used to identify nodes and assign ownership
 // to resources.
 type ID struct {
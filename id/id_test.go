@@ -42,6 +42,59 @@ func TestID_String_Parse_Many(t *testing.T) {
 	}
 }
 
+func TestID_Hex(t *testing.T) {
+	tt := []struct {
+		id     ID
+		expect string
+	}{
+		{id: Zero, expect: "0"},
+		{id: ID{Low: 0xabcdef}, expect: "abcdef"},
+		{id: ID{High: 0xABCDEF, Low: 0xFFFF}, expect: "abcdef000000000000ffff"},
+		{id: Max, expect: "ffffffffffffffffffffffffffffffff"},
+	}
+	for _, tc := range tt {
+		require.Equal(t, tc.expect, FormatHex(tc.id))
+
+		parsed, err := ParseHex(tc.expect)
+		require.NoError(t, err)
+		require.Equal(t, tc.id, parsed)
+
+		parsed, err = Parse("0x" + tc.expect)
+		require.NoError(t, err, "Parse should detect the 0x prefix")
+		require.Equal(t, tc.id, parsed)
+	}
+
+	// Digits in base 16 should round-trip, including leading zeros.
+	n := ID{Low: 0x00c0ffee}
+	parsed, err := ParseHex(n.Digits(32, 16).String())
+	require.NoError(t, err)
+	require.Equal(t, n, parsed)
+
+	parsed, err = ParseHex("0XC0FFEE")
+	require.NoError(t, err)
+	require.Equal(t, n, parsed)
+
+	for _, invalid := range []string{"", "0x", "0xg", "1ffffffffffffffffffffffffffffffff", "-1"} {
+		_, err := ParseHex(invalid)
+		require.Error(t, err, "expected %q to be invalid", invalid)
+	}
+}
+
+func TestID_Hex_Many(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	for i := 0; i < 100_000; i++ {
+		id := ID{High: r.Uint64(), Low: r.Uint64()}
+		if r.Int()%7 == 0 {
+			id.High = 0
+		}
+
+		parsed, err := ParseHex(FormatHex(id))
+		require.NoError(t, err)
+		require.Equal(t, id, parsed)
+	}
+}
+
 func TestID_Digits(t *testing.T) {
 	tt := []struct {
 		id     ID
@@ -5,14 +5,17 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/rfratto/croissant/id"
 )
 
 // Node is a node in the cluster.
 type Node interface {
-	// Join informs a node that joiner wishes to join the cluster. Joins will
-	// be propagated and routed through the cluster based on the joiner ID.
-	// Each peer along the routing path will send a NodeHello to the joiner.
-	Join(ctx context.Context, joiner Descriptor) error
+	// Join informs a node that j.Joiner wishes to join the cluster. Joins
+	// will be propagated and routed through the cluster based on the joiner
+	// ID. Each peer along the routing path will send a NodeHello to the
+	// joiner.
+	Join(ctx context.Context, j Join) error
 
 	// NodeHello is used for nodes to share state. If h.StateAck is
 	// set and the state has changed, NodeHello should reply with an
@@ -24,6 +27,131 @@ type Node interface {
 
 	// GetState gets the current state of a node.
 	GetState(ctx context.Context) (*State, error)
+
+	// Ping checks that a node is reachable. If p.Target is set to another
+	// node, the node pings Target instead and fails if Target is
+	// unreachable. Returns recent changes to the health of peers known by the
+	// node.
+	Ping(ctx context.Context, p Ping) (map[Descriptor]Health, error)
+
+	// Broadcast invokes b.Method on the node and forwards b to every node in
+	// the node's subtree of the broadcast tree, returning the result from
+	// each node.
+	Broadcast(ctx context.Context, b Broadcast) ([]BroadcastResult, error)
+
+	// TraceRoute forwards a trace to the node's next hop for key until the
+	// node closest to key is reached, returning every hop starting with the
+	// node. Fails if the route is longer than maxHops.
+	TraceRoute(ctx context.Context, key id.ID, maxHops int) ([]TraceHop, error)
+
+	// RingChecksum returns the node's view of its neighborhood in the ring.
+	RingChecksum(ctx context.Context) (RingView, error)
+
+	// Evict informs a node that evictee was forcibly removed from the
+	// cluster. The node treats evictee as dead.
+	Evict(ctx context.Context, evictee Descriptor) error
+}
+
+// Gossiper is implemented by Nodes that can open gossip streams.
+type Gossiper interface {
+	// Gossip opens a long-lived stream for sending Hellos to the node.
+	// Canceling ctx breaks the stream.
+	Gossip(ctx context.Context) (GossipStream, error)
+}
+
+// GossipStream is a long-lived stream for sending Hellos to a node. Calls
+// are sent one at a time.
+type GossipStream interface {
+	// NodeHello sends h over the stream. Returns the same errors as
+	// Node.NodeHello. Fails if the stream broke.
+	NodeHello(ctx context.Context, h Hello) error
+
+	// Heartbeat checks that the node is still alive. Fails if the stream
+	// broke.
+	Heartbeat(ctx context.Context) error
+
+	// Done returns a channel which is closed once the stream stops, either
+	// because it broke or because it was closed by Close.
+	Done() <-chan struct{}
+
+	// Err returns why the stream broke once Done is closed. Returns nil if
+	// the stream was closed by Close.
+	Err() error
+
+	// Close closes the stream, informing the node that the stream was closed
+	// on purpose.
+	Close() error
+}
+
+// GossipWatcher may be implemented by a Node to be informed when a gossip
+// stream opened by initiator broke without initiator closing it, which
+// usually means that initiator failed. ctx is the context of the stream,
+// which may already be canceled.
+type GossipWatcher interface {
+	GossipBroken(ctx context.Context, initiator Descriptor, err error)
+}
+
+// TraceHop is a hop in the route of a key.
+type TraceHop struct {
+	Node Descriptor
+
+	// RTT is the round-trip time of the trace sent to Node by the previous
+	// hop, including the time taken to trace the rest of the route. Zero for
+	// the first hop.
+	RTT time.Duration
+}
+
+// Broadcast is a request to invoke a method on every node in the cluster.
+type Broadcast struct {
+	// Method is the full name of the gRPC method to invoke.
+	Method string
+
+	// Payload is the encoded request message for Method.
+	Payload []byte
+
+	// Level is the first row of the routing table the broadcast is
+	// forwarded to.
+	Level int
+}
+
+// BroadcastResult is the result of invoking a Broadcast on a single node.
+type BroadcastResult struct {
+	// Node that produced the result.
+	Node Descriptor
+
+	// Reply is the encoded response message from Node. Only set if Err is
+	// nil.
+	Reply []byte
+
+	// Err is the error returned by Node.
+	Err error
+}
+
+// Join is a request to join the cluster.
+type Join struct {
+	// Joiner is the node that is trying to join.
+	Joiner Descriptor
+
+	// Cluster is the name of the cluster Joiner is trying to join.
+	Cluster string
+
+	// ProtocolVersion is the protocol version of Joiner. 0 if Joiner
+	// predates protocol versions.
+	ProtocolVersion uint32
+
+	// Path holds the nodes the join was propagated through, in order.
+	Path []Descriptor
+}
+
+// Ping is a message used to check that a node is reachable.
+type Ping struct {
+	// Target is the node to ping on behalf of the sender. nil if the
+	// receiver is being pinged.
+	Target *Descriptor
+
+	// Health holds recent changes to the health of peers known by the
+	// sender.
+	Health map[Descriptor]Health
 }
 
 // Hello is a state sharing message.
@@ -37,15 +165,37 @@ type Hello struct {
 	// State of the initiator.
 	State *State
 
+	// Delta may be set instead of State when the receiver is known to have a
+	// previous version of the initiator's State. Receivers that don't know
+	// about Delta.BaseVersion should fail with ErrUnknownBase.
+	Delta *StateDelta
+
 	// StateAck is used to verify the state for the initiator of a previous Hello
-	// hasn't changed. Set to the value of State.LastUpdated from a previous Hello.
-	StateAck time.Time
+	// hasn't changed. Set to the value of State.Version from a previous Hello.
+	// 0 indicates that the Hello is not an acknowledgement.
+	StateAck uint64
+
+	// AcceptDelta is set when the initiator can accept an ErrStateChanged
+	// that only holds a Delta.
+	AcceptDelta bool
+
+	// Cluster is the name of the cluster of the initiator.
+	Cluster string
+
+	// ProtocolVersion is the protocol version of the initiator. 0 if the
+	// initiator predates protocol versions.
+	ProtocolVersion uint32
 }
 
 // ErrStateChanged is the error of a Hello if a node's state has changed since
 // StateAck.
 type ErrStateChanged struct {
+	// NewState is the current state of the node. May be nil if Delta is set.
 	NewState *State
+
+	// Delta holds the changes to the state of the node since StateAck. Only
+	// set if the Hello had AcceptDelta set.
+	Delta *StateDelta
 }
 
 // Error returns the error string.
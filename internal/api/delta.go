@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownBase is returned when a StateDelta can't be applied because the
+// State it is based on is not known.
+var ErrUnknownBase = errors.New("unknown base state for delta")
+
+// StateDelta holds the changes between two versions of a node's State.
+// Sending a StateDelta instead of a full State allows peers that already
+// know a previous version of the State to only receive what changed.
+type StateDelta struct {
+	// Node is the node the State belongs to.
+	Node Descriptor
+
+	// BaseVersion is the Version of the State the delta must be applied to,
+	// and Version is the Version of the State after applying the delta.
+	BaseVersion, Version uint64
+
+	// Leaves and neighbors are always small, so they are always included in
+	// full.
+	Predecessors, Successors, Neighbors []Descriptor
+
+	// Routes holds routing table entries that changed, keyed by
+	// row*Base+col. Entries that were removed are nil.
+	Routes map[int]*Descriptor
+
+	// Statuses holds the health of peers that changed, and Untracked holds
+	// peers whose health is no longer tracked.
+	Statuses  map[Descriptor]Health
+	Untracked []Descriptor
+}
+
+// NewStateDelta returns the changes needed to turn base into s. base and s
+// must be two versions of the State for the same node.
+func NewStateDelta(base, s *State) *StateDelta {
+	// Clone both states so we don't have to hold both locks at once.
+	base, s = base.Clone(), s.Clone()
+
+	d := &StateDelta{
+		Node:        s.Node,
+		BaseVersion: base.Version,
+		Version:     s.Version,
+
+		Predecessors: append([]Descriptor(nil), s.Predecessors.Descriptors...),
+		Successors:   append([]Descriptor(nil), s.Successors.Descriptors...),
+		Neighbors:    append([]Descriptor(nil), s.Neighbors.Descriptors...),
+
+		Routes:   make(map[int]*Descriptor),
+		Statuses: make(map[Descriptor]Health),
+	}
+
+	for row := range s.Routing {
+		for col, ent := range s.Routing[row] {
+			var old *Descriptor
+			if row < len(base.Routing) && col < len(base.Routing[row]) {
+				old = base.Routing[row][col]
+			}
+
+			switch {
+			case ent == nil && old == nil:
+				continue
+			case ent != nil && old != nil && *ent == *old:
+				continue
+			}
+			d.Routes[row*s.Base+col] = ent
+		}
+	}
+
+	for p, h := range s.Statuses {
+		if old, ok := base.Statuses[p]; !ok || old != h {
+			d.Statuses[p] = h
+		}
+	}
+	for p := range base.Statuses {
+		if _, ok := s.Statuses[p]; !ok {
+			d.Untracked = append(d.Untracked, p)
+		}
+	}
+
+	return d
+}
+
+// Apply returns a new State from applying d to base. Returns an error
+// wrapping ErrUnknownBase if base is not the State d is based on.
+func (d *StateDelta) Apply(base *State) (*State, error) {
+	s := base.Clone()
+	if s.Node != d.Node || s.Version != d.BaseVersion {
+		return nil, fmt.Errorf("%w: have version %d of %s, delta is for version %d of %s", ErrUnknownBase, s.Version, s.Node.Addr, d.BaseVersion, d.Node.Addr)
+	}
+
+	s.Predecessors.Descriptors = append([]Descriptor(nil), d.Predecessors...)
+	s.Successors.Descriptors = append([]Descriptor(nil), d.Successors...)
+	s.Neighbors.Descriptors = append([]Descriptor(nil), d.Neighbors...)
+	growSet(s.Predecessors)
+	growSet(s.Successors)
+	growSet(s.Neighbors)
+
+	for idx, ent := range d.Routes {
+		row, col := idx/s.Base, idx%s.Base
+		if row >= len(s.Routing) || col >= len(s.Routing[row]) {
+			return nil, fmt.Errorf("routing entry %d out of range", idx)
+		}
+		if ent == nil {
+			s.Routing[row][col] = nil
+			continue
+		}
+		cp := *ent
+		s.Routing[row][col] = &cp
+	}
+
+	for p, h := range d.Statuses {
+		s.Statuses[p] = h
+	}
+	for _, p := range d.Untracked {
+		delete(s.Statuses, p)
+	}
+
+	s.Version = d.Version
+	return s, nil
+}
+
+// growSet increases the size of dset to fit its descriptors.
+func growSet(dset *DescriptorSet) {
+	if len(dset.Descriptors) > dset.Size {
+		dset.Size = len(dset.Descriptors)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateDelta(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+	peer := func(val int, leaves ...int) *State {
+		s := NewState(descFrom(val), 4, 4, 8, 4)
+		for _, l := range leaves {
+			s.addLeaf(descFrom(l))
+		}
+		return s
+	}
+
+	s := NewState(descFrom(100), 4, 4, 8, 4)
+	s.MixinState(peer(80, 60, 120))
+	s.SetHealth(descFrom(60), Unhealthy)
+	base := s.Clone()
+
+	s.MixinState(peer(200, 140, 180))
+	s.SetHealth(descFrom(60), Healthy)
+	s.SetHealth(descFrom(250), Dead)
+
+	d := NewStateDelta(base, s)
+	require.Equal(t, base.Version, d.BaseVersion)
+	require.Equal(t, s.Version, d.Version)
+	require.NotEmpty(t, d.Routes)
+	require.Len(t, d.Statuses, 2)
+
+	applied, err := d.Apply(base)
+	require.NoError(t, err)
+
+	expect := s.Clone()
+	require.Equal(t, expect.Routing, applied.Routing)
+	require.Equal(t, expect.Leaves(true), applied.Leaves(true))
+	require.Equal(t, expect.Neighbors.Descriptors, applied.Neighbors.Descriptors)
+	require.Equal(t, expect.Statuses, applied.Statuses)
+	require.Equal(t, expect.Version, applied.Version)
+
+	t.Run("removed entries", func(t *testing.T) {
+		next := applied.Clone()
+		row, col := next.RouteIndex(descFrom(200))
+		require.NotNil(t, next.Routing[row][col])
+		next.Routing[row][col] = nil
+		next.Statuses = map[Descriptor]Health{}
+		next.Version++
+
+		d := NewStateDelta(applied, next)
+		idx := row*next.Base + col
+		require.Contains(t, d.Routes, idx)
+		require.Nil(t, d.Routes[idx])
+		require.Len(t, d.Untracked, 2)
+
+		res, err := d.Apply(applied)
+		require.NoError(t, err)
+		require.Nil(t, res.Routing[row][col])
+		require.Empty(t, res.Statuses)
+	})
+
+	t.Run("unknown base", func(t *testing.T) {
+		_, err := d.Apply(s)
+		require.True(t, errors.Is(err, ErrUnknownBase))
+
+		_, err = d.Apply(peer(80))
+		require.True(t, errors.Is(err, ErrUnknownBase))
+	})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/binary"
 	"sort"
 
 	"github.com/rfratto/croissant/id"
@@ -152,6 +153,26 @@ type Descriptor struct {
 	ID id.ID
 	// Addr for connecting.
 	Addr string
+	// Labels holds metadata about the node, encoded with EncodeLabels. Labels
+	// are stored as a string so Descriptor can be compared and used as a map
+	// key.
+	Labels string
+	// Signature of the descriptor, computed over SignedBytes. Empty if the
+	// descriptor isn't signed. Stored as a string so Descriptor remains
+	// comparable.
+	Signature string
+}
+
+// SignedBytes returns the canonical encoding of the ID, Addr, and Labels of
+// d, which is the message signed by Signature.
+func (d Descriptor) SignedBytes() []byte {
+	buf := make([]byte, 32, 32+len(d.Addr)+len(d.Labels))
+	binary.BigEndian.PutUint64(buf[0:], d.ID.High)
+	binary.BigEndian.PutUint64(buf[8:], d.ID.Low)
+	binary.BigEndian.PutUint64(buf[16:], uint64(len(d.Addr)))
+	binary.BigEndian.PutUint64(buf[24:], uint64(len(d.Labels)))
+	buf = append(buf, d.Addr...)
+	return append(buf, d.Labels...)
 }
 
 // ReverseDescriptors reverses ds.
@@ -290,3 +290,12 @@ func TestDescriptorSet_Remove_Wraparound(t *testing.T) {
 		require.Equal(t, tc.expect, res)
 	}
 }
+
+func TestDescriptor_SignedBytes(t *testing.T) {
+	d := Descriptor{ID: id.ID{Low: 1}, Addr: "a:1", Labels: "b=c"}
+	require.Equal(t, d.SignedBytes(), Descriptor{ID: d.ID, Addr: d.Addr, Labels: d.Labels, Signature: "sig"}.SignedBytes())
+
+	// Fields must not be ambiguous when concatenated.
+	require.NotEqual(t, d.SignedBytes(), Descriptor{ID: d.ID, Addr: "a:1b", Labels: "=c"}.SignedBytes())
+	require.NotEqual(t, d.SignedBytes(), Descriptor{ID: id.ID{Low: 2}, Addr: d.Addr, Labels: d.Labels}.SignedBytes())
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/rfratto/croissant/id"
+)
+
+// DiffStates returns a human-readable diff between the views of the cluster
+// held by a and b. Since States are relative to their own node, each State's
+// own node is considered part of its leaves and routing table: a leaf in b
+// that is a's node is not reported as a difference.
+//
+// Descriptors that are only known to a are prefixed with "-" and descriptors
+// only known to b are prefixed with "+". Peers that both states track but
+// disagree on the health of are prefixed with "~". An empty string is
+// returned when there are no differences.
+func DiffStates(a, b *State) string {
+	// Clone both states so we don't have to hold both locks at once.
+	a, b = a.Clone(), b.Clone()
+
+	var sw strings.Builder
+
+	var (
+		leaves    = diffDescriptors(withSelf(a, a.leaves(true)), withSelf(b, b.leaves(true)))
+		routes    = diffDescriptors(withSelf(a, routingEntries(a)), withSelf(b, routingEntries(b)))
+		neighbors = diffDescriptors(a.Neighbors.Descriptors, b.Neighbors.Descriptors)
+	)
+
+	writeDescriptorDiff(&sw, "Leaves", leaves)
+	writeDescriptorDiff(&sw, "Routing", routes)
+	writeDescriptorDiff(&sw, "Neighbors", neighbors)
+	writeHealthDiff(&sw, a, b)
+
+	if sw.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", formatDescriptor(a.Node), formatDescriptor(b.Node), sw.String())
+}
+
+type descriptorDiff struct {
+	onlyA, onlyB []Descriptor
+}
+
+func (d descriptorDiff) empty() bool {
+	return len(d.onlyA) == 0 && len(d.onlyB) == 0
+}
+
+func diffDescriptors(a, b []Descriptor) descriptorDiff {
+	var (
+		diff descriptorDiff
+
+		aSet = make(map[Descriptor]struct{}, len(a))
+		bSet = make(map[Descriptor]struct{}, len(b))
+	)
+	for _, d := range a {
+		aSet[d] = struct{}{}
+	}
+	for _, d := range b {
+		bSet[d] = struct{}{}
+	}
+
+	for d := range aSet {
+		if _, ok := bSet[d]; !ok {
+			diff.onlyA = append(diff.onlyA, d)
+		}
+	}
+	for d := range bSet {
+		if _, ok := aSet[d]; !ok {
+			diff.onlyB = append(diff.onlyB, d)
+		}
+	}
+
+	sortDescriptors(diff.onlyA)
+	sortDescriptors(diff.onlyB)
+	return diff
+}
+
+func writeDescriptorDiff(w io.Writer, section string, diff descriptorDiff) {
+	if diff.empty() {
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", section)
+	for _, d := range diff.onlyA {
+		fmt.Fprintf(w, "  - %s\n", formatDescriptor(d))
+	}
+	for _, d := range diff.onlyB {
+		fmt.Fprintf(w, "  + %s\n", formatDescriptor(d))
+	}
+}
+
+func writeHealthDiff(w io.Writer, a, b *State) {
+	var lines []string
+
+	seen := make(map[Descriptor]struct{}, len(a.Statuses)+len(b.Statuses))
+	for d := range a.Statuses {
+		seen[d] = struct{}{}
+	}
+	for d := range b.Statuses {
+		seen[d] = struct{}{}
+	}
+
+	all := make([]Descriptor, 0, len(seen))
+	for d := range seen {
+		all = append(all, d)
+	}
+	sortDescriptors(all)
+
+	for _, d := range all {
+		// Untracked peers are implicitly healthy, so comparing the zero value
+		// of a missing entry is intended.
+		aHealth, bHealth := a.Statuses[d], b.Statuses[d]
+		if aHealth == bHealth {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  ~ %s: %s != %s\n", formatDescriptor(d), aHealth, bHealth))
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Health:\n")
+	for _, l := range lines {
+		fmt.Fprint(w, l)
+	}
+}
+
+// withSelf returns ds with s.Node appended.
+func withSelf(s *State, ds []Descriptor) []Descriptor {
+	res := make([]Descriptor, 0, len(ds)+1)
+	res = append(res, ds...)
+	return append(res, s.Node)
+}
+
+// routingEntries returns all non-empty entries in the routing table of s.
+func routingEntries(s *State) []Descriptor {
+	var res []Descriptor
+	for _, row := range s.Routing {
+		for _, ent := range row {
+			if ent != nil {
+				res = append(res, *ent)
+			}
+		}
+	}
+	return res
+}
+
+func sortDescriptors(ds []Descriptor) {
+	sort.Slice(ds, func(i, j int) bool {
+		if cmp := id.Compare(ds[i].ID, ds[j].ID); cmp != 0 {
+			return cmp < 0
+		}
+		return ds[i].Addr < ds[j].Addr
+	})
+}
+
+func formatDescriptor(d Descriptor) string {
+	if d.Addr == "" {
+		return d.ID.String()
+	}
+	return fmt.Sprintf("%s (%s)", d.ID, d.Addr)
+}
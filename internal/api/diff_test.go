@@ -0,0 +1,44 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffStates(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	a := NewState(descFrom(5000), 4, 0, 16, 4)
+	b := NewState(descFrom(6000), 4, 0, 16, 4)
+
+	// a and b know about each other and share the leaf 7000.
+	for _, d := range []Descriptor{descFrom(6000), descFrom(7000)} {
+		a.addLeaf(d)
+		a.addRoute(d)
+	}
+	for _, d := range []Descriptor{descFrom(5000), descFrom(7000)} {
+		b.addLeaf(d)
+		b.addRoute(d)
+	}
+	require.Equal(t, "", DiffStates(a, b), "states with the same view should have no diff")
+
+	// Give each side a leaf the other doesn't have and make them disagree on
+	// the health of the shared leaf.
+	a.addLeaf(descFrom(4000))
+	b.addLeaf(descFrom(8000))
+	b.SetHealth(descFrom(7000), Unhealthy)
+
+	expect := `--- 5000
++++ 6000
+Leaves:
+  - 4000
+  + 8000
+Health:
+  ~ 7000: Healthy != Unhealthy
+`
+	require.Equal(t, expect, DiffStates(a, b))
+}
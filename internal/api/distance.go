@@ -6,6 +6,31 @@ import (
 	"github.com/rfratto/croissant/id"
 )
 
+// Distance calculates the distance between a and b in a ring of IDs that
+// are size bits long, accounting for wraparound.
+func Distance(a, b id.ID, size int) id.ID {
+	return idDistance(a, b, id.MaxForSize(size))
+}
+
+// Closer returns true if a is closer to key than b in a ring of IDs that are
+// size bits long. When a and b are the same distance from key, such as when
+// key is halfway between them, the smaller ID is considered closer. This
+// ensures that every node agrees on which node is closest to key.
+func Closer(a, b, key id.ID, size int) bool {
+	return idCloser(a, b, key, id.MaxForSize(size))
+}
+
+func idCloser(a, b, key id.ID, max id.ID) bool {
+	switch id.Compare(idDistance(a, key, max), idDistance(b, key, max)) {
+	case -1:
+		return true
+	case 0:
+		return id.Compare(a, b) < 0
+	default:
+		return false
+	}
+}
+
 // idDistance calculates the distance of a and b accounting
 // for wraparound using max.
 //
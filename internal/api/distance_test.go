@@ -85,3 +85,33 @@ func Test_idDistanceWrap(t *testing.T) {
 		})
 	}
 }
+
+func TestCloser(t *testing.T) {
+	newID := func(v uint64) id.ID { return id.ID{Low: v} }
+
+	tt := []struct {
+		a, b, key uint64
+		expect    bool
+	}{
+		{a: 10, b: 20, key: 12, expect: true},
+		{a: 20, b: 10, key: 12, expect: false},
+
+		// Closer through wraparound
+		{a: 2, b: 240, key: 255, expect: true},
+		{a: 250, b: 10, key: 0, expect: true},
+
+		// Ties pick the smaller ID, including across wraparound.
+		{a: 10, b: 20, key: 15, expect: true},
+		{a: 20, b: 10, key: 15, expect: false},
+		{a: 6, b: 246, key: 254, expect: true},
+		{a: 246, b: 6, key: 254, expect: false},
+
+		// Nothing is closer than itself.
+		{a: 10, b: 10, key: 10, expect: false},
+	}
+
+	for _, tc := range tt {
+		actual := Closer(newID(tc.a), newID(tc.b), newID(tc.key), 8)
+		require.Equal(t, tc.expect, actual, "a=%d b=%d key=%d", tc.a, tc.b, tc.key)
+	}
+}
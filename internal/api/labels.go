@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// EncodeLabels encodes labels into a canonical string. Equal sets of labels
+// always produce the same string. Returns an empty string if there are no
+// labels.
+func EncodeLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(labels[k]))
+	}
+	return sb.String()
+}
+
+// DecodeLabels decodes labels encoded with EncodeLabels. Returns nil if there
+// are no labels.
+func DecodeLabels(enc string) map[string]string {
+	if enc == "" {
+		return nil
+	}
+
+	values, _ := url.ParseQuery(enc)
+	labels := make(map[string]string, len(values))
+	for k, vv := range values {
+		if len(vv) > 0 {
+			labels[k] = vv[0]
+		}
+	}
+	return labels
+}
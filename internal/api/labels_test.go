@@ -0,0 +1,22 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabels(t *testing.T) {
+	labels := map[string]string{
+		"zone":     "us-east1-b",
+		"capacity": "10",
+		"odd key":  "a=b&c",
+	}
+
+	enc := EncodeLabels(labels)
+	require.Equal(t, "capacity=10&odd+key=a%3Db%26c&zone=us-east1-b", enc)
+	require.Equal(t, labels, DecodeLabels(enc))
+
+	require.Empty(t, EncodeLabels(nil))
+	require.Nil(t, DecodeLabels(""))
+}
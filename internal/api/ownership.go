@@ -0,0 +1,230 @@
+package api
+
+import (
+	"github.com/rfratto/croissant/id"
+)
+
+// Range is an inclusive range of IDs in the ring. If Start is bigger than
+// End, the range wraps around the end of the ring.
+type Range struct {
+	Start, End id.ID
+}
+
+// Contains returns true if key is in r.
+func (r Range) Contains(key id.ID) bool {
+	if id.Compare(r.Start, r.End) > 0 {
+		return id.Compare(r.Start, key) <= 0 || id.Compare(key, r.End) <= 0
+	}
+	return id.Compare(r.Start, key) <= 0 && id.Compare(key, r.End) <= 0
+}
+
+// Ownership describes the range of keys a node is closest to.
+type Ownership struct {
+	// Range of keys owned by the node.
+	Range Range
+
+	// Full is true if the node has no healthy leaves and owns every key.
+	Full bool
+
+	// Predecessor and Successor are the healthy leaves used to determine
+	// Range. Unset if Full is true.
+	Predecessor, Successor Descriptor
+}
+
+// OwnershipOf returns the range of keys that s.Node is closest to, based on
+// its closest healthy leaves.
+func OwnershipOf(s *State) Ownership {
+	node := s.Node.ID
+
+	pred, succ, ok := s.RingNeighbors()
+	if !ok {
+		return Ownership{
+			Range: Range{Start: ringAdd(node, id.ID{Low: 1}, s.Size), End: node},
+			Full:  true,
+		}
+	}
+
+	return Ownership{
+		Range: Range{
+			Start: ownedStart(pred.ID, node, s.Size),
+			End:   ownedEnd(node, succ.ID, s.Size),
+		},
+		Predecessor: pred,
+		Successor:   succ,
+	}
+}
+
+// ownedStart returns the first key after pred that node is closest to.
+func ownedStart(pred, node id.ID, size int) id.ID {
+	gap := ringSub(node, pred, size)
+
+	// Keys past the midpoint of pred and node are closer to node. The
+	// midpoint itself is a tie when gap is even, which goes to the smaller ID.
+	offset := idAdd(halve(gap), id.ID{Low: 1})
+	if gap.Low&1 == 0 && id.Compare(node, pred) < 0 {
+		offset = halve(gap)
+	}
+	return ringAdd(pred, offset, size)
+}
+
+// ownedEnd returns the last key before succ that node is closest to.
+func ownedEnd(node, succ id.ID, size int) id.ID {
+	gap := ringSub(succ, node, size)
+
+	offset := halve(gap)
+	if gap.Low&1 == 0 && id.Compare(succ, node) < 0 {
+		offset = idSub(offset, id.ID{Low: 1})
+	}
+	return ringAdd(node, offset, size)
+}
+
+// HandoffOf returns the ranges of keys owned by s.Node along with the nodes
+// that will own them once s.Node leaves the cluster. Keys closer to the
+// predecessor of s.Node go to the predecessor, and the rest go to the
+// successor. Returns nil if s.Node has no healthy leaves.
+func HandoffOf(s *State) []OwnershipChange {
+	o := OwnershipOf(s)
+	if o.Full {
+		return nil
+	}
+	if o.Predecessor == o.Successor {
+		return []OwnershipChange{{Range: o.Range, Peer: o.Predecessor}}
+	}
+
+	// The predecessor's range will extend up to the midpoint between it and
+	// the successor.
+	split := ownedEnd(o.Predecessor.ID, o.Successor.ID, s.Size)
+	if !o.Range.Contains(split) {
+		// Shouldn't happen since s.Node is between its predecessor and
+		// successor, but fall back to giving everything to the successor.
+		return []OwnershipChange{{Range: o.Range, Peer: o.Successor}}
+	}
+
+	changes := []OwnershipChange{{
+		Range: Range{Start: o.Range.Start, End: split},
+		Peer:  o.Predecessor,
+	}}
+	if split != o.Range.End {
+		changes = append(changes, OwnershipChange{
+			Range: Range{Start: ringAdd(split, id.ID{Low: 1}, s.Size), End: o.Range.End},
+			Peer:  o.Successor,
+		})
+	}
+	return changes
+}
+
+// OwnershipChange is a range of keys a node gained or lost ownership of.
+type OwnershipChange struct {
+	Range Range
+
+	// Gained is true if the node gained Range, and false if it lost Range.
+	Gained bool
+
+	// Peer is the node that previously owned Range if Gained is true, or the
+	// node that now owns Range if Gained is false. Peer is unset if Range
+	// was owned by more than one node.
+	Peer Descriptor
+}
+
+// DiffOwnership returns the ranges of keys that were gained or lost between
+// prev and next, which must be the ownership of the same node in a ring of
+// IDs that are size bits long.
+func DiffOwnership(node id.ID, prev, next Ownership, size int) []OwnershipChange {
+	one := id.ID{Low: 1}
+
+	switch {
+	case prev.Full && next.Full:
+		return nil
+
+	case prev.Full:
+		// Everything outside of next was lost. If the node only has one peer,
+		// that peer owns all of it.
+		var peer Descriptor
+		if next.Predecessor == next.Successor {
+			peer = next.Predecessor
+		}
+		return []OwnershipChange{{
+			Range: Range{
+				Start: ringAdd(next.Range.End, one, size),
+				End:   ringSub(next.Range.Start, one, size),
+			},
+			Peer: peer,
+		}}
+
+	case next.Full:
+		var peer Descriptor
+		if prev.Predecessor == prev.Successor {
+			peer = prev.Predecessor
+		}
+		return []OwnershipChange{{
+			Range: Range{
+				Start: ringAdd(prev.Range.End, one, size),
+				End:   ringSub(prev.Range.Start, one, size),
+			},
+			Gained: true,
+			Peer:   peer,
+		}}
+	}
+
+	var changes []OwnershipChange
+
+	// Compare how far each range extends before and after node to determine
+	// whether the range grew or shrunk on each side.
+	var (
+		prevBefore = ringSub(node, prev.Range.Start, size)
+		nextBefore = ringSub(node, next.Range.Start, size)
+	)
+	switch id.Compare(nextBefore, prevBefore) {
+	case -1:
+		changes = append(changes, OwnershipChange{
+			Range: Range{Start: prev.Range.Start, End: ringSub(next.Range.Start, one, size)},
+			Peer:  next.Predecessor,
+		})
+	case 1:
+		changes = append(changes, OwnershipChange{
+			Range:  Range{Start: next.Range.Start, End: ringSub(prev.Range.Start, one, size)},
+			Gained: true,
+			Peer:   prev.Predecessor,
+		})
+	}
+
+	var (
+		prevAfter = ringSub(prev.Range.End, node, size)
+		nextAfter = ringSub(next.Range.End, node, size)
+	)
+	switch id.Compare(nextAfter, prevAfter) {
+	case -1:
+		changes = append(changes, OwnershipChange{
+			Range: Range{Start: ringAdd(next.Range.End, one, size), End: prev.Range.End},
+			Peer:  next.Successor,
+		})
+	case 1:
+		changes = append(changes, OwnershipChange{
+			Range:  Range{Start: ringAdd(prev.Range.End, one, size), End: next.Range.End},
+			Gained: true,
+			Peer:   prev.Successor,
+		})
+	}
+
+	return changes
+}
+
+// ringAdd :: (v + o) mod 2^size
+func ringAdd(v, o id.ID, size int) id.ID {
+	return mask(idAdd(v, o), size)
+}
+
+// ringSub :: (v - o) mod 2^size
+func ringSub(v, o id.ID, size int) id.ID {
+	return mask(idSub(v, o), size)
+}
+
+func mask(v id.ID, size int) id.ID {
+	max := id.MaxForSize(size)
+	return id.ID{High: v.High & max.High, Low: v.Low & max.Low}
+}
+
+// halve :: v / 2
+func halve(v id.ID) id.ID {
+	return id.ID{High: v.High >> 1, Low: v.Low>>1 | v.High<<63}
+}
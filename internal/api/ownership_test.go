@@ -0,0 +1,197 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipOf(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	tt := []struct {
+		name   string
+		node   int
+		leaves []int
+		expect Range
+	}{
+		{
+			name:   "no leaves",
+			node:   20,
+			expect: Range{Start: id.ID{Low: 21}, End: id.ID{Low: 20}},
+		},
+		{
+			name:   "ties go to smaller ID",
+			node:   20,
+			leaves: []int{10, 30},
+			expect: Range{Start: id.ID{Low: 16}, End: id.ID{Low: 25}},
+		},
+		{
+			name:   "wraparound",
+			node:   250,
+			leaves: []int{240, 5},
+			expect: Range{Start: id.ID{Low: 246}, End: id.ID{Low: 255}},
+		},
+		{
+			name:   "single peer",
+			node:   20,
+			leaves: []int{100},
+			expect: Range{Start: id.ID{Low: 188}, End: id.ID{Low: 60}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewState(descFrom(tc.node), 4, 4, 8, 4)
+			for _, l := range tc.leaves {
+				s.addLeaf(descFrom(l))
+			}
+
+			o := OwnershipOf(s)
+			require.Equal(t, tc.expect, o.Range)
+			require.Equal(t, len(tc.leaves) == 0, o.Full)
+
+			// Every key in the range should be closer to the node than its leaves.
+			for k := 0; k < 256; k++ {
+				key := id.ID{Low: uint64(k)}
+
+				closest := s.Node
+				for _, l := range s.Leaves(false) {
+					if Closer(l.ID, closest.ID, key, s.Size) {
+						closest = l
+					}
+				}
+				require.Equal(t, closest == s.Node, o.Range.Contains(key), "key %d", k)
+			}
+		})
+	}
+}
+
+func TestHandoffOf(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	tt := []struct {
+		name   string
+		node   int
+		leaves []int
+	}{
+		{name: "no leaves", node: 20},
+		{name: "even gap", node: 20, leaves: []int{10, 30}},
+		{name: "odd gap", node: 20, leaves: []int{9, 30}},
+		{name: "wraparound", node: 250, leaves: []int{240, 5}},
+		{name: "single peer", node: 20, leaves: []int{100}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewState(descFrom(tc.node), 4, 4, 8, 4)
+			for _, l := range tc.leaves {
+				s.addLeaf(descFrom(l))
+			}
+
+			changes := HandoffOf(s)
+			if len(tc.leaves) == 0 {
+				require.Empty(t, changes)
+				return
+			}
+
+			// Every key owned by the node should be handed off to whichever
+			// of its leaves is closest to the key.
+			o := OwnershipOf(s)
+			for k := 0; k < 256; k++ {
+				key := id.ID{Low: uint64(k)}
+				if !o.Range.Contains(key) {
+					continue
+				}
+
+				var closest Descriptor
+				for i, l := range s.Leaves(false) {
+					if i == 0 || Closer(l.ID, closest.ID, key, s.Size) {
+						closest = l
+					}
+				}
+
+				var found []Descriptor
+				for _, c := range changes {
+					require.False(t, c.Gained)
+					if c.Range.Contains(key) {
+						found = append(found, c.Peer)
+					}
+				}
+				require.Equal(t, []Descriptor{closest}, found, "key %d", k)
+			}
+		})
+	}
+}
+
+func TestDiffOwnership(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+	rangeFrom := func(start, end int) Range {
+		return Range{Start: id.ID{Low: uint64(start)}, End: id.ID{Low: uint64(end)}}
+	}
+	ownership := func(leaves ...int) Ownership {
+		s := NewState(descFrom(100), 4, 4, 8, 4)
+		for _, l := range leaves {
+			s.addLeaf(descFrom(l))
+		}
+		return OwnershipOf(s)
+	}
+
+	tt := []struct {
+		name       string
+		prev, next Ownership
+		expect     []OwnershipChange
+	}{
+		{
+			name: "unchanged",
+			prev: ownership(80, 120),
+			next: ownership(80, 120),
+		},
+		{
+			name: "predecessor joined",
+			prev: ownership(80, 120),
+			next: ownership(80, 90, 120),
+			expect: []OwnershipChange{
+				{Range: rangeFrom(91, 95), Peer: descFrom(90)},
+			},
+		},
+		{
+			name: "successor left",
+			prev: ownership(80, 120, 140),
+			next: ownership(80, 140),
+			expect: []OwnershipChange{
+				{Range: rangeFrom(111, 120), Gained: true, Peer: descFrom(120)},
+			},
+		},
+		{
+			name: "first peer joined",
+			prev: ownership(),
+			next: ownership(200),
+			expect: []OwnershipChange{
+				{Range: rangeFrom(151, 21), Peer: descFrom(200)},
+			},
+		},
+		{
+			name: "last peer left",
+			prev: ownership(80, 120),
+			next: ownership(),
+			expect: []OwnershipChange{
+				{Range: rangeFrom(111, 90), Gained: true},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := DiffOwnership(id.ID{Low: 100}, tc.prev, tc.next, 8)
+			require.Equal(t, tc.expect, actual)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// RingView is a node's view of its neighborhood in the ring, used to verify
+// that nodes agree on the membership of the ring.
+type RingView struct {
+	Node Descriptor
+
+	// Healthy predecessors and successors of Node, closest first.
+	Predecessors, Successors []Descriptor
+
+	// Checksum of the view, computed by RingChecksum.
+	Checksum uint64
+}
+
+// RingViewOf returns the view of the ring of s.Node.
+func RingViewOf(s *State) RingView {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v := RingView{Node: s.Node}
+
+	// Predecessors are sorted furthest first, and successors are sorted
+	// closest first.
+	for i := len(s.Predecessors.Descriptors) - 1; i >= 0; i-- {
+		if d := s.Predecessors.Descriptors[i]; s.Statuses[d] == Healthy {
+			v.Predecessors = append(v.Predecessors, d)
+		}
+	}
+	for _, d := range s.Successors.Descriptors {
+		if s.Statuses[d] == Healthy {
+			v.Successors = append(v.Successors, d)
+		}
+	}
+
+	v.Checksum = RingChecksum(v.Node, v.Predecessors, v.Successors)
+	return v
+}
+
+// RingChecksum computes a checksum over node and its ordered predecessors
+// and successors. Nodes with the same view of their neighborhood have the
+// same checksum.
+func RingChecksum(node Descriptor, preds, succs []Descriptor) uint64 {
+	h := fnv.New64a()
+
+	var buf [16]byte
+	write := func(d Descriptor) {
+		binary.BigEndian.PutUint64(buf[:8], d.ID.High)
+		binary.BigEndian.PutUint64(buf[8:], d.ID.Low)
+		_, _ = h.Write(buf[:])
+		_, _ = h.Write([]byte(d.Addr))
+		_, _ = h.Write([]byte{0})
+	}
+	writeList := func(ds []Descriptor) {
+		binary.BigEndian.PutUint64(buf[:8], uint64(len(ds)))
+		_, _ = h.Write(buf[:8])
+		for _, d := range ds {
+			write(d)
+		}
+	}
+
+	write(node)
+	writeList(preds)
+	writeList(succs)
+	return h.Sum64()
+}
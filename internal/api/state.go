@@ -35,9 +35,30 @@ type State struct {
 	// Statuses maps a Descriptor's ID to its health state.
 	Statuses map[Descriptor]Health
 
-	// LastUpdated is the last time this State was updated. Used to ID it
-	// between previous iterations of the State.
+	// Version is a monotonically increasing number that is incremented every
+	// time the State changes. Used to ID it between previous iterations of
+	// the State.
+	//
+	// The initial Version is 0. Owners of a State seed it before the State
+	// is shared so that versions keep increasing when a node restarts.
+	Version uint64
+
+	// LastUpdated is the last time this State was updated. Only intended for
+	// display purposes; use Version to detect changes.
 	LastUpdated time.Time
+
+	// AdmitFunc, if set, is consulted before adding any peer to the State.
+	// Peers for which AdmitFunc returns false are never added.
+	AdmitFunc func(d Descriptor) bool
+
+	// PreferFunc, if set, reports whether a is preferred over b when either
+	// could fill the same routing table slot or be used as a fallback next
+	// hop. PreferFunc never changes which node is closest to a key.
+	PreferFunc func(a, b Descriptor) bool
+
+	// Now, if set, is used instead of time.Now to set LastUpdated and to
+	// calculate the Age of the State.
+	Now func() time.Time
 }
 
 // NewState creates a new State for a node.
@@ -73,20 +94,52 @@ func NewState(
 		Size: size,
 		Base: base,
 
-		Neighbors:   &DescriptorSet{Size: numNeighbors},
-		Statuses:    make(map[Descriptor]Health),
-		LastUpdated: time.Now(),
+		Neighbors: &DescriptorSet{Size: numNeighbors},
+		Statuses:  make(map[Descriptor]Health),
 	}
 
 	s.reset()
 	return s
 }
 
-// IsNewer returns true if State has been modified since t.
-func (s *State) IsNewer(t time.Time) bool {
+// IsNewer returns true if State has been modified since version.
+func (s *State) IsNewer(version uint64) bool {
 	s.mut.Lock()
 	defer s.mut.Unlock()
-	return s.LastUpdated.After(t)
+	return s.Version > version
+}
+
+// CurrentVersion returns the current Version of the State.
+func (s *State) CurrentVersion() uint64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.Version
+}
+
+// Age returns how long it has been since State was last modified.
+func (s *State) Age() time.Duration {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.now().Sub(s.LastUpdated)
+}
+
+// now returns the current time.
+func (s *State) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// admit returns true if d may be added to s.
+func (s *State) admit(d Descriptor) bool {
+	return s.AdmitFunc == nil || s.AdmitFunc(d)
+}
+
+// touch marks s as modified.
+func (s *State) touch() {
+	s.Version++
+	s.LastUpdated = s.now()
 }
 
 // resets the state, removing all peers.
@@ -102,7 +155,7 @@ func (s *State) reset() {
 	s.Neighbors.Descriptors = s.Neighbors.Descriptors[:0]
 
 	s.Statuses = make(map[Descriptor]Health)
-	s.LastUpdated = time.Now()
+	s.touch()
 
 	// Add ourselves into the routing table at every row.
 	digits := s.Node.ID.Digits(s.Size, s.Base)
@@ -135,18 +188,38 @@ func (s *State) Calculate(hellos []Hello) {
 		// have at least one entry we can use.
 		s.mixinRoutes(h.State)
 	}
+
+	s.touch()
 }
 
 // MixinState takes the routes, neighbors, and leaves from the
 // peer and mixes them all into s.
 func (s *State) MixinState(peer *State) (updatedRoutes, updatedNeighbors, updatedLeaves bool) {
+	return s.MixinStates([]*State{peer})
+}
+
+// MixinStates is like MixinState but mixes in multiple peers at once. All
+// peers are mixed in while holding the lock and Version is only changed
+// once.
+func (s *State) MixinStates(peers []*State) (updatedRoutes, updatedNeighbors, updatedLeaves bool) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	updatedRoutes = s.mixinRoutes(peer)
-	updatedNeighbors = s.mixinNeighbors(peer)
-	updatedLeaves = s.mixinLeaves(peer)
+	for _, peer := range peers {
+		if s.mixinRoutes(peer) {
+			updatedRoutes = true
+		}
+		if s.mixinNeighbors(peer) {
+			updatedNeighbors = true
+		}
+		if s.mixinLeaves(peer) {
+			updatedLeaves = true
+		}
+	}
 
+	if updatedRoutes || updatedNeighbors || updatedLeaves {
+		s.touch()
+	}
 	return
 }
 
@@ -183,7 +256,11 @@ func (s *State) Clone() *State {
 		clone.Statuses[k] = v
 	}
 
+	clone.Version = s.Version
 	clone.LastUpdated = s.LastUpdated
+	clone.AdmitFunc = s.AdmitFunc
+	clone.PreferFunc = s.PreferFunc
+	clone.Now = s.Now
 	return &clone
 }
 
@@ -195,6 +272,33 @@ func (s *State) Leaves(all bool) []Descriptor {
 	return s.leaves(all)
 }
 
+// RingNeighbors returns the closest healthy predecessor and successor of
+// s.Node. ok will be false if there are no healthy leaves. In small clusters,
+// pred and succ may be the same node.
+func (s *State) RingNeighbors() (pred, succ Descriptor, ok bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var foundPred, foundSucc bool
+
+	// Predecessors are sorted furthest first, and successors are sorted
+	// closest first.
+	for i := len(s.Predecessors.Descriptors) - 1; i >= 0; i-- {
+		if d := s.Predecessors.Descriptors[i]; s.Statuses[d] == Healthy {
+			pred, foundPred = d, true
+			break
+		}
+	}
+	for _, d := range s.Successors.Descriptors {
+		if s.Statuses[d] == Healthy {
+			succ, foundSucc = d, true
+			break
+		}
+	}
+
+	return pred, succ, foundPred && foundSucc
+}
+
 func (s *State) leaves(all bool) []Descriptor {
 	added := map[Descriptor]struct{}{}
 
@@ -262,31 +366,36 @@ func (s *State) peers(all bool) []Descriptor {
 }
 
 // mixinRoutes takes routes from peer and incorporates each into
-// s. Fails if the two state tables do not have the same size and base.
-// Ignores node that s or peer do not find healthy.
+// s. peer may use a different size and base than s; entries with IDs too big
+// for s are ignored. Ignores node that s or peer do not find healthy.
 func (s *State) mixinRoutes(peer *State) (updated bool) {
-	// TODO(rfratto): relax restriction that State tables must be of same size.
-	// To relax this, we'd need to iterate over the entire table for peer instead
-	// of just one row.
-	if s.Base != peer.Base || s.Size != peer.Size {
-		return false
-	}
-
-	// Find row with relevant entries and incorporate.
-	overlap := Prefix(
-		s.Node.ID.Digits(s.Size, s.Base),
-		peer.Node.ID.Digits(s.Size, s.Base),
-	)
-	for _, ent := range peer.Routing[overlap] {
-		if ent == nil {
-			continue
-		}
-		d := *ent
-		if s.Statuses[d] != Healthy || peer.Statuses[d] != Healthy {
-			continue
-		}
-		if s.addRoute(d) {
-			updated = true
+	// Only one row of peer's table is relevant when both tables have the same
+	// shape: the row for the prefix shared by both nodes.
+	candidates := peer.Routing
+	if s.Base == peer.Base && s.Size == peer.Size {
+		overlap := Prefix(
+			s.Node.ID.Digits(s.Size, s.Base),
+			peer.Node.ID.Digits(s.Size, s.Base),
+		)
+		candidates = peer.Routing[overlap : overlap+1]
+	}
+
+	// Otherwise, the tables use a different Size or Base (e.g., during a
+	// rolling migration between configurations), and any of peer's entries
+	// may be relevant. addRoute re-derives the position of each entry in our
+	// table from its ID.
+	for _, row := range candidates {
+		for _, ent := range row {
+			if ent == nil {
+				continue
+			}
+			d := *ent
+			if s.Statuses[d] != Healthy || peer.Statuses[d] != Healthy {
+				continue
+			}
+			if s.addRoute(d) {
+				updated = true
+			}
 		}
 	}
 
@@ -294,7 +403,7 @@ func (s *State) mixinRoutes(peer *State) (updated bool) {
 }
 
 func (s *State) addRoute(d Descriptor) bool {
-	if s.Node.ID == d.ID {
+	if s.Node.ID == d.ID || !s.admit(d) {
 		return false
 	}
 
@@ -302,6 +411,11 @@ func (s *State) addRoute(d Descriptor) bool {
 		other = d.ID.Digits(s.Size, s.Base)
 		local = s.Node.ID.Digits(s.Size, s.Base)
 	)
+	if other == nil {
+		// d's ID is too big for our table, which can happen when mixing in
+		// tables of peers using a larger Size.
+		return false
+	}
 
 	// Find position in table. Rows all have the same prefix of digits, and the
 	// column is the first non-matching digit.
@@ -310,14 +424,14 @@ func (s *State) addRoute(d Descriptor) bool {
 		col = other[row]
 	)
 
-	// Don't override an existing entry that is healthy.
+	// Don't override an existing entry that is healthy unless d is preferred.
 	if exist := s.Routing[row][col]; exist != nil && s.Statuses[*exist] == Healthy {
-		// TODO(rfratto): proximity optimization
-		return false
+		if *exist == d || !s.prefer(d, *exist) {
+			return false
+		}
 	}
 
 	s.Routing[row][col] = &d
-	s.LastUpdated = time.Now()
 	return true
 }
 
@@ -340,16 +454,12 @@ func (s *State) mixinNeighbors(peer *State) (updated bool) {
 	return updated
 }
 
-func (s *State) addNeighbor(d Descriptor) (updated bool) {
-	if s.Statuses[d] != Healthy {
+func (s *State) addNeighbor(d Descriptor) bool {
+	if s.Statuses[d] != Healthy || !s.admit(d) {
 		return false
 	}
 
-	updated = s.Neighbors.Push(d)
-	if updated {
-		s.LastUpdated = time.Now()
-	}
-	return
+	return s.Neighbors.Push(d)
 }
 
 // IsLeaf returns true if d is a leaf node.
@@ -359,8 +469,8 @@ func (s *State) IsLeaf(d Descriptor) bool {
 	return s.Predecessors.Contains(d) || s.Successors.Contains(d)
 }
 
-// ReplacePredecessor will replace d with a healthy leaf from peer.
-// d must exist as a Predecessor and be unhealthy.
+// ReplacePredecessor will replace d with the closest healthy predecessor
+// from peer's leaves. d must exist as a Predecessor and be unhealthy.
 func (s *State) ReplacePredecessor(d Descriptor, peer *State) (changed bool) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -370,29 +480,20 @@ func (s *State) ReplacePredecessor(d Descriptor, peer *State) (changed bool) {
 	}
 
 	changed = s.Predecessors.Remove(d)
-	s.LastUpdated = time.Now().UTC()
+	s.touch()
 
 	if peer == nil {
 		return
 	}
 
-	for _, l := range peer.Leaves(false) {
-		// Only replace with a healthy node that's also not us.
-		if s.Statuses[l] != Healthy || l == s.Node {
-			continue
-		}
-		if s.Predecessors.Insert(l) {
-			// TODO(rfratto): optimization: ensure s.Predecessors.Insert will only return true
-			// when the set changes and not if l already exsts. Then do an early return here.
-			changed = true
-		}
+	if s.replaceLeaf(s.Predecessors, peer) {
+		changed = true
 	}
-
 	return
 }
 
-// ReplaceSuccessor will replace d with a healthy leaf from peer.
-// d must exist as a Successors and be unhealthy.
+// ReplaceSuccessor will replace d with the closest healthy successor from
+// peer's leaves. d must exist as a Successors and be unhealthy.
 func (s *State) ReplaceSuccessor(d Descriptor, peer *State) (changed bool) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
@@ -402,25 +503,46 @@ func (s *State) ReplaceSuccessor(d Descriptor, peer *State) (changed bool) {
 	}
 
 	changed = s.Successors.Remove(d)
-	s.LastUpdated = time.Now().UTC()
+	s.touch()
 
 	if peer == nil {
 		return
 	}
 
+	if s.replaceLeaf(s.Successors, peer) {
+		changed = true
+	}
+	return
+}
+
+// replaceLeaf inserts the leaf from peer that is closest to s.Node in the
+// direction of set. Only healthy leaves that aren't already in set are
+// considered. Returns true if a leaf was inserted.
+func (s *State) replaceLeaf(set *DescriptorSet, peer *State) bool {
+	var (
+		best  Descriptor
+		found bool
+	)
+
 	for _, l := range peer.Leaves(false) {
 		// Only replace with a healthy node that's also not us.
-		if s.Statuses[l] != Healthy || l == s.Node {
+		if s.Statuses[l] != Healthy || l == s.Node || !s.admit(l) || set.Contains(l) {
 			continue
 		}
-		if s.Successors.Insert(l) {
-			// TODO(rfratto): optimization: ensure s.Predecessors.Insert will only return true
-			// when the set changes and not if l already exsts. Then do an early return here.
-			changed = true
+
+		switch {
+		case !found:
+			best, found = l, true
+		case set.KeepBiggest && !set.SearchFunc(best, l):
+			// Predecessors are closer the bigger they are.
+			best = l
+		case !set.KeepBiggest && !set.SearchFunc(l, best):
+			// Successors are closer the smaller they are.
+			best = l
 		}
 	}
 
-	return
+	return found && set.Insert(best)
 }
 
 // ReplaceRoute will replace d in the routing table with an entry from peer.
@@ -442,7 +564,7 @@ func (s *State) ReplaceRoute(d Descriptor, peer *State) (replaced, ok bool) {
 		return
 	}
 	ok = true
-	s.LastUpdated = time.Now().UTC()
+	s.touch()
 
 	if peer == nil {
 		s.Routing[row][col] = nil
@@ -450,13 +572,79 @@ func (s *State) ReplaceRoute(d Descriptor, peer *State) (replaced, ok bool) {
 	}
 
 	candidate := peer.Routing[row][col]
-	if candidate != nil && s.Statuses[*candidate] == Healthy && peer.Statuses[*candidate] == Healthy {
+	if candidate != nil && s.Statuses[*candidate] == Healthy && peer.Statuses[*candidate] == Healthy && s.admit(*candidate) {
 		s.Routing[row][col] = candidate
 		replaced = true
 	}
 	return
 }
 
+// RepairRow fills holes and replaces unhealthy entries in row of the routing
+// table with entries from the same row of peer's table. peer must share at
+// least row digits with s.Node so its row covers the same prefix, otherwise
+// RepairRow does nothing. peer itself is also considered as an entry.
+// Returns true if the routing table changed.
+func (s *State) RepairRow(row int, peer *State) (updated bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if peer.Base != s.Base || peer.Size != s.Size || row < 0 || row >= len(s.Routing) {
+		return false
+	}
+	overlap := Prefix(
+		s.Node.ID.Digits(s.Size, s.Base),
+		peer.Node.ID.Digits(s.Size, s.Base),
+	)
+	if overlap < row {
+		return false
+	}
+
+	if s.Statuses[peer.Node] == Healthy && s.addRoute(peer.Node) {
+		updated = true
+	}
+	for _, ent := range peer.Routing[row] {
+		if ent == nil {
+			continue
+		}
+		d := *ent
+		if s.Statuses[d] != Healthy || peer.Statuses[d] != Healthy {
+			continue
+		}
+		if s.addRoute(d) {
+			updated = true
+		}
+	}
+
+	if updated {
+		s.touch()
+	}
+	return
+}
+
+// RoutingFill returns the ratio of filled entries in the routing table. The
+// entry of each row holding s.Node is not counted.
+func (s *State) RoutingFill() float64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var filled, total int
+	for _, row := range s.Routing {
+		if len(row) == 0 {
+			continue
+		}
+		total += len(row) - 1
+		for _, ent := range row {
+			if ent != nil && *ent != s.Node {
+				filled++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(filled) / float64(total)
+}
+
 // RouteIndex returns the index in the routing table for d.
 // d must not be s.Node, otherwise erturns -1, -1.
 func (s *State) RouteIndex(d Descriptor) (row, col int) {
@@ -492,7 +680,7 @@ func (s *State) ReplaceNeighbor(d Descriptor, peer *State) (changed, ok bool) {
 	ok = true
 
 	s.Neighbors.Remove(d)
-	s.LastUpdated = time.Now().UTC()
+	s.touch()
 
 	if peer == nil {
 		return
@@ -520,7 +708,12 @@ func (s *State) ReplaceNeighbor(d Descriptor, peer *State) (changed, ok bool) {
 func (s *State) MixinLeaves(peer *State) (updated bool) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
-	return s.mixinLeaves(peer)
+
+	updated = s.mixinLeaves(peer)
+	if updated {
+		s.touch()
+	}
+	return
 }
 
 func (s *State) mixinLeaves(peer *State) (updated bool) {
@@ -541,16 +734,10 @@ func (s *State) mixinLeaves(peer *State) (updated bool) {
 }
 
 func (s *State) addLeaf(d Descriptor) (updated bool) {
-	if s.Statuses[d] != Healthy {
+	if s.Statuses[d] != Healthy || !s.admit(d) {
 		return false
 	}
 
-	defer func() {
-		if updated {
-			s.LastUpdated = time.Now()
-		}
-	}()
-
 	if s.Predecessors.Insert(d) {
 		updated = true
 	}
@@ -579,11 +766,30 @@ func (s *State) SetHealth(p Descriptor, h Health) (changed bool) {
 		changed = true
 	}
 	if changed {
-		s.LastUpdated = time.Now()
+		s.touch()
 	}
 	return
 }
 
+// Lookup finds the peer of s with the given ID and address, returning its
+// descriptor and health. ok is false if s doesn't know about the peer.
+func (s *State) Lookup(peerID id.ID, addr string) (d Descriptor, h Health, ok bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for p, status := range s.Statuses {
+		if p.ID == peerID && p.Addr == addr {
+			return p, status, true
+		}
+	}
+	for _, p := range s.peers(true) {
+		if p.ID == peerID && p.Addr == addr {
+			return p, Healthy, true
+		}
+	}
+	return Descriptor{}, Healthy, false
+}
+
 // Untrack removes p from s' health tracking. If p is a peer, Untrack
 // will do nothing. This prevents untracking an unhealthy peer that is
 // still being used for routing.
@@ -602,11 +808,21 @@ func (s *State) Untrack(p Descriptor) (changed bool) {
 	return ok
 }
 
+// prefer returns true if a is preferred over b.
+func (s *State) prefer(a, b Descriptor) bool {
+	return s.PreferFunc != nil && s.PreferFunc(a, b)
+}
+
 // distance calculates the distance of a and b.
 func (s *State) distance(a, b id.ID) id.ID {
 	return idDistance(a, b, id.MaxForSize(s.Size))
 }
 
+// closer returns true if a is closer to key than b. See Closer.
+func (s *State) closer(a, b, key id.ID) bool {
+	return idCloser(a, b, key, id.MaxForSize(s.Size))
+}
+
 // Prefix returns the first index where a and b differ. Returns
 // len(a) if they are equal. Returns -1 if a and b have different
 // lengths.
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"sort"
+
 	"github.com/rfratto/croissant/id"
 )
 
@@ -15,20 +17,64 @@ func NewRoutingTable(width, height int) RoutingTable {
 	return rt
 }
 
+// RouteSource describes which part of a State was used to pick the next hop
+// for a key.
+type RouteSource uint
+
+const (
+	// RouteUnknown is used when no next hop could be found.
+	RouteUnknown RouteSource = iota
+	// RouteSelf indicates that the local node is the closest to the key.
+	RouteSelf
+	// RouteLeaf indicates that the next hop was found in the leaf set.
+	RouteLeaf
+	// RouteRoutingTable indicates that the next hop was found in the routing
+	// table. The slot used can be found with State.RouteIndex.
+	RouteRoutingTable
+	// RouteNeighbor indicates that the routing table had no entry for the
+	// key and the next hop was a neighbor found in the fallback scan.
+	RouteNeighbor
+	// RouteFallback indicates that the routing table had no entry for the key
+	// and the next hop was found by scanning all peers.
+	RouteFallback
+)
+
+// String returns the route source as a string.
+func (rs RouteSource) String() string {
+	switch rs {
+	case RouteSelf:
+		return "Self"
+	case RouteLeaf:
+		return "Leaf"
+	case RouteRoutingTable:
+		return "RoutingTable"
+	case RouteNeighbor:
+		return "Neighbor"
+	case RouteFallback:
+		return "Fallback"
+	default:
+		return "Unknown"
+	}
+}
+
 // NextHop looks up the next hop for a key for the given State s.
 // May return s.Node if it is the closest node. Returning ok==false
 // indicates a routing failure, likely due to a bug.
 func NextHop(s *State, key id.ID) (next Descriptor, ok bool) {
+	next, _, ok = NextHopExplain(s, key)
+	return
+}
+
+// NextHopExplain is like NextHop, but also returns which part of the state
+// the next hop was found in.
+func NextHopExplain(s *State, key id.ID) (next Descriptor, source RouteSource, ok bool) {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
 	if inLeafRange(s, key) {
 		// Send to the lowest leaf node. Seed with ourselves so the local node can
 		// be a candidate.
-		var (
-			lowestDist = s.distance(s.Node.ID, key)
-			lowestPeer = s.Node
-		)
+		lowestPeer := s.Node
 
 		for _, n := range s.leaves(true) {
 			// TODO(rfratto): if n is closest but unhealthy, should we return some
@@ -37,14 +83,15 @@ func NextHop(s *State, key id.ID) (next Descriptor, ok bool) {
 				continue
 			}
 
-			dist := s.distance(n.ID, key)
-			if id.Compare(dist, lowestDist) < 0 {
-				lowestDist = dist
+			if s.closer(n.ID, lowestPeer.ID, key) {
 				lowestPeer = n
 			}
 		}
 
-		return lowestPeer, true
+		if lowestPeer == s.Node {
+			return lowestPeer, RouteSelf, true
+		}
+		return lowestPeer, RouteLeaf, true
 	}
 
 	// Not in leaf range. See if the routing table has a node that has a shared prefixLen
@@ -56,37 +103,189 @@ func NextHop(s *State, key id.ID) (next Descriptor, ok bool) {
 		prefixLen = Prefix(ourDigits, keyDigits)
 	)
 	if ent := s.Routing[prefixLen][keyDigits[prefixLen]]; ent != nil && s.Statuses[*ent] == Healthy {
-		return *ent, true
+		return *ent, RouteRoutingTable, true
 	}
 
 	// Rare case: look for any node at all with a shared prefix greater than ours
-	// that is also closer to it in the keyspace.
-	var (
-		localDistance  = s.distance(s.Node.ID, key)
-		lowestDistance = localDistance
-	)
-
+	// that is also closer to it in the keyspace. Any such node makes progress,
+	// so preferred nodes are picked over closer ones.
 	for _, p := range s.peers(false) {
 		// Ignore any candidate who has less digits in common
 		var (
 			candidateDigits = p.ID.Digits(s.Size, s.Base)
 			candidatePrefix = Prefix(candidateDigits, keyDigits)
 		)
-		if candidatePrefix < prefixLen {
+		if candidatePrefix < prefixLen || !s.closer(p.ID, s.Node.ID, key) {
 			continue
 		}
 
-		dist := s.distance(p.ID, key)
-		if id.Compare(dist, lowestDistance) < 0 {
-			lowestDistance = dist
+		switch {
+		case !ok:
+			next, ok = p, true
+		case s.prefer(p, next):
+			next = p
+		case s.prefer(next, p):
+			// Keep the preferred node.
+		case s.closer(p.ID, next.ID, key):
 			next = p
-			ok = true
 		}
 	}
 
+	switch {
+	case !ok:
+		source = RouteUnknown
+	case s.Neighbors.Contains(next):
+		source = RouteNeighbor
+	default:
+		source = RouteFallback
+	}
 	return
 }
 
+// NextHops returns up to n candidates for the next hop for key, best first.
+// The first candidate is the hop returned by NextHop. The remaining
+// candidates are other nodes that also make progress towards key: healthy
+// nodes are ranked before unhealthy nodes, followed by nodes sharing a
+// longer prefix with key, followed by nodes closer to key. Dead nodes are
+// never returned. Returns nil if NextHop fails.
+func NextHops(s *State, key id.ID, n int) []Descriptor {
+	first, ok := NextHop(s, key)
+	if !ok || n < 1 {
+		return nil
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var (
+		keyDigits = key.Digits(s.Size, s.Base)
+		prefixOf  = func(d Descriptor) int { return Prefix(d.ID.Digits(s.Size, s.Base), keyDigits) }
+		leafRange = inLeafRange(s, key)
+	)
+
+	candidates := make([]Descriptor, 0, len(s.Statuses)+1)
+	if first != s.Node && leafRange {
+		// s.Node may be the next closest node to key.
+		candidates = append(candidates, s.Node)
+	}
+	for _, p := range s.peers(true) {
+		if p == first || s.Statuses[p] == Dead {
+			continue
+		}
+		// Outside of the leaf range, only nodes closer to key than s.Node
+		// make progress.
+		if !leafRange && !s.closer(p.ID, s.Node.ID, key) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ah, bh := s.Statuses[a] == Healthy, s.Statuses[b] == Healthy; ah != bh {
+			return ah
+		}
+		if !leafRange {
+			if ap, bp := prefixOf(a), prefixOf(b); ap != bp {
+				return ap > bp
+			}
+		}
+		return s.closer(a.ID, b.ID, key)
+	})
+
+	res := append([]Descriptor{first}, candidates...)
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// Replicas returns up to n healthy nodes responsible for key: the owner of
+// key, which is the node closest to it, followed by the nodes succeeding the
+// owner on the ring, in ring order. ok will be false if key isn't in the
+// range of s' leaves, since s may not know about the owner.
+//
+// Only successors known to s are returned, so fewer than n replicas may be
+// returned when the owner is close to the last successor of s.Node.
+func Replicas(s *State, key id.ID, n int) (replicas []Descriptor, ok bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !inLeafRange(s, key) {
+		return nil, false
+	}
+
+	owner := s.Node
+	for _, l := range s.leaves(false) {
+		if s.closer(l.ID, owner.ID, key) {
+			owner = l
+		}
+	}
+
+	replicas = clockwise(s, owner.ID)
+	if len(replicas) > n {
+		replicas = replicas[:n]
+	}
+	return replicas, true
+}
+
+// Clockwise returns s.Node and the healthy leaves of s ordered clockwise
+// around the ring, starting from the first node at or after start. If the
+// leaves don't cover the whole ring, nodes after the last successor are
+// omitted, since s doesn't know about every node between them and the
+// successors.
+func Clockwise(s *State, start id.ID) []Descriptor {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return clockwise(s, start)
+}
+
+// clockwise implements Clockwise. Must be called with the mutex held.
+func clockwise(s *State, start id.ID) []Descriptor {
+	nodes := append([]Descriptor{s.Node}, s.leaves(false)...)
+	if !coversRing(s) {
+		// Find the last successor, which is the furthest successor from
+		// s.Node going clockwise.
+		var last, lastDist id.ID
+		for _, d := range s.Successors.Descriptors {
+			if dist := ringSub(d.ID, s.Node.ID, s.Size); id.Compare(dist, lastDist) > 0 {
+				last, lastDist = d.ID, dist
+			}
+		}
+
+		end := ringSub(last, start, s.Size)
+		known := nodes[:0]
+		for _, d := range nodes {
+			if id.Compare(ringSub(d.ID, start, s.Size), end) <= 0 {
+				known = append(known, d)
+			}
+		}
+		nodes = known
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return id.Compare(ringSub(nodes[i].ID, start, s.Size), ringSub(nodes[j].ID, start, s.Size)) < 0
+	})
+	return nodes
+}
+
+// coversRing returns true if the leaves of s hold every node in the cluster.
+func coversRing(s *State) bool {
+	if !s.Predecessors.IsFull() || !s.Successors.IsFull() {
+		return true
+	}
+	// The leaf sets wrap around when a node is both a predecessor and a
+	// successor.
+	for _, p := range s.Predecessors.Descriptors {
+		for _, succ := range s.Successors.Descriptors {
+			if p == succ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // inLeafRange returns true if the key is in the range of the leaf nodes.
 func inLeafRange(s *State, key id.ID) bool {
 	// If we're not full then the leaves contain all nodes in the cluster.
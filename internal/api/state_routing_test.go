@@ -22,6 +22,7 @@ func TestNextRoute(t *testing.T) {
 		input  *State
 		key    int
 		expect int
+		source RouteSource
 	}{
 		{
 			name: "exact match",
@@ -32,6 +33,7 @@ func TestNextRoute(t *testing.T) {
 			),
 			key:    0o1000,
 			expect: 0o1000,
+			source: RouteSelf,
 		},
 		{
 			name: "empty table",
@@ -42,6 +44,7 @@ func TestNextRoute(t *testing.T) {
 			),
 			key:    0o5000,
 			expect: 0o1000,
+			source: RouteSelf,
 		},
 		{
 			name: "incomplete leaf",
@@ -64,6 +67,7 @@ func TestNextRoute(t *testing.T) {
 			}(),
 			key:    0o5000,
 			expect: 0o2000,
+			source: RouteLeaf,
 		},
 		{
 			name: "full leaf",
@@ -87,6 +91,7 @@ func TestNextRoute(t *testing.T) {
 			}(),
 			key:    0o150,
 			expect: 0o200,
+			source: RouteLeaf,
 		},
 		{
 			name: "routing lookup",
@@ -113,6 +118,7 @@ func TestNextRoute(t *testing.T) {
 			}(),
 			key:    0o3123,
 			expect: 0o3000,
+			source: RouteRoutingTable,
 		},
 		{
 			// In case the routing table doesn't have an entry, the closest
@@ -138,6 +144,7 @@ func TestNextRoute(t *testing.T) {
 			}(),
 			key:    0o1000,
 			expect: 0o500,
+			source: RouteFallback,
 		},
 		{
 			// In case the routing table doesn't have an entry, the closest
@@ -162,16 +169,18 @@ func TestNextRoute(t *testing.T) {
 			}(),
 			key:    0o1000,
 			expect: 0o1050,
+			source: RouteNeighbor,
 		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			actual, ok := NextHop(tc.input, id.ID{
+			actual, source, ok := NextHopExplain(tc.input, id.ID{
 				Low: uint64(tc.key),
 			})
 			require.True(t, ok)
 			require.Equal(t, tc.expect, int(actual.ID.Low))
+			require.Equal(t, tc.source, source)
 		})
 	}
 }
@@ -449,3 +458,104 @@ func createTestCluster(t *testing.T, rnd *rand.Rand, size int) ([]*State, map[id
 
 	return nodes, states
 }
+
+func TestClockwise(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+	ids := func(ds []Descriptor) []int {
+		res := make([]int, len(ds))
+		for i, d := range ds {
+			res[i] = int(d.ID.Low)
+		}
+		return res
+	}
+
+	t.Run("full leaves", func(t *testing.T) {
+		s := NewState(descFrom(100), 4, 4, 8, 4)
+		for _, l := range []int{60, 80, 120, 140} {
+			s.addLeaf(descFrom(l))
+		}
+
+		// Predecessors before start wrap around to the end of the ring, past
+		// the last known successor.
+		require.Equal(t, []int{80, 100, 120, 140}, ids(Clockwise(s, descFrom(80).ID)))
+		require.Equal(t, []int{120, 140}, ids(Clockwise(s, descFrom(110).ID)))
+	})
+
+	t.Run("partial leaves", func(t *testing.T) {
+		s := NewState(descFrom(100), 4, 4, 8, 4)
+		for _, l := range []int{10, 250} {
+			s.addLeaf(descFrom(l))
+		}
+		require.Equal(t, []int{250, 10, 100}, ids(Clockwise(s, descFrom(200).ID)))
+	})
+}
+
+func TestReplicas(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+	ids := func(ds []Descriptor) []int {
+		res := make([]int, len(ds))
+		for i, d := range ds {
+			res[i] = int(d.ID.Low)
+		}
+		return res
+	}
+
+	s := NewState(descFrom(100), 4, 4, 8, 4)
+	for _, l := range []int{60, 80, 120, 140} {
+		s.addLeaf(descFrom(l))
+	}
+
+	// The owner is followed by its successors, even when a predecessor of
+	// the owner is closer to the key.
+	replicas, ok := Replicas(s, descFrom(85).ID, 3)
+	require.True(t, ok)
+	require.Equal(t, []int{80, 100, 120}, ids(replicas))
+
+	// Successors past the last known successor of s aren't returned.
+	replicas, ok = Replicas(s, descFrom(125).ID, 3)
+	require.True(t, ok)
+	require.Equal(t, []int{120, 140}, ids(replicas))
+
+	_, ok = Replicas(s, descFrom(200).ID, 3)
+	require.False(t, ok, "keys outside of the leaf range have unknown replicas")
+}
+
+func TestNextHops(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+	ids := func(ds []Descriptor) []int {
+		res := make([]int, len(ds))
+		for i, d := range ds {
+			res[i] = int(d.ID.Low)
+		}
+		return res
+	}
+
+	s := NewState(descFrom(100), 4, 4, 8, 4)
+	for _, l := range []int{60, 80, 120, 140} {
+		s.addLeaf(descFrom(l))
+	}
+	s.SetHealth(descFrom(120), Unhealthy)
+	s.SetHealth(descFrom(60), Dead)
+
+	t.Run("leaf range", func(t *testing.T) {
+		// 140 is the next hop since 120 is unhealthy. Unhealthy nodes are
+		// ranked last and dead nodes are never returned.
+		require.Equal(t, []int{140, 100, 80, 120}, ids(NextHops(s, descFrom(125).ID, 10)))
+		require.Equal(t, []int{140, 100}, ids(NextHops(s, descFrom(125).ID, 2)))
+	})
+
+	t.Run("self", func(t *testing.T) {
+		require.Equal(t, []int{100, 80, 140, 120}, ids(NextHops(s, descFrom(98).ID, 10)))
+	})
+
+	t.Run("outside leaf range", func(t *testing.T) {
+		// Only nodes closer to the key than s.Node make progress.
+		require.Equal(t, []int{140, 120}, ids(NextHops(s, descFrom(200).ID, 10)))
+	})
+}
@@ -163,3 +163,187 @@ func TestState_MixLeaf(t *testing.T) {
 		require.Equal(t, tc.expect, gotLeaves)
 	}
 }
+
+func TestState_MixinStates(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	s := NewState(descFrom(5000), 4, 4, 16, 4)
+	before := s.Version
+
+	peers := []*State{
+		NewState(descFrom(3000), 4, 4, 16, 4),
+		NewState(descFrom(4000), 4, 4, 16, 4),
+		NewState(descFrom(6000), 4, 4, 16, 4),
+		NewState(descFrom(7000), 4, 4, 16, 4),
+	}
+
+	_, updatedNeighbors, updatedLeaves := s.MixinStates(peers)
+	require.True(t, updatedNeighbors)
+	require.True(t, updatedLeaves)
+	require.Equal(t, before+1, s.Version)
+
+	var gotLeaves []Descriptor
+	gotLeaves = append(gotLeaves, s.Predecessors.Descriptors...)
+	gotLeaves = append(gotLeaves, s.Successors.Descriptors...)
+	require.Equal(t, []Descriptor{
+		descFrom(3000),
+		descFrom(4000),
+		descFrom(6000),
+		descFrom(7000),
+	}, gotLeaves)
+}
+
+func TestState_MixinRoutes_DifferentShape(t *testing.T) {
+	descFrom := func(val uint64) Descriptor {
+		return Descriptor{ID: id.ID{Low: val}}
+	}
+
+	// peer uses a larger Size and a different Base than s.
+	peer := NewState(descFrom(0x1234), 4, 4, 32, 4)
+	for _, d := range []Descriptor{descFrom(0x8000), descFrom(0x2000), descFrom(0xF0000000)} {
+		require.True(t, peer.addRoute(d))
+	}
+
+	s := NewState(descFrom(0x1000), 4, 4, 16, 16)
+	require.True(t, s.mixinRoutes(peer))
+
+	for _, d := range []Descriptor{descFrom(0x8000), descFrom(0x2000)} {
+		row, col := s.routeIndex(d)
+		require.Equal(t, &d, s.Routing[row][col])
+	}
+
+	// IDs too big for s must be ignored.
+	for _, row := range s.Routing {
+		for _, ent := range row {
+			if ent != nil {
+				require.NotEqual(t, descFrom(0xF0000000), *ent)
+			}
+		}
+	}
+}
+
+func TestState_AdmitFunc(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	s := NewState(descFrom(5000), 4, 4, 16, 4)
+	s.AdmitFunc = func(d Descriptor) bool {
+		return d != descFrom(4000)
+	}
+
+	peer := NewState(descFrom(4000), 4, 4, 16, 4)
+	peer.addLeaf(descFrom(6000))
+	peer.addNeighbor(descFrom(6000))
+
+	s.MixinState(peer)
+	require.ElementsMatch(t, []Descriptor{descFrom(6000)}, s.Peers(true))
+}
+
+func TestState_PreferFunc(t *testing.T) {
+	var (
+		node   = Descriptor{ID: id.ID{Low: 0x0000}, Labels: "zone=a"}
+		remote = Descriptor{ID: id.ID{Low: 0x1000}, Labels: "zone=b"}
+		local  = Descriptor{ID: id.ID{Low: 0x1100}, Labels: "zone=a"}
+	)
+
+	s := NewState(node, 4, 4, 16, 16)
+	s.PreferFunc = func(a, b Descriptor) bool {
+		return a.Labels == node.Labels && b.Labels != node.Labels
+	}
+
+	// Both descriptors map to the same routing table slot.
+	require.True(t, s.addRoute(remote))
+	require.Equal(t, &remote, s.Routing[0][1])
+
+	require.True(t, s.addRoute(local))
+	require.Equal(t, &local, s.Routing[0][1])
+
+	// The preferred entry must not be replaced by a less preferred one.
+	require.False(t, s.addRoute(remote))
+	require.Equal(t, &local, s.Routing[0][1])
+}
+
+func TestState_RepairRow(t *testing.T) {
+	descFrom := func(val uint64) Descriptor {
+		return Descriptor{ID: id.ID{Low: val}}
+	}
+
+	s := NewState(descFrom(0x1000), 4, 4, 16, 16)
+	require.True(t, s.addRoute(descFrom(0x1300)))
+	s.SetHealth(descFrom(0x1300), Unhealthy)
+
+	peer := NewState(descFrom(0x1100), 4, 4, 16, 16)
+	for _, v := range []uint64{0x1200, 0x1380} {
+		require.True(t, peer.addRoute(descFrom(v)))
+	}
+
+	// A peer that doesn't share the row's prefix can't repair it.
+	other := NewState(descFrom(0x2000), 4, 4, 16, 16)
+	require.True(t, other.addRoute(descFrom(0x1200)))
+	require.False(t, s.RepairRow(1, other))
+
+	version := s.Version
+	require.True(t, s.RepairRow(1, peer))
+	require.Greater(t, s.Version, version)
+
+	// Holes are filled with peer and its entries, and the unhealthy entry is
+	// replaced.
+	require.Equal(t, descFrom(0x1100), *s.Routing[1][1])
+	require.Equal(t, descFrom(0x1200), *s.Routing[1][2])
+	require.Equal(t, descFrom(0x1380), *s.Routing[1][3])
+
+	require.False(t, s.RepairRow(1, peer))
+}
+
+func TestState_ReplacePredecessor(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	s := NewState(descFrom(5000), 4, 4, 16, 4)
+	s.addLeaf(descFrom(3000))
+	s.addLeaf(descFrom(4000))
+	require.Equal(t, []Descriptor{descFrom(3000), descFrom(4000)}, s.Predecessors.Descriptors)
+
+	peer := NewState(descFrom(3000), 8, 4, 16, 4)
+	for _, v := range []int{1000, 2000, 3500, 6000} {
+		peer.addLeaf(descFrom(v))
+	}
+
+	s.SetHealth(descFrom(4000), Unhealthy)
+	require.True(t, s.ReplacePredecessor(descFrom(4000), peer))
+
+	// 3500 is the closest predecessor to 5000 that peer knows about.
+	require.Equal(t, []Descriptor{descFrom(3000), descFrom(3500)}, s.Predecessors.Descriptors)
+}
+
+func TestState_RingNeighbors(t *testing.T) {
+	descFrom := func(val int) Descriptor {
+		return Descriptor{ID: id.ID{Low: uint64(val)}}
+	}
+
+	s := NewState(descFrom(5000), 4, 4, 16, 4)
+	_, _, ok := s.RingNeighbors()
+	require.False(t, ok)
+
+	for _, v := range []int{1000, 4000, 6000, 60000} {
+		s.addLeaf(descFrom(v))
+	}
+
+	pred, succ, ok := s.RingNeighbors()
+	require.True(t, ok)
+	require.Equal(t, descFrom(4000), pred)
+	require.Equal(t, descFrom(6000), succ)
+
+	// Unhealthy leaves should be skipped.
+	s.SetHealth(descFrom(4000), Unhealthy)
+	s.SetHealth(descFrom(6000), Unhealthy)
+
+	pred, succ, ok = s.RingNeighbors()
+	require.True(t, ok)
+	require.Equal(t, descFrom(1000), pred)
+	require.Equal(t, descFrom(60000), succ)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Protocol versions. Every Join and Hello carries the protocol version of the
+// sender so peers can avoid features the sender doesn't support.
+const (
+	// ProtocolV1 is the original protocol. Peers that don't send a protocol
+	// version use ProtocolV1.
+	ProtocolV1 uint32 = 1
+
+	// ProtocolV2 adds state deltas, cluster names, and Ping.
+	ProtocolV2 uint32 = 2
+
+	// ProtocolVersion is the protocol version implemented by this package.
+	ProtocolVersion = ProtocolV2
+
+	// MinProtocolVersion is the oldest protocol version peers may use.
+	MinProtocolVersion = ProtocolV1
+)
+
+// ErrUnsupportedVersion is returned when a peer uses a protocol version that
+// is too old.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// NegotiateVersion returns the protocol version to use with a peer that sent
+// peer as its protocol version. The negotiated version is the older of peer
+// and ProtocolVersion. An error wrapping ErrUnsupportedVersion is returned if
+// peer is older than min.
+func NegotiateVersion(min, peer uint32) (uint32, error) {
+	if peer == 0 {
+		peer = ProtocolV1
+	}
+	if peer < min {
+		return 0, fmt.Errorf("%w: peer uses version %d, but at least version %d is required", ErrUnsupportedVersion, peer, min)
+	}
+	if peer > ProtocolVersion {
+		return ProtocolVersion, nil
+	}
+	return peer, nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	tt := []struct {
+		name      string
+		min, peer uint32
+		expect    uint32
+		err       error
+	}{
+		{name: "unversioned peer", min: ProtocolV1, peer: 0, expect: ProtocolV1},
+		{name: "older peer", min: ProtocolV1, peer: ProtocolV1, expect: ProtocolV1},
+		{name: "same version", min: ProtocolV1, peer: ProtocolVersion, expect: ProtocolVersion},
+		{name: "newer peer", min: ProtocolV1, peer: ProtocolVersion + 1, expect: ProtocolVersion},
+		{name: "unsupported peer", min: ProtocolV2, peer: 0, err: ErrUnsupportedVersion},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := NegotiateVersion(tc.min, tc.peer)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, v)
+		})
+	}
+}
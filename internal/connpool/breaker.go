@@ -0,0 +1,124 @@
+package connpool
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerConfig configures circuit breaking for addresses in a Pool.
+//
+// Calls to each address are tracked over a window. Once enough calls failed,
+// the circuit for the address opens for a cooldown period, signaling callers
+// to avoid the address. Afterwards, calls are allowed again: the next
+// success closes the circuit, while the next failure opens it again.
+type BreakerConfig struct {
+	// FailureRatio is the ratio of failed calls in a window that opens the
+	// circuit.
+	FailureRatio float64
+	// MinCalls is the minimum number of calls in a window before the circuit
+	// may open.
+	MinCalls int
+	// Window is how long calls are tracked before their counts are reset.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open.
+	Cooldown time.Duration
+}
+
+// breaker tracks the circuit of a single address.
+type breaker struct {
+	windowStart     time.Time
+	calls, failures int
+	openUntil       time.Time
+	halfOpen        bool // Cooldown passed, waiting for the next result.
+}
+
+// SetBreaker enables circuit breaking with cfg. Circuit breaking is disabled
+// if cfg is nil.
+func (p *Pool) SetBreaker(cfg *BreakerConfig) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.breakerCfg = cfg
+	p.breakers = make(map[string]*breaker)
+}
+
+// CircuitOpen returns true if the circuit for addr is open, meaning that
+// recent calls to addr failed and it should be avoided.
+func (p *Pool) CircuitOpen(addr string) bool {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.breakerCfg == nil {
+		return false
+	}
+	b, ok := p.breakers[addr]
+	if !ok || b.openUntil.IsZero() {
+		return false
+	}
+
+	if p.now().Before(b.openUntil) {
+		return true
+	}
+	// The cooldown passed; allow calls until the next result decides
+	// whether the circuit closes or opens again.
+	b.openUntil = time.Time{}
+	b.halfOpen = true
+	return false
+}
+
+// RecordResult records the result of a call to addr. Calls made through
+// connections from the Pool are recorded automatically; RecordResult
+// allows recording failures that happen before a call is made, such as
+// failing to connect. Only Unavailable errors are failures.
+func (p *Pool) RecordResult(addr string, err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.recordResult(addr, err)
+}
+
+// recordResult must be called with the mutex held.
+func (p *Pool) recordResult(addr string, err error) {
+	cfg := p.breakerCfg
+	if cfg == nil {
+		return
+	}
+
+	now := p.now()
+	b, ok := p.breakers[addr]
+	if !ok {
+		b = &breaker{windowStart: now}
+		p.breakers[addr] = b
+	}
+	if !b.openUntil.IsZero() && !now.Before(b.openUntil) {
+		b.openUntil = time.Time{}
+		b.halfOpen = true
+	}
+
+	failed := status.Code(err) == codes.Unavailable
+	if b.halfOpen {
+		b.halfOpen = false
+		b.calls, b.failures, b.windowStart = 0, 0, now
+		if failed {
+			b.openUntil = now.Add(cfg.Cooldown)
+		}
+		return
+	} else if !b.openUntil.IsZero() {
+		// Calls made while the circuit is open, such as health checks, don't
+		// change it.
+		return
+	}
+
+	if now.Sub(b.windowStart) >= cfg.Window {
+		b.calls, b.failures, b.windowStart = 0, 0, now
+	}
+	b.calls++
+	if failed {
+		b.failures++
+	}
+
+	if b.calls >= cfg.MinCalls && float64(b.failures) >= cfg.FailureRatio*float64(b.calls) && b.failures > 0 {
+		b.openUntil = now.Add(cfg.Cooldown)
+		b.calls, b.failures = 0, 0
+	}
+}
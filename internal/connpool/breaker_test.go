@@ -0,0 +1,57 @@
+package connpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPool_Breaker(t *testing.T) {
+	now := time.Now()
+
+	p := New(5)
+	p.now = func() time.Time { return now }
+	p.SetBreaker(&BreakerConfig{
+		FailureRatio: 0.5,
+		MinCalls:     4,
+		Window:       time.Minute,
+		Cooldown:     time.Second,
+	})
+
+	var (
+		unavailable = status.Error(codes.Unavailable, "down")
+		notFound    = status.Error(codes.NotFound, "missing")
+	)
+
+	// Errors other than Unavailable aren't failures.
+	for i := 0; i < 4; i++ {
+		p.RecordResult("a", notFound)
+	}
+	require.False(t, p.CircuitOpen("a"))
+
+	// Once the window resets, 2 out of 4 calls failing opens the circuit.
+	now = now.Add(time.Minute)
+	p.RecordResult("a", nil)
+	p.RecordResult("a", unavailable)
+	p.RecordResult("a", nil)
+	require.False(t, p.CircuitOpen("a"), "circuit opened before MinCalls")
+	p.RecordResult("a", unavailable)
+	require.True(t, p.CircuitOpen("a"))
+	require.False(t, p.CircuitOpen("b"), "circuits are tracked per address")
+
+	// After the cooldown, a failure opens the circuit again.
+	now = now.Add(time.Second)
+	require.False(t, p.CircuitOpen("a"))
+	p.RecordResult("a", unavailable)
+	require.True(t, p.CircuitOpen("a"))
+
+	// While a success closes it.
+	now = now.Add(time.Second)
+	require.False(t, p.CircuitOpen("a"))
+	p.RecordResult("a", nil)
+	p.RecordResult("a", unavailable)
+	require.False(t, p.CircuitOpen("a"))
+}
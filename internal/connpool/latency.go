@@ -0,0 +1,30 @@
+package connpool
+
+import "time"
+
+// latencyWeight is the weight of a new sample in the moving average of call
+// latencies.
+const latencyWeight = 0.2
+
+// Latency returns the moving average of the latency of successful unary
+// calls to addr made through connections from the Pool. ok is false if no
+// call to addr succeeded yet.
+func (p *Pool) Latency(addr string) (latency time.Duration, ok bool) {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	latency, ok = p.latencies[addr]
+	return latency, ok
+}
+
+// recordLatency adds a latency sample for addr.
+func (p *Pool) recordLatency(addr string, d time.Duration) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	avg, ok := p.latencies[addr]
+	if !ok {
+		p.latencies[addr] = d
+		return
+	}
+	p.latencies[addr] = avg + time.Duration(latencyWeight*float64(d-avg))
+}
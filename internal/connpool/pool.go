@@ -3,10 +3,13 @@ package connpool
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/rfratto/croissant/clock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 // Pool implements a connection Pool to nodes in the cluster. All
@@ -15,28 +18,91 @@ import (
 // The Pool has a maximum number of connections, and the oldest
 // unused connections will be closed and removed when opening a
 // new one. Dead nodes will be automatically removed from the
-// Pool.
+// Pool. The maximum may be scaled dynamically with SetMaxConnsFunc.
+//
+// If a maximum connection age is set with SetMaxConnAge, connections older
+// than the maximum age will be replaced by a new connection the next time
+// they are retrieved. Replaced connections are closed once calls using them
+// complete, but no sooner than a grace period after being replaced, since
+// callers may still hold them from an earlier Get.
+//
+// The latency of successful unary calls is tracked per address and
+// available through Latency.
+//
+// If circuit breaking is enabled with SetBreaker, the results of calls are
+// tracked per address so callers can avoid failing addresses with
+// CircuitOpen.
+//
+// Connections are dialed with grpc.Dial unless a different DialFunc is set
+// with SetDialer.
+//
+// Idle connections and connections to addresses that are no longer needed
+// may be closed in the background with StartReaper.
+//
+// The Pool implements prometheus.Collector, exposing metrics about its
+// connections. The same information is available through Stats.
 type Pool struct {
 	mut sync.RWMutex
 
 	opts []grpc.DialOption
+	dial DialFunc
 
 	maxConns   int
+	maxConnsFn func() int
+	maxAge     time.Duration
 	conns      map[string]*poolConn
 	connLookup map[*grpc.ClientConn]*poolConn
+
+	latencies map[string]time.Duration // Moving average latency by address.
+
+	breakerCfg *BreakerConfig
+	breakers   map[string]*breaker
+	clock      clock.Clock
+	now        func() time.Time // Set to clock.Now. Overridden by tests.
+
+	stopReaper  chan struct{} // Closed to stop the running reaper.
+	quit        chan struct{} // Closed by Close.
+	retireGrace time.Duration // Minimum time retired connections are kept open.
+
+	dials, dialFailures int
+	evictions           map[string]int // Evicted connections by reason.
 }
 
+// DialFunc creates a client connection to addr. opts must be used when
+// creating the connection, since the Pool relies on them to track calls.
+type DialFunc func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+
 type poolConn struct {
 	Conn     *grpc.ClientConn
+	Created  time.Time
 	LastUsed time.Time
+
+	// Number of in-flight calls and whether the conn has been replaced.
+	// Retired connections are closed once their grace period passed and
+	// there are no in-flight calls.
+	InFlight  int
+	Retired   bool
+	GraceOver bool
 }
 
+// retireGrace is the default minimum time a retired connection is kept
+// open. Callers which retrieved the connection before it was retired have
+// that long to start their calls.
+const retireGrace = time.Second
+
 // New creates a new connection pool.
 func New(maxConns int, opts ...grpc.DialOption) *Pool {
 	p := &Pool{
 		conns:      make(map[string]*poolConn, maxConns),
 		connLookup: make(map[*grpc.ClientConn]*poolConn, maxConns),
+		latencies:  make(map[string]time.Duration),
 		maxConns:   maxConns,
+		quit:       make(chan struct{}),
+		clock:      clock.Real(),
+		now:        time.Now,
+		dial:       grpc.Dial,
+
+		retireGrace: retireGrace,
 	}
 
 	fullOpts := []grpc.DialOption{
@@ -53,13 +119,40 @@ func (p *Pool) streamRefreshConn(
 	ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
 	method string, streamer grpc.Streamer, opts ...grpc.CallOption,
 ) (grpc.ClientStream, error) {
-	p.mut.Lock()
-	if pc, ok := p.connLookup[cc]; ok {
-		pc.LastUsed = time.Now()
+	done := p.startCall(cc)
+
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	p.RecordResult(cc.Target(), err)
+	if err != nil {
+		done()
+		return nil, err
 	}
-	p.mut.Unlock()
+	ts := &trackedStream{ClientStream: cs, done: done}
+
+	// Callers may stop reading from the stream without receiving an error.
+	// The context of the stream is done once the stream finishes for any
+	// reason, including being canceled by the caller.
+	go func() {
+		<-cs.Context().Done()
+		ts.once.Do(ts.done)
+	}()
+	return ts, nil
+}
+
+// trackedStream calls done once the stream finishes.
+type trackedStream struct {
+	grpc.ClientStream
 
-	return streamer(ctx, desc, cc, method, opts...)
+	once sync.Once
+	done func()
+}
+
+func (s *trackedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(s.done)
+	}
+	return err
 }
 
 // refreshConn is invoked as a UnaryClientInterceptor that will refresh the
@@ -68,13 +161,86 @@ func (p *Pool) unaryRefreshConn(
 	ctx context.Context, method string, req, reply interface{},
 	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
 ) error {
+	done := p.startCall(cc)
+	defer done()
+
+	start := p.now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	p.RecordResult(cc.Target(), err)
+	if err == nil {
+		p.recordLatency(cc.Target(), p.now().Sub(start))
+	}
+	return err
+}
+
+// startCall refreshes the last used time of cc and tracks a new in-flight
+// call. The returned function must be called when the call completes.
+func (p *Pool) startCall(cc *grpc.ClientConn) (done func()) {
 	p.mut.Lock()
-	if pc, ok := p.connLookup[cc]; ok {
-		pc.LastUsed = time.Now()
+	defer p.mut.Unlock()
+
+	pc, ok := p.connLookup[cc]
+	if !ok {
+		return func() {}
 	}
-	p.mut.Unlock()
+	pc.LastUsed = p.now()
+	pc.InFlight++
+
+	return func() {
+		p.mut.Lock()
+		defer p.mut.Unlock()
 
-	return invoker(ctx, method, req, reply, cc, opts...)
+		pc.InFlight--
+		p.closeIfRetired(pc)
+	}
+}
+
+// SetMaxConnAge sets the maximum age of connections in the pool. Connections
+// older than age will be gracefully replaced when they are next retrieved.
+// An age of 0 disables replacing connections.
+func (p *Pool) SetMaxConnAge(age time.Duration) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.maxAge = age
+}
+
+// SetClock sets the clock used to track the age and usage of connections
+// and to run the reaper. Must be called before the Pool is used.
+func (p *Pool) SetClock(c clock.Clock) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.clock, p.now = c, c.Now
+}
+
+// SetDialer sets the function used to create new connections. Existing
+// connections are unaffected. Set dial to nil to use grpc.Dial.
+func (p *Pool) SetDialer(dial DialFunc) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if dial == nil {
+		dial = grpc.Dial
+	}
+	p.dial = dial
+}
+
+// SetMaxConnsFunc sets a function which returns the maximum number of
+// connections in the pool, overriding the maximum passed to New. f is
+// called with the pool locked whenever a new connection is opened, so it
+// must not use the pool. Set f to nil to use the maximum passed to New.
+func (p *Pool) SetMaxConnsFunc(f func() int) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.maxConnsFn = f
+}
+
+// limit returns the maximum number of connections. Must be called with the
+// mutex held.
+func (p *Pool) limit() int {
+	if p.maxConnsFn != nil {
+		return p.maxConnsFn()
+	}
+	return p.maxConns
 }
 
 // Get retrieves a cached addr or creates a new connection.
@@ -83,52 +249,142 @@ func (p *Pool) Get(addr string) (*grpc.ClientConn, error) {
 	defer p.mut.Unlock()
 
 	if c, ok := p.conns[addr]; ok && c != nil {
-		c.LastUsed = time.Now()
-		return c.Conn, nil
+		if p.maxAge == 0 || p.now().Sub(c.Created) < p.maxAge {
+			c.LastUsed = p.now()
+			return c.Conn, nil
+		}
+
+		// The connection is too old; retire it and replace it with a new
+		// one. It will be closed once in-flight calls complete.
+		delete(p.conns, addr)
+		p.retireConn(c)
+		p.evicted(EvictMaxAge)
 	}
 
-	conn, err := grpc.Dial(addr, p.opts...)
+	p.dials++
+	conn, err := p.dial(addr, p.opts...)
 	if err != nil {
+		p.dialFailures++
 		return nil, err
 	}
 	p.conns[addr] = &poolConn{
 		Conn:     conn,
-		LastUsed: time.Now(),
+		Created:  p.now(),
+		LastUsed: p.now(),
 	}
 	p.connLookup[conn] = p.conns[addr]
 
-	if len(p.conns) > p.maxConns {
+	if len(p.conns) > p.limit() {
 		p.cleanupOldest()
 	}
 
 	return conn, err
 }
 
+// GetReady is like Get, but waits for the connection to become ready before
+// returning it. Connections are dialed lazily, so Get will rarely fail even
+// if addr is unreachable; GetReady allows callers to detect unreachable
+// addresses up front.
+//
+// An error is returned if the connection fails. ctx.Err() is returned if ctx
+// is canceled before the connection becomes ready.
+func (p *Pool) GetReady(ctx context.Context, addr string) (*grpc.ClientConn, error) {
+	cc, err := p.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		s := cc.GetState()
+		switch s {
+		case connectivity.Ready:
+			return cc, nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			p.dialFailed()
+			return nil, fmt.Errorf("connection to %s is not ready: %s", addr, s)
+		}
+
+		// Giving up on ctx says nothing about addr, so it's not counted as a
+		// failed dial.
+		if !cc.WaitForStateChange(ctx, s) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
 // cleanupOldest should only be called when the mutex is held.
 func (p *Pool) cleanupOldest() {
 	var (
-		oldest     = time.Now().Add(time.Hour * 24 * 365)
-		oldestAddr *string
+		oldest     = p.now().Add(time.Hour * 24 * 365)
+		oldestAddr string
+		found      bool
 	)
 	for addr, conn := range p.conns {
 		if conn.LastUsed.Before(oldest) {
 			oldest = conn.LastUsed
-			oldestAddr = &addr
+			oldestAddr, found = addr, true
 		}
 	}
-	if oldestAddr != nil {
-		_ = p.conns[*oldestAddr].Conn.Close()
-		delete(p.connLookup, p.conns[*oldestAddr].Conn)
-		delete(p.conns, *oldestAddr)
+	if found {
+		_ = p.conns[oldestAddr].Conn.Close()
+		delete(p.connLookup, p.conns[oldestAddr].Conn)
+		delete(p.conns, oldestAddr)
+		p.evicted(EvictMaxConns)
+	}
+}
+
+// retireConn marks pc as retired. pc is closed once the grace period passed
+// and there are no in-flight calls. pc must have been removed from p.conns.
+// Must be called with the mutex held.
+func (p *Pool) retireConn(pc *poolConn) {
+	pc.Retired = true
+
+	var (
+		t    = p.clock.NewTimer(p.retireGrace)
+		quit = p.quit
+	)
+	go func() {
+		select {
+		case <-quit:
+			t.Stop()
+			return
+		case <-t.C():
+		}
+
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		pc.GraceOver = true
+		p.closeIfRetired(pc)
+	}()
+}
+
+// closeIfRetired closes pc if it's retired, its grace period passed, and it
+// has no in-flight calls. Must be called with the mutex held.
+func (p *Pool) closeIfRetired(pc *poolConn) {
+	if pc.Retired && pc.GraceOver && pc.InFlight == 0 {
+		p.closeConn(pc)
 	}
 }
 
+// closeConn closes pc and removes it from the lookup table. Must be called
+// with the mutex held.
+func (p *Pool) closeConn(pc *poolConn) {
+	_ = pc.Conn.Close()
+	delete(p.connLookup, pc.Conn)
+}
+
 // Remove deletes a conn from the pool.
 func (p *Pool) Remove(addr string) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
 	if c, ok := p.conns[addr]; ok {
 		// TODO(rfratto): will this let the existing conn be re-used?
 		_ = c.Conn.Close()
 		delete(p.connLookup, c.Conn)
 		delete(p.conns, addr)
+		p.evicted(EvictRemoved)
 	}
+	delete(p.breakers, addr)
+	delete(p.latencies, addr)
 }
@@ -0,0 +1,318 @@
+package connpool
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rfratto/croissant/clock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestPool_GetReady(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	p := New(5, grpc.WithInsecure())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cc, err := p.GetReady(ctx, lis.Addr().String())
+	require.NoError(t, err)
+	require.NotNil(t, cc)
+}
+
+func TestPool_SetDialer(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	var dialed []string
+
+	p := New(5, grpc.WithInsecure())
+	p.SetDialer(func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		dialed = append(dialed, addr)
+		opts = append(opts, grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+		return grpc.Dial(addr, opts...)
+	})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := p.GetReady(ctx, "in-process")
+	require.NoError(t, err)
+	require.Equal(t, []string{"in-process"}, dialed)
+}
+
+func TestPool_GetReady_Unreachable(t *testing.T) {
+	// Grab an address that nothing is listening on.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	p := New(5, grpc.WithInsecure())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = p.Get(addr)
+	require.NoError(t, err, "Get should succeed since dialing is lazy")
+
+	_, err = p.GetReady(ctx, addr)
+	require.Error(t, err)
+	require.NoError(t, ctx.Err(), "GetReady should fail before the context is canceled")
+}
+
+func TestPool_GetReady_Canceled(t *testing.T) {
+	// Connections to a listener which never accepts stay connecting.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	p := New(5, grpc.WithInsecure())
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = p.GetReady(ctx, lis.Addr().String())
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Zero(t, p.Stats().DialFailures, "giving up shouldn't count as a failed dial")
+}
+
+func TestPool_MaxConnAge(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	p := New(5, grpc.WithInsecure())
+	p.SetMaxConnAge(10 * time.Millisecond)
+	p.retireGrace = 50 * time.Millisecond
+	defer p.Close()
+
+	first, err := p.Get(lis.Addr().String())
+	require.NoError(t, err)
+
+	again, err := p.Get(lis.Addr().String())
+	require.NoError(t, err)
+	require.True(t, first == again, "connection should be reused before max age")
+
+	time.Sleep(20 * time.Millisecond)
+
+	replaced, err := p.Get(lis.Addr().String())
+	require.NoError(t, err)
+	require.True(t, first != replaced, "connection should be replaced after max age")
+	require.NotEqual(t, connectivity.Shutdown, first.GetState(), "replaced connection should be kept open during grace period")
+	require.Eventually(t, func() bool {
+		return first.GetState() == connectivity.Shutdown
+	}, time.Second, 10*time.Millisecond, "idle connection should be closed after grace period")
+}
+
+func TestPool_RetireGrace(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	clk := clock.NewFake(time.Now())
+
+	p := New(5, grpc.WithInsecure())
+	p.SetClock(clk)
+	p.SetMaxConnAge(time.Minute)
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Retrieve a connection and only use it after it was replaced.
+	held, err := p.Get(lis.Addr().String())
+	require.NoError(t, err)
+
+	clk.Advance(2 * time.Minute)
+	replaced, err := p.Get(lis.Addr().String())
+	require.NoError(t, err)
+	require.True(t, held != replaced)
+
+	_, err = healthpb.NewHealthClient(held).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err, "connection retrieved before being replaced should be usable during the grace period")
+
+	clk.Advance(retireGrace)
+	require.Eventually(t, func() bool {
+		return held.GetState() == connectivity.Shutdown
+	}, 5*time.Second, 10*time.Millisecond, "connection should be closed after the grace period")
+}
+
+func TestPool_StreamCanceled(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	p := New(5, grpc.WithInsecure())
+	defer p.Close()
+
+	addr := lis.Addr().String()
+	cc, err := p.Get(addr)
+	require.NoError(t, err)
+
+	inFlight := func() int {
+		p.mut.RLock()
+		defer p.mut.RUnlock()
+		return p.conns[addr].InFlight
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = healthpb.NewHealthClient(cc).Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, inFlight())
+
+	// Cancel the stream without ever reading from it.
+	cancel()
+	require.Eventually(t, func() bool {
+		return inFlight() == 0
+	}, 5*time.Second, 10*time.Millisecond, "canceled stream should no longer be in-flight")
+}
+
+func TestPool_Reap(t *testing.T) {
+	now := time.Now()
+
+	p := New(5, grpc.WithInsecure())
+	p.now = func() time.Time { return now }
+	p.retireGrace = 0
+	defer p.Close()
+
+	for _, addr := range []string{"idle:80", "busy:80", "used:80", "removed:80"} {
+		_, err := p.Get(addr)
+		require.NoError(t, err)
+	}
+
+	busy, err := p.Get("busy:80")
+	require.NoError(t, err)
+	done := p.startCall(busy)
+
+	now = now.Add(time.Minute)
+	_, err = p.Get("used:80")
+	require.NoError(t, err)
+
+	reaped := p.Reap(ReapConfig{
+		Interval:    10 * time.Second,
+		IdleTimeout: 30 * time.Second,
+		Keep:        func(addr string) bool { return addr != "removed:80" },
+	})
+	require.Equal(t, 2, reaped)
+	require.Len(t, p.conns, 2)
+	require.Contains(t, p.conns, "busy:80", "connections with in-flight calls must not be reaped")
+	require.Contains(t, p.conns, "used:80")
+
+	// Once its call completes, the busy connection is idle.
+	done()
+	require.Equal(t, 1, p.Reap(ReapConfig{IdleTimeout: 30 * time.Second}))
+	require.Eventually(t, func() bool {
+		return busy.GetState() == connectivity.Shutdown
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPool_Stats(t *testing.T) {
+	now := time.Now()
+
+	p := New(1, grpc.WithInsecure())
+	p.now = func() time.Time { return now }
+	defer p.Close()
+
+	for _, addr := range []string{"a:80", "b:80", "c:80"} {
+		now = now.Add(time.Second)
+		_, err := p.Get(addr)
+		require.NoError(t, err)
+	}
+	p.Remove("c:80")
+
+	s := p.Stats()
+	require.Equal(t, 3, s.Dials)
+	require.Equal(t, map[string]int{EvictMaxConns: 2, EvictRemoved: 1}, s.Evictions)
+	require.Empty(t, s.Conns)
+
+	_, err := p.Get("d:80")
+	require.NoError(t, err)
+	s = p.Stats()
+	require.Len(t, s.Conns, 1)
+	require.Equal(t, "d:80", s.Conns[0].Addr)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(p)
+	expect := `
+# HELP croissant_pool_dials_total Total number of connections dialed by the connection pool
+# TYPE croissant_pool_dials_total counter
+croissant_pool_dials_total 4
+# HELP croissant_pool_evictions_total Total number of connections evicted from the connection pool, by reason
+# TYPE croissant_pool_evictions_total counter
+croissant_pool_evictions_total{reason="idle"} 0
+croissant_pool_evictions_total{reason="max_age"} 0
+croissant_pool_evictions_total{reason="max_conns"} 2
+croissant_pool_evictions_total{reason="removed"} 1
+croissant_pool_evictions_total{reason="unneeded"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expect), "croissant_pool_dials_total", "croissant_pool_evictions_total"))
+}
+
+func TestPool_Latency(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	p := New(5, grpc.WithInsecure())
+	defer p.Close()
+
+	addr := lis.Addr().String()
+	_, ok := p.Latency(addr)
+	require.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cc, err := p.GetReady(ctx, addr)
+	require.NoError(t, err)
+	_, err = healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	latency, ok := p.Latency(addr)
+	require.True(t, ok)
+	require.Greater(t, int64(latency), int64(0))
+
+	// Removing the address forgets its latency.
+	p.Remove(addr)
+	_, ok = p.Latency(addr)
+	require.False(t, ok)
+}
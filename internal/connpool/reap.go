@@ -0,0 +1,129 @@
+package connpool
+
+import "time"
+
+// ReapConfig configures closing unneeded connections in the background.
+// Connections with in-flight calls are never closed by the reaper. Reaped
+// connections are removed from the Pool right away, but are closed after
+// the same grace period as connections replaced for their age.
+type ReapConfig struct {
+	// Interval is how often connections are checked.
+	Interval time.Duration
+
+	// IdleTimeout, if set, closes connections which weren't used for longer
+	// than IdleTimeout.
+	IdleTimeout time.Duration
+
+	// Keep, if set, is called with the address of every connection.
+	// Connections to addresses for which Keep returns false are closed once
+	// they weren't used for Interval, even if IdleTimeout didn't pass yet.
+	Keep func(addr string) bool
+}
+
+// StartReaper starts closing unneeded connections in the background as
+// configured by cfg. The reaper runs until the Pool is closed. Calling
+// StartReaper again replaces the previous reaper.
+func (p *Pool) StartReaper(cfg ReapConfig) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.stopReaper != nil {
+		close(p.stopReaper)
+	}
+	stop := make(chan struct{})
+	p.stopReaper = stop
+
+	t := p.clock.NewTicker(cfg.Interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C():
+				p.Reap(cfg)
+			}
+		}
+	}()
+}
+
+// Reap closes the connections which aren't needed according to cfg.
+// Returns the number of closed connections.
+func (p *Pool) Reap(cfg ReapConfig) int {
+	// Keep is called without the mutex held, since it may be slow.
+	p.mut.RLock()
+	addrs := make([]string, 0, len(p.conns))
+	for addr := range p.conns {
+		addrs = append(addrs, addr)
+	}
+	p.mut.RUnlock()
+
+	drop := make(map[string]struct{})
+	if cfg.Keep != nil {
+		for _, addr := range addrs {
+			if !cfg.Keep(addr) {
+				drop[addr] = struct{}{}
+			}
+		}
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	var (
+		now    = p.now()
+		reaped int
+	)
+	for addr, pc := range p.conns {
+		if pc.InFlight > 0 {
+			continue
+		}
+
+		var (
+			unused    = now.Sub(pc.LastUsed)
+			_, unkept = drop[addr]
+			unneeded  = unkept && unused >= cfg.Interval
+			idle      = cfg.IdleTimeout > 0 && unused > cfg.IdleTimeout
+		)
+		if !unneeded && !idle {
+			continue
+		}
+
+		delete(p.conns, addr)
+		p.retireConn(pc)
+		reaped++
+
+		if unneeded {
+			p.evicted(EvictUnneeded)
+		} else {
+			p.evicted(EvictIdle)
+		}
+	}
+	return reaped
+}
+
+// Close stops the reaper and closes every connection in the Pool. The Pool
+// must not be used after calling Close.
+func (p *Pool) Close() error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.stopReaper != nil {
+		close(p.stopReaper)
+		p.stopReaper = nil
+	}
+	select {
+	case <-p.quit:
+	default:
+		close(p.quit)
+	}
+
+	// connLookup includes retired connections which weren't closed yet.
+	for _, pc := range p.connLookup {
+		p.closeConn(pc)
+	}
+	p.conns = make(map[string]*poolConn)
+	return nil
+}
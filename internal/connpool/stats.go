@@ -0,0 +1,143 @@
+package connpool
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Reasons connections were evicted from the Pool.
+const (
+	EvictMaxConns = "max_conns" // The Pool had too many connections.
+	EvictMaxAge   = "max_age"   // The connection was older than the maximum age.
+	EvictIdle     = "idle"      // The connection was idle for too long.
+	EvictUnneeded = "unneeded"  // The address of the connection wasn't kept.
+	EvictRemoved  = "removed"   // The address was removed with Remove.
+)
+
+// Stats is a snapshot of the connections of a Pool.
+type Stats struct {
+	// Dials is the number of connections dialed by the Pool.
+	Dials int
+	// DialFailures is the number of connections that failed to be dialed or
+	// to become ready.
+	DialFailures int
+	// Evictions is the number of connections evicted from the Pool, by
+	// reason.
+	Evictions map[string]int
+
+	// Conns holds the open connections of the Pool, sorted by address.
+	Conns []ConnStats
+}
+
+// ConnStats describes a connection in a Pool.
+type ConnStats struct {
+	Addr     string
+	State    connectivity.State
+	Created  time.Time
+	LastUsed time.Time
+	InFlight int // Number of in-flight calls.
+}
+
+// Stats returns a snapshot of the connections of the Pool.
+func (p *Pool) Stats() Stats {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+
+	s := Stats{
+		Dials:        p.dials,
+		DialFailures: p.dialFailures,
+		Evictions:    make(map[string]int, len(p.evictions)),
+		Conns:        make([]ConnStats, 0, len(p.conns)),
+	}
+	for reason, n := range p.evictions {
+		s.Evictions[reason] = n
+	}
+	for addr, pc := range p.conns {
+		s.Conns = append(s.Conns, ConnStats{
+			Addr:     addr,
+			State:    pc.Conn.GetState(),
+			Created:  pc.Created,
+			LastUsed: pc.LastUsed,
+			InFlight: pc.InFlight,
+		})
+	}
+	sort.Slice(s.Conns, func(i, j int) bool { return s.Conns[i].Addr < s.Conns[j].Addr })
+	return s
+}
+
+// evicted records the eviction of a connection. Must be called with the
+// mutex held.
+func (p *Pool) evicted(reason string) {
+	if p.evictions == nil {
+		p.evictions = make(map[string]int)
+	}
+	p.evictions[reason]++
+}
+
+// dialFailed records a connection that failed to be dialed or to become
+// ready.
+func (p *Pool) dialFailed() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.dialFailures++
+}
+
+var (
+	connsDesc = prometheus.NewDesc(
+		"croissant_pool_connections",
+		"Current number of connections in the connection pool, by connectivity state",
+		[]string{"state"}, nil,
+	)
+	dialsDesc = prometheus.NewDesc(
+		"croissant_pool_dials_total",
+		"Total number of connections dialed by the connection pool",
+		nil, nil,
+	)
+	dialFailuresDesc = prometheus.NewDesc(
+		"croissant_pool_dial_failures_total",
+		"Total number of connections that failed to be dialed or to become ready",
+		nil, nil,
+	)
+	evictionsDesc = prometheus.NewDesc(
+		"croissant_pool_evictions_total",
+		"Total number of connections evicted from the connection pool, by reason",
+		[]string{"reason"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (p *Pool) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connsDesc
+	ch <- dialsDesc
+	ch <- dialFailuresDesc
+	ch <- evictionsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (p *Pool) Collect(ch chan<- prometheus.Metric) {
+	s := p.Stats()
+
+	states := make(map[connectivity.State]int)
+	for _, c := range s.Conns {
+		states[c.State]++
+	}
+	for _, state := range []connectivity.State{
+		connectivity.Idle,
+		connectivity.Connecting,
+		connectivity.Ready,
+		connectivity.TransientFailure,
+		connectivity.Shutdown,
+	} {
+		ch <- prometheus.MustNewConstMetric(connsDesc, prometheus.GaugeValue, float64(states[state]), strings.ToLower(state.String()))
+	}
+
+	ch <- prometheus.MustNewConstMetric(dialsDesc, prometheus.CounterValue, float64(s.Dials))
+	ch <- prometheus.MustNewConstMetric(dialFailuresDesc, prometheus.CounterValue, float64(s.DialFailures))
+	for _, reason := range []string{EvictMaxConns, EvictMaxAge, EvictIdle, EvictUnneeded, EvictRemoved} {
+		ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(s.Evictions[reason]), reason)
+	}
+}
@@ -0,0 +1,5 @@
+// Package handoffpb holds protobuf descriptions for transferring application
+// data between nodes of a Croissant cluster.
+package handoffpb
+
+//go:generate protoc -I=../../api --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ../../api/handoff.proto
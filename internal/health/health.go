@@ -3,6 +3,7 @@
 package health
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -10,8 +11,11 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/clock"
 	"github.com/rfratto/croissant/internal/api"
 	"github.com/rfratto/croissant/internal/connpool"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"google.golang.org/grpc"
 )
 
 type metrics struct {
@@ -71,11 +75,25 @@ type Config struct {
 	// Maximum number of times a check can fail before the next failure marks as
 	// dead. 0 = dead at the first failure.
 	MaxFailures int
+	// CheckFunc performs each check. Defaults to CheckState if unset.
+	CheckFunc CheckFunc
+	// Clock schedules checks. Defaults to the system clock if unset.
+	Clock clock.Clock
 
 	Log        log.Logger
 	Registerer prometheus.Registerer
 }
 
+// CheckFunc checks the health of the node d, using cc to communicate with it.
+// d is healthy if CheckFunc returns nil.
+type CheckFunc func(ctx context.Context, cc *grpc.ClientConn, d api.Descriptor) error
+
+// CheckState is a CheckFunc that requests the state of the node.
+func CheckState(ctx context.Context, cc *grpc.ClientConn, d api.Descriptor) error {
+	_, err := nodepb.NewNodeClient(cc).GetState(ctx, &nodepb.GetStateRequest{})
+	return err
+}
+
 // Checker is a node health checker. Checker is given a full set of nodes to
 // actively perform checks against.
 type Checker struct {
@@ -103,6 +121,12 @@ func NewChecker(cfg Config, p *connpool.Pool, w Watcher) *Checker {
 		cfg.Log = log.NewNopLogger()
 	}
 	cfg.Log = log.With(cfg.Log, "component", "node_health_checker")
+	if cfg.CheckFunc == nil {
+		cfg.CheckFunc = CheckState
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
 
 	c := &Checker{
 		cfg:     cfg,
@@ -131,12 +155,14 @@ Outer:
 	for {
 		select {
 		case <-c.stop:
-			// Stop all the jobs. This can only happen from Close, where the lock is already held.
+			// Stop all the jobs.
+			c.mut.Lock()
 			for key, j := range c.jobs {
 				level.Debug(c.cfg.Log).Log("msg", "stopping health-tracking for node", "addr", j.cfg.Node.Addr)
 				j.Stop()
 				delete(c.jobs, key)
 			}
+			c.mut.Unlock()
 			break Outer
 
 		case ds := <-c.dsChan:
@@ -184,11 +210,9 @@ Outer:
 //
 // Fails if the checker is closed.
 func (c *Checker) CheckNodes(ds []api.Descriptor) error {
-	c.mut.RLock()
-	defer c.mut.RUnlock()
-
+	// The lock isn't held here: run needs it to process ds.
 	select {
-	case <-c.done:
+	case <-c.stop:
 		return fmt.Errorf("Checker closed")
 	default:
 	}
@@ -198,9 +222,13 @@ func (c *Checker) CheckNodes(ds []api.Descriptor) error {
 		key := descriptorKey(d)
 		dsMap[key] = d
 	}
-	c.dsChan <- dsMap
 
-	return nil
+	select {
+	case c.dsChan <- dsMap:
+		return nil
+	case <-c.stop:
+		return fmt.Errorf("Checker closed")
+	}
 }
 
 func descriptorKey(d api.Descriptor) string {
@@ -222,18 +250,33 @@ func (c *Checker) SetHealth(ds api.Descriptor, h api.Health) error {
 	return fmt.Errorf("descriptor not being checked")
 }
 
+// LastSeen returns the last time a health check of d succeeded. The time is
+// zero if no check has succeeded yet. ok is false if d isn't being checked.
+func (c *Checker) LastSeen(d api.Descriptor) (lastSeen time.Time, ok bool) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	j, ok := c.jobs[descriptorKey(d)]
+	if !ok {
+		return time.Time{}, false
+	}
+	return j.LastSeen(), true
+}
+
 // Close stops the Checker. Fails if the Checker is already closed.
 func (c *Checker) Close() error {
 	c.mut.Lock()
-	defer c.mut.Unlock()
-
 	select {
-	case <-c.done:
+	case <-c.stop:
+		c.mut.Unlock()
 		return fmt.Errorf("Checker closed")
 	default:
 	}
-
 	close(c.stop)
+
+	// Don't hold the lock while waiting for run to exit, since it may be
+	// waiting on the lock to process a CheckNodes call.
+	c.mut.Unlock()
 	<-c.done
 
 	c.metrics.Unregister(c.cfg.Registerer)
@@ -2,6 +2,7 @@ package health
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -65,3 +66,51 @@ func TestChecker(t *testing.T) {
 	case <-time.After(2 * time.Second):
 	}
 }
+
+func TestChecker_CheckFunc(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	defer srv.Stop()
+	go srv.Serve(lis)
+
+	d := api.Descriptor{
+		ID:   id.Zero,
+		Addr: lis.Addr().String(),
+	}
+
+	checkedCh := make(chan api.Descriptor, 10)
+	healthCh := make(chan api.Health, 10)
+
+	checker := NewChecker(Config{
+		CheckFrequency: 100 * time.Millisecond,
+		CheckTimeout:   time.Second,
+		MaxFailures:    1,
+		CheckFunc: func(ctx context.Context, cc *grpc.ClientConn, d api.Descriptor) error {
+			checkedCh <- d
+			return fmt.Errorf("unhealthy")
+		},
+	}, connpool.New(100, grpc.WithInsecure()), &fakeWatcher{
+		OnHealthChanged: func(_ api.Descriptor, h api.Health) { healthCh <- h },
+	})
+	defer checker.Close()
+
+	require.NoError(t, checker.CheckNodes([]api.Descriptor{d}))
+
+	select {
+	case checked := <-checkedCh:
+		require.Equal(t, d, checked)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "expected CheckFunc to be called")
+	}
+
+	for _, expect := range []api.Health{api.Unhealthy, api.Dead} {
+		select {
+		case h := <-healthCh:
+			require.Equal(t, expect, h)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "expected health to change")
+		}
+	}
+}
@@ -7,9 +7,9 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/clock"
 	"github.com/rfratto/croissant/internal/api"
 	"github.com/rfratto/croissant/internal/connpool"
-	"github.com/rfratto/croissant/internal/nodepb"
 )
 
 type jobConfig struct {
@@ -30,20 +30,29 @@ type jobConfig struct {
 }
 
 type job struct {
-	cfg  jobConfig
-	done chan struct{}
+	cfg    jobConfig
+	done   chan struct{}
+	ticker clock.Ticker
 
 	mut            sync.Mutex
 	health         api.Health
 	failedAttempts int
+	lastSeen       time.Time // Time of the last successful check.
 }
 
 // newJob creates and starts a health check job. Call Stop to finish.
 func newJob(c jobConfig) *job {
+	if c.CheckConfig.CheckFunc == nil {
+		c.CheckConfig.CheckFunc = CheckState
+	}
+	if c.CheckConfig.Clock == nil {
+		c.CheckConfig.Clock = clock.Real()
+	}
 	j := &job{
 		cfg:    c,
 		health: api.Healthy,
 		done:   make(chan struct{}),
+		ticker: c.CheckConfig.Clock.NewTicker(c.CheckConfig.CheckFrequency),
 	}
 	go j.run()
 	return j
@@ -52,14 +61,13 @@ func newJob(c jobConfig) *job {
 func (j *job) run() {
 	defer j.cfg.OnDone()
 
-	t := time.NewTicker(j.cfg.CheckConfig.CheckFrequency)
-	defer t.Stop()
+	defer j.ticker.Stop()
 
 	for {
 		select {
 		case <-j.done:
 			return
-		case <-t.C:
+		case <-j.ticker.C():
 			j.doCheck()
 		}
 	}
@@ -78,8 +86,7 @@ func (j *job) doCheck() {
 		return
 	}
 
-	cli := nodepb.NewNodeClient(cc)
-	_, err = cli.GetState(ctx, &nodepb.GetStateRequest{})
+	err = j.cfg.CheckConfig.CheckFunc(ctx, cc, j.cfg.Node)
 	if err != nil {
 		level.Debug(j.cfg.Log).Log("msg", "node health check failed", "err", err)
 	}
@@ -94,6 +101,9 @@ func (j *job) processCheckResult(success bool) {
 
 	switch {
 	case success:
+		j.mut.Lock()
+		j.lastSeen = j.now()
+		j.mut.Unlock()
 		j.SetHealth(api.Healthy)
 
 	case !success && j.failedAttempts < j.cfg.CheckConfig.MaxFailures:
@@ -130,6 +140,22 @@ func (j *job) SetHealth(h api.Health) {
 	go j.cfg.Watcher.HealthChanged(j.cfg.Node, h)
 }
 
+// now returns the current time according to the configured clock.
+func (j *job) now() time.Time {
+	if j.cfg.CheckConfig.Clock == nil {
+		return time.Now()
+	}
+	return j.cfg.CheckConfig.Clock.Now()
+}
+
+// LastSeen returns the time of the last successful check. Zero if no check
+// has succeeded yet.
+func (j *job) LastSeen() time.Time {
+	j.mut.Lock()
+	defer j.mut.Unlock()
+	return j.lastSeen
+}
+
 // Stop stops the job. Only call once.
 func (j *job) Stop() {
 	close(j.done)
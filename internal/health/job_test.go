@@ -207,12 +207,20 @@ func TestJob_Transitions(t *testing.T) {
 type fakeService struct {
 	nodepb.UnimplementedNodeServer
 	OnGetState func(ctx context.Context, req *nodepb.GetStateRequest) (*nodepb.GetStateResponse, error)
+	OnPing     func(ctx context.Context, req *nodepb.PingRequest) (*nodepb.PingResponse, error)
 }
 
 func (f *fakeService) GetState(ctx context.Context, req *nodepb.GetStateRequest) (*nodepb.GetStateResponse, error) {
 	return f.OnGetState(ctx, req)
 }
 
+func (f *fakeService) Ping(ctx context.Context, req *nodepb.PingRequest) (*nodepb.PingResponse, error) {
+	if f.OnPing == nil {
+		return f.UnimplementedNodeServer.Ping(ctx, req)
+	}
+	return f.OnPing(ctx, req)
+}
+
 type fakeWatcher struct {
 	OnHealthChanged func(d api.Descriptor, h api.Health)
 }
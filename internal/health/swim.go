@@ -0,0 +1,552 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/clock"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/connpool"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxGossip is the maximum number of health changes piggybacked on a single
+// message.
+const maxGossip = 8
+
+// SWIMConfig configures a SWIM failure detector.
+type SWIMConfig struct {
+	// ProbeInterval is how often a peer is probed. Only one peer is probed
+	// per interval.
+	ProbeInterval time.Duration
+	// ProbeTimeout is the timeout for a direct probe. Indirect probes may use
+	// the remainder of ProbeInterval.
+	ProbeTimeout time.Duration
+	// IndirectProbes is the number of peers asked to probe a peer after a
+	// direct probe fails.
+	IndirectProbes int
+	// SuspicionTimeout is how long a peer is suspected (Unhealthy) before it
+	// is marked as Dead.
+	SuspicionTimeout time.Duration
+	// CheckFunc, if set, is performed after a successful direct Ping. The
+	// peer is treated as unreachable if CheckFunc fails.
+	CheckFunc CheckFunc
+	// Clock schedules probes and times out suspicions. Defaults to the
+	// system clock if unset.
+	Clock clock.Clock
+	// Rand determines the order peers are probed in. Seeded from the
+	// current time if unset. Only used by one goroutine at a time.
+	Rand *rand.Rand
+
+	Log        log.Logger
+	Registerer prometheus.Registerer
+}
+
+// SWIM is a failure detector based on SWIM. Instead of probing every peer on
+// an interval, SWIM probes a single peer per interval in a randomized
+// round-robin order. Peers that fail a direct probe are probed indirectly
+// through other peers before being suspected, and suspected peers are marked
+// dead after a timeout unless a later probe succeeds.
+//
+// Health changes are disseminated by piggybacking them on Pings. Health
+// learned from peers is never trusted directly: suspicions from peers only
+// cause the suspected peer to be probed sooner.
+type SWIM struct {
+	cfg     SWIMConfig
+	pool    *connpool.Pool
+	metrics *metrics
+	watcher Watcher
+
+	mut     sync.Mutex
+	members map[string]*member // Keyed through return of descriptorKey.
+	order   []string           // Probe order of members.
+	next    int                // Index of the next member in order to probe.
+	urgent  []string           // Members to probe before the next one in order.
+	gossip  []*gossipUpdate    // Health changes to piggyback.
+	rand    *rand.Rand
+
+	closeMut sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type member struct {
+	desc      api.Descriptor
+	health    api.Health
+	suspectAt time.Time
+	lastSeen  time.Time // Time of the last successful probe.
+}
+
+type gossipUpdate struct {
+	desc      api.Descriptor
+	health    api.Health
+	transmits int // Remaining number of times to piggyback the update.
+}
+
+// NewSWIM creates a new SWIM failure detector. The pool will be used for
+// retrieving gRPC clients. Health change events will be sent to the given
+// Watcher.
+//
+// SWIM will run in the background until Close is called.
+func NewSWIM(cfg SWIMConfig, p *connpool.Pool, w Watcher) *SWIM {
+	if cfg.Log == nil {
+		cfg.Log = log.NewNopLogger()
+	}
+	cfg.Log = log.With(cfg.Log, "component", "node_swim_detector")
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	if cfg.Rand == nil {
+		cfg.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	s := &SWIM{
+		cfg:     cfg,
+		pool:    p,
+		watcher: w,
+		metrics: newMetrics(cfg.Registerer),
+
+		members: make(map[string]*member),
+		rand:    cfg.Rand,
+
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go s.run(cfg.Clock.NewTicker(cfg.ProbeInterval))
+	return s
+}
+
+func (s *SWIM) run(t clock.Ticker) {
+	defer close(s.done)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C():
+			s.expireSuspects()
+			if d, ok := s.nextTarget(); ok {
+				s.probe(d)
+			}
+		}
+	}
+}
+
+// nextTarget returns the next member to probe. Members are probed in a
+// random order, which is reshuffled after every member has been probed.
+func (s *SWIM) nextTarget() (api.Descriptor, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for len(s.urgent) > 0 {
+		key := s.urgent[0]
+		s.urgent = s.urgent[1:]
+		if m, ok := s.members[key]; ok {
+			return m.desc, true
+		}
+	}
+
+	for attempts := 0; attempts <= len(s.order); attempts++ {
+		if s.next >= len(s.order) {
+			s.rand.Shuffle(len(s.order), func(i, j int) {
+				s.order[i], s.order[j] = s.order[j], s.order[i]
+			})
+			s.next = 0
+		}
+		if len(s.order) == 0 {
+			break
+		}
+
+		key := s.order[s.next]
+		s.next++
+		if m, ok := s.members[key]; ok {
+			return m.desc, true
+		}
+	}
+	return api.Descriptor{}, false
+}
+
+// probe checks the health of d, first directly and then indirectly through
+// other members.
+func (s *SWIM) probe(d api.Descriptor) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ProbeTimeout)
+	err := s.ping(ctx, d, nil)
+	if err != nil {
+		level.Debug(s.cfg.Log).Log("msg", "direct probe failed, probing indirectly", "peer", d.Addr, "err", err)
+		err = s.probeIndirect(d)
+	} else if s.cfg.CheckFunc != nil {
+		// The peer is reachable, so there's no need for indirect probes if
+		// CheckFunc fails.
+		err = s.check(ctx, d)
+	}
+	cancel()
+
+	s.metrics.checksTotal.Inc()
+	if err != nil {
+		level.Debug(s.cfg.Log).Log("msg", "node health check failed", "peer", d.Addr, "err", err)
+		s.metrics.failedChecksTotal.Inc()
+		s.SetHealth(d, api.Unhealthy)
+		return
+	}
+
+	s.mut.Lock()
+	if m, ok := s.members[descriptorKey(d)]; ok {
+		m.lastSeen = s.cfg.Clock.Now()
+	}
+	s.mut.Unlock()
+	s.SetHealth(d, api.Healthy)
+}
+
+// probeIndirect asks up to IndirectProbes random members to ping d. Succeeds
+// if any of them could reach d.
+func (s *SWIM) probeIndirect(d api.Descriptor) error {
+	helpers := s.randomMembers(s.cfg.IndirectProbes, d)
+	if len(helpers) == 0 {
+		return fmt.Errorf("no members available for indirect probe")
+	}
+
+	timeout := s.cfg.ProbeInterval - s.cfg.ProbeTimeout
+	if timeout < s.cfg.ProbeTimeout {
+		timeout = s.cfg.ProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results := make(chan error, len(helpers))
+	for _, h := range helpers {
+		go func(h api.Descriptor) {
+			results <- s.ping(ctx, h, &d)
+		}(h)
+	}
+
+	var lastErr error
+	for range helpers {
+		err := <-results
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// ping sends a Ping to d, asking it to ping target if target is non-nil.
+// Health piggybacked on the response is merged.
+func (s *SWIM) ping(ctx context.Context, d api.Descriptor, target *api.Descriptor) error {
+	cc, err := s.pool.Get(d.Addr)
+	if err != nil {
+		return err
+	}
+
+	health, err := nodepb.ToAPI(nodepb.NewNodeClient(cc)).Ping(ctx, api.Ping{
+		Target: target,
+		Health: s.Gossip(),
+	})
+	if status.Code(err) == codes.Unimplemented && target == nil {
+		// Peers that don't support Ping can still be probed directly.
+		return Probe(ctx, s.pool, d)
+	} else if err != nil {
+		return err
+	}
+	s.MergeGossip(health)
+	return nil
+}
+
+// check runs CheckFunc against d.
+func (s *SWIM) check(ctx context.Context, d api.Descriptor) error {
+	cc, err := s.pool.Get(d.Addr)
+	if err != nil {
+		return err
+	}
+	return s.cfg.CheckFunc(ctx, cc, d)
+}
+
+// randomMembers returns up to n random members, excluding exclude.
+func (s *SWIM) randomMembers(n int, exclude api.Descriptor) []api.Descriptor {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var res []api.Descriptor
+	for _, idx := range s.rand.Perm(len(s.order)) {
+		if len(res) >= n {
+			break
+		}
+		m, ok := s.members[s.order[idx]]
+		if !ok || m.desc == exclude || m.health != api.Healthy {
+			continue
+		}
+		res = append(res, m.desc)
+	}
+	return res
+}
+
+// expireSuspects marks members that have been suspected for longer than
+// SuspicionTimeout as dead.
+func (s *SWIM) expireSuspects() {
+	var expired []api.Descriptor
+
+	s.mut.Lock()
+	for _, m := range s.members {
+		if m.health == api.Unhealthy && clock.Since(s.cfg.Clock, m.suspectAt) >= s.cfg.SuspicionTimeout {
+			expired = append(expired, m.desc)
+		}
+	}
+	s.mut.Unlock()
+
+	for _, d := range expired {
+		level.Debug(s.cfg.Log).Log("msg", "suspicion timed out", "peer", d.Addr)
+		s.SetHealth(d, api.Dead)
+	}
+}
+
+// CheckNodes will update the set of nodes being checked for health. Subsequent
+// calls to CheckNodes will stop checking nodes that have been removed from ds
+// in between calls.
+//
+// Fails if the detector is closed.
+func (s *SWIM) CheckNodes(ds []api.Descriptor) error {
+	select {
+	case <-s.done:
+		return fmt.Errorf("SWIM closed")
+	default:
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	keep := make(map[string]struct{}, len(ds))
+	for _, d := range ds {
+		key := descriptorKey(d)
+		keep[key] = struct{}{}
+
+		if _, found := s.members[key]; !found {
+			level.Debug(s.cfg.Log).Log("msg", "health-tracking node", "addr", d.Addr)
+			s.members[key] = &member{desc: d, health: api.Healthy}
+
+			// Insert new members at a random position after the next member to
+			// probe so they get probed within one round.
+			pos := s.next + s.rand.Intn(len(s.order)-s.next+1)
+			s.order = append(s.order, "")
+			copy(s.order[pos+1:], s.order[pos:])
+			s.order[pos] = key
+		}
+	}
+
+	for key, m := range s.members {
+		if _, found := keep[key]; !found {
+			level.Debug(s.cfg.Log).Log("msg", "stopping health-tracking for node", "addr", m.desc.Addr)
+			delete(s.members, key)
+		}
+	}
+
+	order := s.order[:0]
+	for i, key := range s.order {
+		if _, found := s.members[key]; !found {
+			if i < s.next {
+				s.next--
+			}
+			continue
+		}
+		order = append(order, key)
+	}
+	s.order = order
+
+	s.metrics.jobs.Set(float64(len(s.members)))
+	return nil
+}
+
+// SetHealth explicitly sets the health of a node and fires off the
+// HealthChanged event. This is useful when communicating with a node fails
+// and you wish to immediately mark it as suspicious.
+func (s *SWIM) SetHealth(d api.Descriptor, h api.Health) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	m, ok := s.members[descriptorKey(d)]
+	if !ok {
+		return fmt.Errorf("descriptor not being checked")
+	}
+
+	// Ignore if the health matches or if it's an invalid state transition.
+	// Dead can go to Healthy, but not Unhealthy.
+	if m.health == h || m.health == api.Dead && h == api.Unhealthy {
+		return nil
+	}
+
+	m.health = h
+	if h == api.Unhealthy {
+		m.suspectAt = s.cfg.Clock.Now()
+	}
+	s.enqueueGossip(d, h)
+
+	// Call HealthChanged in background so we can continue running checks.
+	go s.watcher.HealthChanged(d, h)
+	return nil
+}
+
+// LastSeen returns the last time a probe of d succeeded. The time is zero if
+// no probe has succeeded yet. ok is false if d isn't being checked.
+func (s *SWIM) LastSeen(d api.Descriptor) (lastSeen time.Time, ok bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	m, ok := s.members[descriptorKey(d)]
+	if !ok {
+		return time.Time{}, false
+	}
+	return m.lastSeen, true
+}
+
+// enqueueGossip queues a health change to be piggybacked on future messages.
+// Must be called with the lock held.
+func (s *SWIM) enqueueGossip(d api.Descriptor, h api.Health) {
+	// Each change is sent a number of times proportional to the log of the
+	// cluster size, which is enough for it to reach every member with high
+	// probability.
+	transmits := 3 * int(math.Ceil(math.Log2(float64(len(s.members)+1))))
+	if transmits < 1 {
+		transmits = 1
+	}
+
+	for _, u := range s.gossip {
+		if u.desc == d {
+			u.health, u.transmits = h, transmits
+			return
+		}
+	}
+	s.gossip = append(s.gossip, &gossipUpdate{desc: d, health: h, transmits: transmits})
+}
+
+// Gossip returns recent health changes to piggyback on a message.
+func (s *SWIM) Gossip() map[api.Descriptor]api.Health {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	res := make(map[api.Descriptor]api.Health)
+	for _, u := range s.gossip {
+		if len(res) >= maxGossip {
+			break
+		}
+		res[u.desc] = u.health
+		u.transmits--
+	}
+
+	gossip := s.gossip[:0]
+	for _, u := range s.gossip {
+		if u.transmits > 0 {
+			gossip = append(gossip, u)
+		}
+	}
+	s.gossip = gossip
+	return res
+}
+
+// MergeGossip merges health changes learned from a peer. Members that are
+// reported as unhealthy or dead are probed before any other member, and
+// members reported as dead are suspected until a probe succeeds.
+func (s *SWIM) MergeGossip(health map[api.Descriptor]api.Health) {
+	var suspects []api.Descriptor
+
+	s.mut.Lock()
+	for d, h := range health {
+		if !s.prioritize(d, h) {
+			continue
+		}
+		if h == api.Dead {
+			suspects = append(suspects, d)
+		}
+	}
+	s.mut.Unlock()
+
+	for _, d := range suspects {
+		level.Debug(s.cfg.Log).Log("msg", "suspecting peer reported dead by another peer", "peer", d.Addr)
+		s.SetHealth(d, api.Unhealthy)
+	}
+}
+
+// Prioritize probes members that are reported as unhealthy or dead in health
+// before any other member. Unlike MergeGossip, members are never suspected,
+// making Prioritize suitable for health that may be outdated, such as the
+// health in the State of a peer.
+func (s *SWIM) Prioritize(health map[api.Descriptor]api.Health) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for d, h := range health {
+		s.prioritize(d, h)
+	}
+}
+
+// prioritize queues d to be probed next if h is unhealthy and d is currently
+// healthy. Returns true if d was queued. Must be called with the lock held.
+func (s *SWIM) prioritize(d api.Descriptor, h api.Health) bool {
+	key := descriptorKey(d)
+	m, ok := s.members[key]
+	if !ok || h == api.Healthy || m.health != api.Healthy {
+		return false
+	}
+	for _, k := range s.urgent {
+		if k == key {
+			return false
+		}
+	}
+	s.urgent = append(s.urgent, key)
+	return true
+}
+
+// HandlePing handles a Ping sent by another SWIM detector.
+func (s *SWIM) HandlePing(ctx context.Context, self api.Descriptor, p api.Ping) (map[api.Descriptor]api.Health, error) {
+	s.MergeGossip(p.Health)
+
+	if p.Target != nil && *p.Target != self {
+		if err := s.ping(ctx, *p.Target, nil); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "target %s unreachable: %s", p.Target.Addr, err)
+		}
+	}
+	return s.Gossip(), nil
+}
+
+// Close stops the SWIM detector. Fails if it is already closed.
+func (s *SWIM) Close() error {
+	s.closeMut.Lock()
+	defer s.closeMut.Unlock()
+
+	select {
+	case <-s.done:
+		return fmt.Errorf("SWIM closed")
+	default:
+	}
+
+	close(s.stop)
+	<-s.done
+
+	s.metrics.Unregister(s.cfg.Registerer)
+	return nil
+}
+
+// Probe checks that d is reachable by sending it a Ping, falling back to
+// requesting its state if d doesn't support Ping.
+func Probe(ctx context.Context, p *connpool.Pool, d api.Descriptor) error {
+	cc, err := p.Get(d.Addr)
+	if err != nil {
+		return err
+	}
+
+	cli := nodepb.NewNodeClient(cc)
+	_, err = cli.Ping(ctx, &nodepb.PingRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		_, err = cli.GetState(ctx, &nodepb.GetStateRequest{})
+	}
+	return err
+}
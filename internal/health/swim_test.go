@@ -0,0 +1,168 @@
+package health
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/connpool"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSWIM_FallbackToGetState(t *testing.T) {
+	checkedCh := make(chan struct{}, 10)
+	d := startFakeService(t, 1, &fakeService{
+		OnGetState: func(ctx context.Context, req *nodepb.GetStateRequest) (*nodepb.GetStateResponse, error) {
+			checkedCh <- struct{}{}
+			return &nodepb.GetStateResponse{}, nil
+		},
+	})
+
+	w := &recordingWatcher{}
+	swim := NewSWIM(testSWIMConfig(), connpool.New(5, grpc.WithInsecure()), w)
+	defer swim.Close()
+	require.NoError(t, swim.CheckNodes([]api.Descriptor{d}))
+
+	select {
+	case <-checkedCh:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "expected check to be run")
+	}
+	require.Empty(t, w.Changes())
+
+	require.Eventually(t, func() bool {
+		lastSeen, ok := swim.LastSeen(d)
+		return ok && !lastSeen.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, ok := swim.LastSeen(api.Descriptor{ID: id.ID{Low: 99}, Addr: "unknown"})
+	require.False(t, ok)
+}
+
+func TestSWIM_IndirectProbe(t *testing.T) {
+	// target can't be reached directly, but helper can reach it as long as
+	// helperReachable is true.
+	target := startFakeService(t, 1, &fakeService{
+		OnPing: func(ctx context.Context, req *nodepb.PingRequest) (*nodepb.PingResponse, error) {
+			return nil, status.Errorf(codes.Unavailable, "unreachable")
+		},
+	})
+
+	helperReachable := atomic.NewBool(true)
+	helper := startFakeService(t, 2, &fakeService{
+		OnPing: func(ctx context.Context, req *nodepb.PingRequest) (*nodepb.PingResponse, error) {
+			if req.Target != nil && !helperReachable.Load() {
+				return nil, status.Errorf(codes.Unavailable, "unreachable")
+			}
+			return &nodepb.PingResponse{}, nil
+		},
+	})
+
+	w := &recordingWatcher{}
+	swim := NewSWIM(testSWIMConfig(), connpool.New(5, grpc.WithInsecure()), w)
+	defer swim.Close()
+	require.NoError(t, swim.CheckNodes([]api.Descriptor{target, helper}))
+
+	// Wait for both peers to be probed at least once.
+	time.Sleep(time.Second)
+	require.Empty(t, w.Changes(), "target should be reachable through helper")
+
+	helperReachable.Store(false)
+	require.Eventually(t, func() bool {
+		changes := w.Changes()
+		return len(changes) == 2 &&
+			changes[0] == healthChange{target, api.Unhealthy} &&
+			changes[1] == healthChange{target, api.Dead}
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestSWIM_Gossip(t *testing.T) {
+	var (
+		a = api.Descriptor{ID: id.ID{Low: 1}, Addr: "a"}
+		b = api.Descriptor{ID: id.ID{Low: 2}, Addr: "b"}
+	)
+
+	w := &recordingWatcher{}
+	cfg := testSWIMConfig()
+	cfg.ProbeInterval = time.Hour
+	swim := NewSWIM(cfg, connpool.New(5, grpc.WithInsecure()), w)
+	defer swim.Close()
+	require.NoError(t, swim.CheckNodes([]api.Descriptor{a, b}))
+
+	// Unhealthy peers from gossip are only prioritized, but dead peers are
+	// also suspected.
+	swim.MergeGossip(map[api.Descriptor]api.Health{a: api.Unhealthy, b: api.Dead})
+	require.Eventually(t, func() bool {
+		return len(w.Changes()) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []healthChange{{b, api.Unhealthy}}, w.Changes())
+
+	// Both peers should be probed before anything else.
+	var next []api.Descriptor
+	for i := 0; i < 2; i++ {
+		d, ok := swim.nextTarget()
+		require.True(t, ok)
+		next = append(next, d)
+	}
+	require.ElementsMatch(t, []api.Descriptor{a, b}, next)
+	require.Empty(t, swim.urgent)
+
+	// Our own suspicion of b should be disseminated.
+	require.Equal(t, map[api.Descriptor]api.Health{b: api.Unhealthy}, swim.Gossip())
+}
+
+func testSWIMConfig() SWIMConfig {
+	return SWIMConfig{
+		ProbeInterval:    100 * time.Millisecond,
+		ProbeTimeout:     50 * time.Millisecond,
+		IndirectProbes:   3,
+		SuspicionTimeout: 500 * time.Millisecond,
+	}
+}
+
+// startFakeService starts a gRPC server for svc and returns a descriptor for
+// it.
+func startFakeService(t *testing.T, low uint64, svc nodepb.NodeServer) api.Descriptor {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	nodepb.RegisterNodeServer(srv, svc)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return api.Descriptor{ID: id.ID{Low: low}, Addr: lis.Addr().String()}
+}
+
+type healthChange struct {
+	Desc   api.Descriptor
+	Health api.Health
+}
+
+type recordingWatcher struct {
+	mut     sync.Mutex
+	changes []healthChange
+}
+
+func (w *recordingWatcher) HealthChanged(d api.Descriptor, h api.Health) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	w.changes = append(w.changes, healthChange{d, h})
+}
+
+func (w *recordingWatcher) Changes() []healthChange {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return append([]healthChange(nil), w.changes...)
+}
@@ -4,12 +4,16 @@ import (
 	context "context"
 	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 	"github.com/rfratto/croissant/internal/idconv"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
@@ -41,27 +45,37 @@ type serverShim struct {
 }
 
 func (s *serverShim) Join(ctx context.Context, req *JoinRequest) (*emptypb.Empty, error) {
-	err := s.n.Join(ctx, descriptorToAPI(req.GetJoiner()))
+	err := s.n.Join(ctx, api.Join{
+		Joiner:  descriptorToAPI(req.GetJoiner()),
+		Cluster: req.GetCluster(),
+
+		ProtocolVersion: req.GetProtocolVersion(),
+		Path:            descriptorsToAPI(req.GetPath()),
+	})
 	return &emptypb.Empty{}, err
 }
 
 func (s *serverShim) Hello(ctx context.Context, req *HelloRequest) (*HelloResponse, error) {
-	var h api.Hello
-	h.Initiator = descriptorToAPI(req.GetInitiator())
-	if req.Next != nil {
-		next := descriptorToAPI(req.GetNext())
-		h.Next = &next
-	}
-	h.State = stateToAPI(req.GetState())
-	if req.GetAckId() > 0 {
-		h.StateAck = time.Unix(0, int64(req.GetAckId()))
-	}
+	return s.hello(ctx, helloToAPI(req))
+}
 
+func (s *serverShim) HelloDelta(ctx context.Context, req *HelloDeltaRequest) (*HelloResponse, error) {
+	return s.hello(ctx, helloDeltaToAPI(req))
+}
+
+func (s *serverShim) hello(ctx context.Context, h api.Hello) (*HelloResponse, error) {
 	err := s.n.NodeHello(ctx, h)
 
 	var resp HelloResponse
 	if sc := (api.ErrStateChanged{}); errors.As(err, &sc) {
-		resp.NewState = apiToState(sc.NewState)
+		if sc.Delta != nil {
+			resp.NewStateDelta = apiToDelta(sc.Delta)
+		} else {
+			resp.NewState = apiToState(sc.NewState)
+		}
+		err = nil
+	} else if errors.Is(err, api.ErrUnknownBase) {
+		resp.UnknownBase = true
 		err = nil
 	}
 
@@ -73,6 +87,11 @@ func (s *serverShim) Goodbye(ctx context.Context, req *GoodbyeRequest) (*emptypb
 	return &emptypb.Empty{}, err
 }
 
+func (s *serverShim) Evict(ctx context.Context, req *EvictRequest) (*emptypb.Empty, error) {
+	err := s.n.Evict(ctx, descriptorToAPI(req.GetNode()))
+	return &emptypb.Empty{}, err
+}
+
 func (s *serverShim) GetState(ctx context.Context, req *GetStateRequest) (*GetStateResponse, error) {
 	state, err := s.n.GetState(ctx)
 	if err != nil {
@@ -83,35 +102,168 @@ func (s *serverShim) GetState(ctx context.Context, req *GetStateRequest) (*GetSt
 	}, nil
 }
 
+func (s *serverShim) GetStateStream(req *GetStateRequest, stream Node_GetStateStreamServer) error {
+	state, err := s.n.GetState(stream.Context())
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunkState(apiToState(state), stateChunkEntries) {
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *serverShim) Ping(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+	var p api.Ping
+	if req.Target != nil {
+		target := descriptorToAPI(req.GetTarget())
+		p.Target = &target
+	}
+	p.Health = healthSetToAPI(req.GetHealthSet())
+
+	health, err := s.n.Ping(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &PingResponse{HealthSet: apiToHealthSet(health)}, nil
+}
+
+func (s *serverShim) Broadcast(ctx context.Context, req *BroadcastRequest) (*BroadcastResponse, error) {
+	results, err := s.n.Broadcast(ctx, api.Broadcast{
+		Method:  req.GetMethod(),
+		Payload: req.GetPayload(),
+		Level:   int(req.GetLevel()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &BroadcastResponse{Results: make([]*BroadcastResult, 0, len(results))}
+	for _, r := range results {
+		res := &BroadcastResult{Node: apiToDescriptor(r.Node)}
+		if r.Err != nil {
+			st := status.Convert(r.Err)
+			res.Code = int32(st.Code())
+			res.Message = st.Message()
+		} else {
+			res.Reply = r.Reply
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	return resp, nil
+}
+
+func (s *serverShim) TraceRoute(ctx context.Context, req *TraceRouteRequest) (*TraceRouteResponse, error) {
+	key := req.GetKey()
+	hops, err := s.n.TraceRoute(ctx, id.ID{High: key.GetHigh(), Low: key.GetLow()}, int(req.GetMaxHops()))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &TraceRouteResponse{Hops: make([]*TraceHop, 0, len(hops))}
+	for _, h := range hops {
+		resp.Hops = append(resp.Hops, &TraceHop{
+			Node:     apiToDescriptor(h.Node),
+			RttNanos: int64(h.RTT),
+		})
+	}
+	return resp, nil
+}
+
+func (s *serverShim) RingChecksum(ctx context.Context, _ *RingChecksumRequest) (*RingChecksumResponse, error) {
+	v, err := s.n.RingChecksum(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &RingChecksumResponse{
+		Node:         apiToDescriptor(v.Node),
+		Predecessors: make([]*Descriptor, 0, len(v.Predecessors)),
+		Successors:   make([]*Descriptor, 0, len(v.Successors)),
+		Checksum:     v.Checksum,
+	}
+	for _, d := range v.Predecessors {
+		resp.Predecessors = append(resp.Predecessors, apiToDescriptor(d))
+	}
+	for _, d := range v.Successors {
+		resp.Successors = append(resp.Successors, apiToDescriptor(d))
+	}
+	return resp, nil
+}
+
+// ClientOption configures the api.Node returned by ToAPI.
+type ClientOption func(s *clientShim)
+
+// CompressAbove compresses Hellos with gzip when the encoded request is
+// larger than size bytes. Peers compress their responses to compressed
+// requests, so a new state sent back is compressed too. Compression is
+// disabled if size is 0 or less.
+func CompressAbove(size int) ClientOption {
+	return func(s *clientShim) {
+		s.compressAbove = size
+	}
+}
+
 // ToAPI converts NodeClient into an api.Node.
-func ToAPI(c NodeClient) api.Node {
-	return &clientShim{c}
+func ToAPI(c NodeClient, opts ...ClientOption) api.Node {
+	s := &clientShim{c: c}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
 }
 
 type clientShim struct {
-	c NodeClient
+	c             NodeClient
+	compressAbove int
 }
 
-func (s *clientShim) Join(ctx context.Context, joiner api.Descriptor) error {
+func (s *clientShim) Join(ctx context.Context, j api.Join) error {
 	_, err := s.c.Join(ctx, &JoinRequest{
-		Joiner: apiToDescriptor(joiner),
+		Joiner:  apiToDescriptor(j.Joiner),
+		Cluster: j.Cluster,
+
+		ProtocolVersion: j.ProtocolVersion,
+		Path:            apiToDescriptors(j.Path),
 	}, getCallOptions(ctx)...)
 	return err
 }
 
 func (s *clientShim) NodeHello(ctx context.Context, h api.Hello) error {
-	var helloReq HelloRequest
-	helloReq.Initiator = apiToDescriptor(h.Initiator)
-	if h.Next != nil {
-		helloReq.Next = apiToDescriptor(*h.Next)
-	}
-	helloReq.State = apiToState(h.State)
-	if !h.StateAck.IsZero() {
-		helloReq.AckId = uint64(h.StateAck.UTC().UnixNano())
+	var (
+		resp *HelloResponse
+		err  error
+	)
+
+	if h.Delta != nil {
+		helloReq := apiToHelloDelta(h)
+		resp, err = s.callHello(ctx, helloReq, func(opts ...grpc.CallOption) (*HelloResponse, error) {
+			return s.c.HelloDelta(ctx, helloReq, opts...)
+		})
+	} else {
+		helloReq := apiToHello(h)
+		resp, err = s.callHello(ctx, helloReq, func(opts ...grpc.CallOption) (*HelloResponse, error) {
+			return s.c.Hello(ctx, helloReq, opts...)
+		})
 	}
+	return helloResponseErr(resp, err)
+}
 
-	resp, err := s.c.Hello(ctx, &helloReq, getCallOptions(ctx)...)
-	if resp != nil && resp.NewState != nil {
+// helloResponseErr converts the response to a Hello into the error returned
+// by api.Node.NodeHello.
+func helloResponseErr(resp *HelloResponse, err error) error {
+	switch {
+	case resp == nil:
+		return err
+	case resp.UnknownBase:
+		return api.ErrUnknownBase
+	case resp.NewStateDelta != nil:
+		return api.ErrStateChanged{
+			Delta: deltaToAPI(resp.NewStateDelta),
+		}
+	case resp.NewState != nil:
 		return api.ErrStateChanged{
 			NewState: stateToAPI(resp.NewState),
 		}
@@ -119,6 +271,24 @@ func (s *clientShim) NodeHello(ctx context.Context, h api.Hello) error {
 	return err
 }
 
+// callHello invokes call with the call options from ctx, compressing req if
+// it's larger than the compression threshold.
+func (s *clientShim) callHello(ctx context.Context, req proto.Message, call func(opts ...grpc.CallOption) (*HelloResponse, error)) (*HelloResponse, error) {
+	opts := getCallOptions(ctx)
+	if s.compressAbove <= 0 || proto.Size(req) <= s.compressAbove {
+		return call(opts...)
+	}
+
+	compressed := append(opts[:len(opts):len(opts)], grpc.UseCompressor(gzip.Name))
+	resp, err := call(compressed...)
+	if status.Code(err) == codes.Unimplemented {
+		// Peers without gzip registered reject compressed requests before
+		// handling them, so it's safe to send the request again.
+		return call(opts...)
+	}
+	return resp, err
+}
+
 func (s *clientShim) NodeGoodbye(ctx context.Context, leaver api.Descriptor) error {
 	_, err := s.c.Goodbye(ctx, &GoodbyeRequest{
 		Node: apiToDescriptor(leaver),
@@ -126,7 +296,19 @@ func (s *clientShim) NodeGoodbye(ctx context.Context, leaver api.Descriptor) err
 	return err
 }
 
+func (s *clientShim) Evict(ctx context.Context, evictee api.Descriptor) error {
+	_, err := s.c.Evict(ctx, NewEvictRequest(evictee), getCallOptions(ctx)...)
+	return err
+}
+
 func (s *clientShim) GetState(ctx context.Context) (*api.State, error) {
+	state, err := s.getStateStream(ctx)
+	if status.Code(err) != codes.Unimplemented {
+		return state, err
+	}
+
+	// The peer doesn't support streaming the state; fall back to requesting
+	// it in a single message.
 	resp, err := s.c.GetState(ctx, &GetStateRequest{}, getCallOptions(ctx)...)
 	if resp == nil || err != nil {
 		return nil, err
@@ -134,13 +316,183 @@ func (s *clientShim) GetState(ctx context.Context) (*api.State, error) {
 	return stateToAPI(resp.GetState()), nil
 }
 
+// getStateStream requests the state with GetStateStream and reassembles the
+// chunks.
+func (s *clientShim) getStateStream(ctx context.Context) (*api.State, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.c.GetStateStream(ctx, &GetStateRequest{}, getCallOptions(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []*GetStateChunk
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	state, err := mergeChunks(chunks)
+	if err != nil {
+		return nil, err
+	}
+	return stateToAPI(state), nil
+}
+
+func (s *clientShim) Ping(ctx context.Context, p api.Ping) (map[api.Descriptor]api.Health, error) {
+	var req PingRequest
+	if p.Target != nil {
+		req.Target = apiToDescriptor(*p.Target)
+	}
+	req.HealthSet = apiToHealthSet(p.Health)
+
+	resp, err := s.c.Ping(ctx, &req, getCallOptions(ctx)...)
+	if resp == nil || err != nil {
+		return nil, err
+	}
+	return healthSetToAPI(resp.GetHealthSet()), nil
+}
+
+func (s *clientShim) Broadcast(ctx context.Context, b api.Broadcast) ([]api.BroadcastResult, error) {
+	resp, err := s.c.Broadcast(ctx, &BroadcastRequest{
+		Method:  b.Method,
+		Payload: b.Payload,
+		Level:   uint32(b.Level),
+	}, getCallOptions(ctx)...)
+	if resp == nil || err != nil {
+		return nil, err
+	}
+
+	results := make([]api.BroadcastResult, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		res := api.BroadcastResult{Node: descriptorToAPI(r.GetNode())}
+		if code := codes.Code(r.GetCode()); code != codes.OK {
+			res.Err = status.Error(code, r.GetMessage())
+		} else {
+			res.Reply = r.GetReply()
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (s *clientShim) TraceRoute(ctx context.Context, key id.ID, maxHops int) ([]api.TraceHop, error) {
+	resp, err := s.c.TraceRoute(ctx, &TraceRouteRequest{
+		Key:     &ID{High: key.High, Low: key.Low},
+		MaxHops: uint32(maxHops),
+	}, getCallOptions(ctx)...)
+	if resp == nil || err != nil {
+		return nil, err
+	}
+
+	hops := make([]api.TraceHop, 0, len(resp.Hops))
+	for _, h := range resp.Hops {
+		hops = append(hops, api.TraceHop{
+			Node: descriptorToAPI(h.GetNode()),
+			RTT:  time.Duration(h.GetRttNanos()),
+		})
+	}
+	return hops, nil
+}
+
+func (s *clientShim) RingChecksum(ctx context.Context) (api.RingView, error) {
+	resp, err := s.c.RingChecksum(ctx, &RingChecksumRequest{}, getCallOptions(ctx)...)
+	if resp == nil || err != nil {
+		return api.RingView{}, err
+	}
+
+	v := api.RingView{
+		Node:     descriptorToAPI(resp.GetNode()),
+		Checksum: resp.GetChecksum(),
+	}
+	for _, d := range resp.Predecessors {
+		v.Predecessors = append(v.Predecessors, descriptorToAPI(d))
+	}
+	for _, d := range resp.Successors {
+		v.Successors = append(v.Successors, descriptorToAPI(d))
+	}
+	return v, nil
+}
+
+// NewEvictRequest creates a request to evict evictee, used for broadcasting
+// evictions.
+func NewEvictRequest(evictee api.Descriptor) *EvictRequest {
+	return &EvictRequest{Node: apiToDescriptor(evictee)}
+}
+
+func apiToHello(h api.Hello) *HelloRequest {
+	var req HelloRequest
+	req.Initiator = apiToDescriptor(h.Initiator)
+	if h.Next != nil {
+		req.Next = apiToDescriptor(*h.Next)
+	}
+	req.State = apiToState(h.State)
+	req.AckId = h.StateAck
+	req.AcceptDelta = h.AcceptDelta
+	req.Cluster = h.Cluster
+	req.ProtocolVersion = h.ProtocolVersion
+	return &req
+}
+
+func apiToHelloDelta(h api.Hello) *HelloDeltaRequest {
+	var req HelloDeltaRequest
+	req.Initiator = apiToDescriptor(h.Initiator)
+	if h.Next != nil {
+		req.Next = apiToDescriptor(*h.Next)
+	}
+	req.Delta = apiToDelta(h.Delta)
+	req.AckId = h.StateAck
+	req.AcceptDelta = h.AcceptDelta
+	req.Cluster = h.Cluster
+	req.ProtocolVersion = h.ProtocolVersion
+	return &req
+}
+
+func helloToAPI(req *HelloRequest) api.Hello {
+	var h api.Hello
+	h.Initiator = descriptorToAPI(req.GetInitiator())
+	if req.Next != nil {
+		next := descriptorToAPI(req.GetNext())
+		h.Next = &next
+	}
+	h.State = stateToAPI(req.GetState())
+	h.StateAck = req.GetAckId()
+	h.AcceptDelta = req.GetAcceptDelta()
+	h.Cluster = req.GetCluster()
+	h.ProtocolVersion = req.GetProtocolVersion()
+	return h
+}
+
+func helloDeltaToAPI(req *HelloDeltaRequest) api.Hello {
+	var h api.Hello
+	h.Initiator = descriptorToAPI(req.GetInitiator())
+	if req.Next != nil {
+		next := descriptorToAPI(req.GetNext())
+		h.Next = &next
+	}
+	h.Delta = deltaToAPI(req.GetDelta())
+	h.StateAck = req.GetAckId()
+	h.AcceptDelta = req.GetAcceptDelta()
+	h.Cluster = req.GetCluster()
+	h.ProtocolVersion = req.GetProtocolVersion()
+	return h
+}
+
 func apiToDescriptor(d api.Descriptor) *Descriptor {
 	return &Descriptor{
 		Id: &ID{
 			High: d.ID.High,
 			Low:  d.ID.Low,
 		},
-		Addr: d.Addr,
+		Addr:      d.Addr,
+		Labels:    api.DecodeLabels(d.Labels),
+		Signature: []byte(d.Signature),
 	}
 }
 
@@ -150,8 +502,32 @@ func descriptorToAPI(d *Descriptor) api.Descriptor {
 			High: d.GetId().GetHigh(),
 			Low:  d.GetId().GetLow(),
 		},
-		Addr: d.GetAddr(),
+		Addr:      d.GetAddr(),
+		Labels:    api.EncodeLabels(d.GetLabels()),
+		Signature: string(d.GetSignature()),
+	}
+}
+
+func apiToDescriptors(ds []api.Descriptor) []*Descriptor {
+	if len(ds) == 0 {
+		return nil
+	}
+	res := make([]*Descriptor, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, apiToDescriptor(d))
+	}
+	return res
+}
+
+func descriptorsToAPI(ds []*Descriptor) []api.Descriptor {
+	if len(ds) == 0 {
+		return nil
+	}
+	res := make([]api.Descriptor, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, descriptorToAPI(d))
 	}
+	return res
 }
 
 func apiToHealth(h api.Health) Health {
@@ -180,6 +556,25 @@ func healthToApi(h Health) api.Health {
 	}
 }
 
+func apiToHealthSet(set map[api.Descriptor]api.Health) []*DescriptorHealth {
+	res := make([]*DescriptorHealth, 0, len(set))
+	for d, h := range set {
+		res = append(res, &DescriptorHealth{
+			Peer:   apiToDescriptor(d),
+			Health: apiToHealth(h),
+		})
+	}
+	return res
+}
+
+func healthSetToAPI(set []*DescriptorHealth) map[api.Descriptor]api.Health {
+	res := make(map[api.Descriptor]api.Health, len(set))
+	for _, s := range set {
+		res[descriptorToAPI(s.Peer)] = healthToApi(s.Health)
+	}
+	return res
+}
+
 func apiToState(s *api.State) *State {
 	var res State
 	res.Node = apiToDescriptor(s.Node)
@@ -209,7 +604,7 @@ func apiToState(s *api.State) *State {
 		res.Neighborhood = append(res.Neighborhood, apiToDescriptor(p))
 	}
 
-	res.StateId = uint64(s.LastUpdated.UTC().UnixNano())
+	res.StateId = s.Version
 
 	for d, s := range s.Statuses {
 		res.HealthSet = append(res.HealthSet, &DescriptorHealth{
@@ -265,7 +660,7 @@ func stateToAPI(s *State) *api.State {
 		res.Neighbors.Descriptors = append(res.Neighbors.Descriptors, descriptorToAPI(p))
 	}
 
-	res.LastUpdated = time.Unix(0, int64(s.StateId))
+	res.Version = s.StateId
 
 	res.Statuses = make(map[api.Descriptor]api.Health, len(s.HealthSet))
 	for _, s := range s.HealthSet {
@@ -274,3 +669,79 @@ func stateToAPI(s *State) *api.State {
 
 	return &res
 }
+
+func apiToDelta(d *api.StateDelta) *StateDelta {
+	var res StateDelta
+	res.Node = apiToDescriptor(d.Node)
+	res.BaseStateId = d.BaseVersion
+	res.StateId = d.Version
+
+	for _, p := range d.Predecessors {
+		res.Predecessors = append(res.Predecessors, apiToDescriptor(p))
+	}
+	for _, p := range d.Successors {
+		res.Successors = append(res.Successors, apiToDescriptor(p))
+	}
+	for _, p := range d.Neighbors {
+		res.Neighborhood = append(res.Neighborhood, apiToDescriptor(p))
+	}
+
+	res.Routing = make(map[uint32]*Descriptor, len(d.Routes))
+	for idx, ent := range d.Routes {
+		if ent == nil {
+			// Removed entries are sent as an empty descriptor.
+			res.Routing[uint32(idx)] = &Descriptor{}
+			continue
+		}
+		res.Routing[uint32(idx)] = apiToDescriptor(*ent)
+	}
+
+	for p, h := range d.Statuses {
+		res.HealthSet = append(res.HealthSet, &DescriptorHealth{
+			Peer:   apiToDescriptor(p),
+			Health: apiToHealth(h),
+		})
+	}
+	for _, p := range d.Untracked {
+		res.Untracked = append(res.Untracked, apiToDescriptor(p))
+	}
+
+	return &res
+}
+
+func deltaToAPI(d *StateDelta) *api.StateDelta {
+	var res api.StateDelta
+	res.Node = descriptorToAPI(d.GetNode())
+	res.BaseVersion = d.GetBaseStateId()
+	res.Version = d.GetStateId()
+
+	for _, p := range d.Predecessors {
+		res.Predecessors = append(res.Predecessors, descriptorToAPI(p))
+	}
+	for _, p := range d.Successors {
+		res.Successors = append(res.Successors, descriptorToAPI(p))
+	}
+	for _, p := range d.Neighborhood {
+		res.Neighbors = append(res.Neighbors, descriptorToAPI(p))
+	}
+
+	res.Routes = make(map[int]*api.Descriptor, len(d.Routing))
+	for idx, ent := range d.Routing {
+		if ent == nil || proto.Equal(ent, &Descriptor{}) {
+			res.Routes[int(idx)] = nil
+			continue
+		}
+		apiDesc := descriptorToAPI(ent)
+		res.Routes[int(idx)] = &apiDesc
+	}
+
+	res.Statuses = make(map[api.Descriptor]api.Health, len(d.HealthSet))
+	for _, s := range d.HealthSet {
+		res.Statuses[descriptorToAPI(s.Peer)] = healthToApi(s.Health)
+	}
+	for _, p := range d.Untracked {
+		res.Untracked = append(res.Untracked, descriptorToAPI(p))
+	}
+
+	return &res
+}
@@ -0,0 +1,69 @@
+package nodepb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClientShim_CompressAbove(t *testing.T) {
+	var (
+		self  = api.Descriptor{ID: id.ID{Low: 10}, Addr: "self"}
+		state = api.NewState(self, 8, 8, 32, 16)
+		hello = api.Hello{Initiator: self, State: state}
+	)
+
+	tt := []struct {
+		name       string
+		threshold  int
+		compressed bool
+	}{
+		{"disabled", 0, false},
+		{"below threshold", 1 << 20, false},
+		{"above threshold", 1, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var cli fakeHelloClient
+			err := ToAPI(&cli, CompressAbove(tc.threshold)).NodeHello(context.Background(), hello)
+			require.NoError(t, err)
+			require.Equal(t, []bool{tc.compressed}, cli.compressed)
+		})
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		cli := fakeHelloClient{rejectCompressed: true}
+		err := ToAPI(&cli, CompressAbove(1)).NodeHello(context.Background(), hello)
+		require.NoError(t, err)
+		require.Equal(t, []bool{true, false}, cli.compressed)
+	})
+}
+
+// fakeHelloClient records whether Hellos were sent compressed.
+type fakeHelloClient struct {
+	NodeClient
+
+	rejectCompressed bool
+	compressed       []bool
+}
+
+func (c *fakeHelloClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	var compressed bool
+	for _, o := range opts {
+		if _, ok := o.(grpc.CompressorCallOption); ok {
+			compressed = true
+		}
+	}
+	c.compressed = append(c.compressed, compressed)
+
+	if compressed && c.rejectCompressed {
+		return nil, status.Errorf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding \"gzip\"")
+	}
+	return &HelloResponse{}, nil
+}
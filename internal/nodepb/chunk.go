@@ -0,0 +1,86 @@
+package nodepb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// stateChunkEntries is the maximum number of routing and health entries sent
+// in a single GetStateChunk.
+const stateChunkEntries = 256
+
+// chunkState splits s into chunks for GetStateStream. The first chunk holds
+// every field except for routing and health_set, which are split across the
+// following chunks with at most max entries per chunk.
+func chunkState(s *State, max int) []*GetStateChunk {
+	head := &State{
+		Node:         s.Node,
+		Predecessors: s.Predecessors,
+		Successors:   s.Successors,
+		IdBitLength:  s.IdBitLength,
+		IdBase:       s.IdBase,
+		Neighborhood: s.Neighborhood,
+		StateId:      s.StateId,
+	}
+	chunks := []*GetStateChunk{{State: head}}
+
+	// Sort the routing indices so chunks are deterministic.
+	idxs := make([]uint32, 0, len(s.Routing))
+	for idx := range s.Routing {
+		idxs = append(idxs, idx)
+	}
+	sort.Slice(idxs, func(i, j int) bool { return idxs[i] < idxs[j] })
+
+	var cur *State
+	next := func() *State {
+		if cur == nil || len(cur.Routing)+len(cur.HealthSet) >= max {
+			cur = &State{}
+			chunks = append(chunks, &GetStateChunk{State: cur})
+		}
+		return cur
+	}
+	for _, idx := range idxs {
+		c := next()
+		if c.Routing == nil {
+			c.Routing = make(map[uint32]*Descriptor)
+		}
+		c.Routing[idx] = s.Routing[idx]
+	}
+	for _, h := range s.HealthSet {
+		c := next()
+		c.HealthSet = append(c.HealthSet, h)
+	}
+
+	return chunks
+}
+
+// mergeChunks reassembles a State from the chunks sent by GetStateStream.
+func mergeChunks(chunks []*GetStateChunk) (*State, error) {
+	if len(chunks) == 0 || chunks[0].GetState() == nil {
+		return nil, fmt.Errorf("state stream ended before the state was received")
+	}
+
+	first := chunks[0].GetState()
+	res := &State{
+		Node:         first.Node,
+		Predecessors: first.Predecessors,
+		Successors:   first.Successors,
+		IdBitLength:  first.IdBitLength,
+		IdBase:       first.IdBase,
+		Routing:      make(map[uint32]*Descriptor, len(first.Routing)),
+		Neighborhood: first.Neighborhood,
+		StateId:      first.StateId,
+		HealthSet:    first.HealthSet,
+	}
+	for idx, d := range first.Routing {
+		res.Routing[idx] = d
+	}
+
+	for _, chunk := range chunks[1:] {
+		for idx, d := range chunk.GetState().GetRouting() {
+			res.Routing[idx] = d
+		}
+		res.HealthSet = append(res.HealthSet, chunk.GetState().GetHealthSet()...)
+	}
+	return res, nil
+}
@@ -0,0 +1,43 @@
+package nodepb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestChunkState(t *testing.T) {
+	s := &State{
+		Node:         &Descriptor{Id: &ID{Low: 1}, Addr: "node"},
+		Predecessors: []*Descriptor{{Id: &ID{Low: 0}, Addr: "pred"}},
+		Successors:   []*Descriptor{{Id: &ID{Low: 2}, Addr: "succ"}},
+		IdBitLength:  32,
+		IdBase:       16,
+		Routing:      make(map[uint32]*Descriptor),
+		StateId:      10,
+	}
+	for i := uint32(0); i < 20; i++ {
+		d := &Descriptor{Id: &ID{Low: uint64(i) << 8}, Addr: "peer"}
+		s.Routing[i] = d
+		s.HealthSet = append(s.HealthSet, &DescriptorHealth{Peer: d, Health: Health_UNHEALTHY})
+	}
+
+	chunks := chunkState(s, 6)
+	// One chunk for the head and 40 entries split into chunks of 6.
+	require.Len(t, chunks, 1+7)
+	require.Empty(t, chunks[0].State.Routing)
+	require.Empty(t, chunks[0].State.HealthSet)
+	for _, c := range chunks[1:] {
+		require.LessOrEqual(t, len(c.State.Routing)+len(c.State.HealthSet), 6)
+	}
+
+	merged, err := mergeChunks(chunks)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(s, merged), "merged state doesn't match original")
+}
+
+func TestMergeChunks_Empty(t *testing.T) {
+	_, err := mergeChunks(nil)
+	require.Error(t, err)
+}
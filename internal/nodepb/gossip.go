@@ -0,0 +1,233 @@
+package nodepb
+
+import (
+	context "context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rfratto/croissant/internal/api"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+)
+
+// gossipCloseTimeout is how long Close waits for the receiver to finish the
+// stream before canceling it.
+const gossipCloseTimeout = time.Second
+
+// errGossipClosed is returned by calls made on a closed gossip stream.
+var errGossipClosed = status.Errorf(codes.Canceled, "gossip stream closed")
+
+func (s *serverShim) Gossip(stream Node_GossipServer) error {
+	var (
+		ctx       = stream.Context()
+		initiator *api.Descriptor
+	)
+
+	// broken informs the api.Node that the stream broke once an initiator
+	// successfully sent a hello over it.
+	broken := func(err error) error {
+		if w, ok := s.n.(api.GossipWatcher); ok && initiator != nil {
+			w.GossipBroken(ctx, *initiator, err)
+		}
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return broken(err)
+		}
+
+		var h *api.Hello
+		switch msg := req.GetMsg().(type) {
+		case *GossipRequest_Hello:
+			hello := helloToAPI(msg.Hello)
+			h = &hello
+		case *GossipRequest_HelloDelta:
+			hello := helloDeltaToAPI(msg.HelloDelta)
+			h = &hello
+		}
+
+		var resp GossipResponse
+		if h != nil {
+			helloResp, err := s.hello(ctx, *h)
+			if err != nil {
+				st := status.Convert(err)
+				resp.Code, resp.Message = int32(st.Code()), st.Message()
+			} else {
+				resp.Hello = helloResp
+				initiator = &h.Initiator
+			}
+		}
+
+		if err := stream.Send(&resp); err != nil {
+			return broken(err)
+		}
+	}
+}
+
+// Gossip opens a gossip stream to the node. If compression is enabled,
+// every message sent over the stream is compressed.
+func (s *clientShim) Gossip(ctx context.Context) (api.GossipStream, error) {
+	opts := getCallOptions(ctx)
+	if s.compressAbove > 0 {
+		opts = append(opts[:len(opts):len(opts)], grpc.UseCompressor(gzip.Name))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := s.c.Gossip(ctx, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	gs := &gossipStream{
+		stream:    stream,
+		cancel:    cancel,
+		responses: make(chan *GossipResponse, 1),
+		done:      make(chan struct{}),
+	}
+	go gs.recv()
+	return gs, nil
+}
+
+// gossipStream implements api.GossipStream.
+type gossipStream struct {
+	stream Node_GossipClient
+	cancel context.CancelFunc
+
+	callMut   sync.Mutex // Allows one call at a time.
+	responses chan *GossipResponse
+
+	closeOnce sync.Once
+	closed    atomic.Bool // Set by Close before finishing the stream.
+	done      chan struct{}
+	err       error // Set before done is closed.
+}
+
+func (gs *gossipStream) recv() {
+	defer close(gs.done)
+	defer gs.cancel()
+
+	for {
+		resp, err := gs.stream.Recv()
+		switch {
+		case gs.closed.Load():
+			return
+		case errors.Is(err, io.EOF):
+			gs.err = status.Errorf(codes.Unavailable, "gossip stream finished by peer")
+			return
+		case err != nil:
+			gs.err = err
+			return
+		}
+
+		select {
+		case gs.responses <- resp:
+		default:
+			gs.err = status.Errorf(codes.Internal, "unexpected gossip response")
+			return
+		}
+	}
+}
+
+func (gs *gossipStream) NodeHello(ctx context.Context, h api.Hello) error {
+	// Hellos carrying a delta don't have a full state to convert.
+	var req *GossipRequest
+	if h.Delta != nil {
+		req = &GossipRequest{Msg: &GossipRequest_HelloDelta{HelloDelta: apiToHelloDelta(h)}}
+	} else {
+		req = &GossipRequest{Msg: &GossipRequest_Hello{Hello: apiToHello(h)}}
+	}
+
+	resp, err := gs.call(ctx, req)
+	if err != nil {
+		return err
+	} else if resp.GetCode() != int32(codes.OK) {
+		return status.Error(codes.Code(resp.GetCode()), resp.GetMessage())
+	}
+	return helloResponseErr(resp.GetHello(), nil)
+}
+
+func (gs *gossipStream) Heartbeat(ctx context.Context) error {
+	_, err := gs.call(ctx, &GossipRequest{Msg: &GossipRequest_Heartbeat{Heartbeat: &Heartbeat{}}})
+	return err
+}
+
+// call sends req and waits for its response. The stream is broken if ctx
+// is canceled before the response is received, since the response would
+// otherwise be mistaken for the response of the next call.
+func (gs *gossipStream) call(ctx context.Context, req *GossipRequest) (*GossipResponse, error) {
+	gs.callMut.Lock()
+	defer gs.callMut.Unlock()
+
+	if gs.closed.Load() {
+		return nil, errGossipClosed
+	}
+
+	// Send only reports that the stream finished; the reason is returned by
+	// Recv.
+	if err := gs.stream.Send(req); err != nil {
+		<-gs.done
+		return nil, gs.brokenErr()
+	}
+
+	select {
+	case resp := <-gs.responses:
+		return resp, nil
+	case <-gs.done:
+		return nil, gs.brokenErr()
+	case <-ctx.Done():
+		gs.cancel()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// brokenErr returns the error for a call made after the stream stopped.
+func (gs *gossipStream) brokenErr() error {
+	if err := gs.Err(); err != nil {
+		return err
+	}
+	return errGossipClosed
+}
+
+func (gs *gossipStream) Done() <-chan struct{} { return gs.done }
+
+func (gs *gossipStream) Err() error {
+	select {
+	case <-gs.done:
+		return gs.err
+	default:
+		return nil
+	}
+}
+
+// Close finishes the stream, waiting for the peer to acknowledge it before
+// canceling the stream.
+func (gs *gossipStream) Close() error {
+	gs.closeOnce.Do(func() {
+		gs.callMut.Lock()
+		gs.closed.Store(true)
+		err := gs.stream.CloseSend()
+		gs.callMut.Unlock()
+
+		if err == nil {
+			t := time.NewTimer(gossipCloseTimeout)
+			defer t.Stop()
+			select {
+			case <-gs.done:
+			case <-t.C:
+			}
+		}
+		gs.cancel()
+		<-gs.done
+	})
+	return nil
+}
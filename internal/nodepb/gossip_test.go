@@ -0,0 +1,114 @@
+package nodepb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGossipStream(t *testing.T) {
+	var (
+		self  = api.Descriptor{ID: id.ID{Low: 10}, Addr: "self"}
+		state = api.NewState(self, 8, 8, 32, 16)
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	t.Run("hellos and heartbeats", func(t *testing.T) {
+		node := &fakeGossipNode{helloErr: api.ErrStateChanged{NewState: state}, broken: make(chan api.Descriptor, 1)}
+		stream, err := ToAPI(newGossipClient(t, FromAPI(node))).(api.Gossiper).Gossip(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Heartbeat(ctx))
+
+		err = stream.NodeHello(ctx, api.Hello{Initiator: self, State: state})
+		var sc api.ErrStateChanged
+		require.True(t, errors.As(err, &sc))
+		require.Equal(t, self, sc.NewState.Node)
+		require.Equal(t, []api.Descriptor{self}, node.hellos)
+
+		// Closing the stream doesn't count as breaking it.
+		require.NoError(t, stream.Close())
+		require.NoError(t, stream.Err())
+		require.Equal(t, codes.Canceled, status.Code(stream.Heartbeat(ctx)))
+		require.Never(t, func() bool { return len(node.broken) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		node := &fakeGossipNode{broken: make(chan api.Descriptor, 1)}
+		stream, err := ToAPI(newGossipClient(t, FromAPI(node))).(api.Gossiper).Gossip(ctx)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		next := state.Clone()
+		next.Version++
+		delta := api.NewStateDelta(state, next)
+		require.NoError(t, stream.NodeHello(ctx, api.Hello{Initiator: self, Delta: delta}))
+		require.Equal(t, []api.Descriptor{self}, node.hellos)
+	})
+
+	t.Run("broken", func(t *testing.T) {
+		node := &fakeGossipNode{broken: make(chan api.Descriptor, 1)}
+		streamCtx, streamCancel := context.WithCancel(ctx)
+		stream, err := ToAPI(newGossipClient(t, FromAPI(node))).(api.Gossiper).Gossip(streamCtx)
+		require.NoError(t, err)
+		require.NoError(t, stream.NodeHello(ctx, api.Hello{Initiator: self, State: state}))
+
+		streamCancel()
+		<-stream.Done()
+		require.Equal(t, codes.Canceled, status.Code(stream.Err()))
+		require.Equal(t, self, <-node.broken)
+	})
+
+	t.Run("unimplemented", func(t *testing.T) {
+		stream, err := ToAPI(newGossipClient(t, &UnimplementedNodeServer{})).(api.Gossiper).Gossip(ctx)
+		require.NoError(t, err)
+		require.Equal(t, codes.Unimplemented, status.Code(stream.Heartbeat(ctx)))
+	})
+}
+
+// newGossipClient serves srv and returns a client for it.
+func newGossipClient(t *testing.T, srv NodeServer) NodeClient {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer()
+	RegisterNodeServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+	return NewNodeClient(cc)
+}
+
+// fakeGossipNode records hellos and broken gossip streams.
+type fakeGossipNode struct {
+	api.Node
+
+	helloErr error
+	hellos   []api.Descriptor
+	broken   chan api.Descriptor
+}
+
+func (n *fakeGossipNode) NodeHello(_ context.Context, h api.Hello) error {
+	n.hellos = append(n.hellos, h.Initiator)
+	return n.helloErr
+}
+
+func (n *fakeGossipNode) GossipBroken(_ context.Context, initiator api.Descriptor, _ error) {
+	n.broken <- initiator
+}
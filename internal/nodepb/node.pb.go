@@ -84,6 +84,16 @@ type JoinRequest struct {
 
 	// The joiner is the node that is trying to join.
 	Joiner *Descriptor `protobuf:"bytes,1,opt,name=joiner,proto3" json:"joiner,omitempty"`
+	// Name of the cluster the joiner is trying to join. Nodes must reject
+	// joins for a cluster name different from their own.
+	Cluster string `protobuf:"bytes,2,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// Protocol version of the joiner. Unset by nodes that predate protocol
+	// versions, which use version 1. Nodes must reject joins from protocol
+	// versions they no longer support.
+	ProtocolVersion uint32 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	// Nodes the join was propagated through, in order. Nodes must reject joins
+	// that would be propagated to a node in path.
+	Path []*Descriptor `protobuf:"bytes,4,rep,name=path,proto3" json:"path,omitempty"`
 }
 
 func (x *JoinRequest) Reset() {
@@ -125,6 +135,27 @@ func (x *JoinRequest) GetJoiner() *Descriptor {
 	return nil
 }
 
+func (x *JoinRequest) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *JoinRequest) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *JoinRequest) GetPath() []*Descriptor {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
 // Descriptor describes a node within a cluster.
 type Descriptor struct {
 	state         protoimpl.MessageState
@@ -138,6 +169,12 @@ type Descriptor struct {
 	// Address of the node, used for both inter-node communication and for
 	// clients to send requests.
 	Addr string `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
+	// Arbitrary metadata about the node, such as its zone or capacity. Labels
+	// are set by the node and never changed by peers.
+	Labels map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Signature of the id, addr, and labels, set when nodes sign their
+	// descriptors so peers can authenticate them.
+	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (x *Descriptor) Reset() {
@@ -186,6 +223,20 @@ func (x *Descriptor) GetAddr() string {
 	return ""
 }
 
+func (x *Descriptor) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Descriptor) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 // ID is a 128-bit number that identifies a node.
 type ID struct {
 	state         protoimpl.MessageState
@@ -255,10 +306,19 @@ type HelloRequest struct {
 	State *State `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
 	// Ack ID identifies the Hello as the acknowledgement of a previous hello. The ID
 	// is set to the state ID of the state of the receiver. If the receiver's
-	// state ID has changed, it should reply with the new state.
+	// state ID has increased, it should reply with the new state.
 	//
 	// 0 indicates "not an acknowledgement".
 	AckId uint64 `protobuf:"varint,4,opt,name=ack_id,json=ackId,proto3" json:"ack_id,omitempty"`
+	// Set when the initiator can accept new_state_delta in the response.
+	AcceptDelta bool `protobuf:"varint,5,opt,name=accept_delta,json=acceptDelta,proto3" json:"accept_delta,omitempty"`
+	// Name of the cluster of the initiator. Nodes must reject hellos for a
+	// cluster name different from their own.
+	Cluster string `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// Protocol version of the initiator. Unset by nodes that predate protocol
+	// versions, which use version 1. The receiver must avoid features not
+	// supported by the initiator's version when communicating with it.
+	ProtocolVersion uint32 `protobuf:"varint,7,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
 }
 
 func (x *HelloRequest) Reset() {
@@ -321,19 +381,50 @@ func (x *HelloRequest) GetAckId() uint64 {
 	return 0
 }
 
-type HelloResponse struct {
+func (x *HelloRequest) GetAcceptDelta() bool {
+	if x != nil {
+		return x.AcceptDelta
+	}
+	return false
+}
+
+func (x *HelloRequest) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *HelloRequest) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+type HelloDeltaRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// If ack_id was > 0 in in the Hello request and the state ID of the
-	// receiver has changed, new_state should be set to the current state
-	// of the receiver.
-	NewState *State `protobuf:"bytes,1,opt,name=new_state,json=newState,proto3" json:"new_state,omitempty"`
+	// The node initiating the Hello.
+	Initiator *Descriptor `protobuf:"bytes,1,opt,name=initiator,proto3" json:"initiator,omitempty"`
+	// The next node, if any, that will also send a Hello.
+	Next *Descriptor `protobuf:"bytes,2,opt,name=next,proto3" json:"next,omitempty"`
+	// Changes to the state of the initiator since a previous Hello.
+	Delta *StateDelta `protobuf:"bytes,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	// See HelloRequest.ack_id.
+	AckId uint64 `protobuf:"varint,4,opt,name=ack_id,json=ackId,proto3" json:"ack_id,omitempty"`
+	// See HelloRequest.accept_delta.
+	AcceptDelta bool `protobuf:"varint,5,opt,name=accept_delta,json=acceptDelta,proto3" json:"accept_delta,omitempty"`
+	// See HelloRequest.cluster.
+	Cluster string `protobuf:"bytes,6,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	// See HelloRequest.protocol_version.
+	ProtocolVersion uint32 `protobuf:"varint,7,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
 }
 
-func (x *HelloResponse) Reset() {
-	*x = HelloResponse{}
+func (x *HelloDeltaRequest) Reset() {
+	*x = HelloDeltaRequest{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_node_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -341,13 +432,13 @@ func (x *HelloResponse) Reset() {
 	}
 }
 
-func (x *HelloResponse) String() string {
+func (x *HelloDeltaRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*HelloResponse) ProtoMessage() {}
+func (*HelloDeltaRequest) ProtoMessage() {}
 
-func (x *HelloResponse) ProtoReflect() protoreflect.Message {
+func (x *HelloDeltaRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_node_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -359,52 +450,79 @@ func (x *HelloResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use HelloResponse.ProtoReflect.Descriptor instead.
-func (*HelloResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use HelloDeltaRequest.ProtoReflect.Descriptor instead.
+func (*HelloDeltaRequest) Descriptor() ([]byte, []int) {
 	return file_node_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *HelloResponse) GetNewState() *State {
+func (x *HelloDeltaRequest) GetInitiator() *Descriptor {
 	if x != nil {
-		return x.NewState
+		return x.Initiator
 	}
 	return nil
 }
 
-// State is the internal state of a node used for routing messages.
-type State struct {
+func (x *HelloDeltaRequest) GetNext() *Descriptor {
+	if x != nil {
+		return x.Next
+	}
+	return nil
+}
+
+func (x *HelloDeltaRequest) GetDelta() *StateDelta {
+	if x != nil {
+		return x.Delta
+	}
+	return nil
+}
+
+func (x *HelloDeltaRequest) GetAckId() uint64 {
+	if x != nil {
+		return x.AckId
+	}
+	return 0
+}
+
+func (x *HelloDeltaRequest) GetAcceptDelta() bool {
+	if x != nil {
+		return x.AcceptDelta
+	}
+	return false
+}
+
+func (x *HelloDeltaRequest) GetCluster() string {
+	if x != nil {
+		return x.Cluster
+	}
+	return ""
+}
+
+func (x *HelloDeltaRequest) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+type HelloResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// Descriptor of the node that this state belongs to.
-	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
-	// Nodes that appear immediately before this node in the ring.
-	Predecessors []*Descriptor `protobuf:"bytes,2,rep,name=predecessors,proto3" json:"predecessors,omitempty"`
-	// Nodes that appear immediately after this node in the ring.
-	Successors []*Descriptor `protobuf:"bytes,3,rep,name=successors,proto3" json:"successors,omitempty"`
-	// The bit length used to represent IDs for routing. Must be one of
-	// 8/16/32/64/128.
-	IdBitLength uint32 `protobuf:"varint,4,opt,name=id_bit_length,json=idBitLength,proto3" json:"id_bit_length,omitempty"`
-	// The base used to represent the ID for routing (i.e., hexadecimal,
-	// base-16). Must be one of 2/4/8/16.
-	IdBase uint32 `protobuf:"varint,5,opt,name=id_base,json=idBase,proto3" json:"id_base,omitempty"`
-	// The 2D routing table used to route messages to far away nodes.
-	// Key is the index in the routing table, where the position is
-	// (width*y)+x.
-	Routing map[uint32]*Descriptor `protobuf:"bytes,6,rep,name=routing,proto3" json:"routing,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	// List of nodes that are geographically located close to this node.
-	Neighborhood []*Descriptor `protobuf:"bytes,7,rep,name=neighborhood,proto3" json:"neighborhood,omitempty"`
-	// ID representing this table. A change to the table must change this ID.
-	// It is common to use modified timestamp for this.
-	StateId uint64 `protobuf:"varint,8,opt,name=state_id,json=stateId,proto3" json:"state_id,omitempty"`
-	// A set of health of descriptors in the map. This MUST be sorted in order
-	// of peer ID. Descriptors inside MUST be unique.
-	HealthSet []*DescriptorHealth `protobuf:"bytes,9,rep,name=health_set,json=healthSet,proto3" json:"health_set,omitempty"`
+	// If ack_id was > 0 in in the Hello request and the state ID of the
+	// receiver has changed, new_state should be set to the current state
+	// of the receiver.
+	NewState *State `protobuf:"bytes,1,opt,name=new_state,json=newState,proto3" json:"new_state,omitempty"`
+	// May be set instead of new_state if accept_delta was set in the request.
+	// Holds the changes to the state of the receiver since ack_id.
+	NewStateDelta *StateDelta `protobuf:"bytes,2,opt,name=new_state_delta,json=newStateDelta,proto3" json:"new_state_delta,omitempty"`
+	// Set in response to HelloDelta when the receiver doesn't know about the
+	// state the delta is based on.
+	UnknownBase bool `protobuf:"varint,3,opt,name=unknown_base,json=unknownBase,proto3" json:"unknown_base,omitempty"`
 }
 
-func (x *State) Reset() {
-	*x = State{}
+func (x *HelloResponse) Reset() {
+	*x = HelloResponse{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_node_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -412,13 +530,13 @@ func (x *State) Reset() {
 	}
 }
 
-func (x *State) String() string {
+func (x *HelloResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*State) ProtoMessage() {}
+func (*HelloResponse) ProtoMessage() {}
 
-func (x *State) ProtoReflect() protoreflect.Message {
+func (x *HelloResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_node_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -430,87 +548,46 @@ func (x *State) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use State.ProtoReflect.Descriptor instead.
-func (*State) Descriptor() ([]byte, []int) {
+// Deprecated: Use HelloResponse.ProtoReflect.Descriptor instead.
+func (*HelloResponse) Descriptor() ([]byte, []int) {
 	return file_node_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *State) GetNode() *Descriptor {
-	if x != nil {
-		return x.Node
-	}
-	return nil
-}
-
-func (x *State) GetPredecessors() []*Descriptor {
-	if x != nil {
-		return x.Predecessors
-	}
-	return nil
-}
-
-func (x *State) GetSuccessors() []*Descriptor {
-	if x != nil {
-		return x.Successors
-	}
-	return nil
-}
-
-func (x *State) GetIdBitLength() uint32 {
-	if x != nil {
-		return x.IdBitLength
-	}
-	return 0
-}
-
-func (x *State) GetIdBase() uint32 {
-	if x != nil {
-		return x.IdBase
-	}
-	return 0
-}
-
-func (x *State) GetRouting() map[uint32]*Descriptor {
+func (x *HelloResponse) GetNewState() *State {
 	if x != nil {
-		return x.Routing
+		return x.NewState
 	}
 	return nil
 }
 
-func (x *State) GetNeighborhood() []*Descriptor {
+func (x *HelloResponse) GetNewStateDelta() *StateDelta {
 	if x != nil {
-		return x.Neighborhood
+		return x.NewStateDelta
 	}
 	return nil
 }
 
-func (x *State) GetStateId() uint64 {
-	if x != nil {
-		return x.StateId
-	}
-	return 0
-}
-
-func (x *State) GetHealthSet() []*DescriptorHealth {
+func (x *HelloResponse) GetUnknownBase() bool {
 	if x != nil {
-		return x.HealthSet
+		return x.UnknownBase
 	}
-	return nil
+	return false
 }
 
-type DescriptorHealth struct {
+type GossipRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// The peer this DescriptorHealth is for.
-	Peer *Descriptor `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
-	// The health of the peer.
-	Health Health `protobuf:"varint,2,opt,name=health,proto3,enum=croissant.v1.Health" json:"health,omitempty"`
+	// Types that are assignable to Msg:
+	//	*GossipRequest_Hello
+	//	*GossipRequest_HelloDelta
+	//	*GossipRequest_Heartbeat
+	Msg isGossipRequest_Msg `protobuf_oneof:"msg"`
 }
 
-func (x *DescriptorHealth) Reset() {
-	*x = DescriptorHealth{}
+func (x *GossipRequest) Reset() {
+	*x = GossipRequest{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_node_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -518,13 +595,13 @@ func (x *DescriptorHealth) Reset() {
 	}
 }
 
-func (x *DescriptorHealth) String() string {
+func (x *GossipRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DescriptorHealth) ProtoMessage() {}
+func (*GossipRequest) ProtoMessage() {}
 
-func (x *DescriptorHealth) ProtoReflect() protoreflect.Message {
+func (x *GossipRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_node_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -536,34 +613,73 @@ func (x *DescriptorHealth) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DescriptorHealth.ProtoReflect.Descriptor instead.
-func (*DescriptorHealth) Descriptor() ([]byte, []int) {
+// Deprecated: Use GossipRequest.ProtoReflect.Descriptor instead.
+func (*GossipRequest) Descriptor() ([]byte, []int) {
 	return file_node_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *DescriptorHealth) GetPeer() *Descriptor {
-	if x != nil {
-		return x.Peer
+func (m *GossipRequest) GetMsg() isGossipRequest_Msg {
+	if m != nil {
+		return m.Msg
 	}
 	return nil
 }
 
-func (x *DescriptorHealth) GetHealth() Health {
-	if x != nil {
-		return x.Health
+func (x *GossipRequest) GetHello() *HelloRequest {
+	if x, ok := x.GetMsg().(*GossipRequest_Hello); ok {
+		return x.Hello
 	}
-	return Health_HEALTHY
+	return nil
 }
 
-// GetStateRequest requests the state from a node.
-type GetStateRequest struct {
+func (x *GossipRequest) GetHelloDelta() *HelloDeltaRequest {
+	if x, ok := x.GetMsg().(*GossipRequest_HelloDelta); ok {
+		return x.HelloDelta
+	}
+	return nil
+}
+
+func (x *GossipRequest) GetHeartbeat() *Heartbeat {
+	if x, ok := x.GetMsg().(*GossipRequest_Heartbeat); ok {
+		return x.Heartbeat
+	}
+	return nil
+}
+
+type isGossipRequest_Msg interface {
+	isGossipRequest_Msg()
+}
+
+type GossipRequest_Hello struct {
+	// Handled the same as a call to Hello.
+	Hello *HelloRequest `protobuf:"bytes,1,opt,name=hello,proto3,oneof"`
+}
+
+type GossipRequest_HelloDelta struct {
+	// Handled the same as a call to HelloDelta.
+	HelloDelta *HelloDeltaRequest `protobuf:"bytes,2,opt,name=hello_delta,json=helloDelta,proto3,oneof"`
+}
+
+type GossipRequest_Heartbeat struct {
+	// Checks that the receiver is still alive. Sent instead of a Hello when
+	// the initiator's state hasn't changed.
+	Heartbeat *Heartbeat `protobuf:"bytes,3,opt,name=heartbeat,proto3,oneof"`
+}
+
+func (*GossipRequest_Hello) isGossipRequest_Msg() {}
+
+func (*GossipRequest_HelloDelta) isGossipRequest_Msg() {}
+
+func (*GossipRequest_Heartbeat) isGossipRequest_Msg() {}
+
+type Heartbeat struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 }
 
-func (x *GetStateRequest) Reset() {
-	*x = GetStateRequest{}
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_node_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -571,13 +687,13 @@ func (x *GetStateRequest) Reset() {
 	}
 }
 
-func (x *GetStateRequest) String() string {
+func (x *Heartbeat) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetStateRequest) ProtoMessage() {}
+func (*Heartbeat) ProtoMessage() {}
 
-func (x *GetStateRequest) ProtoReflect() protoreflect.Message {
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
 	mi := &file_node_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -589,22 +705,26 @@ func (x *GetStateRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetStateRequest.ProtoReflect.Descriptor instead.
-func (*GetStateRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
 	return file_node_proto_rawDescGZIP(), []int{7}
 }
 
-type GetStateResponse struct {
+type GossipResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// State holds the state of the node.
-	State *State `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	// Response to a hello or hello_delta. Unset in response to heartbeats.
+	Hello *HelloResponse `protobuf:"bytes,1,opt,name=hello,proto3" json:"hello,omitempty"`
+	// gRPC status code and message of a failed hello or hello_delta. The
+	// stream stays open after a failed hello.
+	Code    int32  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
 }
 
-func (x *GetStateResponse) Reset() {
-	*x = GetStateResponse{}
+func (x *GossipResponse) Reset() {
+	*x = GossipResponse{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_node_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -612,13 +732,13 @@ func (x *GetStateResponse) Reset() {
 	}
 }
 
-func (x *GetStateResponse) String() string {
+func (x *GossipResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetStateResponse) ProtoMessage() {}
+func (*GossipResponse) ProtoMessage() {}
 
-func (x *GetStateResponse) ProtoReflect() protoreflect.Message {
+func (x *GossipResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_node_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -630,44 +750,1057 @@ func (x *GetStateResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetStateResponse.ProtoReflect.Descriptor instead.
-func (*GetStateResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use GossipResponse.ProtoReflect.Descriptor instead.
+func (*GossipResponse) Descriptor() ([]byte, []int) {
 	return file_node_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *GetStateResponse) GetState() *State {
+func (x *GossipResponse) GetHello() *HelloResponse {
 	if x != nil {
-		return x.State
+		return x.Hello
 	}
 	return nil
 }
 
-type GoodbyeRequest struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	// The node leaving the cluster.
-	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+func (x *GossipResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
 }
 
-func (x *GoodbyeRequest) Reset() {
-	*x = GoodbyeRequest{}
+func (x *GossipResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// State is the internal state of a node used for routing messages.
+type State struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Descriptor of the node that this state belongs to.
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// Nodes that appear immediately before this node in the ring.
+	Predecessors []*Descriptor `protobuf:"bytes,2,rep,name=predecessors,proto3" json:"predecessors,omitempty"`
+	// Nodes that appear immediately after this node in the ring.
+	Successors []*Descriptor `protobuf:"bytes,3,rep,name=successors,proto3" json:"successors,omitempty"`
+	// The bit length used to represent IDs for routing. Must be one of
+	// 8/16/32/64/128.
+	IdBitLength uint32 `protobuf:"varint,4,opt,name=id_bit_length,json=idBitLength,proto3" json:"id_bit_length,omitempty"`
+	// The base used to represent the ID for routing (i.e., hexadecimal,
+	// base-16). Must be one of 2/4/8/16.
+	IdBase uint32 `protobuf:"varint,5,opt,name=id_base,json=idBase,proto3" json:"id_base,omitempty"`
+	// The 2D routing table used to route messages to far away nodes.
+	// Key is the index in the routing table, where the position is
+	// (width*y)+x.
+	Routing map[uint32]*Descriptor `protobuf:"bytes,6,rep,name=routing,proto3" json:"routing,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// List of nodes that are geographically located close to this node.
+	Neighborhood []*Descriptor `protobuf:"bytes,7,rep,name=neighborhood,proto3" json:"neighborhood,omitempty"`
+	// ID representing this table. A change to the table must increase this ID.
+	// This is a monotonically increasing version, and must not be derived from
+	// wall-clock time, which may go backwards. The initial ID is seeded from the
+	// start time of the node so IDs keep increasing across restarts, and from
+	// the last ID the node saved if it persists its state. Nodes which don't
+	// persist their state may reuse old IDs after their clock goes backwards.
+	//
+	// Peers only ever compare a state ID against IDs the owner of the state
+	// generated, so nodes which used timestamps as state IDs remain compatible.
+	StateId uint64 `protobuf:"varint,8,opt,name=state_id,json=stateId,proto3" json:"state_id,omitempty"`
+	// A set of health of descriptors in the map. This MUST be sorted in order
+	// of peer ID. Descriptors inside MUST be unique.
+	HealthSet []*DescriptorHealth `protobuf:"bytes,9,rep,name=health_set,json=healthSet,proto3" json:"health_set,omitempty"`
+}
+
+func (x *State) Reset() {
+	*x = State{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *State) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*State) ProtoMessage() {}
+
+func (x *State) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use State.ProtoReflect.Descriptor instead.
+func (*State) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *State) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *State) GetPredecessors() []*Descriptor {
+	if x != nil {
+		return x.Predecessors
+	}
+	return nil
+}
+
+func (x *State) GetSuccessors() []*Descriptor {
+	if x != nil {
+		return x.Successors
+	}
+	return nil
+}
+
+func (x *State) GetIdBitLength() uint32 {
+	if x != nil {
+		return x.IdBitLength
+	}
+	return 0
+}
+
+func (x *State) GetIdBase() uint32 {
+	if x != nil {
+		return x.IdBase
+	}
+	return 0
+}
+
+func (x *State) GetRouting() map[uint32]*Descriptor {
+	if x != nil {
+		return x.Routing
+	}
+	return nil
+}
+
+func (x *State) GetNeighborhood() []*Descriptor {
+	if x != nil {
+		return x.Neighborhood
+	}
+	return nil
+}
+
+func (x *State) GetStateId() uint64 {
+	if x != nil {
+		return x.StateId
+	}
+	return 0
+}
+
+func (x *State) GetHealthSet() []*DescriptorHealth {
+	if x != nil {
+		return x.HealthSet
+	}
+	return nil
+}
+
+// StateDelta holds the changes between two versions of a node's state.
+type StateDelta struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Descriptor of the node that the state belongs to.
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// ID of the state that the delta must be applied to.
+	BaseStateId uint64 `protobuf:"varint,2,opt,name=base_state_id,json=baseStateId,proto3" json:"base_state_id,omitempty"`
+	// ID of the state after applying the delta.
+	StateId uint64 `protobuf:"varint,3,opt,name=state_id,json=stateId,proto3" json:"state_id,omitempty"`
+	// Leaves and neighbors are always sent in full.
+	Predecessors []*Descriptor `protobuf:"bytes,4,rep,name=predecessors,proto3" json:"predecessors,omitempty"`
+	Successors   []*Descriptor `protobuf:"bytes,5,rep,name=successors,proto3" json:"successors,omitempty"`
+	Neighborhood []*Descriptor `protobuf:"bytes,6,rep,name=neighborhood,proto3" json:"neighborhood,omitempty"`
+	// Entries of the routing table that changed, keyed the same as
+	// State.routing. Entries that were removed are set to an empty Descriptor.
+	Routing map[uint32]*Descriptor `protobuf:"bytes,7,rep,name=routing,proto3" json:"routing,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Health of peers that changed.
+	HealthSet []*DescriptorHealth `protobuf:"bytes,8,rep,name=health_set,json=healthSet,proto3" json:"health_set,omitempty"`
+	// Peers whose health is no longer tracked.
+	Untracked []*Descriptor `protobuf:"bytes,9,rep,name=untracked,proto3" json:"untracked,omitempty"`
+}
+
+func (x *StateDelta) Reset() {
+	*x = StateDelta{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StateDelta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateDelta) ProtoMessage() {}
+
+func (x *StateDelta) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateDelta.ProtoReflect.Descriptor instead.
+func (*StateDelta) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StateDelta) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *StateDelta) GetBaseStateId() uint64 {
+	if x != nil {
+		return x.BaseStateId
+	}
+	return 0
+}
+
+func (x *StateDelta) GetStateId() uint64 {
+	if x != nil {
+		return x.StateId
+	}
+	return 0
+}
+
+func (x *StateDelta) GetPredecessors() []*Descriptor {
+	if x != nil {
+		return x.Predecessors
+	}
+	return nil
+}
+
+func (x *StateDelta) GetSuccessors() []*Descriptor {
+	if x != nil {
+		return x.Successors
+	}
+	return nil
+}
+
+func (x *StateDelta) GetNeighborhood() []*Descriptor {
+	if x != nil {
+		return x.Neighborhood
+	}
+	return nil
+}
+
+func (x *StateDelta) GetRouting() map[uint32]*Descriptor {
+	if x != nil {
+		return x.Routing
+	}
+	return nil
+}
+
+func (x *StateDelta) GetHealthSet() []*DescriptorHealth {
+	if x != nil {
+		return x.HealthSet
+	}
+	return nil
+}
+
+func (x *StateDelta) GetUntracked() []*Descriptor {
+	if x != nil {
+		return x.Untracked
+	}
+	return nil
+}
+
+type DescriptorHealth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The peer this DescriptorHealth is for.
+	Peer *Descriptor `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	// The health of the peer.
+	Health Health `protobuf:"varint,2,opt,name=health,proto3,enum=croissant.v1.Health" json:"health,omitempty"`
+}
+
+func (x *DescriptorHealth) Reset() {
+	*x = DescriptorHealth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DescriptorHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescriptorHealth) ProtoMessage() {}
+
+func (x *DescriptorHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescriptorHealth.ProtoReflect.Descriptor instead.
+func (*DescriptorHealth) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DescriptorHealth) GetPeer() *Descriptor {
+	if x != nil {
+		return x.Peer
+	}
+	return nil
+}
+
+func (x *DescriptorHealth) GetHealth() Health {
+	if x != nil {
+		return x.Health
+	}
+	return Health_HEALTHY
+}
+
+// GetStateRequest requests the state from a node.
+type GetStateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetStateRequest) Reset() {
+	*x = GetStateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStateRequest) ProtoMessage() {}
+
+func (x *GetStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStateRequest.ProtoReflect.Descriptor instead.
+func (*GetStateRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{12}
+}
+
+type GetStateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// State holds the state of the node.
+	State *State `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (x *GetStateResponse) Reset() {
+	*x = GetStateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetStateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStateResponse) ProtoMessage() {}
+
+func (x *GetStateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStateResponse.ProtoReflect.Descriptor instead.
+func (*GetStateResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetStateResponse) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+// GetStateChunk holds part of the state of a node. The first chunk holds
+// every field of the state except for routing and health_set, which are split
+// across the following chunks. Receivers reassemble the state by merging the
+// routing and health_set fields of every chunk into the first chunk.
+type GetStateChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	State *State `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+}
+
+func (x *GetStateChunk) Reset() {
+	*x = GetStateChunk{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetStateChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStateChunk) ProtoMessage() {}
+
+func (x *GetStateChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStateChunk.ProtoReflect.Descriptor instead.
+func (*GetStateChunk) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetStateChunk) GetState() *State {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type GoodbyeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The node leaving the cluster.
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+}
+
+func (x *GoodbyeRequest) Reset() {
+	*x = GoodbyeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GoodbyeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GoodbyeRequest) ProtoMessage() {}
+
+func (x *GoodbyeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GoodbyeRequest.ProtoReflect.Descriptor instead.
+func (*GoodbyeRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GoodbyeRequest) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+type PingRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Node to ping on behalf of the sender. Unset if the receiver is the node
+	// being pinged.
+	Target *Descriptor `protobuf:"bytes,1,opt,name=target,proto3" json:"target,omitempty"`
+	// Recent changes to the health of peers known by the sender.
+	HealthSet []*DescriptorHealth `protobuf:"bytes,2,rep,name=health_set,json=healthSet,proto3" json:"health_set,omitempty"`
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PingRequest) GetTarget() *Descriptor {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *PingRequest) GetHealthSet() []*DescriptorHealth {
+	if x != nil {
+		return x.HealthSet
+	}
+	return nil
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Recent changes to the health of peers known by the receiver.
+	HealthSet []*DescriptorHealth `protobuf:"bytes,1,rep,name=health_set,json=healthSet,proto3" json:"health_set,omitempty"`
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *PingResponse) GetHealthSet() []*DescriptorHealth {
+	if x != nil {
+		return x.HealthSet
+	}
+	return nil
+}
+
+type BroadcastRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Full name of the method to invoke on every node, i.e.,
+	// /package.Service/Method.
+	Method string `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	// Encoded request message for method.
+	Payload []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	// First row of the receiver's routing table to forward the broadcast to.
+	Level uint32 `protobuf:"varint,3,opt,name=level,proto3" json:"level,omitempty"`
+}
+
+func (x *BroadcastRequest) Reset() {
+	*x = BroadcastRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BroadcastRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastRequest) ProtoMessage() {}
+
+func (x *BroadcastRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastRequest.ProtoReflect.Descriptor instead.
+func (*BroadcastRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BroadcastRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *BroadcastRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *BroadcastRequest) GetLevel() uint32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+type BroadcastResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Results from the receiver and every node in its subtree.
+	Results []*BroadcastResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *BroadcastResponse) Reset() {
+	*x = BroadcastResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BroadcastResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastResponse) ProtoMessage() {}
+
+func (x *BroadcastResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastResponse.ProtoReflect.Descriptor instead.
+func (*BroadcastResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BroadcastResponse) GetResults() []*BroadcastResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BroadcastResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The node that produced this result.
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// Encoded response message from the node. Unset if code is non-zero.
+	Reply []byte `protobuf:"bytes,2,opt,name=reply,proto3" json:"reply,omitempty"`
+	// gRPC status code and message of the node's response.
+	Code    int32  `protobuf:"varint,3,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *BroadcastResult) Reset() {
+	*x = BroadcastResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BroadcastResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BroadcastResult) ProtoMessage() {}
+
+func (x *BroadcastResult) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BroadcastResult.ProtoReflect.Descriptor instead.
+func (*BroadcastResult) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BroadcastResult) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *BroadcastResult) GetReply() []byte {
+	if x != nil {
+		return x.Reply
+	}
+	return nil
+}
+
+func (x *BroadcastResult) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BroadcastResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type TraceRouteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Key to trace the route of.
+	Key *ID `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Maximum number of hops in the route, including the receiver. The trace
+	// fails if the route is longer.
+	MaxHops uint32 `protobuf:"varint,2,opt,name=max_hops,json=maxHops,proto3" json:"max_hops,omitempty"`
+}
+
+func (x *TraceRouteRequest) Reset() {
+	*x = TraceRouteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TraceRouteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceRouteRequest) ProtoMessage() {}
+
+func (x *TraceRouteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceRouteRequest.ProtoReflect.Descriptor instead.
+func (*TraceRouteRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TraceRouteRequest) GetKey() *ID {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *TraceRouteRequest) GetMaxHops() uint32 {
+	if x != nil {
+		return x.MaxHops
+	}
+	return 0
+}
+
+type TraceRouteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Hops of the route starting from the receiver. The last hop is the node
+	// closest to the key.
+	Hops []*TraceHop `protobuf:"bytes,1,rep,name=hops,proto3" json:"hops,omitempty"`
+}
+
+func (x *TraceRouteResponse) Reset() {
+	*x = TraceRouteResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TraceRouteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceRouteResponse) ProtoMessage() {}
+
+func (x *TraceRouteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceRouteResponse.ProtoReflect.Descriptor instead.
+func (*TraceRouteResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *TraceRouteResponse) GetHops() []*TraceHop {
+	if x != nil {
+		return x.Hops
+	}
+	return nil
+}
+
+type TraceHop struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// Round-trip time in nanoseconds of the request sent to node by the
+	// previous hop, including the time taken to trace the rest of the route.
+	// Unset for the first hop.
+	RttNanos int64 `protobuf:"varint,2,opt,name=rtt_nanos,json=rttNanos,proto3" json:"rtt_nanos,omitempty"`
+}
+
+func (x *TraceHop) Reset() {
+	*x = TraceHop{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TraceHop) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TraceHop) ProtoMessage() {}
+
+func (x *TraceHop) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TraceHop.ProtoReflect.Descriptor instead.
+func (*TraceHop) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *TraceHop) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *TraceHop) GetRttNanos() int64 {
+	if x != nil {
+		return x.RttNanos
+	}
+	return 0
+}
+
+type RingChecksumRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RingChecksumRequest) Reset() {
+	*x = RingChecksumRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RingChecksumRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RingChecksumRequest) ProtoMessage() {}
+
+func (x *RingChecksumRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RingChecksumRequest.ProtoReflect.Descriptor instead.
+func (*RingChecksumRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{24}
+}
+
+type RingChecksumResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	// Healthy predecessors and successors of node, closest first.
+	Predecessors []*Descriptor `protobuf:"bytes,2,rep,name=predecessors,proto3" json:"predecessors,omitempty"`
+	Successors   []*Descriptor `protobuf:"bytes,3,rep,name=successors,proto3" json:"successors,omitempty"`
+	// Checksum over node, predecessors, and successors, in order.
+	Checksum uint64 `protobuf:"varint,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+}
+
+func (x *RingChecksumResponse) Reset() {
+	*x = RingChecksumResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_node_proto_msgTypes[9]
+		mi := &file_node_proto_msgTypes[25]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *GoodbyeRequest) String() string {
+func (x *RingChecksumResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GoodbyeRequest) ProtoMessage() {}
+func (*RingChecksumResponse) ProtoMessage() {}
 
-func (x *GoodbyeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_node_proto_msgTypes[9]
+func (x *RingChecksumResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -678,12 +1811,81 @@ func (x *GoodbyeRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GoodbyeRequest.ProtoReflect.Descriptor instead.
-func (*GoodbyeRequest) Descriptor() ([]byte, []int) {
-	return file_node_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use RingChecksumResponse.ProtoReflect.Descriptor instead.
+func (*RingChecksumResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{25}
 }
 
-func (x *GoodbyeRequest) GetNode() *Descriptor {
+func (x *RingChecksumResponse) GetNode() *Descriptor {
+	if x != nil {
+		return x.Node
+	}
+	return nil
+}
+
+func (x *RingChecksumResponse) GetPredecessors() []*Descriptor {
+	if x != nil {
+		return x.Predecessors
+	}
+	return nil
+}
+
+func (x *RingChecksumResponse) GetSuccessors() []*Descriptor {
+	if x != nil {
+		return x.Successors
+	}
+	return nil
+}
+
+func (x *RingChecksumResponse) GetChecksum() uint64 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+type EvictRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The node being evicted.
+	Node *Descriptor `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+}
+
+func (x *EvictRequest) Reset() {
+	*x = EvictRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_node_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EvictRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvictRequest) ProtoMessage() {}
+
+func (x *EvictRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvictRequest.ProtoReflect.Descriptor instead.
+func (*EvictRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *EvictRequest) GetNode() *Descriptor {
 	if x != nil {
 		return x.Node
 	}
@@ -696,107 +1898,319 @@ var file_node_proto_rawDesc = []byte{
 	0x0a, 0x0a, 0x6e, 0x6f, 0x64, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x63, 0x72,
 	0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x1a, 0x1b, 0x67, 0x6f, 0x6f, 0x67,
 	0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x65, 0x6d, 0x70, 0x74,
-	0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x3f, 0x0a, 0x0b, 0x4a, 0x6f, 0x69, 0x6e, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x06, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x72,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61,
-	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72,
-	0x52, 0x06, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x72, 0x22, 0x42, 0x0a, 0x0a, 0x44, 0x65, 0x73, 0x63,
-	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x12, 0x20, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x10, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76,
-	0x31, 0x2e, 0x49, 0x44, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x64, 0x64, 0x72,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64, 0x72, 0x22, 0x2a, 0x0a, 0x02,
-	0x49, 0x44, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x69, 0x67, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x04, 0x68, 0x69, 0x67, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x77, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x03, 0x6c, 0x6f, 0x77, 0x22, 0xb6, 0x01, 0x0a, 0x0c, 0x48, 0x65, 0x6c,
-	0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x09, 0x69, 0x6e, 0x69,
-	0x74, 0x69, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63,
+	0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb2, 0x01, 0x0a, 0x0b, 0x4a, 0x6f, 0x69, 0x6e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x06, 0x6a, 0x6f, 0x69, 0x6e, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f,
+	0x72, 0x52, 0x06, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x75,
+	0x73, 0x74, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2c,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63,
 	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63,
-	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x09, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x74, 0x6f,
-	0x72, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44,
-	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x12,
-	0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
-	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
-	0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x63,
-	0x6b, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x61, 0x63, 0x6b, 0x49,
-	0x64, 0x22, 0x41, 0x0a, 0x0d, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x22, 0xd9, 0x01, 0x0a,
+	0x0a, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x12, 0x20, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x44, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a,
+	0x04, 0x61, 0x64, 0x64, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64,
+	0x72, 0x12, 0x3c, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x24, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x61, 0x62, 0x65,
+	0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x1a, 0x39, 0x0a,
+	0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x2a, 0x0a, 0x02, 0x49, 0x44, 0x12, 0x12,
+	0x0a, 0x04, 0x68, 0x69, 0x67, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x68, 0x69,
+	0x67, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x77, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x03, 0x6c, 0x6f, 0x77, 0x22, 0x9e, 0x02, 0x0a, 0x0c, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x09, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x74,
+	0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73,
+	0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x6f, 0x72, 0x52, 0x09, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x2c, 0x0a,
+	0x04, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72,
+	0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x12, 0x29, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63, 0x72, 0x6f,
+	0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x63, 0x6b, 0x5f, 0x69, 0x64,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x61, 0x63, 0x6b, 0x49, 0x64, 0x12, 0x21, 0x0a,
+	0x0c, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0b, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x44, 0x65, 0x6c, 0x74, 0x61,
+	0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xa8, 0x02, 0x0a, 0x11, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x44,
+	0x65, 0x6c, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x09, 0x69,
+	0x6e, 0x69, 0x74, 0x69, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18,
+	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x09, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61,
+	0x74, 0x6f, 0x72, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x65, 0x78,
+	0x74, 0x12, 0x2e, 0x0a, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74,
+	0x61, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x63, 0x6b, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x05, 0x61, 0x63, 0x6b, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x61, 0x63, 0x63, 0x65,
+	0x70, 0x74, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b,
+	0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
+	0x6c, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x0f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x22, 0xa6, 0x01, 0x0a, 0x0d, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
 	0x73, 0x65, 0x12, 0x30, 0x0a, 0x09, 0x6e, 0x65, 0x77, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e,
 	0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x08, 0x6e, 0x65, 0x77, 0x53,
-	0x74, 0x61, 0x74, 0x65, 0x22, 0x94, 0x04, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2c,
-	0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63,
-	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63,
-	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x3c, 0x0a, 0x0c,
-	0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76,
-	0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x70, 0x72,
-	0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x38, 0x0a, 0x0a, 0x73, 0x75,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18,
-	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65,
-	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73,
-	0x73, 0x6f, 0x72, 0x73, 0x12, 0x22, 0x0a, 0x0d, 0x69, 0x64, 0x5f, 0x62, 0x69, 0x74, 0x5f, 0x6c,
-	0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x69, 0x64, 0x42,
-	0x69, 0x74, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x17, 0x0a, 0x07, 0x69, 0x64, 0x5f, 0x62,
-	0x61, 0x73, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x69, 0x64, 0x42, 0x61, 0x73,
-	0x65, 0x12, 0x3a, 0x0a, 0x07, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x20, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76,
-	0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x45,
-	0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x3c, 0x0a,
-	0x0c, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x18, 0x07, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e,
-	0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x6e,
-	0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x73,
-	0x74, 0x61, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x73,
-	0x74, 0x61, 0x74, 0x65, 0x49, 0x64, 0x12, 0x3d, 0x0a, 0x0a, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68,
-	0x5f, 0x73, 0x65, 0x74, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x72, 0x6f,
-	0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69,
-	0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x68, 0x65, 0x61, 0x6c,
-	0x74, 0x68, 0x53, 0x65, 0x74, 0x1a, 0x54, 0x0a, 0x0c, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2e, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61,
-	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72,
-	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x6e, 0x0a, 0x10, 0x44,
-	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12,
-	0x2c, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x40, 0x0a, 0x0f, 0x6e, 0x65, 0x77, 0x5f, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x0d, 0x6e, 0x65, 0x77, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x21, 0x0a, 0x0c, 0x75, 0x6e, 0x6b, 0x6e, 0x6f, 0x77,
+	0x6e, 0x5f, 0x62, 0x61, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x75, 0x6e,
+	0x6b, 0x6e, 0x6f, 0x77, 0x6e, 0x42, 0x61, 0x73, 0x65, 0x22, 0xc7, 0x01, 0x0a, 0x0d, 0x47, 0x6f,
+	0x73, 0x73, 0x69, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x32, 0x0a, 0x05, 0x68,
+	0x65, 0x6c, 0x6c, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x72, 0x6f,
+	0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x05, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x12,
+	0x42, 0x0a, 0x0b, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x5f, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74,
+	0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0a, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x44, 0x65,
+	0x6c, 0x74, 0x61, 0x12, 0x37, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61,
+	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x48,
+	0x00, 0x52, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x42, 0x05, 0x0a, 0x03,
+	0x6d, 0x73, 0x67, 0x22, 0x0b, 0x0a, 0x09, 0x48, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74,
+	0x22, 0x71, 0x0a, 0x0e, 0x47, 0x6f, 0x73, 0x73, 0x69, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x31, 0x0a, 0x05, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x05,
+	0x68, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x22, 0x94, 0x04, 0x0a, 0x05, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x2c, 0x0a,
+	0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72,
+	0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x3c, 0x0a, 0x0c, 0x70,
+	0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x70, 0x72, 0x65,
+	0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x38, 0x0a, 0x0a, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e,
 	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73,
-	0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x2c, 0x0a,
-	0x06, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e,
-	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x61,
-	0x6c, 0x74, 0x68, 0x52, 0x06, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x22, 0x11, 0x0a, 0x0f, 0x47,
-	0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3d,
-	0x0a, 0x10, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x13, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
-	0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22, 0x3e, 0x0a,
-	0x0e, 0x47, 0x6f, 0x6f, 0x64, 0x62, 0x79, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
-	0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x6f, 0x72, 0x73, 0x12, 0x22, 0x0a, 0x0d, 0x69, 0x64, 0x5f, 0x62, 0x69, 0x74, 0x5f, 0x6c, 0x65,
+	0x6e, 0x67, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x69, 0x64, 0x42, 0x69,
+	0x74, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x17, 0x0a, 0x07, 0x69, 0x64, 0x5f, 0x62, 0x61,
+	0x73, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x69, 0x64, 0x42, 0x61, 0x73, 0x65,
+	0x12, 0x3a, 0x0a, 0x07, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x20, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x3c, 0x0a, 0x0c,
+	0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x6e, 0x65,
+	0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x73, 0x74,
+	0x61, 0x74, 0x65, 0x49, 0x64, 0x12, 0x3d, 0x0a, 0x0a, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x5f,
+	0x73, 0x65, 0x74, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x68, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x53, 0x65, 0x74, 0x1a, 0x54, 0x0a, 0x0c, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2e, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e,
+	0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xbd, 0x04, 0x0a, 0x0a, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f,
+	0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x22, 0x0a, 0x0d, 0x62, 0x61, 0x73, 0x65, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b,
+	0x62, 0x61, 0x73, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x49, 0x64, 0x12, 0x3c, 0x0a, 0x0c, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63,
+	0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73,
+	0x73, 0x6f, 0x72, 0x73, 0x12, 0x38, 0x0a, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f,
+	0x72, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73,
+	0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x6f, 0x72, 0x52, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x3c,
+	0x0a, 0x0c, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0c,
+	0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x68, 0x6f, 0x6f, 0x64, 0x12, 0x3f, 0x0a, 0x07,
+	0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e,
+	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61,
+	0x74, 0x65, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x3d, 0x0a,
+	0x0a, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x5f, 0x73, 0x65, 0x74, 0x18, 0x08, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74,
+	0x68, 0x52, 0x09, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x65, 0x74, 0x12, 0x36, 0x0a, 0x09,
+	0x75, 0x6e, 0x74, 0x72, 0x61, 0x63, 0x6b, 0x65, 0x64, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x09, 0x75, 0x6e, 0x74, 0x72, 0x61,
+	0x63, 0x6b, 0x65, 0x64, 0x1a, 0x54, 0x0a, 0x0c, 0x52, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x67, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2e, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e,
+	0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x6e, 0x0a, 0x10, 0x44, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x2c,
+	0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x2c, 0x0a, 0x06,
+	0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x14, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x52, 0x06, 0x68, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x22, 0x11, 0x0a, 0x0f, 0x47, 0x65,
+	0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3d, 0x0a,
+	0x10, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x13, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22, 0x3a, 0x0a, 0x0d,
+	0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x29, 0x0a,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x22, 0x3e, 0x0a, 0x0e, 0x47, 0x6f, 0x6f, 0x64,
+	0x62, 0x79, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f,
+	0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73,
+	0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x6f, 0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x22, 0x7e, 0x0a, 0x0b, 0x50, 0x69, 0x6e, 0x67,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x30, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f,
+	0x72, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x3d, 0x0a, 0x0a, 0x68, 0x65, 0x61,
+	0x6c, 0x74, 0x68, 0x5f, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
 	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73,
-	0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x2a, 0x2e, 0x0a,
-	0x06, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x0b, 0x0a, 0x07, 0x48, 0x45, 0x41, 0x4c, 0x54,
-	0x48, 0x59, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x55, 0x4e, 0x48, 0x45, 0x41, 0x4c, 0x54, 0x48,
-	0x59, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x44, 0x45, 0x41, 0x44, 0x10, 0x02, 0x32, 0x8f, 0x02,
-	0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x39, 0x0a, 0x04, 0x4a, 0x6f, 0x69, 0x6e, 0x12, 0x19,
-	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x4a, 0x6f,
-	0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
-	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74,
-	0x79, 0x12, 0x40, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x1a, 0x2e, 0x63, 0x72, 0x6f,
-	0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61,
-	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x07, 0x47, 0x6f, 0x6f, 0x64, 0x62, 0x79, 0x65, 0x12, 0x1c,
-	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x6f,
-	0x6f, 0x64, 0x62, 0x79, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67,
-	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45,
-	0x6d, 0x70, 0x74, 0x79, 0x12, 0x49, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65,
-	0x12, 0x1d, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e,
-	0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x1e, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47,
-	0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42,
-	0x2e, 0x5a, 0x2c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x72, 0x66,
-	0x72, 0x61, 0x74, 0x74, 0x6f, 0x2f, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2f,
-	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x6e, 0x6f, 0x64, 0x65, 0x70, 0x62, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x68,
+	0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x65, 0x74, 0x22, 0x4d, 0x0a, 0x0c, 0x50, 0x69, 0x6e, 0x67,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3d, 0x0a, 0x0a, 0x68, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x5f, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x68, 0x65,
+	0x61, 0x6c, 0x74, 0x68, 0x53, 0x65, 0x74, 0x22, 0x5a, 0x0a, 0x10, 0x42, 0x72, 0x6f, 0x61, 0x64,
+	0x63, 0x61, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6d,
+	0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x14, 0x0a,
+	0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x6c, 0x65,
+	0x76, 0x65, 0x6c, 0x22, 0x4c, 0x0a, 0x11, 0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61,
+	0x73, 0x74, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x73, 0x22, 0x83, 0x01, 0x0a, 0x0f, 0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e,
+	0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e,
+	0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x05, 0x72, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x52, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x63, 0x65,
+	0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x22, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x44, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x19, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x5f, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x07, 0x6d, 0x61, 0x78, 0x48, 0x6f, 0x70, 0x73, 0x22, 0x40, 0x0a, 0x12, 0x54,
+	0x72, 0x61, 0x63, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x2a, 0x0a, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x54,
+	0x72, 0x61, 0x63, 0x65, 0x48, 0x6f, 0x70, 0x52, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x22, 0x55, 0x0a,
+	0x08, 0x54, 0x72, 0x61, 0x63, 0x65, 0x48, 0x6f, 0x70, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f,
+	0x72, 0x52, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x74, 0x74, 0x5f, 0x6e,
+	0x61, 0x6e, 0x6f, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72, 0x74, 0x74, 0x4e,
+	0x61, 0x6e, 0x6f, 0x73, 0x22, 0x15, 0x0a, 0x13, 0x52, 0x69, 0x6e, 0x67, 0x43, 0x68, 0x65, 0x63,
+	0x6b, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xd8, 0x01, 0x0a, 0x14,
+	0x52, 0x69, 0x6e, 0x67, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04, 0x6e, 0x6f,
+	0x64, 0x65, 0x12, 0x3c, 0x0a, 0x0c, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f,
+	0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73,
+	0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x6f, 0x72, 0x52, 0x0c, 0x70, 0x72, 0x65, 0x64, 0x65, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73,
+	0x12, 0x38, 0x0a, 0x0a, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x0a,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x68,
+	0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x22, 0x3c, 0x0a, 0x0c, 0x45, 0x76, 0x69, 0x63, 0x74, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x52, 0x04,
+	0x6e, 0x6f, 0x64, 0x65, 0x2a, 0x2e, 0x0a, 0x06, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x0b,
+	0x0a, 0x07, 0x48, 0x45, 0x41, 0x4c, 0x54, 0x48, 0x59, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x55,
+	0x4e, 0x48, 0x45, 0x41, 0x4c, 0x54, 0x48, 0x59, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x44, 0x45,
+	0x41, 0x44, 0x10, 0x02, 0x32, 0xe6, 0x06, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x39, 0x0a,
+	0x04, 0x4a, 0x6f, 0x69, 0x6e, 0x12, 0x19, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e,
+	0x74, 0x2e, 0x76, 0x31, 0x2e, 0x4a, 0x6f, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x12, 0x40, 0x0a, 0x05, 0x48, 0x65, 0x6c, 0x6c,
+	0x6f, 0x12, 0x1a, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e,
+	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c,
+	0x6c, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4a, 0x0a, 0x0a, 0x48, 0x65,
+	0x6c, 0x6c, 0x6f, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x1f, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73,
+	0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x44, 0x65, 0x6c,
+	0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x47, 0x0a, 0x06, 0x47, 0x6f, 0x73, 0x73, 0x69, 0x70,
+	0x12, 0x1b, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x6f, 0x73, 0x73, 0x69, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e,
+	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x6f, 0x73,
+	0x73, 0x69, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12,
+	0x3f, 0x0a, 0x07, 0x47, 0x6f, 0x6f, 0x64, 0x62, 0x79, 0x65, 0x12, 0x1c, 0x2e, 0x63, 0x72, 0x6f,
+	0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x6f, 0x6f, 0x64, 0x62, 0x79,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
+	0x12, 0x49, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x72,
+	0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4e, 0x0a, 0x0e, 0x47,
+	0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x65, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x1d, 0x2e,
+	0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53,
+	0x74, 0x61, 0x74, 0x65, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x30, 0x01, 0x12, 0x3d, 0x0a, 0x04, 0x50,
+	0x69, 0x6e, 0x67, 0x12, 0x19, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e,
+	0x76, 0x31, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a,
+	0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x69,
+	0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4c, 0x0a, 0x09, 0x42, 0x72,
+	0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74, 0x12, 0x1e, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x72, 0x6f, 0x61, 0x64, 0x63, 0x61, 0x73, 0x74,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4f, 0x0a, 0x0a, 0x54, 0x72, 0x61, 0x63,
+	0x65, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x12, 0x1f, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61,
+	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x63, 0x65, 0x52, 0x6f, 0x75, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73,
+	0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x72, 0x61, 0x63, 0x65, 0x52, 0x6f, 0x75, 0x74,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x55, 0x0a, 0x0c, 0x52, 0x69, 0x6e,
+	0x67, 0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x12, 0x21, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x69, 0x6e, 0x67, 0x43, 0x68, 0x65,
+	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63,
+	0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x69, 0x6e, 0x67,
+	0x43, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x3b, 0x0a, 0x05, 0x45, 0x76, 0x69, 0x63, 0x74, 0x12, 0x1a, 0x2e, 0x63, 0x72, 0x6f, 0x69,
+	0x73, 0x73, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x76, 0x69, 0x63, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x42, 0x2e, 0x5a,
+	0x2c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x72, 0x66, 0x72, 0x61,
+	0x74, 0x74, 0x6f, 0x2f, 0x63, 0x72, 0x6f, 0x69, 0x73, 0x73, 0x61, 0x6e, 0x74, 0x2f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x6e, 0x6f, 0x64, 0x65, 0x70, 0x62, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -812,53 +2226,119 @@ func file_node_proto_rawDescGZIP() []byte {
 }
 
 var file_node_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_node_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_node_proto_msgTypes = make([]protoimpl.MessageInfo, 30)
 var file_node_proto_goTypes = []interface{}{
-	(Health)(0),              // 0: croissant.v1.Health
-	(*JoinRequest)(nil),      // 1: croissant.v1.JoinRequest
-	(*Descriptor)(nil),       // 2: croissant.v1.Descriptor
-	(*ID)(nil),               // 3: croissant.v1.ID
-	(*HelloRequest)(nil),     // 4: croissant.v1.HelloRequest
-	(*HelloResponse)(nil),    // 5: croissant.v1.HelloResponse
-	(*State)(nil),            // 6: croissant.v1.State
-	(*DescriptorHealth)(nil), // 7: croissant.v1.DescriptorHealth
-	(*GetStateRequest)(nil),  // 8: croissant.v1.GetStateRequest
-	(*GetStateResponse)(nil), // 9: croissant.v1.GetStateResponse
-	(*GoodbyeRequest)(nil),   // 10: croissant.v1.GoodbyeRequest
-	nil,                      // 11: croissant.v1.State.RoutingEntry
-	(*emptypb.Empty)(nil),    // 12: google.protobuf.Empty
+	(Health)(0),                  // 0: croissant.v1.Health
+	(*JoinRequest)(nil),          // 1: croissant.v1.JoinRequest
+	(*Descriptor)(nil),           // 2: croissant.v1.Descriptor
+	(*ID)(nil),                   // 3: croissant.v1.ID
+	(*HelloRequest)(nil),         // 4: croissant.v1.HelloRequest
+	(*HelloDeltaRequest)(nil),    // 5: croissant.v1.HelloDeltaRequest
+	(*HelloResponse)(nil),        // 6: croissant.v1.HelloResponse
+	(*GossipRequest)(nil),        // 7: croissant.v1.GossipRequest
+	(*Heartbeat)(nil),            // 8: croissant.v1.Heartbeat
+	(*GossipResponse)(nil),       // 9: croissant.v1.GossipResponse
+	(*State)(nil),                // 10: croissant.v1.State
+	(*StateDelta)(nil),           // 11: croissant.v1.StateDelta
+	(*DescriptorHealth)(nil),     // 12: croissant.v1.DescriptorHealth
+	(*GetStateRequest)(nil),      // 13: croissant.v1.GetStateRequest
+	(*GetStateResponse)(nil),     // 14: croissant.v1.GetStateResponse
+	(*GetStateChunk)(nil),        // 15: croissant.v1.GetStateChunk
+	(*GoodbyeRequest)(nil),       // 16: croissant.v1.GoodbyeRequest
+	(*PingRequest)(nil),          // 17: croissant.v1.PingRequest
+	(*PingResponse)(nil),         // 18: croissant.v1.PingResponse
+	(*BroadcastRequest)(nil),     // 19: croissant.v1.BroadcastRequest
+	(*BroadcastResponse)(nil),    // 20: croissant.v1.BroadcastResponse
+	(*BroadcastResult)(nil),      // 21: croissant.v1.BroadcastResult
+	(*TraceRouteRequest)(nil),    // 22: croissant.v1.TraceRouteRequest
+	(*TraceRouteResponse)(nil),   // 23: croissant.v1.TraceRouteResponse
+	(*TraceHop)(nil),             // 24: croissant.v1.TraceHop
+	(*RingChecksumRequest)(nil),  // 25: croissant.v1.RingChecksumRequest
+	(*RingChecksumResponse)(nil), // 26: croissant.v1.RingChecksumResponse
+	(*EvictRequest)(nil),         // 27: croissant.v1.EvictRequest
+	nil,                          // 28: croissant.v1.Descriptor.LabelsEntry
+	nil,                          // 29: croissant.v1.State.RoutingEntry
+	nil,                          // 30: croissant.v1.StateDelta.RoutingEntry
+	(*emptypb.Empty)(nil),        // 31: google.protobuf.Empty
 }
 var file_node_proto_depIdxs = []int32{
 	2,  // 0: croissant.v1.JoinRequest.joiner:type_name -> croissant.v1.Descriptor
-	3,  // 1: croissant.v1.Descriptor.id:type_name -> croissant.v1.ID
-	2,  // 2: croissant.v1.HelloRequest.initiator:type_name -> croissant.v1.Descriptor
-	2,  // 3: croissant.v1.HelloRequest.next:type_name -> croissant.v1.Descriptor
-	6,  // 4: croissant.v1.HelloRequest.state:type_name -> croissant.v1.State
-	6,  // 5: croissant.v1.HelloResponse.new_state:type_name -> croissant.v1.State
-	2,  // 6: croissant.v1.State.node:type_name -> croissant.v1.Descriptor
-	2,  // 7: croissant.v1.State.predecessors:type_name -> croissant.v1.Descriptor
-	2,  // 8: croissant.v1.State.successors:type_name -> croissant.v1.Descriptor
-	11, // 9: croissant.v1.State.routing:type_name -> croissant.v1.State.RoutingEntry
-	2,  // 10: croissant.v1.State.neighborhood:type_name -> croissant.v1.Descriptor
-	7,  // 11: croissant.v1.State.health_set:type_name -> croissant.v1.DescriptorHealth
-	2,  // 12: croissant.v1.DescriptorHealth.peer:type_name -> croissant.v1.Descriptor
-	0,  // 13: croissant.v1.DescriptorHealth.health:type_name -> croissant.v1.Health
-	6,  // 14: croissant.v1.GetStateResponse.state:type_name -> croissant.v1.State
-	2,  // 15: croissant.v1.GoodbyeRequest.node:type_name -> croissant.v1.Descriptor
-	2,  // 16: croissant.v1.State.RoutingEntry.value:type_name -> croissant.v1.Descriptor
-	1,  // 17: croissant.v1.Node.Join:input_type -> croissant.v1.JoinRequest
-	4,  // 18: croissant.v1.Node.Hello:input_type -> croissant.v1.HelloRequest
-	10, // 19: croissant.v1.Node.Goodbye:input_type -> croissant.v1.GoodbyeRequest
-	8,  // 20: croissant.v1.Node.GetState:input_type -> croissant.v1.GetStateRequest
-	12, // 21: croissant.v1.Node.Join:output_type -> google.protobuf.Empty
-	5,  // 22: croissant.v1.Node.Hello:output_type -> croissant.v1.HelloResponse
-	12, // 23: croissant.v1.Node.Goodbye:output_type -> google.protobuf.Empty
-	9,  // 24: croissant.v1.Node.GetState:output_type -> croissant.v1.GetStateResponse
-	21, // [21:25] is the sub-list for method output_type
-	17, // [17:21] is the sub-list for method input_type
-	17, // [17:17] is the sub-list for extension type_name
-	17, // [17:17] is the sub-list for extension extendee
-	0,  // [0:17] is the sub-list for field type_name
+	2,  // 1: croissant.v1.JoinRequest.path:type_name -> croissant.v1.Descriptor
+	3,  // 2: croissant.v1.Descriptor.id:type_name -> croissant.v1.ID
+	28, // 3: croissant.v1.Descriptor.labels:type_name -> croissant.v1.Descriptor.LabelsEntry
+	2,  // 4: croissant.v1.HelloRequest.initiator:type_name -> croissant.v1.Descriptor
+	2,  // 5: croissant.v1.HelloRequest.next:type_name -> croissant.v1.Descriptor
+	10, // 6: croissant.v1.HelloRequest.state:type_name -> croissant.v1.State
+	2,  // 7: croissant.v1.HelloDeltaRequest.initiator:type_name -> croissant.v1.Descriptor
+	2,  // 8: croissant.v1.HelloDeltaRequest.next:type_name -> croissant.v1.Descriptor
+	11, // 9: croissant.v1.HelloDeltaRequest.delta:type_name -> croissant.v1.StateDelta
+	10, // 10: croissant.v1.HelloResponse.new_state:type_name -> croissant.v1.State
+	11, // 11: croissant.v1.HelloResponse.new_state_delta:type_name -> croissant.v1.StateDelta
+	4,  // 12: croissant.v1.GossipRequest.hello:type_name -> croissant.v1.HelloRequest
+	5,  // 13: croissant.v1.GossipRequest.hello_delta:type_name -> croissant.v1.HelloDeltaRequest
+	8,  // 14: croissant.v1.GossipRequest.heartbeat:type_name -> croissant.v1.Heartbeat
+	6,  // 15: croissant.v1.GossipResponse.hello:type_name -> croissant.v1.HelloResponse
+	2,  // 16: croissant.v1.State.node:type_name -> croissant.v1.Descriptor
+	2,  // 17: croissant.v1.State.predecessors:type_name -> croissant.v1.Descriptor
+	2,  // 18: croissant.v1.State.successors:type_name -> croissant.v1.Descriptor
+	29, // 19: croissant.v1.State.routing:type_name -> croissant.v1.State.RoutingEntry
+	2,  // 20: croissant.v1.State.neighborhood:type_name -> croissant.v1.Descriptor
+	12, // 21: croissant.v1.State.health_set:type_name -> croissant.v1.DescriptorHealth
+	2,  // 22: croissant.v1.StateDelta.node:type_name -> croissant.v1.Descriptor
+	2,  // 23: croissant.v1.StateDelta.predecessors:type_name -> croissant.v1.Descriptor
+	2,  // 24: croissant.v1.StateDelta.successors:type_name -> croissant.v1.Descriptor
+	2,  // 25: croissant.v1.StateDelta.neighborhood:type_name -> croissant.v1.Descriptor
+	30, // 26: croissant.v1.StateDelta.routing:type_name -> croissant.v1.StateDelta.RoutingEntry
+	12, // 27: croissant.v1.StateDelta.health_set:type_name -> croissant.v1.DescriptorHealth
+	2,  // 28: croissant.v1.StateDelta.untracked:type_name -> croissant.v1.Descriptor
+	2,  // 29: croissant.v1.DescriptorHealth.peer:type_name -> croissant.v1.Descriptor
+	0,  // 30: croissant.v1.DescriptorHealth.health:type_name -> croissant.v1.Health
+	10, // 31: croissant.v1.GetStateResponse.state:type_name -> croissant.v1.State
+	10, // 32: croissant.v1.GetStateChunk.state:type_name -> croissant.v1.State
+	2,  // 33: croissant.v1.GoodbyeRequest.node:type_name -> croissant.v1.Descriptor
+	2,  // 34: croissant.v1.PingRequest.target:type_name -> croissant.v1.Descriptor
+	12, // 35: croissant.v1.PingRequest.health_set:type_name -> croissant.v1.DescriptorHealth
+	12, // 36: croissant.v1.PingResponse.health_set:type_name -> croissant.v1.DescriptorHealth
+	21, // 37: croissant.v1.BroadcastResponse.results:type_name -> croissant.v1.BroadcastResult
+	2,  // 38: croissant.v1.BroadcastResult.node:type_name -> croissant.v1.Descriptor
+	3,  // 39: croissant.v1.TraceRouteRequest.key:type_name -> croissant.v1.ID
+	24, // 40: croissant.v1.TraceRouteResponse.hops:type_name -> croissant.v1.TraceHop
+	2,  // 41: croissant.v1.TraceHop.node:type_name -> croissant.v1.Descriptor
+	2,  // 42: croissant.v1.RingChecksumResponse.node:type_name -> croissant.v1.Descriptor
+	2,  // 43: croissant.v1.RingChecksumResponse.predecessors:type_name -> croissant.v1.Descriptor
+	2,  // 44: croissant.v1.RingChecksumResponse.successors:type_name -> croissant.v1.Descriptor
+	2,  // 45: croissant.v1.EvictRequest.node:type_name -> croissant.v1.Descriptor
+	2,  // 46: croissant.v1.State.RoutingEntry.value:type_name -> croissant.v1.Descriptor
+	2,  // 47: croissant.v1.StateDelta.RoutingEntry.value:type_name -> croissant.v1.Descriptor
+	1,  // 48: croissant.v1.Node.Join:input_type -> croissant.v1.JoinRequest
+	4,  // 49: croissant.v1.Node.Hello:input_type -> croissant.v1.HelloRequest
+	5,  // 50: croissant.v1.Node.HelloDelta:input_type -> croissant.v1.HelloDeltaRequest
+	7,  // 51: croissant.v1.Node.Gossip:input_type -> croissant.v1.GossipRequest
+	16, // 52: croissant.v1.Node.Goodbye:input_type -> croissant.v1.GoodbyeRequest
+	13, // 53: croissant.v1.Node.GetState:input_type -> croissant.v1.GetStateRequest
+	13, // 54: croissant.v1.Node.GetStateStream:input_type -> croissant.v1.GetStateRequest
+	17, // 55: croissant.v1.Node.Ping:input_type -> croissant.v1.PingRequest
+	19, // 56: croissant.v1.Node.Broadcast:input_type -> croissant.v1.BroadcastRequest
+	22, // 57: croissant.v1.Node.TraceRoute:input_type -> croissant.v1.TraceRouteRequest
+	25, // 58: croissant.v1.Node.RingChecksum:input_type -> croissant.v1.RingChecksumRequest
+	27, // 59: croissant.v1.Node.Evict:input_type -> croissant.v1.EvictRequest
+	31, // 60: croissant.v1.Node.Join:output_type -> google.protobuf.Empty
+	6,  // 61: croissant.v1.Node.Hello:output_type -> croissant.v1.HelloResponse
+	6,  // 62: croissant.v1.Node.HelloDelta:output_type -> croissant.v1.HelloResponse
+	9,  // 63: croissant.v1.Node.Gossip:output_type -> croissant.v1.GossipResponse
+	31, // 64: croissant.v1.Node.Goodbye:output_type -> google.protobuf.Empty
+	14, // 65: croissant.v1.Node.GetState:output_type -> croissant.v1.GetStateResponse
+	15, // 66: croissant.v1.Node.GetStateStream:output_type -> croissant.v1.GetStateChunk
+	18, // 67: croissant.v1.Node.Ping:output_type -> croissant.v1.PingResponse
+	20, // 68: croissant.v1.Node.Broadcast:output_type -> croissant.v1.BroadcastResponse
+	23, // 69: croissant.v1.Node.TraceRoute:output_type -> croissant.v1.TraceRouteResponse
+	26, // 70: croissant.v1.Node.RingChecksum:output_type -> croissant.v1.RingChecksumResponse
+	31, // 71: croissant.v1.Node.Evict:output_type -> google.protobuf.Empty
+	60, // [60:72] is the sub-list for method output_type
+	48, // [48:60] is the sub-list for method input_type
+	48, // [48:48] is the sub-list for extension type_name
+	48, // [48:48] is the sub-list for extension extendee
+	0,  // [0:48] is the sub-list for field type_name
 }
 
 func init() { file_node_proto_init() }
@@ -916,7 +2396,7 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HelloResponse); i {
+			switch v := v.(*HelloDeltaRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -928,7 +2408,7 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*State); i {
+			switch v := v.(*HelloResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -940,7 +2420,7 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DescriptorHealth); i {
+			switch v := v.(*GossipRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -952,7 +2432,7 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetStateRequest); i {
+			switch v := v.(*Heartbeat); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -964,7 +2444,7 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetStateResponse); i {
+			switch v := v.(*GossipResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -976,6 +2456,78 @@ func file_node_proto_init() {
 			}
 		}
 		file_node_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*State); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StateDelta); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DescriptorHealth); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetStateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetStateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetStateChunk); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*GoodbyeRequest); i {
 			case 0:
 				return &v.state
@@ -987,6 +2539,143 @@ func file_node_proto_init() {
 				return nil
 			}
 		}
+		file_node_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PingRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PingResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BroadcastRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BroadcastResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BroadcastResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TraceRouteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TraceRouteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TraceHop); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RingChecksumRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RingChecksumResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_node_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EvictRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_node_proto_msgTypes[6].OneofWrappers = []interface{}{
+		(*GossipRequest_Hello)(nil),
+		(*GossipRequest_HelloDelta)(nil),
+		(*GossipRequest_Heartbeat)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -994,7 +2683,7 @@ func file_node_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_node_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   11,
+			NumMessages:   30,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -25,10 +25,50 @@ type NodeClient interface {
 	// HelloRequest is an Ack and state has been changed, should respond
 	// with the new state.
 	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// HelloDelta is like Hello, but only sends the changes to the initiator's
+	// state since a previous Hello. If the receiver doesn't know about the
+	// state the changes are based on, it should respond with unknown_base, and
+	// the initiator should send a Hello with the full state instead.
+	HelloDelta(ctx context.Context, in *HelloDeltaRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// Gossip opens a long-lived stream used by the initiator to send Hellos
+	// to a neighbor instead of calling Hello repeatedly. Each request sent on
+	// the stream receives exactly one response, in order. Heartbeats are sent
+	// when the initiator's state hasn't changed. Either side should treat the
+	// stream breaking without the initiator closing it as a failure of the
+	// other side.
+	Gossip(ctx context.Context, opts ...grpc.CallOption) (Node_GossipClient, error)
 	// Goodbye informs a node that a node is leaving the cluster.
 	Goodbye(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// GetState requests the state tables for this node.
 	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*GetStateResponse, error)
+	// GetStateStream is like GetState, but sends the state in chunks so large
+	// states don't exceed message size limits.
+	GetStateStream(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (Node_GetStateStreamClient, error)
+	// Ping checks that a node is reachable. If target is set to another node,
+	// the receiver pings target on behalf of the sender and fails if target is
+	// unreachable. Used for SWIM-style failure detection.
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	// Broadcast invokes a method on the receiver and every node in its
+	// subtree of the broadcast tree. The subtree holds the nodes reachable
+	// through rows level and above of the receiver's routing table; the
+	// receiver forwards the Broadcast to each of those nodes with level set
+	// to the row after the one they were found in. Responses of every node
+	// in the subtree are returned.
+	Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error)
+	// TraceRoute records the path a key takes through the cluster. The
+	// receiver forwards the request to its next hop for key until the node
+	// closest to key is reached. Used for debugging routing.
+	TraceRoute(ctx context.Context, in *TraceRouteRequest, opts ...grpc.CallOption) (*TraceRouteResponse, error)
+	// RingChecksum returns the receiver's view of its neighborhood in the
+	// ring: its healthy predecessors and successors along with a checksum over
+	// them. Used by operators to verify that nodes agree on the membership of
+	// the ring.
+	RingChecksum(ctx context.Context, in *RingChecksumRequest, opts ...grpc.CallOption) (*RingChecksumResponse, error)
+	// Evict informs a node that an operator has forcibly removed a node from
+	// the cluster, such as one that died without sending a Goodbye. The
+	// receiver treats the node as dead and removes it from its state tables.
+	// Evict is sent to every node in the cluster through Broadcast.
+	Evict(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type nodeClient struct {
@@ -57,6 +97,46 @@ func (c *nodeClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *nodeClient) HelloDelta(ctx context.Context, in *HelloDeltaRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	out := new(HelloResponse)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/HelloDelta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Gossip(ctx context.Context, opts ...grpc.CallOption) (Node_GossipClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[0], "/croissant.v1.Node/Gossip", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeGossipClient{stream}
+	return x, nil
+}
+
+type Node_GossipClient interface {
+	Send(*GossipRequest) error
+	Recv() (*GossipResponse, error)
+	grpc.ClientStream
+}
+
+type nodeGossipClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeGossipClient) Send(m *GossipRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *nodeGossipClient) Recv() (*GossipResponse, error) {
+	m := new(GossipResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *nodeClient) Goodbye(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, "/croissant.v1.Node/Goodbye", in, out, opts...)
@@ -75,6 +155,83 @@ func (c *nodeClient) GetState(ctx context.Context, in *GetStateRequest, opts ...
 	return out, nil
 }
 
+func (c *nodeClient) GetStateStream(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (Node_GetStateStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Node_ServiceDesc.Streams[1], "/croissant.v1.Node/GetStateStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nodeGetStateStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Node_GetStateStreamClient interface {
+	Recv() (*GetStateChunk, error)
+	grpc.ClientStream
+}
+
+type nodeGetStateStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *nodeGetStateStreamClient) Recv() (*GetStateChunk, error) {
+	m := new(GetStateChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *nodeClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/Ping", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error) {
+	out := new(BroadcastResponse)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/Broadcast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) TraceRoute(ctx context.Context, in *TraceRouteRequest, opts ...grpc.CallOption) (*TraceRouteResponse, error) {
+	out := new(TraceRouteResponse)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/TraceRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) RingChecksum(ctx context.Context, in *RingChecksumRequest, opts ...grpc.CallOption) (*RingChecksumResponse, error) {
+	out := new(RingChecksumResponse)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/RingChecksum", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Evict(ctx context.Context, in *EvictRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/croissant.v1.Node/Evict", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NodeServer is the server API for Node service.
 // All implementations must embed UnimplementedNodeServer
 // for forward compatibility
@@ -85,10 +242,50 @@ type NodeServer interface {
 	// HelloRequest is an Ack and state has been changed, should respond
 	// with the new state.
 	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
+	// HelloDelta is like Hello, but only sends the changes to the initiator's
+	// state since a previous Hello. If the receiver doesn't know about the
+	// state the changes are based on, it should respond with unknown_base, and
+	// the initiator should send a Hello with the full state instead.
+	HelloDelta(context.Context, *HelloDeltaRequest) (*HelloResponse, error)
+	// Gossip opens a long-lived stream used by the initiator to send Hellos
+	// to a neighbor instead of calling Hello repeatedly. Each request sent on
+	// the stream receives exactly one response, in order. Heartbeats are sent
+	// when the initiator's state hasn't changed. Either side should treat the
+	// stream breaking without the initiator closing it as a failure of the
+	// other side.
+	Gossip(Node_GossipServer) error
 	// Goodbye informs a node that a node is leaving the cluster.
 	Goodbye(context.Context, *GoodbyeRequest) (*emptypb.Empty, error)
 	// GetState requests the state tables for this node.
 	GetState(context.Context, *GetStateRequest) (*GetStateResponse, error)
+	// GetStateStream is like GetState, but sends the state in chunks so large
+	// states don't exceed message size limits.
+	GetStateStream(*GetStateRequest, Node_GetStateStreamServer) error
+	// Ping checks that a node is reachable. If target is set to another node,
+	// the receiver pings target on behalf of the sender and fails if target is
+	// unreachable. Used for SWIM-style failure detection.
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	// Broadcast invokes a method on the receiver and every node in its
+	// subtree of the broadcast tree. The subtree holds the nodes reachable
+	// through rows level and above of the receiver's routing table; the
+	// receiver forwards the Broadcast to each of those nodes with level set
+	// to the row after the one they were found in. Responses of every node
+	// in the subtree are returned.
+	Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error)
+	// TraceRoute records the path a key takes through the cluster. The
+	// receiver forwards the request to its next hop for key until the node
+	// closest to key is reached. Used for debugging routing.
+	TraceRoute(context.Context, *TraceRouteRequest) (*TraceRouteResponse, error)
+	// RingChecksum returns the receiver's view of its neighborhood in the
+	// ring: its healthy predecessors and successors along with a checksum over
+	// them. Used by operators to verify that nodes agree on the membership of
+	// the ring.
+	RingChecksum(context.Context, *RingChecksumRequest) (*RingChecksumResponse, error)
+	// Evict informs a node that an operator has forcibly removed a node from
+	// the cluster, such as one that died without sending a Goodbye. The
+	// receiver treats the node as dead and removes it from its state tables.
+	// Evict is sent to every node in the cluster through Broadcast.
+	Evict(context.Context, *EvictRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedNodeServer()
 }
 
@@ -102,12 +299,36 @@ func (UnimplementedNodeServer) Join(context.Context, *JoinRequest) (*emptypb.Emp
 func (UnimplementedNodeServer) Hello(context.Context, *HelloRequest) (*HelloResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Hello not implemented")
 }
+func (UnimplementedNodeServer) HelloDelta(context.Context, *HelloDeltaRequest) (*HelloResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HelloDelta not implemented")
+}
+func (UnimplementedNodeServer) Gossip(Node_GossipServer) error {
+	return status.Errorf(codes.Unimplemented, "method Gossip not implemented")
+}
 func (UnimplementedNodeServer) Goodbye(context.Context, *GoodbyeRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Goodbye not implemented")
 }
 func (UnimplementedNodeServer) GetState(context.Context, *GetStateRequest) (*GetStateResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
 }
+func (UnimplementedNodeServer) GetStateStream(*GetStateRequest, Node_GetStateStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetStateStream not implemented")
+}
+func (UnimplementedNodeServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
+func (UnimplementedNodeServer) Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Broadcast not implemented")
+}
+func (UnimplementedNodeServer) TraceRoute(context.Context, *TraceRouteRequest) (*TraceRouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TraceRoute not implemented")
+}
+func (UnimplementedNodeServer) RingChecksum(context.Context, *RingChecksumRequest) (*RingChecksumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RingChecksum not implemented")
+}
+func (UnimplementedNodeServer) Evict(context.Context, *EvictRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Evict not implemented")
+}
 func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
 
 // UnsafeNodeServer may be embedded to opt out of forward compatibility for this service.
@@ -157,6 +378,50 @@ func _Node_Hello_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Node_HelloDelta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloDeltaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).HelloDelta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/HelloDelta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).HelloDelta(ctx, req.(*HelloDeltaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Gossip_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NodeServer).Gossip(&nodeGossipServer{stream})
+}
+
+type Node_GossipServer interface {
+	Send(*GossipResponse) error
+	Recv() (*GossipRequest, error)
+	grpc.ServerStream
+}
+
+type nodeGossipServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeGossipServer) Send(m *GossipResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *nodeGossipServer) Recv() (*GossipRequest, error) {
+	m := new(GossipRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _Node_Goodbye_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GoodbyeRequest)
 	if err := dec(in); err != nil {
@@ -193,6 +458,117 @@ func _Node_GetState_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Node_GetStateStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NodeServer).GetStateStream(m, &nodeGetStateStreamServer{stream})
+}
+
+type Node_GetStateStreamServer interface {
+	Send(*GetStateChunk) error
+	grpc.ServerStream
+}
+
+type nodeGetStateStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *nodeGetStateStreamServer) Send(m *GetStateChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Node_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Broadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Broadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/Broadcast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Broadcast(ctx, req.(*BroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_TraceRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TraceRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).TraceRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/TraceRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).TraceRoute(ctx, req.(*TraceRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_RingChecksum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RingChecksumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).RingChecksum(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/RingChecksum",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).RingChecksum(ctx, req.(*RingChecksumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Evict_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Evict(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/croissant.v1.Node/Evict",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Evict(ctx, req.(*EvictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Node_ServiceDesc is the grpc.ServiceDesc for Node service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -208,6 +584,10 @@ var Node_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Hello",
 			Handler:    _Node_Hello_Handler,
 		},
+		{
+			MethodName: "HelloDelta",
+			Handler:    _Node_HelloDelta_Handler,
+		},
 		{
 			MethodName: "Goodbye",
 			Handler:    _Node_Goodbye_Handler,
@@ -216,7 +596,39 @@ var Node_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetState",
 			Handler:    _Node_GetState_Handler,
 		},
+		{
+			MethodName: "Ping",
+			Handler:    _Node_Ping_Handler,
+		},
+		{
+			MethodName: "Broadcast",
+			Handler:    _Node_Broadcast_Handler,
+		},
+		{
+			MethodName: "TraceRoute",
+			Handler:    _Node_TraceRoute_Handler,
+		},
+		{
+			MethodName: "RingChecksum",
+			Handler:    _Node_RingChecksum_Handler,
+		},
+		{
+			MethodName: "Evict",
+			Handler:    _Node_Evict_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Gossip",
+			Handler:       _Node_Gossip_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetStateStream",
+			Handler:       _Node_GetStateStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "node.proto",
 }
@@ -0,0 +1,5 @@
+// Package pubsubpb holds protobuf descriptions for the publish/subscribe
+// service of a Croissant cluster.
+package pubsubpb
+
+//go:generate protoc -I=../../api --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ../../api/pubsub.proto
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"github.com/rfratto/croissant/internal/nodepb"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// RegisterAdmin registers the cluster API to s like Register, along with the
+// standard gRPC health service and server reflection, so tools like grpcurl
+// and Kubernetes gRPC probes work with the node out of the box.
+//
+// The health service reports the overall health of the server and the
+// health of the croissant.v1.Node service. Both are SERVING once the node
+// has joined the cluster and NOT_SERVING before then, while the node is
+// draining, and once the node leaves. Applications that register their own
+// health service should call Register instead.
+func (n *Node) RegisterAdmin(s *grpc.Server) {
+	n.Register(s)
+
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+
+	n.mut.Lock()
+	n.healthServer = hs
+	n.mut.Unlock()
+	n.updateServingStatus()
+}
+
+// updateServingStatus updates the status reported by the health service
+// registered with RegisterAdmin.
+func (n *Node) updateServingStatus() {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	if n.healthServer == nil {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if n.joined && !n.controller.draining.Load() && !n.controller.leaving.Load() {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, service := range []string{"", nodepb.Node_ServiceDesc.ServiceName} {
+		n.healthServer.SetServingStatus(service, status)
+	}
+}
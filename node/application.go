@@ -1,6 +1,9 @@
 package node
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 )
@@ -8,21 +11,166 @@ import (
 // Application represents the application using the cluster. Methods will be
 // invoked by the node depending on the state of the cluster.
 type Application interface {
-	// PeersChanged is invoked when the set of peers changes.
+	// PeersChanged is invoked when the set of peers changes. ps holds the
+	// predecessors and successors of the node; implement TopologyWatcher to
+	// tell them apart.
 	PeersChanged(ps []Peer)
 }
 
+// SingleNodeWatcher may optionally be implemented by an Application to be
+// informed when the node becomes the only node in the cluster or when it
+// stops being the only node in the cluster.
+type SingleNodeWatcher interface {
+	// SingleNodeChanged is invoked with true when the node no longer has any
+	// healthy peers and with false when it has healthy peers again.
+	SingleNodeChanged(single bool)
+}
+
+// IsolationWatcher may optionally be implemented by an Application to be
+// informed when the node becomes isolated from the cluster because every one
+// of its peers died, and when it has peers again.
+type IsolationWatcher interface {
+	// IsolationChanged is invoked with true when the node becomes isolated
+	// and with false when it has healthy peers again.
+	IsolationChanged(isolated bool)
+}
+
+// ReplicaWatcher may optionally be implemented by an Application to be
+// informed when the set of nodes that should store replicas of the keys owned
+// by the node changes.
+type ReplicaWatcher interface {
+	// ReplicasChanged is invoked with the new set of replicas for the keys
+	// owned by the node. The local node is always first, followed by its
+	// successors in ring order.
+	// There will be at most Config.ReplicationFactor replicas.
+	ReplicasChanged(replicas []Peer)
+}
+
+// OwnershipWatcher may optionally be implemented by an Application to be
+// informed when the range of keys owned by the node changes, such as when a
+// peer joins or leaves next to the node. Applications can use this to hand off
+// data for keys to their new owner.
+type OwnershipWatcher interface {
+	// OwnershipChanged is invoked with the ranges of keys the node gained or
+	// lost ownership of.
+	OwnershipChanged(changes []OwnershipChange)
+}
+
+// TopologyWatcher may optionally be implemented by an Application to be told
+// which role each peer fills for the node, such as applications that
+// replicate data to successors specifically. TopologyChanged is invoked
+// whenever PeersChanged is.
+type TopologyWatcher interface {
+	// TopologyChanged is invoked with every peer in the state of the node
+	// and the role it fills. Peers filling more than one role, such as a
+	// successor that's also a neighbor, are included once per role.
+	//
+	// With virtual nodes, roles are relative to the first virtual node.
+	TopologyChanged(peers []PeerInfo)
+}
+
+// PeerRole is the role a peer fills in the state of the node.
+type PeerRole uint
+
+const (
+	// RolePredecessor peers precede the node in the ring.
+	RolePredecessor PeerRole = iota
+	// RoleSuccessor peers follow the node in the ring.
+	RoleSuccessor
+	// RoleNeighbor peers are geographically close to the node.
+	RoleNeighbor
+	// RoleRoute peers are entries in the routing table of the node.
+	RoleRoute
+)
+
+// String returns the name of r.
+func (r PeerRole) String() string {
+	switch r {
+	case RolePredecessor:
+		return "predecessor"
+	case RoleSuccessor:
+		return "successor"
+	case RoleNeighbor:
+		return "neighbor"
+	case RoleRoute:
+		return "route"
+	default:
+		return fmt.Sprintf("PeerRole(%d)", uint(r))
+	}
+}
+
+// PeerInfo is a peer and a role it fills for the node.
+type PeerInfo struct {
+	Peer Peer
+	Role PeerRole
+
+	// Index is the position of Peer within its role. Predecessors and
+	// successors are ordered from closest to farthest away from the node,
+	// so the immediate successor has Index 0. Neighbors are ordered like
+	// State.Neighbors. For routes, Index is the row of the routing table
+	// and Column is the column.
+	Index, Column int
+}
+
+// HandoffHandler may optionally be implemented by an Application to transfer
+// data to other nodes when the node leaves the cluster through Node.Leave.
+type HandoffHandler interface {
+	// Handoff is invoked with the ranges of keys owned by the node and the
+	// peers that will own them once the node leaves. Gained is always false.
+	// Handoff should return once data has been transferred to the new owners.
+	// ctx is canceled if the Leave is canceled.
+	Handoff(ctx context.Context, changes []OwnershipChange) error
+}
+
 // Peer is a peer in the cluster.
 type Peer struct {
 	ID   id.ID
 	Addr string
+
+	// Labels set by the peer through Config.Labels.
+	Labels Labels
+
+	// sig is the signature of the peer's descriptor when descriptors are
+	// signed. See Config.DescriptorSigner.
+	sig string
 }
 
 func getPeers(s *api.State) []Peer {
 	leaves := s.Leaves(true)
 	peers := make([]Peer, len(leaves))
 	for i, l := range leaves {
-		peers[i] = Peer{ID: l.ID, Addr: l.Addr}
+		peers[i] = peerFromDescriptor(l)
 	}
 	return peers
 }
+
+// getTopology returns the peers in s and their roles, excluding peers for
+// which skip returns true. Indexes don't count skipped peers.
+func getTopology(s *api.State, skip func(d api.Descriptor) bool) []PeerInfo {
+	s = s.Clone()
+
+	var res []PeerInfo
+	addList := func(role PeerRole, ds []api.Descriptor) {
+		var idx int
+		for _, d := range ds {
+			if skip(d) {
+				continue
+			}
+			res = append(res, PeerInfo{Peer: peerFromDescriptor(d), Role: role, Index: idx})
+			idx++
+		}
+	}
+	addList(RolePredecessor, closestFirst(s.Predecessors))
+	addList(RoleSuccessor, closestFirst(s.Successors))
+	addList(RoleNeighbor, s.Neighbors.Descriptors)
+
+	for row := range s.Routing {
+		for col, ent := range s.Routing[row] {
+			if ent == nil || skip(*ent) {
+				continue
+			}
+			res = append(res, PeerInfo{Peer: peerFromDescriptor(*ent), Role: RoleRoute, Index: row, Column: col})
+		}
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package node
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// JoinAuthorizer decides whether joiner may join the cluster. info holds
+// the transport credentials of the connection the join was received on,
+// such as credentials.TLSInfo when TLS is used, and is nil for insecure
+// connections.
+//
+// Returning a non-nil error rejects the join. Errors that aren't gRPC
+// status errors are returned to the joiner as PermissionDenied.
+type JoinAuthorizer func(ctx context.Context, joiner Peer, info credentials.AuthInfo) error
+
+// authorizeJoin checks whether joiner may join the cluster using the
+// configured JoinAuthorizer.
+func (n *Node) authorizeJoin(ctx context.Context, joiner api.Descriptor) error {
+	if n.cfg.JoinAuthorizer == nil {
+		return nil
+	}
+
+	var info credentials.AuthInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		info = p.AuthInfo
+	}
+
+	err := n.cfg.JoinAuthorizer(ctx, peerFromDescriptor(joiner), info)
+	if err == nil {
+		return nil
+	}
+	level.Warn(n.cfg.Log).Log("msg", "rejecting unauthorized join", "peer", joiner.Addr, "id", joiner.ID.String(), "err", err)
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.PermissionDenied, "join not authorized: %s", err)
+}
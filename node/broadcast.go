@@ -0,0 +1,158 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// BroadcastResponse is the response of a single node to a broadcast.
+type BroadcastResponse struct {
+	// Peer is the node that responded.
+	Peer Peer
+
+	// Reply is the response from Peer. nil if Err is set.
+	Reply proto.Message
+
+	// Err is the error returned by Peer, or the error that prevented the
+	// broadcast from reaching Peer.
+	Err error
+}
+
+// broadcast invokes method on every node in the cluster, including the local
+// node, and decodes their replies with newReply.
+//
+// The broadcast is disseminated as a tree over the routing tables of nodes:
+// the local node forwards the request to every entry in its routing table,
+// and each entry forwards it to the entries of its own routing table in the
+// rows after the row it was found in. Every node is reached once as long as
+// routing tables are complete.
+func (c *controller) broadcast(ctx context.Context, method string, req proto.Message, newReply func() proto.Message) ([]BroadcastResponse, error) {
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	results, err := c.Broadcast(ctx, api.Broadcast{Method: method, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+
+	resps := make([]BroadcastResponse, 0, len(results))
+	for _, r := range results {
+		resp := BroadcastResponse{Peer: peerFromDescriptor(r.Node), Err: r.Err}
+		if r.Err == nil {
+			reply := newReply()
+			if err := proto.Unmarshal(r.Reply, reply); err != nil {
+				resp.Err = fmt.Errorf("failed to decode reply: %w", err)
+			} else {
+				resp.Reply = reply
+			}
+		}
+		resps = append(resps, resp)
+	}
+	return resps, nil
+}
+
+// Broadcast implements api.Node. The local node handles b before forwarding
+// it to its subtree. Only the first virtual node of a node invokes b.Method,
+// so each node responds once.
+func (c *controller) Broadcast(ctx context.Context, b api.Broadcast) ([]api.BroadcastResult, error) {
+	var (
+		mut     sync.Mutex
+		results []api.BroadcastResult
+		wg      sync.WaitGroup
+	)
+	add := func(rs ...api.BroadcastResult) {
+		mut.Lock()
+		defer mut.Unlock()
+		results = append(results, rs...)
+	}
+
+	state := c.state.Clone()
+	for row := b.Level; row < len(state.Routing); row++ {
+		for _, ent := range state.Routing[row] {
+			if ent == nil || *ent == state.Node || state.Statuses[*ent] == api.Dead {
+				continue
+			}
+
+			wg.Add(1)
+			go func(peer api.Descriptor, row int) {
+				defer wg.Done()
+
+				rs, err := c.forwardBroadcast(ctx, peer, api.Broadcast{
+					Method:  b.Method,
+					Payload: b.Payload,
+					Level:   row,
+				})
+				if err != nil {
+					level.Warn(c.log).Log("msg", "failed to forward broadcast", "peer", peer.Addr, "err", err)
+					add(api.BroadcastResult{Node: peer, Err: err})
+					return
+				}
+				add(rs...)
+			}(*ent, row+1)
+		}
+	}
+
+	if c == c.vnodes[0] {
+		reply, err := c.invokeLocal(ctx, b.Method, b.Payload)
+		add(api.BroadcastResult{Node: state.Node, Reply: reply, Err: err})
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return id.Compare(results[i].Node.ID, results[j].Node.ID) < 0
+	})
+	return results, nil
+}
+
+// forwardBroadcast sends b to peer.
+func (c *controller) forwardBroadcast(ctx context.Context, peer api.Descriptor, b api.Broadcast) ([]api.BroadcastResult, error) {
+	cc, err := c.pool.GetReady(ctx, peer.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return c.nodeClient(cc).Broadcast(withTarget(ctx, peer), b)
+}
+
+// invokeLocal invokes method on the local node's gRPC server with the encoded
+// request payload, returning the encoded reply.
+func (c *controller) invokeLocal(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	cc, err := c.pool.GetReady(ctx, c.state.Node.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The request and reply are passed through as unknown fields of an empty
+	// message, so they don't need to be decoded.
+	var req, reply emptypb.Empty
+	if err := proto.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+
+	// Send the method as a final hop so it's handled by the local node.
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.New(nil)
+	}
+	setFinal(md)
+	c.injectTrace(ctx, md)
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	if err := cc.Invoke(ctx, method, &req, &reply); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&reply)
+}
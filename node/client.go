@@ -3,17 +3,40 @@ package node
 import (
 	"context"
 	"errors"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 var ErrSelfRouting = errors.New("route to self")
 
+// ErrNoRoute is returned when no node could be found to accept a key. This
+// can happen transiently while the node's state is incomplete, such as while
+// joining or when all peers are unhealthy, and the operation may be retried.
+var ErrNoRoute = errors.New("no route to key")
+
+// ErrRoutingLoop is returned when a request would be forwarded to a node it
+// was already forwarded through, which happens when the states of nodes are
+// inconsistent. The message of the error includes the path of the request.
+// Requests fail with an Aborted status error wrapping ErrRoutingLoop.
+var ErrRoutingLoop = errors.New("routing loop detected")
+
+// ErrMaxHops is returned when a request would be forwarded more times than
+// allowed by Config.MaxHops. The message of the error includes the path of
+// the request. Requests fail with an Aborted status error wrapping
+// ErrMaxHops.
+var ErrMaxHops = errors.New("request exceeded maximum number of hops")
+
 // ClientOption modifies a Client.
 type ClientOption func(c *Client)
 
@@ -24,22 +47,74 @@ func WithAllowSelfRouting(allow bool) ClientOption {
 	}
 }
 
-// WithForwardHook allows to hook into the forwarding functionality.
-// Hooks may change the address of where data is sent or modify the
-// message prior to sending.
-func WithForwardHook(hook func(Peer) (Peer, error)) ClientOption {
+// ForwardInfo describes a request that is about to be sent to a peer.
+type ForwardInfo struct {
+	// Method is the full name of the gRPC method, /package.Service/Method.
+	Method string
+	// Key is the routing key of the request.
+	Key id.ID
+	// Attempt is the number of the attempt to send the request, starting
+	// from 1.
+	Attempt int
+	// Hedged is true if the request is a hedged request. For hedged
+	// requests, Attempt is the number of the hedge, starting from 1.
+	Hedged bool
+}
+
+// ForwardHook is invoked before a request is sent to next, including
+// requests sent to the local node. Hooks may return a different peer to
+// send the request to, or an error to fail the request.
+type ForwardHook func(ctx context.Context, info ForwardInfo, next Peer) (Peer, error)
+
+// WithForwardHook allows to hook into the forwarding functionality. The
+// hook is invoked for unary requests, streams, and hedged requests.
+func WithForwardHook(hook ForwardHook) ClientOption {
 	return func(c *Client) {
 		c.forwardHook = hook
 	}
 }
 
+// WithLimiter limits the rate of requests sent to each peer. Requests that
+// would exceed the limit fail with ResourceExhausted. Requests handled by
+// the local node are never limited.
+func WithLimiter(l Limiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// WithRouteRetry configures the Client to retry finding a route when no node
+// can be found for a key, since the state may be updating. Routing will be
+// retried up to attempts times, waiting backoff before the first retry and
+// doubling the wait after each subsequent retry. Default is no retries.
+func WithRouteRetry(attempts int, backoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.routeRetries = attempts
+		c.routeBackoff = backoff
+	}
+}
+
 // Client is a transparent gRPC client interface to the cluster.
-// If a request context is missing a key from WithClientKey,
+// If a request is missing a key from WithKey or WithClientKey,
 // requests will fail with InvalidArgument.
 type Client struct {
-	ctrl        *controller
-	allowSelf   bool
-	forwardHook func(Peer) (Peer, error)
+	ctrl         *controller
+	allowSelf    bool
+	forwardHook  ForwardHook
+	keyExtractor KeyExtractor
+
+	routeRetries int
+	routeBackoff time.Duration
+
+	hedgeDelay time.Duration
+	maxHedges  int
+
+	nearestReplica bool
+
+	retryPolicy RetryPolicy
+	retryBudget *retryBudget
+
+	limiter Limiter
 }
 
 // NewClient creates a new server Client using the node for routing.
@@ -47,92 +122,354 @@ type Client struct {
 // if a node would connect to itself.
 func NewClient(n *Node, opts ...ClientOption) *Client {
 	c := &Client{
-		ctrl:      n.controller,
-		allowSelf: true,
+		ctrl:        n.controller,
+		allowSelf:   true,
+		retryPolicy: DefaultRetryPolicy,
 	}
 	for _, o := range opts {
 		o(c)
 	}
+	if c.retryPolicy.Budget != nil {
+		c.retryBudget = newRetryBudget(*c.retryPolicy.Budget, c.ctrl.clock)
+	}
 	return c
 }
 
 // Invoke makes a request against the cluster, routing the request to the
-// appropriate node. ctx must have a ClientKey set (via WithClientKey)
-// or the request will fail.
-func (c *Client) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
-	key, err := ExtractClientKey(ctx)
-	if err != nil {
-		return status.Errorf(codes.InvalidArgument, "missing or invalid routing key: %s", err.Error())
+// appropriate node. The call must have a key set with WithKey, ctx must
+// have a ClientKey set (via WithClientKey), or the Client must be able to
+// derive the key from args with its KeyExtractor, or the request will fail.
+// Failed requests are retried according to the Client's RetryPolicy.
+func (c *Client) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) (err error) {
+	ctx, span := c.ctrl.tracer.Start(ctx, "croissant.Client/Invoke")
+	defer func() { endSpan(span, err) }()
+	span.SetAttribute(attrMethod, method)
+
+	key, err := requestKey(ctx, c.keyExtractor, method, args, opts)
+	if errors.Is(err, ErrNoKey) {
+		return status.Errorf(codes.InvalidArgument, "missing or invalid routing key: %s", err)
+	} else if err != nil {
+		return err
 	}
+	span.SetAttribute(attrKey, key.String())
 
-Retry:
-	next, ok := api.NextHop(c.ctrl.state, key)
-	if !ok {
-		return status.Errorf(codes.Internal, "routing error: unable to find any node for key %s", key)
+	if c.hedgeDelay > 0 {
+		if msg, ok := reply.(proto.Message); ok {
+			return fromStatus(c.invokeHedged(ctx, span, key, method, args, msg, opts...))
+		}
 	}
+	return fromStatus(c.invoke(ctx, span, key, method, args, reply, opts...))
+}
+
+// invoke sends a request for key to the next hop, retrying according to the
+// Client's RetryPolicy.
+func (c *Client) invoke(ctx context.Context, span Span, key id.ID, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	r := c.newRetrier()
+	for {
+		attempt := r.attempt()
+		span.SetAttribute(attrAttempts, attempt)
 
-	if c.forwardHook != nil {
-		p := Peer{ID: next.ID, Addr: next.Addr}
-		p, err := c.forwardHook(p)
+		next, redirected, err := c.nextHop(ctx, key)
 		if err != nil {
 			return err
 		}
-		next = api.Descriptor{ID: p.ID, Addr: p.Addr}
+
+		next, err = c.hook(ctx, ForwardInfo{Method: method, Key: key, Attempt: attempt}, next)
+		if err != nil {
+			return err
+		}
+		span.SetAttribute(attrNextHop, next.Addr)
+
+		if c.ctrl.isLocal(next) && !c.allowSelf {
+			return ErrSelfRouting
+		}
+		if err := c.limit(next); err != nil {
+			return err
+		}
+		fwdCtx, err := c.forwardContext(ctx, key, next, redirected)
+		if err != nil {
+			return err
+		}
+
+		cc, err := c.getConn(ctx, next)
+		if err != nil && ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		} else if err != nil {
+			if err := r.retry(ctx, next, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = cc.Invoke(fwdCtx, method, args, reply, opts...)
+		if err != nil && (c.connFailed(cc, next, err) || r.retryable(err)) {
+			if err := r.retry(ctx, next, err); err != nil {
+				return err
+			}
+			continue
+		}
+		return err
 	}
+}
+
+// Broadcast invokes method on every node in the cluster. See Node.Broadcast
+// for details. Broadcasts ignore the routing key of ctx.
+func (c *Client) Broadcast(ctx context.Context, method string, req proto.Message, newReply func() proto.Message) ([]BroadcastResponse, error) {
+	return c.ctrl.broadcast(ctx, method, req, newReply)
+}
+
+// NewStream makes a request against the cluster, routing the request to the
+// appropriate node. The call must have a key set with WithKey or ctx must
+// have a ClientKey set (via WithClientKey), or the request will fail.
+// Failures to establish the stream are retried according to the Client's
+// RetryPolicy.
+//
+// The span traced for NewStream only covers establishing the stream.
+func (c *Client) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (_ grpc.ClientStream, err error) {
+	ctx, span := c.ctrl.tracer.Start(ctx, "croissant.Client/NewStream")
+	defer func() { endSpan(span, err) }()
+	span.SetAttribute(attrMethod, method)
 
-	if next == c.ctrl.state.Node && !c.allowSelf {
-		return ErrSelfRouting
+	key, err := requestKey(ctx, nil, method, nil, opts)
+	if errors.Is(err, ErrNoKey) {
+		return nil, status.Errorf(codes.InvalidArgument, "missing or invalid routing key: %s", err)
+	} else if err != nil {
+		return nil, err
 	}
+	span.SetAttribute(attrKey, key.String())
 
-	cc, err := c.ctrl.pool.Get(next.Addr)
-	if err != nil {
-		level.Info(c.ctrl.log).Log("msg", "failed to get conn to peer for routing", "peer", next.Addr, "err", err)
-		_ = c.ctrl.health.SetHealth(next, api.Unhealthy)
-		goto Retry
+	r := c.newRetrier()
+	for {
+		attempt := r.attempt()
+		span.SetAttribute(attrAttempts, attempt)
+
+		next, redirected, err := c.nextHop(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err = c.hook(ctx, ForwardInfo{Method: method, Key: key, Attempt: attempt}, next)
+		if err != nil {
+			return nil, err
+		}
+		span.SetAttribute(attrNextHop, next.Addr)
+
+		if c.ctrl.isLocal(next) && !c.allowSelf {
+			return nil, ErrSelfRouting
+		}
+		if err := c.limit(next); err != nil {
+			return nil, err
+		}
+		fwdCtx, err := c.forwardContext(ctx, key, next, redirected)
+		if err != nil {
+			return nil, err
+		}
+
+		cc, err := c.getConn(ctx, next)
+		if err != nil && ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		} else if err != nil {
+			if err := r.retry(ctx, next, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		cs, err := cc.NewStream(fwdCtx, desc, method, opts...)
+		if err != nil && (c.connFailed(cc, next, err) || r.retryable(err)) {
+			if err := r.retry(ctx, next, err); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return cs, fromStatus(err)
 	}
+}
 
-	err = cc.Invoke(ctx, method, args, reply, opts...)
-	if s := status.Convert(err); s != nil && s.Code() == codes.Unavailable && cc.GetState() == connectivity.TransientFailure {
-		level.Info(c.ctrl.log).Log("msg", "failed to request forward to peer", "peer", next.Addr, "err", err)
+// getConn returns a ready connection to next. If the connection fails, next
+// is marked as unhealthy and an Unavailable error is returned.
+func (c *Client) getConn(ctx context.Context, next api.Descriptor) (*grpc.ClientConn, error) {
+	cc, err := c.ctrl.pool.GetReady(ctx, next.Addr)
+	if err != nil && ctx.Err() == nil {
+		level.Info(c.ctrl.log).Log("msg", "failed to get conn to peer for routing", "peer", next.Addr, "err", err)
 		_ = c.ctrl.health.SetHealth(next, api.Unhealthy)
-		goto Retry
+
+		err = status.Errorf(codes.Unavailable, "failed to connect to %s: %s", next.Addr, err)
+		c.ctrl.pool.RecordResult(next.Addr, err)
+		return nil, err
 	}
+	return cc, err
+}
 
-	return err
+// connFailed returns true if err was caused by the connection to next
+// failing, in which case next is marked as unhealthy.
+func (c *Client) connFailed(cc *grpc.ClientConn, next api.Descriptor, err error) bool {
+	if status.Code(err) != codes.Unavailable || cc.GetState() != connectivity.TransientFailure {
+		return false
+	}
+	level.Info(c.ctrl.log).Log("msg", "failed to request forward to peer", "peer", next.Addr, "err", err)
+	_ = c.ctrl.health.SetHealth(next, api.Unhealthy)
+	return true
 }
 
-// NewStream makes a request against the cluster, routing the request to the
-// appropriate node. ctx must have a ClientKey set (via WithClientKey)
-// or the request will fail.
-func (c *Client) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	key, err := ExtractClientKey(ctx)
+// hook invokes the Client's ForwardHook for a request being sent to next,
+// returning the peer to send the request to.
+func (c *Client) hook(ctx context.Context, info ForwardInfo, next api.Descriptor) (api.Descriptor, error) {
+	if c.forwardHook == nil {
+		return next, nil
+	}
+	p, err := c.forwardHook(ctx, info, peerFromDescriptor(next))
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "missing or invalid routing key: %s", err.Error())
+		return api.Descriptor{}, err
 	}
+	return p.descriptor(), nil
+}
 
-Retry:
-	next, ok := api.NextHop(c.ctrl.state, key)
+// limit returns a ResourceExhausted error if sending a request to next
+// would exceed the Client's limiter.
+func (c *Client) limit(next api.Descriptor) error {
+	if c.limiter == nil || c.ctrl.isLocal(next) {
+		return nil
+	}
+	if !c.limiter.Allow(peerFromDescriptor(next)) {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for peer %s", next.Addr)
+	}
+	return nil
+}
+
+// nextHop finds the next hop for key, retrying with backoff if no route
+// could be found. Returns an Unavailable error wrapping ErrNoRoute if no route
+// was found after all retries. Keys owned by the local node are routed to
+// the next closest node while the node is draining; redirected is true when
+// that happens, and the next hop must handle the request itself.
+//
+// Peers with open circuits are skipped in favor of the next best candidate.
+// If the skipped peer was the closest node to key, redirected is true and
+// the candidate handles the request in its place.
+//
+// With WithNearestReplica, the nearest replica of key is used when the
+// replicas of key are known.
+func (c *Client) nextHop(ctx context.Context, key id.ID) (next api.Descriptor, redirected bool, err error) {
+	backoff := c.routeBackoff
+
+	for attempt := 0; ; attempt++ {
+		if c.nearestReplica {
+			if next, redirected, ok := c.nearestHop(key); ok {
+				c.ctrl.logRoute(key, next, "nearest")
+				return next, redirected, nil
+			}
+		}
+
+		next, source, ok := api.NextHopExplain(c.ctrl.routeState(key), key)
+		if ok && c.ctrl.isLocal(next) && c.ctrl.draining.Load() {
+			// Send requests for our own keys to the next closest node while
+			// draining.
+			if alt, found := c.ctrl.drainHop(key); found {
+				c.ctrl.logRoute(key, alt, "drain")
+				return alt, true, nil
+			}
+		}
+		if ok && !c.ctrl.isLocal(next) && c.ctrl.pool.CircuitOpen(next.Addr) {
+			if alt, found := c.circuitHop(key, next); found {
+				// Within the leaf range, the candidate is the next closest node
+				// to key and handles the request itself. Otherwise, it
+				// continues routing the request.
+				c.ctrl.logRoute(key, alt, "circuit")
+				return alt, source == api.RouteLeaf, nil
+			}
+			ok = false
+		}
+		if ok {
+			c.ctrl.logRoute(key, next, routeReason(source))
+			return next, false, nil
+		} else if attempt >= c.routeRetries {
+			break
+		}
+
+		level.Debug(c.ctrl.log).Log("msg", "no route for key, retrying", "key", key, "backoff", backoff)
+
+		t := c.ctrl.clock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return api.Descriptor{}, false, status.FromContextError(ctx.Err()).Err()
+		case <-t.C():
+		}
+		backoff *= 2
+	}
+
+	return api.Descriptor{}, false, statusErrorf(ErrNoRoute, codes.Unavailable, "%s %s", ErrNoRoute, key)
+}
+
+// circuitHop returns the best candidate for routing key when the circuit
+// for next is open. Returns false if every candidate has an open circuit.
+func (c *Client) circuitHop(key id.ID, next api.Descriptor) (alt api.Descriptor, ok bool) {
+	level.Debug(c.ctrl.log).Log("msg", "circuit for next hop is open, finding another candidate", "key", key, "peer", next.Addr)
+
+	for _, cand := range api.NextHops(c.ctrl.routeState(key), key, math.MaxInt32) {
+		if c.ctrl.isLocal(cand) || !c.ctrl.pool.CircuitOpen(cand.Addr) {
+			return cand, true
+		}
+	}
+	return api.Descriptor{}, false
+}
+
+// forwardContext returns the context to send a request for key to next
+// with. The request carries key, the number of hops it took, and the path
+// of nodes it was forwarded through so peers can continue routing it.
+// Requests that would exceed the maximum number of hops or visit a node
+// twice fail with Aborted.
+//
+// If final is true, key is removed and the request is marked as a final hop
+// so next handles the request itself. The trace context of ctx is injected
+// if the node's Tracer is a TracePropagator.
+func (c *Client) forwardContext(ctx context.Context, key id.ID, next api.Descriptor, final bool) (context.Context, error) {
+	md, ok := metadata.FromOutgoingContext(ctx)
 	if !ok {
-		return nil, status.Errorf(codes.Internal, "routing error: unable to find any node for key %s", key)
+		md = metadata.New(map[string]string{})
+	} else {
+		md = md.Copy()
 	}
 
-	if next == c.ctrl.state.Node && !c.allowSelf {
-		return nil, ErrSelfRouting
+	if final {
+		setFinal(md)
+	} else {
+		delete(md, finalHeader)
+		md.Set(requestIdHeader, key.String())
 	}
 
-	cc, err := c.ctrl.pool.Get(next.Addr)
-	if err != nil {
-		level.Info(c.ctrl.log).Log("msg", "failed to get conn to peer for routing", "peer", next.Addr, "err", err)
-		_ = c.ctrl.health.SetHealth(next, api.Unhealthy)
-		goto Retry
+	var (
+		hops, forwarded = extractHops(ctx)
+		origin, _       = extractOrigin(ctx)
+		path            = extractPath(ctx)
+	)
+
+	// Requests sent to the local node don't count as hops, but keep the
+	// route they took so far.
+	if c.ctrl.isLocal(next) {
+		if forwarded {
+			setRoute(md, origin, hops, path)
+		}
+		c.ctrl.injectTrace(ctx, md)
+		return metadata.NewOutgoingContext(ctx, md), nil
 	}
 
-	cs, err := cc.NewStream(ctx, desc, method, opts...)
-	if s := status.Convert(err); s != nil && s.Code() == codes.Unavailable && cc.GetState() == connectivity.TransientFailure {
-		level.Info(c.ctrl.log).Log("msg", "failed to request forward to peer", "peer", next.Addr, "err", err)
-		_ = c.ctrl.health.SetHealth(next, api.Unhealthy)
-		goto Retry
+	path = append(path, c.ctrl.state.Node.Addr)
+	if err := checkRoute(path, next.Addr, c.ctrl.maxHops); err != nil {
+		return nil, err
 	}
+	if origin == "" {
+		origin = c.ctrl.state.Node.Addr
+	}
+
+	setRoute(md, origin, hops+1, path)
+	c.ctrl.injectTrace(ctx, md)
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
 
-	return cs, err
+// setRoute sets the routing headers of a forwarded request in md.
+func setRoute(md metadata.MD, origin string, hops int, path []string) {
+	md.Set(originHeader, origin)
+	md.Set(hopsHeader, strconv.Itoa(hops))
+	md.Set(pathHeader, path...)
 }
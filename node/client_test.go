@@ -2,14 +2,25 @@ package node
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/clock"
 	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/examples/kv/kvserver"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestClient(t *testing.T) {
@@ -50,3 +61,452 @@ func TestClient(t *testing.T) {
 	require.NoError(t, err, "failed to route to peer")
 	require.Equal(t, "peer", resp.GetValue(), "expected response from peer")
 }
+
+func TestClient_WithKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterClient := kvproto.NewKVClient(NewClient(seedNode))
+
+	resp, err := clusterClient.Get(ctx, &kvproto.GetRequest{Key: "peer"}, WithKey(peerNode.cfg.ID))
+	require.NoError(t, err)
+	require.Equal(t, "peer", resp.GetValue())
+
+	// WithKey takes precedence over the key in the context.
+	resp, err = clusterClient.Get(
+		WithClientKey(ctx, seedNode.cfg.ID),
+		&kvproto.GetRequest{Key: "peer"},
+		WithKey(peerNode.cfg.ID),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "peer", resp.GetValue())
+}
+
+func TestCheckRoute(t *testing.T) {
+	require.NoError(t, checkRoute(nil, "a", 1))
+	require.NoError(t, checkRoute([]string{"a"}, "b", 1))
+
+	err := checkRoute([]string{"a", "b"}, "a", 5)
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.True(t, errors.Is(err, ErrRoutingLoop))
+	require.Equal(t, fmt.Sprintf("%s: a -> b -> a", ErrRoutingLoop), status.Convert(err).Message())
+
+	err = checkRoute([]string{"a", "b"}, "c", 1)
+	require.Equal(t, codes.Aborted, status.Code(err))
+	require.True(t, errors.Is(err, ErrMaxHops))
+	require.Equal(t, fmt.Sprintf("%s (1): a -> b -> c", ErrMaxHops), status.Convert(err).Message())
+
+	// Errors sent by peers are recognized once received.
+	remote := status.ErrorProto(status.Convert(err).Proto())
+	require.True(t, errors.Is(fromStatus(remote), ErrMaxHops))
+}
+
+func TestStatusError(t *testing.T) {
+	err := statusErrorf(ErrNoRoute, codes.Unavailable, "%s %s", ErrNoRoute, id.ID{Low: 1})
+	require.True(t, errors.Is(err, ErrNoRoute))
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, "no route to key 1", status.Convert(err).Message())
+
+	// The error is identified by its status once sent between nodes.
+	remote := status.ErrorProto(status.Convert(err).Proto())
+	require.False(t, errors.Is(remote, ErrNoRoute))
+	require.True(t, errors.Is(fromStatus(remote), ErrNoRoute))
+
+	// Other status errors are returned as-is.
+	other := status.Error(codes.Internal, "oops")
+	require.Equal(t, other, fromStatus(other))
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	// The peer fails every request until it has been called failures times.
+	var calls, failures atomic.Int32
+	var kvFunc kvserver.Func
+	kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+		if calls.Inc() <= failures.Load() {
+			return nil, status.Errorf(codes.Unavailable, "not ready")
+		}
+		return &kvproto.GetResponse{Value: "peer"}, nil
+	}
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, &kvFunc)
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	cli := kvproto.NewKVClient(NewClient(seedNode, WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+	})))
+	reqCtx := WithClientKey(ctx, peerNode.cfg.ID)
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(2)
+
+		resp, err := cli.Get(reqCtx, &kvproto.GetRequest{Key: "peer"})
+		require.NoError(t, err)
+		require.Equal(t, "peer", resp.GetValue())
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("fails after max attempts", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(3)
+
+		_, err := cli.Get(reqCtx, &kvproto.GetRequest{Key: "peer"})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.True(t, errors.Is(err, ErrRetriesExhausted))
+		require.Contains(t, err.Error(), peerNode.cfg.BroadcastAddr)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("non-retryable codes aren't retried", func(t *testing.T) {
+		calls.Store(0)
+		failures.Store(1)
+
+		cli := kvproto.NewKVClient(NewClient(seedNode))
+		_, err := cli.Get(reqCtx, &kvproto.GetRequest{Key: "peer"})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestRetryBudget(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	b := newRetryBudget(RetryBudget{Ratio: 0.5, MinRetriesPerSecond: 0.1}, clk)
+
+	// MinRetriesPerSecond allows one retry per 10 seconds without requests.
+	require.True(t, b.allowRetry())
+	require.False(t, b.allowRetry())
+
+	// Every two requests allow another retry.
+	b.request()
+	b.request()
+	require.True(t, b.allowRetry())
+	require.False(t, b.allowRetry())
+
+	// Retries leave the window after 10 seconds.
+	clk.Advance(retryBudgetWindow * time.Second)
+	require.True(t, b.allowRetry())
+	require.False(t, b.allowRetry())
+}
+
+func TestRetrier_Backoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	r := &retrier{
+		policy:  RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second},
+		clock:   clk,
+		backoff: time.Second,
+	}
+	r.attempt()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.retry(ctx, api.Descriptor{Addr: "peer"}, status.Error(codes.Unavailable, "not ready"))
+	}()
+
+	// The retry waits for the backoff to pass on clk.
+	require.Eventually(t, func() bool { return clk.Waiters() == 1 }, time.Second, time.Millisecond)
+	clk.Advance(time.Second - time.Nanosecond)
+	require.Never(t, func() bool { return len(done) > 0 }, 50*time.Millisecond, time.Millisecond)
+
+	clk.Advance(time.Nanosecond)
+	require.NoError(t, <-done)
+	require.Equal(t, 2*time.Second, r.backoff)
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 1 << 28}
+		c.CircuitBreaker = &CircuitBreakerConfig{MinRequests: 2, Cooldown: time.Minute}
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	// The owner of the key fails every request, while the next closest node
+	// serves them.
+	var failures atomic.Int32
+	var failing kvserver.Func
+	failing.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+		failures.Inc()
+		return nil, status.Errorf(codes.Unavailable, "overloaded")
+	}
+	_, owner := makeTestNodeWithConfig(t, log.With(l, "node", "owner"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, &failing)
+	}, func(c *Config) { c.ID = id.ID{Low: 2 << 28} })
+	require.NoError(t, owner.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	_, backup := makeTestNodeWithConfig(t, log.With(l, "node", "backup"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "backup"))
+	}, func(c *Config) { c.ID = id.ID{Low: 3 << 28} })
+	require.NoError(t, backup.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	cli := kvproto.NewKVClient(NewClient(seedNode, WithRetryPolicy(RetryPolicy{MaxAttempts: 1})))
+	reqCtx := WithClientKey(ctx, id.ID{Low: 2<<28 + 1})
+
+	for i := 0; i < 2; i++ {
+		_, err := cli.Get(reqCtx, &kvproto.GetRequest{Key: "backup"})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	}
+	require.True(t, seedNode.controller.pool.CircuitOpen(owner.cfg.BroadcastAddr))
+
+	// With the circuit open, the owner is skipped.
+	resp, err := cli.Get(reqCtx, &kvproto.GetRequest{Key: "backup"})
+	require.NoError(t, err)
+	require.Equal(t, "backup", resp.GetValue())
+	require.Equal(t, int32(2), failures.Load())
+}
+
+func TestClient_Hedging(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 1 << 28}
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	// The owner of the key hangs until the request is canceled, while the
+	// next closest node responds immediately.
+	canceled := make(chan struct{})
+	var slow kvserver.Func
+	slow.GetFunc = func(ctx context.Context, _ *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+	_, owner := makeTestNodeWithConfig(t, log.With(l, "node", "owner"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, &slow)
+	}, func(c *Config) { c.ID = id.ID{Low: 2 << 28} })
+	require.NoError(t, owner.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	_, backup := makeTestNodeWithConfig(t, log.With(l, "node", "backup"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "backup"))
+	}, func(c *Config) { c.ID = id.ID{Low: 3 << 28} })
+	require.NoError(t, backup.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	cli := kvproto.NewKVClient(NewClient(seedNode, WithHedging(20*time.Millisecond, 1)))
+
+	resp, err := cli.Get(WithClientKey(ctx, id.ID{Low: 2<<28 + 1}), &kvproto.GetRequest{Key: "backup"})
+	require.NoError(t, err)
+	require.Equal(t, "backup", resp.GetValue())
+
+	// The losing request is canceled.
+	select {
+	case <-canceled:
+	case <-ctx.Done():
+		require.FailNow(t, "slow request was never canceled")
+	}
+}
+
+func TestClient_InvokeQuorum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// Every node responds with its name, except for the broken node.
+	var nodes []*Node
+	for i, name := range []string{"a", "b", "broken"} {
+		name := name
+
+		var kvFunc kvserver.Func
+		kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+			if name == "broken" {
+				return nil, status.Error(codes.Internal, "broken")
+			}
+			return &kvproto.GetResponse{Value: name}, nil
+		}
+
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", name), &Router{}, func(s *grpc.Server) {
+			kvproto.RegisterKVServer(s, &kvFunc)
+		}, func(c *Config) { c.ID = id.ID{Low: uint64(i+1) << 28} })
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	var (
+		cli      = NewClient(nodes[0])
+		key      = nodes[0].cfg.ID
+		method   = "/example.kv.v1.KV/Get"
+		req      = &kvproto.GetRequest{Key: "key"}
+		newReply = func() proto.Message { return &kvproto.GetResponse{} }
+	)
+
+	resps, err := cli.InvokeQuorum(ctx, key, 3, 2, method, req, newReply)
+	require.NoError(t, err)
+
+	var values []string
+	for _, resp := range resps {
+		if resp.Err == nil {
+			values = append(values, resp.Reply.(*kvproto.GetResponse).GetValue())
+		}
+	}
+	require.ElementsMatch(t, []string{"a", "b"}, values)
+
+	// A quorum of every replica can't be reached with the broken node.
+	resps, err = cli.InvokeQuorum(ctx, key, 3, 3, method, req, newReply)
+	require.True(t, errors.Is(err, ErrNoQuorum), "unexpected error %v", err)
+	require.NotEmpty(t, resps)
+
+	_, err = cli.InvokeQuorum(ctx, key, 2, 3, method, req, newReply)
+	require.Error(t, err)
+}
+
+func TestClient_NearestReplica(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var nodes []*Node
+	for i, name := range []string{"seed", "owner", "other"} {
+		name := name
+
+		// Respond with the name of the node for any key.
+		var kvFunc kvserver.Func
+		kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+			return &kvproto.GetResponse{Value: name}, nil
+		}
+
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", name), &Router{}, func(s *grpc.Server) {
+			kvproto.RegisterKVServer(s, &kvFunc)
+		}, func(c *Config) {
+			c.ID = id.ID{Low: uint64(i+1) << 28}
+			c.ReplicationFactor = 3
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	var (
+		reqCtx = WithClientKey(ctx, nodes[1].cfg.ID)
+		req    = &kvproto.GetRequest{Key: "key"}
+	)
+
+	// By default, requests go to the owner of the key.
+	resp, err := kvproto.NewKVClient(NewClient(nodes[0])).Get(reqCtx, req)
+	require.NoError(t, err)
+	require.Equal(t, "owner", resp.GetValue())
+
+	// The seed is a replica of every key, so it handles the request itself.
+	cli := kvproto.NewKVClient(NewClient(nodes[0], WithNearestReplica()))
+	resp, err = cli.Get(reqCtx, req)
+	require.NoError(t, err)
+	require.Equal(t, "seed", resp.GetValue())
+
+	// Without routing to self, one of the other replicas is used.
+	cli = kvproto.NewKVClient(NewClient(nodes[0], WithNearestReplica(), WithAllowSelfRouting(false)))
+	resp, err = cli.Get(reqCtx, req)
+	require.NoError(t, err)
+	require.Contains(t, []string{"owner", "other"}, resp.GetValue())
+}
+
+func TestClient_RouteLogging(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	newNode := func(rate float64) (*Node, *routeLogs) {
+		logs := &routeLogs{}
+		_, n := makeTestNodeWithConfig(t, logs, &Router{}, nil, func(c *Config) {
+			c.RandSeed = 1
+			c.RouteLogSampleRate = rate
+		})
+		require.NoError(t, n.Join(ctx, nil))
+		return n, logs
+	}
+
+	t.Run("all decisions", func(t *testing.T) {
+		n, logs := newNode(1)
+		next, _, err := NewClient(n).nextHop(ctx, n.cfg.ID)
+		require.NoError(t, err)
+		require.Equal(t, n.cfg.BroadcastAddr, next.Addr)
+		require.Equal(t, []string{"self"}, logs.Reasons())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		n, logs := newNode(0)
+		_, _, err := NewClient(n).nextHop(ctx, n.cfg.ID)
+		require.NoError(t, err)
+		require.Empty(t, logs.Reasons())
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		n, logs := newNode(0.25)
+		c := NewClient(n)
+		for i := 0; i < 1000; i++ {
+			_, _, err := c.nextHop(ctx, n.cfg.ID)
+			require.NoError(t, err)
+		}
+		require.InDelta(t, 250, len(logs.Reasons()), 50)
+	})
+}
+
+// routeLogs records the reasons of logged routing decisions.
+type routeLogs struct {
+	mut     sync.Mutex
+	reasons []string
+}
+
+func (l *routeLogs) Log(keyvals ...interface{}) error {
+	var msg, reason string
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		switch keyvals[i] {
+		case "msg":
+			msg, _ = keyvals[i+1].(string)
+		case "reason":
+			reason, _ = keyvals[i+1].(string)
+		}
+	}
+	if msg == "routing decision" {
+		l.mut.Lock()
+		l.reasons = append(l.reasons, reason)
+		l.mut.Unlock()
+	}
+	return nil
+}
+
+func (l *routeLogs) Reasons() []string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return append([]string(nil), l.reasons...)
+}
@@ -0,0 +1,50 @@
+package node
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	protoenc "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// forwardCodec is forced on requests forwarded by the Router so their
+// responses can be received without decoding them. The global proto codec
+// is left alone.
+var forwardCodec = grpc.ForceCodec(passthroughCodec{Codec: encoding.GetCodec(protoenc.Name)})
+
+// rawFrame is an encoded message. Responses of requests forwarded by the
+// Router are received as rawFrames so they're sent back to the caller
+// exactly as the peer sent them, regardless of their type.
+type rawFrame struct {
+	payload []byte
+}
+
+// message returns f as a proto message which holds the payload as unknown
+// fields. The message is encoded as the original payload by any proto
+// codec, so servers don't need a custom codec to send it.
+func (f *rawFrame) message() *emptypb.Empty {
+	var m emptypb.Empty
+	m.ProtoReflect().SetUnknown(f.payload)
+	return &m
+}
+
+// passthroughCodec wraps the proto codec, sending and receiving rawFrames
+// as-is.
+type passthroughCodec struct {
+	encoding.Codec
+}
+
+func (c passthroughCodec) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*rawFrame); ok {
+		return f.payload, nil
+	}
+	return c.Codec.Marshal(v)
+}
+
+func (c passthroughCodec) Unmarshal(data []byte, v interface{}) error {
+	if f, ok := v.(*rawFrame); ok {
+		f.payload = append([]byte(nil), data...)
+		return nil
+	}
+	return c.Codec.Unmarshal(data, v)
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	protoenc "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRouter_ForwardedResponse(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	// The response forwarded by the seed must be identical to the response
+	// of the peer.
+	var frame rawFrame
+	err = clusterCC.Invoke(WithClientKey(ctx, peerNode.cfg.ID), "/example.kv.v1.KV/Get", &kvproto.GetRequest{Key: "peer"}, &frame, forwardCodec)
+	require.NoError(t, err)
+
+	expect, err := proto.Marshal(&kvproto.GetResponse{Value: "peer"})
+	require.NoError(t, err)
+	require.Equal(t, expect, frame.payload)
+
+	// The global proto codec must not be replaced.
+	_, replaced := encoding.GetCodec(protoenc.Name).(passthroughCodec)
+	require.False(t, replaced)
+}
+
+func TestRawFrame_Message(t *testing.T) {
+	expect, err := proto.Marshal(&kvproto.GetResponse{Value: "peer"})
+	require.NoError(t, err)
+
+	f := rawFrame{payload: expect}
+	actual, err := encoding.GetCodec(protoenc.Name).Marshal(f.message())
+	require.NoError(t, err)
+	require.Equal(t, expect, actual)
+}
+
+func TestPassthroughCodec(t *testing.T) {
+	var c passthroughCodec
+	c.Codec = codecForTest{}
+
+	data, err := c.Marshal(&rawFrame{payload: []byte("hello")})
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), data)
+
+	var f rawFrame
+	require.NoError(t, c.Unmarshal(data, &f))
+	require.Equal(t, []byte("hello"), f.payload)
+
+	// Other values use the wrapped codec.
+	data, err = c.Marshal("other")
+	require.NoError(t, err)
+	require.Equal(t, []byte("wrapped"), data)
+}
+
+type codecForTest struct{}
+
+func (codecForTest) Marshal(v interface{}) ([]byte, error)      { return []byte("wrapped"), nil }
+func (codecForTest) Unmarshal(data []byte, v interface{}) error { return nil }
+func (codecForTest) Name() string                               { return "test" }
@@ -3,8 +3,14 @@ package node
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -16,6 +22,11 @@ const (
 
 const (
 	requestIdHeader = "croissant-request-id"
+	nodeIdHeader    = "croissant-node-id"
+	hopsHeader      = "croissant-hops"
+	pathHeader      = "croissant-path"
+	originHeader    = "croissant-origin"
+	finalHeader     = "croissant-final"
 )
 
 // ErrNoKey is returned when a key is missing.
@@ -58,3 +69,171 @@ func WithClientKey(ctx context.Context, id id.ID) context.Context {
 		md,
 	)
 }
+
+// WithKey returns a grpc.CallOption that sets the key used for routing a
+// call made with a Client. Unlike WithClientKey, the key isn't stored in the
+// context, so it won't be sent to services outside of the cluster that are
+// called with the same context. WithKey takes precedence over WithClientKey.
+func WithKey(key id.ID) grpc.CallOption {
+	return keyCallOption{key: key}
+}
+
+type keyCallOption struct {
+	grpc.EmptyCallOption
+	key id.ID
+}
+
+// callKey returns the key set by WithKey in opts. ok will be false if no key
+// was set.
+func callKey(opts []grpc.CallOption) (key id.ID, ok bool) {
+	for _, o := range opts {
+		if ko, isKey := o.(keyCallOption); isKey {
+			key, ok = ko.key, true
+		}
+	}
+	return key, ok
+}
+
+// withTarget directs calls to the Node service made with ctx to the virtual
+// node d. Calls without a target are handled by the first virtual node.
+func withTarget(ctx context.Context, d api.Descriptor) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, nodeIdHeader, d.ID.String())
+}
+
+// extractTarget returns the virtual node targeted by an incoming call to the
+// Node service. ok will be false if no virtual node was targeted.
+func extractTarget(ctx context.Context) (target id.ID, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(nodeIdHeader)
+	if len(vals) == 0 {
+		return id.Zero, false
+	}
+	target, err := id.Parse(vals[0])
+	return target, err == nil
+}
+
+// setFinal marks a request sent with md as a final hop, which the
+// receiving node handles itself without routing it.
+func setFinal(md metadata.MD) {
+	delete(md, requestIdHeader)
+	md.Set(finalHeader, "true")
+}
+
+// isFinal returns true if the incoming request in ctx was sent as a final
+// hop. Final hops are handled by the local node even if a KeyExtractor
+// could derive a key for them.
+func isFinal(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return len(md.Get(finalHeader)) > 0
+}
+
+// extractHops returns the number of times an incoming request was sent
+// between nodes. ok will be false if the request wasn't sent by a node.
+func extractHops(ctx context.Context) (hops int, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(hopsHeader)
+	if len(vals) == 0 {
+		return 0, false
+	}
+	hops, err := strconv.Atoi(vals[0])
+	return hops, err == nil
+}
+
+// extractPath returns the addresses of the nodes an incoming request was
+// forwarded through, in order.
+func extractPath(ctx context.Context) []string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return md.Get(pathHeader)
+}
+
+// extractOrigin returns the address of the node that first forwarded an
+// incoming request. ok will be false if the request wasn't sent by a node.
+func extractOrigin(ctx context.Context) (origin string, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(originHeader)
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
+
+// RouteInfo describes how an incoming request was forwarded through the
+// cluster.
+type RouteInfo struct {
+	// Origin is the address of the node that first forwarded the request.
+	Origin string
+	// Hops is the number of times the request was sent between nodes.
+	Hops int
+	// Path holds the addresses of the nodes the request was forwarded
+	// through, in order.
+	Path []string
+}
+
+// RouteInfoFromContext returns how the incoming request in ctx was forwarded
+// through the cluster. ok will be false if the request wasn't forwarded by a
+// node.
+func RouteInfoFromContext(ctx context.Context) (info RouteInfo, ok bool) {
+	hops, ok := extractHops(ctx)
+	if !ok {
+		return RouteInfo{}, false
+	}
+	origin, _ := extractOrigin(ctx)
+	return RouteInfo{Origin: origin, Hops: hops, Path: extractPath(ctx)}, true
+}
+
+// propagateMetadata copies the metadata of the incoming request in ctx to
+// the outgoing metadata of ctx, so it's sent along when the request is
+// forwarded. Routing headers are left out, since they're set per hop, as are
+// headers reserved by gRPC and HTTP/2.
+func propagateMetadata(ctx context.Context) context.Context {
+	in, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	out, _ := metadata.FromOutgoingContext(ctx)
+	out = out.Copy()
+
+	for k, vals := range in {
+		switch {
+		case strings.HasPrefix(k, ":"), strings.HasPrefix(k, "grpc-"):
+		case k == "content-type", k == "user-agent", k == "te":
+		case k == requestIdHeader, k == nodeIdHeader, k == hopsHeader, k == pathHeader, k == originHeader, k == finalHeader:
+		default:
+			if _, set := out[k]; !set {
+				out[k] = append([]string(nil), vals...)
+			}
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+// checkRoute returns an Aborted error if a request forwarded through path
+// can't be sent to next, either because it would exceed maxHops or because
+// next is already in path.
+func checkRoute(path []string, next string, maxHops int) error {
+	fullPath := strings.Join(append(path[:len(path):len(path)], next), " -> ")
+	for _, addr := range path {
+		if addr == next {
+			return statusErrorf(ErrRoutingLoop, codes.Aborted, "%s: %s", ErrRoutingLoop, fullPath)
+		}
+	}
+	if len(path) > maxHops {
+		return statusErrorf(ErrMaxHops, codes.Aborted, "%s (%d): %s", ErrMaxHops, maxHops, fullPath)
+	}
+	return nil
+}
+
+// nodeName identifies d in paths checked by checkRoute. Virtual nodes of the
+// same node share an address, so their IDs are included.
+func nodeName(d api.Descriptor) string {
+	return fmt.Sprintf("%s@%s", d.ID, d.Addr)
+}
+
+// nodePath returns the names of ds for use with checkRoute.
+func nodePath(ds []api.Descriptor) []string {
+	res := make([]string, 0, len(ds))
+	for _, d := range ds {
+		res = append(res, nodeName(d))
+	}
+	return res
+}
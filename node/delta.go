@@ -0,0 +1,222 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSentStates is the number of versions of the local state kept around for
+// building deltas.
+const maxSentStates = 32
+
+// deltas tracks which versions of state have been exchanged with peers so
+// Hellos can hold a StateDelta instead of the full State.
+type deltas struct {
+	mut sync.Mutex
+
+	sent      map[uint64]*api.State         // Recent versions of the local state sent to peers.
+	sentOrder []uint64                      // Versions in sent, oldest first.
+	delivered map[api.Descriptor]uint64     // Version of the local state last delivered to a peer.
+	received  map[api.Descriptor]*api.State // Last state received from a peer.
+	noDelta   map[string]struct{}           // Peers that don't support deltas.
+}
+
+func newDeltas() *deltas {
+	return &deltas{
+		sent:      make(map[uint64]*api.State),
+		delivered: make(map[api.Descriptor]uint64),
+		received:  make(map[api.Descriptor]*api.State),
+		noDelta:   make(map[string]struct{}),
+	}
+}
+
+// recordSent stores s so it may be used as the base of a future delta.
+func (d *deltas) recordSent(s *api.State) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if _, ok := d.sent[s.Version]; ok {
+		return
+	}
+	d.sent[s.Version] = s
+	d.sentOrder = append(d.sentOrder, s.Version)
+
+	for len(d.sentOrder) > maxSentStates {
+		delete(d.sent, d.sentOrder[0])
+		d.sentOrder = d.sentOrder[1:]
+	}
+}
+
+// sentState returns the version of the local state that was sent to peers.
+func (d *deltas) sentState(version uint64) *api.State {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.sent[version]
+}
+
+// base returns the state last delivered to peer, if it can be used as the
+// base of a delta.
+func (d *deltas) base(peer api.Descriptor) *api.State {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if _, ok := d.noDelta[peer.Addr]; ok {
+		return nil
+	}
+	version, ok := d.delivered[peer]
+	if !ok {
+		return nil
+	}
+	return d.sent[version]
+}
+
+// isDelivered returns true if version of the local state was the last
+// version delivered to peer.
+func (d *deltas) isDelivered(peer api.Descriptor, version uint64) bool {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	last, ok := d.delivered[peer]
+	return ok && last == version
+}
+
+// resetDelivered forgets which version of the local state was delivered to
+// peer, so the next Hello sent to peer holds the full state.
+func (d *deltas) resetDelivered(peer api.Descriptor) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	delete(d.delivered, peer)
+}
+
+func (d *deltas) markDelivered(peer api.Descriptor, version uint64) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.delivered[peer] = version
+}
+
+func (d *deltas) markNoDelta(peer api.Descriptor) {
+	d.setSupported(peer, false)
+}
+
+// setSupported sets whether peer supports deltas.
+func (d *deltas) setSupported(peer api.Descriptor, supported bool) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if supported {
+		delete(d.noDelta, peer.Addr)
+	} else {
+		d.noDelta[peer.Addr] = struct{}{}
+	}
+}
+
+// receive returns the full state for h, rebuilding it from the last state
+// received from the initiator if h holds a delta. The resulting state is
+// remembered as the base for future deltas from the initiator.
+func (d *deltas) receive(h api.Hello) (*api.State, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	s := h.State
+	if h.Delta != nil {
+		base, ok := d.received[h.Initiator]
+		if !ok {
+			return nil, api.ErrUnknownBase
+		}
+
+		var err error
+		s, err = h.Delta.Apply(base)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if s == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "hello has no state")
+	}
+
+	d.received[h.Initiator] = s.Clone()
+	return s, nil
+}
+
+// setReceived remembers s as the last state received from its node.
+func (d *deltas) setReceived(s *api.State) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.received[s.Node] = s.Clone()
+}
+
+// forget removes everything known about peer.
+func (d *deltas) forget(peer api.Descriptor) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	delete(d.delivered, peer)
+	delete(d.received, peer)
+	delete(d.noDelta, peer.Addr)
+}
+
+// helloSender sends Hellos to a peer. Implemented by api.Node and
+// api.GossipStream.
+type helloSender interface {
+	NodeHello(ctx context.Context, h api.Hello) error
+}
+
+// sendHello sends h to peer using cli, setting the cluster name and protocol
+// version of the local node. If peer has received a previous version of h.State, only the changes
+// since then are sent. sendHello falls back to sending the full state if peer
+// doesn't know about the previous version.
+func (c *controller) sendHello(ctx context.Context, cli helloSender, peer api.Descriptor, h api.Hello) error {
+	c.metrics.hellosSentTotal.Inc()
+
+	h.Cluster = c.cluster
+	h.ProtocolVersion = api.ProtocolVersion
+
+	full := h.State
+	c.deltas.recordSent(full)
+
+	if base := c.deltas.base(peer); base != nil {
+		h.State = nil
+		h.Delta = api.NewStateDelta(base, full)
+
+		err := cli.NodeHello(ctx, h)
+		switch {
+		case errors.Is(err, api.ErrUnknownBase):
+			level.Debug(c.log).Log("msg", "peer does not know base state, sending full state", "peer", peer.Addr)
+		case status.Code(err) == codes.Unimplemented:
+			level.Debug(c.log).Log("msg", "peer does not support deltas, sending full state", "peer", peer.Addr)
+			c.deltas.markNoDelta(peer)
+		default:
+			if err == nil {
+				c.deltas.markDelivered(peer, full.Version)
+			}
+			return err
+		}
+
+		h.State, h.Delta = full, nil
+	}
+
+	err := cli.NodeHello(ctx, h)
+	if err == nil {
+		c.deltas.markDelivered(peer, full.Version)
+	}
+	return err
+}
+
+// stateChangedError returns the ErrStateChanged to reply to h with. The reply
+// holds a delta if h accepts one and the acknowledged state is still known.
+func (c *controller) stateChangedError(h api.Hello) error {
+	cur := c.state.Clone()
+	c.deltas.recordSent(cur)
+
+	if h.AcceptDelta {
+		if base := c.deltas.sentState(h.StateAck); base != nil {
+			return api.ErrStateChanged{Delta: api.NewStateDelta(base, cur)}
+		}
+	}
+	return api.ErrStateChanged{NewState: cur}
+}
@@ -0,0 +1,59 @@
+package node
+
+import (
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// Drain puts the node into maintenance mode. While draining, the node stops
+// handling routed requests for keys it owns and redirects them to the next
+// closest node instead. The node stays a member of the cluster, so draining
+// a node before restarting it avoids the churn of leaving and rejoining.
+//
+// Requests are only redirected when another healthy node is known; a node
+// without peers keeps handling its own requests. Call Resume to stop
+// draining.
+func (n *Node) Drain() {
+	if n.controller.draining.Load() {
+		return
+	}
+	for _, vnode := range n.vnodes {
+		vnode.draining.Store(true)
+	}
+	n.updateServingStatus()
+	level.Info(n.cfg.Log).Log("msg", "draining node")
+}
+
+// Resume stops draining the node, allowing it to handle routed requests for
+// the keys it owns again.
+func (n *Node) Resume() {
+	if !n.controller.draining.Load() {
+		return
+	}
+	for _, vnode := range n.vnodes {
+		vnode.draining.Store(false)
+	}
+	n.updateServingStatus()
+	level.Info(n.cfg.Log).Log("msg", "resumed node")
+}
+
+// Draining returns true if the node is draining.
+func (n *Node) Draining() bool {
+	return n.controller.draining.Load()
+}
+
+// drainHop returns the healthy remote node closest to key. ok will be false
+// if there are no healthy remote leaves.
+func (c *controller) drainHop(key id.ID) (next api.Descriptor, ok bool) {
+	s := c.routeState(key)
+	for _, l := range s.Leaves(false) {
+		if c.isLocal(l) {
+			continue
+		}
+		if !ok || api.Closer(l.ID, next.ID, key, s.Size) {
+			next, ok = l, true
+		}
+	}
+	return
+}
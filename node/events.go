@@ -0,0 +1,260 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// eventBufferSize is the number of events buffered for a Subscription.
+const eventBufferSize = 128
+
+// Event is a change in the cluster observed by a node. Events are one of
+// PeerJoined, PeerLeft, PeerHealthChanged, OwnershipChanged, or
+// StateRecalculated.
+type Event interface {
+	isEvent()
+}
+
+// PeerJoined is sent when a peer becomes a leaf of the node. Leaves are the
+// peers passed to Application.PeersChanged.
+type PeerJoined struct {
+	Peer Peer
+}
+
+// PeerLeft is sent when a peer stops being a leaf of the node, such as when
+// it leaves the cluster or dies.
+type PeerLeft struct {
+	Peer Peer
+}
+
+// PeerHealthChanged is sent when the health of a peer tracked by the node
+// changes.
+type PeerHealthChanged struct {
+	Peer   Peer
+	Health Health
+}
+
+// OwnershipChanged is sent when the range of keys owned by the node changes.
+// Changes are the same as those passed to OwnershipWatcher.
+type OwnershipChanged struct {
+	Changes []OwnershipChange
+}
+
+// StateRecalculated is sent after the node updated its state in response to
+// a change in the cluster. With virtual nodes, State is the state of the
+// virtual node that changed.
+type StateRecalculated struct {
+	State State
+}
+
+func (PeerJoined) isEvent()        {}
+func (PeerLeft) isEvent()          {}
+func (PeerHealthChanged) isEvent() {}
+func (OwnershipChanged) isEvent()  {}
+func (StateRecalculated) isEvent() {}
+
+// Subscription receives events from a node. Create one with Node.Subscribe.
+type Subscription struct {
+	// C receives events in the order they happened. Events are dropped if
+	// the buffer of C is full. C is closed when the Subscription is closed.
+	C <-chan Event
+
+	ch chan Event
+	ev *events
+
+	closeOnce sync.Once
+}
+
+// Close stops sending events to s.
+func (s *Subscription) Close() {
+	s.ev.mut.Lock()
+	defer s.ev.mut.Unlock()
+
+	delete(s.ev.subs, s)
+	s.closeOnce.Do(func() { close(s.ch) })
+}
+
+// Subscribe returns a Subscription that receives events about changes to the
+// cluster observed by n. Unlike Application.PeersChanged, each kind of
+// change is sent as its own type of Event.
+//
+// Events are buffered, and events for subscribers which don't keep up are
+// dropped. The Subscription must be closed when no longer needed.
+func (n *Node) Subscribe() *Subscription {
+	ch := make(chan Event, eventBufferSize)
+	sub := &Subscription{C: ch, ch: ch, ev: n.events}
+
+	n.events.mut.Lock()
+	defer n.events.mut.Unlock()
+	n.events.subs[sub] = struct{}{}
+	return sub
+}
+
+// peersChanged is invoked after a virtual node informed the Application
+// about new peers.
+func (n *Node) peersChanged() {
+	if n.cfg.DataDir != "" {
+		n.persistPeers()
+	}
+	n.events.setPeers(n.leaves())
+}
+
+// leaves returns the leaves of every virtual node. Only one peer is returned
+// per address, and virtual nodes of the local node are excluded.
+func (n *Node) leaves() []Peer {
+	var (
+		peers []Peer
+		seen  = map[string]struct{}{n.cfg.BroadcastAddr: {}}
+	)
+	for _, vnode := range n.vnodes {
+		for _, p := range getPeers(vnode.state) {
+			if _, ok := seen[p.Addr]; ok {
+				continue
+			}
+			seen[p.Addr] = struct{}{}
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// events sends events to subscriptions. A nil *events drops all events.
+type events struct {
+	log log.Logger
+
+	mut    sync.Mutex
+	subs   map[*Subscription]struct{}
+	peers  map[string]Peer // Last known leaves, keyed by address.
+	health map[Peer]Health // Last reported health of peers. Missing peers are healthy.
+
+	versions map[id.ID]uint64 // Last reported state version of each virtual node.
+}
+
+func newEvents(l log.Logger) *events {
+	return &events{
+		log:    l,
+		subs:   make(map[*Subscription]struct{}),
+		peers:  make(map[string]Peer),
+		health: make(map[Peer]Health),
+
+		versions: make(map[id.ID]uint64),
+	}
+}
+
+// send sends ev to every subscription. e.mut must be held.
+func (e *events) send(ev Event) {
+	for sub := range e.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			level.Warn(e.log).Log("msg", "dropping event for slow subscriber", "event", fmt.Sprintf("%T", ev))
+		}
+	}
+}
+
+// setPeers sends PeerJoined and PeerLeft events for the differences between
+// peers and the last known leaves.
+func (e *events) setPeers(peers []Peer) {
+	if e == nil {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	next := make(map[string]Peer, len(peers))
+	for _, p := range peers {
+		next[p.Addr] = p
+	}
+
+	var joined, left []Peer
+	for addr, p := range next {
+		if _, ok := e.peers[addr]; !ok {
+			joined = append(joined, p)
+		}
+	}
+	for addr, p := range e.peers {
+		if _, ok := next[addr]; !ok {
+			left = append(left, p)
+		}
+	}
+	e.peers = next
+
+	sortPeers(left)
+	sortPeers(joined)
+	for _, p := range left {
+		e.send(PeerLeft{Peer: p})
+	}
+	for _, p := range joined {
+		// Forget the health of peers that died and joined again so their
+		// next death is reported.
+		delete(e.health, p)
+		e.send(PeerJoined{Peer: p})
+	}
+}
+
+// healthChanged sends a PeerHealthChanged event if h differs from the last
+// reported health of d. Virtual nodes may track the same peer, so changes
+// are only reported once.
+func (e *events) healthChanged(d api.Descriptor, ah api.Health) {
+	if e == nil {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	var (
+		p = peerFromDescriptor(d)
+		h = Health(ah)
+	)
+	if e.health[p] == h {
+		return
+	}
+	if h == Healthy {
+		delete(e.health, p)
+	} else {
+		e.health[p] = h
+	}
+	e.send(PeerHealthChanged{Peer: p, Health: h})
+}
+
+// ownershipChanged sends an OwnershipChanged event.
+func (e *events) ownershipChanged(changes []OwnershipChange) {
+	if e == nil || len(changes) == 0 {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.send(OwnershipChanged{Changes: changes})
+}
+
+// stateRecalculated sends a StateRecalculated event if s changed since the
+// last event for s.
+func (e *events) stateRecalculated(s *api.State) {
+	if e == nil {
+		return
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if len(e.subs) == 0 {
+		return
+	}
+
+	snap := stateSnapshot(s)
+	if v, ok := e.versions[snap.Node.ID]; ok && v == snap.Version {
+		return
+	}
+	e.versions[snap.Node.ID] = snap.Version
+	e.send(StateRecalculated{State: snap})
+}
+
+func sortPeers(ps []Peer) {
+	sort.Slice(ps, func(i, j int) bool {
+		return id.Compare(ps[i].ID, ps[j].ID) < 0
+	})
+}
@@ -0,0 +1,92 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// evictMethod is the method broadcast by Node.Evict.
+const evictMethod = "/croissant.v1.Node/Evict"
+
+// Evict forcibly removes peer from the cluster. It's intended for operators
+// to forget about a node which died without leaving, before health checks
+// have declared it dead on every node.
+//
+// Every node in the cluster, including the local node, treats peer as dead:
+// peer is replaced in state tables as if it failed its health checks, and
+// the failure is gossiped to other nodes when the SWIM detector is used.
+// Evict is sent to other nodes with a broadcast; nodes the broadcast doesn't
+// reach still find peer dead through their own health checks.
+//
+// Returns an error if peer is the local node or if a node other than peer
+// failed to evict it. The local node evicts peer even if the broadcast
+// fails.
+func (n *Node) Evict(ctx context.Context, peer Peer) error {
+	evictee := peer.descriptor()
+	if n.controller.isLocal(evictee) {
+		return fmt.Errorf("can't evict self")
+	}
+
+	// Evict locally first so the broadcast isn't forwarded to peer.
+	for _, vnode := range n.vnodes {
+		if err := vnode.Evict(ctx, evictee); err != nil {
+			return err
+		}
+	}
+
+	resps, err := n.controller.broadcast(ctx, evictMethod, nodepb.NewEvictRequest(evictee), func() proto.Message {
+		return &emptypb.Empty{}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to broadcast eviction: %w", err)
+	}
+	for _, resp := range resps {
+		if resp.Err == nil || resp.Peer.ID == peer.ID && resp.Peer.Addr == peer.Addr {
+			continue
+		}
+		return fmt.Errorf("failed to evict %s from %s: %w", peer.Addr, resp.Peer.Addr, resp.Err)
+	}
+	return nil
+}
+
+// Evict implements api.Node.
+func (c *controller) Evict(ctx context.Context, evictee api.Descriptor) error {
+	if c.isLocal(evictee) {
+		return status.Errorf(codes.InvalidArgument, "can't evict self")
+	}
+
+	// The caller may not know the labels of evictee, so use the descriptor
+	// from the state.
+	found := false
+	for _, p := range c.state.Peers(true) {
+		if p.ID == evictee.ID && p.Addr == evictee.Addr {
+			evictee, found = p, true
+			break
+		}
+	}
+	if !found {
+		// Nothing to purge.
+		return nil
+	}
+
+	level.Info(c.log).Log("msg", "evicting peer", "peer", evictee.Addr)
+
+	// Mark evictee dead in the state right away so it's no longer routed to
+	// while it's being replaced.
+	c.state.SetHealth(evictee, api.Dead)
+
+	if err := c.health.SetHealth(evictee, api.Dead); err != nil {
+		// evictee isn't being health checked, so HealthChanged won't be
+		// invoked for it. Purge it from the state directly.
+		go c.HealthChanged(evictee, api.Dead)
+	}
+	return nil
+}
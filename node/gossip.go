@@ -0,0 +1,183 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gossipStreams tracks the gossip streams opened by a controller to its
+// leaves.
+type gossipStreams struct {
+	mut         sync.Mutex
+	streams     map[api.Descriptor]api.GossipStream
+	unsupported map[string]struct{} // Addresses of peers without gossip streams.
+}
+
+func newGossipStreams() *gossipStreams {
+	return &gossipStreams{
+		streams:     make(map[api.Descriptor]api.GossipStream),
+		unsupported: make(map[string]struct{}),
+	}
+}
+
+// greetLeaf shares state with the leaf l. If gossip streams are enabled,
+// state is sent over a long-lived stream to l, and heartbeats are sent
+// instead when l already has state. Otherwise, or if l doesn't support
+// gossip streams, a Hello is sent.
+func (c *controller) greetLeaf(ctx context.Context, l api.Descriptor, state *api.State) error {
+	hello := api.Hello{Initiator: state.Node, State: state}
+
+	stream, err := c.gossipStream(ctx, l)
+	if err != nil {
+		return err
+	} else if stream == nil {
+		cc, err := c.pool.Get(l.Addr)
+		if err != nil {
+			return err
+		}
+		return c.sendHello(withTarget(ctx, l), c.nodeClient(cc), l, hello)
+	}
+
+	if c.deltas.isDelivered(l, state.Version) {
+		c.metrics.heartbeatsSentTotal.Inc()
+		return stream.Heartbeat(ctx)
+	}
+	return c.sendHello(ctx, stream, l, hello)
+}
+
+// gossipStream returns the gossip stream to l, opening one if needed.
+// Returns nil if gossip streams are disabled or unsupported by l.
+func (c *controller) gossipStream(ctx context.Context, l api.Descriptor) (api.GossipStream, error) {
+	g := c.gossip
+	if g == nil {
+		return nil, nil
+	}
+
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if _, ok := g.unsupported[l.Addr]; ok {
+		return nil, nil
+	} else if stream, ok := g.streams[l]; ok {
+		return stream, nil
+	}
+
+	cc, err := c.pool.Get(l.Addr)
+	if err != nil {
+		return nil, err
+	}
+	gossiper, ok := c.nodeClient(cc).(api.Gossiper)
+	if !ok {
+		return nil, nil
+	}
+
+	// The stream outlives ctx, so it's opened with a new context.
+	stream, err := gossiper.Gossip(withTarget(context.Background(), l))
+	if err != nil {
+		return nil, err
+	}
+
+	// Peers that don't support gossip streams only fail once a message is
+	// sent, so check the stream before using it.
+	if err := stream.Heartbeat(ctx); status.Code(err) == codes.Unimplemented {
+		level.Debug(c.log).Log("msg", "peer does not support gossip streams, sending hellos", "peer", l.Addr)
+		g.unsupported[l.Addr] = struct{}{}
+		_ = stream.Close()
+		return nil, nil
+	} else if err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+
+	// The peer may not have a previous state from us, so send the full state
+	// first.
+	c.deltas.resetDelivered(l)
+
+	g.streams[l] = stream
+	go c.watchGossipStream(l, stream)
+	return stream, nil
+}
+
+// watchGossipStream marks l as unhealthy as soon as its gossip stream
+// breaks.
+func (c *controller) watchGossipStream(l api.Descriptor, stream api.GossipStream) {
+	<-stream.Done()
+
+	c.gossip.mut.Lock()
+	if c.gossip.streams[l] == stream {
+		delete(c.gossip.streams, l)
+	}
+	c.gossip.mut.Unlock()
+
+	// Canceled streams were closed by the local node, either on purpose or
+	// because their connection was closed.
+	err := stream.Err()
+	if err == nil || status.Code(err) == codes.Canceled {
+		return
+	}
+	c.gossipBroken(l, err)
+}
+
+// closeGossipStreams closes the gossip streams to peers which aren't in
+// keep. All streams are closed if keep is nil.
+func (c *controller) closeGossipStreams(keep []api.Descriptor) {
+	g := c.gossip
+	if g == nil {
+		return
+	}
+
+	keepSet := make(map[api.Descriptor]struct{}, len(keep))
+	for _, d := range keep {
+		keepSet[d] = struct{}{}
+	}
+
+	var toClose []api.GossipStream
+
+	g.mut.Lock()
+	for d, stream := range g.streams {
+		if _, ok := keepSet[d]; !ok {
+			toClose = append(toClose, stream)
+			delete(g.streams, d)
+		}
+	}
+	g.mut.Unlock()
+
+	for _, stream := range toClose {
+		_ = stream.Close()
+	}
+}
+
+// GossipBroken implements api.GossipWatcher, marking the initiator of a
+// gossip stream as unhealthy as soon as the stream breaks.
+func (c *controller) GossipBroken(_ context.Context, initiator api.Descriptor, err error) {
+	c.gossipBroken(initiator, err)
+}
+
+// gossipBroken marks the leaf d as unhealthy after its gossip stream broke
+// with err. The health checker decides whether d recovers or dies.
+func (c *controller) gossipBroken(d api.Descriptor, err error) {
+	if c.leaving.Load() || !c.isHealthyLeaf(d) {
+		return
+	}
+
+	level.Warn(c.log).Log("msg", "gossip stream with leaf broke", "leaf", d.Addr, "err", err)
+	c.metrics.brokenStreamsTotal.Inc()
+	if err := c.health.SetHealth(d, api.Unhealthy); err != nil {
+		level.Warn(c.log).Log("msg", "could not update health of leaf", "leaf", d.Addr, "err", err)
+	}
+}
+
+// isHealthyLeaf returns true if d is a healthy leaf.
+func (c *controller) isHealthyLeaf(d api.Descriptor) bool {
+	for _, l := range c.state.Leaves(false) {
+		if l == d {
+			return true
+		}
+	}
+	return false
+}
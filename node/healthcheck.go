@@ -0,0 +1,64 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/health"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthCheckFunc checks the health of peer p, using cc to communicate with
+// it. p is healthy if HealthCheckFunc returns nil.
+type HealthCheckFunc func(ctx context.Context, cc *grpc.ClientConn, p Peer) error
+
+// StateHealthCheck is the default HealthCheckFunc, which requests the routing
+// state of the peer.
+func StateHealthCheck(ctx context.Context, cc *grpc.ClientConn, p Peer) error {
+	return health.CheckState(ctx, cc, p.descriptor())
+}
+
+// GRPCHealthCheck returns a HealthCheckFunc that uses the standard gRPC
+// health checking protocol. Peers are healthy if service is reported as
+// serving. An empty service checks the overall health of the peer.
+//
+// Peers must register a health server, such as the one from
+// google.golang.org/grpc/health.
+func GRPCHealthCheck(service string) HealthCheckFunc {
+	return func(ctx context.Context, cc *grpc.ClientConn, p Peer) error {
+		resp, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{
+			Service: service,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("peer %s is %s", p.Addr, resp.GetStatus())
+		}
+		return nil
+	}
+}
+
+// TCPHealthCheck is a HealthCheckFunc that only checks that a TCP connection
+// can be opened to the peer.
+func TCPHealthCheck(ctx context.Context, _ *grpc.ClientConn, p Peer) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// toCheckFunc converts f into a health.CheckFunc. Returns nil if f is nil.
+func toCheckFunc(f HealthCheckFunc) health.CheckFunc {
+	if f == nil {
+		return nil
+	}
+	return func(ctx context.Context, cc *grpc.ClientConn, d api.Descriptor) error {
+		return f(ctx, cc, peerFromDescriptor(d))
+	}
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func TestGRPCHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	hs := health.NewServer()
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer cc.Close()
+
+	p := Peer{Addr: lis.Addr().String()}
+	check := GRPCHealthCheck("kv")
+
+	hs.SetServingStatus("kv", healthpb.HealthCheckResponse_SERVING)
+	require.NoError(t, check(ctx, cc, p))
+
+	hs.SetServingStatus("kv", healthpb.HealthCheckResponse_NOT_SERVING)
+	require.Error(t, check(ctx, cc, p))
+}
+
+func TestTCPHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	p := Peer{Addr: lis.Addr().String()}
+	require.NoError(t, TCPHealthCheck(ctx, nil, p))
+
+	require.NoError(t, lis.Close())
+	require.Error(t, TCPHealthCheck(ctx, nil, p))
+}
+
+func TestNode_RegisterAdmin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	n, err := New(Config{
+		ID:            id.NewGenerator(32).Get(lis.Addr().String()),
+		BroadcastAddr: lis.Addr().String(),
+		NumLeaves:     8,
+		NumNeighbors:  8,
+	}, noopApplication{}, WithDialOptions(grpc.WithInsecure()))
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	n.RegisterAdmin(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer cc.Close()
+
+	var (
+		p     = Peer{Addr: lis.Addr().String()}
+		check = GRPCHealthCheck("croissant.v1.Node")
+	)
+	require.Error(t, check(ctx, cc, p), "node should not be serving before joining")
+
+	require.NoError(t, n.Join(ctx, nil))
+	require.NoError(t, check(ctx, cc, p))
+	require.NoError(t, GRPCHealthCheck("")(ctx, cc, p))
+
+	n.Drain()
+	require.Error(t, check(ctx, cc, p), "node should not be serving while draining")
+	n.Resume()
+	require.NoError(t, check(ctx, cc, p))
+
+	// Services should be discoverable through reflection.
+	stream, err := reflectionpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+	require.Contains(t, services, "croissant.v1.Node")
+	require.Contains(t, services, "grpc.health.v1.Health")
+
+	require.NoError(t, n.Close())
+	require.Error(t, check(ctx, cc, p), "node should not be serving after closing")
+}
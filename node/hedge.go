@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithHedging enables hedged requests. If a request sent by Invoke hasn't
+// completed after delay, it's also sent to the next best candidate for the
+// key, up to maxHedges times, and the first successful response is used.
+// Hedged requests are sent to the next closest replicas of the key, which
+// handle them directly, so hedging should only be used for idempotent
+// reads that any replica can serve.
+//
+// Hedged requests aren't retried, and the error of the original request is
+// returned if every request fails. Only requests with proto.Message replies
+// are hedged, and streams are never hedged.
+func WithHedging(delay time.Duration, maxHedges int) ClientOption {
+	return func(c *Client) {
+		c.hedgeDelay = delay
+		c.maxHedges = maxHedges
+	}
+}
+
+type hedgeResult struct {
+	reply   proto.Message
+	err     error
+	primary bool
+}
+
+// invokeHedged is like invoke, but sends hedged requests to other
+// candidates for key if the original request is slow.
+func (c *Client) invokeHedged(ctx context.Context, span Span, key id.ID, method string, args interface{}, reply proto.Message, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // Abort requests that are still running once one wins.
+
+	var (
+		results = make(chan hedgeResult, c.maxHedges+1)
+		pending int
+		hedges  int
+	)
+	send := func(primary bool, fn func(reply proto.Message) error) {
+		pending++
+		r := reply.ProtoReflect().New().Interface()
+		go func() {
+			results <- hedgeResult{reply: r, err: fn(r), primary: primary}
+		}()
+	}
+
+	// The original request is sent with its own span, since it may still be
+	// running once invokeHedged returns.
+	send(true, func(r proto.Message) error {
+		return c.invoke(ctx, noopSpan{}, key, method, args, r, opts...)
+	})
+
+	var (
+		targets  []api.Descriptor
+		final    bool
+		firstErr error
+	)
+
+	timer := c.ctrl.clock.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			if hedges == 0 {
+				targets, final = c.hedgeTargets(key)
+			}
+			if hedges >= c.maxHedges || len(targets) == 0 {
+				continue
+			}
+
+			next := targets[0]
+			targets = targets[1:]
+			hedges++
+			span.SetAttribute(attrHedges, hedges)
+
+			info := ForwardInfo{Method: method, Key: key, Attempt: hedges, Hedged: true}
+			send(false, func(r proto.Message) error {
+				return c.sendDirect(ctx, info, next, final, args, r, opts...)
+			})
+			timer.Reset(c.hedgeDelay)
+
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				proto.Reset(reply)
+				proto.Merge(reply, res.reply)
+				return nil
+			}
+			if res.primary || firstErr == nil {
+				firstErr = res.err
+			}
+			if pending == 0 {
+				return firstErr
+			}
+		}
+	}
+}
+
+// hedgeTargets returns the candidates for hedged requests for key, best
+// first. final is true if candidates should handle requests themselves.
+func (c *Client) hedgeTargets(key id.ID) (targets []api.Descriptor, final bool) {
+	s := c.ctrl.routeState(key)
+	_, source, _ := api.NextHopExplain(s, key)
+
+	cands := api.NextHops(s, key, c.maxHedges+1)
+	if len(cands) == 0 {
+		return nil, false
+	}
+	for _, cand := range cands[1:] {
+		if c.ctrl.isLocal(cand) && !c.allowSelf {
+			continue
+		} else if !c.ctrl.isLocal(cand) && c.ctrl.pool.CircuitOpen(cand.Addr) {
+			continue
+		}
+		targets = append(targets, cand)
+	}
+	return targets, source == api.RouteLeaf || source == api.RouteSelf
+}
+
+// sendDirect sends a single request described by info to next without
+// retrying. If final is true, next handles the request itself.
+func (c *Client) sendDirect(ctx context.Context, info ForwardInfo, next api.Descriptor, final bool, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	next, err := c.hook(ctx, info, next)
+	if err != nil {
+		return err
+	}
+	if c.ctrl.isLocal(next) && !c.allowSelf {
+		return ErrSelfRouting
+	}
+	if err := c.limit(next); err != nil {
+		return err
+	}
+	fwdCtx, err := c.forwardContext(ctx, info.Key, next, final)
+	if err != nil {
+		return err
+	}
+
+	cc, err := c.getConn(ctx, next)
+	if err != nil {
+		return err
+	}
+	err = cc.Invoke(fwdCtx, info.Method, args, reply, opts...)
+	c.connFailed(cc, next, err)
+	return err
+}
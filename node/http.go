@@ -2,12 +2,19 @@ package node
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 )
 
@@ -110,10 +117,189 @@ func WriteHTTPState(l log.Logger, w io.Writer, n *Node) {
 		Now   time.Time
 		State *api.State
 	}{
-		Now:   time.Now(),
+		Now:   n.cfg.Clock.Now(),
 		State: state,
 	})
 	if err != nil {
 		level.Error(l).Log("msg", "failed to execute template", "err", err)
 	}
 }
+
+// jsonState is the JSON representation of State written by WriteJSONState.
+// Fields must not be renamed or removed, since scripts may depend on them.
+type jsonState struct {
+	Node        jsonPeer  `json:"node"`
+	IDSize      int       `json:"id_size"`
+	IDBase      int       `json:"id_base"`
+	Version     uint64    `json:"version"`
+	LastUpdated time.Time `json:"last_updated"`
+
+	Predecessors []jsonPeer   `json:"predecessors"`
+	Successors   []jsonPeer   `json:"successors"`
+	Neighbors    []jsonPeer   `json:"neighbors"`
+	Routing      []jsonRoute  `json:"routing"`
+	Health       []jsonHealth `json:"health"`
+}
+
+type jsonPeer struct {
+	ID     string            `json:"id"`
+	Digits string            `json:"digits"`
+	Addr   string            `json:"addr"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type jsonRoute struct {
+	Row  int      `json:"row"`
+	Col  int      `json:"col"`
+	Peer jsonPeer `json:"peer"`
+}
+
+type jsonHealth struct {
+	Peer   jsonPeer `json:"peer"`
+	Health string   `json:"health"`
+}
+
+// WriteJSONState writes the state of n as JSON to w. Only non-empty routing
+// table entries are included, and peer health is sorted by ID.
+func WriteJSONState(l log.Logger, w io.Writer, n *Node) {
+	s := n.State()
+
+	toPeer := func(p Peer) jsonPeer {
+		return jsonPeer{
+			ID:     p.ID.String(),
+			Digits: p.ID.Digits(s.Size, s.Base).String(),
+			Addr:   p.Addr,
+			Labels: p.Labels.Map(),
+		}
+	}
+	toPeers := func(ps []Peer) []jsonPeer {
+		res := make([]jsonPeer, len(ps))
+		for i, p := range ps {
+			res[i] = toPeer(p)
+		}
+		return res
+	}
+
+	res := jsonState{
+		Node:        toPeer(s.Node),
+		IDSize:      s.Size,
+		IDBase:      s.Base,
+		Version:     s.Version,
+		LastUpdated: s.LastUpdated,
+
+		Predecessors: toPeers(s.Predecessors),
+		Successors:   toPeers(s.Successors),
+		Neighbors:    toPeers(s.Neighbors),
+		Routing:      []jsonRoute{},
+		Health:       make([]jsonHealth, 0, len(s.Health)),
+	}
+
+	for row := range s.Routing {
+		for col, ent := range s.Routing[row] {
+			if ent == nil {
+				continue
+			}
+			res.Routing = append(res.Routing, jsonRoute{Row: row, Col: col, Peer: toPeer(*ent)})
+		}
+	}
+
+	peers := make([]Peer, 0, len(s.Health))
+	for p := range s.Health {
+		peers = append(peers, p)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return id.Compare(peers[i].ID, peers[j].ID) < 0
+	})
+	for _, p := range peers {
+		res.Health = append(res.Health, jsonHealth{Peer: toPeer(p), Health: s.Health[p].String()})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		level.Error(l).Log("msg", "failed to encode state", "err", err)
+	}
+}
+
+// StateHandler returns an http.Handler that writes the state of n. JSON is
+// written if the request prefers application/json through its Accept header
+// or sets the query parameter format=json. HTML is written otherwise.
+func StateHandler(l log.Logger, n *Node) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if wantsJSON(r) {
+			rw.Header().Set("Content-Type", "application/json")
+			WriteJSONState(l, rw, n)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		WriteHTTPState(l, rw, n)
+	})
+}
+
+// wantsJSON returns true if r prefers a JSON response.
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "html":
+		return false
+	}
+
+	// Use the first media type we know about from the Accept header. Quality
+	// values are ignored; clients list their preferred type first in practice.
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// Handler returns an http.Handler that serves endpoints for operating n:
+//
+//   - /-/ready responds with 200 once n has joined the cluster and has a
+//     usable state, and 503 otherwise, such as while n is draining, leaving,
+//     or isolated from the cluster.
+//   - /-/healthy responds with 200 while n is running.
+//   - /-/cluster writes the state of n like StateHandler.
+func Handler(l log.Logger, n *Node) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/ready", func(rw http.ResponseWriter, _ *http.Request) {
+		if err := n.ready(); err != nil {
+			http.Error(rw, fmt.Sprintf("Node is not ready: %s.", err), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(rw, "Node is ready.")
+	})
+	mux.HandleFunc("/-/healthy", func(rw http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(rw, "Node is healthy.")
+	})
+	mux.Handle("/-/cluster", StateHandler(l, n))
+	return mux
+}
+
+// ready returns an error explaining why n isn't ready to handle requests.
+func (n *Node) ready() error {
+	n.mut.Lock()
+	joined := n.joined
+	n.mut.Unlock()
+
+	switch {
+	case !joined:
+		return fmt.Errorf("not joined")
+	case n.controller.leaving.Load():
+		return fmt.Errorf("leaving")
+	case n.controller.draining.Load():
+		return fmt.Errorf("draining")
+	case n.controller.isolated.Load():
+		return fmt.Errorf("isolated from the cluster")
+	}
+	return nil
+}
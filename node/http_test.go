@@ -0,0 +1,94 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	h := StateHandler(l, seed)
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json, text/plain")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var res jsonState
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		require.Equal(t, seed.cfg.BroadcastAddr, res.Node.Addr)
+		require.Equal(t, seed.cfg.ID.String(), res.Node.ID)
+		require.Len(t, res.Predecessors, 1)
+		require.Equal(t, peer.cfg.BroadcastAddr, res.Predecessors[0].Addr)
+		require.NotEmpty(t, res.Routing)
+	})
+
+	t.Run("query parameter", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/?format=json", nil))
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("html", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "text/html,application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		require.Contains(t, rec.Body.String(), "<h1>Node State</h1>")
+	})
+}
+
+func TestHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, n := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	h := Handler(l, n)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, get("/-/healthy").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/-/ready").Code)
+
+	require.NoError(t, n.Join(ctx, nil))
+	require.Equal(t, http.StatusOK, get("/-/ready").Code)
+
+	n.Drain()
+	rec := get("/-/ready")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "draining")
+	n.Resume()
+	require.Equal(t, http.StatusOK, get("/-/ready").Code)
+
+	rec = get("/-/cluster?format=json")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+}
@@ -0,0 +1,79 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rfratto/croissant/id"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// KeyExtractor derives the routing key of a request from its message.
+// method is the full name of the gRPC method, /package.Service/Method.
+// KeyExtractors should return ErrNoKey if req has no key, in which case
+// the request is handled by the local node.
+type KeyExtractor func(method string, req proto.Message) (id.ID, error)
+
+// WithKeyExtractor derives the routing key of requests from their message
+// using e when the request context has no key from WithClientKey. Keys are
+// only derived for unary requests, since the messages of streams aren't
+// known when they're established.
+func WithKeyExtractor(e KeyExtractor) ClientOption {
+	return func(c *Client) {
+		c.keyExtractor = e
+	}
+}
+
+// FieldKeyExtractor returns a KeyExtractor that derives keys from the
+// field named field of request messages, such as "key". String and bytes
+// fields are hashed into keys with gen. Requests whose messages have no
+// such field have no key.
+func FieldKeyExtractor(gen id.Generator, field string) KeyExtractor {
+	name := protoreflect.Name(field)
+
+	return func(method string, req proto.Message) (id.ID, error) {
+		msg := req.ProtoReflect()
+		fd := msg.Descriptor().Fields().ByName(name)
+		if fd == nil {
+			return id.Zero, ErrNoKey
+		}
+
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			return gen.Get(msg.Get(fd).String()), nil
+		case protoreflect.BytesKind:
+			return gen.Get(string(msg.Get(fd).Bytes())), nil
+		default:
+			return id.Zero, fmt.Errorf("field %s of %s is not a string or bytes field", field, msg.Descriptor().FullName())
+		}
+	}
+}
+
+// requestKey returns the routing key of a request with message req. Keys
+// set with WithKey in opts are preferred, followed by the key in ctx and
+// then a key derived with e. Returns an error
+// wrapping ErrNoKey if the request has no key. Other errors are returned as
+// InvalidArgument unless they're already gRPC status errors.
+func requestKey(ctx context.Context, e KeyExtractor, method string, req interface{}, opts []grpc.CallOption) (id.ID, error) {
+	if key, ok := callKey(opts); ok {
+		return key, nil
+	}
+
+	key, err := ExtractClientKey(ctx)
+	if errors.Is(err, ErrNoKey) && e != nil {
+		if msg, ok := req.(proto.Message); ok {
+			key, err = e(method, msg)
+		}
+	}
+	if err == nil || errors.Is(err, ErrNoKey) {
+		return key, err
+	} else if _, ok := status.FromError(err); ok {
+		return id.Zero, err
+	}
+	return id.Zero, status.Errorf(codes.InvalidArgument, "missing or invalid routing key: %s", err)
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/examples/kv/kvserver"
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFieldKeyExtractor(t *testing.T) {
+	gen := id.NewGenerator(32)
+	e := FieldKeyExtractor(gen, "key")
+
+	key, err := e("/example.kv.v1.KV/Get", &kvproto.GetRequest{Key: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, gen.Get("hello"), key)
+
+	_, err = e("/test/Empty", &emptypb.Empty{})
+	require.ErrorIs(t, err, ErrNoKey)
+
+	e = FieldKeyExtractor(gen, "value")
+	_, err = e("/test/Int", &wrapperspb.Int64Value{Value: 1})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNoKey)
+}
+
+func TestRouter_KeyExtractor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	namedKVServer := func(name string) *kvserver.Func {
+		var kvFunc kvserver.Func
+		kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+			return &kvproto.GetResponse{Value: name}, nil
+		}
+		return &kvFunc
+	}
+
+	var seedRouter Router
+	seedRouter.SetKeyExtractor(FieldKeyExtractor(id.NewGenerator(32), "key"))
+
+	_, seedNode := makeTestNodeWithRouter(t, log.With(l, "node", "seed"), &seedRouter, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, namedKVServer("seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, namedKVServer("peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	// Find a key owned by each node and make sure requests without a key in
+	// their context reach the owner.
+	found := map[string]bool{}
+	for i := 0; len(found) < 2 && i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+
+		_, self, err := seedNode.NextPeer(seedNode.Generator().Get(key))
+		require.NoError(t, err)
+		expect := "peer"
+		if self {
+			expect = "seed"
+		}
+		if found[expect] {
+			continue
+		}
+		found[expect] = true
+
+		resp, err := kvproto.NewKVClient(clusterCC).Get(ctx, &kvproto.GetRequest{Key: key})
+		require.NoError(t, err)
+		require.Equal(t, expect, resp.GetValue(), "unexpected owner of %s", key)
+	}
+	require.Len(t, found, 2)
+
+	// Clients may also derive keys.
+	cli := NewClient(seedNode, WithKeyExtractor(func(string, proto.Message) (id.ID, error) {
+		return id.Zero, status.Errorf(codes.FailedPrecondition, "no key today")
+	}))
+	_, err = kvproto.NewKVClient(cli).Get(ctx, &kvproto.GetRequest{Key: "key"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestRouter_KeyExtractor_Drain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	namedKVServer := func(name string) *kvserver.Func {
+		var kvFunc kvserver.Func
+		kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+			return &kvproto.GetResponse{Value: name}, nil
+		}
+		return &kvFunc
+	}
+	newRouter := func() *Router {
+		var r Router
+		r.SetKeyExtractor(FieldKeyExtractor(id.NewGenerator(32), "key"))
+		return &r
+	}
+
+	_, seedNode := makeTestNodeWithRouter(t, log.With(l, "node", "seed"), newRouter(), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, namedKVServer("seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNodeWithRouter(t, log.With(l, "node", "peer"), newRouter(), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, namedKVServer("peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	// Find a key owned by the seed.
+	var key string
+	for i := 0; i < 1000; i++ {
+		candidate := fmt.Sprintf("key-%d", i)
+		_, self, err := seedNode.NextPeer(seedNode.Generator().Get(candidate))
+		require.NoError(t, err)
+		if self {
+			key = candidate
+			break
+		}
+	}
+	require.NotEmpty(t, key, "no key owned by the seed")
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	// The peer must handle requests redirected to it by the draining seed
+	// instead of deriving the key again and routing them back.
+	seedNode.Drain()
+	resp, err := kvproto.NewKVClient(clusterCC).Get(ctx, &kvproto.GetRequest{Key: key})
+	require.NoError(t, err)
+	require.Equal(t, "peer", resp.GetValue())
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// Labels is an immutable set of key/value pairs describing a node, such as
+// its zone or capacity. Labels can be compared with ==, so Peers remain
+// usable as map keys.
+type Labels struct {
+	enc string
+}
+
+// NewLabels creates Labels from m.
+func NewLabels(m map[string]string) Labels {
+	return Labels{enc: api.EncodeLabels(m)}
+}
+
+// Get returns the value for key. ok is false if key isn't set.
+func (l Labels) Get(key string) (value string, ok bool) {
+	value, ok = api.DecodeLabels(l.enc)[key]
+	return
+}
+
+// Map returns a copy of l as a map. Returns nil if l is empty.
+func (l Labels) Map() map[string]string {
+	return api.DecodeLabels(l.enc)
+}
+
+// Len returns the number of labels.
+func (l Labels) Len() int {
+	return len(api.DecodeLabels(l.enc))
+}
+
+// String returns l in a canonical form of sorted, URL-encoded key=value
+// pairs separated by &.
+func (l Labels) String() string {
+	return l.enc
+}
+
+// peerFromDescriptor converts d into a Peer.
+func peerFromDescriptor(d api.Descriptor) Peer {
+	return Peer{ID: d.ID, Addr: d.Addr, Labels: Labels{enc: d.Labels}, sig: d.Signature}
+}
+
+// descriptor converts p into a Descriptor.
+func (p Peer) descriptor() api.Descriptor {
+	return api.Descriptor{ID: p.ID, Addr: p.Addr, Labels: p.Labels.enc, Signature: p.sig}
+}
@@ -0,0 +1,62 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of requests forwarded to peers.
+type Limiter interface {
+	// Allow returns true if a request may be forwarded to p now.
+	Allow(p Peer) bool
+}
+
+// NewPeerLimiter returns a Limiter that uses a token bucket per peer. Each
+// peer may receive up to rate requests per second, with bursts of up to burst
+// requests.
+func NewPeerLimiter(rate float64, burst int) Limiter {
+	return &peerLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[Peer]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+type peerLimiter struct {
+	rate, burst float64
+
+	mut     sync.Mutex
+	buckets map[Peer]*tokenBucket
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *peerLimiter) Allow(p Peer) bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	now := l.now()
+
+	b, ok := l.buckets[p]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[p] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,76 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPeerLimiter(t *testing.T) {
+	now := time.Now()
+
+	l := NewPeerLimiter(1, 2).(*peerLimiter)
+	l.now = func() time.Time { return now }
+
+	var (
+		a = Peer{ID: id.ID{Low: 1}, Addr: "a"}
+		b = Peer{ID: id.ID{Low: 2}, Addr: "b"}
+	)
+
+	// Burst of 2 is allowed, but not a third request.
+	require.True(t, l.Allow(a))
+	require.True(t, l.Allow(a))
+	require.False(t, l.Allow(a))
+
+	// Other peers have their own bucket.
+	require.True(t, l.Allow(b))
+
+	// After a second, one more token should be available.
+	now = now.Add(time.Second)
+	require.True(t, l.Allow(a))
+	require.False(t, l.Allow(a))
+}
+
+func TestMembershipLimits(t *testing.T) {
+	l := newMembershipLimits(&RateLimitConfig{
+		PeerRate:            0.001,
+		PeerBurst:           2,
+		MaxConcurrentJoins:  1,
+		MaxConcurrentHellos: 2,
+	})
+
+	var (
+		a = api.Descriptor{ID: id.ID{Low: 1}, Addr: "a"}
+		b = api.Descriptor{ID: id.ID{Low: 2}, Addr: "b"}
+	)
+
+	// Only one join may be handled at a time.
+	release, err := l.acquire(a, methodJoin)
+	require.NoError(t, err)
+	_, err = l.acquire(b, methodJoin)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	release()
+
+	// Once released, a can join again but then runs out of tokens.
+	release, err = l.acquire(a, methodJoin)
+	require.NoError(t, err)
+	release()
+	_, err = l.acquire(a, methodJoin)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Hellos are limited separately.
+	release, err = l.acquire(a, methodHello)
+	require.NoError(t, err)
+	defer release()
+
+	// A nil limit allows everything.
+	var unlimited *membershipLimits
+	release, err = unlimited.acquire(a, methodJoin)
+	require.NoError(t, err)
+	release()
+}
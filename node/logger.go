@@ -0,0 +1,10 @@
+package node
+
+import "github.com/go-kit/kit/log"
+
+// Logger logs messages as alternating keys and values. Messages are logged
+// with a "msg" key and a level from the go-kit level package.
+//
+// Logger is the same interface as go-kit's log.Logger, so go-kit loggers can
+// be used directly. Use NewSlogLogger to log with log/slog instead.
+type Logger = log.Logger
@@ -0,0 +1,81 @@
+//go:build go1.21
+// +build go1.21
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// NewSlogLogger returns a Logger that writes messages to l. The "msg" key
+// is used as the message of the record, and levels from the go-kit level
+// package map to the slog level of the same name. Messages without a level
+// are logged at slog.LevelInfo.
+//
+// Messages below the level enabled by the handler of l are dropped, so
+// filtering with level.NewFilter isn't needed.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{h: l.Handler()}
+}
+
+type slogLogger struct {
+	h slog.Handler
+}
+
+func (l slogLogger) Log(keyvals ...interface{}) error {
+	var (
+		ctx = context.Background()
+		lvl = slog.LevelInfo
+		msg string
+
+		attrs = make([]slog.Attr, 0, len(keyvals)/2)
+	)
+	for i := 0; i < len(keyvals); i += 2 {
+		var (
+			k             = keyvals[i]
+			v interface{} = log.ErrMissingValue
+		)
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+
+		switch {
+		case k == level.Key():
+			if lv, ok := v.(level.Value); ok {
+				lvl = slogLevel(lv)
+				continue
+			}
+		case k == "msg" && msg == "":
+			msg = fmt.Sprint(v)
+			continue
+		}
+		attrs = append(attrs, slog.Any(fmt.Sprint(k), v))
+	}
+	if !l.h.Enabled(ctx, lvl) {
+		return nil
+	}
+
+	r := slog.NewRecord(time.Now(), lvl, msg, 0)
+	r.AddAttrs(attrs...)
+	return l.h.Handle(ctx, r)
+}
+
+// slogLevel converts a go-kit level to a slog level.
+func slogLevel(v level.Value) slog.Level {
+	switch v {
+	case level.DebugValue():
+		return slog.LevelDebug
+	case level.WarnValue():
+		return slog.LevelWarn
+	case level.ErrorValue():
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package node
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := log.With(NewSlogLogger(slog.New(h)), "node", "a")
+
+	require.NoError(t, level.Debug(l).Log("msg", "dropped"))
+	require.NoError(t, level.Warn(l).Log("msg", "peer unhealthy", "peer", "b"))
+	require.NoError(t, l.Log("msg", "no level", "odd"))
+
+	require.Equal(t, "level=WARN msg=\"peer unhealthy\" node=a peer=b\n"+
+		"level=INFO msg=\"no level\" node=a odd=(MISSING)\n", buf.String())
+}
@@ -0,0 +1,155 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// Outcomes of routed requests for the forwarded requests metric.
+const (
+	outcomeLocal     = "local"     // Handled by the local node.
+	outcomeForwarded = "forwarded" // Forwarded to a peer.
+	outcomeFailed    = "failed"    // Forwarding to a peer failed.
+)
+
+type metrics struct {
+	joinsInitiatedTotal   prometheus.Counter
+	joinsCompletedTotal   prometheus.Counter
+	joinRestartsTotal     prometheus.Counter
+	joinFailuresTotal     prometheus.Counter
+	joinsHandledTotal     prometheus.Counter
+	warmJoinsTotal        prometheus.Counter
+	goodbyesSentTotal     prometheus.Counter
+	goodbyesReceivedTotal prometheus.Counter
+	hellosSentTotal       prometheus.Counter
+	hellosReceivedTotal   prometheus.Counter
+	heartbeatsSentTotal   prometheus.Counter
+	brokenStreamsTotal    prometheus.Counter
+	limitedRequestsTotal  *prometheus.CounterVec
+	routedRequestsTotal   *prometheus.CounterVec
+	requestHops           prometheus.Histogram
+
+	leaves       prometheus.GaugeFunc
+	routingFill  prometheus.GaugeFunc
+	stateAgeSecs prometheus.GaugeFunc
+}
+
+func newMetrics(r prometheus.Registerer, state *api.State) *metrics {
+	var m metrics
+	m.joinsInitiatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_joins_initiated_total",
+		Help: "Total number of times this node attempted to join a cluster",
+	})
+	m.joinsCompletedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_joins_completed_total",
+		Help: "Total number of times this node successfully joined a cluster",
+	})
+	m.joinRestartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_join_restarts_total",
+		Help: "Total number of times a join was restarted because a peer's state changed",
+	})
+	m.joinFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_join_failures_total",
+		Help: "Total number of failed attempts to join a cluster",
+	})
+	m.joinsHandledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_joins_handled_total",
+		Help: "Total number of join requests from other nodes handled by this node",
+	})
+	m.warmJoinsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_warm_joins_total",
+		Help: "Total number of times this node joined a cluster through its cached leaves",
+	})
+	m.goodbyesSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_goodbyes_sent_total",
+		Help: "Total number of goodbyes sent to peers when leaving the cluster",
+	})
+	m.goodbyesReceivedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_goodbyes_received_total",
+		Help: "Total number of goodbyes received from peers leaving the cluster",
+	})
+	m.hellosSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_hellos_sent_total",
+		Help: "Total number of hellos sent to peers",
+	})
+	m.hellosReceivedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_hellos_received_total",
+		Help: "Total number of hellos received from peers",
+	})
+	m.heartbeatsSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_gossip_heartbeats_sent_total",
+		Help: "Total number of heartbeats sent to peers over gossip streams",
+	})
+	m.brokenStreamsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "croissant_gossip_streams_broken_total",
+		Help: "Total number of gossip streams with peers that broke unexpectedly",
+	})
+	m.limitedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "croissant_limited_requests_total",
+		Help: "Total number of Join and NodeHello requests rejected by rate limits, by method",
+	}, []string{"method"})
+	m.routedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "croissant_routed_requests_total",
+		Help: "Total number of requests with a routing key received by this node, by outcome",
+	}, []string{"outcome"})
+	m.requestHops = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "croissant_request_hops",
+		Help:    "Number of hops routed requests took before being handled by this node",
+		Buckets: prometheus.LinearBuckets(0, 1, 8),
+	})
+
+	m.leaves = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "croissant_leaves",
+		Help: "Current number of healthy leaves known by this node",
+	}, func() float64 {
+		return float64(len(state.Leaves(false)))
+	})
+	m.routingFill = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "croissant_routing_table_fill_ratio",
+		Help: "Ratio of filled entries in this node's routing table",
+	}, state.RoutingFill)
+	m.stateAgeSecs = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "croissant_state_age_seconds",
+		Help: "Seconds since this node's routing state last changed",
+	}, func() float64 {
+		return state.Age().Seconds()
+	})
+
+	if r != nil {
+		r.MustRegister(m.collectors()...)
+	}
+
+	return &m
+}
+
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.joinsInitiatedTotal,
+		m.joinsCompletedTotal,
+		m.joinRestartsTotal,
+		m.joinFailuresTotal,
+		m.joinsHandledTotal,
+		m.warmJoinsTotal,
+		m.goodbyesSentTotal,
+		m.goodbyesReceivedTotal,
+		m.hellosSentTotal,
+		m.hellosReceivedTotal,
+		m.heartbeatsSentTotal,
+		m.brokenStreamsTotal,
+		m.limitedRequestsTotal,
+		m.routedRequestsTotal,
+		m.requestHops,
+		m.leaves,
+		m.routingFill,
+		m.stateAgeSecs,
+	}
+}
+
+func (m *metrics) Unregister(r prometheus.Registerer) {
+	if r == nil {
+		return
+	}
+	for _, c := range m.collectors() {
+		r.Unregister(c)
+	}
+}
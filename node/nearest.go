@@ -0,0 +1,84 @@
+package node
+
+import (
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// WithNearestReplica routes requests to the replica of their key with the
+// lowest latency rather than to the owner of the key. Replicas are
+// determined by Node.Replicas, so Config.ReplicationFactor must be larger
+// than 1 for requests to go to any node other than the owner.
+//
+// The local node is preferred when it's a replica. Otherwise, replicas are
+// ranked by the average latency of recent calls to them. Replicas which
+// haven't been called yet are ranked after the others, preferring replicas
+// in the node's neighbor set. Replicas with open circuits are skipped.
+//
+// Requests for keys too far away from the node to know their replicas are
+// routed to the owner as usual. Since any replica may handle a request,
+// WithNearestReplica should only be used for reads which tolerate stale
+// data.
+func WithNearestReplica() ClientOption {
+	return func(c *Client) {
+		c.nearestReplica = true
+	}
+}
+
+// nearestHop returns the replica of key with the lowest latency. ok is false
+// if the replicas of key aren't known. redirected is true if the replica
+// isn't the owner of key, in which case it must handle the request itself.
+func (c *Client) nearestHop(key id.ID) (next api.Descriptor, redirected bool, ok bool) {
+	s := c.ctrl.routeState(key)
+	replicas, ok := c.ctrl.replicasFor(s, key)
+	if !ok || len(replicas) == 0 {
+		return api.Descriptor{}, false, false
+	}
+
+	var (
+		best        api.Descriptor
+		bestLatency time.Duration
+		bestRank    = -1
+	)
+	for _, r := range replicas {
+		rank, latency, usable := c.replicaRank(s, r)
+		if !usable {
+			continue
+		}
+		if bestRank < 0 || rank < bestRank || (rank == bestRank && latency < bestLatency) {
+			best, bestLatency, bestRank = r, latency, rank
+		}
+	}
+	if bestRank < 0 {
+		return api.Descriptor{}, false, false
+	}
+
+	if best != replicas[0] {
+		level.Debug(c.ctrl.log).Log("msg", "routing to nearest replica", "key", key, "peer", best.Addr, "latency", bestLatency)
+	}
+	return best, best != replicas[0], true
+}
+
+// replicaRank ranks r for nearestHop, lower first. Replicas with the same
+// rank are ordered by latency. usable is false if r should be skipped.
+func (c *Client) replicaRank(s *api.State, r api.Descriptor) (rank int, latency time.Duration, usable bool) {
+	if c.ctrl.isLocal(r) {
+		if !c.allowSelf || c.ctrl.draining.Load() {
+			return 0, 0, false
+		}
+		return 0, 0, true
+	}
+
+	if c.ctrl.pool.CircuitOpen(r.Addr) {
+		return 0, 0, false
+	}
+	if latency, ok := c.ctrl.pool.Latency(r.Addr); ok {
+		return 1, latency, true
+	} else if s.Neighbors.Contains(r) {
+		return 2, 0, true
+	}
+	return 3, 0, true
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/clock"
+	"github.com/rfratto/croissant/discovery"
 	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 	"github.com/rfratto/croissant/internal/connpool"
@@ -17,8 +21,37 @@ import (
 	"github.com/rfratto/croissant/internal/nodepb"
 	"go.uber.org/atomic"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/protobuf/proto"
 )
 
+// DefaultStateCompressionThreshold is the default size in bytes above which
+// states sent to peers are compressed.
+const DefaultStateCompressionThreshold = 64 * 1024
+
+// DefaultMaxHops is the default maximum number of hops of routed requests
+// and joins.
+const DefaultMaxHops = 32
+
+// DefaultRepairInterval is the default interval between repairs of the
+// routing table.
+const DefaultRepairInterval = 10 * time.Minute
+
+// DefaultConnIdleTimeout is the default amount of time connections to peers
+// may be unused before they're closed.
+const DefaultConnIdleTimeout = 5 * time.Minute
+
+// DefaultMembersMaxStaleness is the default amount of time the member list
+// returned by Node.Members is cached for.
+const DefaultMembersMaxStaleness = 30 * time.Second
+
+// DefaultMaxConns is the minimum number of connections kept open to peers
+// when Config.MaxConns is unset.
+const DefaultMaxConns = 250
+
 // Config controls how a node is initialized.
 type Config struct {
 	// ID represents the server. Must be specified.
@@ -28,34 +61,436 @@ type Config struct {
 	// Must bet set.
 	BroadcastAddr string
 
+	// ClusterName identifies the cluster the node belongs to. Nodes reject
+	// joins and hellos from nodes with a different ClusterName, preventing
+	// unrelated clusters that share a discovery mechanism from accidentally
+	// merging. Optional, but all nodes in a cluster must use the same
+	// ClusterName.
+	ClusterName string
+
+	// Labels is arbitrary metadata about the node, such as its zone, rack,
+	// or capacity. Labels are shared with peers and exposed through
+	// Peer.Labels.
+	Labels map[string]string
+
 	// Number of leaves to track. Will be divisible by 2. Defaults to 8 if
 	// unset.
 	NumLeaves int
 	// Number of neighbors to track for locality. Defaults to 8 if unset.
 	NumNeighbors int
 
-	// Log will be used for logging messages.
-	Log log.Logger
+	// IDSize is the size in bits of IDs in the cluster. Must be one of 8, 16,
+	// 32, 64, or 128. Defaults to 32 if unset.
+	IDSize int
+	// IDBase is the base of digits used for routing. Must be a power of two
+	// no greater than 16. Defaults to 16 if unset.
+	//
+	// All nodes in a cluster must use the same IDSize and IDBase. Use
+	// Node.Generator to generate keys that match the node's configuration.
+	IDBase int
+
+	// HelloTimeout is the maximum amount of time to wait for each individual
+	// Hello sent while joining the cluster. The final Hello sent to a joining
+	// node isn't bounded, since the joiner completes its join while handling
+	// it. Defaults to 5s if unset.
+	HelloTimeout time.Duration
+
+	// MaxHops is the maximum number of times a routed request or a join may
+	// be forwarded between nodes. Requests are also rejected if they would
+	// be forwarded to a node they already passed through, which indicates a
+	// routing loop. Defaults to DefaultMaxHops if unset.
+	MaxHops int
+
+	// GossipInterval is how often the node greets its leaves, exchanging
+	// states to detect failed leaves and spread changes. Each interval is
+	// randomly jittered by up to 25% so nodes don't greet their leaves at the
+	// same time. Defaults to 1m if unset.
+	GossipInterval time.Duration
+	// GossipTimeout is the maximum amount of time to wait for leaves to
+	// respond to a greeting. Defaults to 5s if unset.
+	GossipTimeout time.Duration
+	// DisableGossipStreams disables gossip streams. By default, the node
+	// greets each leaf over a long-lived stream, sending its state only when
+	// it changed and a heartbeat otherwise. Leaves are marked unhealthy as
+	// soon as their stream breaks. When disabled, or for leaves that don't
+	// support gossip streams, the full exchange is repeated for every
+	// greeting.
+	DisableGossipStreams bool
+
+	// RepairInterval is how often the node repairs a random row of its
+	// routing table by asking a peer for its entries in the same row,
+	// filling holes and replacing stale entries. Defaults to
+	// DefaultRepairInterval if unset. Set to a negative value to disable
+	// repairs.
+	RepairInterval time.Duration
+
+	// ReplicationFactor is the number of nodes that are responsible for each
+	// key: the owner of the key and the nodes succeeding it on the ring.
+	// Used by Node.Replicas. Must be no larger than NumLeaves/2+1. Defaults
+	// to 1 if unset.
+	ReplicationFactor int
+
+	// MembersMaxStaleness is how long the member list returned by
+	// Node.Members may be cached before walking the ring again. Defaults to
+	// DefaultMembersMaxStaleness if unset. Set to a negative value to walk
+	// the ring on every call.
+	MembersMaxStaleness time.Duration
+
+	// MaxConnAge is the maximum amount of time a connection to a peer will be
+	// reused before being replaced by a new connection, allowing address
+	// changes to take effect. Replaced connections are closed after their
+	// in-flight requests complete, but are kept open for a short grace
+	// period for requests which are about to start. Connections are reused
+	// indefinitely if unset.
+	MaxConnAge time.Duration
+
+	// ConnIdleTimeout is the maximum amount of time a connection to a peer
+	// may be unused before it's closed. Connections to peers which are no
+	// longer in the node's routing state are closed as well. Idle
+	// connections are checked in the background. Defaults to
+	// DefaultConnIdleTimeout if unset. Set to a negative value to keep idle
+	// connections open.
+	ConnIdleTimeout time.Duration
+
+	// MaxConns is the maximum number of connections kept open to peers.
+	// When the limit is reached, the least recently used connection is
+	// closed to open a new one. By default, the limit scales with the
+	// cluster: it's twice the number of peers in the node's routing state,
+	// and no less than DefaultMaxConns.
+	MaxConns int
+
+	// DialTimeout is the minimum amount of time to wait for an attempt to
+	// connect to a peer to complete before retrying. Uses the gRPC default
+	// of 20s if unset.
+	DialTimeout time.Duration
+
+	// DialBackoff, if set, configures the backoff between failed attempts to
+	// connect to a peer. Uses the gRPC default backoff if unset.
+	DialBackoff *DialBackoffConfig
+
+	// Dialer, if set, creates the connections to peers instead of
+	// grpc.Dial. Dialer allows traffic between nodes to use other
+	// transports, such as Unix sockets, in-process listeners, or a service
+	// mesh. See DialFunc.
+	Dialer DialFunc
+
+	// Compressor is the name of a registered gRPC compressor, such as
+	// "gzip", used for every request sent to peers, including forwarded
+	// requests. Requests are not compressed by default, except for large
+	// states (see StateCompressionThreshold).
+	Compressor string
+
+	// MaxRecvMsgSize is the maximum size in bytes of responses received from
+	// peers. Uses the gRPC default if unset. The maximum size of requests
+	// received by the node is configured on the gRPC server.
+	MaxRecvMsgSize int
+
+	// MaxSendMsgSize is the maximum size in bytes of requests sent to peers.
+	// Uses the gRPC default if unset.
+	MaxSendMsgSize int
+
+	// StateCompressionThreshold is the size in bytes above which states sent
+	// to peers in Hellos are compressed with gzip. Defaults to
+	// DefaultStateCompressionThreshold if unset. Set to a negative value to
+	// never compress states.
+	StateCompressionThreshold int
+
+	// NumVirtualNodes is the number of IDs the node registers in the ring.
+	// Using multiple virtual nodes spreads the keys owned by the node across
+	// the ring, improving the balance of keys between nodes in small
+	// clusters. The first virtual node uses ID, and the IDs of the others are
+	// derived from ID. Defaults to 1 if unset.
+	//
+	// Routing methods such as NextPeer treat every virtual node as the local
+	// node. Peers returned by the node have the ID of the peer's virtual
+	// node closest to the relevant key, but always have the address of the
+	// physical node.
+	NumVirtualNodes int
+
+	// HealthCheck is used to check the health of peers. Defaults to
+	// StateHealthCheck if unset. Applications can use HealthCheck to define
+	// what being healthy means for their workload; GRPCHealthCheck and
+	// TCPHealthCheck may be used as alternatives.
+	//
+	// When SWIM is enabled, HealthCheck has no default and is only performed
+	// after a peer responds to a direct probe.
+	HealthCheck HealthCheckFunc
+
+	// SWIM, if set, enables SWIM-style failure detection. By default, every
+	// peer is probed on an interval by every node that knows about it, which
+	// becomes expensive in large clusters. With SWIM, one random peer is
+	// probed per interval, peers that fail a probe are probed indirectly
+	// through other peers, and health changes are piggybacked on probes.
+	//
+	// Peers that don't enable SWIM still respond to probes.
+	SWIM *SWIMConfig
+
+	// Rejoin, if set, makes the node automatically rejoin the cluster after
+	// becoming isolated. A node is isolated when every one of its leaves
+	// died, such as after a network partition. The node rejoins using the
+	// addresses or Discoverer it last joined with. By default, isolated
+	// nodes continue as a single-node cluster.
+	Rejoin *RejoinConfig
+
+	// DataDir, if set, is a directory where the node saves its ID, labels,
+	// and the peers it last knew about. When restarted with the same
+	// DataDir, the node reuses its saved ID and labels unless ID or Labels
+	// are set; setting an ID that doesn't match the saved ID is an error.
+	// Joins fall back to the saved peers when none of the given addresses
+	// can be joined, so restarted nodes can rejoin even if their seeds are
+	// down. DataDir is created if it doesn't exist.
+	//
+	// Restarted nodes first try to rejoin directly through their saved
+	// leaves, which is much cheaper than routing a join from a seed during
+	// rolling restarts. A full join is done instead if the saved leaves are
+	// unavailable or if other nodes joined next to the node while it was
+	// down.
+	//
+	// State versions are seeded from the start time of the node. With a
+	// DataDir, they're also seeded above the version saved when the node
+	// last changed peers or closed, so they keep increasing across restarts
+	// even if the clock went backwards. Without a DataDir, a clock that went
+	// backwards can make peers ignore the node's state until it changes
+	// past its old version.
+	DataDir string
+
+	// CircuitBreaker, if set, enables circuit breaking for peers. Peers
+	// whose requests keep failing with Unavailable are skipped by Clients for
+	// a cooldown period, and requests are routed to the next best peer
+	// instead.
+	CircuitBreaker *CircuitBreakerConfig
+
+	// RateLimits, if set, limits the Join and NodeHello requests handled by
+	// the node, protecting it from misbehaving or restart-looping peers.
+	// Requests over the limits are rejected with ResourceExhausted.
+	RateLimits *RateLimitConfig
+
+	// Placement, if set, customizes which peers are used as replicas and
+	// which peers are preferred when more than one peer may be used for
+	// routing. ZonePlacement may be used to spread replicas across zones.
+	// Peers are only considered as replicas if they are in the leaf set, so
+	// NumLeaves should be large enough to hold peers from every zone.
+	Placement PlacementPolicy
+
+	// AdmitPeer, if set, is consulted before a peer is added to the node's
+	// routing state or allowed to join the cluster through this node. Peers
+	// for which AdmitPeer returns false are ignored, and joins from them are
+	// rejected.
+	AdmitPeer func(p Peer) bool
+
+	// JoinAuthorizer, if set, is called for joins received directly from
+	// the joining node, allowing operators to reject unauthorized nodes from
+	// entering the ring. Joins propagated by other nodes in the cluster are
+	// only assumed to be authorized when TLS.VerifyNodeID is set; otherwise
+	// JoinAuthorizer is called at every hop, with the credentials of the
+	// node that propagated the join.
+	JoinAuthorizer JoinAuthorizer
+
+	// DescriptorSigner, if set, signs the node's descriptor (its ID,
+	// address, and labels) and authenticates the descriptors of peers
+	// before they're added to the node's routing state. Peers with missing
+	// or invalid signatures are ignored, and joins from them are rejected.
+	// All nodes in a cluster must use compatible signers.
+	DescriptorSigner DescriptorSigner
+
+	// TLS, if set, enables TLS for connections between nodes. The gRPC
+	// server the node is registered to must use TLS.ServerOption, and
+	// DialOptions passed to WithDialOptions must not include
+	// grpc.WithInsecure.
+	TLS *TLSConfig
+
+	// Clock, if set, is used to schedule gossip, repairs, and health checks,
+	// and to timestamp states. Simulations and tests can use a clock.Fake to
+	// control the passage of time. Defaults to the system clock if unset.
+	Clock clock.Clock
+
+	// RandSeed seeds the random numbers used by the node, such as to jitter
+	// intervals and to pick peers to probe or repair from. Combined with
+	// Clock, setting RandSeed makes the node's behavior reproducible.
+	// Seeded from the current time if unset.
+	RandSeed int64
+
+	// Log will be used for logging messages. Both go-kit and log/slog
+	// loggers are supported; see Logger.
+	Log Logger
+
+	// Registerer will be used to register metrics for the node. Metrics
+	// will not be registered if nil.
+	Registerer prometheus.Registerer
+
+	// Tracer, if set, is used to create spans for requests routed through
+	// the node. See Tracer for how to propagate traces between nodes.
+	Tracer Tracer
+
+	// RouteLogSampleRate is the fraction of routing decisions made by
+	// Clients of the node which are logged at debug level, from 0 to 1.
+	// Each logged decision includes the key, the chosen hop, and the reason
+	// the hop was chosen. Routing decisions aren't logged if unset.
+	RouteLogSampleRate float64
+}
+
+// RejoinConfig configures automatically rejoining the cluster. Attempts to
+// rejoin are retried with exponential backoff until the node has peers again.
+type RejoinConfig struct {
+	// MinBackoff is the time to wait before the first attempt to rejoin.
+	// Defaults to 1s if unset.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum time to wait between attempts. Defaults to
+	// 1m if unset.
+	MaxBackoff time.Duration
+}
+
+// DialFunc creates a client connection to the peer at addr. opts hold the
+// DialOptions passed to WithDialOptions along with the options derived from Config,
+// and must be used when creating the connection. Transports can be
+// customized by adding options such as grpc.WithContextDialer:
+//
+//	func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+//	  opts = append(opts, grpc.WithContextDialer(dialUnix))
+//	  return grpc.Dial(addr, opts...)
+//	}
+type DialFunc func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+
+// DialBackoffConfig configures the backoff between failed attempts to
+// connect to a peer. The backoff grows exponentially and is randomly
+// jittered.
+type DialBackoffConfig struct {
+	// MinBackoff is the time to wait after the first failed attempt.
+	// Defaults to 1s if unset.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum time to wait between attempts. Defaults to
+	// 2m if unset.
+	MaxBackoff time.Duration
+}
+
+// CircuitBreakerConfig configures circuit breaking for peers.
+//
+// The circuit for a peer opens once FailureRatio of the requests sent to
+// it within Window failed. After Cooldown, requests may be sent to the peer
+// again; the circuit closes after the next successful request, and opens
+// again after the next failure. Requests sent by the node for membership,
+// such as health checks, also count towards the circuit.
+type CircuitBreakerConfig struct {
+	// FailureRatio is the ratio of failed requests that opens the circuit.
+	// Defaults to 0.5 if unset.
+	FailureRatio float64
+	// MinRequests is the minimum number of requests within Window before
+	// the circuit may open. Defaults to 5 if unset.
+	MinRequests int
+	// Window is how long requests are counted before the counts reset.
+	// Defaults to 10s if unset.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open. Defaults to 30s if unset.
+	Cooldown time.Duration
+}
+
+// RateLimitConfig configures limits for Join and NodeHello requests. Limits
+// are shared by every virtual node.
+type RateLimitConfig struct {
+	// PeerRate is the number of Join requests and the number of NodeHello
+	// requests per second accepted from each peer. Joins are attributed to
+	// the joining peer, even when propagated by other nodes. Unlimited if
+	// unset.
+	PeerRate float64
+	// PeerBurst is the number of requests a peer may send at once before
+	// being limited by PeerRate. Defaults to PeerRate rounded up, with a
+	// minimum of 1, if unset.
+	PeerBurst int
+
+	// MaxConcurrentJoins is the maximum number of Join requests handled at
+	// once. Unlimited if unset.
+	MaxConcurrentJoins int
+	// MaxConcurrentHellos is the maximum number of NodeHello requests
+	// handled at once. Unlimited if unset.
+	MaxConcurrentHellos int
+}
+
+// SWIMConfig configures SWIM-style failure detection.
+type SWIMConfig struct {
+	// ProbeInterval is how often a random peer is probed. Defaults to 1s if
+	// unset.
+	ProbeInterval time.Duration
+	// ProbeTimeout is the timeout for directly probing a peer. Defaults to
+	// 250ms if unset. Must be less than ProbeInterval.
+	ProbeTimeout time.Duration
+	// IndirectProbes is the number of peers asked to probe a peer that failed
+	// a direct probe. Defaults to 3 if unset.
+	IndirectProbes int
+	// SuspicionTimeout is how long a peer that failed a probe is considered
+	// unhealthy before being removed. Defaults to 5s if unset.
+	SuspicionTimeout time.Duration
 }
 
 // Node is a node within a Croissant cluster.
 type Node struct {
 	cfg Config
+	app Application
+
+	controller *controller   // Controller for the first virtual node.
+	vnodes     []*controller // Controllers for all virtual nodes, including controller.
+
+	mut       sync.Mutex
+	seeds     discovery.Discoverer // Seeds the node last joined with.
+	registrar discovery.Registrar  // Used to register the node by JoinDiscovered.
+	rejoining bool                 // Set while the node is trying to rejoin.
+	joined    bool                 // Set once the node has joined the cluster.
 
-	controller *controller
+	healthServer *grpchealth.Server // Health service registered by RegisterAdmin.
+	events       *events            // Subscriptions created by Subscribe.
+
+	persistMut sync.Mutex
+	persisted  persistedState // Last state saved to Config.DataDir.
+
+	membersMut sync.Mutex // Held while walking the ring for Members.
+	members    []Peer     // Members found by the last walk.
+	membersAt  time.Time  // Time of the last walk. Zero if never walked.
 }
 
-// New creates a new Node and registers it against the given gRPC server. The
-// provided set of DialOptions are used when communicating with a cluster peer.
-func New(cfg Config, app Application, dial ...grpc.DialOption) (*Node, error) {
+// New creates a new Node from cfg. opts are applied on top of cfg and take
+// precedence over the fields of cfg they set. Use WithDialOptions to set the
+// DialOptions used when communicating with a cluster peer.
+//
+// Breaking change: New used to accept DialOptions directly as its variadic
+// arguments. Existing callers must wrap them in WithDialOptions:
+//
+//	New(cfg, app, grpc.WithInsecure())                  // Before
+//	New(cfg, app, WithDialOptions(grpc.WithInsecure())) // After
+//
+// If cfg is invalid, New returns a ConfigError listing every problem found.
+// Individual problems can be matched with errors.Is and errors.As.
+func New(cfg Config, app Application, opts ...Option) (*Node, error) {
+	o := options{cfg: &cfg}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	dial := o.dial
+
 	if cfg.Log == nil {
 		cfg.Log = log.NewNopLogger()
 	}
+	if cfg.Tracer == nil {
+		cfg.Tracer = noopTracer{}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.Real()
+	}
+	if cfg.RandSeed == 0 {
+		cfg.RandSeed = time.Now().UnixNano()
+	}
+	persisted := &persistedState{}
+	if cfg.DataDir != "" {
+		var err error
+		if persisted, err = applyPersisted(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs ConfigError
 	if cfg.ID == id.Zero {
-		return nil, fmt.Errorf("ID must be set")
+		errs = append(errs, fmt.Errorf("ID must be set"))
 	}
 	if cfg.BroadcastAddr == "" {
-		return nil, fmt.Errorf("BroadcastAddr must be set")
+		errs = append(errs, fmt.Errorf("BroadcastAddr must be set"))
 	}
 
 	if cfg.NumLeaves == 0 {
@@ -64,37 +499,479 @@ func New(cfg Config, app Application, dial ...grpc.DialOption) (*Node, error) {
 	if cfg.NumNeighbors == 0 {
 		cfg.NumNeighbors = 8
 	}
+	if cfg.IDSize == 0 {
+		cfg.IDSize = 32
+	}
+	if cfg.IDBase == 0 {
+		cfg.IDBase = 16
+	}
+	if cfg.HelloTimeout == 0 {
+		cfg.HelloTimeout = 5 * time.Second
+	}
+	if cfg.MaxHops == 0 {
+		cfg.MaxHops = DefaultMaxHops
+	}
+	if cfg.MaxHops < 0 {
+		errs = append(errs, fmt.Errorf("MaxHops must not be negative"))
+	}
+	if cfg.GossipInterval == 0 {
+		cfg.GossipInterval = time.Minute
+	}
+	if cfg.GossipTimeout == 0 {
+		cfg.GossipTimeout = 5 * time.Second
+	}
+	if cfg.GossipInterval < 0 || cfg.GossipTimeout < 0 {
+		errs = append(errs, fmt.Errorf("gossip interval and timeout must not be negative"))
+	}
+	if cfg.RepairInterval == 0 {
+		cfg.RepairInterval = DefaultRepairInterval
+	}
+	if cfg.ConnIdleTimeout == 0 {
+		cfg.ConnIdleTimeout = DefaultConnIdleTimeout
+	}
+	if cfg.MembersMaxStaleness == 0 {
+		cfg.MembersMaxStaleness = DefaultMembersMaxStaleness
+	}
+	if cfg.MaxConns < 0 || cfg.DialTimeout < 0 {
+		errs = append(errs, fmt.Errorf("MaxConns and DialTimeout must not be negative"))
+	}
+	if cfg.DialBackoff != nil {
+		dialBackoff := *cfg.DialBackoff
+		if dialBackoff.MinBackoff == 0 {
+			dialBackoff.MinBackoff = time.Second
+		}
+		if dialBackoff.MaxBackoff == 0 {
+			dialBackoff.MaxBackoff = 2 * time.Minute
+		}
+		if dialBackoff.MinBackoff < 0 || dialBackoff.MaxBackoff < dialBackoff.MinBackoff {
+			errs = append(errs, fmt.Errorf("DialBackoff MaxBackoff must not be less than MinBackoff"))
+		}
+		cfg.DialBackoff = &dialBackoff
+	}
+	if cfg.StateCompressionThreshold == 0 {
+		cfg.StateCompressionThreshold = DefaultStateCompressionThreshold
+	}
+	if cfg.Compressor != "" && encoding.GetCompressor(cfg.Compressor) == nil {
+		errs = append(errs, fmt.Errorf("compressor %q is not registered", cfg.Compressor))
+	}
+	if cfg.MaxRecvMsgSize < 0 || cfg.MaxSendMsgSize < 0 {
+		errs = append(errs, fmt.Errorf("message sizes must not be negative"))
+	}
+	if cfg.TLS != nil && cfg.TLS.Client == nil {
+		errs = append(errs, fmt.Errorf("TLS.Client must be set when TLS is enabled"))
+	}
 	if cfg.NumLeaves%2 != 0 {
-		return nil, fmt.Errorf("leaves must be divisible by 2")
+		errs = append(errs, fmt.Errorf("leaves must be divisible by 2"))
+	}
+	if cfg.NumVirtualNodes == 0 {
+		cfg.NumVirtualNodes = 1
+	}
+	if cfg.NumVirtualNodes < 0 {
+		errs = append(errs, fmt.Errorf("NumVirtualNodes must not be negative"))
+	}
+	if cfg.ReplicationFactor == 0 {
+		cfg.ReplicationFactor = 1
+	}
+	if cfg.ReplicationFactor < 0 || cfg.ReplicationFactor > cfg.NumLeaves/2+1 {
+		errs = append(errs, fmt.Errorf("ReplicationFactor must be between 1 and %d", cfg.NumLeaves/2+1))
+	}
+	switch cfg.IDSize {
+	case 8, 16, 32, 64, 128:
+		if id.Compare(cfg.ID, id.MaxForSize(cfg.IDSize)) > 0 {
+			errs = append(errs, fmt.Errorf("ID %s is too big for IDSize %d", cfg.ID, cfg.IDSize))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid IDSize %d", cfg.IDSize))
+	}
+	switch cfg.IDBase {
+	case 2, 4, 8, 16:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IDBase %d", cfg.IDBase))
+	}
+	if cfg.SWIM != nil {
+		swim := *cfg.SWIM
+		if swim.ProbeInterval == 0 {
+			swim.ProbeInterval = time.Second
+		}
+		if swim.ProbeTimeout == 0 {
+			swim.ProbeTimeout = 250 * time.Millisecond
+		}
+		if swim.IndirectProbes == 0 {
+			swim.IndirectProbes = 3
+		}
+		if swim.SuspicionTimeout == 0 {
+			swim.SuspicionTimeout = 5 * time.Second
+		}
+		if swim.ProbeTimeout >= swim.ProbeInterval {
+			errs = append(errs, fmt.Errorf("SWIM ProbeTimeout must be less than ProbeInterval"))
+		}
+		cfg.SWIM = &swim
+	}
+	if cfg.Rejoin != nil {
+		rejoin := *cfg.Rejoin
+		if rejoin.MinBackoff == 0 {
+			rejoin.MinBackoff = time.Second
+		}
+		if rejoin.MaxBackoff == 0 {
+			rejoin.MaxBackoff = time.Minute
+		}
+		if rejoin.MaxBackoff < rejoin.MinBackoff {
+			errs = append(errs, fmt.Errorf("Rejoin MaxBackoff must not be less than MinBackoff"))
+		}
+		cfg.Rejoin = &rejoin
+	}
+	if cfg.CircuitBreaker != nil {
+		breaker := *cfg.CircuitBreaker
+		if breaker.FailureRatio == 0 {
+			breaker.FailureRatio = 0.5
+		}
+		if breaker.MinRequests == 0 {
+			breaker.MinRequests = 5
+		}
+		if breaker.Window == 0 {
+			breaker.Window = 10 * time.Second
+		}
+		if breaker.Cooldown == 0 {
+			breaker.Cooldown = 30 * time.Second
+		}
+		if breaker.FailureRatio < 0 || breaker.FailureRatio > 1 {
+			errs = append(errs, fmt.Errorf("CircuitBreaker FailureRatio must be between 0 and 1"))
+		}
+		if breaker.MinRequests < 0 || breaker.Window < 0 || breaker.Cooldown < 0 {
+			errs = append(errs, fmt.Errorf("CircuitBreaker settings must not be negative"))
+		}
+		cfg.CircuitBreaker = &breaker
+	}
+	if cfg.RouteLogSampleRate < 0 || cfg.RouteLogSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("RouteLogSampleRate must be between 0 and 1"))
+	}
+	if cfg.RateLimits != nil {
+		limits := *cfg.RateLimits
+		if limits.PeerRate < 0 || limits.PeerBurst < 0 || limits.MaxConcurrentJoins < 0 || limits.MaxConcurrentHellos < 0 {
+			errs = append(errs, fmt.Errorf("RateLimits must not be negative"))
+		}
+		if limits.PeerBurst == 0 {
+			limits.PeerBurst = int(math.Ceil(limits.PeerRate))
+		}
+		if limits.PeerBurst == 0 {
+			limits.PeerBurst = 1
+		}
+		cfg.RateLimits = &limits
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	desc := api.Descriptor{
-		ID:   cfg.ID,
-		Addr: cfg.BroadcastAddr,
+	n := &Node{cfg: cfg, app: app, persisted: *persisted, events: newEvents(cfg.Log)}
+	// Save the ID right away so it's reused even if the node never joins.
+	if err := n.persist(); err != nil {
+		return nil, fmt.Errorf("failed to save state to DataDir: %w", err)
 	}
-	state := api.NewState(
-		desc,
-		cfg.NumLeaves,
-		cfg.NumNeighbors,
-		32,
-		16,
+	limits := newMembershipLimits(cfg.RateLimits)
+
+	var (
+		pool = connpool.New(cfg.MaxConns, append(dial[:len(dial):len(dial)], callOptions(cfg)...)...)
+		gen  = id.NewGenerator(cfg.IDSize)
 	)
+	pool.SetClock(cfg.Clock)
+	pool.SetMaxConnAge(cfg.MaxConnAge)
+	if cfg.Dialer != nil {
+		pool.SetDialer(connpool.DialFunc(cfg.Dialer))
+	}
+	if cfg.Registerer != nil {
+		// The pool is shared between virtual nodes, so its metrics aren't
+		// labeled by virtual node.
+		cfg.Registerer.MustRegister(pool)
+	}
+	if cb := cfg.CircuitBreaker; cb != nil {
+		pool.SetBreaker(&connpool.BreakerConfig{
+			FailureRatio: cb.FailureRatio,
+			MinCalls:     cb.MinRequests,
+			Window:       cb.Window,
+			Cooldown:     cb.Cooldown,
+		})
+	}
+
+	for i := 0; i < cfg.NumVirtualNodes; i++ {
+		vcfg := cfg
+		vcfg.RandSeed = cfg.RandSeed + int64(i)
+		app := app
+		if cfg.NumVirtualNodes > 1 {
+			vcfg.Log = log.With(cfg.Log, "vnode", i)
+			if cfg.Registerer != nil {
+				vcfg.Registerer = prometheus.WrapRegistererWith(prometheus.Labels{"vnode": fmt.Sprint(i)}, cfg.Registerer)
+			}
+			if i > 0 {
+				vcfg.ID = VirtualNodeID(gen, cfg.ID, i)
+			}
+			app = &vnodeApp{app: app, n: n, primary: i == 0}
+		}
+
+		desc := api.Descriptor{
+			ID:     vcfg.ID,
+			Addr:   cfg.BroadcastAddr,
+			Labels: api.EncodeLabels(cfg.Labels),
+		}
+		if cfg.DescriptorSigner != nil {
+			sig, err := cfg.DescriptorSigner.Sign(desc.SignedBytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to sign descriptor: %w", err)
+			}
+			desc.Signature = string(sig)
+		}
+
+		state := api.NewState(
+			desc,
+			cfg.NumLeaves,
+			cfg.NumNeighbors,
+			cfg.IDSize,
+			cfg.IDBase,
+		)
+		state.AdmitFunc = admitFunc(cfg)
+
+		// Seed the version from the start time so versions keep increasing
+		// across restarts. The clock may have gone backwards since the
+		// version saved in DataDir, so never seed below it.
+		now := cfg.Clock.Now()
+		state.Now = cfg.Clock.Now
+		state.Version, state.LastUpdated = uint64(now.UnixNano()), now
+		if state.Version <= persisted.Version {
+			state.Version = persisted.Version + 1
+		}
+
+		if cfg.Placement != nil {
+			state.PreferFunc = func(a, b api.Descriptor) bool {
+				return cfg.Placement.PreferPeer(peerFromDescriptor(desc), peerFromDescriptor(a), peerFromDescriptor(b))
+			}
+		}
+
+		ctrl := newController(vcfg, state, app, pool)
+		ctrl.limits = limits
+		n.vnodes = append(n.vnodes, ctrl)
+	}
+
+	n.controller = n.vnodes[0]
+	pool.StartReaper(reapConfig(cfg, n.knowsAddr))
+	if cfg.MaxConns == 0 {
+		pool.SetMaxConnsFunc(n.maxConns)
+	}
+	if cfg.Rejoin != nil {
+		n.controller.onIsolated = n.startRejoin
+	}
+	for _, ctrl := range n.vnodes {
+		ctrl.vnodes = n.vnodes
+		ctrl.events = n.events
+		ctrl.onPeersChanged = n.peersChanged
+		if len(n.vnodes) > 1 {
+			// Virtual nodes only own every key until the others join, so
+			// record the first ownership after joining without reporting it.
+			ctrl.ownershipKnown = false
+		}
+
+		// Timers are created before the loop starts so time advanced by a
+		// clock.Fake right after New is never missed.
+		go ctrl.run(ctrl.newJitterTimer(ctrl.gossipInterval), ctrl.newJitterTimer(ctrl.repairInterval))
+	}
+	return n, nil
+}
 
-	return &Node{
-		cfg:        cfg,
-		controller: newController(cfg.Log, state, app, dial),
-	}, nil
+// maxReapInterval is the maximum interval between checking for connections
+// to close.
+const maxReapInterval = time.Minute
+
+// reapConfig returns how to close unneeded connections to peers, keeping
+// connections to addresses for which keep returns true unless they're idle.
+func reapConfig(cfg Config, keep func(addr string) bool) connpool.ReapConfig {
+	rc := connpool.ReapConfig{
+		Interval: maxReapInterval,
+		Keep:     keep,
+	}
+	if cfg.ConnIdleTimeout > 0 {
+		rc.IdleTimeout = cfg.ConnIdleTimeout
+		if half := cfg.ConnIdleTimeout / 2; half < rc.Interval {
+			rc.Interval = half
+		}
+	}
+	return rc
+}
+
+// maxConns returns the maximum number of connections to peers, scaling with
+// the number of peers in the routing state of every virtual node.
+func (n *Node) maxConns() int {
+	addrs := make(map[string]struct{})
+	for _, vnode := range n.vnodes {
+		for _, p := range vnode.state.Peers(true) {
+			addrs[p.Addr] = struct{}{}
+		}
+	}
+	if max := 2 * len(addrs); max > DefaultMaxConns {
+		return max
+	}
+	return DefaultMaxConns
+}
+
+// knowsAddr returns true if addr is the address of the node or of a peer
+// in the routing state of any virtual node.
+func (n *Node) knowsAddr(addr string) bool {
+	if addr == n.cfg.BroadcastAddr {
+		return true
+	}
+	for _, vnode := range n.vnodes {
+		for _, p := range vnode.state.Peers(true) {
+			if p.Addr == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// admitFunc returns the function used to check peers before adding them to
+// the routing state. Returns nil if every peer is admitted.
+func admitFunc(cfg Config) func(d api.Descriptor) bool {
+	if cfg.AdmitPeer == nil && cfg.DescriptorSigner == nil {
+		return nil
+	}
+
+	return func(d api.Descriptor) bool {
+		if cfg.DescriptorSigner != nil {
+			err := cfg.DescriptorSigner.Verify(d.SignedBytes(), []byte(d.Signature))
+			if err != nil {
+				level.Debug(cfg.Log).Log("msg", "ignoring peer with invalid signature", "peer", d.Addr, "id", d.ID.String(), "err", err)
+				return false
+			}
+		}
+		return cfg.AdmitPeer == nil || cfg.AdmitPeer(peerFromDescriptor(d))
+	}
+}
+
+// callOptions returns the DialOptions to apply the TLS, dial, and call
+// options in cfg to every request sent to peers.
+func callOptions(cfg Config) []grpc.DialOption {
+	var dial []grpc.DialOption
+	if cfg.TLS != nil {
+		dial = append(dial, grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLS.Client)))
+	}
+	if cfg.DialTimeout > 0 || cfg.DialBackoff != nil {
+		params := grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: cfg.DialTimeout,
+		}
+		if params.MinConnectTimeout == 0 {
+			// Matches the gRPC default.
+			params.MinConnectTimeout = 20 * time.Second
+		}
+		if b := cfg.DialBackoff; b != nil {
+			params.Backoff.BaseDelay = b.MinBackoff
+			params.Backoff.MaxDelay = b.MaxBackoff
+		}
+		dial = append(dial, grpc.WithConnectParams(params))
+	}
+
+	var opts []grpc.CallOption
+	if cfg.Compressor != "" {
+		opts = append(opts, grpc.UseCompressor(cfg.Compressor))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxCallRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxCallSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	if len(opts) > 0 {
+		dial = append(dial, grpc.WithDefaultCallOptions(opts...))
+	}
+	return dial
 }
 
 // Register registers the cluster API to gRPC. Must be called before Join,
 // otherwise other nodes will be unable to connect to this node.
 func (n *Node) Register(s grpc.ServiceRegistrar) {
-	nodepb.RegisterNodeServer(s, nodepb.FromAPI(n.controller))
+	nodepb.RegisterNodeServer(s, nodepb.FromAPI(&vnodeMux{n: n}))
 }
 
 // Join joins the cluster. Calling this more than once will attempt to re-join
 // the cluster.
 func (n *Node) Join(ctx context.Context, addrs []string) error {
+	n.setSeeds(discovery.Static(addrs))
+	return n.join(ctx, addrs)
+}
+
+func (n *Node) setSeeds(d discovery.Discoverer) {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	n.seeds = d
+}
+
+func (n *Node) join(ctx context.Context, addrs []string) error {
+	if err := n.joinSeeds(ctx, addrs); err != nil {
+		return err
+	}
+
+	// The remaining virtual nodes join through the first virtual node, which
+	// is now a part of the cluster.
+	for _, vnode := range n.vnodes[1:] {
+		if err := vnode.Bootstrap(ctx, n.cfg.BroadcastAddr); err != nil {
+			return fmt.Errorf("failed to join virtual node %s: %w", vnode.state.Node.ID, err)
+		}
+	}
+
+	n.mut.Lock()
+	n.joined = true
+	n.mut.Unlock()
+	n.updateServingStatus()
+	return nil
+}
+
+// JoinDiscovered is like Join, but finds the addresses of nodes to join
+// using d. If d doesn't find any addresses, the node starts a new cluster.
+//
+// If d implements discovery.Registrar, BroadcastAddr is registered after
+// joining so other nodes may discover the node. The registration is removed
+// when the node is closed.
+func (n *Node) JoinDiscovered(ctx context.Context, d discovery.Discoverer) error {
+	addrs, err := d.Discover(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to discover nodes to join: %w", err)
+	}
+	level.Debug(n.cfg.Log).Log("msg", "discovered nodes to join", "count", len(addrs))
+
+	n.setSeeds(d)
+	if err := n.join(ctx, addrs); err != nil {
+		return err
+	}
+
+	r, ok := d.(discovery.Registrar)
+	if !ok {
+		return nil
+	}
+	if err := r.Register(ctx, n.cfg.BroadcastAddr); err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
+	}
+
+	n.mut.Lock()
+	n.registrar = r
+	n.mut.Unlock()
+	return nil
+}
+
+// joinSeeds joins the first virtual node to the cluster using addrs. If
+// leaves were saved in Config.DataDir, the node first tries to rejoin
+// through them directly. If none of addrs can be joined, the peers saved in
+// Config.DataDir are tried.
+func (n *Node) joinSeeds(ctx context.Context, addrs []string) error {
+	if leaves := n.cachedLeaves(); len(leaves) > 0 {
+		err := n.controller.WarmJoin(ctx, leaves)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		level.Info(n.cfg.Log).Log("msg", "could not rejoin through cached leaves, joining through seeds", "err", err)
+	}
+
 	var failed bool
 
 	for _, seed := range addrs {
@@ -115,6 +992,23 @@ func (n *Node) Join(ctx context.Context, addrs []string) error {
 		failed = true
 	}
 
+	// The seeds may be down after a restart, but the peers the node knew
+	// about before may still be around. Failing to join them isn't an
+	// error, since the whole cluster may have restarted.
+	for _, peer := range n.rememberedPeers(addrs) {
+		err := n.controller.Bootstrap(ctx, peer)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errSelfJoin) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		level.Warn(n.cfg.Log).Log("msg", "failed to join remembered peer", "addr", peer, "err", err)
+	}
+
 	if failed {
 		return fmt.Errorf("failed to join every peer from join addrs")
 	}
@@ -128,109 +1022,568 @@ func (n *Node) Join(ctx context.Context, addrs []string) error {
 //
 // This allows applications to implement special routing methods; e.g.,
 // batch routing.
+//
+// An error wrapping ErrNoRoute is returned if no peer could be found. This
+// may happen transiently while the state is updating.
 func (n *Node) NextPeer(key id.ID) (next Peer, self bool, err error) {
 	return n.controller.NextPeer(key)
 }
 
+// NextHops is like NextPeer, but returns up to count candidates for the next
+// hop for key, best first, so callers can fail over to another candidate
+// when a peer is unavailable without looking up the state again. The first
+// candidate is the peer NextPeer would return. Healthy peers are ranked
+// before unhealthy peers, which are only returned if there aren't enough
+// healthy candidates. Peers with the address of the local node refer to the
+// local node; at most one candidate is returned for each node.
+//
+// An error wrapping ErrNoRoute is returned if no peer could be found.
+func (n *Node) NextHops(key id.ID, count int) ([]Peer, error) {
+	return n.controller.NextHops(key, count)
+}
+
+// Replicas returns the nodes responsible for key: the owner of key, which is
+// the node requests for key are routed to, followed by the nodes succeeding
+// it on the ring. Up to Config.ReplicationFactor nodes will be returned. The
+// replicas are the same as the first nodes returned by ReplicaPeers.
+//
+// Replicas can only be determined for keys near the node. ErrKeyNotLocal is
+// returned if key is too far away from the node; route a request to the
+// owner of the key to determine its replicas instead.
+func (n *Node) Replicas(key id.ID) ([]Peer, error) {
+	return n.controller.Replicas(key)
+}
+
+// ReplicaPeers returns count distinct nodes for key: the owner of key
+// followed by the nodes that succeed it on the ring, in ring order. Unlike
+// Replicas, ReplicaPeers works for any key and any count. The leaves of the
+// node are used when possible, and the states of peers are fetched to walk
+// further around the ring or to find the owner of a key far away from the
+// node. Fewer than count nodes are returned if the cluster is smaller than
+// count. Only one virtual node is returned for each node.
+//
+// ReplicaPeers is intended for clients that read from or write to a quorum
+// of nodes. Client.InvokeQuorum implements this on top of ReplicaPeers.
+func (n *Node) ReplicaPeers(ctx context.Context, key id.ID, count int) ([]Peer, error) {
+	return n.controller.ReplicaPeers(ctx, key, count)
+}
+
+// Broadcast invokes the gRPC method on every node in the cluster, including
+// the local node, and returns the response of each node sorted by ID. The
+// method must be registered on the gRPC server of every node, and its
+// request and response must be protobuf messages. newReply must return a new
+// message to decode each response into.
+//
+// Broadcast is disseminated as a tree over the routing tables of nodes
+// rather than sent by the local node to every node directly. Nodes which
+// couldn't be reached are reported with an error; when a node fails to
+// forward the request, the nodes it would have forwarded to are missing from
+// the responses.
+func (n *Node) Broadcast(ctx context.Context, method string, req proto.Message, newReply func() proto.Message) ([]BroadcastResponse, error) {
+	return n.controller.broadcast(ctx, method, req, newReply)
+}
+
+// RingNeighbors returns the healthy peers immediately before and after the
+// node on the ring. ok will be false if the node has no healthy leaves. In
+// small clusters, predecessor and successor may be the same peer.
+func (n *Node) RingNeighbors() (predecessor, successor Peer, ok bool) {
+	pred, succ, ok := n.controller.state.RingNeighbors()
+	if !ok {
+		return Peer{}, Peer{}, false
+	}
+	return peerFromDescriptor(pred), peerFromDescriptor(succ), true
+}
+
+// OwnedRange returns the range of keys the node is currently closest to.
+// Requests for keys in the range are routed to the node. Implement
+// OwnershipWatcher to be informed when the range changes.
+//
+// If the node has multiple virtual nodes, only the range of the first
+// virtual node is returned. Use OwnedRanges to get the ranges of all virtual
+// nodes.
+func (n *Node) OwnedRange() KeyRange {
+	return n.controller.OwnedRange()
+}
+
+// OwnedRanges returns the ranges of keys owned by each virtual node of the
+// node.
+func (n *Node) OwnedRanges() []KeyRange {
+	ranges := make([]KeyRange, len(n.vnodes))
+	for i, vnode := range n.vnodes {
+		ranges[i] = vnode.OwnedRange()
+	}
+	return ranges
+}
+
+// State returns a snapshot of the routing state of the node, including its
+// leaves, routing table, and the health of its peers.
+//
+// If the node has multiple virtual nodes, only the state of the first virtual
+// node is returned. Use States to get the states of all virtual nodes.
+func (n *Node) State() State {
+	return stateSnapshot(n.controller.state)
+}
+
+// States returns a snapshot of the routing state of each virtual node of the
+// node.
+func (n *Node) States() []State {
+	states := make([]State, len(n.vnodes))
+	for i, vnode := range n.vnodes {
+		states[i] = stateSnapshot(vnode.state)
+	}
+	return states
+}
+
+// Generator returns an ID generator for keys that matches the IDSize and
+// IDBase of the node.
+func (n *Node) Generator() *id.BaseGenerator {
+	return id.NewBaseGenerator(n.cfg.IDSize, n.cfg.IDBase)
+}
+
+// Distance returns the distance between a and b in the ring, accounting for
+// wraparound. This is the same distance used by the node to determine which
+// node is closest to a key, and allows applications to make placement
+// decisions that agree with routing.
+func (n *Node) Distance(a, b id.ID) id.ID {
+	return api.Distance(a, b, n.controller.state.Size)
+}
+
+// IsSingleNode returns true if the node is the only node in the cluster,
+// either because no peers were joined or because all of its peers are
+// unhealthy. Returns false while the node is joining a cluster, since peers
+// may not have been discovered yet.
+func (n *Node) IsSingleNode() bool {
+	return n.controller.IsSingleNode()
+}
+
+// StateAge returns how long it has been since the node's routing state last
+// changed. Applications can use this to decide how much to trust a routing
+// decision: a recently changed state may still be converging after a join or
+// peer failure, while a state that hasn't changed in a long time may have
+// missed updates.
+func (n *Node) StateAge() time.Duration {
+	age := n.controller.state.Age()
+	for _, vnode := range n.vnodes[1:] {
+		if vnodeAge := vnode.state.Age(); vnodeAge < age {
+			age = vnodeAge
+		}
+	}
+	return age
+}
+
+// Census returns every node in the cluster, including the local node, sorted
+// by ID. Census discovers nodes by transitively fetching the state of peers
+// until no new nodes are found, and may be expensive for large clusters.
+// Nodes that could not be reached are not included. Each virtual node of a
+// peer is returned as a separate Peer.
+//
+// If ctx is canceled before the traversal completes, the nodes discovered so
+// far are returned along with the context's error.
+func (n *Node) Census(ctx context.Context) ([]Peer, error) {
+	return n.controller.Census(ctx)
+}
+
+// Members returns every node in the cluster, including the local node,
+// sorted by ID. Unlike Census, Members walks the ring through the successors
+// of each node, fetching the state of the furthest known successor until
+// the walk gets back to the local node, so every node is found even if the
+// routing tables of nodes are incomplete. Each virtual node of a peer is
+// returned as a separate Peer.
+//
+// The member list is cached for Config.MembersMaxStaleness, and concurrent
+// calls share the same walk. If a successor can't be reached, the walk
+// continues from the closer successors that can. An error is returned if
+// the walk couldn't get back to the local node, along with the nodes found
+// so far; failed walks aren't cached.
+func (n *Node) Members(ctx context.Context) ([]Peer, error) {
+	n.membersMut.Lock()
+	defer n.membersMut.Unlock()
+
+	now := n.cfg.Clock.Now()
+	if !n.membersAt.IsZero() && now.Sub(n.membersAt) < n.cfg.MembersMaxStaleness {
+		return append([]Peer(nil), n.members...), nil
+	}
+
+	found, err := n.controller.walkRing(ctx)
+	members := toPeers(found)
+	if err != nil {
+		return members, err
+	}
+
+	n.members, n.membersAt = members, now
+	return append([]Peer(nil), members...), nil
+}
+
+// Recover informs the node that peer is known to be healthy, such as after
+// it has recovered from a failure. Any unhealthy or dead status for peer is
+// cleared and peer is immediately checked. If the check succeeds, peer will
+// be mixed back into the node's state and health checked again as normal.
+//
+// Returns an error if peer could not be reached.
+func (n *Node) Recover(ctx context.Context, peer Peer) error {
+	for _, vnode := range n.vnodes {
+		if err := vnode.Recover(ctx, peer.descriptor()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close leaves the cluster.
 func (n *Node) Close() error {
-	return n.controller.Close()
+	var firstErr error
+
+	n.mut.Lock()
+	r := n.registrar
+	n.registrar = nil
+	if n.healthServer != nil {
+		n.healthServer.Shutdown()
+	}
+	n.mut.Unlock()
+
+	// Deregister first so joining nodes stop discovering the node.
+	if r != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		firstErr = r.Deregister(ctx)
+		cancel()
+	}
+
+	for _, vnode := range n.vnodes {
+		if err := vnode.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := n.controller.pool.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	// Save the final state version so it's seeded above after a restart.
+	if err := n.persist(); err != nil {
+		level.Warn(n.cfg.Log).Log("msg", "failed to save state to DataDir", "err", err)
+	}
+	if n.cfg.Registerer != nil {
+		n.cfg.Registerer.Unregister(n.controller.pool)
+	}
+	return firstErr
 }
 
 // controller implements health.Watcher and api.Node.
 type controller struct {
-	log log.Logger
+	log            log.Logger
+	registerer     prometheus.Registerer
+	metrics        *metrics
+	tracer         Tracer
+	helloTimeout   time.Duration
+	maxHops        int           // Maximum hops of routed requests and joins.
+	gossipInterval time.Duration // Interval between greeting leaves.
+	gossipTimeout  time.Duration // Timeout for greeting leaves.
+	repairInterval time.Duration // Interval between routing table repairs.
+	compressAbove  int           // Size above which Hellos are compressed.
+	cluster        string        // Name of the cluster.
+	clock          clock.Clock
+	rand           *rand.Rand
+	routeLogRate   float64 // Fraction of routing decisions to log.
 
-	health *health.Checker
+	// Oldest protocol version peers may use.
+	minProtocolVersion uint32
+
+	health healthChecker
 	pool   *connpool.Pool
 	app    Application
+	limits *membershipLimits // Shared between all controllers.
+
+	// Controllers for every virtual node of the local node, including this
+	// one. Shared between all controllers.
+	vnodes []*controller
 
-	// Used to stop run loop by Close.
-	quit chan struct{}
+	// Used to stop run loop by Close. stopped is closed once it exits.
+	quit    chan struct{}
+	stopped chan struct{}
 
 	joinMtx sync.Mutex   // Only allow one concurrent join.
-	joinRes chan error   // Channel for receiving result of join.
 	joining *atomic.Bool // Flag indicating joining.
+	single  *atomic.Bool // Flag indicating the node was last seen as alone.
+
+	leaving    *atomic.Bool // Flag indicating the node is leaving the cluster.
+	draining   *atomic.Bool // Flag indicating routed requests should be redirected.
+	isolated   *atomic.Bool // Flag indicating every leaf died.
+	onIsolated func()       // Invoked when the node becomes isolated.
+
+	onPeersChanged func()  // Invoked after the Application is told about new peers.
+	events         *events // Subscriptions to events. Shared between virtual nodes.
+
+	replicationFactor int
+	placement         PlacementPolicy
+	replicaMut        sync.Mutex       // Protects replicas.
+	replicas          []api.Descriptor // Last known replicas for the local node.
 
-	helloMut  sync.Mutex  // Protect hellos/nextHello from being changed concurrently.
-	hellos    []api.Hello // Hello messages when joining.
-	nextHello string      // Next expected hello.
+	ownershipMut   sync.Mutex    // Protects ownership fields.
+	ownership      api.Ownership // Last known ownership for the local node.
+	ownershipKnown bool          // Flag indicating ownership is set.
+
+	deltas *deltas        // Versions of state exchanged with peers.
+	gossip *gossipStreams // Streams to leaves. nil if disabled.
+
+	helloMut   sync.Mutex      // Protect join fields below from being changed concurrently.
+	hellos     []api.Hello     // Hello messages when joining.
+	nextHello  string          // Next expected hello.
+	joinCtx    context.Context // Context of the current join.
+	joinRes    chan error      // Channel for receiving result of the current join.
+	completing bool            // Flag indicating the current join is being completed.
 
 	state *api.State
 }
 
-func newController(log log.Logger, state *api.State, app Application, dial []grpc.DialOption) *controller {
-	// TODO(rfratto): change 250 to total # peers * 1/2
-	pool := connpool.New(250, dial...)
-
+func newController(cfg Config, state *api.State, app Application, pool *connpool.Pool) *controller {
 	ctrl := &controller{
-		log:  log,
+		log:            cfg.Log,
+		registerer:     cfg.Registerer,
+		metrics:        newMetrics(cfg.Registerer, state),
+		tracer:         cfg.Tracer,
+		helloTimeout:   cfg.HelloTimeout,
+		maxHops:        cfg.MaxHops,
+		gossipInterval: cfg.GossipInterval,
+		gossipTimeout:  cfg.GossipTimeout,
+		repairInterval: cfg.RepairInterval,
+		compressAbove:  cfg.StateCompressionThreshold,
+		cluster:        cfg.ClusterName,
+		clock:          cfg.Clock,
+		rand:           newRand(cfg.RandSeed),
+		routeLogRate:   cfg.RouteLogSampleRate,
+
+		minProtocolVersion: api.MinProtocolVersion,
+
+		replicationFactor: cfg.ReplicationFactor,
+		placement:         cfg.Placement,
+
 		pool: pool,
 		app:  app,
 
-		quit: make(chan struct{}),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
 
-		joinRes: make(chan error, 1),
 		joining: atomic.NewBool(false),
+		single:  atomic.NewBool(true),
+
+		leaving:  atomic.NewBool(false),
+		draining: atomic.NewBool(false),
+		isolated: atomic.NewBool(false),
+
+		ownership:      api.OwnershipOf(state),
+		ownershipKnown: true,
+
+		deltas: newDeltas(),
 
 		state: state,
 	}
 
-	ctrl.health = health.NewChecker(health.Config{
-		CheckFrequency: 5 * time.Second,
-		CheckTimeout:   250 * time.Millisecond,
-		MaxFailures:    3,
-		Log:            log,
-		Registerer:     prometheus.NewRegistry(),
-	}, pool, ctrl)
+	if !cfg.DisableGossipStreams {
+		ctrl.gossip = newGossipStreams()
+	}
+
+	if cfg.SWIM != nil {
+		ctrl.health = health.NewSWIM(health.SWIMConfig{
+			ProbeInterval:    cfg.SWIM.ProbeInterval,
+			ProbeTimeout:     cfg.SWIM.ProbeTimeout,
+			IndirectProbes:   cfg.SWIM.IndirectProbes,
+			SuspicionTimeout: cfg.SWIM.SuspicionTimeout,
+			CheckFunc:        toCheckFunc(cfg.HealthCheck),
+			Clock:            cfg.Clock,
+			Rand:             rand.New(rand.NewSource(ctrl.rand.Int63())),
+			Log:              cfg.Log,
+			Registerer:       cfg.Registerer,
+		}, pool, ctrl)
+	} else {
+		ctrl.health = health.NewChecker(health.Config{
+			CheckFrequency: 5 * time.Second,
+			CheckTimeout:   250 * time.Millisecond,
+			MaxFailures:    3,
+			CheckFunc:      toCheckFunc(cfg.HealthCheck),
+			Clock:          cfg.Clock,
+			Log:            cfg.Log,
+			Registerer:     cfg.Registerer,
+		}, pool, ctrl)
+	}
 
 	return ctrl
 }
 
-func (c *controller) run() {
-	helloTicker := time.NewTicker(time.Minute)
+// healthChecker tracks the health of peers. Implemented by health.Checker
+// and health.SWIM.
+type healthChecker interface {
+	CheckNodes(ds []api.Descriptor) error
+	SetHealth(d api.Descriptor, h api.Health) error
+	LastSeen(d api.Descriptor) (time.Time, bool)
+	Close() error
+}
+
+// run greets leaves and repairs the routing table every time hello and
+// repair fire until Close is called. A nil repair disables repairs.
+func (c *controller) run(hello, repair clock.Timer) {
+	defer close(c.stopped)
+	defer hello.Stop()
+
+	// A nil channel blocks forever, disabling repairs.
+	var repairC <-chan time.Time
+	if repair != nil {
+		defer repair.Stop()
+		repairC = repair.C()
+	}
 
 	for {
 		select {
 		case <-c.quit:
 			return
-		case <-helloTicker.C:
+		case <-hello.C():
 			c.greetLeaves()
+			hello.Reset(c.jitter(c.gossipInterval))
+		case <-repairC:
+			c.repairRoutes()
+			repair.Reset(c.jitter(c.repairInterval))
 		}
 	}
 }
 
+// newJitterTimer returns a timer firing after d adjusted by jitter, or nil
+// if d isn't positive.
+func (c *controller) newJitterTimer(d time.Duration) clock.Timer {
+	if d <= 0 {
+		return nil
+	}
+	return c.clock.NewTimer(c.jitter(d))
+}
+
+// jitter returns d randomly adjusted by up to 25% in either direction.
+func (c *controller) jitter(d time.Duration) time.Duration {
+	spread := int64(d / 2)
+	if spread <= 0 {
+		return d
+	}
+	return d - time.Duration(spread/2) + time.Duration(c.rand.Int63n(spread))
+}
+
 func (c *controller) greetLeaves() {
 	level.Info(c.log).Log("msg", "pinging all leaves")
 	defer level.Info(c.log).Log("msg", "done pinging leaves")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.gossipTimeout)
 	defer cancel()
 
-	state := c.state.Clone()
+	var (
+		state  = c.state.Clone()
+		leaves = c.state.Leaves(false)
+	)
 
-	for _, l := range c.state.Leaves(false) {
-		cc, err := c.pool.Get(l.Addr)
-		if err != nil {
+	// Streams to peers that are no longer healthy leaves aren't needed
+	// anymore.
+	c.closeGossipStreams(leaves)
+
+	for _, l := range leaves {
+		if err := c.greetLeaf(ctx, l, state); err != nil {
 			level.Warn(c.log).Log("msg", "pinging leaf failed", "leaf", l.Addr, "err", err)
 			if err := c.health.SetHealth(l, api.Unhealthy); err != nil {
 				level.Warn(c.log).Log("msg", "could not update health of leaf", "leaf", l.Addr, "err", err)
 			}
-			continue
 		}
+	}
+}
 
-		cli := nodepb.ToAPI(nodepb.NewNodeClient(cc))
-		err = cli.NodeHello(ctx, api.Hello{
-			Initiator: state.Node,
-			State:     state,
-		})
-		if err != nil {
-			level.Warn(c.log).Log("msg", "pinging leaf failed", "leaf", l.Addr, "err", err)
-			if err := c.health.SetHealth(l, api.Unhealthy); err != nil {
-				level.Warn(c.log).Log("msg", "could not update health of leaf", "leaf", l.Addr, "err", err)
-			}
+func (c *controller) IsSingleNode() bool {
+	return !c.joining.Load() && len(c.remoteLeaves()) == 0
+}
+
+// remoteLeaves returns the healthy leaves that aren't virtual nodes of the
+// local node.
+func (c *controller) remoteLeaves() []api.Descriptor {
+	var res []api.Descriptor
+	for _, l := range c.state.Leaves(false) {
+		if !c.isLocal(l) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// nodeClient returns an api.Node for sending requests to the peer over cc.
+func (c *controller) nodeClient(cc *grpc.ClientConn) api.Node {
+	return nodepb.ToAPI(nodepb.NewNodeClient(cc), nodepb.CompressAbove(c.compressAbove))
+}
+
+// isLocal returns true if d is a virtual node of the local node.
+func (c *controller) isLocal(d api.Descriptor) bool {
+	return d.Addr == c.state.Node.Addr
+}
+
+// routeState returns the state of the local virtual node closest to key,
+// which knows the most about the nodes around key.
+func (c *controller) routeState(key id.ID) *api.State {
+	closest := c
+	for _, vnode := range c.vnodes {
+		if api.Closer(vnode.state.Node.ID, closest.state.Node.ID, key, c.state.Size) {
+			closest = vnode
 		}
 	}
+	return closest.state
+}
+
+// checkSingleNode informs the Application when the node transitions between
+// being alone and having peers.
+func (c *controller) checkSingleNode() {
+	if c.joining.Load() {
+		return
+	}
+
+	single := len(c.remoteLeaves()) == 0
+	if c.single.Swap(single) == single {
+		return
+	}
+
+	level.Info(c.log).Log("msg", "single-node status changed", "single", single)
+	if w, ok := c.app.(SingleNodeWatcher); ok {
+		w.SingleNodeChanged(single)
+	}
+	if !single {
+		c.setIsolated(false)
+	}
+}
+
+// checkIsolated marks the node as isolated if it has no healthy leaves left.
+// Called after a peer died.
+func (c *controller) checkIsolated() {
+	if c.joining.Load() || len(c.remoteLeaves()) > 0 {
+		return
+	}
+	c.setIsolated(true)
+}
+
+// peersChanged informs the Application that the leaves of the node changed.
+func (c *controller) peersChanged() {
+	c.app.PeersChanged(getPeers(c.state))
+	if w, ok := c.app.(TopologyWatcher); ok {
+		w.TopologyChanged(getTopology(c.state, c.isLocal))
+	}
+	if c.onPeersChanged != nil {
+		c.onPeersChanged()
+	}
+}
+
+// setIsolated informs the Application when the node becomes isolated or stops
+// being isolated.
+func (c *controller) setIsolated(isolated bool) {
+	if c.isolated.Swap(isolated) == isolated {
+		return
+	}
+
+	if isolated {
+		level.Warn(c.log).Log("msg", "every peer died, node is isolated")
+	} else {
+		level.Info(c.log).Log("msg", "node is no longer isolated")
+	}
+	if w, ok := c.app.(IsolationWatcher); ok {
+		w.IsolationChanged(isolated)
+	}
+	if isolated && c.onIsolated != nil {
+		c.onIsolated()
+	}
 }
 
 func (c *controller) GetState(ctx context.Context) (*api.State, error) {
@@ -238,27 +1591,67 @@ func (c *controller) GetState(ctx context.Context) (*api.State, error) {
 }
 
 func (c *controller) NextPeer(key id.ID) (next Peer, self bool, err error) {
-	hop, ok := api.NextHop(c.state, key)
+	hop, ok := api.NextHop(c.routeState(key), key)
 	if !ok {
-		err = fmt.Errorf("routing failure: unable to find any node able to accept key %s. THIS IS A BUG!", key.String())
+		err = fmt.Errorf("%w %s", ErrNoRoute, key)
 		return
 	}
 
-	self = (hop == c.state.Node)
-	next = Peer{ID: hop.ID, Addr: hop.Addr}
+	self = c.isLocal(hop)
+	next = peerFromDescriptor(hop)
 	return
 }
 
+func (c *controller) NextHops(key id.ID, count int) ([]Peer, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+
+	// Get every candidate, since candidates for virtual nodes of the same
+	// node are removed below.
+	s := c.routeState(key)
+	hops := api.NextHops(s, key, len(s.Peers(true))+1)
+	if len(hops) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoRoute, key)
+	}
+
+	var (
+		res  = make([]api.Descriptor, 0, count)
+		seen = make(map[string]struct{}, count)
+	)
+	for _, hop := range hops {
+		if len(res) == count {
+			break
+		}
+		if _, ok := seen[hop.Addr]; ok {
+			continue
+		}
+		seen[hop.Addr] = struct{}{}
+		res = append(res, hop)
+	}
+	return descriptorsToPeers(res), nil
+}
+
 func (c *controller) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
+	// Stop greeting leaves before saying goodbye so no new gossip streams
+	// are opened while leaving.
+	close(c.quit)
+	<-c.stopped
+
 	var firstErr error
 
 	firstErr = c.health.Close()
 
-	// Tell all healthy peers about us leaving.
+	// Tell all healthy peers about us leaving. Other virtual nodes of the
+	// local node are leaving too, so they don't need to be told.
 	for _, p := range c.state.Peers(false) {
+		if c.isLocal(p) {
+			continue
+		}
+
 		cc, err := c.pool.Get(p.Addr)
 		if err != nil {
 			level.Warn(c.log).Log("msg", "failed to inform peer of leaving", "peer", p.Addr, "err", err)
@@ -268,16 +1661,20 @@ func (c *controller) Close() error {
 			continue
 		}
 
-		cli := nodepb.ToAPI(nodepb.NewNodeClient(cc))
-		err = cli.NodeGoodbye(ctx, c.state.Node)
+		cli := c.nodeClient(cc)
+		err = cli.NodeGoodbye(withTarget(ctx, p), c.state.Node)
 		if err != nil {
 			level.Warn(c.log).Log("msg", "failed to inform peer of leaving", "peer", p.Addr, "err", err)
 			if firstErr == nil {
 				firstErr = err
 			}
+			continue
 		}
+		c.metrics.goodbyesSentTotal.Inc()
 	}
 
-	close(c.quit)
+	c.closeGossipStreams(nil)
+
+	c.metrics.Unregister(c.registerer)
 	return firstErr
 }
@@ -0,0 +1,163 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// censusMaxHops is the maximum number of hops away from the local node that
+// Census will traverse.
+const censusMaxHops = 64
+
+func (c *controller) Census(ctx context.Context) ([]Peer, error) {
+	var (
+		visited = map[api.Descriptor]struct{}{c.state.Node: {}}
+		found   = []api.Descriptor{c.state.Node}
+		next    = c.unvisited(visited, c.state)
+	)
+
+	for hop := 0; hop < censusMaxHops && len(next) > 0; hop++ {
+		if err := ctx.Err(); err != nil {
+			return toPeers(found), err
+		}
+
+		var (
+			wg     sync.WaitGroup
+			states = make([]*api.State, len(next))
+		)
+		for i, d := range next {
+			visited[d] = struct{}{}
+
+			wg.Add(1)
+			go func(i int, d api.Descriptor) {
+				defer wg.Done()
+
+				s, err := getPeerState(ctx, c.pool, d)
+				if err != nil {
+					level.Debug(c.log).Log("msg", "failed to get state from peer during census", "peer", d.Addr, "err", err)
+					return
+				}
+				states[i] = s
+			}(i, d)
+		}
+		wg.Wait()
+
+		next = nil
+		for _, s := range states {
+			if s == nil {
+				continue
+			}
+			found = append(found, s.Node)
+			next = append(next, c.unvisited(visited, s)...)
+		}
+		next = dedupeDescriptors(next)
+	}
+
+	return toPeers(found), ctx.Err()
+}
+
+// walkRing returns every node in the ring, starting from the local node and
+// walking clockwise through the successors of each node. The walk continues
+// from the furthest known successor, or from a closer successor if the
+// furthest can't be reached, until it gets back to the local node.
+func (c *controller) walkRing(ctx context.Context) ([]api.Descriptor, error) {
+	var (
+		start   = c.state.Node
+		seen    = map[api.Descriptor]struct{}{start: {}}
+		fetched = map[api.Descriptor]struct{}{start: {}}
+		found   = []api.Descriptor{start}
+		ring    = api.Clockwise(c.state, start.ID)
+	)
+	if len(ring) <= 1 {
+		// The node is alone.
+		return found, nil
+	}
+
+	for first := true; ; first = false {
+		for _, d := range ring {
+			if d == start && !first {
+				return found, nil
+			} else if _, ok := seen[d]; ok {
+				continue
+			}
+			seen[d] = struct{}{}
+			found = append(found, d)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+
+		var (
+			next    *api.State
+			lastErr = errors.New("no unvisited successors")
+		)
+		for i := len(ring) - 1; i >= 0 && next == nil; i-- {
+			d := ring[i]
+			if _, ok := fetched[d]; ok {
+				continue
+			}
+			fetched[d] = struct{}{}
+
+			s, err := c.peerState(ctx, d)
+			if err != nil {
+				level.Debug(c.log).Log("msg", "failed to get state from peer while walking ring", "peer", d.Addr, "err", err)
+				lastErr = err
+				continue
+			}
+			next = s
+		}
+		if next == nil {
+			return found, fmt.Errorf("failed to walk ring past %s: %w", ring[len(ring)-1].Addr, lastErr)
+		}
+		ring = api.Clockwise(next, next.Node.ID)
+	}
+}
+
+// unvisited returns the peers of s that aren't in visited.
+func (c *controller) unvisited(visited map[api.Descriptor]struct{}, s *api.State) []api.Descriptor {
+	var res []api.Descriptor
+	for _, p := range s.Peers(false) {
+		if _, ok := visited[p]; !ok {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func dedupeDescriptors(ds []api.Descriptor) []api.Descriptor {
+	seen := make(map[api.Descriptor]struct{}, len(ds))
+	res := ds[:0]
+	for _, d := range ds {
+		if _, ok := seen[d]; ok {
+			continue
+		}
+		seen[d] = struct{}{}
+		res = append(res, d)
+	}
+	return res
+}
+
+// toPeers converts ds into a list of peers sorted by ID.
+func toPeers(ds []api.Descriptor) []Peer {
+	peers := make([]Peer, 0, len(ds))
+	seen := make(map[api.Descriptor]struct{}, len(ds))
+	for _, d := range ds {
+		if _, ok := seen[d]; ok {
+			continue
+		}
+		seen[d] = struct{}{}
+		peers = append(peers, peerFromDescriptor(d))
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return id.Compare(peers[i].ID, peers[j].ID) < 0
+	})
+	return peers
+}
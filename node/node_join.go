@@ -3,9 +3,11 @@ package node
 import (
 	"context"
 	"errors"
-	"time"
+	"fmt"
+	"sort"
 
 	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
 	"github.com/rfratto/croissant/internal/api"
 	"github.com/rfratto/croissant/internal/nodepb"
 	"google.golang.org/grpc"
@@ -17,18 +19,28 @@ var errSelfJoin = errors.New("can't join self")
 
 // Bootstrap joins the cluster using seed node seed. Only one Bootstrap
 // call may be running concurrently.
-func (c *controller) Bootstrap(ctx context.Context, seed string) error {
+func (c *controller) Bootstrap(ctx context.Context, seed string) (err error) {
 	c.joinMtx.Lock()
 	defer c.joinMtx.Unlock()
 
 	c.joining.Store(true)
 	defer c.joining.Store(false)
 
+	c.metrics.joinsInitiatedTotal.Inc()
+	defer func() {
+		switch {
+		case err == nil:
+			c.metrics.joinsCompletedTotal.Inc()
+		case !errors.Is(err, errSelfJoin):
+			c.metrics.joinFailuresTotal.Inc()
+		}
+	}()
+
 	cc, err := c.pool.Get(seed)
 	if err != nil {
 		return err
 	}
-	cli := nodepb.ToAPI(nodepb.NewNodeClient(cc))
+	cli := c.nodeClient(cc)
 
 	// Get the nodes state first so we know what advertise address it's using.
 	s, err := cli.GetState(ctx)
@@ -42,32 +54,193 @@ func (c *controller) Bootstrap(ctx context.Context, seed string) error {
 		return errSelfJoin
 	}
 
+	res := make(chan error, 1)
+
 	c.helloMut.Lock()
+	c.hellos = nil
 	c.nextHello = s.Node.Addr
+	c.joinCtx = ctx
+	c.joinRes = res
+	c.completing = false
 	c.helloMut.Unlock()
 
 	// Now send it a join.
 	level.Info(c.log).Log("msg", "sending join to node", "addr", seed)
-	err = cli.Join(ctx, c.state.Clone().Node)
+	err = cli.Join(ctx, api.Join{
+		Joiner:          c.state.Clone().Node,
+		Cluster:         c.cluster,
+		ProtocolVersion: api.ProtocolVersion,
+	})
 	if err != nil {
+		c.resetJoin()
 		return err
 	}
 
 	// Wait for NodeHello to receive the finally hello in the chain, starting
 	// from seed.
 	select {
-	case err := <-c.joinRes:
+	case err := <-res:
+		c.resetJoin()
 		return err
 	case <-ctx.Done():
+		level.Warn(c.log).Log("msg", "join canceled", "err", ctx.Err())
+
+		// If the join was already being completed, wait for it to stop. It
+		// will abort shortly since it uses ctx.
+		if c.resetJoin() {
+			<-res
+		}
 		return ctx.Err()
 	}
 }
 
-func (c *controller) Join(ctx context.Context, joiner api.Descriptor) error {
+// resetJoin clears the state of the current join so that any further hellos
+// are ignored. Returns true if the join was being completed.
+func (c *controller) resetJoin() (completing bool) {
+	c.helloMut.Lock()
+	defer c.helloMut.Unlock()
+
+	completing = c.completing
+
+	c.hellos = nil
+	c.nextHello = ""
+	c.joinCtx = nil
+	c.joinRes = nil
+	c.completing = false
+	return completing
+}
+
+// WarmJoin rejoins the cluster through leaves, the leaves of the node
+// before it restarted, instead of routing a join from a seed. The current
+// state of each leaf is fetched and validated: leaves must still be
+// reachable at the same address with the same ID, and none of them may know
+// of a node closer to the local node than the leaves which isn't one of
+// leaves. The join is then completed as usual by sending the resulting state
+// to every peer.
+// Returns an error if the leaves are stale, in which case a full join should
+// be used instead.
+func (c *controller) WarmJoin(ctx context.Context, leaves []api.Descriptor) (err error) {
+	c.joinMtx.Lock()
+	defer c.joinMtx.Unlock()
+
+	c.joining.Store(true)
+	defer c.joining.Store(false)
+
+	c.metrics.joinsInitiatedTotal.Inc()
+	defer func() {
+		if err == nil {
+			c.metrics.joinsCompletedTotal.Inc()
+			c.metrics.warmJoinsTotal.Inc()
+		} else {
+			c.metrics.joinFailuresTotal.Inc()
+		}
+	}()
+
+	var (
+		self   = c.state.Node
+		hellos = make([]api.Hello, 0, len(leaves))
+		cached = make(map[id.ID]string, len(leaves)) // Addresses by ID.
+	)
+	for _, leaf := range leaves {
+		if leaf.Addr == self.Addr {
+			continue
+		}
+		cached[leaf.ID] = leaf.Addr
+
+		getCtx, cancel := context.WithTimeout(ctx, c.helloTimeout)
+		s, err := getPeerState(getCtx, c.pool, leaf)
+		cancel()
+		if err != nil {
+			level.Debug(c.log).Log("msg", "cached leaf is unavailable", "peer", leaf.Addr, "err", err)
+			continue
+		} else if s.Node.ID != leaf.ID {
+			level.Debug(c.log).Log("msg", "cached leaf changed ID", "peer", leaf.Addr, "id", leaf.ID.String(), "new_id", s.Node.ID.String())
+			continue
+		}
+
+		c.deltas.setReceived(s)
+		hellos = append(hellos, api.Hello{Initiator: s.Node, State: s})
+	}
+	if len(hellos) == 0 {
+		return fmt.Errorf("none of the %d cached leaves are available", len(leaves))
+	}
+
+	// Find the leaves we would have using the leaves of every cached leaf.
+	// If any of them are new nodes closer to us than the farthest cached leaf,
+	// they joined while we were gone, and a full join is needed to correctly
+	// take over keys from them.
+	var (
+		scratch  = api.NewState(self, c.state.Predecessors.Size+c.state.Successors.Size, c.state.Neighbors.Size, c.state.Size, c.state.Base)
+		farthest id.ID
+	)
+	for _, h := range hellos {
+		scratch.MixinLeaves(h.State)
+		if dist := api.Distance(h.Initiator.ID, self.ID, c.state.Size); id.Compare(dist, farthest) > 0 {
+			farthest = dist
+		}
+	}
+	for _, l := range scratch.Leaves(false) {
+		if addr, ok := cached[l.ID]; ok && addr == l.Addr {
+			continue
+		}
+		if dist := api.Distance(l.ID, self.ID, c.state.Size); id.Compare(dist, farthest) < 0 {
+			return fmt.Errorf("cached leaves are stale: found new leaf %s (%s)", l.Addr, l.ID)
+		}
+	}
+
+	// Calculate takes leaves from the final hello, so the closest leaf goes
+	// last.
+	sort.SliceStable(hellos, func(i, j int) bool {
+		return api.Closer(hellos[j].Initiator.ID, hellos[i].Initiator.ID, self.ID, c.state.Size)
+	})
+
+	level.Info(c.log).Log("msg", "rejoining through cached leaves", "leaves", len(hellos))
+	if err := c.completeJoin(ctx, hellos); err != nil {
+		return err
+	}
+
+	c.joining.Store(false)
+	c.peersChanged()
+	c.checkSingleNode()
+	c.checkReplicas()
+	c.checkOwnership()
+	return nil
+}
+
+func (c *controller) Join(ctx context.Context, j api.Join) error {
+	c.metrics.joinsHandledTotal.Inc()
+
+	joiner := j.Joiner
+	release, err := c.limits.acquire(joiner, methodJoin)
+	if err != nil {
+		c.metrics.limitedRequestsTotal.WithLabelValues(methodJoin).Inc()
+		level.Warn(c.log).Log("msg", "rejecting join over rate limit", "peer", joiner.Addr, "id", joiner.ID.String(), "err", err)
+		return err
+	}
+	defer release()
+
+	if err := c.negotiateVersion(joiner, j.ProtocolVersion); err != nil {
+		level.Warn(c.log).Log("msg", "rejecting join from peer using unsupported protocol version", "peer", joiner.Addr, "id", joiner.ID.String(), "version", j.ProtocolVersion)
+		return err
+	}
+	if err := c.checkCluster(j.Cluster); err != nil {
+		level.Warn(c.log).Log("msg", "rejecting join from peer in another cluster", "peer", joiner.Addr, "id", joiner.ID.String(), "cluster", j.Cluster)
+		return err
+	}
+
+	if c.leaving.Load() {
+		// Joiners would take ownership of keys from the node, which is
+		// handing off its keys to its current leaves.
+		return status.Errorf(codes.Unavailable, "node is leaving the cluster")
+	}
+
 	if joiner.Addr == "" {
 		return status.Errorf(codes.InvalidArgument, "no cluster address received")
 	} else if joiner == c.state.Node {
 		return status.Errorf(codes.InvalidArgument, "node can't join itself")
+	} else if c.state.AdmitFunc != nil && !c.state.AdmitFunc(joiner) {
+		level.Warn(c.log).Log("msg", "rejecting join from peer that was not admitted", "peer", joiner.Addr, "id", joiner.ID.String())
+		return status.Errorf(codes.PermissionDenied, "peer not admitted to cluster")
 	}
 
 	level.Info(c.log).Log("msg", "received join request", "peer", joiner.Addr, "id", joiner.ID.String())
@@ -87,6 +260,16 @@ Retry:
 		return status.Errorf(codes.InvalidArgument, "ID already in use")
 	}
 
+	// Check the route before saying hello, since the joiner would wait for a
+	// hello from next.
+	path := append(j.Path[:len(j.Path):len(j.Path)], state.Node)
+	if next != state.Node && next != joiner {
+		if err := checkRoute(nodePath(path), nodeName(next), c.maxHops); err != nil {
+			level.Warn(c.log).Log("msg", "rejecting join that can't be propagated", "peer", joiner.Addr, "err", err)
+			return err
+		}
+	}
+
 	hello := api.Hello{Initiator: state.Node, State: state}
 	if next != state.Node && next != joiner {
 		hello.Next = &next
@@ -98,10 +281,18 @@ Retry:
 		return err
 	}
 
-	cli := nodepb.ToAPI(nodepb.NewNodeClient(cc))
+	cli := c.nodeClient(cc)
 
-	helloCtx := nodepb.WithCallOptions(ctx, grpc.WaitForReady(true))
-	err = cli.NodeHello(helloCtx, hello)
+	// WaitForReady gives the joiner a chance to finish starting up, but
+	// bound it so an unreachable joiner doesn't consume the entire join. The
+	// final hello isn't bounded, since the joiner completes the join while
+	// handling it.
+	helloCtx, cancel := ctx, func() {}
+	if hello.Next != nil {
+		helloCtx, cancel = context.WithTimeout(ctx, c.helloTimeout)
+	}
+	err = c.sendHello(nodepb.WithCallOptions(withTarget(helloCtx, joiner), grpc.WaitForReady(true)), cli, joiner, hello)
+	cancel()
 	if err != nil {
 		level.Warn(c.log).Log("msg", "failed to say hello to joining peer", "peer", joiner.Addr, "err", err)
 		return err
@@ -125,8 +316,10 @@ Retry:
 		goto Retry
 	}
 
-	cli = nodepb.ToAPI(nodepb.NewNodeClient(cc))
-	err = cli.Join(ctx, joiner)
+	cli = c.nodeClient(cc)
+	fwd := j
+	fwd.Path = path
+	err = cli.Join(withTarget(ctx, next), fwd)
 	if s := status.Convert(err); s != nil && s.Code() == codes.Unavailable {
 		// If the call failed because the node was unavailble, taint it and try again.
 		if err := c.health.SetHealth(next, api.Unhealthy); err != nil {
@@ -142,7 +335,48 @@ Retry:
 	return err
 }
 
+// negotiateVersion negotiates the protocol version to use with peer, which
+// uses the protocol version v. Features not supported by the negotiated
+// version are disabled when communicating with peer. Returns an error if peer
+// uses an unsupported protocol version.
+func (c *controller) negotiateVersion(peer api.Descriptor, v uint32) error {
+	version, err := api.NegotiateVersion(c.minProtocolVersion, v)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	c.deltas.setSupported(peer, version >= api.ProtocolV2)
+	return nil
+}
+
+// checkCluster returns an error if cluster isn't the name of the local
+// node's cluster.
+func (c *controller) checkCluster(cluster string) error {
+	if cluster == c.cluster {
+		return nil
+	}
+	return status.Errorf(codes.FailedPrecondition, "cluster name mismatch: peer is in cluster %q, but local node is in cluster %q", cluster, c.cluster)
+}
+
 func (c *controller) NodeHello(ctx context.Context, h api.Hello) error {
+	c.metrics.hellosReceivedTotal.Inc()
+
+	release, err := c.limits.acquire(h.Initiator, methodHello)
+	if err != nil {
+		c.metrics.limitedRequestsTotal.WithLabelValues(methodHello).Inc()
+		level.Warn(c.log).Log("msg", "rejecting hello over rate limit", "peer", h.Initiator.Addr, "err", err)
+		return err
+	}
+	defer release()
+
+	if err := c.negotiateVersion(h.Initiator, h.ProtocolVersion); err != nil {
+		level.Warn(c.log).Log("msg", "rejecting hello from peer using unsupported protocol version", "peer", h.Initiator.Addr, "version", h.ProtocolVersion)
+		return err
+	}
+	if err := c.checkCluster(h.Cluster); err != nil {
+		level.Warn(c.log).Log("msg", "rejecting hello from peer in another cluster", "peer", h.Initiator.Addr, "cluster", h.Cluster)
+		return err
+	}
+
 	// TODO(rfratto): In the future, allow for lazily adding jobs to the health
 	// checker. This will allow us to only maintain a health check against a
 	// neighbors and only spin up extra health checks when routing happens to
@@ -153,11 +387,19 @@ func (c *controller) NodeHello(ctx context.Context, h api.Hello) error {
 
 	// Don't even consider the hello at all if their state was based off of an
 	// outdated version of ours.
-	if !h.StateAck.IsZero() && c.state.IsNewer(h.StateAck) {
-		level.Debug(c.log).Log("msg", "outdated ack", "received", h.StateAck, "expected", c.state.LastUpdated)
-		return api.ErrStateChanged{NewState: c.state.Clone()}
+	if h.StateAck != 0 && c.state.IsNewer(h.StateAck) {
+		level.Debug(c.log).Log("msg", "outdated ack", "received", h.StateAck)
+		return c.stateChangedError(h)
 	}
 
+	// Rebuild the full state if we were only sent what changed.
+	state, err := c.deltas.receive(h)
+	if err != nil {
+		return err
+	}
+	h.State, h.Delta = state, nil
+	c.prioritizeProbes(h.State)
+
 	level.Info(c.log).Log("msg", "got hello from peer", "peer", h.Initiator.Addr, "peer_id", h.Initiator.ID)
 
 	if c.joining.Load() {
@@ -167,8 +409,11 @@ func (c *controller) NodeHello(ctx context.Context, h api.Hello) error {
 	// We're not joining, just mixin the state.
 	_, _, newLeaves := c.state.MixinState(h.State)
 	if newLeaves {
-		c.app.PeersChanged(getPeers(c.state))
+		c.peersChanged()
 	}
+	c.checkSingleNode()
+	c.checkReplicas()
+	c.checkOwnership()
 	return nil
 }
 
@@ -181,6 +426,11 @@ func (c *controller) handleJoiningHello(ctx context.Context, h api.Hello) error
 	// Hellos from unexpected nodes should be ignored. However, it's possible
 	// that the previous node re-sent its hello after failing to propagate to
 	// Next.
+	if c.joinRes == nil || c.completing {
+		level.Info(c.log).Log("msg", "ignoring hello for join that is no longer in progress", "peer", h.Initiator.Addr)
+		return nil
+	}
+
 	if h.Initiator.Addr != c.nextHello {
 		var (
 			prev     *api.Hello
@@ -202,32 +452,107 @@ func (c *controller) handleJoiningHello(ctx context.Context, h api.Hello) error
 	}
 
 	if h.Next != nil {
-		// NOTE(rfratto): It's possible for a malicious node to build a cyclical
-		// hello and prevent us from ever joining. For now, we're assuming a trusted
-		// environment.
+		// Fail the join if the chain of hellos would loop or grow too long,
+		// since we would never receive the final hello.
+		path := make([]api.Descriptor, 0, len(c.hellos))
+		for _, prev := range c.hellos {
+			path = append(path, prev.Initiator)
+		}
+		if err := checkRoute(nodePath(path), nodeName(*h.Next), c.maxHops); err != nil {
+			level.Warn(c.log).Log("msg", "aborting join", "err", err)
+			c.completing = true
+			c.joinRes <- err
+			return err
+		}
+
 		c.nextHello = h.Next.Addr
 		return nil
 	}
 
 	level.Info(c.log).Log("msg", "completing cluster join")
 
-	var joinErr error
+	c.completing = true
+
+	// Stop completing the join if the Bootstrap call waiting for it gives up.
+	ctx, cancel := withJoinCancel(ctx, c.joinCtx)
+	defer cancel()
+
+	err := c.completeJoin(ctx, c.hellos)
+	if err == nil {
+		c.joining.Store(false)
+		c.peersChanged()
+		c.checkSingleNode()
+		c.checkReplicas()
+		c.checkOwnership()
+	}
+	c.joinRes <- err
+	return nil
+}
+
+// withJoinCancel returns a copy of ctx which is also canceled when joinCtx
+// is done.
+func withJoinCancel(ctx, joinCtx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-joinCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// changedState returns the new state of peer from scErr, which was returned
+// in response to acknowledging prev. If scErr only holds a delta that can't be
+// applied to prev, the full state is requested from the peer instead.
+func (c *controller) changedState(ctx context.Context, peer api.Descriptor, prev *api.State, scErr api.ErrStateChanged) (*api.State, error) {
+	if scErr.Delta == nil {
+		c.deltas.setReceived(scErr.NewState)
+		return scErr.NewState, nil
+	}
+
+	s, err := scErr.Delta.Apply(prev)
+	if err != nil {
+		level.Warn(c.log).Log("msg", "could not apply state delta, requesting full state", "peer", peer.Addr, "err", err)
+		s, err = getPeerState(ctx, c.pool, peer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.deltas.setReceived(s)
+	return s, nil
+}
+
+// completeJoin calculates the state from the set of hellos received while
+// joining and shares the state with every peer. completeJoin stops early if
+// ctx is canceled.
+func (c *controller) completeJoin(ctx context.Context, hellos []api.Hello) error {
 Join:
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Initialize our state based on all the Hellos.
-	c.state.Calculate(c.hellos)
+	c.state.Calculate(hellos)
 
 	// Tell every peer about our state.
 	sendState := c.state.Clone()
 	for _, p := range c.state.Peers(false) {
+		if err := ctx.Err(); err != nil {
+			level.Warn(c.log).Log("msg", "aborting join propagation", "err", err)
+			return err
+		}
+
 		// Check to see if we have state from this node. This allows us to
 		// inform it that its state has changed.
 		var (
-			ackID    time.Time
+			ackID    uint64
 			helloIdx = -1
 		)
-		for i, h := range c.hellos {
+		for i, h := range hellos {
 			if h.Initiator == p {
-				ackID = h.State.LastUpdated
+				ackID = h.State.Version
 				helloIdx = i
 				break
 			}
@@ -238,21 +563,29 @@ Join:
 		cc, err := c.pool.Get(p.Addr)
 		if err != nil {
 			level.Error(c.log).Log("msg", "failed to inform peer of join", "peer", p.Addr, "err", err)
-			joinErr = status.Errorf(codes.Aborted, "aboring join because communication with peer %s failed: %s", p.Addr, err)
-			break
+			return status.Errorf(codes.Aborted, "aboring join because communication with peer %s failed: %s", p.Addr, err)
 		}
 
-		cli := nodepb.ToAPI(nodepb.NewNodeClient(cc))
-		err = cli.NodeHello(ctx, api.Hello{
-			Initiator: sendState.Node,
-			State:     sendState,
-			StateAck:  ackID,
+		helloCtx, cancel := context.WithTimeout(ctx, c.helloTimeout)
+		cli := c.nodeClient(cc)
+		err = c.sendHello(withTarget(helloCtx, p), cli, p, api.Hello{
+			Initiator:   sendState.Node,
+			State:       sendState,
+			StateAck:    ackID,
+			AcceptDelta: true,
 		})
+		cancel()
 		if scErr := (api.ErrStateChanged{}); errors.As(err, &scErr) && helloIdx >= 0 {
 			level.Info(c.log).Log("msg", "peer state changed since join, restarting join", "peer", p.Addr)
+			c.metrics.joinRestartsTotal.Inc()
 			// Store the updated hello and restart from the top. If a bunch of nodes
 			// have started at once, we may have to do this a few times.
-			c.hellos[helloIdx].State = scErr.NewState
+			newState, err := c.changedState(ctx, p, hellos[helloIdx].State, scErr)
+			if err != nil {
+				level.Error(c.log).Log("msg", "failed to get changed state of peer", "peer", p.Addr, "err", err)
+				return status.Errorf(codes.Aborted, "aboring join because communication with peer %s failed: %s", p.Addr, err)
+			}
+			hellos[helloIdx].State = newState
 			goto Join
 		}
 
@@ -260,12 +593,12 @@ Join:
 		// join is fatal.
 		if err != nil {
 			level.Error(c.log).Log("msg", "failed to inform peer of join", "peer", p.Addr, "err", err)
-			joinErr = status.Errorf(codes.Aborted, "aboring join because communication with peer %s failed: %s", p.Addr, err)
-			break
+			return status.Errorf(codes.Aborted, "aboring join because communication with peer %s failed: %s", p.Addr, err)
 		}
 	}
 
-	c.joining.Store(false)
-	c.joinRes <- joinErr
+	// Start checking the health of our new peers. Otherwise, they'd only be
+	// checked once another Hello is received.
+	c.health.CheckNodes(c.state.Peers(true))
 	return nil
 }
@@ -2,6 +2,7 @@ package node
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
@@ -10,8 +11,75 @@ import (
 	"github.com/rfratto/croissant/internal/nodepb"
 )
 
+// Leave gracefully leaves the cluster. The node first stops accepting joins,
+// which would give the node ownership of new keys, and then invokes Handoff
+// if the Application implements HandoffHandler so data can be transferred to
+// the peers taking over the node's keys. Once Handoff returns or ctx is
+// canceled, the node leaves the cluster as if Close was called.
+//
+// If Handoff fails or ctx is canceled before it returns, the node still
+// leaves the cluster, but an error is returned.
+func (n *Node) Leave(ctx context.Context) error {
+	for _, vnode := range n.vnodes {
+		vnode.leaving.Store(true)
+	}
+	n.updateServingStatus()
+
+	var handoffErr error
+	if h, ok := n.app.(HandoffHandler); ok {
+		if changes := n.handoff(); len(changes) > 0 {
+			level.Info(n.cfg.Log).Log("msg", "handing off keys before leaving", "ranges", len(changes))
+
+			done := make(chan error, 1)
+			go func() { done <- h.Handoff(ctx, changes) }()
+
+			select {
+			case handoffErr = <-done:
+			case <-ctx.Done():
+				handoffErr = ctx.Err()
+			}
+			if handoffErr != nil {
+				level.Warn(n.cfg.Log).Log("msg", "handoff failed, leaving anyway", "err", handoffErr)
+			}
+		}
+	}
+
+	if err := n.Close(); err != nil {
+		return err
+	}
+	if handoffErr != nil {
+		return fmt.Errorf("left cluster, but handoff failed: %w", handoffErr)
+	}
+	return nil
+}
+
+// handoff returns the ranges of keys owned by every virtual node along with
+// the remote peers that will own them once the node leaves.
+func (n *Node) handoff() []OwnershipChange {
+	var changes []OwnershipChange
+	for _, vnode := range n.vnodes {
+		// The other virtual nodes are leaving too, so ignore them when
+		// determining the new owners.
+		s := vnode.state.Clone()
+		for _, l := range s.Leaves(false) {
+			if vnode.isLocal(l) {
+				s.SetHealth(l, api.Dead)
+			}
+		}
+
+		for _, c := range api.HandoffOf(s) {
+			changes = append(changes, OwnershipChange{
+				Range: KeyRange{Start: c.Range.Start, End: c.Range.End},
+				Peer:  peerFromDescriptor(c.Peer),
+			})
+		}
+	}
+	return changes
+}
+
 func (c *controller) NodeGoodbye(ctx context.Context, leaver api.Descriptor) error {
 	level.Info(c.log).Log("msg", "informed of node leaving, treating as dead", "node", leaver.Addr)
+	c.metrics.goodbyesReceivedTotal.Inc()
 	if err := c.health.SetHealth(leaver, api.Dead); err != nil {
 		level.Warn(c.log).Log("msg", "leaving node is not in set of peers", "node", leaver.Addr)
 	}
@@ -25,6 +93,10 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 
 	level.Info(c.log).Log("msg", "changing health of peer", "peer", d.Addr, "health", h)
 	c.state.SetHealth(d, h)
+	c.events.healthChanged(d, h)
+	defer c.checkOwnership()
+	defer c.checkReplicas()
+	defer c.checkSingleNode()
 
 	if h != api.Dead {
 		// Unless the node dies, there's nothing else to do here; Healthy restores
@@ -32,11 +104,15 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 		return
 	}
 
+	// Check for isolation once the dead node has been removed.
+	defer c.checkIsolated()
+
 	// Stop tracking the health of the node after we're done replacing it.
 	// If we don't do this, dead nodes will leak in the state table.
 	defer level.Info(c.log).Log("msg", "done replacing dead peer", "peer", d.Addr)
 	defer c.state.Untrack(d)
 	defer c.pool.Remove(d.Addr)
+	defer c.deltas.forget(d)
 
 	// Save the state so we can freely perform recovery without worrying
 	// about race conditions.
@@ -66,7 +142,7 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 			if saved.Statuses[pred] != api.Healthy {
 				continue
 			}
-			state, err := getPeerState(ctx, c.pool, pred.Addr)
+			state, err := getPeerState(ctx, c.pool, pred)
 			if err != nil {
 				level.Warn(c.log).Log("msg", "could not get state from peer candidate", "err", err)
 				c.health.SetHealth(pred, api.Unhealthy)
@@ -89,7 +165,7 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 			if saved.Statuses[succ] != api.Healthy {
 				continue
 			}
-			state, err := getPeerState(ctx, c.pool, succ.Addr)
+			state, err := getPeerState(ctx, c.pool, succ)
 			if err != nil {
 				level.Warn(c.log).Log("msg", "could not get state from peer candidate", "err", err)
 				c.health.SetHealth(succ, api.Unhealthy)
@@ -118,7 +194,7 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 					continue
 				}
 
-				state, err := getPeerState(ctx, c.pool, ent.Addr)
+				state, err := getPeerState(ctx, c.pool, *ent)
 				if err != nil {
 					level.Warn(c.log).Log("msg", "could not get state from healthy routing row", "err", err)
 					c.health.SetHealth(*ent, api.Unhealthy)
@@ -144,7 +220,7 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 				continue
 			}
 
-			peerState, err := getPeerState(ctx, c.pool, n.Addr)
+			peerState, err := getPeerState(ctx, c.pool, n)
 			if err != nil {
 				level.Warn(c.log).Log("msg", "could not get state from peer candidate", "err", err)
 				continue
@@ -162,15 +238,68 @@ func (c *controller) HealthChanged(d api.Descriptor, h api.Health) {
 
 	// After updating the state, refresh health checker jobs.
 	if isPredecessor || isSuccessor {
-		c.app.PeersChanged(getPeers(c.state))
+		c.peersChanged()
 	}
 	c.health.CheckNodes(c.state.Peers(true))
 }
 
-func getPeerState(ctx context.Context, p *connpool.Pool, addr string) (*api.State, error) {
-	cc, err := p.Get(addr)
+func (c *controller) Recover(ctx context.Context, d api.Descriptor) error {
+	if d == c.state.Node {
+		return fmt.Errorf("can't recover self")
+	}
+
+	level.Info(c.log).Log("msg", "attempting to recover peer", "peer", d.Addr)
+
+	// Dead peers are removed from the pool, so get a fresh connection and
+	// make sure it works before clearing the health.
+	cc, err := c.pool.GetReady(ctx, d.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to peer: %w", err)
+	}
+	cli := c.nodeClient(cc)
+
+	peerState, err := cli.GetState(withTarget(ctx, d))
+	if err != nil {
+		return fmt.Errorf("failed to check peer: %w", err)
+	} else if peerState.Node.ID != d.ID || peerState.Node.Addr != d.Addr {
+		return fmt.Errorf("peer at %s has ID %s, expected %s", d.Addr, peerState.Node.ID, d.ID)
+	}
+
+	// Use the descriptor from the peer, since the caller may not know its
+	// labels.
+	d = peerState.Node
+
+	// Reset the health check job if one exists. This will invoke
+	// HealthChanged in the background if the job thought the peer was
+	// unhealthy.
+	_ = c.health.SetHealth(d, api.Healthy)
+
+	// Clear the status locally so the peer can be mixed back in. Untrack
+	// is a no-op if the peer is still in the state.
+	c.state.SetHealth(d, api.Healthy)
+	c.state.Untrack(d)
+
+	_, _, newLeaves := c.state.MixinState(peerState)
+	if newLeaves {
+		c.peersChanged()
+	}
+	c.checkSingleNode()
+	c.checkReplicas()
+	c.checkOwnership()
+	c.health.CheckNodes(c.state.Peers(true))
+
+	// Let the peer know about us in case it dropped us while it was down.
+	state := c.state.Clone()
+	return c.sendHello(withTarget(ctx, d), cli, d, api.Hello{
+		Initiator: state.Node,
+		State:     state,
+	})
+}
+
+func getPeerState(ctx context.Context, p *connpool.Pool, d api.Descriptor) (*api.State, error) {
+	cc, err := p.Get(d.Addr)
 	if err != nil {
 		return nil, err
 	}
-	return nodepb.ToAPI(nodepb.NewNodeClient(cc)).GetState(ctx)
+	return nodepb.ToAPI(nodepb.NewNodeClient(cc)).GetState(withTarget(ctx, d))
 }
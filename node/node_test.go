@@ -0,0 +1,2028 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rfratto/croissant/clock"
+	"github.com/rfratto/croissant/discovery"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/nodepb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func TestNode_IsSingleNode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	app := &singleNodeApp{}
+	seedNode.controller.app = app
+
+	err := seedNode.Join(ctx, nil)
+	require.NoError(t, err)
+	require.True(t, seedNode.IsSingleNode())
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	err = peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr})
+	require.NoError(t, err)
+
+	require.False(t, peerNode.IsSingleNode())
+	require.False(t, seedNode.IsSingleNode())
+	require.Equal(t, []bool{false}, app.Changes())
+}
+
+type singleNodeApp struct {
+	noopApplication
+
+	mut     sync.Mutex
+	changes []bool
+}
+
+func (a *singleNodeApp) SingleNodeChanged(single bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.changes = append(a.changes, single)
+}
+
+func (a *singleNodeApp) Changes() []bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return append([]bool(nil), a.changes...)
+}
+
+func TestNode_JoinDiscovered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.JoinDiscovered(ctx, discovery.Static(nil)))
+	require.True(t, seed.IsSingleNode())
+
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	reg := &fakeRegistrar{Static: discovery.Static{seed.cfg.BroadcastAddr}}
+	require.NoError(t, peer.JoinDiscovered(ctx, reg))
+	require.False(t, seed.IsSingleNode())
+	require.False(t, peer.IsSingleNode())
+
+	// The peer should be registered until it closes.
+	require.Equal(t, peer.cfg.BroadcastAddr, reg.registered)
+	require.NoError(t, peer.Close())
+	require.Empty(t, reg.registered)
+}
+
+func TestNode_Rejoin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	fastSWIM := func(c *Config) {
+		c.SWIM = &SWIMConfig{
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     50 * time.Millisecond,
+			SuspicionTimeout: 500 * time.Millisecond,
+		}
+	}
+
+	seedSrv, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, fastSWIM)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		fastSWIM(c)
+		c.Rejoin = &RejoinConfig{MinBackoff: 50 * time.Millisecond, MaxBackoff: 200 * time.Millisecond}
+	})
+	app := &isolationApp{}
+	peer.controller.app = app
+
+	seeds := &switchDiscoverer{addrs: []string{seed.cfg.BroadcastAddr}}
+	require.NoError(t, peer.JoinDiscovered(ctx, seeds))
+	require.False(t, peer.IsSingleNode())
+
+	// Kill the seed without saying goodbye. The peer should become isolated
+	// and fail to rejoin until a new node is available.
+	seedSrv.Stop()
+	require.Eventually(t, func() bool {
+		return len(app.Changes()) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, []bool{true}, app.Changes())
+
+	_, other := makeTestNode(t, log.With(l, "node", "other"), nil)
+	require.NoError(t, other.Join(ctx, nil))
+	seeds.Set([]string{other.cfg.BroadcastAddr})
+
+	require.Eventually(t, func() bool {
+		return !peer.IsSingleNode() && !other.IsSingleNode()
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return len(app.Changes()) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []bool{true, false}, app.Changes())
+}
+
+func TestNode_Leave(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var nodes []*Node
+	for i := 0; i < 3; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	var (
+		leaver = nodes[2]
+		owned  = leaver.controller.OwnedRange()
+		app    = &handoffApp{n: leaver}
+	)
+	leaver.app = app
+	require.NoError(t, leaver.Leave(ctx))
+
+	// Joins must have been rejected during the handoff.
+	require.Equal(t, codes.Unavailable, status.Code(app.joinErr))
+
+	// Every key owned by the leaver should be handed off to one of the other
+	// nodes.
+	require.NotEmpty(t, app.changes)
+	for _, c := range app.changes {
+		require.False(t, c.Gained)
+		require.Contains(t, []string{nodes[0].cfg.BroadcastAddr, nodes[1].cfg.BroadcastAddr}, c.Peer.Addr)
+		require.True(t, owned.Contains(c.Range.Start))
+		require.True(t, owned.Contains(c.Range.End))
+	}
+	require.Equal(t, owned.Start, app.changes[0].Range.Start)
+	require.Equal(t, owned.End, app.changes[len(app.changes)-1].Range.End)
+
+	for _, n := range nodes[:2] {
+		require.Eventually(t, func() bool {
+			s := n.State()
+			for _, p := range append(s.Predecessors, s.Successors...) {
+				if p.Addr == leaver.cfg.BroadcastAddr {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+}
+
+type handoffApp struct {
+	noopApplication
+	n *Node
+
+	changes []OwnershipChange
+	joinErr error
+}
+
+func (a *handoffApp) Handoff(ctx context.Context, changes []OwnershipChange) error {
+	a.changes = changes
+	a.joinErr = a.n.controller.Join(ctx, api.Join{
+		Joiner: api.Descriptor{ID: id.ID{Low: 1}, Addr: "127.0.0.1:1"},
+	})
+	return nil
+}
+
+type isolationApp struct {
+	noopApplication
+
+	mut     sync.Mutex
+	changes []bool
+}
+
+func (a *isolationApp) IsolationChanged(isolated bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.changes = append(a.changes, isolated)
+}
+
+func (a *isolationApp) Changes() []bool {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return append([]bool(nil), a.changes...)
+}
+
+// switchDiscoverer is a discovery.Discoverer whose addresses may be changed.
+type switchDiscoverer struct {
+	mut   sync.Mutex
+	addrs []string
+}
+
+func (d *switchDiscoverer) Set(addrs []string) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	d.addrs = addrs
+}
+
+func (d *switchDiscoverer) Discover(_ context.Context) ([]string, error) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return append([]string(nil), d.addrs...), nil
+}
+
+type fakeRegistrar struct {
+	discovery.Static
+	registered string
+}
+
+func (r *fakeRegistrar) Register(_ context.Context, addr string) error {
+	r.registered = addr
+	return nil
+}
+
+func (r *fakeRegistrar) Deregister(_ context.Context) error {
+	r.registered = ""
+	return nil
+}
+
+func TestNode_Topology(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes []*Node
+		app   = &topologyApp{}
+	)
+	for i := 0; i < 4; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+		if i == 0 {
+			n.controller.app = app
+		}
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// Every role in the state should be reported with its position.
+	require.Eventually(t, func() bool {
+		var (
+			s      = nodes[0].State()
+			expect = map[PeerInfo]struct{}{}
+		)
+		for i, p := range s.Predecessors {
+			expect[PeerInfo{Peer: p, Role: RolePredecessor, Index: i}] = struct{}{}
+		}
+		for i, p := range s.Successors {
+			expect[PeerInfo{Peer: p, Role: RoleSuccessor, Index: i}] = struct{}{}
+		}
+
+		var leaves int
+		for _, info := range app.Topology() {
+			require.NotEqual(t, nodes[0].cfg.BroadcastAddr, info.Peer.Addr)
+			if info.Role != RolePredecessor && info.Role != RoleSuccessor {
+				continue
+			}
+			if _, ok := expect[info]; !ok {
+				return false
+			}
+			leaves++
+		}
+		return leaves == len(expect) && len(s.Successors) == 3
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+type topologyApp struct {
+	noopApplication
+
+	mut      sync.Mutex
+	topology []PeerInfo
+}
+
+func (a *topologyApp) TopologyChanged(peers []PeerInfo) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.topology = peers
+}
+
+func (a *topologyApp) Topology() []PeerInfo {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.topology
+}
+
+func TestNode_Census(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes  []*Node
+		expect []Peer
+	)
+	for i := 0; i < 5; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+
+		nodes = append(nodes, n)
+		expect = append(expect, Peer{ID: n.cfg.ID, Addr: n.cfg.BroadcastAddr})
+	}
+
+	for _, n := range nodes {
+		peers, err := n.Census(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, expect, peers)
+	}
+}
+
+func TestNode_State(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes []*Node
+		peers []Peer
+	)
+	for i := 0; i < 3; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+
+		nodes = append(nodes, n)
+		peers = append(peers, Peer{ID: n.cfg.ID, Addr: n.cfg.BroadcastAddr})
+	}
+
+	state := nodes[0].State()
+	require.Equal(t, peers[0], state.Node)
+	require.Equal(t, 32, state.Size)
+	require.Len(t, nodes[0].States(), 1)
+
+	// In a cluster of three nodes, every other node is both a predecessor and
+	// a successor.
+	require.ElementsMatch(t, peers[1:], state.Predecessors)
+	require.ElementsMatch(t, peers[1:], state.Successors)
+
+	pred, succ, ok := nodes[0].RingNeighbors()
+	require.True(t, ok)
+	require.Equal(t, pred, state.Predecessors[0])
+	require.Equal(t, succ, state.Successors[0])
+
+	require.Equal(t, "", DiffStates(state, state))
+
+	diverged := nodes[0].State()
+	diverged.Health = map[Peer]Health{peers[2]: Unhealthy}
+	expect := fmt.Sprintf("--- %[1]s (%[2]s)\n+++ %[1]s (%[2]s)\nHealth:\n  ~ %[3]s (%[4]s): Healthy != Unhealthy\n",
+		peers[0].ID, peers[0].Addr, peers[2].ID, peers[2].Addr)
+	require.Equal(t, expect, DiffStates(state, diverged))
+
+	// Modifying the snapshot must not change the node.
+	state.Predecessors[0] = Peer{}
+	state.Health[peers[1]] = Dead
+	require.Equal(t, pred, nodes[0].State().Predecessors[0])
+	require.NotContains(t, nodes[0].State().Health, peers[1])
+}
+
+func TestNode_Labels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var nodes []*Node
+	for _, zone := range []string{"us-east1-a", "us-east1-b"} {
+		zone := zone
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", zone), &Router{}, nil, func(c *Config) {
+			c.Labels = map[string]string{"zone": zone}
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// Each node should see the labels of the other.
+	for i, n := range nodes {
+		other := nodes[1-i]
+
+		pred, _, ok := n.RingNeighbors()
+		require.True(t, ok)
+		require.Equal(t, other.cfg.ID, pred.ID)
+		require.Equal(t, other.cfg.Labels, pred.Labels.Map())
+
+		zone, ok := pred.Labels.Get("zone")
+		require.True(t, ok)
+		require.Equal(t, other.cfg.Labels["zone"], zone)
+	}
+}
+
+func TestNode_Distance(t *testing.T) {
+	n, err := New(Config{ID: id.ID{Low: 1}, BroadcastAddr: "127.0.0.1:1", IDSize: 8}, noopApplication{})
+	require.NoError(t, err)
+	defer n.Close()
+
+	tt := []struct {
+		name   string
+		a, b   uint64
+		expect uint64
+	}{
+		{name: "same", a: 10, b: 10, expect: 0},
+		{name: "direct", a: 10, b: 30, expect: 20},
+		{name: "wraparound", a: 250, b: 5, expect: 11},
+		{name: "ends of ring", a: 0, b: 255, expect: 1},
+		{name: "opposite sides of ring", a: 0, b: 128, expect: 128},
+		{name: "wraparound shorter than direct", a: 100, b: 240, expect: 116},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				a = id.ID{Low: tc.a}
+				b = id.ID{Low: tc.b}
+			)
+			require.Equal(t, id.ID{Low: tc.expect}, n.Distance(a, b))
+			require.Equal(t, id.ID{Low: tc.expect}, n.Distance(b, a), "distance must be symmetric")
+		})
+	}
+}
+
+func TestNode_ClusterName(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	withCluster := func(name string) func(c *Config) {
+		return func(c *Config) { c.ClusterName = name }
+	}
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, withCluster("a"))
+	require.NoError(t, seed.Join(ctx, nil))
+
+	_, other := makeTestNodeWithConfig(t, log.With(l, "node", "other"), &Router{}, nil, withCluster("b"))
+	require.Error(t, other.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.True(t, seed.IsSingleNode())
+
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, withCluster("a"))
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.False(t, seed.IsSingleNode())
+
+	// Hellos from another cluster must be rejected as well.
+	err := seed.controller.NodeHello(ctx, api.Hello{
+		Initiator: other.controller.state.Node,
+		State:     other.controller.state.Clone(),
+		Cluster:   "b",
+	})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.NotContains(t, seed.controller.state.Peers(true), other.controller.state.Node)
+}
+
+func TestNode_Compression(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, err := New(Config{ID: id.ID{Low: 1}, BroadcastAddr: "127.0.0.1:0", Compressor: "missing"}, noopApplication{})
+	require.Error(t, err, "unregistered compressors should be rejected")
+
+	compress := func(c *Config) {
+		c.Compressor = "gzip"
+		c.StateCompressionThreshold = 1
+		c.MaxRecvMsgSize = 1 << 20
+	}
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, compress)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	// Nodes that don't compress must still be able to join nodes that do.
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		c.StateCompressionThreshold = -1
+	})
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	_, other := makeTestNodeWithConfig(t, log.With(l, "node", "other"), &Router{}, nil, compress)
+	require.NoError(t, other.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	for _, n := range []*Node{seed, peer, other} {
+		require.Len(t, n.controller.remoteLeaves(), 2)
+	}
+}
+
+func TestNode_ProtocolVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	var (
+		peerDesc = peer.controller.state.Node
+		hello    = api.Hello{Initiator: peerDesc, State: peer.controller.state.Clone()}
+	)
+
+	// Hellos from peers that predate protocol versions disable deltas.
+	require.NoError(t, seed.controller.NodeHello(ctx, hello))
+	require.Contains(t, seed.controller.deltas.noDelta, peerDesc.Addr)
+
+	hello.ProtocolVersion = api.ProtocolVersion
+	require.NoError(t, seed.controller.NodeHello(ctx, hello))
+	require.NotContains(t, seed.controller.deltas.noDelta, peerDesc.Addr)
+
+	// Peers using versions older than the minimum are rejected.
+	seed.controller.minProtocolVersion = api.ProtocolV2
+	hello.ProtocolVersion = api.ProtocolV1
+	err := seed.controller.NodeHello(ctx, hello)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	err = seed.controller.Join(ctx, api.Join{Joiner: peerDesc})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestNode_Replicas(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	app := &replicaApp{}
+
+	var nodes []*Node
+	for i := 0; i < 4; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+		n.controller.replicationFactor = 3
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		} else {
+			n.controller.app = app
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// All nodes know about each other, so they should agree on the replicas
+	// for any key.
+	key := id.NewGenerator(32).Get("some-key")
+
+	expect, err := nodes[0].Replicas(key)
+	require.NoError(t, err)
+	require.Len(t, expect, 3)
+
+	for _, n := range nodes {
+		actual, err := n.Replicas(key)
+		require.NoError(t, err)
+		require.Equal(t, expect, actual)
+	}
+
+	// The seed node should have been told about its own replicas.
+	seed := Peer{ID: nodes[0].cfg.ID, Addr: nodes[0].cfg.BroadcastAddr}
+	require.Eventually(t, func() bool {
+		last := app.Last()
+		return len(last) == 3 && last[0] == seed
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type replicaApp struct {
+	noopApplication
+
+	mut  sync.Mutex
+	last []Peer
+}
+
+func (a *replicaApp) ReplicasChanged(replicas []Peer) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.last = replicas
+}
+
+func (a *replicaApp) Last() []Peer {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.last
+}
+
+func TestNode_NextHops(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var nodes []*Node
+	for i := 0; i < 3; i++ {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.NumVirtualNodes = 2
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	key := nodes[1].cfg.ID
+	next, _, err := nodes[0].NextPeer(key)
+	require.NoError(t, err)
+
+	hops, err := nodes[0].NextHops(key, 10)
+	require.NoError(t, err)
+	require.Len(t, hops, 3, "every node should be a candidate exactly once")
+	require.Equal(t, next, hops[0])
+
+	hops, err = nodes[0].NextHops(key, 1)
+	require.NoError(t, err)
+	require.Equal(t, []Peer{next}, hops)
+}
+
+func TestNode_TraceRoute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// With two leaves, nodes[0] can only know nodes[2] from its routing
+	// table.
+	var nodes []*Node
+	for i := 1; i <= 4; i++ {
+		nodeID := id.ID{Low: uint64(i) << 28}
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.ID = nodeID
+			c.NumLeaves = 2
+			c.RepairInterval = -1
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// Forget about nodes[2] so the route for a key it owns goes through
+	// nodes[1], its predecessor.
+	var (
+		c    = nodes[0].controller
+		lost = nodes[2].controller.state.Node
+	)
+	c.state.SetHealth(lost, api.Unhealthy)
+	c.state.ReplaceRoute(lost, nil)
+	c.state.SetHealth(lost, api.Healthy)
+
+	key := id.ID{Low: 0x2FFFFFFF}
+	route, err := nodes[0].TraceRoute(ctx, key)
+	require.NoError(t, err)
+	require.Zero(t, route[0].Latency)
+
+	var addrs []string
+	for _, hop := range route {
+		addrs = append(addrs, hop.Peer.Addr)
+	}
+	require.Equal(t, []string{
+		nodes[0].cfg.BroadcastAddr,
+		nodes[1].cfg.BroadcastAddr,
+		nodes[2].cfg.BroadcastAddr,
+	}, addrs)
+
+	// Routes longer than the maximum number of hops fail.
+	_, err = c.TraceRoute(ctx, key, 2)
+	require.Equal(t, codes.Aborted, status.Code(err))
+}
+
+func TestNode_ReplicaPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// With only two leaves, ReplicaPeers must fetch the state of peers to
+	// walk around the ring.
+	var nodes []*Node
+	for i := 0; i < 6; i++ {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.NumLeaves = 2
+			c.ReplicationFactor = 2
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// Determine the expected order of nodes around the ring.
+	var ring []Peer
+	for _, n := range nodes {
+		ring = append(ring, Peer{ID: n.cfg.ID, Addr: n.cfg.BroadcastAddr})
+	}
+	sort.Slice(ring, func(i, j int) bool { return id.Compare(ring[i].ID, ring[j].ID) < 0 })
+
+	key := id.NewGenerator(32).Get("some-key")
+	owner := 0
+	for i, p := range ring {
+		if api.Closer(p.ID, ring[owner].ID, key, 32) {
+			owner = i
+		}
+	}
+	var expect []Peer
+	for i := 0; i < 4; i++ {
+		expect = append(expect, ring[(owner+i)%len(ring)])
+	}
+
+	require.Eventually(t, func() bool {
+		for _, n := range nodes {
+			actual, err := n.ReplicaPeers(ctx, key, 4)
+			if err != nil || !reflect.DeepEqual(expect, actual) {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// Asking for more nodes than exist returns every node.
+	all, err := nodes[0].ReplicaPeers(ctx, key, 10)
+	require.NoError(t, err)
+	require.Len(t, all, len(nodes))
+
+	// Replicas agrees with ReplicaPeers on the owner of key.
+	for _, n := range nodes {
+		if n.cfg.ID != ring[owner].ID {
+			continue
+		}
+		replicas, err := n.Replicas(key)
+		require.NoError(t, err)
+		require.Equal(t, expect[:2], replicas)
+	}
+}
+
+func TestNode_Members(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// With only two leaves, no node knows about every other node, so Members
+	// must walk around the ring. The first node never caches its members.
+	var nodes []*Node
+	addNode := func(i int) {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.NumLeaves = 2
+			if i == 0 {
+				c.MembersMaxStaleness = -1
+			}
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+	expect := func() []Peer {
+		var res []Peer
+		for _, n := range nodes {
+			res = append(res, Peer{ID: n.cfg.ID, Addr: n.cfg.BroadcastAddr})
+		}
+		sort.Slice(res, func(i, j int) bool { return id.Compare(res[i].ID, res[j].ID) < 0 })
+		return res
+	}
+
+	for i := 0; i < 6; i++ {
+		addNode(i)
+	}
+	require.Eventually(t, func() bool {
+		members, err := nodes[0].Members(ctx)
+		return err == nil && reflect.DeepEqual(expect(), members)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	cached, err := nodes[1].Members(ctx)
+	require.NoError(t, err)
+	require.Equal(t, expect(), cached)
+
+	// New nodes are only found once the cache is stale.
+	addNode(6)
+	require.Eventually(t, func() bool {
+		members, err := nodes[0].Members(ctx)
+		return err == nil && reflect.DeepEqual(expect(), members)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	members, err := nodes[1].Members(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cached, members)
+}
+
+func TestNode_VerifyRing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var nodes []*Node
+	for i := 0; i < 5; i++ {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.NumLeaves = 2
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	require.Eventually(t, func() bool {
+		report, err := nodes[0].VerifyRing(ctx)
+		return err == nil && len(report.Members) == len(nodes) && report.Consistent()
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestNode_Evict(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		servers []*grpc.Server
+		nodes   []*Node
+	)
+	for i := 0; i < 4; i++ {
+		srv, n := makeTestNode(t, log.With(l, "node", i), nil)
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+
+		servers = append(servers, srv)
+		nodes = append(nodes, n)
+	}
+
+	// Stop the last node without saying goodbye and evict it before health
+	// checks declare it dead. Evicting without labels must still work.
+	servers[3].Stop()
+	evicted := Peer{ID: nodes[3].cfg.ID, Addr: nodes[3].cfg.BroadcastAddr}
+	require.NoError(t, nodes[0].Evict(ctx, evicted))
+
+	known := func(s State) bool {
+		for _, p := range append(append(s.Predecessors, s.Successors...), s.Neighbors...) {
+			if p == evicted {
+				return true
+			}
+		}
+		for _, row := range s.Routing {
+			for _, p := range row {
+				if p != nil && *p == evicted {
+					return true
+				}
+			}
+		}
+		_, tracked := s.Health[evicted]
+		return tracked
+	}
+	require.Eventually(t, func() bool {
+		for _, n := range nodes[:3] {
+			if known(n.State()) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Error(t, nodes[0].Evict(ctx, Peer{ID: nodes[0].cfg.ID, Addr: nodes[0].cfg.BroadcastAddr}))
+}
+
+func TestNode_LifecycleMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+	seedState := seed.controller.state.Clone()
+
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	var (
+		sm = seed.controller.metrics
+		pm = peer.controller.metrics
+	)
+	require.Equal(t, 1.0, testutil.ToFloat64(pm.joinsInitiatedTotal))
+	require.Equal(t, 1.0, testutil.ToFloat64(pm.joinsCompletedTotal))
+	require.Zero(t, testutil.ToFloat64(pm.joinFailuresTotal))
+	require.Zero(t, testutil.ToFloat64(pm.joinRestartsTotal))
+	require.Equal(t, 1.0, testutil.ToFloat64(sm.joinsHandledTotal))
+	require.GreaterOrEqual(t, testutil.ToFloat64(pm.stateAgeSecs), 0.0)
+
+	// Joins rejected by the seed are failures.
+	_, other := makeTestNodeWithConfig(t, log.With(l, "node", "other"), &Router{}, nil, func(c *Config) {
+		c.ClusterName = "other"
+	})
+	require.Error(t, other.controller.Bootstrap(ctx, seed.cfg.BroadcastAddr))
+	om := other.controller.metrics
+	require.Equal(t, 1.0, testutil.ToFloat64(om.joinsInitiatedTotal))
+	require.Zero(t, testutil.ToFloat64(om.joinsCompletedTotal))
+	require.Equal(t, 1.0, testutil.ToFloat64(om.joinFailuresTotal))
+
+	// Completing a join with a hello holding an outdated state of the seed
+	// restarts the join, since the seed mixed in peer since then.
+	_, late := makeTestNode(t, log.With(l, "node", "late"), nil)
+	err := late.controller.completeJoin(ctx, []api.Hello{{Initiator: seedState.Node, State: seedState}})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(late.controller.metrics.joinRestartsTotal))
+
+	// Leaving says goodbye to both seed and late.
+	require.NoError(t, peer.Close())
+	require.Equal(t, 2.0, testutil.ToFloat64(pm.goodbyesSentTotal))
+	require.Equal(t, 1.0, testutil.ToFloat64(sm.goodbyesReceivedTotal))
+}
+
+func TestNode_Recover(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.False(t, seed.IsSingleNode())
+
+	// Declare the peer dead as if it failed its health checks, removing it
+	// from the state of the seed.
+	seed.controller.HealthChanged(peer.controller.state.Clone().Node, api.Dead)
+	require.True(t, seed.IsSingleNode())
+
+	// Labels aren't needed to recover a peer.
+	p := Peer{ID: peer.cfg.ID, Addr: peer.cfg.BroadcastAddr}
+	require.NoError(t, seed.Recover(ctx, p))
+	require.False(t, seed.IsSingleNode())
+
+	h, ok := seed.PeerHealth(p)
+	require.True(t, ok)
+	require.Equal(t, Healthy, h.Health)
+
+	// Recovering a node at the address of peer with a different ID must
+	// fail.
+	err := seed.Recover(ctx, Peer{ID: id.ID{Low: 1234}, Addr: peer.cfg.BroadcastAddr})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "has ID")
+
+	require.Error(t, seed.Recover(ctx, Peer{ID: seed.cfg.ID, Addr: seed.cfg.BroadcastAddr}))
+}
+
+func TestCheckRingView(t *testing.T) {
+	var ring []Peer
+	for i := 1; i <= 4; i++ {
+		ring = append(ring, Peer{ID: id.ID{Low: uint64(i)}, Addr: fmt.Sprintf("node-%d", i)})
+	}
+	var (
+		node  = ring[1].descriptor()
+		preds = []api.Descriptor{ring[0].descriptor()}
+		succs = []api.Descriptor{ring[2].descriptor()}
+	)
+
+	_, ok := checkRingView(ring, 1, api.RingView{
+		Node:         node,
+		Predecessors: preds,
+		Successors:   succs,
+		Checksum:     api.RingChecksum(node, preds, succs),
+	})
+	require.True(t, ok)
+
+	// A view that skips a successor and holds onto a node which left.
+	var (
+		left     = api.Descriptor{ID: id.ID{Low: 10}, Addr: "left"}
+		badSuccs = []api.Descriptor{ring[3].descriptor(), left}
+	)
+	d, ok := checkRingView(ring, 1, api.RingView{
+		Node:         node,
+		Predecessors: preds,
+		Successors:   badSuccs,
+		Checksum:     api.RingChecksum(node, preds, badSuccs),
+	})
+	require.False(t, ok)
+	require.Equal(t, []Peer{ring[2]}, d.Missing)
+	require.Equal(t, []Peer{peerFromDescriptor(left)}, d.Stale)
+}
+
+func TestNode_RepairRoutes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// Only the first node repairs its routing table, driven by fake. Hellos
+	// could also restore the lost entry, so they never happen during the
+	// test.
+	const repairInterval = time.Minute
+	fake := clock.NewFake(time.Unix(0, 0))
+
+	// IDs are chosen so every peer is in the first row of each routing table.
+	var nodes []*Node
+	for i := 1; i <= 3; i++ {
+		nodeID := id.ID{Low: uint64(i) << 28}
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.ID = nodeID
+			c.RepairInterval = -1
+			if i == 1 {
+				c.Clock = fake
+				c.RepairInterval = repairInterval
+				c.GossipInterval = 24 * time.Hour
+			}
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	var (
+		c    = nodes[0].controller
+		lost = nodes[2].controller.state.Node
+	)
+	row, col := c.state.RouteIndex(lost)
+	require.Equal(t, 0, row)
+	require.Eventually(t, func() bool {
+		return c.state.Clone().Routing[row][col] != nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Remove the entry from the routing table without marking it as dead.
+	c.state.SetHealth(lost, api.Unhealthy)
+	_, ok := c.state.ReplaceRoute(lost, nil)
+	require.True(t, ok)
+	c.state.SetHealth(lost, api.Healthy)
+	require.Nil(t, c.state.Clone().Routing[row][col])
+
+	// The only other entry is nodes[1], which knows about the lost entry.
+	// Advancing past the longest jittered interval always triggers a repair.
+	require.Eventually(t, func() bool {
+		fake.Advance(repairInterval * 5 / 4)
+		return c.state.Clone().Routing[row][col] != nil
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, &lost, c.state.Clone().Routing[row][col])
+}
+
+func TestNode_OwnedRange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	app := &ownershipApp{}
+
+	var nodes []*Node
+	for i := 0; i < 4; i++ {
+		_, n := makeTestNode(t, log.With(l, "node", i), nil)
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		} else {
+			n.controller.app = app
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+	}
+
+	// Every key should be owned by exactly one node, which is the node the
+	// key is routed to.
+	gen := id.NewGenerator(32)
+	for i := 0; i < 100; i++ {
+		key := gen.Get(fmt.Sprintf("key-%d", i))
+
+		next, _, err := nodes[0].NextPeer(key)
+		require.NoError(t, err)
+
+		var owners []Peer
+		for _, n := range nodes {
+			if n.OwnedRange().Contains(key) {
+				owners = append(owners, Peer{ID: n.cfg.ID, Addr: n.cfg.BroadcastAddr})
+			}
+		}
+		require.Equal(t, []Peer{next}, owners, "key %s", key)
+	}
+
+	// The seed node owned every key before other nodes joined, so it should
+	// have been told about losing keys.
+	require.Eventually(t, func() bool {
+		for _, c := range app.Changes() {
+			if !c.Gained {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+type ownershipApp struct {
+	noopApplication
+
+	mut     sync.Mutex
+	changes []OwnershipChange
+}
+
+func (a *ownershipApp) OwnershipChanged(changes []OwnershipChange) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.changes = append(a.changes, changes...)
+}
+
+func (a *ownershipApp) Changes() []OwnershipChange {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return append([]OwnershipChange(nil), a.changes...)
+}
+
+func TestNode_VirtualNodes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes  []*Node
+		byAddr = map[string]*Node{}
+	)
+	for i := 0; i < 3; i++ {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.NumVirtualNodes = 4
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+
+		nodes = append(nodes, n)
+		byAddr[n.cfg.BroadcastAddr] = n
+	}
+
+	for _, n := range nodes {
+		require.Len(t, n.OwnedRanges(), 4)
+		require.False(t, n.IsSingleNode())
+	}
+
+	// Every key should be routed to the node with the closest virtual node.
+	gen := id.NewGenerator(32)
+	for i := 0; i < 100; i++ {
+		key := gen.Get(fmt.Sprintf("key-%d", i))
+
+		var (
+			expect  *Node
+			closest id.ID
+		)
+		for _, n := range nodes {
+			for _, vnode := range n.vnodes {
+				vid := vnode.state.Node.ID
+				if expect == nil || api.Closer(vid, closest, key, 32) {
+					expect, closest = n, vid
+				}
+			}
+		}
+
+		cur := nodes[0]
+		for hops := 0; ; hops++ {
+			require.Less(t, hops, 10, "routing loop for key %s", key)
+
+			next, self, err := cur.NextPeer(key)
+			require.NoError(t, err)
+			if self {
+				break
+			}
+			cur = byAddr[next.Addr]
+		}
+		require.Equal(t, expect.cfg.BroadcastAddr, cur.cfg.BroadcastAddr, "key %s", key)
+
+		var owners int
+		for _, r := range cur.OwnedRanges() {
+			if r.Contains(key) {
+				owners++
+			}
+		}
+		require.Equal(t, 1, owners, "key %s", key)
+	}
+}
+
+func TestNode_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	sub := seed.Subscribe()
+	defer sub.Close()
+
+	// waitFor waits for events matching every matcher in any order, ignoring
+	// other events.
+	waitFor := func(matchers ...func(ev Event) bool) {
+		t.Helper()
+		for len(matchers) > 0 {
+			select {
+			case ev := <-sub.C:
+				for i, f := range matchers {
+					if f(ev) {
+						matchers = append(matchers[:i], matchers[i+1:]...)
+						break
+					}
+				}
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "timed out waiting for events")
+			}
+		}
+	}
+
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	peerAddr := peer.cfg.BroadcastAddr
+
+	waitFor(
+		func(ev Event) bool {
+			joined, ok := ev.(PeerJoined)
+			return ok && joined.Peer.Addr == peerAddr
+		},
+		func(ev Event) bool {
+			owned, ok := ev.(OwnershipChanged)
+			return ok && len(owned.Changes) > 0 && !owned.Changes[0].Gained
+		},
+		func(ev Event) bool {
+			s, ok := ev.(StateRecalculated)
+			return ok && len(s.State.Successors) == 1 && s.State.Successors[0].Addr == peerAddr
+		},
+	)
+
+	require.NoError(t, peer.Leave(ctx))
+	waitFor(
+		func(ev Event) bool {
+			hc, ok := ev.(PeerHealthChanged)
+			return ok && hc.Peer.Addr == peerAddr && hc.Health == Dead
+		},
+		func(ev Event) bool {
+			left, ok := ev.(PeerLeft)
+			return ok && left.Peer.Addr == peerAddr
+		},
+	)
+
+	// C is closed once the subscription is closed.
+	sub.Close()
+	for range sub.C {
+	}
+}
+
+func TestNode_PeerHealth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	fastSWIM := func(c *Config) {
+		c.SWIM = &SWIMConfig{
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     50 * time.Millisecond,
+			SuspicionTimeout: time.Minute,
+		}
+	}
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, fastSWIM)
+	require.NoError(t, seed.Join(ctx, nil))
+	peerSrv, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, fastSWIM)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	// Labels aren't needed to look up a peer.
+	p := Peer{ID: peer.cfg.ID, Addr: peer.cfg.BroadcastAddr}
+	require.Eventually(t, func() bool {
+		h, ok := seed.PeerHealth(p)
+		return ok && h.Health == Healthy && !h.LastSeen.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The peer stays suspected for SuspicionTimeout after failing probes,
+	// and remembers when it was last seen.
+	peerSrv.Stop()
+	require.Eventually(t, func() bool {
+		h, ok := seed.PeerHealth(p)
+		return ok && h.Health == Unhealthy && !h.LastSeen.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, ok := seed.PeerHealth(Peer{ID: id.ID{Low: 1}, Addr: "127.0.0.1:1"})
+	require.False(t, ok)
+}
+
+func TestNode_SWIM(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		servers []*grpc.Server
+		nodes   []*Node
+	)
+	for i := 0; i < 3; i++ {
+		srv, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, nil, func(c *Config) {
+			c.SWIM = &SWIMConfig{
+				ProbeInterval:    100 * time.Millisecond,
+				ProbeTimeout:     50 * time.Millisecond,
+				SuspicionTimeout: 500 * time.Millisecond,
+			}
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+
+		servers = append(servers, srv)
+		nodes = append(nodes, n)
+	}
+
+	// Stop the last node without saying goodbye. The other nodes should
+	// detect it as dead and remove it.
+	servers[2].Stop()
+	stopped := Peer{ID: nodes[2].cfg.ID, Addr: nodes[2].cfg.BroadcastAddr}
+
+	require.Eventually(t, func() bool {
+		for _, n := range nodes[:2] {
+			s := n.State()
+			for _, p := range append(s.Predecessors, s.Successors...) {
+				if p == stopped {
+					return false
+				}
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestJitter(t *testing.T) {
+	c := &controller{rand: newRand(1)}
+	for i := 0; i < 1000; i++ {
+		d := c.jitter(time.Minute)
+		require.GreaterOrEqual(t, int64(d), int64(45*time.Second))
+		require.Less(t, int64(d), int64(75*time.Second))
+	}
+	require.Equal(t, time.Duration(1), c.jitter(1))
+
+	// The same seed produces the same jitter.
+	other := &controller{rand: newRand(1)}
+	c.rand = newRand(1)
+	require.Equal(t, c.jitter(time.Minute), other.jitter(time.Minute))
+}
+
+func TestNode_GossipInterval(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		c.Clock = fake
+		c.GossipInterval = time.Minute
+		c.RepairInterval = -1
+	})
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	// Only greeting leaves opens gossip streams, so an open stream means the
+	// peer greeted the seed.
+	greeted := func() bool {
+		g := peer.controller.gossip
+		g.mut.Lock()
+		defer g.mut.Unlock()
+		return len(g.streams) > 0
+	}
+
+	// The first greeting is jittered to within 25% of the interval.
+	fake.Advance(45*time.Second - time.Millisecond)
+	require.Never(t, greeted, 100*time.Millisecond, 10*time.Millisecond)
+
+	fake.Advance(30*time.Second + time.Millisecond)
+	require.Eventually(t, greeted, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNode_JoinAuthorizer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		mut        sync.Mutex
+		authorized []id.ID
+	)
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 1 << 28}
+		c.JoinAuthorizer = func(_ context.Context, joiner Peer, info credentials.AuthInfo) error {
+			mut.Lock()
+			defer mut.Unlock()
+			authorized = append(authorized, joiner.ID)
+
+			if joiner.ID.Low == 3<<28 {
+				return fmt.Errorf("unknown node")
+			}
+			return nil
+		}
+	})
+	require.NoError(t, seed.Join(ctx, nil))
+
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 2 << 28}
+	})
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	_, rejected := makeTestNodeWithConfig(t, log.With(l, "node", "rejected"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 3 << 28}
+	})
+	err := rejected.controller.Bootstrap(ctx, seed.cfg.BroadcastAddr)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Without mTLS, the path of a join can't be trusted, so a join claiming
+	// to be propagated by peer must still be authorized.
+	cc, err := rejected.controller.pool.Get(seed.cfg.BroadcastAddr)
+	require.NoError(t, err)
+	err = rejected.controller.nodeClient(cc).Join(ctx, api.Join{
+		Joiner:          rejected.controller.state.Clone().Node,
+		ProtocolVersion: api.ProtocolVersion,
+		Path:            []api.Descriptor{peer.controller.state.Clone().Node},
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, []id.ID{{Low: 2 << 28}, {Low: 3 << 28}, {Low: 3 << 28}}, authorized)
+}
+
+func TestNode_DescriptorSigner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	makeNode := func(name string, nodeID uint64, key string) *Node {
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", name), &Router{}, nil, func(c *Config) {
+			c.ID = id.ID{Low: nodeID << 28}
+			c.DescriptorSigner = NewHMACSigner([]byte(key))
+		})
+		return n
+	}
+
+	seed := makeNode("seed", 1, "secret")
+	require.NoError(t, seed.Join(ctx, nil))
+	require.NotEmpty(t, seed.State().Node.sig)
+
+	peer := makeNode("peer", 2, "secret")
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.False(t, seed.IsSingleNode())
+
+	intruder := makeNode("intruder", 3, "wrong")
+	err := intruder.controller.Bootstrap(ctx, seed.cfg.BroadcastAddr)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Descriptors which were tampered with must not be admitted.
+	admit := seed.controller.state.AdmitFunc
+	desc := peer.controller.state.Node
+	require.True(t, admit(desc))
+
+	desc.Addr = "127.0.0.1:1"
+	require.False(t, admit(desc))
+
+	desc = peer.controller.state.Node
+	desc.Signature = ""
+	require.False(t, admit(desc))
+}
+
+func TestNode_RateLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.RateLimits = &RateLimitConfig{PeerRate: 0.001}
+	})
+	require.NoError(t, seed.Join(ctx, nil))
+
+	_, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	// Joining again immediately exceeds the peer's rate limit.
+	err := peer.controller.Bootstrap(ctx, seed.cfg.BroadcastAddr)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestNode_GossipStreams(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// The seed only greets its leaves when fake is advanced.
+	fake := clock.NewFake(time.Unix(0, 0))
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.Clock = fake
+		c.GossipInterval = time.Minute
+		c.RepairInterval = -1
+	})
+	require.NoError(t, seed.Join(ctx, nil))
+
+	peerSrv, peer := makeTestNode(t, log.With(l, "node", "peer"), nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	c := seed.controller
+	leaf := peer.controller.state.Node
+	require.Eventually(t, func() bool {
+		_, tracked := c.health.LastSeen(leaf)
+		return tracked
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// greet advances fake past the gossip interval and waits for the
+	// greeting and the health check it triggers to finish. The hello timer
+	// is waiting again once the greeting is done.
+	greet := func() {
+		waiters := fake.Waiters()
+		fake.Advance(75 * time.Second)
+		require.Eventually(t, func() bool {
+			seen, _ := c.health.LastSeen(leaf)
+			return fake.Waiters() == waiters && seen.Equal(fake.Now())
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// The first greeting opens a stream and sends the state. The state
+	// didn't change since, so the second greeting is a heartbeat.
+	greet()
+	greet()
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.heartbeatsSentTotal))
+	require.Len(t, c.state.Leaves(false), 1)
+
+	// Killing the peer breaks the stream, which marks the peer as unhealthy
+	// without waiting for the next greeting or health check, as fake isn't
+	// advanced anymore.
+	peerSrv.Stop()
+	require.Eventually(t, func() bool {
+		return len(c.state.Leaves(false)) == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.brokenStreamsTotal))
+}
+
+func TestNode_ConnIdleTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, func(c *Config) {
+		c.ConnIdleTimeout = 100 * time.Millisecond
+	})
+	require.NoError(t, seed.Join(ctx, nil))
+
+	// Connections to addresses that aren't peers of the node are closed once
+	// they're unused.
+	stray, err := seed.controller.pool.Get("127.0.0.1:1")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return stray.GetState() == connectivity.Shutdown
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNode_MaxConns(t *testing.T) {
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	t.Run("scales by default", func(t *testing.T) {
+		_, n := makeTestNode(t, l, nil)
+		require.Equal(t, DefaultMaxConns, n.maxConns())
+	})
+
+	t.Run("fixed", func(t *testing.T) {
+		_, n := makeTestNodeWithConfig(t, l, &Router{}, nil, func(c *Config) {
+			c.MaxConns = 1
+			c.DialTimeout = time.Second
+			c.DialBackoff = &DialBackoffConfig{MinBackoff: 10 * time.Millisecond}
+		})
+		require.Equal(t, 2*time.Minute, n.cfg.DialBackoff.MaxBackoff)
+
+		first, err := n.controller.pool.Get("127.0.0.1:1")
+		require.NoError(t, err)
+		_, err = n.controller.pool.Get("127.0.0.1:2")
+		require.NoError(t, err)
+		require.Equal(t, connectivity.Shutdown, first.GetState())
+	})
+
+	t.Run("invalid backoff", func(t *testing.T) {
+		_, err := New(Config{
+			ID:            id.ID{Low: 1 << 28},
+			BroadcastAddr: "127.0.0.1:1",
+			DialBackoff:   &DialBackoffConfig{MinBackoff: time.Minute, MaxBackoff: time.Second},
+		}, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestNode_Dialer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		mut    sync.Mutex
+		dialed = make(map[string]struct{})
+	)
+	dialer := func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		mut.Lock()
+		dialed[addr] = struct{}{}
+		mut.Unlock()
+		return grpc.Dial(addr, opts...)
+	}
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	_, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		c.Dialer = dialer
+	})
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Contains(t, dialed, seed.cfg.BroadcastAddr)
+}
+
+func TestNode_Clock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	start := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+
+	_, n := makeTestNodeWithConfig(t, l, &Router{}, nil, func(c *Config) {
+		c.Clock = fake
+		c.RandSeed = 1
+	})
+	require.NoError(t, n.Join(ctx, nil))
+
+	require.Equal(t, start, n.State().LastUpdated)
+	require.Zero(t, n.StateAge())
+
+	fake.Advance(time.Hour)
+	require.Equal(t, time.Hour, n.StateAge())
+}
+
+func TestNode_DataDir(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		l   = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+		dir = t.TempDir()
+	)
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	peerSrv, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, func(c *Config) {
+		c.DataDir = dir
+		c.Labels = map[string]string{"zone": "a"}
+	})
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	ps, err := loadPersisted(dir)
+	require.NoError(t, err)
+	require.Equal(t, peer.cfg.ID.String(), ps.ID)
+	require.Equal(t, []persistedPeer{{ID: seed.cfg.ID.String(), Addr: seed.cfg.BroadcastAddr}}, ps.Peers)
+
+	require.NoError(t, peer.Close())
+	peerSrv.Stop()
+
+	// Restart the peer without an ID. It should reuse its saved ID and
+	// labels, and rejoin through the seed even though the address it's
+	// told to join is down.
+	_, restarted := makeTestNodeWithConfig(t, log.With(l, "node", "restarted"), &Router{}, nil, func(c *Config) {
+		c.ID = id.Zero
+		c.DataDir = dir
+	})
+	require.Equal(t, peer.cfg.ID, restarted.cfg.ID)
+	require.Equal(t, map[string]string{"zone": "a"}, restarted.cfg.Labels)
+
+	require.NoError(t, restarted.Join(ctx, []string{"127.0.0.1:1"}))
+	require.False(t, restarted.IsSingleNode())
+
+	_, err = New(Config{ID: id.ID{Low: 1}, BroadcastAddr: "127.0.0.1:1", DataDir: dir}, noopApplication{})
+	require.Error(t, err, "IDs that don't match the saved ID should be rejected")
+}
+
+// TestNodeHello_StateAckClockSkew checks that acks are compared against the
+// state version rather than timestamps, so clock skew between nodes never
+// makes an ack look stale or fresh.
+func TestNode_HelloTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	const helloTimeout = 250 * time.Millisecond
+
+	_, a := makeTestNodeWithConfig(t, log.With(l, "node", "a"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 1 << 28}
+		c.HelloTimeout = helloTimeout
+	})
+	require.NoError(t, a.Join(ctx, nil))
+	_, b := makeTestNodeWithConfig(t, log.With(l, "node", "b"), &Router{}, nil, func(c *Config) {
+		c.ID = id.ID{Low: 2 << 28}
+	})
+	require.NoError(t, b.Join(ctx, []string{a.cfg.BroadcastAddr}))
+
+	t.Run("intermediate hello", func(t *testing.T) {
+		// The joiner is closest to b, so the hello from a is followed by
+		// another from b. Stall the hello until the sender gives up.
+		joiner := startFakeNode(t, id.ID{Low: 2<<28 + 1}, &fakeNode{
+			hello: func(ctx context.Context, _ api.Hello) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		})
+
+		start := time.Now()
+		err := a.controller.Join(ctx, api.Join{Joiner: joiner, ProtocolVersion: api.ProtocolVersion})
+		require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+		require.Less(t, int64(time.Since(start)), int64(4*helloTimeout))
+	})
+
+	t.Run("final hello", func(t *testing.T) {
+		// The joiner is closest to a, so the hello from a completes the join.
+		// Completing a join may take longer than helloTimeout.
+		joiner := startFakeNode(t, id.ID{Low: 1<<28 + 1}, &fakeNode{
+			hello: func(ctx context.Context, _ api.Hello) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(3 * helloTimeout):
+					return nil
+				}
+			},
+		})
+
+		err := a.controller.Join(ctx, api.Join{Joiner: joiner, ProtocolVersion: api.ProtocolVersion})
+		require.NoError(t, err)
+	})
+}
+
+func TestNode_CancelJoin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seed := makeTestNode(t, log.With(l, "node", "seed"), nil)
+	require.NoError(t, seed.Join(ctx, nil))
+	_, joiner := makeTestNode(t, log.With(l, "node", "joiner"), nil)
+
+	// Accept the join without propagating it, so the joiner waits for a
+	// hello that never arrives.
+	received := make(chan struct{})
+	blackhole := startFakeNode(t, id.ID{}, &fakeNode{
+		Node: seed.controller,
+		join: func(context.Context, api.Join) error {
+			close(received)
+			return nil
+		},
+	})
+
+	joinCtx, cancelJoin := context.WithCancel(ctx)
+	joinErr := make(chan error, 1)
+	go func() { joinErr <- joiner.controller.Bootstrap(joinCtx, blackhole.Addr) }()
+
+	// Give the joiner time to receive the response to the join so it's
+	// waiting for hellos when the join is canceled.
+	<-received
+	time.Sleep(100 * time.Millisecond)
+	cancelJoin()
+
+	select {
+	case err := <-joinErr:
+		// The join may still be canceled while the response to the join is
+		// being received on slow machines.
+		if !errors.Is(err, context.Canceled) {
+			require.Equal(t, codes.Canceled, status.Code(err))
+		}
+	case <-ctx.Done():
+		require.FailNow(t, "join wasn't aborted")
+	}
+
+	// A canceled join must not prevent joining again.
+	require.NoError(t, joiner.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.False(t, joiner.IsSingleNode())
+	require.False(t, seed.IsSingleNode())
+}
+
+// fakeNode implements api.Node, allowing individual methods to be
+// overridden. Calls to methods that aren't overridden are sent to Node,
+// which must be set if they are invoked.
+type fakeNode struct {
+	api.Node
+
+	join  func(ctx context.Context, j api.Join) error
+	hello func(ctx context.Context, h api.Hello) error
+}
+
+func (n *fakeNode) Join(ctx context.Context, j api.Join) error {
+	if n.join == nil {
+		return n.Node.Join(ctx, j)
+	}
+	return n.join(ctx, j)
+}
+
+func (n *fakeNode) NodeHello(ctx context.Context, h api.Hello) error {
+	if n.hello == nil {
+		return n.Node.NodeHello(ctx, h)
+	}
+	return n.hello(ctx, h)
+}
+
+// startFakeNode serves n on a random port and returns a descriptor for it
+// with the given ID.
+func startFakeNode(t *testing.T, nodeID id.ID, n api.Node) api.Descriptor {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	nodepb.RegisterNodeServer(srv, nodepb.FromAPI(n))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return api.Descriptor{ID: nodeID, Addr: lis.Addr().String()}
+}
+
+func TestNodeHello_StateAckClockSkew(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	clk := clock.NewFake(time.Unix(1_000_000, 0))
+	_, n := makeTestNodeWithConfig(t, log.NewNopLogger(), &Router{}, nil, func(c *Config) {
+		c.Clock = clk
+	})
+	require.NoError(t, n.Join(ctx, nil))
+
+	var (
+		ctrl = n.controller
+		peer = api.Descriptor{ID: id.ID{Low: ctrl.state.Node.ID.Low ^ 1}, Addr: "127.0.0.1:1"}
+	)
+	hello := func(ack uint64) error {
+		// The peer's clock is a day behind.
+		ps := api.NewState(peer, 8, 8, 32, 16)
+		ps.Now = func() time.Time { return clk.Now().Add(-24 * time.Hour) }
+		ps.Version = 1
+
+		return ctrl.NodeHello(ctx, api.Hello{
+			Initiator:       peer,
+			State:           ps,
+			StateAck:        ack,
+			ProtocolVersion: api.ProtocolVersion,
+		})
+	}
+
+	// Acking the current version is never outdated, even after the local
+	// clock jumps ahead.
+	ack := ctrl.state.CurrentVersion()
+	clk.Advance(time.Hour)
+	require.False(t, errors.As(hello(ack), &api.ErrStateChanged{}))
+
+	// Mixing in the peer changed the state, which outdates the ack even
+	// though the clock went backwards.
+	clk.Advance(-2 * time.Hour)
+	require.ErrorAs(t, hello(ack), &api.ErrStateChanged{})
+}
+
+func TestNode_DataDir_Version(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		clk = clock.NewFake(time.Unix(1000, 0))
+	)
+
+	n, err := New(Config{ID: id.ID{Low: 1}, BroadcastAddr: "127.0.0.1:1", DataDir: dir}, noopApplication{}, WithClock(clk))
+	require.NoError(t, err)
+	n.controller.state.SetHealth(api.Descriptor{ID: id.ID{Low: 2}, Addr: "127.0.0.1:2"}, api.Unhealthy)
+	prev := n.controller.state.CurrentVersion()
+	require.NoError(t, n.Close())
+
+	// Restart with a clock that went backwards. The version must keep
+	// increasing.
+	clk = clock.NewFake(time.Unix(1, 0))
+	restarted, err := New(Config{BroadcastAddr: "127.0.0.1:1", DataDir: dir}, noopApplication{}, WithClock(clk))
+	require.NoError(t, err)
+	defer restarted.Close()
+	require.True(t, restarted.controller.state.IsNewer(prev))
+}
+
+func TestNode_WarmJoin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		l   = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+		dir = t.TempDir()
+	)
+
+	withID := func(v uint64, dataDir string) func(c *Config) {
+		return func(c *Config) {
+			c.ID = id.ID{Low: v}
+			c.DataDir = dataDir
+		}
+	}
+
+	_, seed := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, nil, withID(100, ""))
+	require.NoError(t, seed.Join(ctx, nil))
+
+	peerSrv, peer := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, withID(1000, dir))
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.NoError(t, peer.Close())
+	peerSrv.Stop()
+
+	// The peer should rejoin through its cached leaves without any seeds.
+	peerSrv, peer = makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, withID(1000, dir))
+	require.NoError(t, peer.Join(ctx, nil))
+	require.False(t, peer.IsSingleNode())
+	require.Equal(t, float64(1), testutil.ToFloat64(peer.controller.metrics.warmJoinsTotal))
+	require.Len(t, seed.State().Successors, 1)
+	require.NoError(t, peer.Close())
+	peerSrv.Stop()
+
+	// A node closer to the peer joins while it's down, so its cached leaves
+	// are stale and it should do a full join instead.
+	_, other := makeTestNodeWithConfig(t, log.With(l, "node", "other"), &Router{}, nil, withID(990, ""))
+	require.NoError(t, other.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+
+	_, peer = makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, nil, withID(1000, dir))
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.Zero(t, testutil.ToFloat64(peer.controller.metrics.warmJoinsTotal))
+
+	var leaves []id.ID
+	for _, p := range peer.State().Predecessors {
+		leaves = append(leaves, p.ID)
+	}
+	require.Contains(t, leaves, other.cfg.ID)
+}
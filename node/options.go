@@ -0,0 +1,95 @@
+package node
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/clock"
+	"google.golang.org/grpc"
+)
+
+// Option configures a Node created by New. Options take precedence over the
+// fields of Config they set.
+type Option func(o *options)
+
+type options struct {
+	cfg  *Config
+	dial []grpc.DialOption
+}
+
+// WithDialOptions appends DialOptions used when communicating with a
+// cluster peer.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dial = append(o.dial, opts...)
+	}
+}
+
+// WithLogger sets the logger used by the node. Overrides Config.Log.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.cfg.Log = l }
+}
+
+// WithRegisterer sets the registerer for the node's metrics. Overrides
+// Config.Registerer.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(o *options) { o.cfg.Registerer = reg }
+}
+
+// WithTracer sets the Tracer used by the node. Overrides Config.Tracer.
+func WithTracer(t Tracer) Option {
+	return func(o *options) { o.cfg.Tracer = t }
+}
+
+// WithClock sets the clock used by the node. Overrides Config.Clock.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) { o.cfg.Clock = c }
+}
+
+// WithIDBits sets the number of bits in IDs used by the cluster. Overrides
+// Config.IDSize.
+func WithIDBits(bits int) Option {
+	return func(o *options) { o.cfg.IDSize = bits }
+}
+
+// WithPoolSize sets the maximum number of connections to peers kept open at
+// once. Overrides Config.MaxConns.
+func WithPoolSize(size int) Option {
+	return func(o *options) { o.cfg.MaxConns = size }
+}
+
+// WithRouteLogSampleRate sets the fraction of routing decisions logged at
+// debug level. Overrides Config.RouteLogSampleRate.
+func WithRouteLogSampleRate(rate float64) Option {
+	return func(o *options) { o.cfg.RouteLogSampleRate = rate }
+}
+
+// ConfigError is returned by New when the Config is invalid. It holds every
+// problem found with the Config.
+type ConfigError []error
+
+// Error implements error.
+func (e ConfigError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns every problem found with the Config, allowing errors.Is and
+// errors.As to match individual problems.
+func (e ConfigError) Unwrap() []error { return e }
+
+// Is returns true if any problem found with the Config matches target. Is
+// allows errors.Is to match individual problems on Go versions that don't
+// support Unwrap methods returning multiple errors.
+func (e ConfigError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rfratto/croissant/clock"
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestNew_Options(t *testing.T) {
+	var (
+		l   = log.NewNopLogger()
+		reg = prometheus.NewRegistry()
+		clk = clock.NewFake(time.Unix(0, 0))
+	)
+
+	n, err := New(Config{
+		ID:            id.ID{Low: 1},
+		BroadcastAddr: "127.0.0.1:1",
+		IDSize:        128,
+		MaxConns:      10,
+	}, noopApplication{},
+		WithDialOptions(grpc.WithInsecure()),
+		WithLogger(l),
+		WithRegisterer(reg),
+		WithClock(clk),
+		WithIDBits(16),
+		WithPoolSize(5),
+	)
+	require.NoError(t, err)
+	defer n.Close()
+
+	require.Equal(t, l, n.cfg.Log)
+	require.Equal(t, reg, n.cfg.Registerer)
+	require.Equal(t, clk, n.cfg.Clock)
+	require.Equal(t, 16, n.cfg.IDSize, "options should override Config")
+	require.Equal(t, 5, n.cfg.MaxConns, "options should override Config")
+	require.Equal(t, 16, n.Generator().Size())
+	require.Equal(t, n.cfg.IDBase, n.Generator().Base())
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, families, "metrics should be registered to the Registerer")
+}
+
+func TestNew_ConfigError(t *testing.T) {
+	_, err := New(Config{
+		NumLeaves: 3,
+		MaxHops:   -1,
+	}, noopApplication{}, WithIDBits(12))
+
+	var cerr ConfigError
+	require.ErrorAs(t, err, &cerr)
+	require.EqualError(t, err, "invalid config: ID must be set; BroadcastAddr must be set; "+
+		"MaxHops must not be negative; leaves must be divisible by 2; invalid IDSize 12")
+}
+
+func TestConfigError_Is(t *testing.T) {
+	var (
+		wrapped       = fmt.Errorf("reading DataDir: %w", os.ErrPermission)
+		err     error = ConfigError{errors.New("ID must be set"), wrapped}
+	)
+	require.ErrorIs(t, err, wrapped)
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.False(t, errors.Is(err, os.ErrNotExist))
+
+	var pathErr *os.PathError
+	require.False(t, errors.As(err, &pathErr))
+
+	err = ConfigError{&os.PathError{Op: "open", Path: "data", Err: os.ErrNotExist}}
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "data", pathErr.Path)
+}
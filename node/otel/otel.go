@@ -0,0 +1,104 @@
+// Package otel implements a node.Tracer backed by OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rfratto/croissant/node"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const instrumentationName = "github.com/rfratto/croissant/node"
+
+// Tracer is a node.Tracer which creates OpenTelemetry spans. Tracer
+// implements node.TracePropagator, so the spans of every node a request is
+// forwarded through belong to the same trace.
+type Tracer struct {
+	t    trace.Tracer
+	prop propagation.TextMapPropagator
+}
+
+// NewTracer creates a new Tracer which creates spans with tp. Trace context
+// is sent between nodes with prop. If prop is nil, the W3C Trace Context
+// format is used, which matches the default of otelgrpc's interceptors.
+func NewTracer(tp trace.TracerProvider, prop propagation.TextMapPropagator) *Tracer {
+	if prop == nil {
+		prop = propagation.TraceContext{}
+	}
+	return &Tracer{
+		t:    tp.Tracer(instrumentationName),
+		prop: prop,
+	}
+}
+
+// Start implements node.Tracer.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, node.Span) {
+	ctx, span := t.t.Start(ctx, name)
+	return ctx, otelSpan{span}
+}
+
+// Inject implements node.TracePropagator.
+func (t *Tracer) Inject(ctx context.Context, md metadata.MD) {
+	t.prop.Inject(ctx, metadataCarrier(md))
+}
+
+// Extract implements node.TracePropagator. ctx is returned unchanged if it
+// already holds a span, such as one started by otelgrpc's server
+// interceptors.
+func (t *Tracer) Extract(ctx context.Context, md metadata.MD) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	return t.prop.Extract(ctx, metadataCarrier(md))
+}
+
+type otelSpan struct{ s trace.Span }
+
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.s.SetAttributes(attribute.String(key, v))
+	case bool:
+		s.s.SetAttributes(attribute.Bool(key, v))
+	case int:
+		s.s.SetAttributes(attribute.Int(key, v))
+	default:
+		s.s.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
+
+// metadataCarrier adapts metadata.MD to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,108 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rfratto/croissant/croissanttest"
+	"github.com/rfratto/croissant/examples/kv/kvproto"
+	"github.com/rfratto/croissant/examples/kv/kvserver"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTracer_Propagation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var (
+		rec = tracetest.NewSpanRecorder()
+		tp  = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	)
+
+	c := croissanttest.New(t, croissanttest.Config{
+		NumNodes: 2,
+		Configure: func(i int, cfg *node.Config) {
+			cfg.Tracer = NewTracer(tp, nil)
+		},
+		Register: func(i int, s *grpc.Server) {
+			kvproto.RegisterKVServer(s, &kvserver.Func{
+				GetFunc: func(ctx context.Context, _ *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+					_, span := tp.Tracer("test").Start(ctx, "handler")
+					defer span.End()
+					return &kvproto.GetResponse{Value: fmt.Sprint(i)}, nil
+				},
+			})
+		},
+	})
+	c.WaitConverged(30 * time.Second)
+
+	// Send the request to node 0 with a key owned by node 1, so it's
+	// forwarded once.
+	key := c.Node(1).Config.ID
+	require.Equal(t, c.Node(1), c.Owner(key))
+
+	resp, err := kvproto.NewKVClient(c.Dial(0)).Get(node.WithClientKey(ctx, key), &kvproto.GetRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "1", resp.Value)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range rec.Ended() {
+		if s.Name() == "croissant.Client/Invoke" && s.Parent().IsRemote() {
+			// The owner routes the request to itself; ignore its Invoke span.
+			continue
+		}
+		if s.Name() == "croissant.Router/ForwardUnary" && s.Parent().IsRemote() {
+			spans["owner"] = s
+			continue
+		}
+		spans[s.Name()] = s
+	}
+
+	var (
+		invoke  = spans["croissant.Client/Invoke"]
+		owner   = spans["owner"]
+		handler = spans["handler"]
+	)
+	require.NotNil(t, invoke, "missing span for Invoke on node 0")
+	require.NotNil(t, owner, "missing span for ForwardUnary on node 1")
+	require.NotNil(t, handler, "missing span for handler")
+
+	traceID := invoke.SpanContext().TraceID()
+	require.Equal(t, traceID, owner.SpanContext().TraceID())
+	require.Equal(t, traceID, handler.SpanContext().TraceID())
+
+	require.Equal(t, invoke.SpanContext().SpanID(), owner.Parent().SpanID(),
+		"owner's span should be a child of the forwarding node's span")
+	require.Equal(t, owner.SpanContext().SpanID(), handler.Parent().SpanID(),
+		"handler should run in the context of the owner's span")
+}
+
+func TestTracer_Extract_ExistingSpan(t *testing.T) {
+	tracer := NewTracer(sdktrace.NewTracerProvider(), nil)
+
+	ctx, span := tracer.t.Start(context.Background(), "outer")
+	defer span.End()
+
+	md := metadata.MD{}
+	tracer.Inject(ctx, md)
+	require.NotEmpty(t, md.Get("traceparent"))
+
+	// Extracting with an existing span, such as one started by otelgrpc,
+	// keeps that span.
+	ctx2, inner := tracer.t.Start(context.Background(), "inner")
+	defer inner.End()
+	got := tracer.Extract(ctx2, md)
+	require.Equal(t, inner.SpanContext(), trace.SpanContextFromContext(got))
+
+	got = tracer.Extract(context.Background(), md)
+	require.Equal(t, span.SpanContext().TraceID(), trace.SpanContextFromContext(got).TraceID())
+	require.True(t, trace.SpanContextFromContext(got).IsRemote())
+}
@@ -0,0 +1,89 @@
+package node
+
+import (
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// KeyRange is an inclusive range of keys in the ring. If Start is bigger than
+// End, the range wraps around the end of the ring.
+type KeyRange struct {
+	Start, End id.ID
+}
+
+// Contains returns true if key is in r.
+func (r KeyRange) Contains(key id.ID) bool {
+	return api.Range{Start: r.Start, End: r.End}.Contains(key)
+}
+
+// OwnershipChange is a range of keys the node gained or lost ownership of.
+type OwnershipChange struct {
+	Range KeyRange
+
+	// Gained is true if the node now owns Range, and false if the node no
+	// longer owns Range.
+	Gained bool
+
+	// Peer is the peer that previously owned Range if Gained is true, or the
+	// peer that now owns Range if Gained is false. Peer is unset if Range
+	// was owned by more than one peer, such as when the node first joins a
+	// cluster or becomes the only node in the cluster.
+	Peer Peer
+}
+
+func (c *controller) OwnedRange() KeyRange {
+	o := api.OwnershipOf(c.state)
+	return KeyRange{Start: o.Range.Start, End: o.Range.End}
+}
+
+// checkOwnership informs the Application when the range of keys owned by the
+// local node changes.
+func (c *controller) checkOwnership() {
+	if c.joining.Load() {
+		return
+	}
+
+	// Hold the lock while informing the Application so it sees changes in
+	// order.
+	c.ownershipMut.Lock()
+	defer c.ownershipMut.Unlock()
+
+	// Every change to the state is followed by checking ownership.
+	c.events.stateRecalculated(c.state)
+
+	next := api.OwnershipOf(c.state)
+	if !c.ownershipKnown {
+		c.ownership, c.ownershipKnown = next, true
+		return
+	}
+
+	diff := api.DiffOwnership(c.state.Node.ID, c.ownership, next, c.state.Size)
+	c.ownership = next
+	if len(diff) == 0 {
+		return
+	}
+
+	level.Debug(c.log).Log("msg", "ownership changed", "start", next.Range.Start, "end", next.Range.End)
+	changes := make([]OwnershipChange, len(diff))
+	for i, d := range diff {
+		changes[i] = OwnershipChange{
+			Range:  KeyRange{Start: d.Range.Start, End: d.Range.End},
+			Gained: d.Gained,
+			Peer:   peerFromDescriptor(d.Peer),
+		}
+	}
+	if w, ok := c.app.(OwnershipWatcher); ok {
+		w.OwnershipChanged(changes)
+	}
+
+	// Like vnodeApp, ignore keys moving between virtual nodes of the local
+	// node.
+	var remote []OwnershipChange
+	for _, change := range changes {
+		if !c.isLocal(change.Peer.descriptor()) {
+			remote = append(remote, change)
+		}
+	}
+	c.events.ownershipChanged(remote)
+}
@@ -0,0 +1,210 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// persistFile is the name of the file in Config.DataDir holding the node's
+// persisted state.
+const persistFile = "node.json"
+
+// persistedState is the state of a node saved in Config.DataDir.
+type persistedState struct {
+	// ID is the base-10 ID of the node.
+	ID     string            `json:"id"`
+	Addr   string            `json:"addr"`
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Peers are the peers last known by the node, sorted by address.
+	Peers []persistedPeer `json:"peers,omitempty"`
+	// Leaves are the last known healthy leaves of the first virtual node,
+	// used to rejoin the cluster without a full join.
+	Leaves []persistedPeer `json:"leaves,omitempty"`
+
+	// Version is the highest state version of the node's virtual nodes when
+	// the state was saved. Restarted nodes seed their state versions above
+	// it, so versions keep increasing even if the clock went backwards.
+	Version uint64 `json:"version,omitempty"`
+}
+
+type persistedPeer struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+// loadPersisted loads the state saved in dir. Returns nil if no state has
+// been saved.
+func loadPersisted(dir string) (*persistedState, error) {
+	bb, err := ioutil.ReadFile(filepath.Join(dir, persistFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ps persistedState
+	if err := json.Unmarshal(bb, &ps); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", persistFile, err)
+	}
+	return &ps, nil
+}
+
+// applyPersisted loads the state saved in cfg.DataDir, using the saved ID
+// and labels when cfg doesn't set them. Returns an error if cfg.ID is set
+// and doesn't match the saved ID.
+func applyPersisted(cfg *Config) (*persistedState, error) {
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create DataDir: %w", err)
+	}
+	ps, err := loadPersisted(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state from DataDir: %w", err)
+	} else if ps == nil {
+		return &persistedState{}, nil
+	}
+
+	savedID, err := id.Parse(ps.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID saved in DataDir: %w", err)
+	}
+	if cfg.ID == id.Zero {
+		cfg.ID = savedID
+	} else if cfg.ID != savedID {
+		return nil, fmt.Errorf("ID %s does not match ID %s saved in DataDir", cfg.ID, savedID)
+	}
+	if cfg.Labels == nil {
+		cfg.Labels = ps.Labels
+	}
+	return ps, nil
+}
+
+// persist saves the node's ID, labels, peers, leaves, and state version to
+// Config.DataDir.
+// The previously saved peers and leaves are kept if the node doesn't know
+// about any, so isolated nodes can still rejoin through them.
+func (n *Node) persist() error {
+	if n.cfg.DataDir == "" {
+		return nil
+	}
+
+	var (
+		peers []persistedPeer
+		seen  = map[string]struct{}{n.cfg.BroadcastAddr: {}}
+	)
+	for _, vnode := range n.vnodes {
+		for _, p := range vnode.state.Peers(true) {
+			if _, ok := seen[p.Addr]; ok {
+				continue
+			}
+			seen[p.Addr] = struct{}{}
+			peers = append(peers, persistedPeer{ID: p.ID.String(), Addr: p.Addr})
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Addr < peers[j].Addr })
+
+	var version uint64
+	for _, vnode := range n.vnodes {
+		if v := vnode.state.CurrentVersion(); v > version {
+			version = v
+		}
+	}
+
+	var leaves []persistedPeer
+	if len(n.vnodes) > 0 {
+		for _, l := range n.controller.state.Leaves(false) {
+			leaves = append(leaves, persistedPeer{ID: l.ID.String(), Addr: l.Addr})
+		}
+	}
+
+	n.persistMut.Lock()
+	defer n.persistMut.Unlock()
+
+	if len(peers) == 0 {
+		peers = n.persisted.Peers
+	}
+	if len(leaves) == 0 {
+		leaves = n.persisted.Leaves
+	}
+	if version < n.persisted.Version {
+		version = n.persisted.Version
+	}
+	ps := persistedState{
+		ID:      n.cfg.ID.String(),
+		Addr:    n.cfg.BroadcastAddr,
+		Labels:  n.cfg.Labels,
+		Peers:   peers,
+		Leaves:  leaves,
+		Version: version,
+	}
+
+	bb, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash doesn't leave a partially
+	// written file behind.
+	path := filepath.Join(n.cfg.DataDir, persistFile)
+	if err := ioutil.WriteFile(path+".tmp", bb, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	n.persisted = ps
+	return nil
+}
+
+// persistPeers is invoked when the peers of a virtual node change.
+func (n *Node) persistPeers() {
+	if err := n.persist(); err != nil {
+		level.Warn(n.cfg.Log).Log("msg", "failed to save peers to DataDir", "err", err)
+	}
+}
+
+// rememberedPeers returns the addresses of the peers saved in
+// Config.DataDir, excluding addresses in exclude.
+func (n *Node) rememberedPeers(exclude []string) []string {
+	n.persistMut.Lock()
+	defer n.persistMut.Unlock()
+
+	seen := map[string]struct{}{n.cfg.BroadcastAddr: {}}
+	for _, addr := range exclude {
+		seen[addr] = struct{}{}
+	}
+
+	var res []string
+	for _, p := range n.persisted.Peers {
+		if _, ok := seen[p.Addr]; !ok {
+			res = append(res, p.Addr)
+		}
+	}
+	return res
+}
+
+// cachedLeaves returns the leaves saved in Config.DataDir. Leaves with
+// invalid IDs are ignored.
+func (n *Node) cachedLeaves() []api.Descriptor {
+	n.persistMut.Lock()
+	defer n.persistMut.Unlock()
+
+	var res []api.Descriptor
+	for _, l := range n.persisted.Leaves {
+		leafID, err := id.Parse(l.ID)
+		if err != nil {
+			continue
+		}
+		res = append(res, api.Descriptor{ID: leafID, Addr: l.Addr})
+	}
+	return res
+}
@@ -0,0 +1,121 @@
+package node
+
+import "github.com/rfratto/croissant/internal/api"
+
+// PlacementPolicy customizes which peers are used as replicas and which peers
+// are preferred for routing. Policies typically use Peer.Labels to make
+// decisions based on where peers are running.
+type PlacementPolicy interface {
+	// SelectReplicas returns up to n replicas from candidates. The first
+	// candidate owns the key, followed by the nodes succeeding it on the
+	// ring in ring order. The owner must always be the first replica
+	// returned.
+	SelectReplicas(candidates []Peer, n int) []Peer
+
+	// PreferPeer returns true if a should be used over b by self when both
+	// may be used for routing. PreferPeer must return false if a and b are
+	// equally preferred.
+	PreferPeer(self, a, b Peer) bool
+}
+
+// ZonePlacement is a PlacementPolicy that spreads replicas across zones.
+// The zone of a peer is read from one of its labels. Peers without a zone
+// are only used as replicas when there aren't enough peers in distinct zones.
+type ZonePlacement struct {
+	// Label is the key of the label holding the zone of a peer. Defaults to
+	// "zone" if unset.
+	Label string
+
+	// AvoidSameZone makes routing prefer peers in zones other than the zone
+	// of the local node. By default, routing prefers peers in the same zone.
+	AvoidSameZone bool
+}
+
+func (zp ZonePlacement) zone(p Peer) string {
+	label := zp.Label
+	if label == "" {
+		label = "zone"
+	}
+	zone, _ := p.Labels.Get(label)
+	return zone
+}
+
+// SelectReplicas implements PlacementPolicy. The owner of the key is picked
+// first, followed by the first candidate from each zone that isn't used
+// yet. If there are fewer zones than n, the remaining replicas are the
+// first unused candidates.
+func (zp ZonePlacement) SelectReplicas(candidates []Peer, n int) []Peer {
+	if len(candidates) == 0 || n <= 0 {
+		return nil
+	}
+
+	var (
+		picked = make([]bool, len(candidates))
+		zones  = make(map[string]struct{}, n)
+		count  = 1
+	)
+	picked[0] = true
+	if zone := zp.zone(candidates[0]); zone != "" {
+		zones[zone] = struct{}{}
+	}
+
+	for i := 1; i < len(candidates) && count < n; i++ {
+		zone := zp.zone(candidates[i])
+		if zone == "" {
+			continue
+		}
+		if _, used := zones[zone]; used {
+			continue
+		}
+		zones[zone] = struct{}{}
+		picked[i] = true
+		count++
+	}
+	for i := 1; i < len(candidates) && count < n; i++ {
+		if !picked[i] {
+			picked[i] = true
+			count++
+		}
+	}
+
+	// Keep replicas ordered by how close they are to the key.
+	replicas := make([]Peer, 0, count)
+	for i, p := range candidates {
+		if picked[i] {
+			replicas = append(replicas, p)
+		}
+	}
+	return replicas
+}
+
+// PreferPeer implements PlacementPolicy.
+func (zp ZonePlacement) PreferPeer(self, a, b Peer) bool {
+	var (
+		selfZone = zp.zone(self)
+		aZone    = zp.zone(a)
+		bZone    = zp.zone(b)
+	)
+	if selfZone == "" || aZone == "" || bZone == "" {
+		return false
+	}
+
+	var (
+		aSame = aZone == selfZone
+		bSame = bZone == selfZone
+	)
+	if zp.AvoidSameZone {
+		return !aSame && bSame
+	}
+	return aSame && !bSame
+}
+
+// selectReplicas applies policy to the replica candidates in ds.
+func selectReplicas(policy PlacementPolicy, ds []api.Descriptor, n int) []api.Descriptor {
+	peers := policy.SelectReplicas(descriptorsToPeers(ds), n)
+
+	res := make([]api.Descriptor, len(peers))
+	for i, p := range peers {
+		res[i] = p.descriptor()
+	}
+	return res
+}
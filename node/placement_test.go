@@ -0,0 +1,66 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZonePlacement(t *testing.T) {
+	peer := func(val int, zone string) Peer {
+		p := Peer{ID: id.ID{Low: uint64(val)}}
+		if zone != "" {
+			p.Labels = NewLabels(map[string]string{"zone": zone})
+		}
+		return p
+	}
+
+	var zp ZonePlacement
+
+	t.Run("SelectReplicas", func(t *testing.T) {
+		candidates := []Peer{
+			peer(1, "a"),
+			peer(2, "a"),
+			peer(3, ""),
+			peer(4, "b"),
+			peer(5, "b"),
+			peer(6, "c"),
+		}
+
+		require.Equal(t, []Peer{candidates[0], candidates[3], candidates[5]}, zp.SelectReplicas(candidates, 3))
+		require.Equal(t, []Peer{candidates[0], candidates[3]}, zp.SelectReplicas(candidates, 2))
+
+		// Not enough zones: fill with the closest remaining candidates.
+		require.Equal(t, []Peer{candidates[0], candidates[1], candidates[3], candidates[5]}, zp.SelectReplicas(candidates, 4))
+		require.Equal(t, candidates, zp.SelectReplicas(candidates, 10))
+	})
+
+	t.Run("PreferPeer", func(t *testing.T) {
+		var (
+			self    = peer(0, "a")
+			local   = peer(1, "a")
+			remote  = peer(2, "b")
+			unknown = peer(3, "")
+		)
+
+		require.True(t, zp.PreferPeer(self, local, remote))
+		require.False(t, zp.PreferPeer(self, remote, local))
+		require.False(t, zp.PreferPeer(self, local, local))
+		require.False(t, zp.PreferPeer(self, local, unknown))
+
+		avoid := ZonePlacement{AvoidSameZone: true}
+		require.True(t, avoid.PreferPeer(self, remote, local))
+		require.False(t, avoid.PreferPeer(self, local, remote))
+	})
+
+	t.Run("custom label", func(t *testing.T) {
+		rack := ZonePlacement{Label: "rack"}
+		var (
+			a = Peer{ID: id.ID{Low: 1}, Labels: NewLabels(map[string]string{"rack": "r1"})}
+			b = Peer{ID: id.ID{Low: 2}, Labels: NewLabels(map[string]string{"rack": "r1"})}
+			c = Peer{ID: id.ID{Low: 3}, Labels: NewLabels(map[string]string{"rack": "r2"})}
+		)
+		require.Equal(t, []Peer{a, c}, rack.SelectReplicas([]Peer{a, b, c}, 2))
+	})
+}
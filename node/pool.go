@@ -0,0 +1,52 @@
+package node
+
+import "time"
+
+// PoolStats is a snapshot of the connections the node has open to its
+// peers. Connections are shared between virtual nodes.
+type PoolStats struct {
+	// Dials is the number of connections dialed to peers.
+	Dials int
+	// DialFailures is the number of connections that failed to be dialed or
+	// to become ready.
+	DialFailures int
+	// Evictions is the number of connections closed by the node, by reason
+	// (e.g., "idle" or "max_conns").
+	Evictions map[string]int
+
+	// Conns holds the open connections, sorted by address.
+	Conns []ConnStats
+}
+
+// ConnStats describes a connection to a peer.
+type ConnStats struct {
+	Addr     string
+	State    string // Connectivity state of the connection, e.g., "READY".
+	Created  time.Time
+	LastUsed time.Time
+	InFlight int // Number of in-flight calls.
+}
+
+// PoolStats returns a snapshot of the connections the node has open to its
+// peers. The same stats are exposed as metrics when Config.Registerer is
+// set.
+func (n *Node) PoolStats() PoolStats {
+	s := n.controller.pool.Stats()
+
+	ps := PoolStats{
+		Dials:        s.Dials,
+		DialFailures: s.DialFailures,
+		Evictions:    s.Evictions,
+		Conns:        make([]ConnStats, 0, len(s.Conns)),
+	}
+	for _, c := range s.Conns {
+		ps.Conns = append(ps.Conns, ConnStats{
+			Addr:     c.Addr,
+			State:    c.State.String(),
+			Created:  c.Created,
+			LastUsed: c.LastUsed,
+			InFlight: c.InFlight,
+		})
+	}
+	return ps
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rfratto/croissant/id"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNoQuorum is returned by InvokeQuorum when too few replicas of a key
+// succeeded. The message of the error includes the number of successful
+// replicas and the first error returned by a replica.
+var ErrNoQuorum = errors.New("quorum not reached")
+
+// QuorumResponse is the response of a single replica to InvokeQuorum.
+type QuorumResponse struct {
+	// Peer is the replica that responded.
+	Peer Peer
+
+	// Reply is the response from Peer. nil if Err is set.
+	Reply proto.Message
+
+	// Err is the error returned by Peer, or the error that prevented the
+	// request from reaching Peer.
+	Err error
+}
+
+// InvokeQuorum invokes method on the n replicas of key found by
+// Node.ReplicaPeers, decoding their replies with newReply. Each replica
+// handles the request itself. InvokeQuorum returns once w replicas
+// succeeded, or once too many replicas failed for w of them to succeed.
+//
+// The responses received before InvokeQuorum returned are returned in
+// replica order, closest to key first. Requests to the remaining replicas
+// keep running until they complete or ctx is canceled, so writes still
+// reach every replica that's available.
+//
+// An error wrapping ErrNoQuorum is returned along with the responses if
+// fewer than w replicas succeeded, including when fewer than w replicas
+// could be found for key.
+func (c *Client) InvokeQuorum(ctx context.Context, key id.ID, n, w int, method string, req proto.Message, newReply func() proto.Message) (_ []QuorumResponse, err error) {
+	ctx, span := c.ctrl.tracer.Start(ctx, "croissant.Client/InvokeQuorum")
+	defer func() { endSpan(span, err) }()
+	span.SetAttribute(attrMethod, method)
+	span.SetAttribute(attrKey, key.String())
+
+	if w < 1 || w > n {
+		return nil, fmt.Errorf("w must be between 1 and n, got w=%d n=%d", w, n)
+	}
+
+	peers, err := c.ctrl.ReplicaPeers(ctx, key, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(peers) < w {
+		return nil, fmt.Errorf("%w: found %d replicas for %s, need %d", ErrNoQuorum, len(peers), key, w)
+	}
+
+	type result struct {
+		index int
+		resp  QuorumResponse
+	}
+	results := make(chan result, len(peers))
+
+	for i, p := range peers {
+		go func(i int, p Peer) {
+			reply := newReply()
+			info := ForwardInfo{Method: method, Key: key, Attempt: 1}
+			err := c.sendDirect(ctx, info, p.descriptor(), true, req, reply)
+
+			resp := QuorumResponse{Peer: p, Err: err}
+			if err == nil {
+				resp.Reply = reply
+			}
+			results <- result{index: i, resp: resp}
+		}(i, p)
+	}
+
+	var (
+		received  []result
+		succeeded int
+		failed    int
+		firstErr  error
+	)
+	for succeeded < w && len(peers)-failed >= w {
+		r := <-results
+		received = append(received, r)
+
+		if r.resp.Err == nil {
+			succeeded++
+			continue
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = r.resp.Err
+		}
+	}
+
+	sort.Slice(received, func(i, j int) bool { return received[i].index < received[j].index })
+	resps := make([]QuorumResponse, len(received))
+	for i, r := range received {
+		resps[i] = r.resp
+	}
+
+	if succeeded < w {
+		return resps, fmt.Errorf("%w: %d of %d replicas succeeded, need %d: %s", ErrNoQuorum, succeeded, len(peers), w, firstErr)
+	}
+	return resps, nil
+}
@@ -0,0 +1,36 @@
+package node
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// newRand returns a *rand.Rand seeded with seed which is safe for
+// concurrent use.
+func newRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// lockedSource is a rand.Source64 which is safe for concurrent use.
+type lockedSource struct {
+	mut sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Methods limited by membershipLimits.
+const (
+	methodJoin  = "Join"
+	methodHello = "NodeHello"
+)
+
+// membershipLimits enforces a RateLimitConfig. A nil *membershipLimits
+// allows every request.
+type membershipLimits struct {
+	peers map[string]Limiter       // Per-peer rate limits by method.
+	slots map[string]chan struct{} // Concurrency slots by method.
+}
+
+func newMembershipLimits(cfg *RateLimitConfig) *membershipLimits {
+	if cfg == nil {
+		return nil
+	}
+
+	l := &membershipLimits{
+		peers: make(map[string]Limiter),
+		slots: make(map[string]chan struct{}),
+	}
+	if cfg.PeerRate > 0 {
+		l.peers[methodJoin] = NewPeerLimiter(cfg.PeerRate, cfg.PeerBurst)
+		l.peers[methodHello] = NewPeerLimiter(cfg.PeerRate, cfg.PeerBurst)
+	}
+	if cfg.MaxConcurrentJoins > 0 {
+		l.slots[methodJoin] = make(chan struct{}, cfg.MaxConcurrentJoins)
+	}
+	if cfg.MaxConcurrentHellos > 0 {
+		l.slots[methodHello] = make(chan struct{}, cfg.MaxConcurrentHellos)
+	}
+	return l
+}
+
+// acquire checks whether a request for method from p may be handled.
+// release must be called once the request completes. Returns a
+// ResourceExhausted error if the request is over the limits.
+func (l *membershipLimits) acquire(p api.Descriptor, method string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if peers, ok := l.peers[method]; ok && !peers.Allow(peerFromDescriptor(p)) {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many %s requests from %s", method, p.Addr)
+	}
+
+	slots, ok := l.slots[method]
+	if !ok {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "too many concurrent %s requests", method)
+	}
+}
@@ -0,0 +1,87 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/discovery"
+)
+
+// startRejoin starts trying to rejoin the cluster in the background if the
+// node isn't already trying to rejoin.
+func (n *Node) startRejoin() {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+
+	if n.rejoining || n.seeds == nil {
+		return
+	}
+	n.rejoining = true
+	go n.rejoin(n.seeds)
+}
+
+// rejoin tries to rejoin the cluster using seeds until the node has peers
+// again or the node is closed.
+func (n *Node) rejoin(seeds discovery.Discoverer) {
+	defer func() {
+		n.mut.Lock()
+		n.rejoining = false
+		n.mut.Unlock()
+	}()
+
+	var (
+		cfg     = n.cfg.Rejoin
+		backoff = cfg.MinBackoff
+	)
+
+	for {
+		select {
+		case <-n.controller.quit:
+			return
+		case <-n.cfg.Clock.NewTimer(backoff).C():
+		}
+
+		// Peers may have found us again while we were waiting.
+		if !n.controller.isolated.Load() {
+			return
+		}
+
+		err := n.tryRejoin(seeds)
+		if err == nil && !n.IsSingleNode() {
+			level.Info(n.cfg.Log).Log("msg", "rejoined cluster")
+			n.controller.setIsolated(false)
+			return
+		}
+		if err == nil {
+			level.Warn(n.cfg.Log).Log("msg", "failed to rejoin cluster: no peers found", "backoff", backoff)
+		} else {
+			level.Warn(n.cfg.Log).Log("msg", "failed to rejoin cluster", "err", err, "backoff", backoff)
+		}
+
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
+
+func (n *Node) tryRejoin(seeds discovery.Discoverer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// Cancel the attempt if the node closes.
+	go func() {
+		select {
+		case <-n.controller.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	addrs, err := seeds.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	return n.join(ctx, addrs)
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// repairRoutes repairs a random row of the routing table. Entries are only
+// added to the routing table reactively, when peers greet the node or when an
+// entry fails, so rows may have holes or stale entries long after the node
+// joined. repairRoutes asks a random peer sharing the row's prefix for its
+// state and mixes in the peer's entries for that row.
+//
+// Rows without live entries are repaired through entries of later rows,
+// which share a longer prefix with the local node and so also know about the
+// row's prefix.
+func (c *controller) repairRoutes() {
+	state := c.state.Clone()
+
+	// candidates returns the live remote entries in row or later rows.
+	candidates := func(row int) []api.Descriptor {
+		var res []api.Descriptor
+		for _, entries := range state.Routing[row:] {
+			for _, ent := range entries {
+				if ent == nil || ent.Addr == state.Node.Addr || state.Statuses[*ent] != api.Healthy {
+					continue
+				}
+				res = append(res, *ent)
+			}
+		}
+		return res
+	}
+
+	// Only rows up to the last row with a live entry can be repaired.
+	last := -1
+	for row := len(state.Routing) - 1; row >= 0 && last < 0; row-- {
+		if len(candidates(row)) > 0 {
+			last = row
+		}
+	}
+	if last < 0 {
+		return
+	}
+
+	var (
+		row   = c.rand.Intn(last + 1)
+		peers = candidates(row)
+		peer  = peers[c.rand.Intn(len(peers))]
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.helloTimeout)
+	defer cancel()
+
+	peerState, err := getPeerState(ctx, c.pool, peer)
+	if err != nil {
+		level.Warn(c.log).Log("msg", "could not get state to repair routing table", "row", row, "peer", peer.Addr, "err", err)
+		return
+	}
+
+	if c.state.RepairRow(row, peerState) {
+		level.Debug(c.log).Log("msg", "repaired routing table", "row", row, "peer", peer.Addr)
+		c.health.CheckNodes(c.state.Peers(true))
+	}
+}
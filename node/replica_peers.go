@@ -0,0 +1,104 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// ReplicaPeers returns the owner of key followed by the nodes succeeding it
+// on the ring, until count distinct nodes are found.
+func (c *controller) ReplicaPeers(ctx context.Context, key id.ID, count int) ([]Peer, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+
+	s, err := c.stateNear(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	owners, _ := api.Replicas(s, key, 1)
+	if len(owners) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoRoute, key)
+	}
+
+	var (
+		res     []api.Descriptor
+		seen    = make(map[api.Descriptor]struct{})
+		addrs   = make(map[string]struct{})
+		ring    = api.Clockwise(s, owners[0].ID)
+		fetched = 0
+	)
+	for {
+		for _, d := range ring {
+			if _, ok := seen[d]; ok {
+				// We wrapped around the ring; there are no more nodes.
+				return descriptorsToPeers(res), nil
+			}
+			seen[d] = struct{}{}
+
+			// Only use the first virtual node of each node.
+			if _, ok := addrs[d.Addr]; ok {
+				continue
+			}
+			addrs[d.Addr] = struct{}{}
+
+			res = append(res, d)
+			if len(res) == count {
+				return descriptorsToPeers(res), nil
+			}
+		}
+
+		// Continue walking the ring from the furthest node we know about.
+		if len(ring) == 0 || fetched >= censusMaxHops {
+			return descriptorsToPeers(res), nil
+		}
+		last := ring[len(ring)-1]
+		next, err := c.peerState(ctx, last)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state of %s: %w", last.Addr, err)
+		}
+		fetched++
+
+		// Skip the node itself, which was already visited.
+		ring = api.Clockwise(next, last.ID)
+		if len(ring) > 0 && ring[0] == last {
+			ring = ring[1:]
+		}
+	}
+}
+
+// stateNear returns a state whose leaves cover key, fetching the state of
+// peers along the route to key if key is too far away from the local node.
+func (c *controller) stateNear(ctx context.Context, key id.ID) (*api.State, error) {
+	s := c.routeState(key)
+	for hop := 0; hop < censusMaxHops; hop++ {
+		if _, ok := api.Replicas(s, key, 1); ok {
+			return s, nil
+		}
+
+		next, ok := api.NextHop(s, key)
+		if !ok {
+			return nil, fmt.Errorf("%w %s", ErrNoRoute, key)
+		}
+		peerState, err := c.peerState(ctx, next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get state of %s: %w", next.Addr, err)
+		}
+		s = peerState
+	}
+	return nil, fmt.Errorf("%w %s: too many hops", ErrNoRoute, key)
+}
+
+// peerState returns the state of d, which may be a virtual node of the local
+// node.
+func (c *controller) peerState(ctx context.Context, d api.Descriptor) (*api.State, error) {
+	for _, vnode := range c.vnodes {
+		if vnode.state.Node == d {
+			return vnode.state.Clone(), nil
+		}
+	}
+	return getPeerState(ctx, c.pool, d)
+}
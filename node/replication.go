@@ -0,0 +1,102 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// ErrKeyNotLocal is returned when a key is too far away from the node for
+// the node to answer a request about it.
+var ErrKeyNotLocal = errors.New("key is not in range of the node's leaves")
+
+func (c *controller) Replicas(key id.ID) ([]Peer, error) {
+	replicas, ok := c.replicasFor(c.routeState(key), key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotLocal, key)
+	}
+	return descriptorsToPeers(replicas), nil
+}
+
+// replicasFor returns the replicas for key from s. Virtual nodes of the same
+// node are never used as separate replicas; only the first virtual node of
+// each node is returned. If a PlacementPolicy is configured, it selects the
+// replicas from the owner of key and its successors.
+func (c *controller) replicasFor(s *api.State, key id.ID) ([]api.Descriptor, bool) {
+	if len(c.vnodes) <= 1 && c.placement == nil {
+		return api.Replicas(s, key, c.replicationFactor)
+	}
+
+	all, ok := api.Replicas(s, key, len(s.Leaves(false))+1)
+	if !ok {
+		return nil, false
+	}
+
+	var (
+		candidates = make([]api.Descriptor, 0, len(all))
+		seen       = make(map[string]struct{}, len(all))
+	)
+	for _, r := range all {
+		if _, ok := seen[r.Addr]; ok {
+			continue
+		}
+		seen[r.Addr] = struct{}{}
+		candidates = append(candidates, r)
+	}
+
+	if c.placement != nil {
+		return selectReplicas(c.placement, candidates, c.replicationFactor), true
+	}
+	if len(candidates) > c.replicationFactor {
+		candidates = candidates[:c.replicationFactor]
+	}
+	return candidates, true
+}
+
+// checkReplicas informs the Application when the replicas for the keys owned
+// by the local node change.
+func (c *controller) checkReplicas() {
+	if c.joining.Load() {
+		return
+	}
+
+	// Hold the lock while informing the Application so it sees changes in
+	// order.
+	c.replicaMut.Lock()
+	defer c.replicaMut.Unlock()
+
+	replicas, _ := c.replicasFor(c.state, c.state.Node.ID)
+	if equalDescriptors(c.replicas, replicas) {
+		return
+	}
+	c.replicas = replicas
+
+	level.Debug(c.log).Log("msg", "replicas changed", "count", len(replicas))
+	if w, ok := c.app.(ReplicaWatcher); ok {
+		w.ReplicasChanged(descriptorsToPeers(replicas))
+	}
+}
+
+func equalDescriptors(a, b []api.Descriptor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// descriptorsToPeers converts ds into peers, retaining order.
+func descriptorsToPeers(ds []api.Descriptor) []Peer {
+	peers := make([]Peer, len(ds))
+	for i, d := range ds {
+		peers[i] = peerFromDescriptor(d)
+	}
+	return peers
+}
@@ -0,0 +1,211 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfratto/croissant/clock"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrRetriesExhausted is returned by Clients when a request failed and may
+// not be retried again. The message of the error includes the peers the
+// request was sent to and the last error.
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// DefaultRetryPolicy is the RetryPolicy used by Clients unless changed with
+// WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// RetryPolicy controls how Clients retry failed requests. Requests are
+// always retried when they can't be sent to a peer, after marking the peer
+// as unhealthy so the next attempt may choose a different peer.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts to send a request,
+	// including the first attempt. Requests aren't retried if MaxAttempts is
+	// less than 2.
+	MaxAttempts int
+
+	// InitialBackoff is the time to wait before the first retry. The wait
+	// doubles after each retry, up to MaxBackoff if MaxBackoff is set.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// RetryableCodes are the status codes of errors returned by peers that
+	// are retried. Errors from peers aren't retried by default, since the
+	// request may have had side effects.
+	RetryableCodes []codes.Code
+
+	// Budget, if set, limits retries across all requests sent by the Client,
+	// preventing retries from overloading the cluster during outages.
+	Budget *RetryBudget
+}
+
+// RetryBudget limits the number of retries relative to the number of
+// requests sent by a Client over the last 10 seconds.
+type RetryBudget struct {
+	// Ratio is the maximum ratio of retries to requests. For example, 0.1
+	// allows one retry for every 10 requests.
+	Ratio float64
+
+	// MinRetriesPerSecond is the number of retries allowed per second
+	// regardless of Ratio, so requests may be retried when few requests are
+	// sent.
+	MinRetriesPerSecond float64
+}
+
+// WithRetryPolicy sets the policy for retrying failed requests. Defaults to
+// DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = p
+	}
+}
+
+// retrier tracks the attempts of a single request.
+type retrier struct {
+	policy  RetryPolicy
+	budget  *retryBudget
+	clock   clock.Clock
+	backoff time.Duration
+
+	attempts int
+	tried    []string // Addresses of peers that failed the request.
+}
+
+func (c *Client) newRetrier() *retrier {
+	if c.retryBudget != nil {
+		c.retryBudget.request()
+	}
+	return &retrier{
+		policy:  c.retryPolicy,
+		budget:  c.retryBudget,
+		clock:   c.ctrl.clock,
+		backoff: c.retryPolicy.InitialBackoff,
+	}
+}
+
+// attempt records the start of a new attempt and returns the number of
+// attempts so far.
+func (r *retrier) attempt() int {
+	r.attempts++
+	return r.attempts
+}
+
+// retryable returns true if err, returned by a peer, may be retried.
+func (r *retrier) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range r.policy.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retry records that the current attempt to send a request to next failed
+// with err and waits before the next attempt. Returns an error wrapping
+// ErrRetriesExhausted if the request may not be retried.
+func (r *retrier) retry(ctx context.Context, next api.Descriptor, err error) error {
+	r.tried = append(r.tried, next.Addr)
+
+	if r.attempts >= r.policy.MaxAttempts {
+		return r.exhausted(fmt.Sprintf("after %d attempts", r.attempts), err)
+	} else if r.budget != nil && !r.budget.allowRetry() {
+		return r.exhausted("retry budget exceeded", err)
+	}
+
+	if r.backoff > 0 {
+		t := r.clock.NewTimer(r.backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		case <-t.C():
+		}
+	}
+
+	r.backoff *= 2
+	if r.policy.MaxBackoff > 0 && r.backoff > r.policy.MaxBackoff {
+		r.backoff = r.policy.MaxBackoff
+	}
+	return nil
+}
+
+func (r *retrier) exhausted(reason string, err error) error {
+	s := status.Convert(err)
+	return statusErrorf(ErrRetriesExhausted, s.Code(), "%s %s (tried %s): %s", ErrRetriesExhausted, reason, strings.Join(r.tried, ", "), s.Message())
+}
+
+// retryBudgetWindow is the number of seconds tracked by retryBudget.
+const retryBudgetWindow = 10
+
+// retryBudget enforces a RetryBudget using a sliding window of one second
+// buckets.
+type retryBudget struct {
+	cfg RetryBudget
+	now func() time.Time
+
+	mut     sync.Mutex
+	buckets [retryBudgetWindow]budgetBucket
+}
+
+type budgetBucket struct {
+	sec               int64
+	requests, retries int
+}
+
+func newRetryBudget(cfg RetryBudget, clk clock.Clock) *retryBudget {
+	return &retryBudget{cfg: cfg, now: clk.Now}
+}
+
+// request records a new request.
+func (b *retryBudget) request() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.bucket(b.now()).requests++
+}
+
+// allowRetry returns true and records a retry if the budget allows for it.
+func (b *retryBudget) allowRetry() bool {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := b.now()
+
+	var requests, retries int
+	for _, bk := range b.buckets {
+		if now.Unix()-bk.sec < retryBudgetWindow {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+
+	allowed := b.cfg.Ratio*float64(requests) + b.cfg.MinRetriesPerSecond*retryBudgetWindow
+	if float64(retries) >= allowed {
+		return false
+	}
+	b.bucket(now).retries++
+	return true
+}
+
+// bucket returns the bucket for now, resetting it if it was last used for
+// an older second.
+func (b *retryBudget) bucket(now time.Time) *budgetBucket {
+	sec := now.Unix()
+	bk := &b.buckets[sec%retryBudgetWindow]
+	if bk.sec != sec {
+		*bk = budgetBucket{sec: sec}
+	}
+	return bk
+}
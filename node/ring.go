@@ -0,0 +1,172 @@
+package node
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// RingReport is the result of verifying the consistency of the ring with
+// Node.VerifyRing.
+type RingReport struct {
+	// Members are the nodes found by walking the ring, sorted by ID.
+	Members []Peer
+
+	// Divergences are the members whose view of the ring doesn't match
+	// Members or couldn't be retrieved. Sorted by ID.
+	Divergences []RingDivergence
+}
+
+// Consistent returns true if every member agrees on the ring.
+func (r RingReport) Consistent() bool { return len(r.Divergences) == 0 }
+
+// RingDivergence describes a node whose view of its neighborhood in the ring
+// differs from the ring found by Node.VerifyRing.
+type RingDivergence struct {
+	Peer Peer
+
+	// Err is set if the view of Peer couldn't be retrieved. The other fields
+	// are unset if Err is set.
+	Err error
+
+	// Checksum is the checksum of Peer's view, and Expected is the checksum
+	// Peer would report if its view matched the ring.
+	Checksum, Expected uint64
+
+	// Missing are members that should be leaves of Peer but aren't, such as
+	// when Peer has a split view of the ring. Stale are leaves of Peer that
+	// shouldn't be, such as nodes that left the ring.
+	Missing, Stale []Peer
+}
+
+// VerifyRing checks that every node in the cluster agrees on the membership
+// of the ring. The ring is walked like Members, bypassing the cache, and
+// every member is asked for its healthy predecessors and successors along
+// with a checksum over them. Each member's checksum is compared against the
+// checksum of the neighborhood it should have according to the ring, which
+// spans as many predecessors and successors as the member reported.
+//
+// Views change while nodes join and leave, so divergences may be transient.
+// An error is returned along with the partial report if the ring couldn't
+// be walked.
+func (n *Node) VerifyRing(ctx context.Context) (RingReport, error) {
+	return n.controller.VerifyRing(ctx)
+}
+
+// RingChecksum implements api.Node.
+func (c *controller) RingChecksum(_ context.Context) (api.RingView, error) {
+	return api.RingViewOf(c.state), nil
+}
+
+func (c *controller) VerifyRing(ctx context.Context) (RingReport, error) {
+	found, walkErr := c.walkRing(ctx)
+
+	ring := toPeers(found)
+	report := RingReport{Members: ring}
+
+	var (
+		wg    sync.WaitGroup
+		views = make([]api.RingView, len(ring))
+		errs  = make([]error, len(ring))
+	)
+	for i, p := range ring {
+		wg.Add(1)
+		go func(i int, d api.Descriptor) {
+			defer wg.Done()
+			views[i], errs[i] = c.ringView(ctx, d)
+		}(i, p.descriptor())
+	}
+	wg.Wait()
+
+	for i, p := range ring {
+		if errs[i] != nil {
+			report.Divergences = append(report.Divergences, RingDivergence{Peer: p, Err: errs[i]})
+			continue
+		}
+		if d, ok := checkRingView(ring, i, views[i]); !ok {
+			report.Divergences = append(report.Divergences, d)
+		}
+	}
+	return report, walkErr
+}
+
+// ringView returns the view of the ring of d, which may be a virtual node of
+// the local node.
+func (c *controller) ringView(ctx context.Context, d api.Descriptor) (api.RingView, error) {
+	for _, vnode := range c.vnodes {
+		if vnode.state.Node == d {
+			return vnode.RingChecksum(ctx)
+		}
+	}
+
+	cc, err := c.pool.GetReady(ctx, d.Addr)
+	if err != nil {
+		return api.RingView{}, err
+	}
+	return c.nodeClient(cc).RingChecksum(withTarget(ctx, d))
+}
+
+// checkRingView compares the view v of ring[i] against ring. ok is false if
+// v has a different checksum than expected.
+func checkRingView(ring []Peer, i int, v api.RingView) (_ RingDivergence, ok bool) {
+	n := len(ring)
+
+	// neighborhood returns count members of the ring away from ring[i] in
+	// direction dir, closest first.
+	neighborhood := func(count, dir int) []api.Descriptor {
+		if count > n-1 {
+			count = n - 1
+		}
+		res := make([]api.Descriptor, 0, count)
+		for k := 1; k <= count; k++ {
+			res = append(res, ring[((i+dir*k)%n+n)%n].descriptor())
+		}
+		return res
+	}
+
+	var (
+		expectPreds = neighborhood(len(v.Predecessors), -1)
+		expectSuccs = neighborhood(len(v.Successors), 1)
+		expected    = api.RingChecksum(v.Node, expectPreds, expectSuccs)
+	)
+	if v.Checksum == expected {
+		return RingDivergence{}, true
+	}
+
+	var (
+		expectSet = descriptorSet(expectPreds, expectSuccs)
+		actualSet = descriptorSet(v.Predecessors, v.Successors)
+
+		missing, stale []api.Descriptor
+	)
+	for desc := range expectSet {
+		if _, ok := actualSet[desc]; !ok {
+			missing = append(missing, desc)
+		}
+	}
+	for desc := range actualSet {
+		if _, ok := expectSet[desc]; !ok {
+			stale = append(stale, desc)
+		}
+	}
+
+	return RingDivergence{
+		Peer:     ring[i],
+		Checksum: v.Checksum,
+		Expected: expected,
+		Missing:  toPeers(missing),
+		Stale:    toPeers(stale),
+	}, false
+}
+
+// descriptorSet returns the set of descriptors in lists.
+func descriptorSet(lists ...[]api.Descriptor) map[api.Descriptor]struct{} {
+	set := make(map[api.Descriptor]struct{})
+	for _, list := range lists {
+		for _, d := range list {
+			set[d] = struct{}{}
+		}
+	}
+	return set
+}
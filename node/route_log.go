@@ -0,0 +1,40 @@
+package node
+
+import (
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// logRoute logs that next was chosen as the next hop for key. reason
+// describes why next was chosen. Only a sample of decisions are logged,
+// controlled by Config.RouteLogSampleRate.
+func (c *controller) logRoute(key id.ID, next api.Descriptor, reason string) {
+	if c.routeLogRate <= 0 || (c.routeLogRate < 1 && c.rand.Float64() >= c.routeLogRate) {
+		return
+	}
+	level.Debug(c.log).Log(
+		"msg", "routing decision",
+		"key", key,
+		"hop", next.Addr,
+		"hop_id", next.ID,
+		"self", c.isLocal(next),
+		"reason", reason,
+	)
+}
+
+// routeReason returns the reason logged for a hop found in source.
+func routeReason(source api.RouteSource) string {
+	switch source {
+	case api.RouteSelf:
+		return "self"
+	case api.RouteLeaf:
+		return "leaf"
+	case api.RouteRoutingTable:
+		return "table"
+	case api.RouteNeighbor:
+		return "neighbor"
+	default:
+		return "fallback"
+	}
+}
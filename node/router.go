@@ -3,20 +3,161 @@ package node
 import (
 	"context"
 	"errors"
+	"io"
+	"strings"
 	"sync"
 
+	"github.com/rfratto/croissant/internal/nodepb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // Router supplies a set of gRPC server interceptors that can route requests
 // through the cluster. A Node must be set with SetNode for the Router to work.
-// set with SetNode
+//
+// Methods of the cluster's Node service are never routed. Other methods can
+// be excluded from routing with Exclude, ExcludeServices, and ExcludeFunc,
+// or limited to a set of methods and services with Include.
 type Router struct {
-	mut  sync.Mutex
-	node *Node
+	mut       sync.Mutex
+	node      *Node
+	excluded  map[string]struct{} // Excluded methods and services.
+	included  map[string]struct{} // If set, the only routed methods and services.
+	excludeFn func(fullMethod string) bool
+	limiter   Limiter
+	hook      ForwardHook
+	extractor KeyExtractor
+}
+
+// InfrastructureServices are gRPC services that report on or describe the
+// local server, such as health checking and reflection. Pass them to
+// ExcludeServices so they're always handled locally.
+var InfrastructureServices = []string{
+	"grpc.health.v1.Health",
+	"grpc.reflection.v1alpha.ServerReflection",
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.channelz.v1.Channelz",
+}
+
+// Exclude prevents the given methods from being routed. Excluded methods will
+// always be handled by the local node, even if a key is present in the
+// request context. Methods must be specified by their full name, i.e.,
+// /package.Service/Method.
+func (r *Router) Exclude(methods ...string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.excluded == nil {
+		r.excluded = make(map[string]struct{}, len(methods))
+	}
+	for _, m := range methods {
+		r.excluded[m] = struct{}{}
+	}
+}
+
+// ExcludeServices prevents every method of the given services from being
+// routed. Services must be specified by their full name, i.e.,
+// package.Service.
+func (r *Router) ExcludeServices(services ...string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.excluded == nil {
+		r.excluded = make(map[string]struct{}, len(services))
+	}
+	for _, s := range services {
+		r.excluded[s] = struct{}{}
+	}
+}
+
+// Include limits routing to the given methods and services. Once called,
+// all other methods are handled by the local node. Methods must be specified
+// by their full name, i.e., /package.Service/Method, and services by their
+// full name, i.e., package.Service. Exclusions still apply to included
+// methods.
+func (r *Router) Include(methodsOrServices ...string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.included == nil {
+		r.included = make(map[string]struct{}, len(methodsOrServices))
+	}
+	for _, m := range methodsOrServices {
+		r.included[m] = struct{}{}
+	}
+}
+
+// ExcludeFunc prevents methods from being routed when f returns true for the
+// full name of the method. Excluded methods will always be handled by the
+// local node. Replaces any previously set function.
+func (r *Router) ExcludeFunc(f func(fullMethod string) bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.excludeFn = f
+}
+
+// SetLimiter limits the rate of requests forwarded to each peer. Forwarded
+// requests that would exceed the limit fail with ResourceExhausted instead of
+// being sent. Requests handled by the local node are never limited. Passing
+// nil removes the limit.
+func (r *Router) SetLimiter(l Limiter) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.limiter = l
+}
+
+// SetForwardHook sets a hook invoked before requests are forwarded to a
+// peer, including requests forwarded to the local node. See ForwardHook.
+// Passing nil removes the hook.
+func (r *Router) SetForwardHook(h ForwardHook) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.hook = h
+}
+
+// SetKeyExtractor derives the routing key of unary requests from their
+// message using e when callers didn't set a key with WithClientKey.
+// Passing nil removes the extractor.
+func (r *Router) SetKeyExtractor(e KeyExtractor) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.extractor = e
+}
+
+// isExcluded returns true if fullMethod shouldn't be routed. Must be called
+// with the mutex held.
+func (r *Router) isExcluded(fullMethod string) bool {
+	service := serviceName(fullMethod)
+	if service == nodepb.Node_ServiceDesc.ServiceName {
+		return true
+	}
+	if r.included != nil && !matchMethod(r.included, fullMethod, service) {
+		return true
+	}
+	if matchMethod(r.excluded, fullMethod, service) {
+		return true
+	}
+	return r.excludeFn != nil && r.excludeFn(fullMethod)
+}
+
+// matchMethod returns true if set holds fullMethod or its service.
+func matchMethod(set map[string]struct{}, fullMethod, service string) bool {
+	if _, ok := set[fullMethod]; ok {
+		return true
+	}
+	_, ok := set[service]
+	return ok
+}
+
+// serviceName returns the service of fullMethod, /package.Service/Method.
+func serviceName(fullMethod string) string {
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return name
 }
 
 // Unary returns a grpc.UnaryServerInterceptor.
@@ -24,13 +165,20 @@ func (r *Router) Unary() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		r.mut.Lock()
 		node := r.node
+		excluded := r.isExcluded(info.FullMethod)
+		limiter := r.limiter
+		hook := r.hook
+		extractor := r.extractor
 		r.mut.Unlock()
 
+		if excluded {
+			return handler(ctx, req)
+		}
 		if node == nil {
 			return nil, status.Errorf(codes.Unavailable, "not connected to cluster")
 		}
 
-		return r.node.controller.ForwardUnary(ctx, req, info, handler)
+		return node.controller.ForwardUnary(ctx, req, info, handler, WithLimiter(limiter), WithForwardHook(hook), WithKeyExtractor(extractor))
 	}
 }
 
@@ -39,13 +187,19 @@ func (r *Router) Stream() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		r.mut.Lock()
 		node := r.node
+		excluded := r.isExcluded(info.FullMethod)
+		limiter := r.limiter
+		hook := r.hook
 		r.mut.Unlock()
 
+		if excluded {
+			return handler(srv, ss)
+		}
 		if node == nil {
 			return status.Errorf(codes.Unavailable, "not connected to cluster")
 		}
 
-		return r.node.controller.ForwardStream(srv, ss, info, handler)
+		return node.controller.ForwardStream(srv, ss, info, handler, WithLimiter(limiter), WithForwardHook(hook))
 	}
 }
 
@@ -59,37 +213,288 @@ func (r *Router) SetNode(n *Node) {
 // ForwardUnary implements grpc.UnaryServerInterceptor and will propagate
 // a request or call handler if it is owned by the local node. Node errors
 // are resolved immediately and requests will be re-tried until there is a
-// node that can handle it.
-func (c *controller) ForwardUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	_, err = ExtractClientKey(ctx)
+// node that can handle it. opts are applied to the Client used for
+// forwarding.
+//
+// Metadata of the request is forwarded along with the request, and the
+// deadline of the request applies to every hop. The handler of the node
+// owning the key can inspect the route of the request with
+// RouteInfoFromContext. The handler's context holds the span of the peer
+// that forwarded the request if the node's Tracer is a TracePropagator.
+// Requests a peer sent as a final hop, such as requests redirected away from
+// a draining node, are always handled locally, even when a KeyExtractor
+// could derive a key for them.
+//
+// Responses of forwarded requests aren't decoded, so they're sent to the
+// caller exactly as the peer sent them. Interceptors chained before the
+// Router receive forwarded responses as an *emptypb.Empty holding the
+// encoded response as unknown fields. The gRPC server doesn't need a custom
+// codec to send them.
+func (c *controller) ForwardUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler, opts ...ClientOption) (resp interface{}, err error) {
+	ctx = c.extractTrace(ctx)
+	if isFinal(ctx) {
+		c.observeLocal(ctx, false)
+		return handler(ctx, req)
+	}
+
+	cc := &Client{ctrl: c, allowSelf: false}
+	for _, o := range opts {
+		o(cc)
+	}
+
+	key, err := requestKey(ctx, cc.keyExtractor, info.FullMethod, req, nil)
 	if errors.Is(err, ErrNoKey) {
+		c.observeLocal(ctx, false)
 		return handler(ctx, req)
 	} else if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid key: %s", err)
+		return nil, err
 	}
+	ctx = context.WithValue(ctx, idContextKey, key)
 
-	cc := &Client{ctrl: c, allowSelf: false}
+	ctx, span := c.startForwardSpan(ctx, "croissant.Router/ForwardUnary", info.FullMethod)
+	defer func() { span.End(err) }()
 
-	var m anypb.Any
-	err = cc.Invoke(ctx, info.FullMethod, req, &m)
+	var frame rawFrame
+	err = cc.Invoke(propagateMetadata(ctx), info.FullMethod, req, &frame, forwardCodec)
 	if errors.Is(err, ErrSelfRouting) {
+		c.observeLocal(ctx, true)
+		span.SetAttribute(attrOutcome, outcomeLocal)
 		return handler(ctx, req)
 	}
-	return &m, err
+	span.SetAttribute(attrOutcome, c.observeForwarded(err))
+	if err != nil {
+		return nil, err
+	}
+	return frame.message(), nil
+}
+
+// startForwardSpan starts a span for routing a request for method.
+func (c *controller) startForwardSpan(ctx context.Context, name, method string) (context.Context, Span) {
+	ctx, span := c.tracer.Start(ctx, name)
+	span.SetAttribute(attrMethod, method)
+	if key, err := ExtractClientKey(ctx); err == nil {
+		span.SetAttribute(attrKey, key.String())
+	}
+	if hops, ok := extractHops(ctx); ok {
+		span.SetAttribute(attrHops, hops)
+	}
+	return ctx, span
+}
+
+// observeLocal records a request handled by the local node. Requests that
+// weren't routed by the local node or a peer are ignored unless routed is
+// true.
+func (c *controller) observeLocal(ctx context.Context, routed bool) {
+	hops, ok := extractHops(ctx)
+	if !ok && !routed {
+		return
+	}
+	c.metrics.routedRequestsTotal.WithLabelValues(outcomeLocal).Inc()
+	c.metrics.requestHops.Observe(float64(hops))
+}
+
+// observeForwarded records a request forwarded to a peer, returning its
+// outcome.
+func (c *controller) observeForwarded(err error) (outcome string) {
+	outcome = outcomeForwarded
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	c.metrics.routedRequestsTotal.WithLabelValues(outcome).Inc()
+	return outcome
 }
 
 // ForwardStream implements grpc.StreamServerInterceptor and will propagate
 // a request or call handler if it is owned by the local node. Node errors
 // are resolved immediately and requests will be re-tried until there is a
-// node that can handle it.
-func (c *controller) ForwardStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	_, err := ExtractClientKey(ss.Context())
+// node that can handle it. opts are applied to the Client used for
+// forwarding.
+//
+// Metadata and the deadline of the stream are forwarded as with
+// ForwardUnary. Messages are proxied between the caller and the node owning
+// the key until either side finishes. Headers and trailers from the owner
+// are sent back to the caller.
+func (c *controller) ForwardStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler, opts ...ClientOption) (err error) {
+	ss = c.extractTraceStream(ss)
+	if isFinal(ss.Context()) {
+		c.observeLocal(ss.Context(), false)
+		return handler(srv, ss)
+	}
+
+	_, err = ExtractClientKey(ss.Context())
 	if errors.Is(err, ErrNoKey) {
+		c.observeLocal(ss.Context(), false)
 		return handler(srv, ss)
 	} else if err != nil {
 		return status.Errorf(codes.InvalidArgument, "invalid key: %s", err)
 	}
 
-	// TODO(rfratto): implement
-	return status.Errorf(codes.Unimplemented, "unable to route stream requests")
+	cli := &Client{ctrl: c, allowSelf: false}
+	for _, o := range opts {
+		o(cli)
+	}
+
+	ctx, span := c.startForwardSpan(ss.Context(), "croissant.Router/ForwardStream", info.FullMethod)
+	defer func() { span.End(err) }()
+
+	ctx, cancel := context.WithCancel(propagateMetadata(ctx))
+	defer cancel()
+
+	desc := &grpc.StreamDesc{
+		StreamName:    info.FullMethod,
+		ServerStreams: info.IsServerStream,
+		ClientStreams: info.IsClientStream,
+	}
+	cs, err := cli.NewStream(ctx, desc, info.FullMethod)
+	if errors.Is(err, ErrSelfRouting) {
+		c.observeLocal(ctx, true)
+		span.SetAttribute(attrOutcome, outcomeLocal)
+		return handler(srv, ss)
+	}
+	span.SetAttribute(attrOutcome, c.observeForwarded(err))
+	if err != nil {
+		return err
+	}
+
+	// Stop proxying and wait for the pumps once either side finishes, since
+	// the streams must not be used after ForwardStream returns.
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	var (
+		fromCaller = pumpCallerMessages(ctx, &wg, ss, cs)
+		fromOwner  = pumpOwnerMessages(&wg, cs, ss)
+	)
+	for {
+		select {
+		case err := <-fromCaller:
+			if errors.Is(err, io.EOF) {
+				// The caller is done sending messages. Keep waiting for the
+				// response of the owner.
+				fromCaller = nil
+				continue
+			}
+			return status.Errorf(codes.Internal, "failed to forward stream: %s", err)
+
+		case err := <-fromOwner:
+			ss.SetTrailer(cs.Trailer())
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// callerMessage is a message received from the caller of a forwarded stream.
+type callerMessage struct {
+	m   *emptypb.Empty
+	err error
+}
+
+// pumpCallerMessages sends messages from the caller ss to the owner cs until
+// ctx is canceled, and closes the send direction of cs once the caller stops
+// sending. The returned channel receives io.EOF when the caller stops
+// sending.
+func pumpCallerMessages(ctx context.Context, wg *sync.WaitGroup, ss grpc.ServerStream, cs grpc.ClientStream) <-chan error {
+	var (
+		msgs  = recvCallerMessages(ctx, ss)
+		errCh = make(chan error, 1)
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			var msg callerMessage
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-msgs:
+			}
+
+			if msg.err != nil {
+				if errors.Is(msg.err, io.EOF) {
+					// Let the owner know the caller is done.
+					_ = cs.CloseSend()
+				}
+				errCh <- msg.err
+				return
+			}
+			if err := cs.SendMsg(msg.m); err != nil {
+				// The real error will be returned when receiving from cs.
+				return
+			}
+		}
+	}()
+	return errCh
+}
+
+// recvCallerMessages receives messages from the caller ss until an error is
+// received or ctx is canceled.
+//
+// Unlike the pumps, the receiving goroutine isn't waited on: RecvMsg can't
+// be interrupted, and blocks until the caller sends a message or until the
+// stream is finished after the interceptor returns. It never uses ss or any
+// other stream once RecvMsg returns.
+func recvCallerMessages(ctx context.Context, ss grpc.ServerStream) <-chan callerMessage {
+	msgs := make(chan callerMessage)
+	go func() {
+		for {
+			// Unknown fields are retained when unmarshaling, so an empty
+			// message can be used to pass through any message as-is.
+			var (
+				m   emptypb.Empty
+				err = ss.RecvMsg(&m)
+			)
+			select {
+			case <-ctx.Done():
+				return
+			case msgs <- callerMessage{m: &m, err: err}:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return msgs
+}
+
+// pumpOwnerMessages sends headers and messages from the owner cs to the
+// caller ss until cs fails or is canceled. The returned channel receives
+// io.EOF when the owner successfully completes the stream.
+func pumpOwnerMessages(wg *sync.WaitGroup, cs grpc.ClientStream, ss grpc.ServerStream) <-chan error {
+	errCh := make(chan error, 1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		md, err := cs.Header()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if err := ss.SendHeader(md); err != nil {
+			errCh <- err
+			return
+		}
+
+		for {
+			var m emptypb.Empty
+			if err := cs.RecvMsg(&m); err != nil {
+				errCh <- err
+				return
+			}
+			if err := ss.SendMsg(&m); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	return errCh
 }
@@ -2,19 +2,31 @@ package node
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/rfratto/croissant/examples/kv/kvproto"
 	"github.com/rfratto/croissant/examples/kv/kvserver"
 	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 // TODO(rfratto): simulate a cluster with 1,000 nodes and make sure each
@@ -61,13 +73,119 @@ func TestClientRouting(t *testing.T) {
 	require.Equal(t, "peer", resp.GetValue(), "expected response from peer")
 }
 
+func TestRouter_Exclude(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var seedRouter Router
+	seedRouter.Exclude("/example.kv.v1.KV/Get")
+
+	_, seedNode := makeTestNodeWithRouter(t, log.With(l, "node", "seed"), &seedRouter, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	})
+	err := seedNode.Join(ctx, nil)
+	require.NoError(t, err)
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	})
+	err = peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr})
+	require.NoError(t, err)
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	clusterClient := kvproto.NewKVClient(clusterCC)
+
+	// The key belongs to the peer, but the seed should handle it since the
+	// method is excluded.
+	resp, err := clusterClient.Get(
+		WithClientKey(ctx, peerNode.cfg.ID),
+		&kvproto.GetRequest{Key: "seed"},
+	)
+	require.NoError(t, err, "failed to handle excluded method locally")
+	require.Equal(t, "seed", resp.GetValue(), "expected response from seed")
+}
+
+func TestRouter_ExcludeServices(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	newHealthServer := func(s grpc_health_v1.HealthCheckResponse_ServingStatus) *health.Server {
+		srv := health.NewServer()
+		srv.SetServingStatus("", s)
+		return srv
+	}
+
+	var seedRouter Router
+	seedRouter.ExcludeServices(InfrastructureServices...)
+	seedRouter.Include("/example.kv.v1.KV/Get", "grpc.health.v1.Health")
+
+	// Only the seed implements Set.
+	seedKV := echoKVServer(t, "seed")
+	seedKV.SetFunc = func(context.Context, *kvproto.SetRequest) (*kvproto.SetResponse, error) {
+		return &kvproto.SetResponse{}, nil
+	}
+
+	_, seedNode := makeTestNodeWithRouter(t, log.With(l, "node", "seed"), &seedRouter, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, seedKV)
+		grpc_health_v1.RegisterHealthServer(s, newHealthServer(grpc_health_v1.HealthCheckResponse_NOT_SERVING))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+		grpc_health_v1.RegisterHealthServer(s, newHealthServer(grpc_health_v1.HealthCheckResponse_SERVING))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	peerCtx := WithClientKey(ctx, peerNode.cfg.ID)
+
+	// Included services are routed.
+	resp, err := kvproto.NewKVClient(clusterCC).Get(peerCtx, &kvproto.GetRequest{Key: "peer"})
+	require.NoError(t, err)
+	require.Equal(t, "peer", resp.GetValue())
+
+	// Excluded services are handled locally, even if included.
+	check, err := grpc_health_v1.NewHealthClient(clusterCC).Check(peerCtx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, check.GetStatus())
+
+	// Methods that aren't included are handled locally.
+	_, err = kvproto.NewKVClient(clusterCC).Set(peerCtx, &kvproto.SetRequest{Key: "peer"})
+	require.NoError(t, err)
+}
+
+func TestServiceName(t *testing.T) {
+	require.Equal(t, "example.kv.v1.KV", serviceName("/example.kv.v1.KV/Get"))
+	require.Equal(t, "grpc.health.v1.Health", serviceName("/grpc.health.v1.Health/Watch"))
+}
+
 func makeTestNode(t *testing.T, l log.Logger, reg func(s *grpc.Server)) (*grpc.Server, *Node) {
 	t.Helper()
+	return makeTestNodeWithRouter(t, l, &Router{}, reg)
+}
+
+func makeTestNodeWithRouter(t *testing.T, l log.Logger, router *Router, reg func(s *grpc.Server)) (*grpc.Server, *Node) {
+	t.Helper()
+	return makeTestNodeWithConfig(t, l, router, reg, nil)
+}
+
+// makeTestNodeWithConfig creates a test node, allowing its config to be
+// modified by configure before the node is created.
+func makeTestNodeWithConfig(t *testing.T, l log.Logger, router *Router, reg func(s *grpc.Server), configure func(c *Config)) (*grpc.Server, *Node) {
+	t.Helper()
 
 	lis, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
 
-	var router Router
 	srv := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(router.Unary()),
 		grpc.ChainStreamInterceptor(router.Stream()),
@@ -76,13 +194,18 @@ func makeTestNode(t *testing.T, l log.Logger, reg func(s *grpc.Server)) (*grpc.S
 		reg(srv)
 	}
 
-	n, err := New(Config{
+	cfg := Config{
 		ID:            id.NewGenerator(32).Get(lis.Addr().String()),
 		BroadcastAddr: lis.Addr().String(),
 		NumLeaves:     8,
 		NumNeighbors:  8,
 		Log:           l,
-	}, noopApplication{}, grpc.WithInsecure())
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	n, err := New(cfg, noopApplication{}, WithDialOptions(grpc.WithInsecure()))
 	n.Register(srv)
 	require.NoError(t, err)
 
@@ -109,3 +232,564 @@ func echoKVServer(t *testing.T, expect string) *kvserver.Func {
 type noopApplication struct{}
 
 func (noopApplication) PeersChanged(ps []Peer) {}
+
+func TestRouter_Stream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*3)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	// Use the health service's streaming Watch method, where the seed and
+	// the peer report different statuses.
+	newHealthServer := func(s grpc_health_v1.HealthCheckResponse_ServingStatus) *health.Server {
+		srv := health.NewServer()
+		srv.SetServingStatus("", s)
+		return srv
+	}
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		grpc_health_v1.RegisterHealthServer(s, newHealthServer(grpc_health_v1.HealthCheckResponse_NOT_SERVING))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		grpc_health_v1.RegisterHealthServer(s, newHealthServer(grpc_health_v1.HealthCheckResponse_SERVING))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+	clusterClient := grpc_health_v1.NewHealthClient(clusterCC)
+
+	tt := []struct {
+		key    id.ID
+		expect grpc_health_v1.HealthCheckResponse_ServingStatus
+	}{
+		{key: seedNode.cfg.ID, expect: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{key: peerNode.cfg.ID, expect: grpc_health_v1.HealthCheckResponse_SERVING},
+	}
+	for _, tc := range tt {
+		streamCtx, streamCancel := context.WithCancel(WithClientKey(ctx, tc.key))
+
+		stream, err := clusterClient.Watch(streamCtx, &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, resp.GetStatus())
+
+		streamCancel()
+	}
+}
+
+func TestRouter_StreamEndsEarly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		released = make(chan struct{}, 1)
+		mode     = make(chan string, 1)
+	)
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		registerBidiServer(s, func(grpc.ServerStream) error {
+			return status.Error(codes.Internal, "stream should have been forwarded")
+		})
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		registerBidiServer(s, func(ss grpc.ServerStream) error {
+			switch <-mode {
+			case "abort":
+				return status.Error(codes.Aborted, "ended early")
+			default:
+				<-ss.Context().Done()
+				released <- struct{}{}
+				return ss.Context().Err()
+			}
+		})
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+
+	t.Run("owner ends stream", func(t *testing.T) {
+		// The caller never finishes sending, but must still receive the
+		// error from the owner.
+		mode <- "abort"
+		cs, err := clusterCC.NewStream(WithClientKey(ctx, peerNode.cfg.ID), desc, bidiMethod)
+		require.NoError(t, err)
+		err = cs.RecvMsg(&emptypb.Empty{})
+		require.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	t.Run("caller cancels stream", func(t *testing.T) {
+		mode <- "wait"
+		streamCtx, streamCancel := context.WithCancel(WithClientKey(ctx, peerNode.cfg.ID))
+		cs, err := clusterCC.NewStream(streamCtx, desc, bidiMethod)
+		require.NoError(t, err)
+		require.NoError(t, cs.SendMsg(&emptypb.Empty{}))
+
+		// Give the stream time to be forwarded to the owner before canceling
+		// it.
+		time.Sleep(100 * time.Millisecond)
+		streamCancel()
+
+		select {
+		case <-released:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "forwarded stream was never canceled")
+		}
+	})
+}
+
+const bidiMethod = "/croissant.test.Bidi/Stream"
+
+// registerBidiServer registers a bidirectional streaming method at
+// bidiMethod which is handled by h.
+func registerBidiServer(s *grpc.Server, h func(ss grpc.ServerStream) error) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "croissant.test.Bidi",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Stream",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(_ interface{}, ss grpc.ServerStream) error {
+				return h(ss)
+			},
+		}},
+	}, struct{}{})
+}
+
+func TestNode_Drain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+	clusterClient := kvproto.NewKVClient(clusterCC)
+
+	get := func(key string) (string, error) {
+		resp, err := clusterClient.Get(
+			WithClientKey(ctx, seedNode.cfg.ID),
+			&kvproto.GetRequest{Key: key},
+		)
+		return resp.GetValue(), err
+	}
+
+	// While draining, requests for the seed's keys go to the peer.
+	seedNode.Drain()
+	require.True(t, seedNode.Draining())
+	val, err := get("peer")
+	require.NoError(t, err)
+	require.Equal(t, "peer", val)
+
+	// The seed is still a member of the cluster.
+	require.False(t, seedNode.IsSingleNode())
+	require.False(t, peerNode.IsSingleNode())
+
+	seedNode.Resume()
+	require.False(t, seedNode.Draining())
+	val, err = get("seed")
+	require.NoError(t, err)
+	require.Equal(t, "seed", val)
+}
+
+func TestNode_Broadcast(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes  []*Node
+		expect []string
+	)
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		_, n := makeTestNodeWithConfig(t, log.With(l, "node", i), &Router{}, func(s *grpc.Server) {
+			var kvFunc kvserver.Func
+			kvFunc.GetFunc = func(_ context.Context, gr *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+				return &kvproto.GetResponse{Value: name + "/" + gr.Key}, nil
+			}
+			kvproto.RegisterKVServer(s, &kvFunc)
+		}, func(c *Config) {
+			c.NumVirtualNodes = 2
+		})
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+		expect = append(expect, name+"/invalidate")
+	}
+
+	// Routing keys should be ignored by broadcasts.
+	bctx := WithClientKey(ctx, nodes[3].cfg.ID)
+
+	resps, err := nodes[2].Broadcast(bctx, "/example.kv.v1.KV/Get", &kvproto.GetRequest{Key: "invalidate"}, func() proto.Message {
+		return &kvproto.GetResponse{}
+	})
+	require.NoError(t, err)
+
+	var actual []string
+	for _, resp := range resps {
+		require.NoError(t, resp.Err)
+		actual = append(actual, resp.Reply.(*kvproto.GetResponse).Value)
+	}
+	require.ElementsMatch(t, expect, actual, "every node should respond exactly once")
+}
+
+func TestNode_Metrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		seedReg = prometheus.NewRegistry()
+		peerReg = prometheus.NewRegistry()
+	)
+	_, seedNode := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	}, func(c *Config) { c.Registerer = seedReg })
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNodeWithConfig(t, log.With(l, "node", "peer"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	}, func(c *Config) { c.Registerer = peerReg })
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+	clusterClient := kvproto.NewKVClient(clusterCC)
+
+	_, err = clusterClient.Get(WithClientKey(ctx, seedNode.cfg.ID), &kvproto.GetRequest{Key: "seed"})
+	require.NoError(t, err)
+	_, err = clusterClient.Get(WithClientKey(ctx, peerNode.cfg.ID), &kvproto.GetRequest{Key: "peer"})
+	require.NoError(t, err)
+
+	var (
+		seed = seedNode.controller.metrics
+		peer = peerNode.controller.metrics
+	)
+	require.Equal(t, 1.0, testutil.ToFloat64(seed.routedRequestsTotal.WithLabelValues(outcomeLocal)))
+	require.Equal(t, 1.0, testutil.ToFloat64(seed.routedRequestsTotal.WithLabelValues(outcomeForwarded)))
+	require.Equal(t, 1.0, testutil.ToFloat64(peer.routedRequestsTotal.WithLabelValues(outcomeLocal)))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(seed.joinsHandledTotal))
+	require.Equal(t, 1.0, testutil.ToFloat64(peer.leaves))
+	require.Greater(t, testutil.ToFloat64(peer.routingFill), 0.0)
+	require.Greater(t, testutil.ToFloat64(peer.hellosSentTotal), 0.0)
+	require.Greater(t, testutil.ToFloat64(seed.hellosReceivedTotal), 0.0)
+
+	// The peer handled the forwarded request after one hop.
+	families, err := peerReg.Gather()
+	require.NoError(t, err)
+	var hops *dto.Histogram
+	for _, f := range families {
+		if f.GetName() == "croissant_request_hops" {
+			hops = f.GetMetric()[0].GetHistogram()
+		}
+	}
+	require.NotNil(t, hops)
+	require.Equal(t, uint64(1), hops.GetSampleCount())
+	require.Equal(t, 1.0, hops.GetSampleSum())
+
+	// The seed dialed the peer to forward the request.
+	stats := seedNode.PoolStats()
+	require.Greater(t, stats.Dials, 0)
+	var found bool
+	for _, c := range stats.Conns {
+		found = found || c.Addr == peerNode.cfg.BroadcastAddr
+	}
+	require.True(t, found, "seed should have a connection to the peer")
+
+	families, err = seedReg.Gather()
+	require.NoError(t, err)
+	var dials float64
+	for _, f := range families {
+		if f.GetName() == "croissant_pool_dials_total" {
+			dials = f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	require.Equal(t, float64(seedNode.PoolStats().Dials), dials)
+}
+
+func TestNode_Tracing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	tracer := &fakeTracer{}
+	_, seedNode := makeTestNodeWithConfig(t, log.With(l, "node", "seed"), &Router{}, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	}, func(c *Config) { c.Tracer = tracer })
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	_, err = kvproto.NewKVClient(clusterCC).Get(WithClientKey(ctx, peerNode.cfg.ID), &kvproto.GetRequest{Key: "peer"})
+	require.NoError(t, err)
+
+	spans := tracer.Spans()
+	require.Len(t, spans, 2)
+
+	// Spans are recorded as they end, so the child comes first.
+	invoke, forward := spans[0], spans[1]
+	require.Equal(t, "croissant.Router/ForwardUnary", forward.name)
+	require.Equal(t, "", forward.parent)
+	require.Equal(t, outcomeForwarded, forward.attrs[attrOutcome])
+	require.Equal(t, peerNode.cfg.ID.String(), forward.attrs[attrKey])
+
+	require.Equal(t, "croissant.Client/Invoke", invoke.name)
+	require.Equal(t, forward.name, invoke.parent)
+	require.Equal(t, peerNode.cfg.BroadcastAddr, invoke.attrs[attrNextHop])
+	require.Equal(t, 1, invoke.attrs[attrAttempts])
+	require.NoError(t, invoke.err)
+}
+
+type fakeTracer struct {
+	mut   sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpanKey struct{}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &fakeSpan{t: t, name: name, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		span.parent = parent.name
+	}
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (t *fakeTracer) Spans() []*fakeSpan {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return append([]*fakeSpan(nil), t.spans...)
+}
+
+type fakeSpan struct {
+	t      *fakeTracer
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+
+func (s *fakeSpan) End(err error) {
+	s.err = err
+
+	s.t.mut.Lock()
+	defer s.t.mut.Unlock()
+	s.t.spans = append(s.t.spans, s)
+}
+
+func TestRouter_MultiHop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	newCluster := func(maxHops int) []*Node {
+		// With two leaves, nodes[0] can only know nodes[2] from its routing
+		// table.
+		var nodes []*Node
+		for i := 1; i <= 4; i++ {
+			var (
+				name   = fmt.Sprintf("node-%d", i)
+				nodeID = id.ID{Low: uint64(i) << 28}
+			)
+			_, n := makeTestNodeWithConfig(t, log.With(l, "node", name), &Router{}, func(s *grpc.Server) {
+				var kvFunc kvserver.Func
+				kvFunc.GetFunc = func(context.Context, *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+					return &kvproto.GetResponse{Value: name}, nil
+				}
+				kvproto.RegisterKVServer(s, &kvFunc)
+			}, func(c *Config) {
+				c.ID = nodeID
+				c.NumLeaves = 2
+				c.RepairInterval = -1
+				c.MaxHops = maxHops
+			})
+
+			var joinAddrs []string
+			if len(nodes) > 0 {
+				joinAddrs = []string{nodes[0].cfg.BroadcastAddr}
+			}
+			require.NoError(t, n.Join(ctx, joinAddrs))
+			nodes = append(nodes, n)
+		}
+
+		// Forget about nodes[2] so requests for keys it owns go through
+		// nodes[1], its predecessor.
+		var (
+			c    = nodes[0].controller
+			lost = nodes[2].controller.state.Node
+		)
+		c.state.SetHealth(lost, api.Unhealthy)
+		c.state.ReplaceRoute(lost, nil)
+		c.state.SetHealth(lost, api.Healthy)
+		return nodes
+	}
+
+	key := id.ID{Low: 0x2FFFFFFF}
+	get := func(n *Node) (*kvproto.GetResponse, error) {
+		return kvproto.NewKVClient(NewClient(n)).Get(WithClientKey(ctx, key), &kvproto.GetRequest{})
+	}
+
+	t.Run("forwarded to owner", func(t *testing.T) {
+		nodes := newCluster(0)
+		resp, err := get(nodes[0])
+		require.NoError(t, err)
+		require.Equal(t, "node-3", resp.GetValue())
+	})
+
+	t.Run("max hops exceeded", func(t *testing.T) {
+		nodes := newCluster(1)
+		_, err := get(nodes[0])
+		require.Equal(t, codes.Aborted, status.Code(err))
+		require.True(t, errors.Is(err, ErrMaxHops), "error from peer should wrap ErrMaxHops")
+	})
+}
+
+func TestRouter_ForwardHook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		mut   sync.Mutex
+		infos []ForwardInfo
+	)
+	router := &Router{}
+	router.SetForwardHook(func(_ context.Context, info ForwardInfo, next Peer) (Peer, error) {
+		mut.Lock()
+		defer mut.Unlock()
+		infos = append(infos, info)
+
+		if info.Method == "/example.kv.v1.KV/Set" {
+			return Peer{}, status.Errorf(codes.PermissionDenied, "writes not allowed")
+		}
+		return next, nil
+	})
+
+	_, seedNode := makeTestNodeWithRouter(t, log.With(l, "node", "seed"), router, func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+		grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "peer"))
+		grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	clusterCC, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer clusterCC.Close()
+
+	peerCtx := WithClientKey(ctx, peerNode.cfg.ID)
+
+	_, err = kvproto.NewKVClient(clusterCC).Get(peerCtx, &kvproto.GetRequest{Key: "peer"})
+	require.NoError(t, err)
+
+	_, err = kvproto.NewKVClient(clusterCC).Set(peerCtx, &kvproto.SetRequest{Key: "peer"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := grpc_health_v1.NewHealthClient(clusterCC).Watch(peerCtx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	mut.Lock()
+	defer mut.Unlock()
+	require.Equal(t, []ForwardInfo{
+		{Method: "/example.kv.v1.KV/Get", Key: peerNode.cfg.ID, Attempt: 1},
+		{Method: "/example.kv.v1.KV/Set", Key: peerNode.cfg.ID, Attempt: 1},
+		{Method: "/grpc.health.v1.Health/Watch", Key: peerNode.cfg.ID, Attempt: 1},
+	}, infos)
+}
+
+func TestRouter_PropagatesMetadata(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	_, seedNode := makeTestNode(t, log.With(l, "node", "seed"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, echoKVServer(t, "seed"))
+	})
+	require.NoError(t, seedNode.Join(ctx, nil))
+
+	var (
+		gotMD       metadata.MD
+		gotDeadline bool
+		gotRoute    RouteInfo
+	)
+	var kvFunc kvserver.Func
+	kvFunc.GetFunc = func(ctx context.Context, _ *kvproto.GetRequest) (*kvproto.GetResponse, error) {
+		gotMD, _ = metadata.FromIncomingContext(ctx)
+		_, gotDeadline = ctx.Deadline()
+		gotRoute, _ = RouteInfoFromContext(ctx)
+		return &kvproto.GetResponse{Value: "peer"}, nil
+	}
+	_, peerNode := makeTestNode(t, log.With(l, "node", "peer"), func(s *grpc.Server) {
+		kvproto.RegisterKVServer(s, &kvFunc)
+	})
+	require.NoError(t, peerNode.Join(ctx, []string{seedNode.cfg.BroadcastAddr}))
+
+	cc, err := grpc.Dial(seedNode.cfg.BroadcastAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer cc.Close()
+
+	reqCtx := metadata.AppendToOutgoingContext(WithClientKey(ctx, peerNode.cfg.ID), "x-tenant", "acme")
+	_, err = kvproto.NewKVClient(cc).Get(reqCtx, &kvproto.GetRequest{})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"acme"}, gotMD.Get("x-tenant"))
+	require.True(t, gotDeadline, "deadline was not propagated")
+	require.Equal(t, RouteInfo{
+		Origin: seedNode.cfg.BroadcastAddr,
+		Hops:   1,
+		Path:   []string{seedNode.cfg.BroadcastAddr},
+	}, gotRoute)
+}
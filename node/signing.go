@@ -0,0 +1,54 @@
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrInvalidSignature is returned by DescriptorSigners when a signature
+// doesn't match its message.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// DescriptorSigner signs and verifies descriptors of nodes. Messages are
+// canonical encodings of a node's ID, address, and labels.
+//
+// Signers using a shared cluster key, such as NewHMACSigner, prevent nodes
+// outside the cluster from injecting descriptors into routing states, but
+// any node holding the key can sign descriptors for arbitrary IDs. To
+// protect against compromised nodes, use an asymmetric scheme where each
+// node is only given signatures for its own descriptors, issued by an
+// authority whose public key is used by Verify.
+type DescriptorSigner interface {
+	// Sign returns the signature of msg, the descriptor of the local node.
+	Sign(msg []byte) ([]byte, error)
+
+	// Verify returns an error if sig isn't a valid signature of msg, the
+	// descriptor of a peer. sig is empty if the peer didn't sign its
+	// descriptor.
+	Verify(msg, sig []byte) error
+}
+
+// NewHMACSigner returns a DescriptorSigner that signs descriptors with
+// HMAC-SHA256 using key, a secret shared by every node in the cluster.
+func NewHMACSigner(key []byte) DescriptorSigner {
+	return hmacSigner{key: append([]byte(nil), key...)}
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+func (s hmacSigner) Sign(msg []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+func (s hmacSigner) Verify(msg, sig []byte) error {
+	expect, _ := s.Sign(msg)
+	if !hmac.Equal(expect, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
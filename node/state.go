@@ -0,0 +1,214 @@
+package node
+
+import (
+	"time"
+
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// Health is the health of a peer as seen by the local node.
+type Health uint
+
+const (
+	// Healthy peers are used for routing.
+	Healthy Health = iota
+	// Unhealthy peers failed a health check and aren't used for routing.
+	Unhealthy
+	// Dead peers are being removed from the State.
+	Dead
+)
+
+// String returns the name of h.
+func (h Health) String() string {
+	return api.Health(h).String()
+}
+
+// PeerHealth is the health of a peer as seen by the failure detector of the
+// local node.
+type PeerHealth struct {
+	Health Health
+
+	// LastSeen is the last time a health check of the peer succeeded. Zero
+	// if the peer hasn't been checked successfully yet.
+	LastSeen time.Time
+}
+
+// PeerHealth returns the health of peer as seen by the failure detector of
+// n, so applications can avoid unhealthy peers without probing them again.
+// ok is false if n doesn't know about peer. Peers are matched by ID and
+// address.
+//
+// With virtual nodes, the worst health and latest LastSeen across virtual
+// nodes are returned.
+func (n *Node) PeerHealth(peer Peer) (h PeerHealth, ok bool) {
+	for _, vnode := range n.vnodes {
+		vh, found := vnode.peerHealth(peer.descriptor())
+		if !found {
+			continue
+		}
+		if !ok || vh.Health > h.Health {
+			h.Health = vh.Health
+		}
+		if vh.LastSeen.After(h.LastSeen) {
+			h.LastSeen = vh.LastSeen
+		}
+		ok = true
+	}
+	return h, ok
+}
+
+// peerHealth returns the health of d. ok is false if d isn't in the state.
+func (c *controller) peerHealth(d api.Descriptor) (h PeerHealth, ok bool) {
+	known, status, ok := c.state.Lookup(d.ID, d.Addr)
+	if !ok {
+		return PeerHealth{}, false
+	}
+	h.Health = Health(status)
+	h.LastSeen, _ = c.health.LastSeen(known)
+	return h, true
+}
+
+// State is a snapshot of the routing state of a node. Changing a State has no
+// effect on the node it was taken from.
+type State struct {
+	// Node is the node the State belongs to.
+	Node Peer
+
+	// Size is the bit length of IDs, and Base is the base digits of IDs are
+	// represented in for routing.
+	Size, Base int
+
+	// Predecessors and Successors are the leaves of the node, ordered from
+	// closest to farthest away from the node.
+	Predecessors, Successors []Peer
+
+	// Neighbors are peers that are geographically close to the node.
+	Neighbors []Peer
+
+	// Routing is the routing table of the node. There is one row per digit in
+	// an ID and Base columns per row. Empty entries are nil.
+	Routing [][]*Peer
+
+	// Health holds the health of peers whose health is being tracked. Peers
+	// that are not in Health are healthy.
+	Health map[Peer]Health
+
+	// Version increases every time the State changes.
+	Version uint64
+
+	// LastUpdated is the last time the State changed.
+	LastUpdated time.Time
+}
+
+// DiffStates returns a human-readable diff between the views of the cluster
+// held by a and b, such as the States of two nodes. Each State's own node is
+// considered part of its leaves and routing table, so two leaves that know
+// about each other don't differ.
+//
+// Peers only known to a are prefixed with "-" and peers only known to b are
+// prefixed with "+". Peers that both States track but disagree on the health
+// of are prefixed with "~". An empty string is returned when there are no
+// differences.
+func DiffStates(a, b State) string {
+	return api.DiffStates(a.apiState(), b.apiState())
+}
+
+// apiState converts s back into an api.State.
+func (s State) apiState() *api.State {
+	res := &api.State{
+		Node: s.Node.descriptor(),
+		Size: s.Size,
+		Base: s.Base,
+
+		Predecessors: &api.DescriptorSet{Descriptors: peersToDescriptors(s.Predecessors), KeepBiggest: true},
+		Successors:   &api.DescriptorSet{Descriptors: peersToDescriptors(s.Successors)},
+		Neighbors:    &api.DescriptorSet{Descriptors: peersToDescriptors(s.Neighbors)},
+
+		Routing:  make([][]*api.Descriptor, len(s.Routing)),
+		Statuses: make(map[api.Descriptor]api.Health, len(s.Health)),
+
+		Version:     s.Version,
+		LastUpdated: s.LastUpdated,
+	}
+
+	for row := range s.Routing {
+		res.Routing[row] = make([]*api.Descriptor, len(s.Routing[row]))
+		for col, ent := range s.Routing[row] {
+			if ent == nil {
+				continue
+			}
+			d := ent.descriptor()
+			res.Routing[row][col] = &d
+		}
+	}
+
+	for p, h := range s.Health {
+		res.Statuses[p.descriptor()] = api.Health(h)
+	}
+
+	return res
+}
+
+// peersToDescriptors converts peers into descriptors, retaining order.
+func peersToDescriptors(peers []Peer) []api.Descriptor {
+	ds := make([]api.Descriptor, len(peers))
+	for i, p := range peers {
+		ds[i] = p.descriptor()
+	}
+	return ds
+}
+
+// stateSnapshot converts s into a State.
+func stateSnapshot(s *api.State) State {
+	s = s.Clone()
+
+	res := State{
+		Node: peerFromDescriptor(s.Node),
+		Size: s.Size,
+		Base: s.Base,
+
+		Predecessors: descriptorsToPeers(closestFirst(s.Predecessors)),
+		Successors:   descriptorsToPeers(closestFirst(s.Successors)),
+		Neighbors:    descriptorsToPeers(s.Neighbors.Descriptors),
+
+		Routing: make([][]*Peer, len(s.Routing)),
+		Health:  make(map[Peer]Health, len(s.Statuses)),
+
+		Version:     s.Version,
+		LastUpdated: s.LastUpdated,
+	}
+
+	for row := range s.Routing {
+		res.Routing[row] = make([]*Peer, len(s.Routing[row]))
+		for col, ent := range s.Routing[row] {
+			if ent == nil {
+				continue
+			}
+			p := peerFromDescriptor(*ent)
+			res.Routing[row][col] = &p
+		}
+	}
+
+	for d, h := range s.Statuses {
+		res.Health[peerFromDescriptor(d)] = Health(h)
+	}
+
+	return res
+}
+
+// closestFirst returns the descriptors in set ordered from closest to the
+// node to farthest away.
+func closestFirst(set *api.DescriptorSet) []api.Descriptor {
+	res := make([]api.Descriptor, len(set.Descriptors))
+	copy(res, set.Descriptors)
+	if !set.KeepBiggest {
+		return res
+	}
+
+	// Predecessors keep the biggest descriptors closest to the node at the
+	// end of the set.
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo details which identify errors
+// of the package in status errors sent between nodes.
+const errorDomain = "croissant"
+
+// statusReasons are the ErrorInfo reasons of the errors of the package which
+// are returned as status errors.
+var statusReasons = map[error]string{
+	ErrNoRoute:          "NO_ROUTE",
+	ErrRoutingLoop:      "ROUTING_LOOP",
+	ErrMaxHops:          "MAX_HOPS",
+	ErrRetriesExhausted: "RETRIES_EXHAUSTED",
+}
+
+// statusError is a status error which wraps an error of the package, so
+// callers can check for the error with errors.Is.
+type statusError struct {
+	s   *status.Status
+	err error
+}
+
+func (e *statusError) Error() string              { return e.s.Err().Error() }
+func (e *statusError) GRPCStatus() *status.Status { return e.s }
+func (e *statusError) Unwrap() error              { return e.err }
+
+// statusErrorf returns a status error with code c and a message formatted
+// from format and args which wraps err. err is attached to the status as
+// ErrorInfo, so it's still identified after being sent to another node.
+func statusErrorf(err error, c codes.Code, format string, args ...interface{}) error {
+	s := status.Newf(c, format, args...)
+	if ds, detailErr := s.WithDetails(&errdetails.ErrorInfo{
+		Reason: statusReasons[err],
+		Domain: errorDomain,
+	}); detailErr == nil {
+		s = ds
+	}
+	return &statusError{s: s, err: err}
+}
+
+// fromStatus returns err wrapping the error of the package identified by the
+// ErrorInfo details of err's status, if any. Used for errors returned by
+// peers.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*statusError); ok {
+		return err
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, d := range s.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorDomain {
+			continue
+		}
+		for sentinel, reason := range statusReasons {
+			if info.Reason == reason {
+				return &statusError{s: s, err: sentinel}
+			}
+		}
+	}
+	return err
+}
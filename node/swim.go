@@ -0,0 +1,32 @@
+package node
+
+import (
+	"context"
+
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/internal/health"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (c *controller) Ping(ctx context.Context, p api.Ping) (map[api.Descriptor]api.Health, error) {
+	if swim, ok := c.health.(*health.SWIM); ok {
+		return swim.HandlePing(ctx, c.state.Node, p)
+	}
+
+	// Nodes that don't use SWIM still help with indirect probes.
+	if p.Target != nil && *p.Target != c.state.Node {
+		if err := health.Probe(ctx, c.pool, *p.Target); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "target %s unreachable: %s", p.Target.Addr, err)
+		}
+	}
+	return nil, nil
+}
+
+// prioritizeProbes informs the SWIM detector, if used, about the health of
+// peers as seen by another peer.
+func (c *controller) prioritizeProbes(peerState *api.State) {
+	if swim, ok := c.health.(*health.SWIM); ok {
+		swim.Prioritize(peerState.Statuses)
+	}
+}
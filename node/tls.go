@@ -0,0 +1,109 @@
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TLSConfig configures TLS for connections between nodes.
+type TLSConfig struct {
+	// Client is used when connecting to peers. For mutual TLS, Certificates
+	// must hold the certificate of the node. Must be set.
+	Client *tls.Config
+
+	// Server is used by ServerOption to accept connections from peers. For
+	// mutual TLS, ClientAuth should be tls.RequireAndVerifyClientCert and
+	// ClientCAs should be set.
+	Server *tls.Config
+
+	// VerifyNodeID, if true, requires peers to present a certificate bound
+	// to the ID they claim when they join, greet, or say goodbye to the node.
+	// Certificates are bound to IDs through URI SANs created by NodeIDURIs,
+	// which prevents peers from taking over another node's position in the
+	// ring. Requires mutual TLS.
+	VerifyNodeID bool
+}
+
+// ServerOption returns a grpc.ServerOption that enables TLS on the gRPC
+// server the node is registered to.
+func (c *TLSConfig) ServerOption() grpc.ServerOption {
+	return grpc.Creds(credentials.NewTLS(c.Server))
+}
+
+// NodeIDURIs returns the URI SANs the certificate of a node with cfg must
+// hold when peers verify node IDs. There is one URI for each virtual node.
+func NodeIDURIs(cfg Config) []*url.URL {
+	var (
+		size   = cfg.IDSize
+		vnodes = cfg.NumVirtualNodes
+	)
+	if size == 0 {
+		size = 32
+	}
+	if vnodes == 0 {
+		vnodes = 1
+	}
+
+	gen := id.NewGenerator(size)
+	uris := make([]*url.URL, 0, vnodes)
+	for i := 0; i < vnodes; i++ {
+		uris = append(uris, nodeIDURI(VirtualNodeID(gen, cfg.ID, i)))
+	}
+	return uris
+}
+
+// VirtualNodeID returns the ID of the i'th virtual node of the node with ID
+// nodeID, where gen generates IDs of the cluster's IDSize. The first virtual
+// node uses nodeID. See Config.NumVirtualNodes.
+func VirtualNodeID(gen id.Generator, nodeID id.ID, i int) id.ID {
+	if i == 0 {
+		return nodeID
+	}
+	return gen.Get(fmt.Sprintf("%s/%d", nodeID, i))
+}
+
+func nodeIDURI(nodeID id.ID) *url.URL {
+	return &url.URL{Scheme: "croissant", Host: "node", Path: "/" + nodeID.String()}
+}
+
+// verifiesPeers returns true if the identities of peers are verified
+// through mTLS.
+func (n *Node) verifiesPeers() bool {
+	return n.cfg.TLS != nil && n.cfg.TLS.VerifyNodeID
+}
+
+// verifyPeer returns an error if the TLS certificate of the peer that sent
+// the request in ctx isn't bound to the ID of d. Always returns nil if node
+// IDs aren't verified.
+func (n *Node) verifyPeer(ctx context.Context, d api.Descriptor) error {
+	if !n.verifiesPeers() {
+		return nil
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "no peer information")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return status.Errorf(codes.Unauthenticated, "peer did not present a certificate")
+	}
+
+	expect := nodeIDURI(d.ID).String()
+	for _, uri := range info.State.PeerCertificates[0].URIs {
+		if uri.String() == expect {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "certificate of peer %s is not valid for node ID %s", p.Addr, d.ID)
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestNodeIDURIs(t *testing.T) {
+	cfg := Config{ID: id.ID{Low: 1234}, NumVirtualNodes: 3}
+	uris := NodeIDURIs(cfg)
+	require.Len(t, uris, 3)
+	require.Equal(t, "croissant://node/1234", uris[0].String())
+
+	gen := id.NewGenerator(32)
+	require.Equal(t, nodeIDURI(gen.Get("1234/2")).String(), uris[2].String())
+}
+
+func TestNode_TLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	ca := newTestCA(t)
+
+	seed := makeTLSTestNode(t, log.With(l, "node", "seed"), ca, id.ID{Low: 1 << 28}, nil)
+	require.NoError(t, seed.Join(ctx, nil))
+
+	peer := makeTLSTestNode(t, log.With(l, "node", "peer"), ca, id.ID{Low: 2 << 28}, nil)
+	require.NoError(t, peer.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+	require.False(t, seed.IsSingleNode())
+
+	// A node with a valid certificate for a different ID must not be able to
+	// take over another position in the ring.
+	impostorCert := NodeIDURIs(Config{ID: id.ID{Low: 4 << 28}})
+	impostor := makeTLSTestNode(t, log.With(l, "node", "impostor"), ca, id.ID{Low: 3 << 28}, impostorCert)
+	require.Error(t, impostor.Join(ctx, []string{seed.cfg.BroadcastAddr}))
+}
+
+// makeTLSTestNode creates a test node which uses mutual TLS with node ID
+// verification. If uris is nil, the certificate of the node is bound to
+// nodeID.
+func makeTLSTestNode(t *testing.T, l log.Logger, ca *testCA, nodeID id.ID, uris []*url.URL) *Node {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	cfg := Config{
+		ID:             nodeID,
+		BroadcastAddr:  lis.Addr().String(),
+		NumLeaves:      8,
+		NumNeighbors:   8,
+		RepairInterval: -1,
+		Log:            l,
+	}
+	if uris == nil {
+		uris = NodeIDURIs(cfg)
+	}
+	cert := ca.issue(t, uris)
+
+	cfg.TLS = &TLSConfig{
+		Client: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      ca.pool,
+		},
+		Server: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool,
+		},
+		VerifyNodeID: true,
+	}
+
+	n, err := New(cfg, noopApplication{})
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(cfg.TLS.ServerOption())
+	n.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return n
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "croissant test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue issues a certificate valid for 127.0.0.1 and uris.
+func (ca *testCA) issue(t *testing.T, uris []*url.URL) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "croissant test node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		URIs:         uris,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
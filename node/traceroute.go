@@ -0,0 +1,84 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RouteHop is a hop in the route of a key.
+type RouteHop struct {
+	Peer Peer
+
+	// Latency is the time taken by the previous hop to reach Peer and
+	// receive its response, excluding the time Peer spent tracing the rest
+	// of the route. Zero for the first hop.
+	Latency time.Duration
+}
+
+// TraceRoute records the route requests for key take through the cluster,
+// starting from the local node. The last hop is the node closest to key.
+// Virtual nodes of the same node may appear as separate hops with no
+// latency. Routes longer than Config.MaxHops fail with Aborted.
+func (n *Node) TraceRoute(ctx context.Context, key id.ID) ([]RouteHop, error) {
+	hops, err := n.controller.TraceRoute(ctx, key, n.cfg.MaxHops+1)
+	if err != nil {
+		return nil, err
+	}
+
+	route := make([]RouteHop, len(hops))
+	for i, h := range hops {
+		route[i] = RouteHop{Peer: peerFromDescriptor(h.Node), Latency: h.RTT}
+
+		// RTTs include the rest of the route, so subtract the RTT of the next
+		// hop to get the latency of this hop.
+		if i+1 < len(hops) {
+			route[i].Latency -= hops[i+1].RTT
+		}
+		if route[i].Latency < 0 {
+			route[i].Latency = 0
+		}
+	}
+	return route, nil
+}
+
+// TraceRoute implements api.Node.
+func (c *controller) TraceRoute(ctx context.Context, key id.ID, maxHops int) ([]api.TraceHop, error) {
+	hops := []api.TraceHop{{Node: c.state.Node}}
+
+	next, ok := api.NextHop(c.routeState(key), key)
+	if !ok {
+		return nil, statusErrorf(ErrNoRoute, codes.Unavailable, "%s %s", ErrNoRoute, key)
+	}
+	if c.isLocal(next) {
+		if next != c.state.Node {
+			hops = append(hops, api.TraceHop{Node: next})
+		}
+		return hops, nil
+	}
+
+	if maxHops <= 1 {
+		return nil, status.Errorf(codes.Aborted, "route to %s is longer than the maximum number of hops", key)
+	}
+
+	cc, err := c.pool.GetReady(ctx, next.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to next hop %s: %w", next.Addr, err)
+	}
+
+	start := time.Now()
+	rest, err := c.nodeClient(cc).TraceRoute(withTarget(ctx, next), key, maxHops-1)
+	if err != nil {
+		return nil, err
+	} else if len(rest) == 0 {
+		return nil, fmt.Errorf("next hop %s returned an empty route", next.Addr)
+	}
+	rest[0].RTT = time.Since(start)
+
+	return append(hops, rest...), nil
+}
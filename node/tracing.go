@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Tracer creates spans for requests routed through the cluster. Tracers are
+// usually thin adapters around a tracing library; the node/otel package
+// provides one for OpenTelemetry.
+//
+// Spans are created from the context of the request being routed. Tracers
+// which also implement TracePropagator send the trace context along with
+// requests forwarded to peers, so the spans of every hop belong to the same
+// trace.
+type Tracer interface {
+	// Start starts a new span named name as a child of any span in ctx. The
+	// returned context holds the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span created by a Tracer.
+type Span interface {
+	// SetAttribute records an attribute of the span. value is a string,
+	// bool, or int.
+	SetAttribute(key string, value interface{})
+
+	// End completes the span. err is the error of the traced operation, if
+	// any.
+	End(err error)
+}
+
+// TracePropagator is implemented by Tracers that propagate trace context
+// between nodes. Inject is called with the outgoing metadata of every
+// request forwarded to a peer. Extract is called with the metadata of every
+// request received by a Router, and returns a context holding the remote
+// span.
+type TracePropagator interface {
+	Inject(ctx context.Context, md metadata.MD)
+	Extract(ctx context.Context, md metadata.MD) context.Context
+}
+
+// injectTrace injects the trace context of ctx into md if the node's Tracer
+// is a TracePropagator.
+func (c *controller) injectTrace(ctx context.Context, md metadata.MD) {
+	if p, ok := c.tracer.(TracePropagator); ok {
+		p.Inject(ctx, md)
+	}
+}
+
+// extractTrace returns ctx with the trace context of the incoming request
+// in ctx if the node's Tracer is a TracePropagator.
+func (c *controller) extractTrace(ctx context.Context) context.Context {
+	p, ok := c.tracer.(TracePropagator)
+	if !ok {
+		return ctx
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return p.Extract(ctx, md)
+}
+
+// extractTraceStream is extractTrace for a server stream.
+func (c *controller) extractTraceStream(ss grpc.ServerStream) grpc.ServerStream {
+	if _, ok := c.tracer.(TracePropagator); !ok {
+		return ss
+	}
+	return &contextStream{ServerStream: ss, ctx: c.extractTrace(ss.Context())}
+}
+
+// contextStream overrides the context of a grpc.ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+// Attributes recorded on spans of routed requests.
+const (
+	attrMethod   = "croissant.method"   // Full name of the gRPC method.
+	attrKey      = "croissant.key"      // Routing key of the request.
+	attrNextHop  = "croissant.next_hop" // Address of the chosen next hop.
+	attrAttempts = "croissant.attempts" // Number of attempts to send the request.
+	attrOutcome  = "croissant.outcome"  // Outcome of routing the request.
+	attrHops     = "croissant.hops"     // Hops taken by the request so far.
+	attrHedges   = "croissant.hedges"   // Number of hedged requests sent.
+)
+
+// endSpan ends span. ErrSelfRouting isn't treated as an error, since it
+// only signals that the request should be handled locally.
+func endSpan(span Span, err error) {
+	if errors.Is(err, ErrSelfRouting) {
+		err = nil
+	}
+	span.End(err)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End(error)                        {}
@@ -0,0 +1,155 @@
+package node
+
+import (
+	"context"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+)
+
+// vnodeMux implements api.Node by forwarding calls to the virtual node they
+// target. Calls that don't target a known virtual node are handled by the
+// first virtual node.
+type vnodeMux struct {
+	n *Node
+}
+
+func (m *vnodeMux) get(ctx context.Context) *controller {
+	target, ok := extractTarget(ctx)
+	if !ok {
+		return m.n.controller
+	}
+	for _, vnode := range m.n.vnodes {
+		if vnode.state.Node.ID == target {
+			return vnode
+		}
+	}
+	return m.n.controller
+}
+
+// Join is sent by the joiner or, when propagated, by the last node in the
+// path of the join. The path is set by the sender, so a join is only trusted
+// to have been authorized by an earlier hop when the identity of the last
+// node in the path was verified through mTLS.
+func (m *vnodeMux) Join(ctx context.Context, j api.Join) error {
+	sender := j.Joiner
+	if len(j.Path) > 0 {
+		sender = j.Path[len(j.Path)-1]
+	}
+	if err := m.n.verifyPeer(ctx, sender); err != nil {
+		return err
+	}
+	if len(j.Path) == 0 || !m.n.verifiesPeers() {
+		if err := m.n.authorizeJoin(ctx, j.Joiner); err != nil {
+			return err
+		}
+	}
+	return m.get(ctx).Join(ctx, j)
+}
+
+func (m *vnodeMux) NodeHello(ctx context.Context, h api.Hello) error {
+	if err := m.n.verifyPeer(ctx, h.Initiator); err != nil {
+		return err
+	}
+	return m.get(ctx).NodeHello(ctx, h)
+}
+
+func (m *vnodeMux) NodeGoodbye(ctx context.Context, leaver api.Descriptor) error {
+	if err := m.n.verifyPeer(ctx, leaver); err != nil {
+		return err
+	}
+	return m.get(ctx).NodeGoodbye(ctx, leaver)
+}
+
+func (m *vnodeMux) GossipBroken(ctx context.Context, initiator api.Descriptor, err error) {
+	m.get(ctx).GossipBroken(ctx, initiator, err)
+}
+
+func (m *vnodeMux) GetState(ctx context.Context) (*api.State, error) {
+	return m.get(ctx).GetState(ctx)
+}
+
+func (m *vnodeMux) Ping(ctx context.Context, p api.Ping) (map[api.Descriptor]api.Health, error) {
+	return m.get(ctx).Ping(ctx, p)
+}
+
+func (m *vnodeMux) Broadcast(ctx context.Context, b api.Broadcast) ([]api.BroadcastResult, error) {
+	return m.get(ctx).Broadcast(ctx, b)
+}
+
+func (m *vnodeMux) TraceRoute(ctx context.Context, key id.ID, maxHops int) ([]api.TraceHop, error) {
+	return m.get(ctx).TraceRoute(ctx, key, maxHops)
+}
+
+func (m *vnodeMux) RingChecksum(ctx context.Context) (api.RingView, error) {
+	return m.get(ctx).RingChecksum(ctx)
+}
+
+// Evict evicts evictee from every virtual node, since Broadcast only
+// invokes Evict once per node.
+func (m *vnodeMux) Evict(ctx context.Context, evictee api.Descriptor) error {
+	for _, vnode := range m.n.vnodes {
+		if err := vnode.Evict(ctx, evictee); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vnodeApp wraps the Application for a virtual node when a node has more than
+// one virtual node, hiding the other virtual nodes of the local node from
+// app.
+type vnodeApp struct {
+	app     Application
+	n       *Node
+	primary bool // Only the first virtual node reports single-node and replica changes.
+}
+
+// PeersChanged invokes PeersChanged on the wrapped Application with the
+// leaves of every virtual node. Only one peer is reported per address.
+func (a *vnodeApp) PeersChanged(_ []Peer) {
+	a.app.PeersChanged(a.n.leaves())
+}
+
+func (a *vnodeApp) TopologyChanged(peers []PeerInfo) {
+	if w, ok := a.app.(TopologyWatcher); ok && a.primary {
+		w.TopologyChanged(peers)
+	}
+}
+
+func (a *vnodeApp) SingleNodeChanged(single bool) {
+	if w, ok := a.app.(SingleNodeWatcher); ok && a.primary {
+		w.SingleNodeChanged(single)
+	}
+}
+
+func (a *vnodeApp) IsolationChanged(isolated bool) {
+	if w, ok := a.app.(IsolationWatcher); ok && a.primary {
+		w.IsolationChanged(isolated)
+	}
+}
+
+func (a *vnodeApp) ReplicasChanged(replicas []Peer) {
+	if w, ok := a.app.(ReplicaWatcher); ok && a.primary {
+		w.ReplicasChanged(replicas)
+	}
+}
+
+// OwnershipChanged invokes OwnershipChanged on the wrapped Application,
+// ignoring changes where keys moved between virtual nodes of the local node.
+func (a *vnodeApp) OwnershipChanged(changes []OwnershipChange) {
+	w, ok := a.app.(OwnershipWatcher)
+	if !ok {
+		return
+	}
+
+	var filtered []OwnershipChange
+	for _, c := range changes {
+		if c.Peer.Addr != a.n.cfg.BroadcastAddr {
+			filtered = append(filtered, c)
+		}
+	}
+	if len(filtered) > 0 {
+		w.OwnershipChanged(filtered)
+	}
+}
@@ -0,0 +1,360 @@
+// Package pubsub implements topic-based publish/subscribe over a Croissant
+// cluster, following the design of Scribe.
+//
+// Every topic is owned by the node closest to the ID of the topic, called the
+// root of the topic. Subscribers form a multicast tree for each topic along
+// the routing paths towards its root, so publishing a message only involves
+// the nodes on the paths between the root and the topic's subscribers.
+// Messages are published by routing them to the root, which disseminates
+// them down the tree.
+//
+// Delivery is best-effort: messages published while the tree is being
+// repaired, such as after a node in the tree failed or the root of a topic
+// changed, may be lost, and messages may be delivered more than once.
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/connpool"
+	"github.com/rfratto/croissant/internal/pubsubpb"
+	"github.com/rfratto/croissant/node"
+	"google.golang.org/grpc"
+)
+
+// Config configures a PubSub.
+type Config struct {
+	// RefreshInterval is how often the node renews its subscriptions with
+	// its parent in the tree of each topic. Renewing subscriptions repairs
+	// the tree when nodes fail or the root of a topic changes. Children that
+	// don't renew their subscription within three intervals are removed.
+	// Defaults to 15s if unset.
+	RefreshInterval time.Duration
+
+	// BufferSize is the number of messages buffered for each Subscription.
+	// Messages delivered to a Subscription with a full buffer are dropped.
+	// Defaults to 64 if unset.
+	BufferSize int
+
+	// Log will be used for logging messages. Use node.NewSlogLogger to log
+	// with log/slog.
+	Log log.Logger
+}
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic string
+	Data  []byte
+}
+
+// PubSub implements publish/subscribe for a node. Every node in the cluster
+// must use PubSub for messages to be routed through them.
+type PubSub struct {
+	cfg  Config
+	n    *node.Node
+	gen  id.Generator
+	pool *connpool.Pool
+	self string // Address of the local node.
+
+	mut    sync.Mutex
+	topics map[string]*topic
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// topic is the local node's view of a topic's tree.
+type topic struct {
+	name     string
+	id       id.ID
+	parent   string               // Address of the parent. Empty if the node is the root or not subscribed.
+	children map[string]time.Time // Addresses of children to the time their subscription expires.
+	subs     map[*Subscription]struct{}
+}
+
+// active returns true if the local node needs to be part of t's tree.
+func (t *topic) active() bool {
+	return len(t.children) > 0 || len(t.subs) > 0
+}
+
+// New creates a new PubSub for n. The provided DialOptions are used when
+// communicating with peers. PubSub must be registered to the same gRPC server
+// as n with Register.
+func New(cfg Config, n *node.Node, dial ...grpc.DialOption) *PubSub {
+	if cfg.RefreshInterval == 0 {
+		cfg.RefreshInterval = 15 * time.Second
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = 64
+	}
+	if cfg.Log == nil {
+		cfg.Log = log.NewNopLogger()
+	}
+
+	p := &PubSub{
+		cfg:  cfg,
+		n:    n,
+		gen:  n.Generator(),
+		pool: connpool.New(250, dial...),
+		self: n.State().Node.Addr,
+
+		topics: make(map[string]*topic),
+
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Register registers the PubSub service to gRPC.
+func (p *PubSub) Register(s grpc.ServiceRegistrar) {
+	pubsubpb.RegisterPubSubServer(s, &server{p: p})
+}
+
+// Subscribe subscribes to a topic. Messages published to the topic are sent
+// to the returned Subscription until it is closed.
+func (p *PubSub) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	ch := make(chan Message, p.cfg.BufferSize)
+	sub := &Subscription{C: ch, ch: ch, p: p, topic: topic}
+
+	p.mut.Lock()
+	t := p.getTopic(topic)
+	t.subs[sub] = struct{}{}
+	p.mut.Unlock()
+
+	if err := p.join(ctx, t); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Publish publishes data to topic. Publish returns once the message reached
+// the root of the topic and was delivered down its tree.
+func (p *PubSub) Publish(ctx context.Context, topic string, data []byte) error {
+	return p.publish(ctx, &pubsubpb.Message{Topic: topic, Data: data})
+}
+
+// getTopic gets or creates the topic with the given name. p.mut must be held.
+func (p *PubSub) getTopic(name string) *topic {
+	t, ok := p.topics[name]
+	if !ok {
+		t = &topic{
+			name:     name,
+			id:       p.gen.Get(name),
+			children: make(map[string]time.Time),
+			subs:     make(map[*Subscription]struct{}),
+		}
+		p.topics[name] = t
+	}
+	return t
+}
+
+// join subscribes to t through the next hop towards the root of t, making
+// the local node part of t's tree. If the next hop changed since the last
+// call to join, the node unsubscribes from its previous parent.
+func (p *PubSub) join(ctx context.Context, t *topic) error {
+	next, self, err := p.n.NextPeer(t.id)
+	if err != nil {
+		return err
+	}
+
+	var parent string
+	if !self {
+		cli, err := p.client(next.Addr)
+		if err != nil {
+			return err
+		}
+		_, err = cli.Subscribe(ctx, &pubsubpb.SubscribeRequest{Topic: t.name, Subscriber: p.self})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s through %s: %w", t.name, next.Addr, err)
+		}
+		parent = next.Addr
+	}
+
+	p.mut.Lock()
+	prev := t.parent
+	t.parent = parent
+	p.mut.Unlock()
+
+	if prev != "" && prev != parent {
+		p.leave(ctx, t.name, prev)
+	}
+	return nil
+}
+
+// leave unsubscribes from topic through parent.
+func (p *PubSub) leave(ctx context.Context, topic string, parent string) {
+	cli, err := p.client(parent)
+	if err == nil {
+		_, err = cli.Unsubscribe(ctx, &pubsubpb.UnsubscribeRequest{Topic: topic, Subscriber: p.self})
+	}
+	if err != nil {
+		level.Warn(p.cfg.Log).Log("msg", "failed to unsubscribe from parent", "topic", topic, "parent", parent, "err", err)
+	}
+}
+
+// removeIfInactive removes t if the local node no longer needs to be part of
+// its tree, returning the parent to unsubscribe from. p.mut must be held.
+func (p *PubSub) removeIfInactive(t *topic) (parent string) {
+	if t.active() || p.topics[t.name] != t {
+		return ""
+	}
+	delete(p.topics, t.name)
+	return t.parent
+}
+
+func (p *PubSub) client(addr string) (pubsubpb.PubSubClient, error) {
+	cc, err := p.pool.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	return pubsubpb.NewPubSubClient(cc), nil
+}
+
+// publish routes msg to the next hop towards the root of its topic, or
+// delivers msg down the topic's tree if the local node is the root.
+func (p *PubSub) publish(ctx context.Context, msg *pubsubpb.Message) error {
+	next, self, err := p.n.NextPeer(p.gen.Get(msg.Topic))
+	if err != nil {
+		return err
+	}
+	if self {
+		p.deliver(ctx, msg)
+		return nil
+	}
+
+	cli, err := p.client(next.Addr)
+	if err != nil {
+		return err
+	}
+	_, err = cli.Publish(ctx, msg)
+	return err
+}
+
+// deliver sends msg to local subscribers of its topic and to the children of
+// the local node in the topic's tree.
+func (p *PubSub) deliver(ctx context.Context, msg *pubsubpb.Message) {
+	p.mut.Lock()
+	t, ok := p.topics[msg.Topic]
+	if !ok {
+		p.mut.Unlock()
+		return
+	}
+	children := make([]string, 0, len(t.children))
+	for child := range t.children {
+		children = append(children, child)
+	}
+	for sub := range t.subs {
+		sub.send(Message{Topic: msg.Topic, Data: msg.Data})
+	}
+	p.mut.Unlock()
+
+	var wg sync.WaitGroup
+	for _, child := range children {
+		wg.Add(1)
+		go func(child string) {
+			defer wg.Done()
+
+			cli, err := p.client(child)
+			if err == nil {
+				_, err = cli.Deliver(ctx, msg)
+			}
+			if err != nil {
+				// Children that are gone will be removed once their
+				// subscription expires.
+				level.Warn(p.cfg.Log).Log("msg", "failed to deliver message to child", "topic", msg.Topic, "child", child, "err", err)
+			}
+		}(child)
+	}
+	wg.Wait()
+}
+
+// run periodically renews subscriptions and removes expired children until
+// p is closed.
+func (p *PubSub) run() {
+	defer close(p.done)
+
+	t := time.NewTicker(p.cfg.RefreshInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-t.C:
+			p.refresh()
+		}
+	}
+}
+
+// refresh removes expired children and renews the subscriptions of every
+// topic the local node is part of.
+func (p *PubSub) refresh() {
+	var (
+		now    = time.Now()
+		active []*topic
+		leave  = make(map[string]string) // Topic to parent.
+	)
+
+	p.mut.Lock()
+	for _, t := range p.topics {
+		for child, expire := range t.children {
+			if now.After(expire) {
+				delete(t.children, child)
+			}
+		}
+		if parent := p.removeIfInactive(t); parent != "" {
+			leave[t.name] = parent
+		} else if t.active() {
+			active = append(active, t)
+		}
+	}
+	p.mut.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.RefreshInterval)
+	defer cancel()
+
+	for topic, parent := range leave {
+		p.leave(ctx, topic, parent)
+	}
+	for _, t := range active {
+		if err := p.join(ctx, t); err != nil {
+			level.Warn(p.cfg.Log).Log("msg", "failed to renew subscription", "topic", t.name, "err", err)
+		}
+	}
+}
+
+// Close closes every Subscription and unsubscribes the local node from the
+// tree of every topic.
+func (p *PubSub) Close() error {
+	close(p.quit)
+	<-p.done
+
+	p.mut.Lock()
+	leave := make(map[string]string, len(p.topics))
+	for name, t := range p.topics {
+		for sub := range t.subs {
+			sub.closeChannel()
+		}
+		if t.parent != "" {
+			leave[name] = t.parent
+		}
+	}
+	p.topics = make(map[string]*topic)
+	p.mut.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for topic, parent := range leave {
+		p.leave(ctx, topic, parent)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/node"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestPubSub(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	var (
+		nodes []*node.Node
+		pss   []*PubSub
+	)
+	for i := 0; i < 5; i++ {
+		n, ps := makeTestNode(t, log.With(l, "node", i))
+
+		var joinAddrs []string
+		if len(nodes) > 0 {
+			joinAddrs = []string{nodes[0].State().Node.Addr}
+		}
+		require.NoError(t, n.Join(ctx, joinAddrs))
+		nodes = append(nodes, n)
+		pss = append(pss, ps)
+	}
+
+	var subs []*Subscription
+	for _, ps := range pss[1:] {
+		sub, err := ps.Subscribe(ctx, "events")
+		require.NoError(t, err)
+		subs = append(subs, sub)
+	}
+
+	require.NoError(t, pss[0].Publish(ctx, "events", []byte("hello")))
+	for _, sub := range subs {
+		requireMessage(t, sub, "hello")
+	}
+
+	// Closed subscriptions shouldn't receive messages, but the others should.
+	subs[0].Close()
+	require.NoError(t, pss[2].Publish(ctx, "events", []byte("world")))
+	for _, sub := range subs[1:] {
+		requireMessage(t, sub, "world")
+	}
+	_, open := <-subs[0].C
+	require.False(t, open, "closed subscription should not receive messages")
+
+	// Topics without subscribers can be published to.
+	require.NoError(t, pss[1].Publish(ctx, "nobody", []byte("hello")))
+}
+
+func TestPubSub_Refresh(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	l := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	seed, seedPS := makeTestNode(t, log.With(l, "node", "seed"))
+	require.NoError(t, seed.Join(ctx, nil))
+
+	sub, err := seedPS.Subscribe(ctx, "events")
+	require.NoError(t, err)
+
+	// Nodes joining after the subscription may become the root of the topic.
+	// The tree should be repaired once subscriptions are renewed.
+	var pss []*PubSub
+	for i := 0; i < 4; i++ {
+		n, ps := makeTestNode(t, log.With(l, "node", i))
+		require.NoError(t, n.Join(ctx, []string{seed.State().Node.Addr}))
+		pss = append(pss, ps)
+	}
+
+	require.Eventually(t, func() bool {
+		for i, ps := range pss {
+			if err := ps.Publish(ctx, "events", []byte(fmt.Sprint(i))); err != nil {
+				return false
+			}
+		}
+		for i := range pss {
+			select {
+			case msg := <-sub.C:
+				if string(msg.Data) != fmt.Sprint(i) {
+					return false
+				}
+			case <-time.After(100 * time.Millisecond):
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func requireMessage(t *testing.T, sub *Subscription, data string) {
+	t.Helper()
+
+	select {
+	case msg := <-sub.C:
+		require.Equal(t, "events", msg.Topic)
+		require.Equal(t, data, string(msg.Data))
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for message")
+	}
+}
+
+func makeTestNode(t *testing.T, l log.Logger) (*node.Node, *PubSub) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var router node.Router
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(router.Unary()),
+		grpc.ChainStreamInterceptor(router.Stream()),
+	)
+
+	n, err := node.New(node.Config{
+		ID:            id.NewGenerator(32).Get(lis.Addr().String()),
+		BroadcastAddr: lis.Addr().String(),
+		Log:           l,
+	}, nopApplication{}, node.WithDialOptions(grpc.WithInsecure()))
+	require.NoError(t, err)
+	n.Register(srv)
+	router.SetNode(n)
+
+	ps := New(Config{RefreshInterval: 100 * time.Millisecond, Log: l}, n, grpc.WithInsecure())
+	ps.Register(srv)
+
+	go srv.Serve(lis)
+	t.Cleanup(func() {
+		_ = ps.Close()
+		_ = n.Close()
+		srv.Stop()
+	})
+	return n, ps
+}
+
+type nopApplication struct{}
+
+func (nopApplication) PeersChanged(ps []node.Peer) {}
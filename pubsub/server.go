@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/rfratto/croissant/internal/pubsubpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// server implements pubsubpb.PubSubServer for a PubSub.
+type server struct {
+	pubsubpb.UnimplementedPubSubServer
+	p *PubSub
+}
+
+func (s *server) Subscribe(ctx context.Context, req *pubsubpb.SubscribeRequest) (*emptypb.Empty, error) {
+	p := s.p
+
+	p.mut.Lock()
+	t := p.getTopic(req.Topic)
+	t.children[req.Subscriber] = time.Now().Add(3 * p.cfg.RefreshInterval)
+	joined := t.parent != ""
+	p.mut.Unlock()
+
+	// Extend the tree towards the root if the local node wasn't part of it
+	// yet.
+	if !joined {
+		if err := p.join(ctx, t); err != nil {
+			return nil, err
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) Unsubscribe(ctx context.Context, req *pubsubpb.UnsubscribeRequest) (*emptypb.Empty, error) {
+	p := s.p
+
+	p.mut.Lock()
+	var parent string
+	if t, ok := p.topics[req.Topic]; ok {
+		delete(t.children, req.Subscriber)
+		parent = p.removeIfInactive(t)
+	}
+	p.mut.Unlock()
+
+	if parent != "" {
+		p.leave(ctx, req.Topic, parent)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) Publish(ctx context.Context, msg *pubsubpb.Message) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, s.p.publish(ctx, msg)
+}
+
+func (s *server) Deliver(ctx context.Context, msg *pubsubpb.Message) (*emptypb.Empty, error) {
+	s.p.deliver(ctx, msg)
+	return &emptypb.Empty{}, nil
+}
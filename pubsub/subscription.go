@@ -0,0 +1,58 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// Subscription is a subscription to a topic.
+type Subscription struct {
+	// C receives messages published to the topic. C is closed when the
+	// Subscription is closed.
+	C <-chan Message
+
+	ch    chan Message
+	p     *PubSub
+	topic string
+
+	closeOnce sync.Once
+}
+
+// send sends msg to s, dropping it if the buffer of s is full. p.mut must be
+// held.
+func (s *Subscription) send(msg Message) {
+	select {
+	case s.ch <- msg:
+	default:
+		level.Warn(s.p.cfg.Log).Log("msg", "dropping message for slow subscriber", "topic", s.topic)
+	}
+}
+
+// closeChannel closes the channel of s. p.mut must be held.
+func (s *Subscription) closeChannel() {
+	s.closeOnce.Do(func() { close(s.ch) })
+}
+
+// Close unsubscribes from the topic. The local node leaves the topic's tree
+// if it has no other subscriptions to the topic and no children in the tree.
+func (s *Subscription) Close() {
+	p := s.p
+
+	p.mut.Lock()
+	var parent string
+	if t, ok := p.topics[s.topic]; ok {
+		delete(t.subs, s)
+		parent = p.removeIfInactive(t)
+	}
+	s.closeChannel()
+	p.mut.Unlock()
+
+	if parent != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.leave(ctx, s.topic, parent)
+	}
+}
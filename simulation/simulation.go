@@ -0,0 +1,318 @@
+// Package simulation models a Croissant cluster without any networking.
+// A simulated cluster is built by joining the routing states of nodes
+// together the same way real nodes do, and can be queried for how keys are
+// routed and distributed between nodes. Operators can use simulations to
+// choose IDs, virtual nodes, and routing parameters before deploying.
+package simulation
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/rfratto/croissant/internal/api"
+	"github.com/rfratto/croissant/node"
+)
+
+// Config describes a simulated cluster. Fields match their counterparts in
+// node.Config.
+type Config struct {
+	// NumNodes is the number of nodes in the cluster. Nodes get random IDs.
+	// Ignored if IDs is set.
+	NumNodes int
+	// IDs, if set, are the IDs of the nodes in the cluster.
+	IDs []id.ID
+
+	// NumVirtualNodes is the number of virtual nodes of each node. Virtual
+	// nodes get the same IDs as they would in a real cluster. Defaults to 1
+	// if unset.
+	NumVirtualNodes int
+
+	// NumLeaves and NumNeighbors are the number of leaves and neighbors of
+	// each node. Both default to 8 if unset.
+	NumLeaves, NumNeighbors int
+
+	// IDSize and IDBase are the size in bits of IDs and the base of digits
+	// used for routing. Default to 32 and 16 if unset.
+	IDSize, IDBase int
+
+	// Seed seeds the random IDs of nodes and the nodes each node joins
+	// through. Simulations with the same Config are identical.
+	Seed int64
+}
+
+// Cluster is a simulated cluster.
+type Cluster struct {
+	cfg Config
+
+	nodes  []id.ID                // Node IDs in join order.
+	vnodes map[id.ID][]*api.State // States of the virtual nodes of each node.
+	states map[id.ID]*api.State   // States by virtual node ID.
+	owners map[id.ID]id.ID        // Node ID by virtual node ID.
+	ring   []*api.State           // Every virtual node, sorted by ID.
+}
+
+// New builds a simulated cluster by joining its nodes one at a time, each
+// through a random node which already joined. Returns an error if cfg is
+// invalid or if a routing failure is found while joining.
+func New(cfg Config) (*Cluster, error) {
+	if cfg.NumVirtualNodes == 0 {
+		cfg.NumVirtualNodes = 1
+	}
+	if cfg.NumLeaves == 0 {
+		cfg.NumLeaves = 8
+	}
+	if cfg.NumNeighbors == 0 {
+		cfg.NumNeighbors = 8
+	}
+	if cfg.IDSize == 0 {
+		cfg.IDSize = 32
+	}
+	if cfg.IDBase == 0 {
+		cfg.IDBase = 16
+	}
+	switch {
+	case cfg.NumVirtualNodes < 0:
+		return nil, fmt.Errorf("NumVirtualNodes must not be negative")
+	case cfg.NumLeaves%2 != 0:
+		return nil, fmt.Errorf("leaves must be divisible by 2")
+	}
+	switch cfg.IDSize {
+	case 8, 16, 32, 64, 128:
+	default:
+		return nil, fmt.Errorf("invalid IDSize %d", cfg.IDSize)
+	}
+	switch cfg.IDBase {
+	case 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("invalid IDBase %d", cfg.IDBase)
+	}
+
+	var (
+		rnd = rand.New(rand.NewSource(cfg.Seed))
+		max = id.MaxForSize(cfg.IDSize)
+		gen = id.NewGenerator(cfg.IDSize)
+	)
+
+	ids := cfg.IDs
+	if ids == nil {
+		seen := make(map[id.ID]struct{}, cfg.NumNodes)
+		for len(ids) < cfg.NumNodes {
+			nodeID := id.ID{High: rnd.Uint64() & max.High, Low: rnd.Uint64() & max.Low}
+			if _, ok := seen[nodeID]; !ok {
+				seen[nodeID] = struct{}{}
+				ids = append(ids, nodeID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("cluster must have at least one node")
+	}
+
+	c := &Cluster{
+		cfg:    cfg,
+		vnodes: make(map[id.ID][]*api.State, len(ids)),
+		states: make(map[id.ID]*api.State, len(ids)*cfg.NumVirtualNodes),
+		owners: make(map[id.ID]id.ID, len(ids)*cfg.NumVirtualNodes),
+	}
+	for _, nodeID := range ids {
+		if id.Compare(nodeID, max) > 0 {
+			return nil, fmt.Errorf("ID %s is too big for IDSize %d", nodeID, cfg.IDSize)
+		} else if _, ok := c.vnodes[nodeID]; ok {
+			return nil, fmt.Errorf("duplicate node ID %s", nodeID)
+		}
+		c.nodes = append(c.nodes, nodeID)
+		c.vnodes[nodeID] = nil
+
+		for i := 0; i < cfg.NumVirtualNodes; i++ {
+			vnodeID := node.VirtualNodeID(gen, nodeID, i)
+			if _, ok := c.states[vnodeID]; ok {
+				return nil, fmt.Errorf("virtual node ID %s of node %s is already used", vnodeID, nodeID)
+			}
+			if err := c.join(rnd, vnodeID); err != nil {
+				return nil, err
+			}
+			c.vnodes[nodeID] = append(c.vnodes[nodeID], c.states[vnodeID])
+			c.owners[vnodeID] = nodeID
+		}
+	}
+
+	sort.Slice(c.ring, func(i, j int) bool {
+		return id.Compare(c.ring[i].Node.ID, c.ring[j].Node.ID) < 0
+	})
+	return c, nil
+}
+
+// join adds a virtual node to the cluster, collecting a Hello from every
+// node along the route to its ID from a random node.
+func (c *Cluster) join(rnd *rand.Rand, vnodeID id.ID) error {
+	s := api.NewState(api.Descriptor{ID: vnodeID, Addr: "simulation"}, c.cfg.NumLeaves, c.cfg.NumNeighbors, c.cfg.IDSize, c.cfg.IDBase)
+	if len(c.ring) > 0 {
+		var hellos []api.Hello
+		path, err := c.route(c.ring[rnd.Intn(len(c.ring))], vnodeID)
+		if err != nil {
+			return fmt.Errorf("joining %s: %w", vnodeID, err)
+		}
+		for _, hop := range path {
+			hellos = append(hellos, api.Hello{Initiator: hop.Node, State: hop})
+		}
+
+		s.Calculate(hellos)
+		for _, p := range s.Peers(true) {
+			c.states[p.ID].MixinState(s)
+		}
+	}
+
+	c.states[vnodeID] = s
+	c.ring = append(c.ring, s)
+	return nil
+}
+
+// route returns the states of the virtual nodes key is routed through from
+// start, ending with the virtual node closest to key.
+func (c *Cluster) route(start *api.State, key id.ID) ([]*api.State, error) {
+	var (
+		path = []*api.State{start}
+		seen = map[id.ID]struct{}{start.Node.ID: {}}
+	)
+	for cur := start; ; {
+		next, ok := api.NextHop(cur, key)
+		if !ok {
+			return nil, fmt.Errorf("no route to %s from %s", key, cur.Node.ID)
+		} else if next == cur.Node {
+			return path, nil
+		} else if _, ok := seen[next.ID]; ok {
+			return nil, fmt.Errorf("routing cycle to %s while routing %s", next.ID, key)
+		}
+		seen[next.ID] = struct{}{}
+
+		cur = c.states[next.ID]
+		path = append(path, cur)
+	}
+}
+
+// Nodes returns the IDs of the nodes in the cluster.
+func (c *Cluster) Nodes() []id.ID {
+	return append([]id.ID(nil), c.nodes...)
+}
+
+// VirtualNodes returns the IDs of the virtual nodes of the node with ID
+// nodeID. Returns nil if there is no such node.
+func (c *Cluster) VirtualNodes(nodeID id.ID) []id.ID {
+	var res []id.ID
+	for _, s := range c.vnodes[nodeID] {
+		res = append(res, s.Node.ID)
+	}
+	return res
+}
+
+// Route returns the IDs of the nodes key is routed through when sent to the
+// node with ID from, ending with the owner of key. Returns an error if
+// there is no such node or if routing fails.
+func (c *Cluster) Route(from, key id.ID) ([]id.ID, error) {
+	vnodes, ok := c.vnodes[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %s", from)
+	}
+
+	// Nodes route keys through their virtual node closest to the key.
+	start := vnodes[0]
+	for _, s := range vnodes[1:] {
+		if api.Closer(s.Node.ID, start.Node.ID, key, c.cfg.IDSize) {
+			start = s
+		}
+	}
+
+	path, err := c.route(start, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []id.ID
+	for _, s := range path {
+		if owner := c.owners[s.Node.ID]; len(res) == 0 || res[len(res)-1] != owner {
+			res = append(res, owner)
+		}
+	}
+	return res, nil
+}
+
+// Owner returns the ID of the node which owns key.
+func (c *Cluster) Owner(key id.ID) id.ID {
+	closest := c.ring[0]
+	for _, s := range c.ring[1:] {
+		if api.Closer(s.Node.ID, closest.Node.ID, key, c.cfg.IDSize) {
+			closest = s
+		}
+	}
+	return c.owners[closest.Node.ID]
+}
+
+// OwnedRanges returns the ranges of keys owned by the node with ID nodeID,
+// one per virtual node.
+func (c *Cluster) OwnedRanges(nodeID id.ID) []node.KeyRange {
+	var res []node.KeyRange
+	for _, s := range c.vnodes[nodeID] {
+		o := api.OwnershipOf(s)
+		res = append(res, node.KeyRange{Start: o.Range.Start, End: o.Range.End})
+	}
+	return res
+}
+
+// Distribution returns the fraction of the keyspace owned by each node.
+// The fractions add up to 1.
+func (c *Cluster) Distribution() map[id.ID]float64 {
+	var (
+		ringSize = new(big.Int).Lsh(big.NewInt(1), uint(c.cfg.IDSize))
+		total    = new(big.Float).SetInt(ringSize)
+		res      = make(map[id.ID]float64, len(c.nodes))
+	)
+	for _, s := range c.ring {
+		// Ranges are inclusive and may wrap around the ring.
+		r := api.OwnershipOf(s).Range
+		size := new(big.Int).Sub(toBig(r.End), toBig(r.Start))
+		if size.Sign() < 0 {
+			size.Add(size, ringSize)
+		}
+		size.Add(size, big.NewInt(1))
+
+		frac, _ := new(big.Float).Quo(new(big.Float).SetInt(size), total).Float64()
+		res[c.owners[s.Node.ID]] += frac
+	}
+	return res
+}
+
+// CountKeys returns the number of keys owned by each node. Nodes which
+// don't own any of keys aren't included.
+func (c *Cluster) CountKeys(keys []id.ID) map[id.ID]int {
+	res := make(map[id.ID]int, len(c.nodes))
+	for _, key := range keys {
+		res[c.Owner(key)]++
+	}
+	return res
+}
+
+// Check routes every key from every node and returns an error if a key
+// doesn't end up at its owner or if routing fails.
+func (c *Cluster) Check(keys []id.ID) error {
+	for _, key := range keys {
+		owner := c.Owner(key)
+		for _, nodeID := range c.nodes {
+			path, err := c.Route(nodeID, key)
+			if err != nil {
+				return err
+			} else if dest := path[len(path)-1]; dest != owner {
+				return fmt.Errorf("key %s routed from %s to %s, but %s owns it", key, nodeID, dest, owner)
+			}
+		}
+	}
+	return nil
+}
+
+func toBig(v id.ID) *big.Int {
+	res := new(big.Int).SetUint64(v.High)
+	res.Lsh(res, 64)
+	return res.Or(res, new(big.Int).SetUint64(v.Low))
+}
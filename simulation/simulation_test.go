@@ -0,0 +1,73 @@
+package simulation
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/rfratto/croissant/id"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCluster(t *testing.T) {
+	c, err := New(Config{NumNodes: 100, NumVirtualNodes: 2, IDBase: 8, Seed: 1})
+	require.NoError(t, err)
+	require.Len(t, c.Nodes(), 100)
+
+	rnd := rand.New(rand.NewSource(1))
+	keys := make([]id.ID, 100)
+	for i := range keys {
+		keys[i] = id.ID{Low: uint64(rnd.Uint32())}
+	}
+	require.NoError(t, c.Check(keys))
+
+	var (
+		dist  = c.Distribution()
+		total float64
+	)
+	require.Len(t, dist, 100)
+	for _, frac := range dist {
+		total += frac
+	}
+	require.InDelta(t, 1, total, 1e-9)
+
+	for _, key := range keys {
+		owner := c.Owner(key)
+
+		var owned bool
+		for _, r := range c.OwnedRanges(owner) {
+			owned = owned || r.Contains(key)
+		}
+		require.True(t, owned, "owner of %s doesn't own a range containing it", key)
+	}
+
+	var counted int
+	for _, n := range c.CountKeys(keys) {
+		counted += n
+	}
+	require.Equal(t, len(keys), counted)
+}
+
+func TestCluster_IDs(t *testing.T) {
+	var (
+		a = id.ID{Low: 0x1000}
+		b = id.ID{Low: 0x3000}
+	)
+	c, err := New(Config{IDs: []id.ID{a, b}, IDSize: 16})
+	require.NoError(t, err)
+
+	dist := c.Distribution()
+	require.InDelta(t, 0.5, dist[a], 0.001)
+	require.InDelta(t, 0.5, dist[b], 0.001)
+	require.Equal(t, a, c.Owner(id.ID{Low: 0x1fff}))
+	require.Equal(t, b, c.Owner(id.ID{Low: 0x2001}))
+
+	path, err := c.Route(a, id.ID{Low: 0x2fff})
+	require.NoError(t, err)
+	require.Equal(t, []id.ID{a, b}, path)
+
+	_, err = New(Config{IDs: []id.ID{a, a}, IDSize: 16})
+	require.Error(t, err, "duplicate IDs should be rejected")
+
+	_, err = New(Config{IDs: []id.ID{{Low: 1 << 20}}, IDSize: 16})
+	require.Error(t, err, "IDs bigger than IDSize should be rejected")
+}